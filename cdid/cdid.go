@@ -3,6 +3,8 @@ package cdid
 import (
 	"crypto/rand"
 	"encoding/base32"
+	"errors"
+	"hash/crc32"
 	"time"
 )
 
@@ -12,9 +14,33 @@ var (
 	decoder  = base32.NewEncoding(encoding).WithPadding(base32.NoPadding)
 )
 
+var (
+	ErrInvalidLength    = errors.New("cdid: invalid length")
+	ErrInvalidChar      = errors.New("cdid: invalid character")
+	ErrInvalidChecksum  = errors.New("cdid: checksum mismatch")
+	ErrUnknownVersion   = errors.New("cdid: unknown version")
+	ErrUnexpectedPrefix = errors.New("cdid: unexpected prefix")
+)
+
+// Version is the textual format of a CDID.
+// V1 is the original 26 characters form. V2 stores the version in the two spare bits of
+// the last character and appends a checksum character, which makes it 27 characters long.
+type Version uint8
+
+const (
+	V1 Version = 1
+	V2 Version = 2
+)
+
+const (
+	lenV1 = 26
+	lenV2 = 27
+)
+
 type CDID struct {
-	data [10]byte
-	time [6]byte
+	data    [10]byte
+	time    [6]byte
+	version Version
 }
 
 func New(data [10]byte, t time.Time) CDID {
@@ -41,6 +67,13 @@ func Make() CDID {
 	return NewWithAutoTime(data)
 }
 
+// NewV2 is the same as New but the result is formatted as V2.
+func NewV2(data [10]byte, t time.Time) CDID {
+	c := New(data, t)
+	c.version = V2
+	return c
+}
+
 func (c *CDID) SetData(data [10]byte) {
 	c.data = data
 }
@@ -73,48 +106,164 @@ func (c *CDID) Bytes() []byte {
 	return append(c.data[:], c.time[:]...)
 }
 
+// Version returns the textual format used by String. The zero value is formatted as V1.
+func (c CDID) Version() Version {
+	if c.version == 0 {
+		return V1
+	}
+	return c.version
+}
+
+func (c *CDID) SetVersion(v Version) {
+	c.version = v
+}
+
 func (c CDID) String() string {
-	return encoder.EncodeToString(c.Bytes())
+	body := encoder.EncodeToString(c.Bytes())
+	if c.Version() != V2 {
+		return body
+	}
+
+	// 16 bytes are 128 bits, so the last of the 26 characters carries 2 unused bits.
+	last := decodeChar(body[lenV1-1]) | versionBits(V2)
+	return body[:lenV1-1] + string(encoding[last]) + string(encoding[checksum(c.Bytes())])
 }
 
+// Parse decodes both V1 and V2 CDIDs without a prefix.
+// It rejects any length other than 26 or 27, non canonical V1 strings and V2 strings with a wrong checksum.
 func Parse(s string) (CDID, error) {
-	b, err := decoder.DecodeString(s)
-	if err != nil {
-		return CDID{}, err
+	switch len(s) {
+	case lenV1, lenV2:
+	default:
+		return CDID{}, ErrInvalidLength
 	}
 
-	if len(b) != 16 {
-		return CDID{}, nil
+	for i := 0; i < len(s); i++ {
+		if !IsCDIDChar(s[i]) {
+			return CDID{}, ErrInvalidChar
+		}
+	}
+
+	version := V1
+	body := s[:lenV1]
+	if len(s) == lenV2 {
+		if decodeChar(s[lenV1-1])&3 != versionBits(V2) {
+			return CDID{}, ErrUnknownVersion
+		}
+		version = V2
+		body = s[:lenV1-1] + string(encoding[decodeChar(s[lenV1-1])&^3])
+	} else if decodeChar(s[lenV1-1])&3 != 0 {
+		return CDID{}, ErrUnknownVersion
+	}
+
+	b, err := decoder.DecodeString(body)
+	if err != nil {
+		return CDID{}, err
 	}
 
 	var c CDID
 	copy(c.data[:], b[:10])
 	copy(c.time[:], b[10:])
+	c.version = version
+
+	if version == V2 && encoding[checksum(b)] != s[lenV2-1] {
+		return CDID{}, ErrInvalidChecksum
+	}
+
 	return c, nil
 }
 
+// ParsePrefixed decodes an ID such as a message or an association ID, which is a CDID led by a type prefix.
+// A V1 ID with a prefix and a V2 ID without one have the same length. They are told apart by the last
+// character: a canonical V1 string always ends with zeroed spare bits, while a V2 checksum never does.
+func ParsePrefixed(s string, expectPrefix byte) (CDID, error) {
+	prefix, c, err := Split(s)
+	if err != nil {
+		return CDID{}, err
+	}
+	if prefix != expectPrefix {
+		return CDID{}, ErrUnexpectedPrefix
+	}
+	return c, nil
+}
+
+// Split decodes an ID with an optional prefix. The prefix is 0 when the ID has none.
+func Split(s string) (byte, CDID, error) {
+	var prefix byte
+	switch len(s) {
+	case lenV1:
+	case lenV2:
+		if IsCDIDChar(s[lenV2-1]) && decodeChar(s[lenV2-1])&3 == 0 {
+			prefix, s = s[0], s[1:]
+		}
+	case lenV2 + 1:
+		prefix, s = s[0], s[1:]
+	default:
+		return 0, CDID{}, ErrInvalidLength
+	}
+
+	c, err := Parse(s)
+	if err != nil {
+		return 0, CDID{}, err
+	}
+	return prefix, c, nil
+}
+
+// TimeOf returns the creation time embedded in an ID, with or without a prefix.
+// It is meant for pagination cursors, which only need the position of the item in time.
+func TimeOf(id string) (time.Time, error) {
+	_, c, err := Split(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.GetTime(), nil
+}
+
+func versionBits(v Version) byte {
+	return byte(v-1) & 3
+}
+
+func decodeChar(c byte) byte {
+	for i := 0; i < len(encoding); i++ {
+		if encoding[i] == c {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+// checksum maps the crc of the bytes onto the 24 characters whose spare bits are not zero,
+// so that a V2 string can never be mistaken for a canonical V1 string with a prefix.
+func checksum(b []byte) byte {
+	n := byte(crc32.ChecksumIEEE(b) % 24)
+	return n/3*4 + n%3 + 1
+}
+
 func IsCDIDChar(c byte) bool {
 	// 0-9 a-z but no i, l, o, u
 	return ((c >= '0' && c <= '9') || (c >= 'a' && c <= 'z')) && c != 'i' && c != 'l' && c != 'o' && c != 'u'
 }
 
+// IsSeemsCDID reports whether the string looks like a CDID of either version, with or without the expected prefix.
+// Unlike Parse it does not verify the checksum.
 func IsSeemsCDID(str string, expectPrefix byte) bool {
-	if len(str) == 27 {
-		if str[0] != expectPrefix {
+	for i := 0; i < len(str); i++ {
+		if !IsCDIDChar(str[i]) {
 			return false
 		}
-		str = str[1:]
 	}
 
-	if len(str) != 26 {
-		return false
-	}
-
-	for i := 0; i < 26; i++ {
-		if !IsCDIDChar(str[i]) {
-			return false
+	switch len(str) {
+	case lenV1:
+		return true
+	case lenV2:
+		if decodeChar(str[lenV2-1])&3 == 0 {
+			return str[0] == expectPrefix
 		}
+		return decodeChar(str[lenV1-1])&3 == versionBits(V2)
+	case lenV2 + 1:
+		return str[0] == expectPrefix
 	}
 
-	return true
+	return false
 }
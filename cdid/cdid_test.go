@@ -0,0 +1,108 @@
+package cdid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testData = [10]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23}
+	testTime = time.Date(2024, 5, 1, 12, 34, 56, 789000000, time.UTC)
+)
+
+func TestParseV1(t *testing.T) {
+	id := New(testData, testTime)
+	str := id.String()
+	assert.Len(t, str, 26)
+
+	parsed, err := Parse(str)
+	if assert.NoError(t, err) {
+		assert.Equal(t, V1, parsed.Version())
+		assert.Equal(t, str, parsed.String())
+		assert.True(t, testTime.Equal(parsed.GetTime()))
+	}
+}
+
+func TestParseV2(t *testing.T) {
+	id := NewV2(testData, testTime)
+	str := id.String()
+	assert.Len(t, str, 27)
+	assert.Equal(t, New(testData, testTime).String()[:25], str[:25])
+
+	parsed, err := Parse(str)
+	if assert.NoError(t, err) {
+		assert.Equal(t, V2, parsed.Version())
+		assert.Equal(t, str, parsed.String())
+		assert.True(t, testTime.Equal(parsed.GetTime()))
+	}
+
+	// flip one character in the body
+	broken := []byte(str)
+	if broken[3] == '0' {
+		broken[3] = '1'
+	} else {
+		broken[3] = '0'
+	}
+	_, err = Parse(string(broken))
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := New(testData, testTime).String()
+
+	_, err := Parse("")
+	assert.ErrorIs(t, err, ErrInvalidLength)
+
+	_, err = Parse(valid[:25])
+	assert.ErrorIs(t, err, ErrInvalidLength)
+
+	_, err = Parse(valid + "00")
+	assert.ErrorIs(t, err, ErrInvalidLength)
+
+	_, err = Parse(valid[:25] + "u")
+	assert.ErrorIs(t, err, ErrInvalidChar)
+
+	// spare bits set without the checksum character
+	_, err = Parse(valid[:25] + "1")
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
+
+func TestSplit(t *testing.T) {
+	v1 := New(testData, testTime).String()
+	v2 := NewV2(testData, testTime).String()
+
+	for _, id := range []string{v1, v2} {
+		prefix, c, err := Split(id)
+		if assert.NoError(t, err) {
+			assert.Equal(t, byte(0), prefix)
+			assert.Equal(t, id, c.String())
+		}
+
+		prefix, c, err = Split("m" + id)
+		if assert.NoError(t, err) {
+			assert.Equal(t, byte('m'), prefix)
+			assert.Equal(t, id, c.String())
+		}
+
+		_, err = ParsePrefixed("m"+id, 'a')
+		assert.ErrorIs(t, err, ErrUnexpectedPrefix)
+
+		assert.True(t, IsSeemsCDID(id, 'm'))
+		assert.True(t, IsSeemsCDID("m"+id, 'm'))
+	}
+
+	assert.False(t, IsSeemsCDID("a"+v1, 'm'))
+	assert.False(t, IsSeemsCDID("a"+v2, 'm'))
+}
+
+func TestTimeOf(t *testing.T) {
+	got, err := TimeOf("a" + NewV2(testData, testTime).String())
+	if assert.NoError(t, err) {
+		assert.True(t, testTime.Equal(got))
+	}
+
+	_, err = TimeOf("world")
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type hostBreaker struct {
+	state     breakerState
+	failures  int
+	trips     int
+	openUntil time.Time
+}
+
+// circuitBreaker tracks failures per host.
+// after threshold consecutive failures the host is opened and requests fail fast.
+// once the cooldown passes a single probe request is let through; the cooldown doubles each time the probe fails.
+type circuitBreaker struct {
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostBreaker
+	now   func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown, maxCooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: maxCooldown,
+		hosts:       make(map[string]*hostBreaker),
+		now:         time.Now,
+	}
+}
+
+// allow reports whether a request to host may be sent
+func (b *circuitBreaker) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[host]
+	if !ok {
+		return true
+	}
+
+	switch h.state {
+	case breakerOpen:
+		if b.now().Before(h.openUntil) {
+			return false
+		}
+		h.state = breakerHalfOpen
+		h.openUntil = b.now().Add(b.cooldown)
+		return true
+	case breakerHalfOpen:
+		// a probe is already in flight. let another one through only if it seems lost
+		if b.now().Before(h.openUntil) {
+			return false
+		}
+		h.openUntil = b.now().Add(b.cooldown)
+		return true
+	default:
+		return true
+	}
+}
+
+// isOpen reports whether host is failing fast, without consuming the probe
+func (b *circuitBreaker) isOpen(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[host]
+	if !ok {
+		return false
+	}
+	return h.state == breakerOpen && b.now().Before(h.openUntil)
+}
+
+// success closes the breaker of host. true is returned if the host was recovering from an outage.
+func (b *circuitBreaker) success(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[host]
+	if !ok {
+		return false
+	}
+	recovered := h.state != breakerClosed
+	if recovered {
+		slog.Info("domain is back online", slog.String("domain", host), slog.String("module", "client"))
+	}
+	delete(b.hosts, host)
+	return recovered
+}
+
+func (b *circuitBreaker) failure(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.host(host)
+	h.failures++
+	if h.state == breakerHalfOpen || h.failures >= b.threshold {
+		b.open(host, h, "consecutive failures")
+	}
+}
+
+// trip opens the breaker of host regardless of consecutive failures, e.g. when its error rate is too high
+func (b *circuitBreaker) trip(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.host(host)
+	if h.state == breakerOpen && b.now().Before(h.openUntil) {
+		return
+	}
+	b.open(host, h, "error rate")
+}
+
+func (b *circuitBreaker) host(host string) *hostBreaker {
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &hostBreaker{}
+		b.hosts[host] = h
+	}
+	return h
+}
+
+func (b *circuitBreaker) open(host string, h *hostBreaker, reason string) {
+	cooldown := b.cooldown << h.trips
+	if cooldown > b.maxCooldown || cooldown <= 0 {
+		cooldown = b.maxCooldown
+	} else {
+		h.trips++
+	}
+	h.state = breakerOpen
+	h.openUntil = b.now().Add(cooldown)
+	slog.Warn(
+		"domain is offline",
+		slog.String("domain", host),
+		slog.String("reason", reason),
+		slog.Int("failures", h.failures),
+		slog.Duration("cooldown", cooldown),
+		slog.String("module", "client"),
+	)
+}
+
+// status returns the state of host as online, offline or probing, and when an open breaker cools down
+func (b *circuitBreaker) status(host string) (string, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[host]
+	if !ok {
+		return "online", time.Time{}
+	}
+	switch {
+	case h.state == breakerOpen && b.now().Before(h.openUntil):
+		return "offline", h.openUntil
+	case h.state != breakerClosed:
+		return "probing", time.Time{}
+	default:
+		return "online", time.Time{}
+	}
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(2, time.Second, 4*time.Second)
+	b.now = func() time.Time { return now }
+
+	b.failure("a.example")
+	assert.True(t, b.allow("a.example"))
+
+	// threshold reached
+	b.failure("a.example")
+	assert.False(t, b.allow("a.example"))
+	assert.True(t, b.isOpen("a.example"))
+	assert.True(t, b.allow("b.example"))
+
+	// cooldown passed: only one probe goes through
+	now = now.Add(time.Second)
+	assert.True(t, b.allow("a.example"))
+	assert.False(t, b.allow("a.example"))
+
+	// failed probe doubles the cooldown
+	b.failure("a.example")
+	now = now.Add(time.Second)
+	assert.False(t, b.allow("a.example"))
+	now = now.Add(time.Second)
+	assert.True(t, b.allow("a.example"))
+
+	b.success("a.example")
+	assert.False(t, b.isOpen("a.example"))
+	assert.True(t, b.allow("a.example"))
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+
+	"github.com/totegamma/concurrent/x/cache"
+)
+
+type cacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// lruCache is a size bounded in-memory cache with per entry expiry
+type lruCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+func (l *lruCache) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if l.now().After(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (l *lruCache) set(key string, value []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value = value
+		entry.expiresAt = l.now().Add(ttl)
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&cacheEntry{key: key, value: value, expiresAt: l.now().Add(ttl)})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (l *lruCache) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+	}
+}
+
+// responseCache caches decoded responses of remote domains.
+// entries are looked up in the in-memory lru first, then in memcached if configured.
+// keys carry a per domain generation which is bumped when the domain is remapped,
+// so entries fetched from the previous address are never served again.
+type responseCache struct {
+	lru *lruCache
+	mc  cache.Cache
+
+	mu          sync.Mutex
+	generations map[string]int
+}
+
+func newResponseCache(size int, mc cache.Cache) *responseCache {
+	if size <= 0 && mc == nil {
+		return nil
+	}
+	cache := &responseCache{
+		mc:          mc,
+		generations: make(map[string]int),
+	}
+	if size > 0 {
+		cache.lru = newLRUCache(size)
+	}
+	return cache
+}
+
+func (r *responseCache) key(kind, domain, id string) string {
+	r.mu.Lock()
+	generation := r.generations[domain]
+	r.mu.Unlock()
+	return fmt.Sprintf("client:%s:%s:%d:%s", kind, domain, generation, id)
+}
+
+func (r *responseCache) get(kind, domain, id string, ttl time.Duration, value any) bool {
+	if r == nil {
+		return false
+	}
+	key := r.key(kind, domain, id)
+
+	if r.lru != nil {
+		if data, ok := r.lru.get(key); ok {
+			return json.Unmarshal(data, value) == nil
+		}
+	}
+	if r.mc != nil {
+		item, err := r.mc.Get(key)
+		if err == nil {
+			if r.lru != nil {
+				r.lru.set(key, item.Value, ttl)
+			}
+			return json.Unmarshal(item.Value, value) == nil
+		}
+	}
+	return false
+}
+
+func (r *responseCache) set(kind, domain, id string, value any, ttl time.Duration) {
+	if r == nil || ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	key := r.key(kind, domain, id)
+
+	if r.lru != nil {
+		r.lru.set(key, data, ttl)
+	}
+	if r.mc != nil {
+		r.mc.Set(&memcache.Item{Key: key, Value: data, Expiration: int32(ttl.Seconds())})
+	}
+}
+
+func (r *responseCache) delete(kind, domain, id string) {
+	if r == nil {
+		return
+	}
+	key := r.key(kind, domain, id)
+
+	if r.lru != nil {
+		r.lru.delete(key)
+	}
+	if r.mc != nil {
+		r.mc.Delete(key)
+	}
+}
+
+// forget makes every cached entry of the domain unreachable
+func (r *responseCache) forget(domain string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.generations[domain]++
+	r.mu.Unlock()
+}
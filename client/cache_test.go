@@ -0,0 +1,43 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	now := time.Now()
+	l := newLRUCache(2)
+	l.now = func() time.Time { return now }
+
+	l.set("a", []byte("1"), time.Minute)
+	l.set("b", []byte("2"), time.Minute)
+	_, ok := l.get("a")
+	assert.True(t, ok)
+
+	// b is the least recently used
+	l.set("c", []byte("3"), time.Minute)
+	_, ok = l.get("b")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = l.get("a")
+	assert.False(t, ok)
+}
+
+func TestResponseCacheForget(t *testing.T) {
+	r := newResponseCache(10, nil)
+
+	r.set("entity", "a.example", "con1", "alice", time.Minute)
+
+	var value string
+	assert.True(t, r.get("entity", "a.example", "con1", time.Minute, &value))
+	assert.Equal(t, "alice", value)
+
+	r.forget("a.example")
+	assert.False(t, r.get("entity", "a.example", "con1", time.Minute, &value))
+
+	assert.Nil(t, newResponseCache(0, nil))
+}
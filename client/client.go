@@ -5,25 +5,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/coalesce"
+	"github.com/totegamma/concurrent/x/cache"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	defaultTimeout = 3 * time.Second
 )
 
+// call types, which the timeouts of WithCallTimeout are set for
+const (
+	CallCommit      = "commit"
+	CallEntity      = "entity"
+	CallMessage     = "message"
+	CallAssociation = "association"
+	CallProfile     = "profile"
+	CallTimeline    = "timeline"
+	CallChunks      = "chunks"
+	CallKey         = "key"
+	CallDomain      = "domain"
+	CallDiscover    = "discover"
+	CallRelay       = "relay"
+)
+
+// ErrDomainOffline is returned without sending a request while the circuit breaker of the domain is open
+var ErrDomainOffline = errors.New("Domain is offline")
+
 var tracer = otel.Tracer("client")
 
 type Client interface {
@@ -38,9 +64,18 @@ type Client interface {
 	GetChunks(ctx context.Context, domain string, timelines []string, queryTime time.Time, opts *Options) (map[string]core.Chunk, error)
 	GetKey(ctx context.Context, domain, id string, opts *Options) ([]core.Key, error)
 	GetDomain(ctx context.Context, domain string, opts *Options) (core.Domain, error)
+	Hello(ctx context.Context, domain, fqdn string, opts *Options) (core.Domain, error)
 	GetChunkItrs(ctx context.Context, domain string, timelines []string, epoch string, opts *Options) (map[string]string, error)
 	GetChunkBodies(ctx context.Context, domain string, query map[string]string, opts *Options) (map[string]core.Chunk, error)
 	GetRetracted(ctx context.Context, domain string, timelines []string, opts *Options) (map[string][]string, error)
+	DiscoverTimelines(ctx context.Context, domain string, schemas []string, limit int, opts *Options) ([]core.DiscoveredTimeline, error)
+	RelayEvents(ctx context.Context, domain, body string, opts *Options) error
+	Subscribe(ctx context.Context, domain string, timelines []string, opts *Options) (Subscription, error)
+	FederationStats() []core.FederationStats
+
+	InvalidateEntity(domain, address string)
+	InvalidateDomain(domain string)
+	InvalidateTimeline(domain, id string)
 }
 
 type remapRecord struct {
@@ -49,35 +84,225 @@ type remapRecord struct {
 }
 
 type client struct {
-	client     *http.Client
-	lastFailed map[string]time.Time
-	failCount  map[string]int
-	userAgent  string
-	hostRemap  map[string]remapRecord
+	client    *http.Client
+	transport http.RoundTripper
+	breaker   *circuitBreaker
+	stats     *domainStats
+	cache     *responseCache
+	config    Config
+	userAgent string
+	hostRemap map[string]remapRecord
+
+	// concurrent fetches of the same resource share one request
+	entities  coalesce.Group[core.Entity]
+	timelines coalesce.Group[core.Timeline]
+	chunks    coalesce.Group[map[string]core.Chunk]
+	itrs      coalesce.Group[map[string]string]
+}
+
+// Config tunes timeouts, retries, circuit breaking and connection pooling of a Client
+type Config struct {
+	Timeout time.Duration // per call, including retries
+
+	MaxRetries     int // retries of idempotent requests on network errors and 502/503/504
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	BreakerThreshold   int // consecutive failures which open the breaker of a host
+	BreakerCooldown    time.Duration
+	BreakerMaxCooldown time.Duration
+	BreakerErrorRate   float64 // failure ratio in the recent window which also opens the breaker. 0 disables
+	BreakerMinRequests int64   // requests in the window before the error rate is considered
+
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+
+	CacheSize        int         // entries of the in-memory response cache. 0 disables it
+	CacheMemcache    cache.Cache // optional second level shared between processes
+	EntityCacheTTL   time.Duration
+	DomainCacheTTL   time.Duration
+	TimelineCacheTTL time.Duration
+
+	// deadline of each call type, retries included. Timeout still bounds every call
+	CallTimeouts map[string]time.Duration
+
+	// identity of the domain the chunk fetches are signed with. see WithDomainIdentity
+	FQDN       string
+	CSID       string
+	PrivateKey string
+}
+
+// ClientOption modifies the Config of a Client
+type ClientOption func(*Config)
+
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+func WithRetry(maxRetries int, baseDelay, maxDelay time.Duration) ClientOption {
+	return func(c *Config) {
+		c.MaxRetries = maxRetries
+		c.RetryBaseDelay = baseDelay
+		c.RetryMaxDelay = maxDelay
+	}
+}
+
+func WithCircuitBreaker(threshold int, cooldown, maxCooldown time.Duration) ClientOption {
+	return func(c *Config) {
+		c.BreakerThreshold = threshold
+		c.BreakerCooldown = cooldown
+		c.BreakerMaxCooldown = maxCooldown
+	}
+}
+
+// WithErrorRateBreaker opens the breaker of a host whose failure ratio in the last 5 minutes exceeds rate,
+// once at least minRequests were sent to it
+func WithErrorRateBreaker(rate float64, minRequests int64) ClientOption {
+	return func(c *Config) {
+		c.BreakerErrorRate = rate
+		c.BreakerMinRequests = minRequests
+	}
 }
 
-func NewClient() Client {
+func WithConnectionPool(maxIdleConnsPerHost, maxConnsPerHost int, idleConnTimeout time.Duration) ClientOption {
+	return func(c *Config) {
+		c.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		c.MaxConnsPerHost = maxConnsPerHost
+		c.IdleConnTimeout = idleConnTimeout
+	}
+}
+
+// WithCache enables caching of GetEntity, GetDomain and GetTimeline responses. mc may be nil
+func WithCache(size int, mc cache.Cache) ClientOption {
+	return func(c *Config) {
+		c.CacheSize = size
+		c.CacheMemcache = mc
+	}
+}
+
+func WithCacheTTL(entity, domain, timeline time.Duration) ClientOption {
+	return func(c *Config) {
+		c.EntityCacheTTL = entity
+		c.DomainCacheTTL = domain
+		c.TimelineCacheTTL = timeline
+	}
+}
+
+// WithCallTimeout bounds the calls of the type with a context deadline.
+// it is meant to be shorter than the overall Timeout, e.g. for calls made while a user waits
+func WithCallTimeout(call string, timeout time.Duration) ClientOption {
+	return func(c *Config) {
+		if c.CallTimeouts == nil {
+			c.CallTimeouts = make(map[string]time.Duration)
+		}
+		c.CallTimeouts[call] = timeout
+	}
+}
+
+func defaultConfig() Config {
+	return Config{
+		Timeout:             defaultTimeout,
+		MaxRetries:          2,
+		RetryBaseDelay:      100 * time.Millisecond,
+		RetryMaxDelay:       time.Second,
+		BreakerThreshold:    5,
+		BreakerCooldown:     time.Second,
+		BreakerMaxCooldown:  10 * time.Minute,
+		BreakerErrorRate:    0.5,
+		BreakerMinRequests:  20,
+		MaxIdleConnsPerHost: 16,
+		MaxConnsPerHost:     64,
+		IdleConnTimeout:     90 * time.Second,
+		EntityCacheTTL:      5 * time.Minute,
+		DomainCacheTTL:      10 * time.Minute,
+		TimelineCacheTTL:    time.Minute,
+	}
+}
+
+func NewClient(opts ...ClientOption) Client {
+	config := defaultConfig()
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	// one transport is shared by all hosts so that idle connections are pooled
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = config.MaxConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+
+	// each attempt gets its own span, and the trace context is propagated with the request
+	instrumented := otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return "HTTP " + req.Method + " " + req.URL.Host
+	}))
+
 	httpClient := http.Client{
-		Timeout: defaultTimeout,
+		Timeout: config.Timeout,
 	}
 	client := &client{
-		client:     &httpClient,
-		lastFailed: make(map[string]time.Time),
-		failCount:  make(map[string]int),
+		client:    &httpClient,
+		transport: instrumented,
+		breaker:   newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown, config.BreakerMaxCooldown),
+		stats:     newDomainStats(),
+		cache:     newResponseCache(config.CacheSize, config.CacheMemcache),
+		config:    config,
 	}
 	httpClient.Transport = client
 	client.hostRemap = make(map[string]remapRecord)
-	go client.UpKeeper()
 	return client
 }
 
 type Options struct {
 	AuthToken string
+	NoCache   bool // bypass the response cache
+}
+
+// shareable reports whether concurrent calls for the same resource may share one request.
+// authorized responses may depend on the requester, so they are fetched by each caller
+func shareable(opts *Options) bool {
+	return opts == nil || opts.AuthToken == ""
+}
+
+// coalesced fetches with the group when the call is shareable, and directly otherwise.
+// the passport of the requester is sent with the request, and the remote may answer according to it,
+// so calls made for different requesters never share a response.
+// a caller whose deadline passes while it waits gets core.ErrorTimeout, as if it had made the request itself
+func coalesced[T any](ctx context.Context, group *coalesce.Group[T], domain, key string, opts *Options, fetch func(ctx context.Context) (T, error)) (T, error) {
+	if !shareable(opts) {
+		return fetch(ctx)
+	}
+	if passport, ok := ctx.Value(core.RequesterPassportKey).(string); ok && passport != "" {
+		key += "\n" + passport
+	}
+	value, shared, err := group.Do(ctx, key, fetch)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("coalesced", shared))
+	if errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, core.ErrorTimeout{}) {
+		err = core.NewErrorTimeout("remote "+domain, 0, err)
+	}
+	return value, err
+}
+
+// sorted returns a sorted copy of the ids, so that the same set is asked for with the same url
+func sorted(ids []string) []string {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return ids
+}
+
+// cacheable reports whether a response for the request may be served from or stored to the cache.
+// authorized responses may depend on the requester, so they are never cached.
+func cacheable(opts *Options) bool {
+	return opts == nil || (opts.AuthToken == "" && !opts.NoCache)
 }
 
 func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.Header.Set("User-Agent", c.userAgent)
 
+	domain := req.Host
+
 	// remap host
 	if remap, ok := c.hostRemap[req.Host]; ok {
 		req.Host = remap.Remap
@@ -89,7 +314,135 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}
 
-	return http.DefaultTransport.RoundTrip(req)
+	if !c.breaker.allow(domain) {
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	started := time.Now()
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = c.transport.RoundTrip(req)
+		if !idempotent || attempt >= c.config.MaxRetries || !isRetryable(resp, err) || req.Context().Err() != nil {
+			break
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// cancellation by the caller says nothing about the health of the remote
+	if req.Context().Err() == nil {
+		failure := err
+		if failure == nil && resp.StatusCode >= http.StatusInternalServerError {
+			failure = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		c.stats.record(domain, time.Since(started), failure)
+
+		if failure != nil {
+			c.breaker.failure(domain)
+			if c.config.BreakerErrorRate > 0 {
+				rate, requests := c.stats.errorRate(domain)
+				if requests >= c.config.BreakerMinRequests && rate >= c.config.BreakerErrorRate {
+					c.breaker.trip(domain)
+				}
+			}
+		} else if c.breaker.success(domain) {
+			// the failures before the outage would trip the breaker again right away
+			c.stats.reset(domain)
+		}
+	}
+
+	if err != nil && req.Context().Err() == nil {
+		err = core.NewErrorRemoteUnavailable(domain, err)
+	}
+	return resp, err
+}
+
+// FederationStats returns the success rate, latency and breaker state of the remote domains contacted recently
+func (c *client) FederationStats() []core.FederationStats {
+	snapshots := c.stats.snapshot()
+	result := make([]core.FederationStats, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		stats := core.FederationStats{
+			Domain:    snapshot.host,
+			Requests:  snapshot.window.requests,
+			Failures:  snapshot.window.failures,
+			LastError: snapshot.lastError,
+			LastSeen:  snapshot.lastSeen,
+		}
+		if snapshot.window.requests > 0 {
+			stats.SuccessRate = float64(snapshot.window.requests-snapshot.window.failures) / float64(snapshot.window.requests)
+			stats.AvgLatency = float64(snapshot.window.latency.Milliseconds()) / float64(snapshot.window.requests)
+		}
+		stats.MaxLatency = float64(snapshot.window.maxDelay.Milliseconds())
+
+		state, openUntil := c.breaker.status(snapshot.host)
+		stats.State = state
+		if !openUntil.IsZero() {
+			stats.OpenUntil = &openUntil
+		}
+
+		result = append(result, stats)
+	}
+	return result
+}
+
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns an exponential delay with jitter for the attempt
+func (c *client) backoff(attempt int) time.Duration {
+	delay := c.config.RetryBaseDelay << attempt
+	if delay > c.config.RetryMaxDelay || delay <= 0 {
+		delay = c.config.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// call starts the span of a call to a remote domain and applies the deadline of the call type.
+// endpoint is the route of the remote api, without the ids, so that spans can be grouped by it
+func (c *client) call(ctx context.Context, name, kind, domain, endpoint string) (context.Context, trace.Span, context.CancelFunc) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("domain", domain),
+		attribute.String("call", kind),
+		attribute.String("endpoint", endpoint),
+	))
+
+	cancel := func() {}
+	if timeout, ok := c.config.CallTimeouts[kind]; ok && timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		span.SetAttributes(attribute.String("timeout", timeout.String()))
+	}
+	return ctx, span, cancel
 }
 
 func (c *client) SetUserAgent(software, version string) {
@@ -101,65 +454,35 @@ func (c *client) RegisterHostRemap(host string, remap string, useHttps bool) {
 		Remap:    remap,
 		UseHttps: useHttps,
 	}
+	c.cache.forget(host)
 }
 
-func (c *client) IsOnline(domain string) bool {
-	lastfailed, ok := c.lastFailed[domain]
-	if !ok {
-		return true
-	}
-	if lastfailed.IsZero() {
-		return true
-	}
-	return false
+func (c *client) InvalidateEntity(domain, address string) {
+	c.cache.delete("entity", domain, address)
 }
 
-func (c *client) UpKeeper() {
-	ctx := context.Background()
-	for {
-		time.Sleep(1 * time.Second)
-		for domain, lastFailed := range c.lastFailed {
-			// exponential backoff (max 10 minutes)
-			if _, ok := c.failCount[domain]; !ok {
-				c.failCount[domain] = 0
-			}
-			var span int = 600
-			if c.failCount[domain] < 10 {
-				span = 1 << c.failCount[domain]
-			}
-			if time.Since(lastFailed) > time.Duration(span)*time.Second {
-				log.Printf("Domain %s is offline. Fail count: %d", domain, c.failCount[domain])
-				// health check
-				_, err := httpRequest[core.Domain](ctx, c.client, "GET", "https://"+domain+"/api/v1/domain", "", &Options{})
-				if err != nil {
-					if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-						c.lastFailed[domain] = time.Now()
-					}
-					c.failCount[domain]++
-					if c.failCount[domain] > 20 {
-						log.Printf("Domain %s is still offline after 20 retries. Bye bye :(", domain)
-						delete(c.lastFailed, domain)
-						delete(c.failCount, domain)
-					}
-				} else {
-					log.Printf("Domain %s is back online :3", domain)
-					delete(c.lastFailed, domain)
-					delete(c.failCount, domain)
-				}
-			}
-		}
-	}
+func (c *client) InvalidateDomain(domain string) {
+	c.cache.delete("domain", domain, "")
+}
+
+func (c *client) InvalidateTimeline(domain, id string) {
+	c.cache.delete("timeline", domain, id)
+}
+
+func (c *client) IsOnline(domain string) bool {
+	return !c.breaker.isOpen(domain)
 }
 
 func (c *client) Commit(ctx context.Context, domain, body string, response any, opts *Options) (*http.Response, error) {
-	ctx, span := tracer.Start(ctx, "Client.Commit")
+	ctx, span, cancel := c.call(ctx, "Client.Commit", CallCommit, domain, "/api/v1/commit")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return &http.Response{}, fmt.Errorf("Domain is offline")
+		return &http.Response{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
-	req, err := http.NewRequest("POST", "https://"+domain+"/api/v1/commit", bytes.NewBuffer([]byte(body)))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://"+domain+"/api/v1/commit", bytes.NewBuffer([]byte(body)))
 	if err != nil {
 		span.RecordError(err)
 		return &http.Response{}, err
@@ -176,19 +499,17 @@ func (c *client) Commit(ctx context.Context, domain, body string, response any,
 	if ok {
 		req.Header.Set(core.RequesterPassportHeader, passport)
 	}
-	span.SetAttributes(attribute.String("passport", passport))
 
-	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	domainPassport, ok := ctx.Value(domainPassportCtxKey{}).(string)
+	if ok {
+		req.Header.Set(core.DomainPassportHeader, domainPassport)
+	}
+	span.SetAttributes(attribute.String("passport", passport))
 
 	resp, err := c.client.Do(req)
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
-		return &http.Response{}, err
+		return &http.Response{}, requestError(req, err)
 	}
 
 	if response != nil && !reflect.ValueOf(response).IsNil() {
@@ -203,8 +524,18 @@ func (c *client) Commit(ctx context.Context, domain, body string, response any,
 	return resp, nil
 }
 
+// requestError returns a request which timed out as core.ErrorTimeout, whether the deadline of its call type
+// or the overall timeout of the client was hit
+func requestError(req *http.Request, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return core.NewErrorTimeout("remote "+req.URL.Host, 0, err)
+	}
+	return err
+}
+
 func httpRequest[T any](ctx context.Context, client *http.Client, method, url, body string, opts *Options) (*T, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer([]byte(body)))
 	if err != nil {
 		return nil, err
 	}
@@ -221,12 +552,16 @@ func httpRequest[T any](ctx context.Context, client *http.Client, method, url, b
 		req.Header.Set(core.RequesterPassportHeader, passport)
 	}
 
-	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	domainPassport, ok := ctx.Value(domainPassportCtxKey{}).(string)
+	if ok {
+		req.Header.Set(core.DomainPassportHeader, domainPassport)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, requestError(req, err)
 	}
+	defer resp.Body.Close()
 
 	respbody, _ := io.ReadAll(resp.Body)
 	var response core.ResponseBase[T]
@@ -237,43 +572,67 @@ func httpRequest[T any](ctx context.Context, client *http.Client, method, url, b
 
 	if response.Status != "ok" {
 		log.Printf("error: %v", string(body))
-		return nil, fmt.Errorf("Request failed(%s): %v", resp.Status, string(body))
+		err = fmt.Errorf("Request failed(%s): %v", resp.Status, string(body))
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("%w: %w", core.NewErrorNotFound(), err)
+		case resp.StatusCode >= http.StatusInternalServerError:
+			return nil, core.NewErrorRemoteUnavailable(req.Host, err)
+		}
+		return nil, err
 	}
 
 	return &response.Content, nil
 }
 
 func (c *client) GetEntity(ctx context.Context, domain, address string, opts *Options) (core.Entity, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetEntity")
+	ctx, span, cancel := c.call(ctx, "Client.GetEntity", CallEntity, domain, "/api/v1/entity/:id")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return core.Entity{}, fmt.Errorf("Domain is offline")
+		return core.Entity{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	if cacheable(opts) {
+		var cached core.Entity
+		if c.cache.get("entity", domain, address, c.config.EntityCacheTTL, &cached) {
+			span.SetAttributes(attribute.String("cache", "hit"))
+			return cached, nil
+		}
+		span.SetAttributes(attribute.String("cache", "miss"))
+	} else {
+		span.SetAttributes(attribute.String("cache", "bypass"))
 	}
 
 	url := "https://" + domain + "/api/v1/entity/" + address
 	span.SetAttributes(attribute.String("url", url))
 
-	response, err := httpRequest[core.Entity](ctx, c.client, "GET", url, "", opts)
+	entity, err := coalesced(ctx, &c.entities, domain, domain+"/"+address, opts, func(ctx context.Context) (core.Entity, error) {
+		response, err := httpRequest[core.Entity](ctx, c.client, "GET", url, "", opts)
+		if err != nil {
+			return core.Entity{}, err
+		}
+		if cacheable(opts) {
+			c.cache.set("entity", domain, address, *response, c.config.EntityCacheTTL)
+		}
+		return *response, nil
+	})
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return core.Entity{}, err
 	}
 
-	return *response, nil
+	return entity, nil
 }
 
 func (c *client) GetMessage(ctx context.Context, domain, id string, opts *Options) (core.Message, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetMessage")
+	ctx, span, cancel := c.call(ctx, "Client.GetMessage", CallMessage, domain, "/api/v1/message/:id")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return core.Message{}, fmt.Errorf("Domain is offline")
+		return core.Message{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 
 	}
 
@@ -283,11 +642,6 @@ func (c *client) GetMessage(ctx context.Context, domain, id string, opts *Option
 	response, err := httpRequest[core.Message](ctx, c.client, "GET", url, "", opts)
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return core.Message{}, err
 	}
 
@@ -295,11 +649,12 @@ func (c *client) GetMessage(ctx context.Context, domain, id string, opts *Option
 }
 
 func (c *client) GetAssociation(ctx context.Context, domain, id string, opts *Options) (core.Association, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetAssociation")
+	ctx, span, cancel := c.call(ctx, "Client.GetAssociation", CallAssociation, domain, "/api/v1/association/:id")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return core.Association{}, fmt.Errorf("Domain is offline")
+		return core.Association{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
 	url := "https://" + domain + "/api/v1/association/" + id
@@ -308,11 +663,6 @@ func (c *client) GetAssociation(ctx context.Context, domain, id string, opts *Op
 	response, err := httpRequest[core.Association](ctx, c.client, "GET", url, "", opts)
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return core.Association{}, err
 	}
 
@@ -320,11 +670,12 @@ func (c *client) GetAssociation(ctx context.Context, domain, id string, opts *Op
 }
 
 func (c *client) GetProfile(ctx context.Context, domain, id string, opts *Options) (core.Profile, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetProfile")
+	ctx, span, cancel := c.call(ctx, "Client.GetProfile", CallProfile, domain, "/api/v1/profile/:id")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return core.Profile{}, fmt.Errorf("Domain is offline")
+		return core.Profile{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
 	url := "https://" + domain + "/api/v1/profile/" + id
@@ -333,11 +684,6 @@ func (c *client) GetProfile(ctx context.Context, domain, id string, opts *Option
 	response, err := httpRequest[core.Profile](ctx, c.client, "GET", url, "", opts)
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return core.Profile{}, err
 	}
 
@@ -345,121 +691,152 @@ func (c *client) GetProfile(ctx context.Context, domain, id string, opts *Option
 }
 
 func (c *client) GetTimeline(ctx context.Context, domain, id string, opts *Options) (core.Timeline, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetTimeline")
+	ctx, span, cancel := c.call(ctx, "Client.GetTimeline", CallTimeline, domain, "/api/v1/timeline/:id")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return core.Timeline{}, fmt.Errorf("Domain is offline")
+		return core.Timeline{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	if cacheable(opts) {
+		var cached core.Timeline
+		if c.cache.get("timeline", domain, id, c.config.TimelineCacheTTL, &cached) {
+			span.SetAttributes(attribute.String("cache", "hit"))
+			return cached, nil
+		}
+		span.SetAttributes(attribute.String("cache", "miss"))
+	} else {
+		span.SetAttributes(attribute.String("cache", "bypass"))
 	}
 
 	url := "https://" + domain + "/api/v1/timeline/" + id
 	span.SetAttributes(attribute.String("url", url))
 
-	response, err := httpRequest[core.Timeline](ctx, c.client, "GET", url, "", opts)
+	timeline, err := coalesced(ctx, &c.timelines, domain, domain+"/"+id, opts, func(ctx context.Context) (core.Timeline, error) {
+		response, err := httpRequest[core.Timeline](ctx, c.client, "GET", url, "", opts)
+		if err != nil {
+			return core.Timeline{}, err
+		}
+		if cacheable(opts) {
+			c.cache.set("timeline", domain, id, *response, c.config.TimelineCacheTTL)
+		}
+		return *response, nil
+	})
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return core.Timeline{}, err
 	}
 
-	return *response, nil
+	return timeline, nil
 }
 
 func (c *client) GetChunks(ctx context.Context, domain string, timelines []string, queryTime time.Time, opts *Options) (map[string]core.Chunk, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetChunks")
+	ctx, span, cancel := c.call(ctx, "Client.GetChunks", CallChunks, domain, "/api/v1/timelines/chunks")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return nil, fmt.Errorf("Domain is offline")
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
-	timelinesStr := strings.Join(timelines, ",")
+	ctx = c.withDomainPassport(ctx, domain)
+
+	timelinesStr := strings.Join(sorted(timelines), ",")
 	timeStr := fmt.Sprintf("%d", queryTime.Unix())
 
 	url := "https://" + domain + "/api/v1/timelines/chunks?timelines=" + timelinesStr + "&time=" + timeStr
 	span.SetAttributes(attribute.String("url", url))
 
-	response, err := httpRequest[map[string]core.Chunk](ctx, c.client, "GET", url, "", opts)
+	chunks, err := coalesced(ctx, &c.chunks, domain, url, opts, func(ctx context.Context) (map[string]core.Chunk, error) {
+		response, err := httpRequest[map[string]core.Chunk](ctx, c.client, "GET", url, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		return *response, nil
+	})
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return nil, err
 	}
 
-	return *response, nil
+	return chunks, nil
 }
 
 func (c *client) GetChunkItrs(ctx context.Context, domain string, timelines []string, epoch string, opts *Options) (map[string]string, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetChunkItrs")
+	ctx, span, cancel := c.call(ctx, "Client.GetChunkItrs", CallChunks, domain, "/api/v1/chunks/itr")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return nil, fmt.Errorf("Domain is offline")
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
-	timelinesStr := strings.Join(timelines, ",")
+	// the remote serves private timelines only to domains it can identify
+	ctx = c.withDomainPassport(ctx, domain)
+
+	timelinesStr := strings.Join(sorted(timelines), ",")
 
 	url := "https://" + domain + "/api/v1/chunks/itr?timelines=" + timelinesStr + "&epoch=" + epoch
 	span.SetAttributes(attribute.String("url", url))
 
-	response, err := httpRequest[map[string]string](ctx, c.client, "GET", url, "", opts)
+	itrs, err := coalesced(ctx, &c.itrs, domain, url, opts, func(ctx context.Context) (map[string]string, error) {
+		response, err := httpRequest[map[string]string](ctx, c.client, "GET", url, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		return *response, nil
+	})
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return nil, err
 	}
 
-	return *response, nil
+	return itrs, nil
 }
 
 func (c *client) GetChunkBodies(ctx context.Context, domain string, query map[string]string, opts *Options) (map[string]core.Chunk, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetChunkBodies")
+	ctx, span, cancel := c.call(ctx, "Client.GetChunkBodies", CallChunks, domain, "/api/v1/chunks/body")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return nil, fmt.Errorf("Domain is offline")
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
+	ctx = c.withDomainPassport(ctx, domain)
+
 	queries := []string{}
 	for key, value := range query {
 		queries = append(queries, key+":"+value)
 	}
+	slices.Sort(queries)
 
 	url := "https://" + domain + "/api/v1/chunks/body?query=" + strings.Join(queries, ",")
 	span.SetAttributes(attribute.String("url", url))
 
-	response, err := httpRequest[map[string]core.Chunk](ctx, c.client, "GET", url, "", opts)
+	chunks, err := coalesced(ctx, &c.chunks, domain, url, opts, func(ctx context.Context) (map[string]core.Chunk, error) {
+		response, err := httpRequest[map[string]core.Chunk](ctx, c.client, "GET", url, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		return *response, nil
+	})
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return nil, err
 	}
 
-	return *response, nil
+	return chunks, nil
 }
 
 func (c *client) GetKey(ctx context.Context, domain, id string, opts *Options) ([]core.Key, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetKey")
+	ctx, span, cancel := c.call(ctx, "Client.GetKey", CallKey, domain, "/api/v1/key/:id")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return nil, fmt.Errorf("Domain is offline")
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
 	url := "https://" + domain + "/api/v1/key/" + id
@@ -468,11 +845,6 @@ func (c *client) GetKey(ctx context.Context, domain, id string, opts *Options) (
 	response, err := httpRequest[[]core.Key](ctx, c.client, "GET", url, "", opts)
 	if err != nil {
 		span.RecordError(err)
-
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
-
 		return nil, err
 	}
 
@@ -480,11 +852,23 @@ func (c *client) GetKey(ctx context.Context, domain, id string, opts *Options) (
 }
 
 func (c *client) GetDomain(ctx context.Context, domain string, opts *Options) (core.Domain, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetDomain")
+	ctx, span, cancel := c.call(ctx, "Client.GetDomain", CallDomain, domain, "/api/v1/domain")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return core.Domain{}, fmt.Errorf("Domain is offline")
+		return core.Domain{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	if cacheable(opts) {
+		var cached core.Domain
+		if c.cache.get("domain", domain, "", c.config.DomainCacheTTL, &cached) {
+			span.SetAttributes(attribute.String("cache", "hit"))
+			return cached, nil
+		}
+		span.SetAttributes(attribute.String("cache", "miss"))
+	} else {
+		span.SetAttributes(attribute.String("cache", "bypass"))
 	}
 
 	url := "https://" + domain + "/api/v1/domain"
@@ -493,13 +877,39 @@ func (c *client) GetDomain(ctx context.Context, domain string, opts *Options) (c
 	response, err := httpRequest[core.Domain](ctx, c.client, "GET", url, "", opts)
 	if err != nil {
 		span.RecordError(err)
+		return core.Domain{}, err
+	}
 
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		} else if _, ok := err.(*json.SyntaxError); ok {
-			c.lastFailed[domain] = time.Now()
-		}
+	if cacheable(opts) {
+		c.cache.set("domain", domain, "", *response, c.config.DomainCacheTTL)
+	}
+
+	return *response, nil
+}
 
+// Hello tells the domain that fqdn changed, e.g. rotated its CSID, so that it fetches fqdn again.
+// the domain responds with what it holds for fqdn afterwards
+func (c *client) Hello(ctx context.Context, domain, fqdn string, opts *Options) (core.Domain, error) {
+	ctx, span, cancel := c.call(ctx, "Client.Hello", CallDomain, domain, "/api/v1/domains/hello")
+	defer cancel()
+	defer span.End()
+
+	if !c.IsOnline(domain) {
+		return core.Domain{}, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	body, err := json.Marshal(map[string]string{"fqdn": fqdn})
+	if err != nil {
+		span.RecordError(err)
+		return core.Domain{}, err
+	}
+
+	url := "https://" + domain + "/api/v1/domains/hello"
+	span.SetAttributes(attribute.String("url", url))
+
+	response, err := httpRequest[core.Domain](ctx, c.client, "POST", url, string(body), opts)
+	if err != nil {
+		span.RecordError(err)
 		return core.Domain{}, err
 	}
 
@@ -507,11 +917,12 @@ func (c *client) GetDomain(ctx context.Context, domain string, opts *Options) (c
 }
 
 func (c *client) GetRetracted(ctx context.Context, domain string, timelines []string, opts *Options) (map[string][]string, error) {
-	ctx, span := tracer.Start(ctx, "Client.GetRetracted")
+	ctx, span, cancel := c.call(ctx, "Client.GetRetracted", CallTimeline, domain, "/api/v1/timelines/retracted")
+	defer cancel()
 	defer span.End()
 
 	if !c.IsOnline(domain) {
-		return nil, fmt.Errorf("Domain is offline")
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
 	}
 
 	timelinesStr := strings.Join(timelines, ",")
@@ -521,13 +932,57 @@ func (c *client) GetRetracted(ctx context.Context, domain string, timelines []st
 	response, err := httpRequest[map[string][]string](ctx, c.client, "GET", url, "", opts)
 	if err != nil {
 		span.RecordError(err)
+		return nil, err
+	}
 
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			c.lastFailed[domain] = time.Now()
-		}
+	return *response, nil
+}
+
+func (c *client) DiscoverTimelines(ctx context.Context, domain string, schemas []string, limit int, opts *Options) ([]core.DiscoveredTimeline, error) {
+	ctx, span, cancel := c.call(ctx, "Client.DiscoverTimelines", CallDiscover, domain, "/api/v1/timelines/discover")
+	defer cancel()
+	defer span.End()
 
+	if !c.IsOnline(domain) {
+		return nil, core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	query := url.Values{}
+	if len(schemas) > 0 {
+		query.Set("schema", strings.Join(schemas, ","))
+	}
+	query.Set("limit", strconv.Itoa(limit))
+
+	url := "https://" + domain + "/api/v1/timelines/discover?" + query.Encode()
+	span.SetAttributes(attribute.String("url", url))
+
+	response, err := httpRequest[[]core.DiscoveredTimeline](ctx, c.client, "GET", url, "", opts)
+	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	return *response, nil
 }
+
+// RelayEvents sends a signed relay document to a relay, or to a peer of the relay
+func (c *client) RelayEvents(ctx context.Context, domain, body string, opts *Options) error {
+	ctx, span, cancel := c.call(ctx, "Client.RelayEvents", CallRelay, domain, "/api/v1/relay/events")
+	defer cancel()
+	defer span.End()
+
+	if !c.IsOnline(domain) {
+		return core.NewErrorRemoteUnavailable(domain, ErrDomainOffline)
+	}
+
+	url := "https://" + domain + "/api/v1/relay/events"
+	span.SetAttributes(attribute.String("url", url))
+
+	_, err := httpRequest[any](ctx, c.client, "POST", url, body, opts)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
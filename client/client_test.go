@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/testutil"
+)
+
+func TestCallTimeout(t *testing.T) {
+	spans := testutil.SetupMockTraceProvider()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if strings.HasPrefix(r.URL.Path, "/api/v1/entity/") {
+			time.Sleep(500 * time.Millisecond)
+		}
+		w.Write([]byte(`{"status":"ok","content":{}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(WithRetry(0, 0, 0), WithCallTimeout(CallEntity, 50*time.Millisecond))
+	c.RegisterHostRemap("remote.example", strings.TrimPrefix(server.URL, "http://"), false)
+
+	started := time.Now()
+	_, err := c.GetEntity(context.Background(), "remote.example", "con1example", &Options{NoCache: true})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, core.ErrorTimeout{})
+	assert.Less(t, time.Since(started), 400*time.Millisecond)
+
+	// other call types keep the overall timeout
+	ctx, traceID := testutil.SetupTraceCtx()
+	_, err = c.GetDomain(ctx, "remote.example", &Options{NoCache: true})
+	assert.NoError(t, err)
+	assert.Contains(t, traceparent, traceID)
+
+	var call, attempt bool
+	for _, span := range spans.GetSpans() {
+		if span.Name == "Client.GetDomain" {
+			call = true
+			for _, attr := range span.Attributes {
+				if attr.Key == "endpoint" {
+					assert.Equal(t, "/api/v1/domain", attr.Value.AsString())
+				}
+			}
+		}
+		if span.Name == "HTTP GET "+strings.TrimPrefix(server.URL, "http://") {
+			attempt = true
+		}
+	}
+	assert.True(t, call)
+	assert.True(t, attempt)
+}
+
+func TestCoalesce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"status":"ok","content":{"ccid":"con1example"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(WithRetry(0, 0, 0))
+	c.RegisterHostRemap("remote.example", strings.TrimPrefix(server.URL, "http://"), false)
+
+	// a burst for the same entity is a single request
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entity, err := c.GetEntity(context.Background(), "remote.example", "con1example", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "con1example", entity.ID)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), requests.Load())
+
+	// authorized calls are made by each caller
+	requests.Store(0)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetEntity(context.Background(), "remote.example", "con1example", &Options{AuthToken: "token"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), requests.Load())
+
+	// the passport of the requester is sent along, so calls for different requesters are not shared
+	requests.Store(0)
+	for _, passport := range []string{"passport-a", "passport-b", "passport-a"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), core.RequesterPassportKey, passport)
+			_, err := c.GetChunkItrs(ctx, "remote.example", []string{"t00000000000000000000000000@remote.example"}, "1700000000", nil)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), requests.Load())
+}
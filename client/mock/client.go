@@ -58,6 +58,35 @@ func (mr *MockClientMockRecorder) Commit(ctx, domain, body, response, opts any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockClient)(nil).Commit), ctx, domain, body, response, opts)
 }
 
+// DiscoverTimelines mocks base method.
+func (m *MockClient) DiscoverTimelines(ctx context.Context, domain string, schemas []string, limit int, opts *client.Options) ([]core.DiscoveredTimeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiscoverTimelines", ctx, domain, schemas, limit, opts)
+	ret0, _ := ret[0].([]core.DiscoveredTimeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiscoverTimelines indicates an expected call of DiscoverTimelines.
+func (mr *MockClientMockRecorder) DiscoverTimelines(ctx, domain, schemas, limit, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiscoverTimelines", reflect.TypeOf((*MockClient)(nil).DiscoverTimelines), ctx, domain, schemas, limit, opts)
+}
+
+// FederationStats mocks base method.
+func (m *MockClient) FederationStats() []core.FederationStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FederationStats")
+	ret0, _ := ret[0].([]core.FederationStats)
+	return ret0
+}
+
+// FederationStats indicates an expected call of FederationStats.
+func (mr *MockClientMockRecorder) FederationStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FederationStats", reflect.TypeOf((*MockClient)(nil).FederationStats))
+}
+
 // GetAssociation mocks base method.
 func (m *MockClient) GetAssociation(ctx context.Context, domain, id string, opts *client.Options) (core.Association, error) {
 	m.ctrl.T.Helper()
@@ -222,3 +251,107 @@ func (mr *MockClientMockRecorder) GetTimeline(ctx, domain, id, opts any) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimeline", reflect.TypeOf((*MockClient)(nil).GetTimeline), ctx, domain, id, opts)
 }
+
+// Hello mocks base method.
+func (m *MockClient) Hello(ctx context.Context, domain, fqdn string, opts *client.Options) (core.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hello", ctx, domain, fqdn, opts)
+	ret0, _ := ret[0].(core.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hello indicates an expected call of Hello.
+func (mr *MockClientMockRecorder) Hello(ctx, domain, fqdn, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hello", reflect.TypeOf((*MockClient)(nil).Hello), ctx, domain, fqdn, opts)
+}
+
+// InvalidateDomain mocks base method.
+func (m *MockClient) InvalidateDomain(domain string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "InvalidateDomain", domain)
+}
+
+// InvalidateDomain indicates an expected call of InvalidateDomain.
+func (mr *MockClientMockRecorder) InvalidateDomain(domain any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateDomain", reflect.TypeOf((*MockClient)(nil).InvalidateDomain), domain)
+}
+
+// InvalidateEntity mocks base method.
+func (m *MockClient) InvalidateEntity(domain, address string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "InvalidateEntity", domain, address)
+}
+
+// InvalidateEntity indicates an expected call of InvalidateEntity.
+func (mr *MockClientMockRecorder) InvalidateEntity(domain, address any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateEntity", reflect.TypeOf((*MockClient)(nil).InvalidateEntity), domain, address)
+}
+
+// InvalidateTimeline mocks base method.
+func (m *MockClient) InvalidateTimeline(domain, id string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "InvalidateTimeline", domain, id)
+}
+
+// InvalidateTimeline indicates an expected call of InvalidateTimeline.
+func (mr *MockClientMockRecorder) InvalidateTimeline(domain, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateTimeline", reflect.TypeOf((*MockClient)(nil).InvalidateTimeline), domain, id)
+}
+
+// RegisterHostRemap mocks base method.
+func (m *MockClient) RegisterHostRemap(host, remap string, useHttps bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RegisterHostRemap", host, remap, useHttps)
+}
+
+// RegisterHostRemap indicates an expected call of RegisterHostRemap.
+func (mr *MockClientMockRecorder) RegisterHostRemap(host, remap, useHttps any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterHostRemap", reflect.TypeOf((*MockClient)(nil).RegisterHostRemap), host, remap, useHttps)
+}
+
+// RelayEvents mocks base method.
+func (m *MockClient) RelayEvents(ctx context.Context, domain, body string, opts *client.Options) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RelayEvents", ctx, domain, body, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RelayEvents indicates an expected call of RelayEvents.
+func (mr *MockClientMockRecorder) RelayEvents(ctx, domain, body, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RelayEvents", reflect.TypeOf((*MockClient)(nil).RelayEvents), ctx, domain, body, opts)
+}
+
+// SetUserAgent mocks base method.
+func (m *MockClient) SetUserAgent(software, version string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetUserAgent", software, version)
+}
+
+// SetUserAgent indicates an expected call of SetUserAgent.
+func (mr *MockClientMockRecorder) SetUserAgent(software, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserAgent", reflect.TypeOf((*MockClient)(nil).SetUserAgent), software, version)
+}
+
+// Subscribe mocks base method.
+func (m *MockClient) Subscribe(ctx context.Context, domain string, timelines []string, opts *client.Options) (client.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx, domain, timelines, opts)
+	ret0, _ := ret[0].(client.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockClientMockRecorder) Subscribe(ctx, domain, timelines, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockClient)(nil).Subscribe), ctx, domain, timelines, opts)
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type domainPassportCtxKey struct{}
+
+// WithDomainIdentity signs the chunk fetches with the CSID of the domain, so that the remote domain knows
+// which domain, and which of its users, the items are fetched for. without it chunk fetches are anonymous
+// and private timelines are not served
+func WithDomainIdentity(fqdn, csid, privateKey string) ClientOption {
+	return func(c *Config) {
+		c.FQDN = fqdn
+		c.CSID = csid
+		c.PrivateKey = privateKey
+	}
+}
+
+// withDomainPassport attaches a domain passport for the audience to the requests made with ctx.
+// the local user the request is made for, if any, is named in it
+func (c *client) withDomainPassport(ctx context.Context, audience string) context.Context {
+	if c.config.CSID == "" || c.config.PrivateKey == "" {
+		return ctx
+	}
+
+	var requester string
+	if requesterType, ok := ctx.Value(core.RequesterTypeCtxKey).(int); ok && requesterType == core.LocalUser {
+		requester, _ = ctx.Value(core.RequesterIdCtxKey).(string)
+	}
+
+	passport, err := signDomainPassport(c.config.FQDN, c.config.CSID, c.config.PrivateKey, audience, requester)
+	if err != nil {
+		// sent anonymous. the remote serves the public timelines only
+		return ctx
+	}
+	return context.WithValue(ctx, domainPassportCtxKey{}, passport)
+}
+
+func signDomainPassport(fqdn, csid, privateKey, audience, requester string) (string, error) {
+	document, err := json.Marshal(core.DomainPassportDocument{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   csid,
+			Type:     "domainpassport",
+			SignedAt: time.Now(),
+		},
+		Domain:    fqdn,
+		Audience:  audience,
+		Requester: requester,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := core.SignBytes(document, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	passport, err := json.Marshal(core.Passport{
+		Document:  string(document),
+		Signature: hex.EncodeToString(signature),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(passport), nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestChunkFetchDomainPassport(t *testing.T) {
+	const privateKey = "863183823d2c2a19101140eef0f905c872de1dae6470c9129a1547f3482cb612"
+	csid, err := core.PrivKeyToAddr(privateKey, "ccs")
+	assert.NoError(t, err)
+
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(core.DomainPassportHeader)
+		w.Write([]byte(`{"status":"ok","content":{}}`))
+	}))
+	defer server.Close()
+
+	fetch := func(c Client, ctx context.Context) core.DomainPassportDocument {
+		c.RegisterHostRemap("remote.example", strings.TrimPrefix(server.URL, "http://"), false)
+		header = ""
+		_, err := c.GetChunkItrs(ctx, "remote.example", []string{"t1@remote.example"}, "0", nil)
+		assert.NoError(t, err)
+		if header == "" {
+			return core.DomainPassportDocument{}
+		}
+
+		passportJson, err := base64.URLEncoding.DecodeString(header)
+		assert.NoError(t, err)
+		var passport core.Passport
+		assert.NoError(t, json.Unmarshal(passportJson, &passport))
+		signature, err := hex.DecodeString(passport.Signature)
+		assert.NoError(t, err)
+		assert.NoError(t, core.VerifySignature([]byte(passport.Document), signature, csid))
+
+		var doc core.DomainPassportDocument
+		assert.NoError(t, json.Unmarshal([]byte(passport.Document), &doc))
+		return doc
+	}
+
+	// without an identity the fetch is anonymous
+	doc := fetch(NewClient(), context.Background())
+	assert.Empty(t, doc.Signer)
+
+	c := NewClient(WithDomainIdentity("local.example", csid, privateKey))
+	doc = fetch(c, context.Background())
+	assert.Equal(t, "domainpassport", doc.Type)
+	assert.Equal(t, "local.example", doc.Domain)
+	assert.Equal(t, "remote.example", doc.Audience)
+	assert.Empty(t, doc.Requester)
+
+	ctx := context.WithValue(context.Background(), core.RequesterTypeCtxKey, core.LocalUser)
+	ctx = context.WithValue(ctx, core.RequesterIdCtxKey, "con1example")
+	doc = fetch(c, ctx)
+	assert.Equal(t, "con1example", doc.Requester)
+
+	// remote users are not vouched for
+	ctx = context.WithValue(context.Background(), core.RequesterTypeCtxKey, core.RemoteUser)
+	ctx = context.WithValue(ctx, core.RequesterIdCtxKey, "con1example")
+	doc = fetch(c, ctx)
+	assert.Empty(t, doc.Requester)
+}
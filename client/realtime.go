@@ -0,0 +1,376 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	realtimePingInterval = 10 * time.Second
+	// the connection is dropped when nothing, not even a pong, was read for this long
+	realtimeReadTimeout      = 30 * time.Second
+	realtimeHandshakeTimeout = 10 * time.Second
+	realtimeBufferSize       = 64
+)
+
+// reconnection delays of a Subscription. variables so that tests can shorten them
+var (
+	realtimeMinBackoff = time.Second
+	realtimeMaxBackoff = time.Minute
+)
+
+// ErrSubscriptionClosed is returned by a Subscription after Close
+var ErrSubscriptionClosed = errors.New("subscription is closed")
+
+// Subscription delivers the events of timelines of a remote domain over a websocket.
+// the connection is made again when it is lost, and the timelines are listened to again.
+// when the remote keeps sessions (protocol v2), the events sent meanwhile are replayed;
+// otherwise they are missed, and the timelines should be fetched again after a reconnection
+type Subscription interface {
+	// Events is closed after Close, or when the context of Subscribe is done
+	Events() <-chan core.Event
+	// Listen replaces the timelines the events are delivered for
+	Listen(timelines []string) error
+	Close() error
+}
+
+type subscription struct {
+	client *client
+	domain string
+	opts   Options
+	events chan core.Event
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	// timelines are listened to again on every connection
+	timelines []string
+	// token and seq of the protocol v2 session, to resume it after a reconnection
+	token string
+	seq   int64
+	acked int64
+}
+
+// realtimeFrame is a frame of the remote other than an event. events have no type
+type realtimeFrame struct {
+	Type     string   `json:"type"`
+	Token    string   `json:"token"`
+	Seq      int64    `json:"seq"`
+	Channels []string `json:"channels"`
+}
+
+type realtimeRequest struct {
+	Type     string   `json:"type"`
+	Channels []string `json:"channels,omitempty"`
+	Token    string   `json:"token,omitempty"`
+	Seq      int64    `json:"seq,omitempty"`
+}
+
+// Subscribe connects to the realtime endpoint of the domain and listens to the timelines.
+// the first connection is made before it returns; later ones are made in background until Close
+func (c *client) Subscribe(ctx context.Context, domain string, timelines []string, opts *Options) (Subscription, error) {
+	ctx, span := tracer.Start(ctx, "Client.Subscribe")
+	defer span.End()
+
+	s := &subscription{
+		client:    c,
+		domain:    domain,
+		events:    make(chan core.Event, realtimeBufferSize),
+		done:      make(chan struct{}),
+		timelines: slices.Clone(timelines),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	// the span ends here, while the subscription lives on
+	s.ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	context.AfterFunc(ctx, s.cancel)
+
+	conn, err := s.connect(s.ctx)
+	if err != nil {
+		s.cancel()
+		span.RecordError(err)
+		return nil, err
+	}
+
+	go s.run(conn)
+	return s, nil
+}
+
+func (s *subscription) Events() <-chan core.Event {
+	return s.events
+}
+
+func (s *subscription) Listen(timelines []string) error {
+	if s.ctx.Err() != nil {
+		return ErrSubscriptionClosed
+	}
+
+	s.mu.Lock()
+	s.timelines = slices.Clone(timelines)
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		// sent with the next connection
+		return nil
+	}
+	return s.write(conn, realtimeRequest{Type: "listen", Channels: timelines})
+}
+
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// url returns the realtime endpoint of the domain, with the host remapped like the http calls
+func (s *subscription) url() string {
+	u := url.URL{Scheme: "wss", Host: s.domain, Path: "/api/v1/timelines/realtime"}
+	if remap, ok := s.client.hostRemap[s.domain]; ok {
+		u.Host = remap.Remap
+		if !remap.UseHttps {
+			u.Scheme = "ws"
+		}
+	}
+	return u.String()
+}
+
+// connect dials the domain, then resumes the session or says hello, and listens to the timelines
+func (s *subscription) connect(ctx context.Context) (*websocket.Conn, error) {
+	if !s.client.breaker.allow(s.domain) {
+		return nil, core.NewErrorRemoteUnavailable(s.domain, ErrDomainOffline)
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", s.client.userAgent)
+	if s.opts.AuthToken != "" {
+		header.Set("Authorization", "Bearer "+s.opts.AuthToken)
+	}
+	if passport, ok := ctx.Value(core.RequesterPassportKey).(string); ok {
+		header.Set(core.RequesterPassportHeader, passport)
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = realtimeHandshakeTimeout
+
+	started := time.Now()
+	conn, _, err := dialer.DialContext(ctx, s.url(), header)
+	if ctx.Err() == nil {
+		s.client.stats.record(s.domain, time.Since(started), err)
+		if err != nil {
+			s.client.breaker.failure(s.domain)
+		} else {
+			s.client.breaker.success(s.domain)
+		}
+	}
+	if err != nil {
+		return nil, core.NewErrorRemoteUnavailable(s.domain, err)
+	}
+
+	s.mu.Lock()
+	hello := realtimeRequest{Type: "hello"}
+	if s.token != "" {
+		hello = realtimeRequest{Type: "resume", Token: s.token, Seq: s.seq}
+	}
+	listen := realtimeRequest{Type: "listen", Channels: s.timelines}
+	s.conn = conn
+	s.mu.Unlock()
+
+	// remotes without sessions ignore hello
+	err = errors.Join(s.write(conn, hello), s.write(conn, listen))
+	if err != nil {
+		conn.Close()
+		return nil, core.NewErrorRemoteUnavailable(s.domain, err)
+	}
+	return conn, nil
+}
+
+// run serves the connections until the subscription is closed
+func (s *subscription) run(conn *websocket.Conn) {
+	defer close(s.done)
+	defer close(s.events)
+
+	for {
+		err := s.serve(conn)
+
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+
+		if s.ctx.Err() != nil {
+			return
+		}
+		slog.Warn(
+			"realtime connection lost",
+			slog.String("domain", s.domain),
+			slog.String("error", err.Error()),
+			slog.String("module", "client"),
+		)
+
+		for attempt := 0; ; attempt++ {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(realtimeBackoff(attempt)):
+			}
+
+			conn, err = s.connect(s.ctx)
+			if err == nil {
+				break
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// serve reads the events of the connection until it fails
+func (s *subscription) serve(conn *websocket.Conn) error {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(realtimeReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(realtimeReadTimeout))
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(realtimePingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.ctx.Done():
+				// unblocks the read
+				conn.Close()
+				return
+			case <-ticker.C:
+				err := s.heartbeat(conn)
+				if err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(realtimeReadTimeout))
+
+		if !s.handle(message) {
+			return s.ctx.Err()
+		}
+	}
+}
+
+// heartbeat pings the remote, and acks the events received since the last one
+func (s *subscription) heartbeat(conn *websocket.Conn) error {
+	err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(realtimePingInterval))
+	if err != nil {
+		return err
+	}
+	// proxies may drop control frames, so the connection is kept busy with a message too
+	err = s.write(conn, realtimeRequest{Type: "h"})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	seq := s.seq
+	ack := s.token != "" && seq > s.acked
+	s.acked = seq
+	s.mu.Unlock()
+
+	if ack {
+		return s.write(conn, realtimeRequest{Type: "ack", Seq: seq})
+	}
+	return nil
+}
+
+// handle delivers an event, or applies a frame of the remote. it returns false when the subscription is closed
+func (s *subscription) handle(message []byte) bool {
+	var frame realtimeFrame
+	err := json.Unmarshal(message, &frame)
+	if err != nil {
+		return true
+	}
+
+	switch frame.Type {
+	case "session":
+		s.mu.Lock()
+		s.token = frame.Token
+		s.seq = frame.Seq
+		s.acked = frame.Seq
+		s.mu.Unlock()
+		return true
+	case "denied":
+		// not asked again on the next connection
+		s.mu.Lock()
+		s.timelines = slices.DeleteFunc(s.timelines, func(timeline string) bool {
+			return slices.Contains(frame.Channels, timeline)
+		})
+		s.mu.Unlock()
+		return true
+	case "":
+	default:
+		return true
+	}
+
+	var event core.Event
+	err = json.Unmarshal(message, &event)
+	if err != nil {
+		return true
+	}
+	if event.Seq > 0 {
+		s.mu.Lock()
+		s.seq = event.Seq
+		s.mu.Unlock()
+	}
+
+	select {
+	case s.events <- event:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *subscription) write(conn *websocket.Conn, v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(realtimePingInterval))
+	return conn.WriteJSON(v)
+}
+
+// realtimeBackoff returns an exponential delay with jitter for the reconnection attempt
+func realtimeBackoff(attempt int) time.Duration {
+	delay := realtimeMinBackoff << min(attempt, 16)
+	if delay > realtimeMaxBackoff || delay <= 0 {
+		delay = realtimeMaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
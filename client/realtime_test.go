@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestSubscribe(t *testing.T) {
+	realtimeMinBackoff = 10 * time.Millisecond
+	realtimeMaxBackoff = 20 * time.Millisecond
+
+	var mu sync.Mutex
+	var requests []realtimeRequest
+	connections := 0
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/timelines/realtime", r.URL.Path)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		connections++
+		first := connections == 1
+		mu.Unlock()
+
+		for i := 0; i < 2; i++ {
+			var req realtimeRequest
+			if conn.ReadJSON(&req) != nil {
+				return
+			}
+			mu.Lock()
+			requests = append(requests, req)
+			mu.Unlock()
+		}
+
+		if first {
+			conn.WriteJSON(realtimeFrame{Type: "session", Token: "token"})
+			conn.WriteJSON(core.Event{Timeline: "t1@remote.example", Document: "first", Seq: 1})
+			// the connection is lost
+			return
+		}
+		conn.WriteJSON(core.Event{Timeline: "t1@remote.example", Document: "second", Seq: 2})
+		conn.ReadJSON(&realtimeRequest{})
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.RegisterHostRemap("remote.example", strings.TrimPrefix(server.URL, "http://"), false)
+
+	sub, err := c.Subscribe(context.Background(), "remote.example", []string{"t1@remote.example"}, nil)
+	assert.NoError(t, err)
+
+	receive := func() core.Event {
+		select {
+		case event := <-sub.Events():
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return core.Event{}
+		}
+	}
+	assert.Equal(t, "first", receive().Document)
+	assert.Equal(t, "second", receive().Document)
+
+	assert.NoError(t, sub.Close())
+	_, open := <-sub.Events()
+	assert.False(t, open)
+	assert.ErrorIs(t, sub.Listen([]string{"t2@remote.example"}), ErrSubscriptionClosed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []realtimeRequest{
+		{Type: "hello"},
+		{Type: "listen", Channels: []string{"t1@remote.example"}},
+		// the session is resumed after the last event received, and the timelines are listened to again
+		{Type: "resume", Token: "token", Seq: 1},
+		{Type: "listen", Channels: []string{"t1@remote.example"}},
+	}, requests)
+}
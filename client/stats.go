@@ -0,0 +1,154 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	statsBucketSpan  = 30 * time.Second
+	statsBucketCount = 10 // the window covers the last 5 minutes
+)
+
+type statsBucket struct {
+	start    time.Time
+	requests int64
+	failures int64
+	latency  time.Duration
+	maxDelay time.Duration
+}
+
+type hostStats struct {
+	buckets   [statsBucketCount]statsBucket
+	lastError string
+	lastSeen  time.Time
+}
+
+// domainStats keeps success rate and latency per host in a sliding window of buckets
+type domainStats struct {
+	mu    sync.Mutex
+	hosts map[string]*hostStats
+	now   func() time.Time
+}
+
+func newDomainStats() *domainStats {
+	return &domainStats{
+		hosts: make(map[string]*hostStats),
+		now:   time.Now,
+	}
+}
+
+// bucket returns the bucket of now, recycling it if it belongs to an older round
+func (h *hostStats) bucket(now time.Time) *statsBucket {
+	start := now.Truncate(statsBucketSpan)
+	b := &h.buckets[(start.Unix()/int64(statsBucketSpan/time.Second))%statsBucketCount]
+	if !b.start.Equal(start) {
+		*b = statsBucket{start: start}
+	}
+	return b
+}
+
+func (s *domainStats) record(host string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostStats{}
+		s.hosts[host] = h
+	}
+
+	now := s.now()
+	b := h.bucket(now)
+	b.requests++
+	b.latency += latency
+	if latency > b.maxDelay {
+		b.maxDelay = latency
+	}
+	if err != nil {
+		b.failures++
+		h.lastError = err.Error()
+	}
+	h.lastSeen = now
+}
+
+// reset forgets the window of host, e.g. after it recovered from an outage
+func (s *domainStats) reset(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, ok := s.hosts[host]; ok {
+		h.buckets = [statsBucketCount]statsBucket{}
+	}
+}
+
+type windowStats struct {
+	requests int64
+	failures int64
+	latency  time.Duration
+	maxDelay time.Duration
+}
+
+func (h *hostStats) window(now time.Time) windowStats {
+	var w windowStats
+	oldest := now.Truncate(statsBucketSpan).Add(-statsBucketSpan * (statsBucketCount - 1))
+	for _, b := range h.buckets {
+		if b.start.Before(oldest) {
+			continue
+		}
+		w.requests += b.requests
+		w.failures += b.failures
+		w.latency += b.latency
+		if b.maxDelay > w.maxDelay {
+			w.maxDelay = b.maxDelay
+		}
+	}
+	return w
+}
+
+// errorRate returns the failure ratio of host in the window and the number of requests it is based on
+func (s *domainStats) errorRate(host string) (float64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[host]
+	if !ok {
+		return 0, 0
+	}
+	w := h.window(s.now())
+	if w.requests == 0 {
+		return 0, 0
+	}
+	return float64(w.failures) / float64(w.requests), w.requests
+}
+
+type hostSnapshot struct {
+	host      string
+	window    windowStats
+	lastError string
+	lastSeen  time.Time
+}
+
+// snapshot returns the window of every host seen recently, sorted by host
+func (s *domainStats) snapshot() []hostSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	result := make([]hostSnapshot, 0, len(s.hosts))
+	for host, h := range s.hosts {
+		w := h.window(now)
+		if w.requests == 0 && now.Sub(h.lastSeen) > statsBucketSpan*statsBucketCount {
+			// hosts not contacted for a whole window are dropped
+			delete(s.hosts, host)
+			continue
+		}
+		result = append(result, hostSnapshot{host: host, window: w, lastError: h.lastError, lastSeen: h.lastSeen})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].host < result[j].host
+	})
+	return result
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainStats(t *testing.T) {
+	now := time.Date(2024, 6, 15, 10, 0, 0, 0, time.UTC)
+	s := newDomainStats()
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		s.record("a.example", 100*time.Millisecond, nil)
+	}
+	for i := 0; i < 4; i++ {
+		s.record("a.example", 300*time.Millisecond, errors.New("status 502"))
+	}
+
+	rate, requests := s.errorRate("a.example")
+	assert.Equal(t, int64(10), requests)
+	assert.InDelta(t, 0.4, rate, 0.001)
+
+	snapshots := s.snapshot()
+	assert.Len(t, snapshots, 1)
+	assert.Equal(t, 1800*time.Millisecond, snapshots[0].window.latency)
+	assert.Equal(t, 300*time.Millisecond, snapshots[0].window.maxDelay)
+	assert.Equal(t, "status 502", snapshots[0].lastError)
+
+	// old buckets leave the window
+	now = now.Add(statsBucketSpan * statsBucketCount)
+	s.record("a.example", 100*time.Millisecond, nil)
+	rate, requests = s.errorRate("a.example")
+	assert.Equal(t, int64(1), requests)
+	assert.Equal(t, 0.0, rate)
+
+	s.reset("a.example")
+	_, requests = s.errorRate("a.example")
+	assert.Equal(t, int64(0), requests)
+}
+
+func TestCircuitBreakerTrip(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(5, time.Second, 4*time.Second)
+	b.now = func() time.Time { return now }
+
+	b.trip("a.example")
+	assert.False(t, b.allow("a.example"))
+	state, until := b.status("a.example")
+	assert.Equal(t, "offline", state)
+	assert.Equal(t, now.Add(time.Second), until)
+
+	now = now.Add(time.Second)
+	assert.True(t, b.allow("a.example"))
+	state, _ = b.status("a.example")
+	assert.Equal(t, "probing", state)
+
+	assert.True(t, b.success("a.example"))
+	state, _ = b.status("a.example")
+	assert.Equal(t, "online", state)
+	assert.False(t, b.success("a.example"))
+}
@@ -1,16 +1,42 @@
 package main
 
 import (
+	"fmt"
 	"github.com/go-yaml/yaml"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/export"
+	"github.com/totegamma/concurrent/x/loadshed"
+	"github.com/totegamma/concurrent/x/notification"
+	"github.com/totegamma/concurrent/x/quota"
+	"github.com/totegamma/concurrent/x/relay"
+	"github.com/totegamma/concurrent/x/score"
+	"github.com/totegamma/concurrent/x/spam"
+	"github.com/totegamma/concurrent/x/timeline"
 	"log"
 	"os"
+	"time"
 )
 
 type Config struct {
 	Server  Server           `yaml:"server"`
 	Concrnt core.ConfigInput `yaml:"concrnt"`
 	Profile Profile          `yaml:"profile"`
+	Tenants []Tenant         `yaml:"tenants"`
+}
+
+// Tenant is an additional domain served by the same process.
+// requests are routed to a tenant by the Host header. its tables live in a separate postgres schema.
+type Tenant struct {
+	Concrnt        core.ConfigInput `yaml:"concrnt"`
+	Profile        Profile          `yaml:"profile"`
+	DBSchema       string           `yaml:"dbSchema"`
+	RedisDB        int              `yaml:"redisDB"`
+	MemcachedAddr  string           `yaml:"memcachedAddr"`
+	RepositoryPath string           `yaml:"repositoryPath"`
+	// budgets of the keyspace of the redis database of the tenant. the server's apply if unset
+	RedisKeyspaceBudget map[string]int64 `yaml:"redisKeyspaceBudget"`
 }
 
 type Server struct {
@@ -21,10 +47,88 @@ type Server struct {
 	EnableTrace     bool   `yaml:"enableTrace"`
 	TraceEndpoint   string `yaml:"traceEndpoint"`
 	RepositoryPath  string `yaml:"repositoryPath"`
+	CaptchaProvider string `yaml:"captchaProvider"`
 	CaptchaSitekey  string `yaml:"captchaSitekey"`
 	CaptchaSecret   string `yaml:"captchaSecret"`
 	VapidPublicKey  string `yaml:"vapidPublicKey"`
 	VapidPrivateKey string `yaml:"vapidPrivateKey"`
+
+	EnableMetrics   bool          `yaml:"enableMetrics"`
+	MetricsInterval time.Duration `yaml:"metricsInterval"`
+
+	Database database.Config `yaml:"database"`
+
+	ListenAddrs          []string `yaml:"listenAddrs"`
+	TLSListenAddrs       []string `yaml:"tlsListenAddrs"`
+	TLSCertFile          string   `yaml:"tlsCertFile"`
+	TLSKeyFile           string   `yaml:"tlsKeyFile"`
+	Autocert             bool     `yaml:"autocert"`
+	AutocertHosts        []string `yaml:"autocertHosts"`
+	AutocertEmail        string   `yaml:"autocertEmail"`
+	AutocertCacheDir     string   `yaml:"autocertCacheDir"`
+	AutocertDirectoryURL string   `yaml:"autocertDirectoryURL"`
+
+	GrpcAddr         string `yaml:"grpcAddr"`
+	GrpcCertFile     string `yaml:"grpcCertFile"`
+	GrpcKeyFile      string `yaml:"grpcKeyFile"`
+	GrpcClientCAFile string `yaml:"grpcClientCAFile"`
+
+	Cache cache.Config `yaml:"cache"`
+
+	ChunkWarmupCount  int `yaml:"chunkWarmupCount"`
+	ChunkWarmupOffset int `yaml:"chunkWarmupOffset"`
+
+	TimelineBackfill timeline.BackfillConfig `yaml:"timelineBackfill"`
+
+	RedisKeyspaceBudget  map[string]int64 `yaml:"redisKeyspaceBudget"`
+	RedisMemoryWarnRatio float64          `yaml:"redisMemoryWarnRatio"`
+
+	NotificationTriggers  []notification.Trigger `yaml:"notificationTriggers"`
+	NotificationRateLimit int                    `yaml:"notificationRateLimit"`
+
+	RealtimeQueueSize      int           `yaml:"realtimeQueueSize"`
+	RealtimeOverflowPolicy string        `yaml:"realtimeOverflowPolicy"`
+	RealtimeResumeTTL      time.Duration `yaml:"realtimeResumeTTL"`
+	RealtimeReplaySize     int64         `yaml:"realtimeReplaySize"`
+	RealtimeReadRecheck    time.Duration `yaml:"realtimeReadRecheck"`
+
+	SchemaValidation string `yaml:"schemaValidation"`
+
+	WebFingerActorURL string `yaml:"webFingerActorURL"`
+
+	ActivityPub        bool `yaml:"activityPub"`
+	ActivityPubWorkers int  `yaml:"activityPubWorkers"`
+	Mastodon           bool `yaml:"mastodon"`
+
+	EntityScoreRules []score.RuleConfig `yaml:"entityScoreRules"`
+	EntityRefreshTTL time.Duration      `yaml:"entityRefreshTTL"`
+
+	Spam spam.Config `yaml:"spam"`
+
+	Relay relay.Config `yaml:"relay"`
+
+	Snapshot export.SnapshotConfig `yaml:"snapshot"`
+
+	Limits core.DocumentLimits `yaml:"limits"`
+
+	Durability core.CommitDurability `yaml:"durability"`
+
+	Quotas map[string]quota.Limit `yaml:"quotas"`
+
+	LoadShed loadshed.Config `yaml:"loadShed"`
+
+	DisableAutoMigrate bool `yaml:"disableAutoMigrate"`
+
+	ClientCallTimeouts map[string]time.Duration `yaml:"clientCallTimeouts"`
+
+	HostRemap []HostRemap `yaml:"hostRemap"`
+}
+
+// HostRemap sends the requests to a remote domain to another address, e.g. to reach local instances in development
+type HostRemap struct {
+	Host     string `yaml:"host"`
+	Remap    string `yaml:"remap"`
+	UseHttps bool   `yaml:"useHttps"`
 }
 
 type BuildInfo struct {
@@ -43,11 +147,12 @@ type Profile struct {
 	MaintainerEmail string `yaml:"maintainerEmail" json:"maintainerEmail"`
 
 	// internal generated
-	Registration string    `yaml:"registration" json:"registration"`
-	Version      string    `yaml:"version" json:"version"`
-	BuildInfo    BuildInfo `yaml:"buildInfo" json:"buildInfo"`
-	SiteKey      string    `yaml:"captchaSiteKey" json:"captchaSiteKey"`
-	VapidKey     string    `yaml:"vapidKey" json:"vapidKey"`
+	Registration    string    `yaml:"registration" json:"registration"`
+	Version         string    `yaml:"version" json:"version"`
+	BuildInfo       BuildInfo `yaml:"buildInfo" json:"buildInfo"`
+	SiteKey         string    `yaml:"captchaSiteKey" json:"captchaSiteKey"`
+	CaptchaProvider string    `yaml:"captchaProvider" json:"captchaProvider"`
+	VapidKey        string    `yaml:"vapidKey" json:"vapidKey"`
 }
 
 // Load loads config from given path
@@ -65,5 +170,20 @@ func (c *Config) Load(path string) error {
 		return err
 	}
 
+	err = c.validate()
+	if err != nil {
+		log.Fatal("invalid configuration file:", err)
+		return err
+	}
+
+	return nil
+}
+
+// validate rejects the settings which would otherwise fall back to a default silently
+func (c *Config) validate() error {
+	err := timeline.ValidateOverflowPolicy(c.Server.RealtimeOverflowPolicy)
+	if err != nil {
+		return fmt.Errorf("realtimeOverflowPolicy: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const defaultAutocertCacheDir = "/etc/concurrent/autocert"
+
+// listenConfig is where the HTTP API listens and how it terminates TLS
+type listenConfig struct {
+	addrs    []string // plaintext
+	tlsAddrs []string
+	certFile string
+	keyFile  string
+	autocert *autocert.Manager
+}
+
+func newListenConfig(server Server, hosts []string, defaultAddr string) (listenConfig, error) {
+	listen := listenConfig{
+		addrs:    server.ListenAddrs,
+		tlsAddrs: server.TLSListenAddrs,
+		certFile: server.TLSCertFile,
+		keyFile:  server.TLSKeyFile,
+	}
+	if len(listen.addrs) == 0 && len(listen.tlsAddrs) == 0 {
+		listen.addrs = []string{defaultAddr}
+	}
+
+	if server.Autocert {
+		if listen.certFile != "" {
+			return listen, fmt.Errorf("autocert and tlsCertFile cannot be used together")
+		}
+		if len(server.AutocertHosts) > 0 {
+			hosts = server.AutocertHosts
+		}
+		cacheDir := server.AutocertCacheDir
+		if cacheDir == "" {
+			cacheDir = defaultAutocertCacheDir
+		}
+		listen.autocert = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(hosts...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      server.AutocertEmail,
+		}
+		if server.AutocertDirectoryURL != "" {
+			listen.autocert.Client = &acme.Client{DirectoryURL: server.AutocertDirectoryURL}
+		}
+	}
+
+	if len(listen.tlsAddrs) > 0 && listen.certFile == "" && listen.autocert == nil {
+		return listen, fmt.Errorf("tlsListenAddrs requires tlsCertFile and tlsKeyFile, or autocert")
+	}
+	return listen, nil
+}
+
+func (l listenConfig) tlsConfig() (*tls.Config, error) {
+	if l.autocert != nil {
+		// answers the tls-alpn-01 challenge and negotiates h2
+		return l.autocert.TLSConfig(), nil
+	}
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// serve runs the handler on every configured address until one of the listeners fails.
+// TLS listeners speak HTTP/2 to the clients negotiating it with ALPN
+func serve(handler http.Handler, listen listenConfig) error {
+	var tlsConfig *tls.Config
+	if len(listen.tlsAddrs) > 0 {
+		var err error
+		tlsConfig, err = listen.tlsConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load tls config: %w", err)
+		}
+	}
+
+	plain := handler
+	if listen.autocert != nil {
+		// the http-01 challenge is answered on the plaintext listeners, everything else goes to the API
+		plain = listen.autocert.HTTPHandler(handler)
+	}
+
+	errs := make(chan error, len(listen.addrs)+len(listen.tlsAddrs))
+	start := func(addr string, handler http.Handler, tlsConfig *tls.Config) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			errs <- err
+			return
+		}
+		server := &http.Server{
+			Handler:           handler,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			if tlsConfig != nil {
+				slog.Info(fmt.Sprintf("HTTP API listening on %s (tls)", ln.Addr()))
+				errs <- server.ServeTLS(ln, "", "")
+			} else {
+				slog.Info(fmt.Sprintf("HTTP API listening on %s", ln.Addr()))
+				errs <- server.Serve(ln)
+			}
+		}()
+	}
+
+	for _, addr := range listen.addrs {
+		start(addr, plain, nil)
+	}
+	for _, addr := range listen.tlsAddrs {
+		start(addr, handler, tlsConfig)
+	}
+
+	return <-errs
+}
@@ -5,70 +5,37 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
-	"net/http"
 	"os"
 	"time"
 
-	"github.com/redis/go-redis/v9"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"github.com/totegamma/concurrent"
-	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
-	"github.com/totegamma/concurrent/x/ack"
-	"github.com/totegamma/concurrent/x/association"
-	"github.com/totegamma/concurrent/x/auth"
-	"github.com/totegamma/concurrent/x/domain"
-	"github.com/totegamma/concurrent/x/entity"
-	"github.com/totegamma/concurrent/x/job"
-	"github.com/totegamma/concurrent/x/key"
-	"github.com/totegamma/concurrent/x/message"
-	"github.com/totegamma/concurrent/x/notification"
-	"github.com/totegamma/concurrent/x/profile"
-	"github.com/totegamma/concurrent/x/store"
-	"github.com/totegamma/concurrent/x/subscription"
-	"github.com/totegamma/concurrent/x/timeline"
-	"github.com/totegamma/concurrent/x/userkv"
-
-	"github.com/SherClockHolmes/webpush-go"
-	"github.com/bradfitz/gomemcache/memcache"
-	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/totegamma/concurrent/internal/health"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/rpc"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/scheduler"
+
+	prometheusbridge "go.opentelemetry.io/contrib/bridges/prometheus"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
-	"go.opentelemetry.io/otel/trace"
-	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-type CustomHandler struct {
-	slog.Handler
-}
-
-func (h *CustomHandler) Handle(ctx context.Context, r slog.Record) error {
-
-	r.AddAttrs(slog.String("type", "app"))
-
-	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		r.AddAttrs(slog.String("traceID", span.SpanContext().TraceID().String()))
-		r.AddAttrs(slog.String("spanID", span.SpanContext().SpanID().String()))
-	}
-
-	return h.Handler.Handle(ctx, r)
-}
-
 var (
 	version      = "v1.6.5"
 	buildMachine = "AlmaLinux release 9.5 (Teal Serval)"
@@ -76,11 +43,17 @@ var (
 	goVersion    = "go1.22.4 linux/amd64"
 )
 
+// the outcome of the last export of the telemetry, reported by the readiness probes
+var (
+	traceExports  = &health.Recorder{}
+	metricExports = &health.Recorder{}
+)
+
 func main() {
 
 	fmt.Fprint(os.Stderr, concurrent.Banner)
 
-	handler := &CustomHandler{Handler: slog.NewJSONHandler(os.Stdout, nil)}
+	handler := logging.NewHandler(slog.NewJSONHandler(os.Stdout, nil))
 	slogger := slog.New(handler)
 	slog.SetDefault(slogger)
 
@@ -89,6 +62,7 @@ func main() {
 	e := echo.New()
 	e.HidePort = true
 	e.HideBanner = true
+	e.HTTPErrorHandler = core.HTTPErrorHandler(e)
 	config := Config{}
 	configPath := os.Getenv("CONCRNT_CONFIG")
 	if configPath == "" {
@@ -101,6 +75,7 @@ func main() {
 	}
 
 	conconf := core.SetupConfig(config.Concrnt)
+	e.Binder = &core.LimitedBinder{Limit: config.Server.Limits.WithDefaults().MaxRequestBytes}
 
 	slog.Info(fmt.Sprintf("Config loaded! I am: %s", conconf.CCID))
 
@@ -113,12 +88,20 @@ func main() {
 
 		skipper := otelecho.WithSkipper(
 			func(c echo.Context) bool {
-				return c.Path() == "/metrics" || c.Path() == "/health"
+				return c.Path() == "/metrics" || health.IsProbe(c.Path())
 			},
 		)
 		e.Use(otelecho.Middleware("api", skipper))
 	}
 
+	if config.Server.EnableMetrics {
+		cleanup, err := setupMeterProvider(config.Server.TraceEndpoint, config.Concrnt.FQDN+"/ccapi", version, config.Server.MetricsInterval)
+		if err != nil {
+			panic(err)
+		}
+		defer cleanup()
+	}
+
 	e.Use(echoprometheus.NewMiddlewareWithConfig(echoprometheus.MiddlewareConfig{
 		Namespace: "ccapi",
 		LabelFuncs: map[string]echoprometheus.LabelValueFunc{
@@ -130,7 +113,7 @@ func main() {
 			if c.Request().Header.Get("Upgrade") == "websocket" {
 				return true
 			}
-			return c.Path() == "/metrics" || c.Path() == "/health"
+			return c.Path() == "/metrics" || health.IsProbe(c.Path())
 		},
 	}))
 
@@ -146,248 +129,82 @@ func main() {
 		},
 	)
 
-	db, err := gorm.Open(postgres.Open(config.Server.Dsn), &gorm.Config{
-		Logger:         gormLogger,
-		TranslateError: true,
-	})
-	if err != nil {
-		panic("failed to connect database")
-	}
-	sqlDB, err := db.DB() // for pinging
+	db, sqlDB, err := openDatabase(config.Server.Dsn, "", config.Server.Database, !config.Server.DisableAutoMigrate, gormLogger)
 	if err != nil {
-		panic("failed to connect database")
+		panic(err)
 	}
 	defer sqlDB.Close()
+	prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, "concrnt"))
 
-	err = db.Use(tracing.NewPlugin(
-		tracing.WithDBName("postgres"),
-	))
+	rdb, err := openRedis(config.Server.RedisAddr, config.Server.RedisDB)
 	if err != nil {
-		panic("failed to setup tracing plugin")
+		panic(err)
 	}
 
-	// Migrate the schema
-	slog.Info("start migrate")
-	err = db.AutoMigrate(
-		&core.Schema{},
-		&core.Message{},
-		&core.Profile{},
-		&core.Association{},
-		&core.Timeline{},
-		&core.TimelineItem{},
-		&core.Domain{},
-		&core.Entity{},
-		&core.EntityMeta{},
-		&core.Ack{},
-		&core.Key{},
-		&core.UserKV{},
-		&core.Subscription{},
-		&core.SubscriptionItem{},
-		&core.SemanticID{},
-		&core.Job{},
-		&core.CommitLog{},
-		&core.CommitOwner{},
-		&core.NotificationSubscription{},
-	)
-
+	mc, err := cache.New(config.Server.Cache, config.Server.MemcachedAddr, rdb)
 	if err != nil {
-		panic("failed to migrate schema: " + err.Error())
+		panic(err)
 	}
-
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     config.Server.RedisAddr,
-		Password: "", // no password set
-		DB:       config.Server.RedisDB,
-	})
-	err = redisotel.InstrumentTracing(
-		rdb,
-		redisotel.WithAttributes(
-			attribute.KeyValue{
-				Key:   "db.name",
-				Value: attribute.StringValue("redis"),
-			},
-		),
-	)
-	if err != nil {
-		panic("failed to setup tracing plugin")
-	}
-
-	mc := memcache.New(config.Server.MemcachedAddr)
 	defer mc.Close()
 
-	client := client.NewClient()
-	client.SetUserAgent("CCAPI", version)
-	timelineKeeper := timeline.NewKeeper(rdb, mc, client, conconf)
-
-	globalPolicy := concurrent.GetDefaultGlobalPolicy()
-
-	policy := concurrent.SetupPolicyService(rdb, globalPolicy, conconf)
-
-	domainService := concurrent.SetupDomainService(db, client, conconf)
-	domainHandler := domain.NewHandler(domainService)
-
-	userKvService := concurrent.SetupUserkvService(db)
-	userkvHandler := userkv.NewHandler(userKvService)
-
-	messageService := concurrent.SetupMessageService(db, rdb, mc, timelineKeeper, client, policy, conconf)
-	messageHandler := message.NewHandler(messageService)
-
-	associationService := concurrent.SetupAssociationService(db, rdb, mc, timelineKeeper, client, policy, conconf)
-	associationHandler := association.NewHandler(associationService)
-
-	profileService := concurrent.SetupProfileService(db, rdb, mc, client, policy, conconf)
-	profileHandler := profile.NewHandler(profileService)
-
-	timelineService := concurrent.SetupTimelineService(db, rdb, mc, timelineKeeper, client, policy, conconf)
-	timelineHandler := timeline.NewHandler(timelineService)
-
-	entityService := concurrent.SetupEntityService(db, rdb, mc, client, policy, conconf)
-	entityHandler := entity.NewHandler(entityService)
-
-	authService := concurrent.SetupAuthService(db, rdb, mc, client, policy, conconf)
-	authHandler := auth.NewHandler(authService)
-
-	keyService := concurrent.SetupKeyService(db, rdb, mc, client, conconf)
-	keyHandler := key.NewHandler(keyService)
-
-	ackService := concurrent.SetupAckService(db, rdb, mc, client, policy, conconf)
-	ackHandler := ack.NewHandler(ackService)
-
-	storeService := concurrent.SetupStoreService(db, rdb, mc, timelineKeeper, client, policy, conconf, config.Server.RepositoryPath)
-	storeHandler := store.NewHandler(storeService)
-
-	subscriptionService := concurrent.SetupSubscriptionService(db, rdb, mc, client, policy, conconf)
-	subscriptionHandler := subscription.NewHandler(subscriptionService)
-
-	jobService := concurrent.SetupJobService(db)
-	jobHandler := job.NewHandler(jobService)
-	jobReactor := job.NewReactor(storeService, jobService)
-
-	webpushOpts := webpush.Options{
-		Subscriber:      "webmaster@" + config.Concrnt.FQDN,
-		VAPIDPublicKey:  config.Server.VapidPublicKey,
-		VAPIDPrivateKey: config.Server.VapidPrivateKey,
-		TTL:             60,
+	primary := Tenant{
+		Concrnt:        config.Concrnt,
+		Profile:        config.Profile,
+		RedisDB:        config.Server.RedisDB,
+		MemcachedAddr:  config.Server.MemcachedAddr,
+		RepositoryPath: config.Server.RepositoryPath,
 	}
-
-	notificationService := concurrent.SetupNotificationService(db)
-	notificationHandler := notification.NewHandler(notificationService)
-	notificationReactor := notification.NewReactor(notificationService, timelineService, webpushOpts)
-
-	apiV1 := e.Group("", auth.ReceiveGatewayAuthPropagation)
-	// store
-	apiV1.POST("/commit", storeHandler.Commit)
-
-	// domain
-	apiV1.GET("/domain", func(c echo.Context) error {
-		meta := config.Profile
-		meta.Registration = config.Concrnt.Registration
-		meta.Version = version
-		meta.BuildInfo = BuildInfo{
-			BuildTime:    buildTime,
-			BuildMachine: buildMachine,
-			GoVersion:    goVersion,
+	scanner := cache.NewScanner(config.Server.Cache, config.Server.MemcachedAddr, rdb)
+	app := setupTenant(e, e.Group(""), config, primary, db, sqlDB, rdb, mc, scanner)
+	timelineService := app.timelineService
+	messageService := app.messageService
+	entityService := app.entityService
+	profileService := app.profileService
+	associationService := app.associationService
+	storeService := app.storeService
+
+	// additional domains are routed by the Host header.
+	// keys like rate limits and scheduler locks are not namespaced in redis, so every domain needs its own redis db
+	redisDBs := map[int]string{config.Server.RedisDB: config.Concrnt.FQDN}
+	for _, tenant := range config.Tenants {
+		if tenant.DBSchema == "" {
+			panic(fmt.Sprintf("tenant %s: dbSchema is required", tenant.Concrnt.FQDN))
 		}
-		meta.SiteKey = config.Server.CaptchaSitekey
-		meta.VapidKey = config.Server.VapidPublicKey
-
-		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": core.Domain{
-			ID:        conconf.FQDN,
-			CCID:      conconf.CCID,
-			CSID:      conconf.CSID,
-			Dimension: conconf.Dimension,
-			Meta:      meta,
-		}})
-	})
-	apiV1.GET("/domain/:id", domainHandler.Get)
-	apiV1.GET("/domains", domainHandler.List)
-
-	// entity
-	apiV1.GET("/entity", entityHandler.GetSelf, auth.Restrict(auth.ISREGISTERED))
-	apiV1.GET("/entity/:id", entityHandler.Get)
-	apiV1.GET("/entity/:id/acking", ackHandler.GetAcking)
-	apiV1.GET("/entity/:id/acker", ackHandler.GetAcker)
-	apiV1.GET("/entities", entityHandler.List)
-
-	// message
-	apiV1.GET("/message/:id", messageHandler.Get)
-	apiV1.GET("/message/:id/associations", associationHandler.GetFiltered)
-	apiV1.GET("/message/:id/associationcounts", associationHandler.GetCounts)
-	apiV1.GET("/message/:id/associations/mine", associationHandler.GetOwnByTarget, auth.Restrict(auth.ISKNOWN))
-
-	// association
-	apiV1.GET("/association/:id", associationHandler.Get)
-
-	// profile
-	apiV1.GET("/profile/:id", profileHandler.Get)
-	apiV1.GET("/profile/:owner/:semanticid", profileHandler.GetBySemanticID)
-	apiV1.GET("/profiles", profileHandler.Query)
-	apiV1.GET("/profile/:id/associations", associationHandler.GetAttached)
-
-	// timeline
-	apiV1.GET("/timeline/:id", timelineHandler.Get)
-	apiV1.GET("/timeline/:id/query", timelineHandler.Query)
-	apiV1.GET("/timeline/:id/associations", associationHandler.GetAttached)
-	apiV1.GET("/timelines", timelineHandler.List)
-	apiV1.GET("/timelines/mine", timelineHandler.ListMine)
-	apiV1.GET("/timelines/recent", timelineHandler.Recent)
-	apiV1.GET("/timelines/range", timelineHandler.Range)
-	apiV1.GET("/timelines/chunks", timelineHandler.GetChunks)
-	apiV1.GET("/timelines/retracted", timelineHandler.Retracted)
-	apiV1.GET("/timelines/realtime", timelineHandler.Realtime)
-
-	// chunk
-	apiV1.GET("/chunks/itr", timelineHandler.GetChunkItr)
-	apiV1.GET("/chunks/body", timelineHandler.GetChunkBody)
-
-	// userkv
-	apiV1.GET("/kv/:key", userkvHandler.Get, auth.Restrict(auth.ISREGISTERED))
-	apiV1.PUT("/kv/:key", userkvHandler.Upsert, auth.Restrict(auth.ISREGISTERED))
-
-	// auth
-	apiV1.GET("/auth/passport", authHandler.GetPassport, auth.Restrict(auth.ISLOCAL))
-
-	// key
-	apiV1.GET("/key/:id", keyHandler.GetKeyResolution)
-	apiV1.GET("/keys/mine", keyHandler.GetKeyMine, auth.Restrict(auth.ISREGISTERED))
-
-	// subscription
-	apiV1.GET("/subscription/:id", subscriptionHandler.GetSubscription)
-	apiV1.GET("/subscription/:id/associations", associationHandler.GetAttached)
-	apiV1.GET("/subscriptions/mine", subscriptionHandler.GetOwnSubscriptions, auth.Restrict(auth.ISLOCAL))
-
-	// storage
-	apiV1.GET("/repository", storeHandler.Get, auth.Restrict(auth.ISREGISTERED))
-	apiV1.POST("/repository", storeHandler.Post, auth.Restrict(auth.ISLOCAL))
-	apiV1.GET("/repositories/sync", storeHandler.GetSyncStatus, auth.Restrict(auth.ISREGISTERED))
-	apiV1.POST("/repositories/sync", storeHandler.PerformSync, auth.Restrict(auth.ISREGISTERED))
-
-	// job
-	apiV1.GET("/jobs", jobHandler.List, auth.Restrict(auth.ISREGISTERED))
-	apiV1.POST("/jobs", jobHandler.Create, auth.Restrict(auth.ISREGISTERED))
-	apiV1.DELETE("/job/:id", jobHandler.Cancel, auth.Restrict(auth.ISREGISTERED))
-
-	// notification
-	apiV1.POST("/notification", notificationHandler.Subscribe, auth.Restrict(auth.ISREGISTERED))
-	apiV1.DELETE("/notification/:owner/:vendor_id", notificationHandler.Delete, auth.Restrict(auth.ISREGISTERED))
-	apiV1.GET("/notification/:owner/:vendor_id", notificationHandler.Get, auth.Restrict(auth.ISREGISTERED))
-
-	// misc
-	e.GET("/health", func(c echo.Context) (err error) {
-		ctx := c.Request().Context()
-
-		err = sqlDB.Ping()
+		if other, ok := redisDBs[tenant.RedisDB]; ok {
+			panic(fmt.Sprintf("tenant %s: redisDB %d is already used by %s", tenant.Concrnt.FQDN, tenant.RedisDB, other))
+		}
+		redisDBs[tenant.RedisDB] = tenant.Concrnt.FQDN
+
+		tenantDB, tenantSQLDB, err := openDatabase(config.Server.Dsn, tenant.DBSchema, config.Server.Database, !config.Server.DisableAutoMigrate, gormLogger)
 		if err != nil {
-			return c.String(http.StatusInternalServerError, "db error")
+			panic(fmt.Sprintf("tenant %s: %v", tenant.Concrnt.FQDN, err))
 		}
+		defer tenantSQLDB.Close()
+		prometheus.MustRegister(collectors.NewDBStatsCollector(tenantSQLDB, tenant.DBSchema))
 
-		err = rdb.Ping(ctx).Err()
+		tenantRDB, err := openRedis(config.Server.RedisAddr, tenant.RedisDB)
 		if err != nil {
-			return c.String(http.StatusInternalServerError, "redis error")
+			panic(fmt.Sprintf("tenant %s: %v", tenant.Concrnt.FQDN, err))
 		}
 
-		return c.String(http.StatusOK, "ok")
-	})
+		// keys of the cache are fixed names like tl:readers, so a shared cache is namespaced by the domain.
+		// otherwise the domains would read each other's reader lists and counters
+		tenantMC := cache.NewNamespaced(mc, tenant.Concrnt.FQDN)
+		tenantScanner := cache.NewNamespacedScanner(scanner, tenant.Concrnt.FQDN)
+		if (config.Server.Cache.Backend == "" || config.Server.Cache.Backend == cache.BackendMemcached) &&
+			tenant.MemcachedAddr != "" && tenant.MemcachedAddr != config.Server.MemcachedAddr {
+			tenantMC, err = cache.New(config.Server.Cache, tenant.MemcachedAddr, tenantRDB)
+			if err != nil {
+				panic(fmt.Sprintf("tenant %s: %v", tenant.Concrnt.FQDN, err))
+			}
+			defer tenantMC.Close()
+			tenantScanner = cache.NewScanner(config.Server.Cache, tenant.MemcachedAddr, tenantRDB)
+		}
+
+		t := setupTenant(e, e.Host(tenant.Concrnt.FQDN), config, tenant, tenantDB, tenantSQLDB, tenantRDB, tenantMC, tenantScanner)
+		t.start(context.Background())
+		slog.Info(fmt.Sprintf("Tenant loaded: %s (%s)", t.config.FQDN, t.config.CCID))
+	}
 
 	var timelineSubscriptionMetrics = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -407,15 +224,24 @@ func main() {
 	)
 	prometheus.MustRegister(resourceCountMetrics)
 
-	go func() {
-		for {
-			time.Sleep(15 * time.Second)
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
+	var queueDepthMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_queue_depth",
+			Help: "items waiting in the queues of the domain",
+		},
+		[]string{"queue"},
+	)
+	prometheus.MustRegister(queueDepthMetrics)
+
+	err = app.scheduler.Register(scheduler.Job{
+		Name:        "metrics.resources",
+		Spec:        "@every 15s",
+		Timeout:     10 * time.Second,
+		PerInstance: true,
+		Run: func(ctx context.Context) error {
 			subscriptions, err := timelineService.ListTimelineSubscriptions(ctx)
 			if err != nil {
-				slog.Error(fmt.Sprintf("failed to list timeline subscriptions: %v", err))
-				continue
+				return fmt.Errorf("failed to list timeline subscriptions: %w", err)
 			}
 			for timeline, count := range subscriptions {
 				timelineSubscriptionMetrics.WithLabelValues(timeline).Set(float64(count))
@@ -423,55 +249,84 @@ func main() {
 
 			count, err := messageService.Count(ctx)
 			if err != nil {
-				slog.Error(fmt.Sprintf("failed to count messages: %v", err))
-				continue
+				return fmt.Errorf("failed to count messages: %w", err)
 			}
 			resourceCountMetrics.WithLabelValues("message").Set(float64(count))
 
 			count, err = entityService.Count(ctx)
 			if err != nil {
-				slog.Error(fmt.Sprintf("failed to count entities: %v", err))
-				continue
+				return fmt.Errorf("failed to count entities: %w", err)
 			}
 			resourceCountMetrics.WithLabelValues("entity").Set(float64(count))
 
 			count, err = profileService.Count(ctx)
 			if err != nil {
-				slog.Error(fmt.Sprintf("failed to count profiles: %v", err))
-				continue
+				return fmt.Errorf("failed to count profiles: %w", err)
 			}
 			resourceCountMetrics.WithLabelValues("profile").Set(float64(count))
 
 			count, err = associationService.Count(ctx)
 			if err != nil {
-				slog.Error(fmt.Sprintf("failed to count associations: %v", err))
-				continue
+				return fmt.Errorf("failed to count associations: %w", err)
 			}
 			resourceCountMetrics.WithLabelValues("association").Set(float64(count))
 
 			count, err = timelineService.Count(ctx)
 			if err != nil {
-				slog.Error(fmt.Sprintf("failed to count timelines: %v", err))
-				continue
+				return fmt.Errorf("failed to count timelines: %w", err)
 			}
 			resourceCountMetrics.WithLabelValues("timeline").Set(float64(count))
 
+			queues, err := app.statsService.Queues(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to probe queues: %w", err)
+			}
+			for queue, depth := range queues {
+				queueDepthMetrics.WithLabelValues(queue).Set(float64(depth))
+			}
+
 			timelineService.UpdateMetrics()
-		}
-	}()
+			return nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
 
 	e.GET("/metrics", echoprometheus.NewHandler())
 
-	timelineKeeper.Start(context.Background())
-	jobReactor.Start(context.Background())
-	notificationReactor.Start(context.Background())
+	app.start(context.Background())
+
+	if config.Server.GrpcAddr != "" {
+		tlsConfig, err := rpc.ServerTLSConfig(config.Server.GrpcCertFile, config.Server.GrpcKeyFile, config.Server.GrpcClientCAFile)
+		if err != nil {
+			panic("failed to load grpc tls config: " + err.Error())
+		}
+		rpcServer := rpc.NewServer(entityService, storeService, timelineService)
+		go func() {
+			slog.Info(fmt.Sprintf("Internal gRPC API listening on %s", config.Server.GrpcAddr))
+			err := rpc.ListenAndServe(config.Server.GrpcAddr, rpcServer, tlsConfig)
+			if err != nil {
+				slog.Error("grpc server stopped", slog.String("error", err.Error()))
+			}
+		}()
+	}
 
 	port := "192.168.10.14:8010"
 	envport := os.Getenv("CC_API_PORT")
 	if envport != "" {
 		port = ":" + envport
 	}
-	e.Logger.Fatal(e.Start(port))
+
+	hosts := []string{config.Concrnt.FQDN}
+	for _, tenant := range config.Tenants {
+		hosts = append(hosts, tenant.Concrnt.FQDN)
+	}
+	listen, err := newListenConfig(config.Server, hosts, port)
+	if err != nil {
+		panic(err)
+	}
+	e.Logger.Fatal(serve(e, listen))
 }
 
 func setupTraceProvider(endpoint string, serviceName string, serviceVersion string) (func(), error) {
@@ -493,7 +348,7 @@ func setupTraceProvider(endpoint string, serviceName string, serviceVersion stri
 	)
 
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithBatcher(health.SpanExporter(exporter, traceExports)),
 		sdktrace.WithSampler(sdktrace.AlwaysSample()),
 		sdktrace.WithResource(resource),
 	)
@@ -514,3 +369,50 @@ func setupTraceProvider(endpoint string, serviceName string, serviceVersion stri
 	}
 	return cleanup, nil
 }
+
+const defaultMetricsInterval = time.Minute
+
+// setupMeterProvider pushes the metrics of the prometheus registry to the OTLP endpoint.
+// the same request latencies, pool stats, cache and queue gauges served on /metrics are sent,
+// so a stack without a prometheus scraper gets them too
+func setupMeterProvider(endpoint string, serviceName string, serviceVersion string, interval time.Duration) (func(), error) {
+
+	exporter, err := otlpmetrichttp.New(
+		context.Background(),
+		otlpmetrichttp.WithEndpoint(endpoint),
+		otlpmetrichttp.WithInsecure(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resource := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(serviceVersion),
+	)
+
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+			health.MetricExporter(exporter, metricExports),
+			sdkmetric.WithInterval(interval),
+			sdkmetric.WithProducer(prometheusbridge.NewMetricProducer()),
+		)),
+		sdkmetric.WithResource(resource),
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	cleanup := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if err := meterProvider.Shutdown(ctx); err != nil {
+			slog.Error(fmt.Sprintf("Failed to shutdown meter provider: %v", err))
+		}
+	}
+	return cleanup, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/health"
+	"github.com/totegamma/concurrent/internal/openapi"
+	"github.com/totegamma/concurrent/x/activitypub"
+	"github.com/totegamma/concurrent/x/auth"
+	"github.com/totegamma/concurrent/x/domain"
+	"github.com/totegamma/concurrent/x/entity"
+	"github.com/totegamma/concurrent/x/invite"
+	"github.com/totegamma/concurrent/x/job"
+	"github.com/totegamma/concurrent/x/mastodon"
+	"github.com/totegamma/concurrent/x/migration"
+	"github.com/totegamma/concurrent/x/quota"
+	"github.com/totegamma/concurrent/x/scheduler"
+	"github.com/totegamma/concurrent/x/schema"
+	"github.com/totegamma/concurrent/x/stats"
+	"github.com/totegamma/concurrent/x/store"
+	"github.com/totegamma/concurrent/x/timeline"
+	"github.com/totegamma/concurrent/x/trend"
+)
+
+// describeRoutes registers request/response schemas of the api routes.
+// routes which are not described here are still listed in the document without schemas.
+func describeRoutes(r *openapi.Registry) {
+	// store
+	r.Describe(http.MethodPost, "/commit", openapi.Spec{Summary: "Commit a signed document", Tags: []string{"store"}, Request: core.Commit{}, Response: new(any)})
+	r.Describe(http.MethodPost, "/commit/associations", openapi.Spec{Summary: "Commit association documents in a batch", Tags: []string{"store"}, Request: store.BatchCommitRequest{}, Response: []core.BatchResult{}})
+
+	// domain
+	r.Describe(http.MethodGet, "/domain", openapi.Spec{Summary: "Get this domain", Tags: []string{"domain"}, Response: core.Domain{}})
+	r.Describe(http.MethodGet, "/domain/keys", openapi.Spec{Summary: "Get the state of the CSID rotation of this domain", Tags: []string{"domain"}, Response: core.DomainKeyStatus{}, Auth: true})
+	r.Describe(http.MethodPost, "/domain/keys/announce", openapi.Spec{Summary: "Tell known domains to fetch the current CSID of this domain", Tags: []string{"domain"}, Response: []core.DomainAnnouncement{}, Auth: true})
+	r.Describe(http.MethodGet, "/domain/:id", openapi.Spec{Summary: "Get a domain", Tags: []string{"domain"}, Response: core.Domain{}})
+	r.Describe(http.MethodGet, "/domains", openapi.Spec{Summary: "List known domains", Tags: []string{"domain"}, Response: []core.Domain{}})
+	r.Describe(http.MethodPost, "/domains/hello", openapi.Spec{Summary: "Have this domain fetch a known domain again", Tags: []string{"domain"}, Request: domain.HelloRequest{}, Response: core.Domain{}})
+	r.Describe(http.MethodGet, "/domains/federation", openapi.Spec{Summary: "Get success rate, latency and breaker state of remote domains", Tags: []string{"domain"}, Response: []core.FederationStats{}, Auth: true})
+	r.Describe(http.MethodGet, "/domains/peers", openapi.Spec{Summary: "Get the state and the settings of the known remote domains", Tags: []string{"domain"}, Response: []core.DomainPeer{}, Auth: true})
+	r.Describe(http.MethodGet, "/domains/peers/:fqdn", openapi.Spec{Summary: "Get the state and the settings of a remote domain", Tags: []string{"domain"}, Response: core.DomainPeer{}, Auth: true})
+	r.Describe(http.MethodPut, "/domains/peers/:fqdn/settings", openapi.Spec{Summary: "Block, silence or override the rate limits of a remote domain", Tags: []string{"domain"}, Request: core.DomainSettingsUpdate{}, Response: core.DomainPeer{}, Auth: true})
+
+	// entity
+	r.Describe(http.MethodGet, "/entity", openapi.Spec{Summary: "Get requester entity", Tags: []string{"entity"}, Response: core.Entity{}, Auth: true})
+	r.Describe(http.MethodGet, "/entity/alias", openapi.Spec{Summary: "Verify the DNS record of the requester's alias again", Tags: []string{"entity"}, Response: core.AliasStatus{}, Auth: true})
+	r.Describe(http.MethodPut, "/entity/alias", openapi.Spec{Summary: "Attach an alias once its DNS record is verified", Tags: []string{"entity"}, Request: entity.AliasRequest{}, Response: core.AliasStatus{}, Auth: true})
+	r.Describe(http.MethodDelete, "/entity/alias", openapi.Spec{Summary: "Remove the requester's alias", Tags: []string{"entity"}, Auth: true})
+	r.Describe(http.MethodGet, "/entity/:id", openapi.Spec{Summary: "Get an entity", Tags: []string{"entity"}, Query: []string{"hint"}, Response: core.Entity{}})
+	r.Describe(http.MethodGet, "/entity/:id/acking", openapi.Spec{Summary: "List entities acked by the entity", Tags: []string{"entity"}, Response: []core.Ack{}})
+	r.Describe(http.MethodGet, "/entity/:id/acker", openapi.Spec{Summary: "List entities acking the entity", Tags: []string{"entity"}, Response: []core.Ack{}})
+	r.Describe(http.MethodGet, "/entities", openapi.Spec{Summary: "List entities", Tags: []string{"entity"}, Query: []string{"domain", "tag", "minscore", "maxscore", "since", "until", "order", "cursor", "limit"}, Response: []core.Entity{}})
+	r.Describe(http.MethodGet, "/entities/tags", openapi.Spec{Summary: "List the tags the admin may give to entities", Tags: []string{"entity"}, Response: core.TagTaxonomy{}})
+	r.Describe(http.MethodGet, "/entity/:id/tags", openapi.Spec{Summary: "List the live tags of an entity", Tags: []string{"entity"}, Response: []core.EntityTag{}, Auth: true})
+	r.Describe(http.MethodPut, "/entity/:id/tags/:name", openapi.Spec{Summary: "Give a tag to an entity, optionally until expiresAt", Tags: []string{"entity"}, Request: entity.TagRequest{}, Response: core.EntityTag{}, Auth: true})
+	r.Describe(http.MethodDelete, "/entity/:id/tags/:name", openapi.Spec{Summary: "Remove a tag from an entity", Tags: []string{"entity"}, Auth: true})
+	r.Describe(http.MethodGet, "/entity/:id/badges", openapi.Spec{Summary: "List the live badges of an entity", Tags: []string{"entity"}, Response: []core.Badge{}})
+	r.Describe(http.MethodPut, "/entity/:id/badges/:name", openapi.Spec{Summary: "Issue a badge signed by the domain to a local entity, optionally until expiresAt", Tags: []string{"entity"}, Request: entity.BadgeRequest{}, Response: core.Badge{}, Auth: true})
+	r.Describe(http.MethodDelete, "/entity/:id/badges/:name", openapi.Spec{Summary: "Revoke a badge of an entity", Tags: []string{"entity"}, Auth: true})
+	r.Describe(http.MethodPost, "/entity/:id/refresh", openapi.Spec{Summary: "Pull a remote entity from its domain again", Tags: []string{"entity"}, Response: core.Entity{}, Auth: true})
+	r.Describe(http.MethodPut, "/entity/:id/score", openapi.Spec{Summary: "Override the score of an entity", Tags: []string{"entity"}, Request: map[string]any{"score": 0, "fixed": false}, Auth: true})
+	r.Describe(http.MethodGet, "/spam/quarantine", openapi.Spec{Summary: "List documents quarantined as spam", Tags: []string{"spam"}, Query: []string{"limit"}, Response: []core.QuarantinedDocument{}, Auth: true})
+	r.Describe(http.MethodPost, "/spam/quarantine/:id/release", openapi.Spec{Summary: "Commit a quarantined document reviewed as not spam", Tags: []string{"spam"}, Auth: true})
+	r.Describe(http.MethodDelete, "/spam/quarantine/:id", openapi.Spec{Summary: "Drop a quarantined document reviewed as spam", Tags: []string{"spam"}, Auth: true})
+	r.Describe(http.MethodGet, "/deletions", openapi.Spec{Summary: "List account deletions running or failed", Tags: []string{"entity"}, Response: []core.AccountDeletion{}, Auth: true})
+	r.Describe(http.MethodGet, "/deletions/:id", openapi.Spec{Summary: "Get the progress of an account deletion", Tags: []string{"entity"}, Response: core.AccountDeletion{}, Auth: true})
+	r.Describe(http.MethodPost, "/relay/events", openapi.Spec{Summary: "Receive timeline events from a relay or one of its peers", Tags: []string{"relay"}, Request: core.Commit{}, Response: map[string]int{"accepted": 0}})
+	r.Describe(http.MethodGet, "/relay/peers", openapi.Spec{Summary: "List domains allowed to use this domain as a relay", Tags: []string{"relay"}, Response: []core.RelayPeer{}, Auth: true})
+	r.Describe(http.MethodPut, "/relay/peers/:domain", openapi.Spec{Summary: "Allow a domain to use this domain as a relay", Tags: []string{"relay"}, Response: core.RelayPeer{}, Auth: true})
+	r.Describe(http.MethodDelete, "/relay/peers/:domain", openapi.Spec{Summary: "Remove a domain from the peers of the relay", Tags: []string{"relay"}, Auth: true})
+	r.Describe(http.MethodPost, "/deletions/:id/retry", openapi.Spec{Summary: "Resume a failed account deletion", Tags: []string{"entity"}, Response: core.AccountDeletion{}, Auth: true})
+	r.Describe(http.MethodGet, "/entity/:id/score/history", openapi.Spec{Summary: "List score changes of an entity", Tags: []string{"entity"}, Query: []string{"limit"}, Response: []core.EntityScoreHistory{}, Auth: true})
+
+	// message
+	r.Describe(http.MethodGet, "/message/:id", openapi.Spec{Summary: "Get a message", Tags: []string{"message"}, Response: core.Message{}})
+	r.Describe(http.MethodGet, "/message/:id/receipts", openapi.Spec{Summary: "List the deliveries of an own message to remote domains", Tags: []string{"message"}, Response: []core.DeliveryReceipt{}, Auth: true})
+	r.Describe(http.MethodGet, "/message/:id/associations", openapi.Spec{Summary: "List associations of a message", Tags: []string{"association"}, Query: []string{"schema", "variant"}, Response: []core.Association{}})
+	r.Describe(http.MethodGet, "/message/:id/associationcounts", openapi.Spec{Summary: "Count associations of a message", Tags: []string{"association"}, Query: []string{"schema"}, Response: map[string]int64{}})
+	r.Describe(http.MethodGet, "/message/:id/associations/mine", openapi.Spec{Summary: "List own associations of a message", Tags: []string{"association"}, Response: []core.Association{}, Auth: true})
+	r.Describe(http.MethodGet, "/message/:id/reposters", openapi.Spec{Summary: "List reposters of a message", Tags: []string{"association"}, Response: map[string]any{}})
+	r.Describe(http.MethodGet, "/message/:id/thread", openapi.Spec{Summary: "Get the reply tree of a message", Tags: []string{"message"}, Query: []string{"depth", "limit"}, Response: core.Thread{}})
+	r.Describe(http.MethodGet, "/message/:id/thread/timeline", openapi.Spec{Summary: "Get the thread timeline of the conversation of a message", Tags: []string{"message"}, Response: core.Timeline{}})
+
+	// association
+	r.Describe(http.MethodGet, "/association/:id", openapi.Spec{Summary: "Get an association", Tags: []string{"association"}, Response: core.Association{}})
+
+	// profile
+	r.Describe(http.MethodGet, "/profile/:id", openapi.Spec{Summary: "Get a profile, fetching one of another domain from the hint", Tags: []string{"profile"}, Query: []string{"hint"}, Response: core.Profile{}})
+	r.Describe(http.MethodGet, "/profile/:owner/:semanticid", openapi.Spec{Summary: "Get a profile by semantic id", Tags: []string{"profile"}, Response: core.Profile{}})
+	r.Describe(http.MethodGet, "/profiles", openapi.Spec{Summary: "Query profiles", Tags: []string{"profile"}, Query: []string{"author", "schema", "limit", "since", "until"}, Response: []core.Profile{}})
+	r.Describe(http.MethodGet, "/profile/:id/associations", openapi.Spec{Summary: "List associations of a profile", Tags: []string{"association"}, Response: []core.Association{}})
+
+	// timeline
+	r.Describe(http.MethodGet, "/timeline/:id", openapi.Spec{Summary: "Get a timeline", Tags: []string{"timeline"}, Response: core.Timeline{}})
+	r.Describe(http.MethodGet, "/timeline/:id/query", openapi.Spec{Summary: "Query items of a timeline, streamed a line each with Accept: application/x-ndjson", Tags: []string{"timeline"}, Query: []string{"schema", "owner", "author", "until", "limit"}, Response: []core.TimelineItem{}})
+	r.Describe(http.MethodGet, "/timeline/:id/associations", openapi.Spec{Summary: "List associations of a timeline", Tags: []string{"association"}, Response: []core.Association{}})
+	r.Describe(http.MethodPost, "/timeline/:id/merge", openapi.Spec{Summary: "Merge an own timeline into another timeline of the domain", Tags: []string{"timeline"}, Request: timeline.MergeRequest{}, Response: core.Timeline{}, Auth: true})
+	r.Describe(http.MethodGet, "/timelines", openapi.Spec{Summary: "List timelines by schema", Tags: []string{"timeline"}, Query: []string{"schema"}, Response: []core.Timeline{}})
+	r.Describe(http.MethodGet, "/timelines/discover", openapi.Spec{Summary: "Discover timelines by activity and subscribers", Tags: []string{"timeline"}, Query: []string{"schema", "cursor", "limit", "federated"}, Response: []core.DiscoveredTimeline{}})
+	r.Describe(http.MethodGet, "/timelines/mine", openapi.Spec{Summary: "List own timelines", Tags: []string{"timeline"}, Response: []core.Timeline{}, Auth: true})
+	r.Describe(http.MethodGet, "/timelines/recent", openapi.Spec{Summary: "Get recent items of timelines", Tags: []string{"timeline"}, Query: []string{"timelines", "subscription", "include"}, Response: []core.TimelineItem{}})
+	r.Describe(http.MethodGet, "/timelines/range", openapi.Spec{Summary: "Get items of timelines in range", Tags: []string{"timeline"}, Query: []string{"timelines", "subscription", "since", "until", "include"}, Response: []core.TimelineItem{}})
+	r.Describe(http.MethodGet, "/timelines/chunks", openapi.Spec{Summary: "Get chunks of timelines", Tags: []string{"timeline"}, Query: []string{"timelines", "time", "include"}, Response: map[string]core.Chunk{}})
+	r.Describe(http.MethodGet, "/timelines/retracted", openapi.Spec{Summary: "List recently retracted items", Tags: []string{"timeline"}, Query: []string{"timelines"}, Response: map[string][]string{}})
+	r.Describe(http.MethodGet, "/timelines/realtime", openapi.Spec{Summary: "Subscribe timeline events (websocket)", Tags: []string{"timeline"}})
+	r.Describe(http.MethodGet, "/timelines/sse", openapi.Spec{Summary: "Subscribe timeline events (server-sent events)", Tags: []string{"timeline"}, Query: []string{"timelines"}})
+	r.Describe(http.MethodGet, "/timelines/bridges", openapi.Spec{Summary: "Get state of connections relaying remote timelines", Tags: []string{"timeline"}, Response: []core.BridgeStatus{}, Auth: true})
+
+	// chunk
+	r.Describe(http.MethodGet, "/chunks/itr", openapi.Spec{Summary: "Lookup chunk iterators", Tags: []string{"timeline"}, Query: []string{"timelines", "epoch"}, Response: map[string]string{}})
+	r.Describe(http.MethodGet, "/chunks/body", openapi.Spec{Summary: "Load chunk bodies", Tags: []string{"timeline"}, Query: []string{"query", "include"}, Response: map[string]core.Chunk{}})
+
+	// userkv
+	r.Describe(http.MethodGet, "/kv/:key", openapi.Spec{Summary: "Get a user kv value", Tags: []string{"userkv"}, Response: "", Auth: true})
+	r.Describe(http.MethodPut, "/kv/:key", openapi.Spec{Summary: "Upsert a user kv value", Tags: []string{"userkv"}, Query: []string{"ttl"}, Response: core.UserKV{}, Auth: true})
+	r.Describe(http.MethodDelete, "/kv/:key", openapi.Spec{Summary: "Delete a user kv value", Tags: []string{"userkv"}, Auth: true})
+	r.Describe(http.MethodGet, "/kvs", openapi.Spec{Summary: "List user kv values by prefix", Tags: []string{"userkv"}, Query: []string{"prefix", "limit"}, Response: []core.UserKV{}, Auth: true})
+	r.Describe(http.MethodGet, "/kv/:namespace/:key", openapi.Spec{Summary: "Get a user kv value of a namespace", Tags: []string{"userkv"}, Response: "", Auth: true})
+	r.Describe(http.MethodPut, "/kv/:namespace/:key", openapi.Spec{Summary: "Upsert a user kv value of a namespace", Tags: []string{"userkv"}, Query: []string{"ttl"}, Response: core.UserKV{}, Auth: true})
+	r.Describe(http.MethodDelete, "/kv/:namespace/:key", openapi.Spec{Summary: "Delete a user kv value of a namespace", Tags: []string{"userkv"}, Auth: true})
+	r.Describe(http.MethodGet, "/kvs/:namespace", openapi.Spec{Summary: "List user kv values of a namespace by prefix", Tags: []string{"userkv"}, Query: []string{"prefix", "limit"}, Response: []core.UserKV{}, Auth: true})
+
+	// mute
+	r.Describe(http.MethodGet, "/mutes", openapi.Spec{Summary: "List own mute rules", Tags: []string{"mute"}, Response: []core.MuteRule{}, Auth: true})
+	r.Describe(http.MethodPost, "/mutes", openapi.Spec{Summary: "Add a mute rule", Tags: []string{"mute"}, Request: map[string]string{"type": "keyword", "value": ""}, Response: core.MuteRule{}, Auth: true})
+	r.Describe(http.MethodDelete, "/mute/:id", openapi.Spec{Summary: "Delete a mute rule", Tags: []string{"mute"}, Auth: true})
+
+	// readstate
+	r.Describe(http.MethodGet, "/readstates", openapi.Spec{Summary: "List own read positions", Tags: []string{"readstate"}, Response: []core.ReadState{}, Auth: true})
+	r.Describe(http.MethodPost, "/readstates", openapi.Spec{Summary: "Merge read positions of a device", Tags: []string{"readstate"}, Request: map[string][]core.ReadState{"states": {}}, Response: []core.ReadState{}, Auth: true})
+	r.Describe(http.MethodPut, "/readstate/:target", openapi.Spec{Summary: "Move the read position of a timeline or subscription forward", Tags: []string{"readstate"}, Response: core.ReadState{}, Auth: true})
+
+	// auth
+	r.Describe(http.MethodGet, "/auth/passport", openapi.Spec{Summary: "Issue a passport", Tags: []string{"auth"}, Query: []string{"audience", "scopes"}, Response: "", Auth: true})
+	r.Describe(http.MethodPost, "/auth/delegation", openapi.Spec{Summary: "Let a companion service sign passports with its own key", Tags: []string{"auth"}, Request: auth.DelegationRequest{}, Response: "", Auth: true})
+	r.Describe(http.MethodGet, "/auth/tokens", openapi.Spec{Summary: "List scoped tokens", Tags: []string{"auth"}, Response: []core.AuthToken{}, Auth: true})
+	r.Describe(http.MethodPost, "/auth/tokens", openapi.Spec{Summary: "Issue a scoped token", Tags: []string{"auth"}, Request: auth.TokenRequest{}, Response: auth.IssuedToken{}, Auth: true})
+	r.Describe(http.MethodDelete, "/auth/token/:id", openapi.Spec{Summary: "Revoke a scoped token", Tags: []string{"auth"}, Auth: true})
+
+	// key
+	r.Describe(http.MethodGet, "/key/:id", openapi.Spec{Summary: "Resolve a key", Tags: []string{"key"}, Response: []core.Key{}})
+	r.Describe(http.MethodGet, "/keys/mine", openapi.Spec{Summary: "List own keys", Tags: []string{"key"}, Response: []core.Key{}, Auth: true})
+	r.Describe(http.MethodGet, "/keys/sessions", openapi.Spec{Summary: "List own subkeys with their last use", Tags: []string{"key"}, Response: []core.KeySession{}, Auth: true})
+	r.Describe(http.MethodDelete, "/key/:id", openapi.Spec{Summary: "Revoke an own subkey with a signed revoke document", Tags: []string{"key"}, Request: core.Commit{}, Response: core.Key{}, Auth: true})
+	r.Describe(http.MethodPost, "/key/:id/reverify", openapi.Spec{Summary: "Re-validate documents signed after a key revocation", Tags: []string{"key"}, Request: map[string]bool{"remove": false}, Response: core.Job{}, Auth: true})
+	r.Describe(http.MethodGet, "/key/:id/invalid", openapi.Spec{Summary: "List documents found invalid after a key revocation", Tags: []string{"key"}, Response: []core.InvalidDocument{}, Auth: true})
+
+	// subscription
+	r.Describe(http.MethodGet, "/subscription/:id", openapi.Spec{Summary: "Get a subscription", Tags: []string{"subscription"}, Response: core.Subscription{}})
+	r.Describe(http.MethodGet, "/subscription/:id/associations", openapi.Spec{Summary: "List associations of a subscription", Tags: []string{"association"}, Response: []core.Association{}})
+	r.Describe(http.MethodGet, "/subscriptions/mine", openapi.Spec{Summary: "List own subscriptions", Tags: []string{"subscription"}, Response: []core.Subscription{}, Auth: true})
+
+	// storage
+	r.Describe(http.MethodGet, "/repository", openapi.Spec{Summary: "Export own repository", Tags: []string{"store"}, Query: []string{"since", "until"}, Auth: true})
+	r.Describe(http.MethodPost, "/repository", openapi.Spec{Summary: "Import a repository", Tags: []string{"store"}, Query: []string{"from"}, Auth: true})
+	r.Describe(http.MethodGet, "/repositories/sync", openapi.Spec{Summary: "Get repository sync status", Tags: []string{"store"}, Response: core.SyncStatus{}, Auth: true})
+	r.Describe(http.MethodPost, "/repositories/sync", openapi.Spec{Summary: "Start repository sync", Tags: []string{"store"}, Response: core.SyncStatus{}, Auth: true})
+	r.Describe(http.MethodPost, "/repositories/compact", openapi.Spec{Summary: "Drop deleted documents from own repository", Tags: []string{"store"}, Response: map[string]int{}, Auth: true})
+
+	// job
+	r.Describe(http.MethodGet, "/jobs", openapi.Spec{Summary: "List own jobs", Tags: []string{"job"}, Response: []core.Job{}, Auth: true})
+	r.Describe(http.MethodPost, "/jobs", openapi.Spec{Summary: "Create a job", Tags: []string{"job"}, Request: job.Job{}, Response: core.Job{}, Auth: true})
+	r.Describe(http.MethodDelete, "/job/:id", openapi.Spec{Summary: "Cancel a job", Tags: []string{"job"}, Response: core.Job{}, Auth: true})
+
+	// export
+	r.Describe(http.MethodPost, "/export", openapi.Spec{Summary: "Start an export of all own data", Tags: []string{"export"}, Response: core.Job{}, Auth: true})
+	r.Describe(http.MethodGet, "/export/:id", openapi.Spec{Summary: "Get the status of an export", Tags: []string{"export"}, Response: core.Job{}, Auth: true})
+	r.Describe(http.MethodGet, "/export/:id/download", openapi.Spec{Summary: "Download the archive of a completed export", Tags: []string{"export"}, Auth: true})
+	r.Describe(http.MethodPost, "/timeline/:id/snapshot", openapi.Spec{Summary: "Start a snapshot of a local timeline with its resolved documents", Tags: []string{"export"}, Response: core.Job{}, Auth: true})
+	r.Describe(http.MethodGet, "/snapshot/:id", openapi.Spec{Summary: "Get the status of a timeline snapshot", Tags: []string{"export"}, Response: core.Job{}, Auth: true})
+	r.Describe(http.MethodGet, "/snapshot/:id/download", openapi.Spec{Summary: "Download a completed timeline snapshot stored on disk", Tags: []string{"export"}, Auth: true})
+
+	// scheduler
+	r.Describe(http.MethodGet, "/scheduler/jobs", openapi.Spec{Summary: "List scheduled background jobs with their health", Tags: []string{"scheduler"}, Response: []scheduler.JobStatus{}, Auth: true})
+
+	// stats
+	r.Describe(http.MethodGet, "/admin/stats", openapi.Spec{Summary: "Get counts, rates, cache and queue stats for the operator dashboard", Tags: []string{"admin"}, Response: stats.Stats{}, Auth: true})
+	r.Describe(http.MethodGet, "/admin/migrations", openapi.Spec{Summary: "Get the versioned migrations and whether they are applied", Tags: []string{"admin"}, Response: []migration.Status{}, Auth: true})
+	r.Describe(http.MethodGet, "/admin/invites", openapi.Spec{Summary: "Get the invitation tree of the domain", Tags: []string{"admin"}, Query: []string{"root", "depth"}, Response: invite.Report{}, Auth: true})
+	r.Describe(http.MethodGet, "/quota", openapi.Spec{Summary: "Get the API consumption of the requester today", Tags: []string{"quota"}, Response: quota.Report{}, Auth: true})
+
+	// trend
+	r.Describe(http.MethodGet, "/trends", openapi.Spec{Summary: "Get trending hashtags of the domain", Tags: []string{"trend"}, Query: []string{"window", "limit"}, Response: []trend.Trend{}})
+
+	// recommendation
+	r.Describe(http.MethodGet, "/recommendations", openapi.Spec{Summary: "List the timelines recommended to the requester", Tags: []string{"recommendation"}, Response: []core.Recommendation{}, Auth: true})
+	r.Describe(http.MethodGet, "/recommendations/optout", openapi.Spec{Summary: "Get whether the requester opted out of the recommendations", Tags: []string{"recommendation"}, Response: false, Auth: true})
+	r.Describe(http.MethodPut, "/recommendations/optout", openapi.Spec{Summary: "Opt out of the recommendations", Tags: []string{"recommendation"}, Auth: true})
+	r.Describe(http.MethodDelete, "/recommendations/optout", openapi.Spec{Summary: "Opt back in to the recommendations", Tags: []string{"recommendation"}, Auth: true})
+
+	// schema
+	r.Describe(http.MethodGet, "/schemas", openapi.Spec{Summary: "List the schemas used on the domain with their usage", Tags: []string{"schema"}, Response: []schema.Entry{}})
+	r.Describe(http.MethodGet, "/schema/:id", openapi.Spec{Summary: "Get a schema with its usage, redirecting to the replacement of a deprecated one", Tags: []string{"schema"}, Query: []string{"redirect"}, Response: schema.Entry{}})
+	r.Describe(http.MethodPut, "/schema/:id/deprecation", openapi.Spec{Summary: "Deprecate a schema in favor of a replacement url", Tags: []string{"schema"}, Request: schema.DeprecateRequest{}, Response: schema.Entry{}, Auth: true})
+	r.Describe(http.MethodDelete, "/schema/:id/deprecation", openapi.Spec{Summary: "Remove the deprecation of a schema", Tags: []string{"schema"}, Response: schema.Entry{}, Auth: true})
+
+	// notification
+	r.Describe(http.MethodPost, "/notification", openapi.Spec{Summary: "Subscribe push notification", Tags: []string{"notification"}, Request: core.NotificationSubscription{}, Response: core.NotificationSubscription{}, Auth: true})
+	r.Describe(http.MethodDelete, "/notification/:owner/:vendor_id", openapi.Spec{Summary: "Unsubscribe push notification", Tags: []string{"notification"}, Auth: true})
+	r.Describe(http.MethodGet, "/notification/:owner/:vendor_id", openapi.Spec{Summary: "Get push notification subscription", Tags: []string{"notification"}, Response: core.NotificationSubscription{}, Auth: true})
+	r.Describe(http.MethodGet, "/notification/preference", openapi.Spec{Summary: "Get own push notification preference", Tags: []string{"notification"}, Response: core.NotificationPreference{}, Auth: true})
+	r.Describe(http.MethodPut, "/notification/preference", openapi.Spec{Summary: "Update own push notification preference", Tags: []string{"notification"}, Request: core.NotificationPreference{}, Response: core.NotificationPreference{}, Auth: true})
+
+	// activitypub
+	r.Describe(http.MethodGet, "/ap/actor/:ccid", openapi.Spec{Summary: "Get the ActivityPub actor of an entity", Tags: []string{"activitypub"}, Response: activitypub.Actor{}})
+	r.Describe(http.MethodGet, "/ap/actor/:ccid/followers", openapi.Spec{Summary: "Get the ActivityPub followers collection of an entity", Tags: []string{"activitypub"}, Response: activitypub.OrderedCollection{}})
+	r.Describe(http.MethodPost, "/ap/actor/:ccid/inbox", openapi.Spec{Summary: "Receive a signed activity", Tags: []string{"activitypub"}, Request: activitypub.Activity{}})
+	r.Describe(http.MethodGet, "/ap/note/:id", openapi.Spec{Summary: "Get a published message as a Note", Tags: []string{"activitypub"}, Response: activitypub.Note{}})
+	r.Describe(http.MethodGet, "/ap/timelines", openapi.Spec{Summary: "List own timelines published to ActivityPub", Tags: []string{"activitypub"}, Response: []core.ApTimeline{}, Auth: true})
+	r.Describe(http.MethodPut, "/ap/timeline/:id", openapi.Spec{Summary: "Publish a timeline to ActivityPub", Tags: []string{"activitypub"}, Response: core.ApTimeline{}, Auth: true})
+	r.Describe(http.MethodDelete, "/ap/timeline/:id", openapi.Spec{Summary: "Stop publishing a timeline to ActivityPub", Tags: []string{"activitypub"}, Auth: true})
+	r.Describe(http.MethodGet, "/ap/followers", openapi.Spec{Summary: "List own ActivityPub followers with their delivery state", Tags: []string{"activitypub"}, Response: []core.ApFollower{}, Auth: true})
+
+	// mastodon
+	r.Describe(http.MethodGet, "/instance", openapi.Spec{Summary: "Describe the domain to Mastodon clients", Tags: []string{"mastodon"}, Response: mastodon.Instance{}, Raw: true})
+	r.Describe(http.MethodGet, "/timelines/public", openapi.Spec{Summary: "Get the public timeline as Mastodon statuses", Tags: []string{"mastodon"}, Query: []string{"max_id", "limit"}, Response: []mastodon.Status{}, Raw: true})
+	r.Describe(http.MethodGet, "/accounts/lookup", openapi.Spec{Summary: "Look up a Mastodon account by its acct", Tags: []string{"mastodon"}, Query: []string{"acct"}, Response: mastodon.Account{}, Raw: true})
+	r.Describe(http.MethodGet, "/accounts/:id", openapi.Spec{Summary: "Get an entity as a Mastodon account", Tags: []string{"mastodon"}, Response: mastodon.Account{}, Raw: true})
+	r.Describe(http.MethodGet, "/accounts/:id/statuses", openapi.Spec{Summary: "Get the statuses of a Mastodon account", Tags: []string{"mastodon"}, Query: []string{"max_id", "limit"}, Response: []mastodon.Status{}, Raw: true})
+	r.Describe(http.MethodGet, "/statuses/:id", openapi.Spec{Summary: "Get a message as a Mastodon status", Tags: []string{"mastodon"}, Response: mastodon.Status{}, Raw: true})
+	r.Describe(http.MethodGet, "/statuses/:id/context", openapi.Spec{Summary: "Get the thread of a Mastodon status", Tags: []string{"mastodon"}, Response: mastodon.Context{}, Raw: true})
+
+	// misc
+	r.Describe(http.MethodGet, "/livez", openapi.Spec{Summary: "Liveness probe", Tags: []string{"misc"}})
+	r.Describe(http.MethodGet, "/readyz", openapi.Spec{Summary: "Readiness probe, failing while a critical dependency is down", Tags: []string{"misc"}, Query: []string{"verbose"}, Response: health.Report{}, Raw: true})
+	r.Describe(http.MethodGet, "/healthz", openapi.Spec{Summary: "Status of each dependency", Tags: []string{"misc"}, Query: []string{"verbose"}, Response: health.Report{}, Raw: true})
+	r.Describe(http.MethodGet, "/openapi.json", openapi.Spec{Summary: "This document", Tags: []string{"misc"}})
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
+
+	"github.com/totegamma/concurrent"
+	"github.com/totegamma/concurrent/client"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/internal/health"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/internal/openapi"
+	"github.com/totegamma/concurrent/x/ack"
+	"github.com/totegamma/concurrent/x/activitypub"
+	"github.com/totegamma/concurrent/x/association"
+	"github.com/totegamma/concurrent/x/auth"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/deletion"
+	"github.com/totegamma/concurrent/x/domain"
+	"github.com/totegamma/concurrent/x/entity"
+	"github.com/totegamma/concurrent/x/export"
+	"github.com/totegamma/concurrent/x/invite"
+	"github.com/totegamma/concurrent/x/job"
+	"github.com/totegamma/concurrent/x/key"
+	"github.com/totegamma/concurrent/x/keyspace"
+	"github.com/totegamma/concurrent/x/loadshed"
+	"github.com/totegamma/concurrent/x/mastodon"
+	"github.com/totegamma/concurrent/x/message"
+	"github.com/totegamma/concurrent/x/migration"
+	"github.com/totegamma/concurrent/x/mute"
+	"github.com/totegamma/concurrent/x/notification"
+	"github.com/totegamma/concurrent/x/profile"
+	"github.com/totegamma/concurrent/x/quota"
+	"github.com/totegamma/concurrent/x/readstate"
+	"github.com/totegamma/concurrent/x/recommendation"
+	"github.com/totegamma/concurrent/x/relay"
+	"github.com/totegamma/concurrent/x/reverify"
+	"github.com/totegamma/concurrent/x/scheduler"
+	"github.com/totegamma/concurrent/x/schema"
+	"github.com/totegamma/concurrent/x/score"
+	"github.com/totegamma/concurrent/x/spam"
+	"github.com/totegamma/concurrent/x/stats"
+	"github.com/totegamma/concurrent/x/store"
+	"github.com/totegamma/concurrent/x/subscription"
+	"github.com/totegamma/concurrent/x/timeline"
+	"github.com/totegamma/concurrent/x/trend"
+	"github.com/totegamma/concurrent/x/userkv"
+)
+
+// tenantRuntime holds the services of a domain which are used outside of request handling
+type tenantRuntime struct {
+	config              core.Config
+	timelineKeeper      timeline.Keeper
+	timelineBackfiller  timeline.Backfiller
+	jobReactor          job.Reactor
+	notificationReactor notification.Reactor
+	activitypubReactor  activitypub.Reactor
+	relayReactor        relay.Reactor
+	scheduler           scheduler.Scheduler
+	shedder             *loadshed.Shedder
+	keyspaceMonitor     keyspace.Monitor
+
+	entityService      core.EntityService
+	messageService     core.MessageService
+	profileService     core.ProfileService
+	associationService core.AssociationService
+	timelineService    core.TimelineService
+	storeService       core.StoreService
+	statsService       stats.Service
+}
+
+// openDatabase connects to postgres and migrates the tables unless autoMigrate is off.
+// if dbSchema is given, the tables are created in that postgres schema, and it is the search path of the connections
+// so that raw sql doesn't reach the tables of another tenant.
+func openDatabase(dsn, dbSchema string, dbConfig database.Config, autoMigrate bool, gormLogger logger.Interface) (*gorm.DB, *sql.DB, error) {
+	db, err := database.OpenSchema(dsn, dbConfig, &gorm.Config{
+		Logger:         gormLogger,
+		TranslateError: true,
+	}, dbSchema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+	sqlDB, err := db.DB() // for pinging
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	err = db.Use(tracing.NewPlugin(
+		tracing.WithDBName("postgres"),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to setup tracing plugin: %w", err)
+	}
+
+	err = database.CreateSchema(db, dbSchema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	migrations := migration.NewService(db, dbSchema)
+	if !autoMigrate {
+		pending, err := migrations.Pending(context.Background())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check migrations: %w", err)
+		}
+		if len(pending) > 0 {
+			slog.Warn("database has pending migrations. apply them with the migrate command", slog.String("schema", dbSchema), slog.Int("pending", len(pending)))
+		}
+		return db, sqlDB, nil
+	}
+
+	// Migrate the schema
+	slog.Info("start migrate", slog.String("schema", dbSchema))
+	err = db.AutoMigrate(migration.Models...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	_, err = migrations.Up(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	return db, sqlDB, nil
+}
+
+func openRedis(addr string, db int) (*redis.Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: "", // no password set
+		DB:       db,
+	})
+	err := redisotel.InstrumentTracing(
+		rdb,
+		redisotel.WithAttributes(
+			attribute.KeyValue{
+				Key:   "db.name",
+				Value: attribute.StringValue("redis"),
+			},
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup tracing plugin: %w", err)
+	}
+	return rdb, nil
+}
+
+// setupTenant builds the services of a domain and registers its routes to router
+func setupTenant(e *echo.Echo, router *echo.Group, config Config, tenant Tenant, db *gorm.DB, sqlDB *sql.DB, rdb *redis.Client, mc cache.Cache, scanner cache.Scanner) *tenantRuntime {
+	conconf := core.SetupConfig(tenant.Concrnt)
+
+	clientOptions := []client.ClientOption{
+		client.WithDomainIdentity(conconf.FQDN, conconf.CSID, conconf.PrivateKey),
+	}
+	for call, timeout := range config.Server.ClientCallTimeouts {
+		clientOptions = append(clientOptions, client.WithCallTimeout(call, timeout))
+	}
+	client := client.NewClient(clientOptions...)
+	client.SetUserAgent("CCAPI", version)
+	for _, remap := range config.Server.HostRemap {
+		client.RegisterHostRemap(remap.Host, remap.Remap, remap.UseHttps)
+	}
+	timelineKeeper := timeline.NewKeeper(rdb, mc, client, conconf)
+
+	globalPolicy := concurrent.GetDefaultGlobalPolicy()
+
+	policy := concurrent.SetupPolicyService(rdb, globalPolicy, conconf)
+
+	domainService := concurrent.SetupDomainService(db, client, conconf)
+
+	userKvService := concurrent.SetupUserkvService(db)
+	userkvHandler := userkv.NewHandler(userKvService)
+
+	muteService := concurrent.SetupMuteService(db, rdb, mc, client, policy, conconf)
+	muteHandler := mute.NewHandler(muteService)
+
+	readstateService := readstate.NewService(readstate.NewRepository(db, rdb))
+	readstateHandler := readstate.NewHandler(readstateService)
+
+	quotaService := quota.NewService(quota.NewRepository(rdb), config.Server.Quotas)
+	quotaHandler := quota.NewHandler(quotaService)
+
+	migrationHandler := migration.NewHandler(migration.NewService(db, tenant.DBSchema))
+
+	messageService := concurrent.SetupMessageService(db, rdb, mc, timelineKeeper, client, policy, conconf)
+	messageHandler := message.NewHandler(messageService)
+
+	associationService := concurrent.SetupAssociationService(db, rdb, mc, timelineKeeper, client, policy, conconf)
+	associationHandler := association.NewHandler(associationService)
+
+	profileService := concurrent.SetupProfileService(db, rdb, mc, client, policy, conconf)
+	profileHandler := profile.NewHandler(profileService)
+
+	timelineService := concurrent.SetupTimelineService(db, rdb, mc, timelineKeeper, client, policy, conconf)
+	domainHandler := domain.NewHandler(domainService, timelineService)
+	// timelines of the domain are moved to the new key once the domain runs with it
+	migrated, err := timelineService.MigrateDomainTimelines(context.Background())
+	if err != nil {
+		slog.Error("failed to move the timelines of the domain to its new key", slog.String("error", err.Error()))
+	} else if migrated > 0 {
+		slog.Info("moved the timelines of the domain to its new key", slog.Int("count", migrated))
+	}
+	firehose, err := timelineService.EnsureFirehose(context.Background())
+	if err != nil {
+		slog.Error("failed to set up the firehose timeline", slog.String("error", err.Error()))
+	} else {
+		slog.Info("firehose timeline is ready", slog.String("id", firehose.ID+"@"+conconf.FQDN))
+	}
+	timelineHandler := timeline.NewHandler(timelineService, associationService, timeline.RealtimeConfig{
+		QueueSize:           config.Server.RealtimeQueueSize,
+		OverflowPolicy:      config.Server.RealtimeOverflowPolicy,
+		Sessions:            timeline.NewSessionStore(rdb, config.Server.RealtimeResumeTTL, config.Server.RealtimeReplaySize),
+		ReadRecheckInterval: config.Server.RealtimeReadRecheck,
+	})
+
+	entityService := concurrent.SetupEntityService(db, rdb, mc, client, policy, conconf)
+	entityHandler := entity.NewHandler(entityService, entity.WebFingerConfig{
+		FQDN:     conconf.FQDN,
+		ActorURL: config.Server.WebFingerActorURL,
+	})
+
+	authService := concurrent.SetupAuthService(db, rdb, mc, client, policy, conconf)
+	authHandler := auth.NewHandler(authService)
+
+	keyService := concurrent.SetupKeyService(db, rdb, mc, client, conconf)
+
+	ackService := concurrent.SetupAckService(db, rdb, mc, timelineKeeper, client, policy, conconf)
+	ackHandler := ack.NewHandler(ackService)
+
+	storeService := concurrent.SetupStoreService(db, rdb, mc, timelineKeeper, client, policy, conconf, tenant.RepositoryPath, schema.NewValidator(config.Server.SchemaValidation))
+	storeService.SetLimits(config.Server.Limits)
+	storeService.SetDurability(config.Server.Durability)
+	keyHandler := key.NewHandler(keyService, storeService)
+	storeHandler := store.NewHandler(storeService)
+
+	spamRepository := spam.NewRepository(db)
+	spamService := spam.NewService(spamRepository, storeService, spam.NewDetectors(spamRepository, config.Server.Spam), config.Server.Spam)
+	spamHandler := spam.NewHandler(spamService)
+	if config.Server.Spam.Enabled {
+		storeService.AddHook(spamService)
+	}
+
+	subscriptionService := concurrent.SetupSubscriptionService(db, rdb, mc, client, policy, conconf)
+	subscriptionHandler := subscription.NewHandler(subscriptionService)
+
+	jobService := concurrent.SetupJobService(db)
+	jobHandler := job.NewHandler(jobService)
+	exportService := export.NewService(export.NewRepository(db), jobService, entityService, storeService, timelineService, tenant.RepositoryPath, config.Server.Snapshot)
+	exportHandler := export.NewHandler(exportService)
+
+	deletionService := deletion.NewService(deletion.NewRepository(db), entityService, domainService, client, conconf, deletion.Modules{
+		Messages:      messageService,
+		Associations:  associationService,
+		Profiles:      profileService,
+		Timelines:     timelineService,
+		Subscriptions: subscriptionService,
+		SemanticIDs:   concurrent.SetupSemanticidService(db),
+		UserKV:        userKvService,
+		Media:         exportService,
+		Keys:          keyService,
+		Entity:        entityService,
+	})
+	deletionHandler := deletion.NewHandler(deletionService)
+	storeService.AddListener(deletionService)
+
+	timelineBackfiller := timeline.NewBackfiller(rdb, timelineService, entityService, conconf, config.Server.TimelineBackfill)
+	storeService.AddListener(timelineBackfiller)
+
+	jobScheduler := scheduler.NewScheduler(rdb)
+	schedulerHandler := scheduler.NewHandler(jobScheduler)
+
+	err = jobScheduler.Register(scheduler.Job{
+		Name: "entity.expireTags",
+		Spec: "@every 1m",
+		Run:  entityService.ExpireTags,
+	})
+	if err != nil {
+		slog.Error("failed to register entity tag expiry", slog.String("error", err.Error()))
+	}
+
+	// ephemeral messages are removed from the database, the timelines and the repository logs once they expire
+	err = jobScheduler.Register(scheduler.Job{
+		Name: "store.pruneExpired",
+		Spec: "@every 1m",
+		Run:  storeService.PruneExpired,
+	})
+	if err != nil {
+		slog.Error("failed to register expired message pruning", slog.String("error", err.Error()))
+	}
+
+	// the counts are maintained along with the associations. this fixes the ones changed behind the repository's back
+	err = jobScheduler.Register(scheduler.Job{
+		Name:    "association.reconcileCounts",
+		Spec:    "@daily",
+		Timeout: 30 * time.Minute,
+		Run:     associationService.ReconcileCounts,
+	})
+	if err != nil {
+		slog.Error("failed to register association count reconciliation", slog.String("error", err.Error()))
+	}
+
+	// iterators replaced by writes never expire, and the ones of deleted timelines are never read again
+	cacheSweeper := timeline.NewSweeper(db, mc, scanner, conconf)
+	err = jobScheduler.Register(scheduler.Job{
+		Name:    "timeline.sweepCache",
+		Spec:    "@daily",
+		Timeout: time.Hour,
+		Run:     cacheSweeper.Sweep,
+	})
+	if err != nil {
+		slog.Error("failed to register timeline cache sweep", slog.String("error", err.Error()))
+	}
+
+	// read states are written to redis first and flushed to postgres periodically
+	err = jobScheduler.Register(scheduler.Job{
+		Name: "readstate.flush",
+		Spec: "@every 1m",
+		Run:  readstateService.Flush,
+	})
+	if err != nil {
+		slog.Error("failed to register readstate flush", slog.String("error", err.Error()))
+	}
+
+	err = jobScheduler.Register(scheduler.Job{
+		Name: "userkv.sweep",
+		Spec: "@hourly",
+		Run:  userKvService.Sweep,
+	})
+	if err != nil {
+		slog.Error("failed to register userkv sweep", slog.String("error", err.Error()))
+	}
+
+	// deletions interrupted by a crash or a failing module are picked up again
+	err = jobScheduler.Register(scheduler.Job{
+		Name: "deletion.resume",
+		Spec: "@every 5m",
+		Run:  deletionService.Resume,
+	})
+	if err != nil {
+		slog.Error("failed to register deletion resume", slog.String("error", err.Error()))
+	}
+
+	trendService := trend.NewService(trend.NewRepository(db, rdb))
+	trendHandler := trend.NewHandler(trendService)
+	err = jobScheduler.Register(scheduler.Job{
+		Name: "trend.aggregate",
+		Spec: "@every 1m",
+		Run:  trendService.Run,
+	})
+	if err != nil {
+		slog.Error("failed to register trend aggregation", slog.String("error", err.Error()))
+	}
+
+	reverifyService := reverify.NewService(reverify.NewRepository(db), jobService, keyService, timelineService)
+	reverifyHandler := reverify.NewHandler(reverifyService)
+
+	jobReactor := job.NewReactor(storeService, jobService, timelineService, exportService, reverifyService, jobScheduler, job.ReactorConfig{
+		ChunkWarmupCount:  config.Server.ChunkWarmupCount,
+		ChunkWarmupOffset: time.Duration(config.Server.ChunkWarmupOffset) * time.Second,
+	})
+
+	webpushOpts := webpush.Options{
+		Subscriber:      "webmaster@" + tenant.Concrnt.FQDN,
+		VAPIDPublicKey:  config.Server.VapidPublicKey,
+		VAPIDPrivateKey: config.Server.VapidPrivateKey,
+		TTL:             60,
+	}
+
+	notificationService := concurrent.SetupNotificationService(db)
+	notificationHandler := notification.NewHandler(notificationService)
+	notificationReactor := notification.NewReactor(notificationService, timelineService, webpushOpts, notification.ReactorConfig{
+		Triggers:  config.Server.NotificationTriggers,
+		RateLimit: config.Server.NotificationRateLimit,
+	})
+
+	scoreRules, err := score.NewRules(config.Server.EntityScoreRules)
+	if err != nil {
+		slog.Error("invalid entity score rules. scoring is disabled", slog.String("error", err.Error()))
+		scoreRules = nil
+	}
+	scoreService := score.NewService(score.NewRepository(db), entityService, scoreRules, conconf)
+	scoreHandler := score.NewHandler(scoreService)
+	if len(scoreRules) > 0 {
+		err := jobScheduler.Register(scheduler.Job{
+			Name:    "entity.score",
+			Spec:    "@hourly",
+			Timeout: 30 * time.Minute,
+			Run:     scoreService.Run,
+		})
+		if err != nil {
+			slog.Error("failed to register entity scoring", slog.String("error", err.Error()))
+		}
+	}
+
+	recommendationService := recommendation.NewService(recommendation.NewRepository(db, conconf))
+	recommendationHandler := recommendation.NewHandler(recommendationService)
+	err = jobScheduler.Register(scheduler.Job{
+		Name:    "recommendation.compute",
+		Spec:    "@every 6h",
+		Timeout: 30 * time.Minute,
+		Run:     recommendationService.Run,
+	})
+	if err != nil {
+		slog.Error("failed to register recommendation job", slog.String("error", err.Error()))
+	}
+
+	entityRefreshTTL := config.Server.EntityRefreshTTL
+	if entityRefreshTTL <= 0 {
+		entityRefreshTTL = 24 * time.Hour
+	}
+	err = jobScheduler.Register(scheduler.Job{
+		Name:    "entity.refresh",
+		Spec:    "@every 10m",
+		Timeout: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := entityService.RefreshStale(ctx, entityRefreshTTL)
+			return err
+		},
+	})
+	if err != nil {
+		slog.Error("failed to register entity refresh", slog.String("error", err.Error()))
+	}
+
+	activitypubService := activitypub.NewService(activitypub.NewRepository(db, rdb), entityService, messageService, timelineService, activitypub.Config{
+		FQDN:            conconf.FQDN,
+		DeliveryWorkers: config.Server.ActivityPubWorkers,
+	})
+	activitypubHandler := activitypub.NewHandler(activitypubService)
+	var activitypubReactor activitypub.Reactor
+	if config.Server.ActivityPub {
+		activitypubReactor = activitypub.NewReactor(activitypubService, timelineService)
+	}
+
+	mastodonHandler := mastodon.NewHandler(mastodon.NewService(entityService, messageService, profileService, timelineService, mastodon.Config{
+		FQDN:         conconf.FQDN,
+		Title:        tenant.Profile.Nickname,
+		Description:  tenant.Profile.Description,
+		Email:        tenant.Profile.MaintainerEmail,
+		Version:      version,
+		Registration: tenant.Concrnt.Registration == "open",
+	}))
+
+	relayService := relay.NewService(relay.NewRepository(db, rdb), domainService, entityService, keyService, timelineService, client, conconf, config.Server.Relay)
+	relayHandler := relay.NewHandler(relayService)
+	relayReactor := relay.NewReactor(relayService, timelineService, conconf, config.Server.Relay)
+
+	statsService := stats.NewService(
+		stats.NewRepository(db),
+		map[string]stats.Counter{
+			"entity":      entityService,
+			"message":     messageService,
+			"profile":     profileService,
+			"association": associationService,
+			"timeline":    timelineService,
+		},
+		timelineService,
+		domainService,
+		map[string]stats.QueueProbe{
+			"activitypub.delivery": activitypubService.DeliveryBacklog,
+			"activitypub.retry":    activitypubService.RetryBacklog,
+			"readstate.flush":      readstateService.Pending,
+		},
+	)
+	statsHandler := stats.NewHandler(statsService)
+
+	inviteHandler := invite.NewHandler(invite.NewService(invite.NewRepository(db, conconf)))
+
+	schemaHandler := schema.NewHandler(schema.NewRegistry(schema.NewRepository(db)))
+
+	// anonymous reads are shed first while the database or redis of the domain respond slowly
+	loadShed := config.Server.LoadShed.WithDefaults()
+	shedder := loadshed.New(
+		conconf.FQDN,
+		loadShed,
+		loadshed.Probe{Name: "db", Threshold: loadShed.DBLatency, Ping: sqlDB.PingContext},
+		loadshed.Probe{Name: "redis", Threshold: loadShed.RedisLatency, Ping: func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		}},
+	)
+
+	apiV1 := router.Group("", auth.ReceiveGatewayAuthPropagation, auth.ScopeGuard, logging.Middleware, shedder.Middleware)
+	// store
+	auth.AllowScoped(apiV1.POST("/commit", storeHandler.Commit))
+	apiV1.POST("/commit/associations", storeHandler.CommitAssociations, auth.Restrict(auth.ISLOCAL))
+
+	// domain
+	apiV1.GET("/domain", func(c echo.Context) error {
+		meta := tenant.Profile
+		meta.Registration = tenant.Concrnt.Registration
+		meta.Version = version
+		meta.BuildInfo = BuildInfo{
+			BuildTime:    buildTime,
+			BuildMachine: buildMachine,
+			GoVersion:    goVersion,
+		}
+		meta.SiteKey = config.Server.CaptchaSitekey
+		meta.CaptchaProvider = config.Server.CaptchaProvider
+		if meta.CaptchaProvider == "" && meta.SiteKey != "" {
+			meta.CaptchaProvider = "recaptcha"
+		}
+		meta.VapidKey = config.Server.VapidPublicKey
+
+		self := core.Domain{
+			ID:        conconf.FQDN,
+			CCID:      conconf.CCID,
+			CSID:      conconf.CSID,
+			Dimension: conconf.Dimension,
+			Meta:      meta,
+		}
+		// peers keep accepting documents of the previous CSID during the overlap of a rotation
+		if conconf.PreviousCSID != "" && time.Now().Before(conconf.PreviousCSIDUntil) {
+			self.PreviousCSID = conconf.PreviousCSID
+			self.PreviousCSIDUntil = conconf.PreviousCSIDUntil
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": self})
+	})
+	apiV1.GET("/domain/keys", domainHandler.Keys, auth.Restrict(auth.ISADMIN))
+	apiV1.POST("/domain/keys/announce", domainHandler.Announce, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/domain/:id", domainHandler.Get)
+	apiV1.GET("/domains", domainHandler.List)
+	apiV1.POST("/domains/hello", domainHandler.Hello)
+	apiV1.GET("/domains/federation", domainHandler.Federation, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/domains/peers", domainHandler.Peers, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/domains/peers/:fqdn", domainHandler.Peer, auth.Restrict(auth.ISADMIN))
+	apiV1.PUT("/domains/peers/:fqdn/settings", domainHandler.UpdateSettings, auth.Restrict(auth.ISADMIN))
+
+	// entity
+	apiV1.GET("/entity", entityHandler.GetSelf, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/entity/alias", entityHandler.GetAlias, auth.Restrict(auth.ISLOCAL))
+	apiV1.PUT("/entity/alias", entityHandler.PutAlias, auth.Restrict(auth.ISLOCAL))
+	apiV1.DELETE("/entity/alias", entityHandler.DeleteAlias, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/entity/:id", entityHandler.Get, auth.RequireScope("entity.read"))
+	apiV1.GET("/entity/:id/acking", ackHandler.GetAcking, auth.RequireScope("entity.read"))
+	apiV1.GET("/entity/:id/acker", ackHandler.GetAcker, auth.RequireScope("entity.read"))
+	apiV1.GET("/entities", entityHandler.List, auth.RequireScope("entity.read"))
+	apiV1.GET("/entities/tags", entityHandler.GetTagTaxonomy)
+	apiV1.GET("/entity/:id/tags", entityHandler.ListTags, auth.Restrict(auth.ISADMIN))
+	apiV1.PUT("/entity/:id/tags/:name", entityHandler.PutTag, auth.Restrict(auth.ISADMIN))
+	apiV1.DELETE("/entity/:id/tags/:name", entityHandler.DeleteTag, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/entity/:id/badges", entityHandler.ListBadges, auth.RequireScope("entity.read"))
+	apiV1.PUT("/entity/:id/badges/:name", entityHandler.PutBadge, auth.Restrict(auth.ISADMIN))
+	apiV1.DELETE("/entity/:id/badges/:name", entityHandler.DeleteBadge, auth.Restrict(auth.ISADMIN))
+	apiV1.POST("/entity/:id/refresh", entityHandler.Refresh, auth.Restrict(auth.ISADMIN))
+	apiV1.PUT("/entity/:id/score", scoreHandler.Override, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/entity/:id/score/history", scoreHandler.History, auth.Restrict(auth.ISADMIN))
+
+	// spam
+	apiV1.GET("/spam/quarantine", spamHandler.List, auth.Restrict(auth.ISADMIN))
+	apiV1.POST("/spam/quarantine/:id/release", spamHandler.Release, auth.Restrict(auth.ISADMIN))
+	apiV1.DELETE("/spam/quarantine/:id", spamHandler.Reject, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/deletions", deletionHandler.List, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/deletions/:id", deletionHandler.Get, auth.Restrict(auth.ISADMIN))
+	apiV1.POST("/deletions/:id/retry", deletionHandler.Retry, auth.Restrict(auth.ISADMIN))
+
+	// relay
+	apiV1.POST("/relay/events", relayHandler.Receive)
+	apiV1.GET("/relay/peers", relayHandler.ListPeers, auth.Restrict(auth.ISADMIN))
+	apiV1.PUT("/relay/peers/:domain", relayHandler.AddPeer, auth.Restrict(auth.ISADMIN))
+	apiV1.DELETE("/relay/peers/:domain", relayHandler.RemovePeer, auth.Restrict(auth.ISADMIN))
+
+	// message
+	apiV1.GET("/message/:id", messageHandler.Get, auth.RequireScope("message.read"))
+	apiV1.GET("/message/:id/receipts", messageHandler.GetReceipts, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/message/:id/associations", associationHandler.GetFiltered, auth.RequireScope("message.read"))
+	apiV1.GET("/message/:id/associationcounts", associationHandler.GetCounts, auth.RequireScope("message.read"))
+	apiV1.GET("/message/:id/associations/mine", associationHandler.GetOwnByTarget, auth.Restrict(auth.ISKNOWN), auth.RequireScope("message.read"))
+	apiV1.GET("/message/:id/reposters", associationHandler.GetReposters, auth.RequireScope("message.read"))
+	apiV1.GET("/message/:id/thread", associationHandler.GetThread, auth.RequireScope("message.read"))
+	apiV1.GET("/message/:id/thread/timeline", associationHandler.GetThreadTimeline, auth.RequireScope("message.read"))
+
+	// association
+	apiV1.GET("/association/:id", associationHandler.Get, auth.RequireScope("association.read"))
+
+	// profile
+	apiV1.GET("/profile/:id", profileHandler.Get, auth.RequireScope("profile.read"))
+	apiV1.GET("/profile/:owner/:semanticid", profileHandler.GetBySemanticID, auth.RequireScope("profile.read"))
+	apiV1.GET("/profiles", profileHandler.Query, auth.RequireScope("profile.read"))
+	apiV1.GET("/profile/:id/associations", associationHandler.GetAttached, auth.RequireScope("profile.read"))
+
+	// timeline
+	apiV1.GET("/timeline/:id", timelineHandler.Get, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timeline/:id/query", timelineHandler.Query, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timeline/:id/associations", associationHandler.GetAttached, auth.RequireScope("timeline.read"))
+	apiV1.POST("/timeline/:id/merge", timelineHandler.Merge, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/timelines", timelineHandler.List, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/mine", timelineHandler.ListMine, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/discover", timelineHandler.Discover, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/recent", timelineHandler.Recent, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/range", timelineHandler.Range, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/chunks", timelineHandler.GetChunks, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/retracted", timelineHandler.Retracted, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/realtime", timelineHandler.Realtime, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/sse", timelineHandler.RealtimeSSE, auth.RequireScope("timeline.read"))
+	apiV1.GET("/timelines/bridges", timelineHandler.Bridges, auth.Restrict(auth.ISADMIN))
+
+	// chunk
+	apiV1.GET("/chunks/itr", timelineHandler.GetChunkItr, auth.RequireScope("timeline.read"))
+	apiV1.GET("/chunks/body", timelineHandler.GetChunkBody, auth.RequireScope("timeline.read"))
+
+	// userkv
+	apiV1.GET("/kv/:key", userkvHandler.Get, auth.Restrict(auth.ISREGISTERED))
+	apiV1.PUT("/kv/:key", userkvHandler.Upsert, auth.Restrict(auth.ISREGISTERED))
+	apiV1.DELETE("/kv/:key", userkvHandler.Delete, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/kvs", userkvHandler.List, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/kv/:namespace/:key", userkvHandler.Get, auth.Restrict(auth.ISREGISTERED))
+	apiV1.PUT("/kv/:namespace/:key", userkvHandler.Upsert, auth.Restrict(auth.ISREGISTERED))
+	apiV1.DELETE("/kv/:namespace/:key", userkvHandler.Delete, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/kvs/:namespace", userkvHandler.List, auth.Restrict(auth.ISREGISTERED))
+
+	// mute
+	apiV1.GET("/mutes", muteHandler.List, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/mutes", muteHandler.Create, auth.Restrict(auth.ISREGISTERED))
+	apiV1.DELETE("/mute/:id", muteHandler.Delete, auth.Restrict(auth.ISREGISTERED))
+
+	// readstate
+	apiV1.GET("/readstates", readstateHandler.List, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/readstates", readstateHandler.Merge, auth.Restrict(auth.ISREGISTERED))
+	apiV1.PUT("/readstate/:target", readstateHandler.Set, auth.Restrict(auth.ISREGISTERED))
+
+	// auth
+	apiV1.GET("/auth/passport", authHandler.GetPassport, auth.Restrict(auth.ISLOCAL))
+	apiV1.POST("/auth/delegation", authHandler.IssueDelegation, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/auth/tokens", authHandler.ListTokens, auth.Restrict(auth.ISLOCAL))
+	apiV1.POST("/auth/tokens", authHandler.IssueToken, auth.Restrict(auth.ISLOCAL))
+	apiV1.DELETE("/auth/token/:id", authHandler.RevokeToken, auth.Restrict(auth.ISLOCAL))
+
+	// key
+	apiV1.GET("/key/:id", keyHandler.GetKeyResolution)
+	apiV1.GET("/keys/mine", keyHandler.GetKeyMine, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/keys/sessions", keyHandler.ListSessions, auth.Restrict(auth.ISLOCAL))
+	apiV1.DELETE("/key/:id", keyHandler.Revoke, auth.Restrict(auth.ISLOCAL))
+	apiV1.POST("/key/:id/reverify", reverifyHandler.Request, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/key/:id/invalid", reverifyHandler.ListInvalid, auth.Restrict(auth.ISADMIN))
+
+	// subscription
+	apiV1.GET("/subscription/:id", subscriptionHandler.GetSubscription)
+	apiV1.GET("/subscription/:id/associations", associationHandler.GetAttached)
+	apiV1.GET("/subscriptions/mine", subscriptionHandler.GetOwnSubscriptions, auth.Restrict(auth.ISLOCAL))
+
+	// storage
+	apiV1.GET("/repository", storeHandler.Get, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/repository", storeHandler.Post, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/repositories/sync", storeHandler.GetSyncStatus, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/repositories/sync", storeHandler.PerformSync, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/repositories/compact", storeHandler.Compact, auth.Restrict(auth.ISREGISTERED))
+
+	// job
+	apiV1.GET("/jobs", jobHandler.List, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/jobs", jobHandler.Create, auth.Restrict(auth.ISREGISTERED))
+	apiV1.DELETE("/job/:id", jobHandler.Cancel, auth.Restrict(auth.ISREGISTERED))
+
+	// export
+	apiV1.POST("/export", exportHandler.Request, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/export/:id", exportHandler.Status, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/export/:id/download", exportHandler.Download, auth.Restrict(auth.ISREGISTERED))
+	apiV1.POST("/timeline/:id/snapshot", exportHandler.RequestSnapshot, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/snapshot/:id", exportHandler.SnapshotStatus, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/snapshot/:id/download", exportHandler.DownloadSnapshot, auth.Restrict(auth.ISLOCAL))
+
+	// scheduler
+	apiV1.GET("/scheduler/jobs", schedulerHandler.List, auth.Restrict(auth.ISADMIN))
+
+	// stats
+	apiV1.GET("/admin/stats", statsHandler.Get, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/admin/migrations", migrationHandler.Status, auth.Restrict(auth.ISADMIN))
+	apiV1.GET("/admin/invites", inviteHandler.Tree, auth.Restrict(auth.ISADMIN))
+
+	// quota
+	apiV1.GET("/quota", quotaHandler.Get, auth.Restrict(auth.ISREGISTERED))
+
+	// trend
+	apiV1.GET("/trends", trendHandler.Get)
+
+	// recommendation
+	apiV1.GET("/recommendations", recommendationHandler.List, auth.Restrict(auth.ISLOCAL))
+	apiV1.GET("/recommendations/optout", recommendationHandler.GetOptOut, auth.Restrict(auth.ISLOCAL))
+	apiV1.PUT("/recommendations/optout", recommendationHandler.OptOut, auth.Restrict(auth.ISLOCAL))
+	apiV1.DELETE("/recommendations/optout", recommendationHandler.OptIn, auth.Restrict(auth.ISLOCAL))
+
+	// schema
+	apiV1.GET("/schemas", schemaHandler.List)
+	apiV1.GET("/schema/:id", schemaHandler.Get)
+	apiV1.PUT("/schema/:id/deprecation", schemaHandler.Deprecate, auth.Restrict(auth.ISADMIN))
+	apiV1.DELETE("/schema/:id/deprecation", schemaHandler.Undeprecate, auth.Restrict(auth.ISADMIN))
+
+	// notification
+	apiV1.POST("/notification", notificationHandler.Subscribe, auth.Restrict(auth.ISREGISTERED))
+	apiV1.DELETE("/notification/:owner/:vendor_id", notificationHandler.Delete, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/notification/:owner/:vendor_id", notificationHandler.Get, auth.Restrict(auth.ISREGISTERED))
+	apiV1.GET("/notification/preference", notificationHandler.GetPreference, auth.Restrict(auth.ISREGISTERED))
+	apiV1.PUT("/notification/preference", notificationHandler.UpdatePreference, auth.Restrict(auth.ISREGISTERED))
+
+	// activitypub
+	if config.Server.ActivityPub {
+		apiV1.GET("/ap/actor/:ccid", activitypubHandler.GetActor)
+		apiV1.GET("/ap/actor/:ccid/followers", activitypubHandler.GetFollowers)
+		apiV1.POST("/ap/actor/:ccid/inbox", activitypubHandler.Inbox)
+		apiV1.GET("/ap/note/:id", activitypubHandler.GetNote)
+		apiV1.GET("/ap/timelines", activitypubHandler.ListTimelines, auth.Restrict(auth.ISLOCAL))
+		apiV1.PUT("/ap/timeline/:id", activitypubHandler.EnableTimeline, auth.Restrict(auth.ISLOCAL))
+		apiV1.DELETE("/ap/timeline/:id", activitypubHandler.DisableTimeline, auth.Restrict(auth.ISLOCAL))
+		apiV1.GET("/ap/followers", activitypubHandler.ListFollowers, auth.Restrict(auth.ISLOCAL))
+	}
+
+	// read-only Mastodon client api
+	if config.Server.Mastodon {
+		apiV1.GET("/instance", mastodonHandler.Instance)
+		apiV1.GET("/timelines/public", mastodonHandler.PublicTimeline)
+		apiV1.GET("/accounts/lookup", mastodonHandler.LookupAccount)
+		apiV1.GET("/accounts/:id", mastodonHandler.GetAccount)
+		apiV1.GET("/accounts/:id/statuses", mastodonHandler.AccountStatuses)
+		apiV1.GET("/statuses/:id", mastodonHandler.GetStatus)
+		apiV1.GET("/statuses/:id/context", mastodonHandler.GetStatusContext)
+	}
+
+	// misc
+	openapiRegistry := openapi.NewRegistry("concrnt api", version, "https://"+conconf.FQDN+"/api/v1")
+	describeRoutes(openapiRegistry)
+	apiV1.GET("/openapi.json", openapiRegistry.Handler(e))
+
+	router.GET("/.well-known/webfinger", entityHandler.WebFinger)
+
+	checker := health.NewChecker(0)
+	checker.Register(health.Check{Name: "postgres", Critical: true, Probe: sqlDB.PingContext})
+	checker.Register(health.Check{Name: "redis", Critical: true, Probe: func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}})
+	// everything is still read from the database without the cache, only slower
+	checker.Register(health.Check{Name: "cache", Probe: func(ctx context.Context) error {
+		return cache.Ping(mc)
+	}})
+	// realtime events stop flowing to remote subscribers while the keeper isn't listening
+	checker.Register(health.Check{Name: "eventbus", Probe: func(ctx context.Context) error {
+		subs, err := rdb.PubSubNumSub(ctx, timeline.SubscriptionUpdatedChannel).Result()
+		if err != nil {
+			return err
+		}
+		if subs[timeline.SubscriptionUpdatedChannel] == 0 {
+			return fmt.Errorf("no keeper is listening")
+		}
+		return nil
+	}})
+	if config.Server.EnableTrace {
+		checker.Register(health.Check{Name: "otlp-traces", Probe: traceExports.Probe})
+	}
+	if config.Server.EnableMetrics {
+		checker.Register(health.Check{Name: "otlp-metrics", Probe: metricExports.Probe})
+	}
+
+	router.GET("/livez", health.Livez)
+	router.GET("/readyz", checker.Readyz)
+	router.GET("/healthz", checker.Readyz)
+
+	// every domain has its own redis database, so its keys are measured and budgeted on their own
+	keyspaceBudget := tenant.RedisKeyspaceBudget
+	if keyspaceBudget == nil {
+		keyspaceBudget = config.Server.RedisKeyspaceBudget
+	}
+	keyspaceMonitor := keyspace.NewMonitor(rdb, keyspace.Config{
+		Domain:    conconf.FQDN,
+		Budgets:   keyspaceBudget,
+		WarnRatio: config.Server.RedisMemoryWarnRatio,
+	})
+
+	return &tenantRuntime{
+		config:              conconf,
+		timelineKeeper:      timelineKeeper,
+		timelineBackfiller:  timelineBackfiller,
+		jobReactor:          jobReactor,
+		notificationReactor: notificationReactor,
+		activitypubReactor:  activitypubReactor,
+		relayReactor:        relayReactor,
+		scheduler:           jobScheduler,
+		shedder:             shedder,
+		keyspaceMonitor:     keyspaceMonitor,
+
+		entityService:      entityService,
+		messageService:     messageService,
+		profileService:     profileService,
+		associationService: associationService,
+		timelineService:    timelineService,
+		storeService:       storeService,
+		statsService:       statsService,
+	}
+}
+
+func (t *tenantRuntime) start(ctx context.Context) {
+	t.timelineKeeper.Start(ctx)
+	t.timelineBackfiller.Start(ctx)
+	t.jobReactor.Start(ctx)
+	t.notificationReactor.Start(ctx)
+	if t.activitypubReactor != nil {
+		t.activitypubReactor.Start(ctx)
+	}
+	t.relayReactor.Start(ctx)
+	t.scheduler.Start(ctx)
+	t.shedder.Start(ctx)
+	t.keyspaceMonitor.Start(ctx)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/internal/testutil"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	db, cleanup := testutil.CreateDB()
+	defer cleanup()
+	dsn := db.Dialector.(*postgres.Dialector).Config.DSN
+
+	tenantA, _, err := openDatabase(dsn, "tenant_a", database.Config{}, true, logger.Discard)
+	assert.NoError(t, err)
+	tenantB, _, err := openDatabase(dsn, "tenant_b", database.Config{}, true, logger.Discard)
+	assert.NoError(t, err)
+
+	owner := "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4"
+	commit := core.CommitLog{
+		DocumentID:   "TQ7ZQ3R1X1KQMN4N0676PETFAR",
+		Type:         "message",
+		Document:     "{}",
+		Signature:    "DUMMY",
+		CommitOwners: []core.CommitOwner{{Owner: owner}},
+	}
+	assert.NoError(t, tenantA.Create(&commit).Error)
+
+	// the joined table is named in raw sql, and has to resolve to the schema of the tenant as well
+	countCommits := func(tenant *gorm.DB) int64 {
+		var count int64
+		err := tenant.Model(&core.CommitLog{}).
+			Joins("JOIN commit_owners ON commit_owners.commit_log_id = commit_logs.id").
+			Where("commit_owners.owner = ?", owner).
+			Count(&count).Error
+		assert.NoError(t, err)
+		return count
+	}
+	assert.Equal(t, int64(1), countCommits(tenantA))
+	assert.Equal(t, int64(0), countCommits(tenantB))
+
+	var owners int64
+	assert.NoError(t, tenantB.Raw("SELECT count(*) FROM commit_owners").Scan(&owners).Error)
+	assert.Equal(t, int64(0), owners)
+}
@@ -0,0 +1,177 @@
+// fsck finds references between the modules which point to rows that do not exist anymore,
+// e.g. timeline items of deleted messages, and optionally removes them.
+//
+//	fsck [-config path] [-schema name] [-check name,...] [-repair] [-samples n]
+//
+// a JSON summary is printed per schema. the exit status is 1 when references are left dangling.
+// without -schema, the command runs on the default schema and every tenant schema in the config.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/integrity"
+)
+
+type Config struct {
+	Server struct {
+		Dsn           string          `yaml:"dsn"`
+		Database      database.Config `yaml:"database"`
+		MemcachedAddr string          `yaml:"memcachedAddr"`
+		RedisAddr     string          `yaml:"redisAddr"`
+		RedisDB       int             `yaml:"redisDB"`
+		Cache         cache.Config    `yaml:"cache"`
+	} `yaml:"server"`
+	Concrnt struct {
+		FQDN string `yaml:"fqdn"`
+	} `yaml:"concrnt"`
+	Tenants []struct {
+		Concrnt struct {
+			FQDN string `yaml:"fqdn"`
+		} `yaml:"concrnt"`
+		DBSchema      string `yaml:"dbSchema"`
+		MemcachedAddr string `yaml:"memcachedAddr"`
+	} `yaml:"tenants"`
+}
+
+// Summary is the line printed for a schema
+type Summary struct {
+	Schema string `json:"schema"`
+	FQDN   string `json:"fqdn"`
+	integrity.Report
+}
+
+type target struct {
+	schema        string
+	fqdn          string
+	memcachedAddr string
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONCRNT_CONFIG"), "path to config.yaml")
+	only := flag.String("schema", "", "run only on this tenant schema. \"default\" selects the default one")
+	checks := flag.String("check", "", "comma separated checks to run: "+strings.Join(integrity.Names(), ", "))
+	repair := flag.Bool("repair", false, "remove the rows holding dangling references")
+	samples := flag.Int("samples", integrity.DefaultSamples, "findings reported for each check")
+	flush := flag.Bool("flush-cache", true, "flush the cache after rows are removed")
+	flag.Parse()
+
+	if *configPath == "" {
+		*configPath = "/etc/concrnt/config/config.yaml"
+	}
+
+	var config Config
+	file, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatalf("failed to read config: %v", err)
+	}
+	err = yaml.Unmarshal(file, &config)
+	if err != nil {
+		log.Fatalf("failed to parse config: %v", err)
+	}
+
+	var selected []string
+	if *checks != "" {
+		selected = strings.Split(*checks, ",")
+	}
+
+	targets := []target{{"", config.Concrnt.FQDN, config.Server.MemcachedAddr}}
+	for _, tenant := range config.Tenants {
+		memcachedAddr := tenant.MemcachedAddr
+		if memcachedAddr == "" {
+			memcachedAddr = config.Server.MemcachedAddr
+		}
+		targets = append(targets, target{tenant.DBSchema, tenant.Concrnt.FQDN, memcachedAddr})
+	}
+
+	ctx := context.Background()
+	dangling := false
+	for _, t := range targets {
+		name := t.schema
+		if name == "" {
+			name = "default"
+		}
+		if *only != "" && *only != name {
+			continue
+		}
+
+		db, err := database.OpenSchema(config.Server.Dsn, config.Server.Database, &gorm.Config{
+			Logger:         logger.Default.LogMode(logger.Silent),
+			TranslateError: true,
+		}, t.schema)
+		if err != nil {
+			log.Fatalf("[%s] failed to connect database: %v", name, err)
+		}
+
+		report, err := integrity.Run(ctx, db, integrity.Options{
+			FQDN:    t.fqdn,
+			Checks:  selected,
+			Repair:  *repair,
+			Samples: *samples,
+		})
+		if err != nil {
+			log.Fatalf("[%s] %v", name, err)
+		}
+
+		b, _ := json.Marshal(Summary{Schema: name, FQDN: t.fqdn, Report: report})
+		fmt.Println(string(b))
+
+		if report.Found > report.Repaired {
+			dangling = true
+		}
+
+		// chunks and counts cached from the removed rows would still be served.
+		// the cache cannot drop the keys of one domain only, so the whole cache is dropped.
+		if *flush && report.Repaired > 0 {
+			err = flushCache(config.Server.Cache, t.memcachedAddr, config.Server.RedisAddr, config.Server.RedisDB)
+			if err != nil {
+				log.Fatalf("[%s] failed to flush cache: %v", name, err)
+			}
+		}
+	}
+
+	if dangling {
+		os.Exit(1)
+	}
+}
+
+// flushCache drops the cache the api serves from, including its replicas
+func flushCache(config cache.Config, memcachedAddr, redisAddr string, redisDB int) error {
+	var rdb *redis.Client
+	switch config.Backend {
+	case cache.BackendMemory:
+		fmt.Println("the cache is held by the api process, restart it to drop cached entries")
+		return nil
+	case cache.BackendRedis:
+		rdb = redis.NewClient(&redis.Options{
+			Addr: redisAddr,
+			DB:   redisDB,
+		})
+		defer rdb.Close()
+	default:
+		if memcachedAddr == "" {
+			return nil
+		}
+	}
+
+	mc, err := cache.New(config, memcachedAddr, rdb)
+	if err != nil {
+		return err
+	}
+	defer mc.Close()
+
+	return mc.FlushAll()
+}
@@ -3,8 +3,12 @@ package main
 import (
 	"github.com/go-yaml/yaml"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/quota"
 	"log"
 	"os"
+	"time"
 )
 
 // Config is Concurrent base configuration
@@ -15,15 +19,26 @@ type Config struct {
 }
 
 type Server struct {
-	Dsn            string `yaml:"dsn"`
-	RedisAddr      string `yaml:"redisAddr"`
-	RedisDB        int    `yaml:"redisDB"`
-	MemcachedAddr  string `yaml:"memcachedAddr"`
-	EnableTrace    bool   `yaml:"enableTrace"`
-	TraceEndpoint  string `yaml:"traceEndpoint"`
-	RepositoryPath string `yaml:"repositoryPath"`
-	CaptchaSitekey string `yaml:"captchaSitekey"`
-	CaptchaSecret  string `yaml:"captchaSecret"`
+	Dsn             string  `yaml:"dsn"`
+	RedisAddr       string  `yaml:"redisAddr"`
+	RedisDB         int     `yaml:"redisDB"`
+	MemcachedAddr   string  `yaml:"memcachedAddr"`
+	EnableTrace     bool    `yaml:"enableTrace"`
+	TraceEndpoint   string  `yaml:"traceEndpoint"`
+	RepositoryPath  string  `yaml:"repositoryPath"`
+	CaptchaProvider string  `yaml:"captchaProvider"` // recaptcha, recaptchav3, hcaptcha, turnstile
+	CaptchaSitekey  string  `yaml:"captchaSitekey"`
+	CaptchaSecret   string  `yaml:"captchaSecret"`
+	CaptchaMinScore float64 `yaml:"captchaMinScore"`
+	CaptchaAction   string  `yaml:"captchaAction"`
+
+	EnableMetrics   bool          `yaml:"enableMetrics"`
+	MetricsInterval time.Duration `yaml:"metricsInterval"`
+
+	Database database.Config `yaml:"database"`
+
+	Cache  cache.Config           `yaml:"cache"`
+	Quotas map[string]quota.Limit `yaml:"quotas"`
 }
 
 type BuildInfo struct {
@@ -15,29 +15,34 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"github.com/totegamma/concurrent"
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/internal/health"
 	"github.com/totegamma/concurrent/x/auth"
-
-	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/quota"
 
 	"github.com/redis/go-redis/extra/redisotel/v9"
-	"github.com/xinguang/go-recaptcha"
+	prometheusbridge "go.opentelemetry.io/contrib/bridges/prometheus"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
@@ -52,9 +57,16 @@ var (
 	goVersion    = "unknown"
 )
 
+// the outcome of the last export of the telemetry, reported by the readiness probes
+var (
+	traceExports  = &health.Recorder{}
+	metricExports = &health.Recorder{}
+)
+
 func main() {
 
 	e := echo.New()
+	e.HTTPErrorHandler = core.HTTPErrorHandler(e)
 
 	// Configファイルの読み込み
 	config := Config{}
@@ -97,7 +109,7 @@ func main() {
 
 		skipper := otelecho.WithSkipper(
 			func(c echo.Context) bool {
-				return c.Path() == "/metrics" || c.Path() == "/health"
+				return c.Path() == "/metrics" || health.IsProbe(c.Path())
 			},
 		)
 		e.Use(otelecho.Middleware(config.Concrnt.FQDN, skipper))
@@ -111,17 +123,31 @@ func main() {
 		})
 	}
 
+	if config.Server.EnableMetrics {
+		cleanup, err := setupMeterProvider(config.Server.TraceEndpoint, config.Concrnt.FQDN+"/ccgateway", version, config.Server.MetricsInterval)
+		if err != nil {
+			panic(err)
+		}
+		defer cleanup()
+	}
+
 	if config.Server.CaptchaSecret != "" {
-		validator, err := recaptcha.NewWithSecert(config.Server.CaptchaSecret)
+		provider, err := auth.NewCaptchaProvider(auth.CaptchaConfig{
+			Provider: config.Server.CaptchaProvider,
+			Secret:   config.Server.CaptchaSecret,
+			MinScore: config.Server.CaptchaMinScore,
+			Action:   config.Server.CaptchaAction,
+		})
 		if err != nil {
 			panic(err)
 		}
-		e.Use(auth.Recaptcha(validator))
+		log.Printf("Captcha provider: %s", provider.Name())
+		e.Use(auth.Captcha(provider))
 	}
 
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Skipper: func(c echo.Context) bool {
-			return c.Path() == "/metrics" || c.Path() == "/health"
+			return c.Path() == "/metrics" || health.IsProbe(c.Path())
 		},
 		Format: `{"time":"${time_rfc3339_nano}",${custom},"remote_ip":"${remote_ip}",` +
 			`"host":"${host}","method":"${method}","uri":"${uri}","status":${status},` +
@@ -153,7 +179,7 @@ func main() {
 			if c.Request().Header.Get("Upgrade") == "websocket" {
 				return true
 			}
-			return c.Path() == "/metrics" || c.Path() == "/health"
+			return c.Path() == "/metrics" || health.IsProbe(c.Path())
 		},
 	}))
 
@@ -168,7 +194,7 @@ func main() {
 	)
 
 	// Postrgresqlとの接続
-	db, err := gorm.Open(postgres.Open(config.Server.Dsn), &gorm.Config{
+	db, err := database.Open(config.Server.Dsn, config.Server.Database, &gorm.Config{
 		Logger:         gormLogger,
 		TranslateError: true,
 	})
@@ -180,6 +206,7 @@ func main() {
 		panic("failed to connect database")
 	}
 	defer sqlDB.Close()
+	prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, "concrnt"))
 
 	err = db.Use(tracing.NewPlugin(
 		tracing.WithDBName("postgres"),
@@ -207,7 +234,10 @@ func main() {
 		panic("failed to setup tracing plugin")
 	}
 
-	mc := memcache.New(config.Server.MemcachedAddr)
+	mc, err := cache.New(config.Server.Cache, config.Server.MemcachedAddr, rdb)
+	if err != nil {
+		panic(err)
+	}
 	defer mc.Close()
 
 	client := client.NewClient()
@@ -218,10 +248,13 @@ func main() {
 
 	e.Use(authService.IdentifyIdentity)
 
+	quotaService := quota.NewService(quota.NewRepository(rdb), config.Server.Quotas)
+	e.Use(quotaService.Middleware)
+
 	cors := middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:  []string{"*"},
-		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "passport"},
-		ExposeHeaders: []string{"trace-id"},
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "passport", core.RequestSignatureHeader, "If-None-Match", echo.HeaderIfModifiedSince},
+		ExposeHeaders: []string{"trace-id", "ETag", "Last-Modified"},
 	})
 
 	// プロキシ設定
@@ -317,6 +350,34 @@ func main() {
 				c.Request().Header.Set(core.RequesterIsRegisteredHeader, strconv.FormatBool(requesterIsRegistered))
 			}
 
+			requesterScopes, ok := ctx.Value(core.RequesterScopesKey).(core.Scopes)
+			if ok {
+				c.Request().Header.Set(core.RequesterScopesHeader, requesterScopes.ToString())
+			} else {
+				c.Request().Header.Del(core.RequesterScopesHeader)
+			}
+
+			requesterPassportScopes, ok := ctx.Value(core.RequesterPassportScopesKey).(core.Scopes)
+			if ok {
+				c.Request().Header.Set(core.RequesterPassportScopesHeader, requesterPassportScopes.ToString())
+			} else {
+				c.Request().Header.Del(core.RequesterPassportScopesHeader)
+			}
+
+			requesterVouchedId, ok := ctx.Value(core.RequesterVouchedIdCtxKey).(string)
+			if ok {
+				c.Request().Header.Set(core.RequesterVouchedIdHeader, requesterVouchedId)
+			} else {
+				c.Request().Header.Del(core.RequesterVouchedIdHeader)
+			}
+
+			requesterBadges, ok := ctx.Value(core.RequesterBadgesKey).([]string)
+			if ok && len(requesterBadges) > 0 {
+				c.Request().Header.Set(core.RequesterBadgesHeader, strings.Join(requesterBadges, ","))
+			} else {
+				c.Request().Header.Del(core.RequesterBadgesHeader)
+			}
+
 			proxy.ServeHTTP(c.Response(), c.Request())
 			return nil
 		}
@@ -377,21 +438,25 @@ func main() {
 		return c.File("/etc/concrnt/static/register-template.json")
 	}, cors)
 
-	e.GET("/health", func(c echo.Context) (err error) {
-		ctx := c.Request().Context()
-
-		err = sqlDB.Ping()
-		if err != nil {
-			return c.String(http.StatusInternalServerError, "db error")
-		}
-
-		err = rdb.Ping(ctx).Err()
-		if err != nil {
-			return c.String(http.StatusInternalServerError, "redis error")
-		}
+	checker := health.NewChecker(0)
+	checker.Register(health.Check{Name: "postgres", Critical: true, Probe: sqlDB.PingContext})
+	checker.Register(health.Check{Name: "redis", Critical: true, Probe: func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}})
+	// passports and rate limits are still served without the cache, only slower
+	checker.Register(health.Check{Name: "cache", Probe: func(ctx context.Context) error {
+		return cache.Ping(mc)
+	}})
+	if config.Server.EnableTrace {
+		checker.Register(health.Check{Name: "otlp-traces", Probe: traceExports.Probe})
+	}
+	if config.Server.EnableMetrics {
+		checker.Register(health.Check{Name: "otlp-metrics", Probe: metricExports.Probe})
+	}
 
-		return c.String(http.StatusOK, "ok")
-	})
+	e.GET("/livez", health.Livez)
+	e.GET("/readyz", checker.Readyz)
+	e.GET("/healthz", checker.Readyz)
 
 	e.GET("/metrics", echoprometheus.NewHandler())
 
@@ -421,7 +486,7 @@ func setupTraceProvider(endpoint string, serviceName string, serviceVersion stri
 	)
 
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithBatcher(health.SpanExporter(exporter, traceExports)),
 		sdktrace.WithSampler(sdktrace.AlwaysSample()),
 		sdktrace.WithResource(resource),
 	)
@@ -443,6 +508,51 @@ func setupTraceProvider(endpoint string, serviceName string, serviceVersion stri
 	return cleanup, nil
 }
 
+const defaultMetricsInterval = time.Minute
+
+// setupMeterProvider pushes the metrics of the prometheus registry to the OTLP endpoint,
+// for a stack without a prometheus scraper
+func setupMeterProvider(endpoint string, serviceName string, serviceVersion string, interval time.Duration) (func(), error) {
+
+	exporter, err := otlpmetrichttp.New(
+		context.Background(),
+		otlpmetrichttp.WithEndpoint(endpoint),
+		otlpmetrichttp.WithInsecure(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	resource := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(serviceVersion),
+	)
+
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+			health.MetricExporter(exporter, metricExports),
+			sdkmetric.WithInterval(interval),
+			sdkmetric.WithProducer(prometheusbridge.NewMetricProducer()),
+		)),
+		sdkmetric.WithResource(resource),
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	cleanup := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if err := meterProvider.Shutdown(ctx); err != nil {
+			log.Printf("Failed to shutdown meter provider: %v", err)
+		}
+	}
+	return cleanup, nil
+}
+
 func singleJoiningSlash(a, b string) string {
 	aslash := strings.HasSuffix(a, "/")
 	bslash := strings.HasPrefix(b, "/")
@@ -0,0 +1,130 @@
+// migrate applies the versioned database migrations of the api to the domain and its tenants.
+//
+//	migrate [-config path] [-schema name] up|down|status
+//
+// up applies all pending migrations, down reverts the latest one and status lists them.
+// without -schema, the command runs on the default schema and every tenant schema in the config.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/go-yaml/yaml"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/x/migration"
+)
+
+type Config struct {
+	Server struct {
+		Dsn      string          `yaml:"dsn"`
+		Database database.Config `yaml:"database"`
+	} `yaml:"server"`
+	Tenants []struct {
+		DBSchema string `yaml:"dbSchema"`
+	} `yaml:"tenants"`
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONCRNT_CONFIG"), "path to config.yaml")
+	only := flag.String("schema", "", "run only on this tenant schema. \"default\" selects the default one")
+	flag.Parse()
+
+	command := flag.Arg(0)
+	if command != "up" && command != "down" && command != "status" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *configPath == "" {
+		*configPath = "/etc/concrnt/config/config.yaml"
+	}
+
+	var config Config
+	file, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatalf("failed to read config: %v", err)
+	}
+	err = yaml.Unmarshal(file, &config)
+	if err != nil {
+		log.Fatalf("failed to parse config: %v", err)
+	}
+
+	schemas := []string{""}
+	for _, tenant := range config.Tenants {
+		schemas = append(schemas, tenant.DBSchema)
+	}
+
+	ctx := context.Background()
+	for _, schema := range schemas {
+		name := schema
+		if name == "" {
+			name = "default"
+		}
+		if *only != "" && *only != name {
+			continue
+		}
+
+		db, err := open(config.Server.Dsn, config.Server.Database, schema)
+		if err != nil {
+			log.Fatalf("[%s] failed to connect database: %v", name, err)
+		}
+		service := migration.NewService(db, schema)
+
+		switch command {
+		case "status":
+			status, err := service.Status(ctx)
+			if err != nil {
+				log.Fatalf("[%s] failed to get status: %v", name, err)
+			}
+			for _, s := range status {
+				state := "pending"
+				if s.Applied {
+					state = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("[%s] %04d_%s: %s\n", name, s.Version, s.Name, state)
+			}
+		case "up":
+			applied, err := service.Up(ctx)
+			for _, s := range applied {
+				fmt.Printf("[%s] applied %04d_%s\n", name, s.Version, s.Name)
+			}
+			if err != nil {
+				log.Fatalf("[%s] %v", name, err)
+			}
+			fmt.Printf("[%s] %d migrations applied\n", name, len(applied))
+		case "down":
+			reverted, err := service.Down(ctx)
+			if err != nil {
+				log.Fatalf("[%s] failed to revert: %v", name, err)
+			}
+			if reverted.Version == 0 {
+				fmt.Printf("[%s] nothing to revert\n", name)
+				continue
+			}
+			fmt.Printf("[%s] reverted %04d_%s\n", name, reverted.Version, reverted.Name)
+		}
+	}
+}
+
+// open connects to postgres the same way the api does, and creates the schema of a tenant not migrated yet
+func open(dsn string, dbConfig database.Config, schema string) (*gorm.DB, error) {
+	db, err := database.OpenSchema(dsn, dbConfig, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Warn),
+		TranslateError: true,
+	}, schema)
+	if err != nil {
+		return nil, err
+	}
+	err = database.CreateSchema(db, schema)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,163 @@
+// reindex rewrites schema urls stored in the database when schemas are moved to another host.
+//
+//	reindex -from https://old.example.com/schemas/ -to https://new.example.com/schemas/ [-schema name] [-dry-run]
+//
+// without -schema, the schemas of the default domain are rewritten.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/go-yaml/yaml"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/schema"
+)
+
+type Config struct {
+	Server struct {
+		Dsn           string          `yaml:"dsn"`
+		Database      database.Config `yaml:"database"`
+		MemcachedAddr string          `yaml:"memcachedAddr"`
+		RedisAddr     string          `yaml:"redisAddr"`
+		RedisDB       int             `yaml:"redisDB"`
+		Cache         cache.Config    `yaml:"cache"`
+	} `yaml:"server"`
+	Tenants []struct {
+		DBSchema      string `yaml:"dbSchema"`
+		MemcachedAddr string `yaml:"memcachedAddr"`
+	} `yaml:"tenants"`
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONCRNT_CONFIG"), "path to config.yaml")
+	from := flag.String("from", "", "current schema url prefix")
+	to := flag.String("to", "", "new schema url prefix")
+	only := flag.String("schema", "", "rewrite the schemas of the tenant of this schema instead of the default domain")
+	dryRun := flag.Bool("dry-run", false, "print changes without applying them")
+	flush := flag.Bool("flush-cache", true, "flush the cache after applying changes")
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *configPath == "" {
+		*configPath = "/etc/concrnt/config/config.yaml"
+	}
+
+	var config Config
+	file, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatalf("failed to read config: %v", err)
+	}
+	err = yaml.Unmarshal(file, &config)
+	if err != nil {
+		log.Fatalf("failed to parse config: %v", err)
+	}
+
+	memcachedAddr := config.Server.MemcachedAddr
+	if *only != "" {
+		found := false
+		for _, tenant := range config.Tenants {
+			if tenant.DBSchema == *only {
+				found = true
+				if tenant.MemcachedAddr != "" {
+					memcachedAddr = tenant.MemcachedAddr
+				}
+				break
+			}
+		}
+		if !found {
+			log.Fatalf("no tenant with schema %s", *only)
+		}
+	}
+
+	db, err := database.OpenSchema(config.Server.Dsn, config.Server.Database, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	}, *only)
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+
+	count, err := reindex(context.Background(), db, os.Stdout, *from, *to, *dryRun)
+	if err != nil {
+		log.Fatalf("reindex failed: %v", err)
+	}
+	if *dryRun {
+		return
+	}
+
+	// cached documents and counts may carry resolved schema urls.
+	// the cache cannot drop the keys of one domain only, so the whole cache is dropped.
+	if *flush && count > 0 {
+		err = flushCache(config.Server.Cache, memcachedAddr, config.Server.RedisAddr, config.Server.RedisDB)
+		if err != nil {
+			log.Fatalf("failed to flush cache: %v", err)
+		}
+	}
+}
+
+// flushCache drops the cache the api serves from, including its replicas
+func flushCache(config cache.Config, memcachedAddr, redisAddr string, redisDB int) error {
+	var rdb *redis.Client
+	switch config.Backend {
+	case cache.BackendMemory:
+		fmt.Println("the cache is held by the api process, restart it to drop cached documents")
+		return nil
+	case cache.BackendRedis:
+		rdb = redis.NewClient(&redis.Options{
+			Addr: redisAddr,
+			DB:   redisDB,
+		})
+		defer rdb.Close()
+	default:
+		if memcachedAddr == "" {
+			return nil
+		}
+	}
+
+	mc, err := cache.New(config, memcachedAddr, rdb)
+	if err != nil {
+		return err
+	}
+	defer mc.Close()
+
+	err = mc.FlushAll()
+	if err != nil {
+		return err
+	}
+	fmt.Println("cache flushed")
+	return nil
+}
+
+// reindex runs the migration and writes each rewritten schema as a json line, followed by a summary
+func reindex(ctx context.Context, db *gorm.DB, w io.Writer, from, to string, dryRun bool) (int, error) {
+	entries, err := schema.Reindex(ctx, db, from, to, dryRun)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		b, _ := json.Marshal(entry)
+		fmt.Fprintln(w, string(b))
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "dry run: %d schemas would be rewritten\n", len(entries))
+	} else {
+		fmt.Fprintf(w, "%d schemas rewritten\n", len(entries))
+	}
+	return len(entries), nil
+}
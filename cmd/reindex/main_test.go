@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/testutil"
+	"github.com/totegamma/concurrent/x/schema"
+)
+
+var ctx = context.Background()
+var db *gorm.DB
+
+func TestMain(m *testing.M) {
+	var cleanup func()
+	db, cleanup = testutil.CreateDB()
+	defer cleanup()
+
+	m.Run()
+}
+
+func TestReindex(t *testing.T) {
+	schemas := []core.Schema{
+		{ID: 1, URL: "https://old.example/schemas/note.json"},
+		{ID: 2, URL: "https://old.example/schemas/like.json"},
+		{ID: 3, URL: "https://new.example/schemas/like.json"},
+		{ID: 4, URL: "https://other.example/schemas/note.json"},
+	}
+	assert.NoError(t, db.Create(&schemas).Error)
+
+	messages := []core.Message{
+		{ID: "RWF1Y4A1QZP3ZP0V0676PETFAR", Author: "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4", SchemaID: 1, Document: "{}", Signature: "DUMMY"},
+		{ID: "RWF1Y4A1QZP3ZP0V1676PETFAR", Author: "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4", SchemaID: 2, Document: "{}", Signature: "DUMMY"},
+	}
+	assert.NoError(t, db.Create(&messages).Error)
+
+	// dry run reports the changes without applying them
+	var out bytes.Buffer
+	count, err := reindex(ctx, db, &out, "https://old.example/schemas/", "https://new.example/schemas/", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		var moved, merged schema.ReindexEntry
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &moved))
+		assert.Equal(t, uint(1), moved.ID)
+		assert.Equal(t, "https://new.example/schemas/note.json", moved.To)
+		assert.Nil(t, moved.MergeInto)
+
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &merged))
+		assert.Equal(t, uint(2), merged.ID)
+		if assert.NotNil(t, merged.MergeInto) {
+			assert.Equal(t, uint(3), *merged.MergeInto)
+		}
+		assert.Equal(t, int64(1), merged.Rows["messages"])
+
+		assert.Equal(t, "dry run: 2 schemas would be rewritten", lines[2])
+	}
+
+	var schema1 core.Schema
+	assert.NoError(t, db.First(&schema1, 1).Error)
+	assert.Equal(t, "https://old.example/schemas/note.json", schema1.URL)
+	var message core.Message
+	assert.NoError(t, db.First(&message, "id = ?", messages[1].ID).Error)
+	assert.Equal(t, uint(2), message.SchemaID)
+
+	// the url of a schema is rewritten in place, and references of a schema whose new url exists are merged into it
+	out.Reset()
+	count, err = reindex(ctx, db, &out, "https://old.example/schemas/", "https://new.example/schemas/", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.True(t, strings.HasSuffix(out.String(), "2 schemas rewritten\n"))
+
+	assert.NoError(t, db.First(&schema1, 1).Error)
+	assert.Equal(t, "https://new.example/schemas/note.json", schema1.URL)
+
+	assert.NoError(t, db.First(&message, "id = ?", messages[0].ID).Error)
+	assert.Equal(t, uint(1), message.SchemaID)
+	assert.NoError(t, db.First(&message, "id = ?", messages[1].ID).Error)
+	assert.Equal(t, uint(3), message.SchemaID)
+
+	var remaining int64
+	assert.NoError(t, db.Model(&core.Schema{}).Where("id = ?", 2).Count(&remaining).Error)
+	assert.Equal(t, int64(0), remaining)
+
+	var other core.Schema
+	assert.NoError(t, db.First(&other, 4).Error)
+	assert.Equal(t, "https://other.example/schemas/note.json", other.URL)
+}
@@ -0,0 +1,93 @@
+// rotatekey generates the next key of a domain and prints the values of the config applying it.
+// the key is only ever printed here, so that it never goes over the network.
+//
+//	rotatekey [-config path] [-schema name] [-overlap 168h]
+//
+// without -schema, the key of the default domain is rotated. the rotation is refused while the entity
+// of the domain owns resources, since they can't be moved to the entity of the new key. the timelines of
+// the domain are moved to the new CSID by the api when it starts with the new key.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/x/domain"
+)
+
+type Config struct {
+	Server struct {
+		Dsn      string          `yaml:"dsn"`
+		Database database.Config `yaml:"database"`
+	} `yaml:"server"`
+	Concrnt core.ConfigInput `yaml:"concrnt"`
+	Tenants []struct {
+		Concrnt  core.ConfigInput `yaml:"concrnt"`
+		DBSchema string           `yaml:"dbSchema"`
+	} `yaml:"tenants"`
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONCRNT_CONFIG"), "path to config.yaml")
+	only := flag.String("schema", "", "rotate the key of the tenant of this schema instead of the default domain")
+	overlap := flag.Duration("overlap", 0, "how long peers keep accepting the current CSID (default 168h)")
+	flag.Parse()
+
+	if *configPath == "" {
+		*configPath = "/etc/concrnt/config/config.yaml"
+	}
+
+	var config Config
+	file, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatalf("failed to read config: %v", err)
+	}
+	err = yaml.Unmarshal(file, &config)
+	if err != nil {
+		log.Fatalf("failed to parse config: %v", err)
+	}
+
+	input := config.Concrnt
+	if *only != "" {
+		found := false
+		for _, tenant := range config.Tenants {
+			if tenant.DBSchema == *only {
+				input, found = tenant.Concrnt, true
+				break
+			}
+		}
+		if !found {
+			log.Fatalf("no tenant with schema %s", *only)
+		}
+	}
+	conconf := core.SetupConfig(input)
+
+	db, err := database.OpenSchema(config.Server.Dsn, config.Server.Database, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	}, *only)
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+
+	rotation, err := domain.RotateKey(context.Background(), db, conconf, *overlap)
+	if err != nil {
+		log.Fatalf("failed to rotate key of %s: %v", conconf.FQDN, err)
+	}
+
+	fmt.Printf("# next key of %s: csid %s, ccid %s\n", conconf.FQDN, rotation.CSID, rotation.CCID)
+	fmt.Println("# replace these in the concrnt section of the config, restart and POST /api/v1/domain/keys/announce")
+	fmt.Printf("privatekey: %s\n", rotation.PrivateKey)
+	fmt.Printf("previousCSID: %s\n", rotation.PreviousCSID)
+	fmt.Printf("previousCSIDUntil: %s\n", rotation.PreviousCSIDUntil.UTC().Format(time.RFC3339))
+}
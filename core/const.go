@@ -1,27 +1,41 @@
 package core
 
 const (
-	RequesterTypeCtxKey      = "cc-requesterType"
-	RequesterIdCtxKey        = "cc-requesterId"
-	RequesterTagCtxKey       = "cc-requesterTag"
-	RequesterDomainCtxKey    = "cc-requesterDomain"
-	RequesterDomainTagsKey   = "cc-requesterDomainTags"
-	RequesterKeychainKey     = "cc-requesterKeychain"
-	RequesterPassportKey     = "cc-requesterPassport"
-	RequesterIsRegisteredKey = "cc-requesterIsRegistered"
-	CaptchaVerifiedKey       = "cc-captchaVerified"
+	RequesterTypeCtxKey        = "cc-requesterType"
+	RequesterIdCtxKey          = "cc-requesterId"
+	RequesterTagCtxKey         = "cc-requesterTag"
+	RequesterDomainCtxKey      = "cc-requesterDomain"
+	RequesterDomainTagsKey     = "cc-requesterDomainTags"
+	RequesterKeychainKey       = "cc-requesterKeychain"
+	RequesterPassportKey       = "cc-requesterPassport"
+	RequesterIsRegisteredKey   = "cc-requesterIsRegistered"
+	CaptchaVerifiedKey         = "cc-captchaVerified"
+	RequesterScopesKey         = "cc-requesterScopes"
+	RequesterPassportScopesKey = "cc-requesterPassportScopes"
+	SpamVerdictCtxKey          = "cc-spamVerdict"
+	// RequesterVouchedIdCtxKey is the entity a verified domain passport says the request is made for.
+	// it is not authenticated by the entity itself, so only reads of private timelines rely on it
+	RequesterVouchedIdCtxKey = "cc-requesterVouchedId"
+	// RequesterBadgesKey holds the names of the verified badges of the requester
+	RequesterBadgesKey = "cc-requesterBadges"
 )
 
 const (
-	RequesterTypeHeader         = "cc-requester-type"
-	RequesterIdHeader           = "cc-requester-ccid"
-	RequesterTagHeader          = "cc-requester-tag"
-	RequesterDomainHeader       = "cc-requester-domain"
-	RequesterDomainTagsHeader   = "cc-requester-domain-tags"
-	RequesterKeychainHeader     = "cc-requester-keychain"
-	RequesterPassportHeader     = "passport"
-	RequesterIsRegisteredHeader = "cc-requester-is-registered"
-	CaptchaVerifiedHeader       = "cc-captcha-verified"
+	RequesterTypeHeader           = "cc-requester-type"
+	RequesterIdHeader             = "cc-requester-ccid"
+	RequesterTagHeader            = "cc-requester-tag"
+	RequesterDomainHeader         = "cc-requester-domain"
+	RequesterDomainTagsHeader     = "cc-requester-domain-tags"
+	RequesterKeychainHeader       = "cc-requester-keychain"
+	RequesterPassportHeader       = "passport"
+	RequesterIsRegisteredHeader   = "cc-requester-is-registered"
+	CaptchaVerifiedHeader         = "cc-captcha-verified"
+	RequesterScopesHeader         = "cc-requester-scopes"
+	RequesterPassportScopesHeader = "cc-requester-passport-scopes"
+	DomainPassportHeader          = "cc-domain-passport"
+	RequesterVouchedIdHeader      = "cc-requester-vouched-ccid"
+	RequestSignatureHeader        = "x-concurrent-signature"
+	RequesterBadgesHeader         = "cc-requester-badges"
 )
 
 type CommitMode int
@@ -33,6 +47,13 @@ const (
 	CommitModeLocalOnlyExec
 )
 
+// statuses of a DeliveryReceipt
+const (
+	DeliveryPending  = "pending"
+	DeliveryAccepted = "accepted"
+	DeliveryRejected = "rejected"
+)
+
 type PolicyEvalResult int
 
 const (
@@ -65,3 +86,30 @@ func RequesterTypeString(t int) string {
 		return "Error"
 	}
 }
+
+const (
+	// AssociationVariantRepost marks an association as a repost of its target.
+	// repost associations are also posted into the reposter's home timeline.
+	AssociationVariantRepost = "repost"
+
+	// AssociationVariantReply marks an association as a reply to its target.
+	// associations of the reply schema are treated as replies regardless of the variant.
+	AssociationVariantReply = "reply"
+
+	// ReplyAssociationSchema is the schema of associations linking a message to its replies
+	ReplyAssociationSchema = "https://schema.concrnt.world/a/reply.json"
+
+	// RepostTimelineSemanticID is the semantic id of the timeline reposts are delivered to
+	RepostTimelineSemanticID = "world.concrnt.t-home"
+
+	// NotificationTimelineSemanticID is the semantic id of the timeline events addressed to its owner are delivered to
+	NotificationTimelineSemanticID = "world.concrnt.t-notify"
+
+	// FirehoseTimelineSemanticID is the semantic id of the domain owned timeline which public messages created on the domain are delivered to.
+	// it is addressed as "world.concrnt.t-local@<fqdn>"
+	FirehoseTimelineSemanticID = "world.concrnt.t-local"
+
+	// ThreadTimelineSemanticIDPrefix prefixes the id of the root message of a conversation to name the domain owned timeline
+	// public replies in it are delivered to. it is addressed as "world.concrnt.t-thread.<root message id>@<fqdn>"
+	ThreadTimelineSemanticIDPrefix = "world.concrnt.t-thread."
+)
@@ -124,5 +124,21 @@ func SetupConfig(base ConfigInput) Config {
 		Dimension:    base.Dimension,
 		CCID:         ccid,
 		CSID:         csid,
+
+		PreviousCSID:      base.PreviousCSID,
+		PreviousCSIDUntil: base.PreviousCSIDUntil,
+
+		OwnershipLimits: base.OwnershipLimits,
+		EntityTags:      base.EntityTags,
+		ThreadTimelines: base.ThreadTimelines,
+	}
+}
+
+// GeneratePrivateKey returns a new random private key, in the hex form of the config
+func GeneratePrivateKey() (string, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate key")
 	}
+	return hex.EncodeToString(crypto.FromECDSA(key)), nil
 }
@@ -8,6 +8,10 @@ import (
 type Schema struct {
 	ID  uint   `json:"id" gorm:"primaryKey;auto_increment"`
 	URL string `json:"url" gorm:"type:text"`
+
+	// a schema deprecated by an admin points to the url replacing it
+	ReplacedBy   *string    `json:"replacedBy,omitempty" gorm:"type:text"`
+	DeprecatedAt *time.Time `json:"deprecatedAt,omitempty" gorm:"type:timestamp with time zone"`
 }
 
 type Key struct {
@@ -22,6 +26,14 @@ type Key struct {
 	ValidUntil      time.Time `json:"validUntil" gorm:"type:timestamp with time zone"`
 }
 
+// KeyUsage is when a local subkey was last used to sign a request, kept apart from the key
+// so that it is never served with the key resolution
+type KeyUsage struct {
+	KeyID     string    `json:"keyID" gorm:"primaryKey;type:char(42)"`
+	LastUsed  time.Time `json:"lastUsed" gorm:"type:timestamp with time zone"`
+	UserAgent string    `json:"userAgent" gorm:"type:text"`
+}
+
 type SemanticID struct {
 	ID        string    `json:"id" gorm:"primaryKey;type:text"`
 	Owner     string    `json:"owner" gorm:"primaryKey;type:char(42)"`
@@ -29,7 +41,7 @@ type SemanticID struct {
 	Document  string    `json:"document" gorm:"type:json"`
 	Signature string    `json:"signature" gorm:"type:char(130)"`
 	CDate     time.Time `json:"cdate" gorm:"->;<-:create;autoCreateTime"`
-	MDate     time.Time `json:"mdate" gorm:"autoUpdateTime"`
+	MDate     time.Time `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 }
 
 // Association is one of a concurrent base object
@@ -49,6 +61,15 @@ type Association struct {
 	Timelines pq.StringArray `json:"timelines" gorm:"type:text[]"`
 }
 
+// AssociationCount is the number of associations to a target by schema and variant.
+// it is maintained in the same transaction as the associations, so that the counts are read without aggregation
+type AssociationCount struct {
+	Target   string `json:"target" gorm:"primaryKey;type:char(27)"`
+	SchemaID uint   `json:"-" gorm:"primaryKey"`
+	Variant  string `json:"variant" gorm:"primaryKey;type:text"`
+	Count    int64  `json:"count" gorm:"not null;default:0"`
+}
+
 // Profile is one of a Concurrent base object
 // mutable
 type Profile struct {
@@ -62,25 +83,54 @@ type Profile struct {
 	PolicyID     uint          `json:"-"`
 	Policy       string        `json:"policy,omitempty" gorm:"-"`
 	PolicyParams *string       `json:"policyParams,omitempty" gorm:"type:json"`
+	Version      int64         `json:"version" gorm:"not null;default:1"` // incremented by every update
 	CDate        time.Time     `json:"cdate" gorm:"->;<-:create;autoCreateTime"`
-	MDate        time.Time     `json:"mdate" gorm:"autoUpdateTime"`
+	MDate        time.Time     `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 }
 
 // Entity is one of a concurrent base object
 // mutable
 type Entity struct {
-	ID                   string    `json:"ccid" gorm:"type:char(42)"`
-	Domain               string    `json:"domain" gorm:"type:text"`
-	Tag                  string    `json:"tag" gorm:"type:text;"`
-	Score                int       `json:"score" gorm:"type:integer;default:0"`
-	IsScoreFixed         bool      `json:"isScoreFixed" gorm:"type:boolean;default:false"`
-	AffiliationDocument  string    `json:"affiliationDocument" gorm:"type:json"`
-	AffiliationSignature string    `json:"affiliationSignature" gorm:"type:char(130)"`
-	TombstoneDocument    *string   `json:"tombstoneDocument" gorm:"type:json;default:null"`
-	TombstoneSignature   *string   `json:"tombstoneSignature" gorm:"type:char(130);default:null"`
-	Alias                *string   `json:"alias,omitempty" gorm:"type:text"`
-	CDate                time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
-	MDate                time.Time `json:"mdate" gorm:"autoUpdateTime"`
+	ID                   string     `json:"ccid" gorm:"type:char(42)"`
+	Domain               string     `json:"domain" gorm:"type:text"`
+	Tag                  string     `json:"tag" gorm:"type:text;"`
+	Score                int        `json:"score" gorm:"type:integer;default:0"`
+	IsScoreFixed         bool       `json:"isScoreFixed" gorm:"type:boolean;default:false"`
+	AffiliationDocument  string     `json:"affiliationDocument" gorm:"type:json"`
+	AffiliationSignature string     `json:"affiliationSignature" gorm:"type:char(130)"`
+	TombstoneDocument    *string    `json:"tombstoneDocument" gorm:"type:json;default:null"`
+	TombstoneSignature   *string    `json:"tombstoneSignature" gorm:"type:char(130);default:null"`
+	Alias                *string    `json:"alias,omitempty" gorm:"type:text"`
+	LastFetched          *time.Time `json:"lastFetched,omitempty" gorm:"type:timestamp with time zone;default:null"` // when a remote entity was last pulled from its domain
+	CDate                time.Time  `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+	MDate                time.Time  `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
+
+	// the live tags, loaded along with a tagged entity
+	Tags []EntityTag `json:"-" gorm:"-"`
+	// the live badges, attached to the local entities this domain passes on
+	Badges []Badge `json:"badges,omitempty" gorm:"-"`
+}
+
+// Badge is a badge document issued by this domain to one of its entities. only the document and its
+// signature are passed on, as the receivers trust nothing but what is signed
+type Badge struct {
+	EntityID  string     `json:"-" gorm:"primaryKey;type:char(42)"`
+	Name      string     `json:"-" gorm:"primaryKey;type:text"`
+	Document  string     `json:"document" gorm:"type:json"`
+	Signature string     `json:"signature" gorm:"type:char(130)"`
+	ExpiresAt *time.Time `json:"-" gorm:"type:timestamp with time zone;index"`
+	CDate     time.Time  `json:"-" gorm:"type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// EntityTag is a tag given to an entity by the admin of the domain. Entity.Tag holds the live tags joined,
+// for the readers which only need the names
+type EntityTag struct {
+	EntityID  string     `json:"entity" gorm:"primaryKey;type:char(42)"`
+	Name      string     `json:"name" gorm:"primaryKey;type:text"`
+	Value     string     `json:"value,omitempty" gorm:"type:text"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" gorm:"type:timestamp with time zone;index"`
+	Author    string     `json:"author" gorm:"type:char(42)"`
+	CDate     time.Time  `json:"cdate" gorm:"type:timestamp with time zone;not null;default:clock_timestamp()"`
 }
 
 type EntityMeta struct {
@@ -101,8 +151,33 @@ type Domain struct {
 	IsScoreFixed bool        `json:"isScoreFixed" gorm:"type:boolean;default:false"`
 	Dimension    string      `json:"dimension" gorm:"-"`
 	CDate        time.Time   `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
-	MDate        time.Time   `json:"mdate" gorm:"autoUpdateTime"`
+	MDate        time.Time   `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 	LastScraped  time.Time   `json:"lastScraped" gorm:"type:timestamp with time zone"`
+	// the CSID before a rotation of the domain, accepted until PreviousCSIDUntil
+	PreviousCSID      string    `json:"previousCSID,omitempty" gorm:"type:char(42)"`
+	PreviousCSIDUntil time.Time `json:"previousCSIDUntil" gorm:"type:timestamp with time zone"`
+}
+
+// AcceptsCSID tells whether csid signs for the domain at now: its CSID, or the previous one during a rotation
+func (d Domain) AcceptsCSID(csid string, now time.Time) bool {
+	if csid == "" {
+		return false
+	}
+	if csid == d.CSID {
+		return true
+	}
+	return csid == d.PreviousCSID && now.Before(d.PreviousCSIDUntil)
+}
+
+// DomainSettings are the overrides the admin sets for a remote domain.
+// they are kept apart from Domain, which is overwritten by what the domain tells about itself
+type DomainSettings struct {
+	Domain string `json:"domain" gorm:"primaryKey;type:text"`
+	// RateLimitScale multiplies the rate limits of the users of the domain, e.g. 0.1 for a tenth. 0 keeps them
+	RateLimitScale float64   `json:"rateLimitScale" gorm:"type:double precision;default:0"`
+	Note           string    `json:"note,omitempty" gorm:"type:text"`
+	CDate          time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+	MDate          time.Time `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 }
 
 // Message is one of a concurrent base object
@@ -122,24 +197,39 @@ type Message struct {
 	Associations    []Association  `json:"associations,omitempty" gorm:"-"`
 	OwnAssociations []Association  `json:"ownAssociations,omitempty" gorm:"-"`
 	Timelines       pq.StringArray `json:"timelines" gorm:"type:text[]"`
+	ExpiresAt       *time.Time     `json:"expiresAt,omitempty" gorm:"type:timestamp with time zone;index"`
 }
 
 // Timeline is one of a base object of concurrent
 // mutable
 type Timeline struct {
-	ID           string    `json:"id" gorm:"primaryKey;type:char(26);"`
-	Indexable    bool      `json:"indexable" gorm:"type:boolean;default:false"`
-	Owner        string    `json:"owner" gorm:"type:char(42)"`
-	Author       string    `json:"author" gorm:"type:char(42)"`
-	SchemaID     uint      `json:"-"`
-	Schema       string    `json:"schema" gorm:"-"`
-	PolicyID     uint      `json:"-"`
-	Policy       string    `json:"policy,omitempty" gorm:"-"`
-	PolicyParams *string   `json:"policyParams,omitempty" gorm:"type:json"`
-	Document     string    `json:"document" gorm:"type:json"`
-	Signature    string    `json:"signature" gorm:"type:char(130)"`
-	CDate        time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
-	MDate        time.Time `json:"mdate" gorm:"autoUpdateTime"`
+	ID             string    `json:"id" gorm:"primaryKey;type:char(26);"`
+	Indexable      bool      `json:"indexable" gorm:"type:boolean;default:false"`
+	FirehoseOptOut bool      `json:"firehoseOptOut,omitempty" gorm:"type:boolean;default:false"`
+	Owner          string    `json:"owner" gorm:"type:char(42)"`
+	Author         string    `json:"author" gorm:"type:char(42)"`
+	SchemaID       uint      `json:"-"`
+	Schema         string    `json:"schema" gorm:"-"`
+	PolicyID       uint      `json:"-"`
+	Policy         string    `json:"policy,omitempty" gorm:"-"`
+	PolicyParams   *string   `json:"policyParams,omitempty" gorm:"type:json"`
+	Document       string    `json:"document" gorm:"type:json"`
+	Signature      string    `json:"signature" gorm:"type:char(130)"`
+	Version        int64     `json:"version" gorm:"not null;default:1"` // incremented by every update
+	CDate          time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+	MDate          time.Time `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
+	// Readers restricts the items of the timeline to the listed ccids besides the owner and the author. empty is public
+	Readers pq.StringArray `json:"readers,omitempty" gorm:"type:text[]"`
+	// MergedInto is the timeline this one was merged into, read from its TimelineAlias
+	MergedInto string `json:"mergedInto,omitempty" gorm:"-"`
+}
+
+// TimelineAlias marks a local timeline as merged into another one.
+// reads of the timeline are served from the other one, and writes to it are rejected
+type TimelineAlias struct {
+	Timeline   string    `json:"timeline" gorm:"primaryKey;type:char(26)"`
+	MergedInto string    `json:"mergedInto" gorm:"type:char(26);index"`
+	CDate      time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
 }
 
 // TimelineItem is one of a base object of concurrent
@@ -152,6 +242,15 @@ type TimelineItem struct {
 	SchemaID   uint      `json:"-"`
 	Schema     string    `json:"schema,omitempty" gorm:"-"`
 	CDate      time.Time `json:"cdate,omitempty" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp();index:idx_timeline_id_c_date"`
+	// ExpiresAt is the expiry of an ephemeral message, so that the domains holding the item drop it as well
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" gorm:"type:timestamp with time zone;index"`
+	// AssociationCounts is filled only when a client asks for it with include=associationCounts
+	AssociationCounts map[string]int64 `json:"associationCounts,omitempty" gorm:"-"`
+}
+
+// Expired reports whether the item is of an ephemeral message which expired by now
+func (i TimelineItem) Expired(now time.Time) bool {
+	return i.ExpiresAt != nil && !i.ExpiresAt.After(now)
 }
 
 type Ack struct {
@@ -177,7 +276,7 @@ type Subscription struct {
 	Signature    string             `json:"signature" gorm:"type:char(130)"`
 	Items        []SubscriptionItem `json:"items" gorm:"foreignKey:Subscription"`
 	CDate        time.Time          `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
-	MDate        time.Time          `json:"mdate" gorm:"autoUpdateTime"`
+	MDate        time.Time          `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 
 	DomainOwned bool `json:"domainOwned" gorm:"type:boolean;default:false"`
 }
@@ -197,10 +296,16 @@ type SubscriptionItem struct {
 	Domain       *string      `json:"domain" gorm:"type:text;"`
 }
 
+// UserKV is a value stored by a user.
+// keys of a namespace are stored as "<namespace>/<key>", flat keys belong to the empty namespace
 type UserKV struct {
-	Owner string `json:"owner" gorm:"primaryKey;type:char(42)"`
-	Key   string `json:"key" gorm:"primaryKey;type:text"`
-	Value string `json:"value" gorm:"type:text"`
+	Owner     string     `json:"owner" gorm:"primaryKey;type:char(42);index:idx_user_kv_namespace,priority:1"`
+	Key       string     `json:"key" gorm:"primaryKey;type:text"`
+	Namespace string     `json:"namespace" gorm:"type:text;not null;default:'';index:idx_user_kv_namespace,priority:2"`
+	Value     string     `json:"value" gorm:"type:text"`
+	Version   int64      `json:"version" gorm:"not null;default:1"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" gorm:"index"`
+	MDate     time.Time  `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 }
 
 type Job struct {
@@ -243,5 +348,179 @@ type NotificationSubscription struct {
 	Timelines    pq.StringArray `json:"timelines" gorm:"type:text[]"`
 	Subscription string         `json:"subscription" gorm:"type:text"`
 	CDate        time.Time      `json:"cdate" gorm:"type:timestamp with time zone;not null;default:clock_timestamp()"`
-	MDate        time.Time      `json:"mdate" gorm:"autoUpdateTime"`
+	MDate        time.Time      `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// NotificationPreference is per user settings of push notification
+type NotificationPreference struct {
+	Owner     string         `json:"owner" gorm:"primaryKey;type:char(42)"`
+	Triggers  pq.StringArray `json:"triggers" gorm:"type:text[]"`             // enabled trigger names. empty enables all
+	Muted     pq.StringArray `json:"muted" gorm:"type:text[]"`                // ccids whose events are never delivered
+	RateLimit int            `json:"rateLimit" gorm:"type:integer;default:0"` // notifications per hour. 0 uses the server default
+	MDate     time.Time      `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// AuthToken is a bearer token minted by a local user for delegated access.
+// requests made with it, and documents signed by the bound subkey, are limited to Scopes.
+// revoked tokens are kept so that their subkey stays restricted.
+type AuthToken struct {
+	ID         string         `json:"id" gorm:"primaryKey;type:char(32)"`
+	Owner      string         `json:"owner" gorm:"type:char(42);index"`
+	Name       string         `json:"name" gorm:"type:text"`
+	KeyID      string         `json:"keyID,omitempty" gorm:"type:char(42);index"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	SecretHash string         `json:"-" gorm:"type:char(64)"`
+	ExpiresAt  *time.Time     `json:"expiresAt,omitempty" gorm:"type:timestamp with time zone;default:null"`
+	RevokedAt  *time.Time     `json:"revokedAt,omitempty" gorm:"type:timestamp with time zone;default:null"`
+	CDate      time.Time      `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// ApActor is the ActivityPub key pair of a local entity
+type ApActor struct {
+	CCID       string    `json:"ccid" gorm:"primaryKey;type:char(42)"`
+	PublicKey  string    `json:"publicKey" gorm:"type:text"`
+	PrivateKey string    `json:"-" gorm:"type:text"`
+	CDate      time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// ApTimeline marks a local timeline whose new messages are published to ActivityPub followers of its owner
+type ApTimeline struct {
+	TimelineID string    `json:"timelineID" gorm:"primaryKey;type:char(27)"`
+	Owner      string    `json:"owner" gorm:"type:char(42);index"`
+	CDate      time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// ApFollower is an ActivityPub actor following a local entity.
+// Failures counts consecutive failed deliveries; the follower is skipped once it is suspended.
+type ApFollower struct {
+	Owner       string     `json:"owner" gorm:"primaryKey;type:char(42)"`
+	Actor       string     `json:"actor" gorm:"primaryKey;type:text"`
+	Inbox       string     `json:"inbox" gorm:"type:text"`
+	SharedInbox string     `json:"sharedInbox" gorm:"type:text"`
+	Failures    int        `json:"failures" gorm:"type:integer;default:0"`
+	LastError   string     `json:"lastError" gorm:"type:text"`
+	LastFailure *time.Time `json:"lastFailure,omitempty" gorm:"type:timestamp with time zone;default:null"`
+	Suspended   bool       `json:"suspended" gorm:"type:boolean;default:false"`
+	CDate       time.Time  `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// ReadState is the last read position of a user in a timeline or a subscription.
+// it only moves forward; ReadAt is the time of the last read item.
+type ReadState struct {
+	Owner  string    `json:"owner" gorm:"primaryKey;type:char(42)"`
+	Target string    `json:"target" gorm:"primaryKey;type:text"`
+	ItemID string    `json:"itemId" gorm:"type:text"`
+	ReadAt time.Time `json:"readAt" gorm:"type:timestamp with time zone;not null"`
+	MDate  time.Time `json:"mdate" gorm:"type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// EntityScoreHistory is a change of an entity score, either computed by the scoring rules or set by an admin.
+// Breakdown holds the points of each rule of a computed score.
+type EntityScoreHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey;auto_increment"`
+	Entity    string    `json:"entity" gorm:"type:char(42);index"`
+	Score     int       `json:"score" gorm:"type:integer"`
+	Previous  int       `json:"previous" gorm:"type:integer"`
+	Reason    string    `json:"reason" gorm:"type:text"` // computed or override
+	Breakdown string    `json:"breakdown,omitempty" gorm:"type:json;default:null"`
+	Actor     string    `json:"actor,omitempty" gorm:"type:text"`
+	CDate     time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// MuteRule is a server side filter of a user. items matching it are left out of what the user reads.
+// Type is one of keyword, author, schema or domain
+type MuteRule struct {
+	ID    uint      `json:"id" gorm:"primaryKey;auto_increment"`
+	Owner string    `json:"owner" gorm:"type:char(42);index"`
+	Type  string    `json:"type" gorm:"type:text"`
+	Value string    `json:"value" gorm:"type:text"`
+	CDate time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// InvalidDocument is a committed document found invalid after a key of its signer was revoked.
+// Removed is set when the resource made by the document was deleted
+type InvalidDocument struct {
+	ID          uint      `json:"id" gorm:"primaryKey;auto_increment"`
+	CommitLogID uint      `json:"commitLogID" gorm:"uniqueIndex"`
+	DocumentID  string    `json:"documentID" gorm:"type:char(26)"`
+	Type        string    `json:"type" gorm:"type:text"`
+	Signer      string    `json:"signer" gorm:"type:char(42)"`
+	KeyID       string    `json:"keyID" gorm:"type:char(42);index"`
+	SignedAt    time.Time `json:"signedAt" gorm:"type:timestamp with time zone"`
+	Reason      string    `json:"reason" gorm:"type:text"`
+	Removed     bool      `json:"removed" gorm:"type:boolean;default:false"`
+	CDate       time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// SpamFeature counts how often a feature, e.g. a word or a link host, appeared in documents reviewed as spam or ham.
+// the counts are the training data of the spam detectors
+type SpamFeature struct {
+	Feature string `json:"feature" gorm:"primaryKey;type:text"`
+	Spam    int64  `json:"spam" gorm:"type:bigint;not null;default:0"`
+	Ham     int64  `json:"ham" gorm:"type:bigint;not null;default:0"`
+}
+
+// QuarantinedDocument is a committed document held back for review because it looked like spam.
+// Keys are the keys of the passport it was committed with, to verify it again when released
+type QuarantinedDocument struct {
+	ID        uint      `json:"id" gorm:"primaryKey;auto_increment"`
+	Type      string    `json:"type" gorm:"type:text"`
+	Signer    string    `json:"signer" gorm:"type:char(42);index"`
+	Document  string    `json:"document" gorm:"type:json"`
+	Signature string    `json:"signature" gorm:"type:char(130)"`
+	Option    string    `json:"option,omitempty" gorm:"type:text"`
+	Keys      string    `json:"keys,omitempty" gorm:"type:json;default:null"`
+	Score     float64   `json:"score" gorm:"type:double precision"`
+	Verdict   string    `json:"verdict" gorm:"type:json"`
+	CDate     time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// AccountDeletion is the progress of deleting the data of a local entity after its tombstone.
+// Step is the step running or to run next; the steps before it are done
+type AccountDeletion struct {
+	ID        string    `json:"ccid" gorm:"primaryKey;type:char(42)"`
+	Step      string    `json:"step" gorm:"type:text"`
+	Status    string    `json:"status" gorm:"type:text;index"` // running, completed, failed
+	Attempts  int       `json:"attempts" gorm:"type:integer;default:0"`
+	Error     string    `json:"error,omitempty" gorm:"type:text"`
+	Document  string    `json:"-" gorm:"type:json;default:null"` // the tombstone, relayed to the peers
+	Signature string    `json:"-" gorm:"type:char(130)"`
+	CDate     time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+	MDate     time.Time `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// RelayPeer is a domain allowed to send its events to this domain acting as a relay, and to receive the events of the others
+type RelayPeer struct {
+	Domain string    `json:"domain" gorm:"primaryKey;type:text"`
+	CDate  time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// Recommendation is a timeline suggested to a local user by the recommendation job.
+// Reason is the signal contributing most to the score: ack or subscription
+type Recommendation struct {
+	Owner      string    `json:"owner" gorm:"primaryKey;type:char(42)"`
+	Timeline   string    `json:"timeline" gorm:"primaryKey;type:text"`
+	Score      float64   `json:"score" gorm:"type:double precision"`
+	Reason     string    `json:"reason" gorm:"type:text"`
+	Supporters int       `json:"supporters" gorm:"type:integer"`
+	CDate      time.Time `json:"cdate" gorm:"type:timestamp with time zone;not null;index"`
+}
+
+// RecommendationOptOut is a local user who opted out of the recommendations. their acks and subscriptions
+// are not used to recommend timelines to the others, and nothing is recommended to them
+type RecommendationOptOut struct {
+	Owner string    `json:"owner" gorm:"primaryKey;type:char(42)"`
+	CDate time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// DeliveryReceipt is the result of relaying a message of a local user to a remote domain.
+// it is pending until the domain answers the commit, then accepted or rejected
+type DeliveryReceipt struct {
+	Message   string    `json:"message" gorm:"primaryKey;type:char(27)"`
+	Domain    string    `json:"domain" gorm:"primaryKey;type:text"`
+	Timelines []string  `json:"timelines" gorm:"type:json;serializer:json"`
+	Status    string    `json:"status" gorm:"type:text"`
+	Error     string    `json:"error,omitempty" gorm:"type:text"`
+	CDate     time.Time `json:"cdate" gorm:"->;<-:create;type:timestamp with time zone;not null;default:clock_timestamp()"`
+	MDate     time.Time `json:"mdate" gorm:"autoUpdateTime;not null;default:CURRENT_TIMESTAMP"`
 }
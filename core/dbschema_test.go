@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainAcceptsCSID(t *testing.T) {
+	now := time.Now()
+	domain := Domain{
+		CSID:              "ccs1new",
+		PreviousCSID:      "ccs1old",
+		PreviousCSIDUntil: now.Add(time.Hour),
+	}
+
+	assert.True(t, domain.AcceptsCSID("ccs1new", now))
+	assert.True(t, domain.AcceptsCSID("ccs1old", now))
+	assert.False(t, domain.AcceptsCSID("ccs1old", now.Add(2*time.Hour)))
+	assert.False(t, domain.AcceptsCSID("ccs1other", now))
+	assert.False(t, Domain{}.AcceptsCSID("", now))
+}
@@ -49,6 +49,8 @@ type UnackDocument struct { // type: unack
 type MessageDocument[T any] struct { // type: message
 	DocumentBase[T]
 	Timelines []string `json:"timelines"`
+	// ephemeral messages are delivered as usual, and removed everywhere once ExpiresAt passes
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 type DeleteDocument struct { // type: delete
@@ -67,6 +69,8 @@ type AssociationDocument[T any] struct { // type: association
 // profile
 type ProfileDocument[T any] struct { // type: profile
 	DocumentBase[T]
+	// Version is the version of the profile the update was made over. the update is refused when it was updated since
+	Version int64 `json:"version,omitempty"`
 }
 
 // key
@@ -75,6 +79,8 @@ type EnactDocument struct { // type: enact
 	Target string `json:"target"`
 	Root   string `json:"root"`
 	Parent string `json:"parent"`
+	// Scopes limits what the subkey can sign. they are signed with the key, so every domain enforces them
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type RevokeDocument struct { // type: revoke
@@ -87,6 +93,12 @@ type TimelineDocument[T any] struct { // type: timeline
 	DocumentBase[T]
 	Indexable   bool `json:"indexable"`
 	DomainOwned bool `json:"domainOwned"`
+	// messages posted to the timeline are not delivered to the firehose of the domain
+	FirehoseOptOut bool `json:"firehoseOptOut,omitempty"`
+	// Readers makes the timeline private: only the listed ccids, the owner and the signer read its items
+	Readers []string `json:"readers,omitempty"`
+	// Version is the version of the timeline the update was made over. the update is refused when it was updated since
+	Version int64 `json:"version,omitempty"`
 }
 
 type RetractDocument struct {
@@ -116,9 +128,54 @@ type UnsubscribeDocument struct { // type: unsubscribe
 
 type PassportDocument struct {
 	DocumentBase[any]
-	Domain string `json:"domain"`
-	Entity Entity `json:"entity"`
-	Keys   []Key  `json:"keys"`
+	Domain   string   `json:"domain"`
+	Entity   Entity   `json:"entity"`
+	Keys     []Key    `json:"keys"`
+	Audience []string `json:"audience,omitempty"` // sha256 of the fqdn of the domains accepting the passport. empty means every domain
+	Scopes   []string `json:"scopes,omitempty"`   // actions allowed with the passport. empty means all
+	// the delegation of the service key which signed the passport instead of the domain, if any
+	Delegation *Passport `json:"delegation,omitempty"`
+}
+
+// DelegationDocument lets a companion service of a domain sign passports with its own key instead of the one
+// of the domain. it is signed with the CSID of Domain and embedded in the passports the service signs,
+// which are limited to Scopes until Until
+type DelegationDocument struct { // type: delegation
+	DocumentBase[any]
+	Domain  string    `json:"domain"`
+	Service string    `json:"service"` // the name of the service, for the audit of its requests
+	Key     string    `json:"key"`     // the CSID the service signs passports with
+	Scopes  []string  `json:"scopes"`
+	Until   time.Time `json:"until"`
+}
+
+// BadgeDocument certifies something about an entity on behalf of its domain, e.g. "verified" or "moderator".
+// it is signed with the CSID of Domain and travels with the entity in passports and entity responses,
+// so that other domains can check it without asking the domain
+type BadgeDocument struct { // type: badge
+	DocumentBase[any]
+	Domain    string     `json:"domain"` // the domain issuing the badge, which is the one of Entity
+	Entity    string     `json:"entity"`
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// DomainPassportDocument identifies a domain fetching resources from another one. it is signed with the CSID of Domain
+// and sent with every request, so that it is accepted only for a short while after SignedAt
+type DomainPassportDocument struct { // type: domainpassport
+	DocumentBase[any]
+	Domain    string `json:"domain"`              // the domain sending the request
+	Audience  string `json:"audience"`            // the fqdn of the domain the request is sent to
+	Requester string `json:"requester,omitempty"` // the local entity the request is made for, if any
+}
+
+// RelayDocument carries timeline events between a relay and its peers. it is signed with the CSID of Domain
+type RelayDocument struct { // type: relay
+	DocumentBase[any]
+	Domain string   `json:"domain"`         // the domain sending the document
+	Origin string   `json:"origin"`         // the domain the events were published on
+	Hops   []string `json:"hops,omitempty"` // the relays the events went through
+	Events []Event  `json:"events"`
 }
 
 type EventDocument struct { // type: event
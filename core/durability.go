@@ -0,0 +1,35 @@
+package core
+
+const (
+	// DurabilityDefault returns once postgres committed the document
+	DurabilityDefault = "default"
+	// DurabilityLogged also appends the document to the repository log of its owners before returning
+	DurabilityLogged = "logged"
+	// DurabilityAsync returns before postgres flushed the document and its commit log to its WAL.
+	// a crash may lose the last commits, but never leaves the database inconsistent
+	DurabilityAsync = "async"
+
+	// FsyncAlways syncs the repository log to the disk on each append
+	FsyncAlways = "always"
+	// FsyncNone leaves writing the repository log back to the OS
+	FsyncNone = "none"
+)
+
+// CommitDurability is how durable a commit is when the store returns it. zero values use the defaults
+type CommitDurability struct {
+	Level string `yaml:"level"` // default, logged or async
+	Fsync string `yaml:"fsync"` // always or none. used by the logged level
+}
+
+// WithDefaults returns the durability with the defaults in place of the unset or unknown values
+func (d CommitDurability) WithDefaults() CommitDurability {
+	switch d.Level {
+	case DurabilityLogged, DurabilityAsync:
+	default:
+		d.Level = DurabilityDefault
+	}
+	if d.Fsync != FsyncNone {
+		d.Fsync = FsyncAlways
+	}
+	return d
+}
@@ -1,5 +1,10 @@
 package core
 
+import (
+	"fmt"
+	"time"
+)
+
 type ErrorNotFound struct {
 }
 
@@ -43,3 +48,285 @@ func (e ErrorAlreadyDeleted) Error() string {
 func NewErrorAlreadyDeleted() ErrorAlreadyDeleted {
 	return ErrorAlreadyDeleted{}
 }
+
+// SchemaViolation is a reason why a document body does not match its schema
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+type ErrorSchemaViolation struct {
+	Schema     string
+	Violations []SchemaViolation
+}
+
+func (e ErrorSchemaViolation) Error() string {
+	return "Schema Violation: " + e.Schema
+}
+
+func NewErrorSchemaViolation(schema string, violations []SchemaViolation) ErrorSchemaViolation {
+	return ErrorSchemaViolation{Schema: schema, Violations: violations}
+}
+
+// ErrorPolicyDenied is returned when a policy does not allow the action to the requester
+type ErrorPolicyDenied struct {
+	Action string
+}
+
+func (e ErrorPolicyDenied) Error() string {
+	return "Policy Denied: " + e.Action
+}
+
+// Is matches any ErrorPolicyDenied regardless of the action.
+// it also matches ErrorPermissionDenied, which callers checked for before policy denials were told apart
+func (e ErrorPolicyDenied) Is(target error) bool {
+	switch target.(type) {
+	case ErrorPolicyDenied, ErrorPermissionDenied:
+		return true
+	}
+	return false
+}
+
+func NewErrorPolicyDenied(action string) ErrorPolicyDenied {
+	return ErrorPolicyDenied{Action: action}
+}
+
+// ErrorInvalidSignature is returned when the signature of a document can't be verified
+type ErrorInvalidSignature struct {
+	Reason string
+}
+
+func (e ErrorInvalidSignature) Error() string {
+	return "Invalid Signature: " + e.Reason
+}
+
+func (e ErrorInvalidSignature) Is(target error) bool {
+	_, ok := target.(ErrorInvalidSignature)
+	return ok
+}
+
+func NewErrorInvalidSignature(reason string) ErrorInvalidSignature {
+	return ErrorInvalidSignature{Reason: reason}
+}
+
+// ErrorInvalidArgument is returned when a document or a request is malformed
+type ErrorInvalidArgument struct {
+	Reason string
+}
+
+func (e ErrorInvalidArgument) Error() string {
+	return "Invalid Argument: " + e.Reason
+}
+
+func (e ErrorInvalidArgument) Is(target error) bool {
+	_, ok := target.(ErrorInvalidArgument)
+	return ok
+}
+
+func NewErrorInvalidArgument(reason string) ErrorInvalidArgument {
+	return ErrorInvalidArgument{Reason: reason}
+}
+
+// ErrorRemoteUnavailable is returned when a remote domain can't be reached
+type ErrorRemoteUnavailable struct {
+	Domain string
+	Err    error
+}
+
+func (e ErrorRemoteUnavailable) Error() string {
+	if e.Err == nil {
+		return "Remote Unavailable: " + e.Domain
+	}
+	return "Remote Unavailable: " + e.Domain + ": " + e.Err.Error()
+}
+
+func (e ErrorRemoteUnavailable) Is(target error) bool {
+	_, ok := target.(ErrorRemoteUnavailable)
+	return ok
+}
+
+func (e ErrorRemoteUnavailable) Unwrap() error {
+	return e.Err
+}
+
+func NewErrorRemoteUnavailable(domain string, err error) ErrorRemoteUnavailable {
+	return ErrorRemoteUnavailable{Domain: domain, Err: err}
+}
+
+// ErrorTimeout is returned when an operation, a database statement or a call to a remote domain,
+// did not complete within its deadline
+type ErrorTimeout struct {
+	Operation string
+	Timeout   time.Duration
+	Err       error
+}
+
+func (e ErrorTimeout) Error() string {
+	msg := "Timeout: " + e.Operation
+	if e.Timeout > 0 {
+		msg += " did not complete within " + e.Timeout.String()
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e ErrorTimeout) Is(target error) bool {
+	_, ok := target.(ErrorTimeout)
+	return ok
+}
+
+func (e ErrorTimeout) Unwrap() error {
+	return e.Err
+}
+
+func NewErrorTimeout(operation string, timeout time.Duration, err error) ErrorTimeout {
+	return ErrorTimeout{Operation: operation, Timeout: timeout, Err: err}
+}
+
+// ErrorQuotaExceeded is returned when the requester used up a quota. Reset is when it is refilled, if known
+type ErrorQuotaExceeded struct {
+	Quota string
+	Reset time.Time
+}
+
+func (e ErrorQuotaExceeded) Error() string {
+	return "Quota Exceeded: " + e.Quota
+}
+
+func (e ErrorQuotaExceeded) Is(target error) bool {
+	_, ok := target.(ErrorQuotaExceeded)
+	return ok
+}
+
+func NewErrorQuotaExceeded(quota string, reset time.Time) ErrorQuotaExceeded {
+	return ErrorQuotaExceeded{Quota: quota, Reset: reset}
+}
+
+// ErrorLimitExceeded is returned when the requester already owns as many resources of a kind as it may.
+// it is sent to the client as is, so that it can tell the user what to remove
+type ErrorLimitExceeded struct {
+	Resource string `json:"resource"`
+	Limit    int64  `json:"limit"`
+	Owned    int64  `json:"owned"`
+}
+
+func (e ErrorLimitExceeded) Error() string {
+	return fmt.Sprintf("Limit Exceeded: %d of %d %s owned", e.Owned, e.Limit, e.Resource)
+}
+
+func (e ErrorLimitExceeded) Is(target error) bool {
+	_, ok := target.(ErrorLimitExceeded)
+	return ok
+}
+
+func NewErrorLimitExceeded(resource string, limit, owned int64) ErrorLimitExceeded {
+	return ErrorLimitExceeded{Resource: resource, Limit: limit, Owned: owned}
+}
+
+// ErrorRateLimited is returned when the requester wrote to a target more often than its limit allows.
+// it is sent to the client as is, so that it can tell the user when to try again
+type ErrorRateLimited struct {
+	Action     string `json:"action"`
+	Target     string `json:"target"`
+	Limit      int64  `json:"limit"`
+	Window     int64  `json:"window"`     // seconds
+	RetryAfter int64  `json:"retryAfter"` // seconds
+}
+
+func (e ErrorRateLimited) Error() string {
+	return "Rate Limited: " + e.Action + " to " + e.Target
+}
+
+func (e ErrorRateLimited) Is(target error) bool {
+	_, ok := target.(ErrorRateLimited)
+	return ok
+}
+
+func NewErrorRateLimited(action, target string, limit int64, window, retryAfter time.Duration) ErrorRateLimited {
+	return ErrorRateLimited{
+		Action:     action,
+		Target:     target,
+		Limit:      limit,
+		Window:     int64(window.Seconds()),
+		RetryAfter: int64(retryAfter.Seconds()),
+	}
+}
+
+// ErrorConflict is returned when an update was made over an older version of the document than the stored one.
+// the client can make it again over Current, or commit it with the force option to overwrite
+type ErrorConflict struct {
+	Current int64 `json:"current"`
+}
+
+func (e ErrorConflict) Error() string {
+	return "Conflict: the document was updated concurrently"
+}
+
+func (e ErrorConflict) Is(target error) bool {
+	_, ok := target.(ErrorConflict)
+	return ok
+}
+
+func NewErrorConflict(current int64) ErrorConflict {
+	return ErrorConflict{Current: current}
+}
+
+// ErrorTooLarge is returned when a request or a document exceeds one of the size limits of the domain.
+// it is sent to the client as is, so that it can tell which limit was hit
+type ErrorTooLarge struct {
+	Limit  string `json:"limit"`
+	Max    int64  `json:"max"`
+	Actual int64  `json:"actual,omitempty"` // unknown when the request body is streamed
+}
+
+func (e ErrorTooLarge) Error() string {
+	return "Too Large: " + e.Limit
+}
+
+func (e ErrorTooLarge) Is(target error) bool {
+	_, ok := target.(ErrorTooLarge)
+	return ok
+}
+
+func NewErrorTooLarge(limit string, max, actual int64) ErrorTooLarge {
+	return ErrorTooLarge{Limit: limit, Max: max, Actual: actual}
+}
+
+// ErrorTimelineMerged is returned when an item is written to a timeline merged into another one.
+// the client can write it to MergedInto instead
+type ErrorTimelineMerged struct {
+	Timeline   string `json:"timeline"`
+	MergedInto string `json:"mergedInto"`
+}
+
+func (e ErrorTimelineMerged) Error() string {
+	return "Gone: the timeline " + e.Timeline + " was merged into " + e.MergedInto
+}
+
+func (e ErrorTimelineMerged) Is(target error) bool {
+	_, ok := target.(ErrorTimelineMerged)
+	return ok
+}
+
+func NewErrorTimelineMerged(timeline, mergedInto string) ErrorTimelineMerged {
+	return ErrorTimelineMerged{Timeline: timeline, MergedInto: mergedInto}
+}
+
+type ErrorUnauthorized struct {
+}
+
+func (e ErrorUnauthorized) Error() string {
+	return "Unauthorized"
+}
+
+func (e ErrorUnauthorized) Is(target error) bool {
+	_, ok := target.(ErrorUnauthorized)
+	return ok
+}
+
+func NewErrorUnauthorized() ErrorUnauthorized {
+	return ErrorUnauthorized{}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTPStatus returns the status code a handler responds with for the error
+func HTTPStatus(err error) int {
+	var schemaViolation ErrorSchemaViolation
+	switch {
+	case errors.Is(err, ErrorNotFound{}):
+		return http.StatusNotFound
+	case errors.Is(err, ErrorAlreadyExists{}), errors.Is(err, ErrorConflict{}):
+		return http.StatusConflict
+	case errors.Is(err, ErrorAlreadyDeleted{}), errors.Is(err, ErrorTimelineMerged{}):
+		return http.StatusGone
+	case errors.Is(err, ErrorUnauthorized{}):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrorPermissionDenied{}), errors.Is(err, ErrorLimitExceeded{}):
+		return http.StatusForbidden
+	case errors.Is(err, ErrorInvalidSignature{}), errors.Is(err, ErrorInvalidArgument{}), errors.As(err, &schemaViolation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrorTooLarge{}):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrorQuotaExceeded{}), errors.Is(err, ErrorRateLimited{}):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrorRemoteUnavailable{}):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrorTimeout{}):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// HTTPErrorHandler responds to errors returned from handlers with the status of HTTPStatus.
+// handlers return the typed errors of the services as is, so the status is decided here from the type of the error.
+// errors of echo itself, e.g. unknown routes, are handled by the default handler
+func HTTPErrorHandler(e *echo.Echo) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+		if c.Response().Committed {
+			return
+		}
+
+		body := echo.Map{"status": "error", "error": err.Error()}
+
+		var violation ErrorSchemaViolation
+		if errors.As(err, &violation) {
+			body["content"] = violation.Violations
+		}
+
+		var quota ErrorQuotaExceeded
+		if errors.As(err, &quota) && !quota.Reset.IsZero() {
+			c.Response().Header().Set("X-Quota-Reset", strconv.FormatInt(quota.Reset.Unix(), 10))
+		}
+
+		var conflict ErrorConflict
+		if errors.As(err, &conflict) {
+			body["content"] = conflict
+		}
+
+		var merged ErrorTimelineMerged
+		if errors.As(err, &merged) {
+			body["content"] = merged
+		}
+
+		var tooLarge ErrorTooLarge
+		if errors.As(err, &tooLarge) {
+			body["content"] = tooLarge
+		}
+
+		var exceeded ErrorLimitExceeded
+		if errors.As(err, &exceeded) {
+			body["content"] = exceeded
+		}
+
+		var limited ErrorRateLimited
+		if errors.As(err, &limited) {
+			body["content"] = limited
+			c.Response().Header().Set("Retry-After", strconv.FormatInt(limited.RetryAfter, 10))
+		}
+
+		status := HTTPStatus(err)
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(status)
+		} else {
+			err = c.JSON(status, body)
+		}
+		if err != nil {
+			e.Logger.Error(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(NewErrorNotFound()))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(fmt.Errorf("wrapped: %w", NewErrorAlreadyExists())))
+	assert.Equal(t, http.StatusConflict, HTTPStatus(NewErrorConflict(3)))
+	assert.Equal(t, http.StatusForbidden, HTTPStatus(NewErrorPolicyDenied("timeline.distribute")))
+	assert.Equal(t, http.StatusUnauthorized, HTTPStatus(fmt.Errorf("%w: captcha", NewErrorUnauthorized())))
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(NewErrorInvalidSignature("signer mismatch")))
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(NewErrorInvalidArgument("invalid type")))
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatus(NewErrorQuotaExceeded("daily requests", time.Time{})))
+	assert.Equal(t, http.StatusForbidden, HTTPStatus(NewErrorLimitExceeded("timelines", 64, 64)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, HTTPStatus(NewErrorTooLarge("document", 8192, 10000)))
+	assert.Equal(t, http.StatusGone, HTTPStatus(NewErrorTimelineMerged("t00000000000000000000000000", "t11111111111111111111111111")))
+	assert.Equal(t, http.StatusBadGateway, HTTPStatus(NewErrorRemoteUnavailable("example.com", errors.New("dial tcp"))))
+	assert.Equal(t, http.StatusGatewayTimeout, HTTPStatus(fmt.Errorf("failed to get chunks: %w", NewErrorTimeout("db.query", time.Second, errors.New("canceled")))))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(errors.New("unknown")))
+
+	// policy denials are permission errors too
+	assert.True(t, errors.Is(NewErrorPolicyDenied("message.read"), ErrorPermissionDenied{}))
+
+	cause := errors.New("dial tcp")
+	assert.ErrorIs(t, NewErrorRemoteUnavailable("example.com", cause), cause)
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	handler := HTTPErrorHandler(e)
+
+	rec := httptest.NewRecorder()
+	reset := time.Unix(1704240000, 0)
+	handler(NewErrorQuotaExceeded("daily commits", reset), e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1704240000", rec.Header().Get("X-Quota-Reset"))
+
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "error", body["status"])
+	assert.Equal(t, "Quota Exceeded: daily commits", body["error"])
+
+	rec = httptest.NewRecorder()
+	handler(NewErrorRateLimited("timeline.distribute", "t00000000000000000000000000", 10, time.Minute, 42*time.Second), e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "42", rec.Header().Get("Retry-After"))
+	body = nil
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"action":     "timeline.distribute",
+		"target":     "t00000000000000000000000000",
+		"limit":      float64(10),
+		"window":     float64(60),
+		"retryAfter": float64(42),
+	}, body["content"])
+
+	// errors of echo keep their own status
+	rec = httptest.NewRecorder()
+	handler(echo.ErrMethodNotAllowed, e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
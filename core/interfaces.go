@@ -21,6 +21,7 @@ type AgentService interface {
 
 type AssociationService interface {
 	Create(ctx context.Context, mode CommitMode, document, signature string) (Association, []string, error)
+	CreateBatch(ctx context.Context, commits []Commit) ([]BatchResult, error)
 	Delete(ctx context.Context, mode CommitMode, document, signature string) (Association, []string, error)
 
 	Clean(ctx context.Context, ccid string) error
@@ -28,17 +29,28 @@ type AssociationService interface {
 	GetOwn(ctx context.Context, author string) ([]Association, error)
 	GetByTarget(ctx context.Context, targetID string) ([]Association, error)
 	GetCountsBySchema(ctx context.Context, messageID string) (map[string]int64, error)
+	GetCountsBySchemaForTargets(ctx context.Context, targetIDs []string) (map[string]map[string]int64, error)
 	GetBySchema(ctx context.Context, messageID string, schema string) ([]Association, error)
 	GetCountsBySchemaAndVariant(ctx context.Context, messageID string, schema string) (map[string]int64, error)
 	GetBySchemaAndVariant(ctx context.Context, messageID string, schema string, variant string) ([]Association, error)
 	GetOwnByTarget(ctx context.Context, targetID, author string) ([]Association, error)
+	GetReposters(ctx context.Context, messageID string) ([]string, error)
+	CountReposts(ctx context.Context, messageID string) (int64, error)
+	GetThread(ctx context.Context, messageID string, query ThreadQuery) (Thread, error)
+	GetThreadTimeline(ctx context.Context, messageID string) (Timeline, error)
 	Count(ctx context.Context) (int64, error)
+	ReconcileCounts(ctx context.Context) error
 }
 
 type AuthService interface {
-	IssuePassport(ctx context.Context, requester string, key []Key) (string, error)
+	IssuePassport(ctx context.Context, requester string, key []Key, audience []string, scopes Scopes) (string, error)
+	IssueDelegation(ctx context.Context, service, key string, scopes Scopes, until time.Time) (string, error)
 	IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc
 	RateLimiter(configMap RateLimitConfigMap) echo.MiddlewareFunc
+	IssueToken(ctx context.Context, token AuthToken) (AuthToken, string, error)
+	ListTokens(ctx context.Context, owner string) ([]AuthToken, error)
+	RevokeToken(ctx context.Context, owner, id string) error
+	AuthorizeCommit(ctx context.Context, document string) error
 }
 
 type DomainService interface {
@@ -51,6 +63,14 @@ type DomainService interface {
 	Delete(ctx context.Context, id string) error
 	Update(ctx context.Context, host Domain) error
 	UpdateScrapeTime(ctx context.Context, id string, scrapeTime time.Time) error
+	FederationStats(ctx context.Context) []FederationStats
+	Hello(ctx context.Context, fqdn string) (Domain, error)
+	Announce(ctx context.Context) ([]DomainAnnouncement, error)
+	KeyStatus(ctx context.Context) DomainKeyStatus
+	Peers(ctx context.Context) ([]DomainPeer, error)
+	Peer(ctx context.Context, fqdn string) (DomainPeer, error)
+	UpdateSettings(ctx context.Context, fqdn string, update DomainSettingsUpdate) (DomainPeer, error)
+	RateLimitScale(ctx context.Context, fqdn string) float64
 }
 
 type EntityService interface {
@@ -62,13 +82,27 @@ type EntityService interface {
 	GetWithHint(ctx context.Context, ccid, hint string) (Entity, error)
 	GetMeta(ctx context.Context, ccid string) (EntityMeta, error)
 	GetByAlias(ctx context.Context, alias string) (Entity, error)
-	List(ctx context.Context) ([]Entity, error)
+	AttachAlias(ctx context.Context, ccid, alias string) (AliasStatus, error)
+	GetAliasStatus(ctx context.Context, ccid string) (AliasStatus, error)
+	DetachAlias(ctx context.Context, ccid string) error
+	List(ctx context.Context, query EntityListQuery) ([]Entity, string, error)
 	UpdateScore(ctx context.Context, id string, score int) error
+	OverrideScore(ctx context.Context, id string, score int, fixed bool) error
 	UpdateTag(ctx context.Context, id, tag string) error
+	TagTaxonomy() TagTaxonomy
+	ListTags(ctx context.Context, id string) ([]EntityTag, error)
+	SetTag(ctx context.Context, tag EntityTag) (EntityTag, error)
+	RemoveTag(ctx context.Context, id, name string) error
+	ExpireTags(ctx context.Context) error
+	IssueBadge(ctx context.Context, id, name string, body any, expiresAt *time.Time) (Badge, error)
+	RevokeBadge(ctx context.Context, id, name string) error
+	ListBadges(ctx context.Context, id string) ([]Badge, error)
 	IsUserExists(ctx context.Context, user string) bool
 	Delete(ctx context.Context, id string) error
 	Count(ctx context.Context) (int64, error)
 	PullEntityFromRemote(ctx context.Context, id, domain string) (Entity, error)
+	Refresh(ctx context.Context, id string) (Entity, error)
+	RefreshStale(ctx context.Context, ttl time.Duration) (int, error)
 }
 
 type KeyService interface {
@@ -79,6 +113,8 @@ type KeyService interface {
 	GetKeyResolution(ctx context.Context, keyID string) ([]Key, error)
 	GetRemoteKeyResolution(ctx context.Context, remote string, keyID string) ([]Key, error)
 	GetAllKeys(ctx context.Context, owner string) ([]Key, error)
+	ListSessions(ctx context.Context, owner string) ([]KeySession, error)
+	Touch(ctx context.Context, keyID, userAgent string) error
 }
 
 type MessageService interface {
@@ -89,6 +125,8 @@ type MessageService interface {
 	Create(ctx context.Context, mode CommitMode, document string, signature string) (Message, []string, error)
 	Delete(ctx context.Context, mode CommitMode, document, signature string) (Message, []string, error)
 	Count(ctx context.Context) (int64, error)
+	GetReceipts(ctx context.Context, id string, requester string) ([]DeliveryReceipt, error)
+	Expire(ctx context.Context) ([]Message, error)
 }
 
 type PolicyService interface {
@@ -100,12 +138,13 @@ type PolicyService interface {
 }
 
 type ProfileService interface {
-	Upsert(ctx context.Context, mode CommitMode, document, signature string) (Profile, error)
+	Upsert(ctx context.Context, mode CommitMode, document, signature, option string) (Profile, error)
 	Delete(ctx context.Context, mode CommitMode, document string) (Profile, error)
 
 	Clean(ctx context.Context, ccid string) error
 	Count(ctx context.Context) (int64, error)
 	Get(ctx context.Context, id string) (Profile, error)
+	GetWithHint(ctx context.Context, id, hint string) (Profile, error)
 	GetBySemanticID(ctx context.Context, semanticID, owner string) (Profile, error)
 	GetByAuthorAndSchema(ctx context.Context, owner string, schema string) ([]Profile, error)
 	GetByAuthor(ctx context.Context, owner string) ([]Profile, error)
@@ -118,10 +157,15 @@ type SchemaService interface {
 	IDToUrl(ctx context.Context, id uint) (string, error)
 }
 
+type SchemaValidator interface {
+	Validate(ctx context.Context, schemaURL string, body any) error
+}
+
 type SemanticIDService interface {
 	Name(ctx context.Context, id, owner, target, document, signature string) (SemanticID, error)
 	Lookup(ctx context.Context, id, owner string) (string, error)
 	Delete(ctx context.Context, id, owner string) error
+	Repoint(ctx context.Context, from, to string) (int64, error)
 	Clean(ctx context.Context, ccid string) error
 }
 
@@ -134,10 +178,29 @@ type SocketManager interface {
 type StoreService interface {
 	Commit(ctx context.Context, mode CommitMode, document, signature, option string, keys []Key, IP string) (any, error)
 	Restore(ctx context.Context, archive io.Reader, from, IP string) ([]BatchResult, error)
+	CommitAssociations(ctx context.Context, commits []Commit, keys []Key, IP string) ([]BatchResult, error)
 	ValidateDocument(ctx context.Context, document, signature string, keys []Key) error
 	CleanUserAllData(ctx context.Context, target string) error
 	SyncCommitFile(ctx context.Context, owner string) (SyncStatus, error)
 	SyncStatus(ctx context.Context, owner string) (SyncStatus, error)
+	StreamRepository(ctx context.Context, owner string, since, until time.Time, w io.Writer) error
+	CompactRepository(ctx context.Context, owner string) (int, error)
+	PruneExpired(ctx context.Context) error
+	AddHook(hook CommitHook)
+	AddListener(listener CommitListener)
+	SetLimits(limits DocumentLimits)
+	SetDurability(durability CommitDurability)
+}
+
+// CommitHook inspects documents before the store executes them.
+// the returned context is used for the rest of the commit. returning an error aborts it
+type CommitHook interface {
+	BeforeCommit(ctx context.Context, mode CommitMode, document, signature, option string, keys []Key) (context.Context, error)
+}
+
+// CommitListener is told about the documents the store executed, after they are committed
+type CommitListener interface {
+	AfterCommit(ctx context.Context, mode CommitMode, typ, document, signature string)
 }
 
 type SubscriptionService interface {
@@ -152,7 +215,7 @@ type SubscriptionService interface {
 }
 
 type TimelineService interface {
-	UpsertTimeline(ctx context.Context, mode CommitMode, document, signature string) (Timeline, error)
+	UpsertTimeline(ctx context.Context, mode CommitMode, document, signature, option string) (Timeline, error)
 	DeleteTimeline(ctx context.Context, mode CommitMode, document string) (Timeline, error)
 	Event(ctx context.Context, mode CommitMode, document, signature string) (Event, error)
 
@@ -167,18 +230,28 @@ type TimelineService interface {
 	GetImmediateItemsFromSubscription(ctx context.Context, subscription string, since time.Time, limit int) ([]TimelineItem, error)
 	GetItem(ctx context.Context, timeline string, id string) (TimelineItem, error)
 	PostItem(ctx context.Context, timeline string, item TimelineItem, document, signature string) (TimelineItem, error)
+	CheckThrottle(ctx context.Context, timelines []string, author, document string) error
 	Retract(ctx context.Context, mode CommitMode, document, signature string) (TimelineItem, []string, error)
 	RemoveItemsByResourceID(ctx context.Context, resourceID string) error
+	ExpireItems(ctx context.Context) (int64, error)
 
 	PublishEvent(ctx context.Context, event Event) error
 
 	GetTimeline(ctx context.Context, key string) (Timeline, error)
+	Merge(ctx context.Context, timeline, into, requester string) (Timeline, error)
+	ResolveAliases(ctx context.Context, timelines []string) (map[string]string, error)
 	GetTimelineAutoDomain(ctx context.Context, timelineID string) (Timeline, error)
+	EnsureFirehose(ctx context.Context) (Timeline, error)
+	EnsureThread(ctx context.Context, rootID string) (Timeline, error)
+	MigrateDomainTimelines(ctx context.Context) (int, error)
 
 	ListTimelineBySchema(ctx context.Context, schema string) ([]Timeline, error)
+	Discover(ctx context.Context, query TimelineDiscoverQuery) ([]DiscoveredTimeline, string, error)
 	ListTimelineByAuthor(ctx context.Context, author string) ([]Timeline, error)
 
 	GetChunks(ctx context.Context, timelines []string, epoch string) (map[string]Chunk, error)
+	WarmChunkCache(ctx context.Context, epoch string, count int) ([]string, error)
+	Backfill(ctx context.Context, timeline string, chunks int) (int, error)
 
 	ListTimelineSubscriptions(ctx context.Context) (map[string]int64, error)
 	Count(ctx context.Context) (int64, error)
@@ -186,12 +259,17 @@ type TimelineService interface {
 	GetOwners(ctx context.Context, timelines []string) ([]string, error)
 
 	Query(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int) ([]TimelineItem, error)
+	QueryStream(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(TimelineItem) error) error
 
 	ListLocalRecentlyRemovedItems(ctx context.Context, timelines []string) (map[string][]string, error)
 
 	Realtime(ctx context.Context, request <-chan []string, response chan<- Event)
+	FilterReadable(ctx context.Context, timelines []string) (allowed []string, denied []string)
+	AllowsReader(ctx context.Context, timelines []string, requester string) bool
 
 	UpdateMetrics()
+	GetMetrics() map[string]int64
+	GetBridges() []BridgeStatus
 }
 
 type JobService interface {
@@ -207,4 +285,20 @@ type NotificationService interface {
 	GetAllSubscriptions(ctx context.Context) ([]NotificationSubscription, error)
 	Delete(ctx context.Context, vendorID, owner string) error
 	Get(ctx context.Context, vendorID, owner string) (NotificationSubscription, error)
+	GetPreference(ctx context.Context, owner string) (NotificationPreference, error)
+	UpdatePreference(ctx context.Context, preference NotificationPreference) (NotificationPreference, error)
+}
+
+type MuteService interface {
+	List(ctx context.Context, owner string) ([]MuteRule, error)
+	Create(ctx context.Context, rule MuteRule) (MuteRule, error)
+	Delete(ctx context.Context, owner string, id uint) error
+	Filter(ctx context.Context, owner string) (MuteFilter, error)
+}
+
+// MuteFilter tells which items the mute rules of a user hide
+type MuteFilter interface {
+	Empty() bool
+	MutedItems(ctx context.Context, items []TimelineItem) map[string]bool
+	MutedEvent(ctx context.Context, event Event) bool
 }
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	DefaultMaxDocumentBytes     = 8192
+	DefaultMaxTimelines         = 64
+	DefaultMaxPolicyParamsBytes = 4096
+	DefaultMaxRequestBytes      = 1 << 20
+)
+
+// DocumentLimits bound the size and the complexity of what clients send. zero values use the defaults
+type DocumentLimits struct {
+	MaxDocumentBytes     int   `yaml:"maxDocumentBytes"`
+	MaxTimelines         int   `yaml:"maxTimelines"`         // timelines a message or an association is posted to
+	MaxPolicyParamsBytes int   `yaml:"maxPolicyParamsBytes"` // policyParams and policyDefaults of a document
+	MaxRequestBytes      int64 `yaml:"maxRequestBytes"`      // bodies bound by the handlers
+}
+
+// WithDefaults returns the limits with the defaults in place of the unset ones
+func (l DocumentLimits) WithDefaults() DocumentLimits {
+	if l.MaxDocumentBytes <= 0 {
+		l.MaxDocumentBytes = DefaultMaxDocumentBytes
+	}
+	if l.MaxTimelines <= 0 {
+		l.MaxTimelines = DefaultMaxTimelines
+	}
+	if l.MaxPolicyParamsBytes <= 0 {
+		l.MaxPolicyParamsBytes = DefaultMaxPolicyParamsBytes
+	}
+	if l.MaxRequestBytes <= 0 {
+		l.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	return l
+}
+
+// DefaultOwnershipLimit is the name of the limit applied to entities without a tag having its own limit
+const DefaultOwnershipLimit = "DEFAULT"
+
+// OwnershipLimit bounds how many resources an entity may create and keep on the domain. 0 means unlimited
+type OwnershipLimit struct {
+	Timelines     int64 `yaml:"timelines" json:"timelines"`
+	Subscriptions int64 `yaml:"subscriptions" json:"subscriptions"`
+}
+
+// OwnershipLimits are keyed by entity tag, with DefaultOwnershipLimit for the others
+type OwnershipLimits map[string]OwnershipLimit
+
+// For returns the limit of an entity. when several of its tags have a limit, the most generous one wins for each kind,
+// so that a tag given by the admin overrides the default
+func (l OwnershipLimits) For(tags Tags) OwnershipLimit {
+	var limit OwnershipLimit
+	matched := false
+	for tag, tagLimit := range l {
+		if tag == DefaultOwnershipLimit || !tags.Has(tag) {
+			continue
+		}
+		if !matched {
+			limit = tagLimit
+			matched = true
+			continue
+		}
+		limit.Timelines = generousLimit(limit.Timelines, tagLimit.Timelines)
+		limit.Subscriptions = generousLimit(limit.Subscriptions, tagLimit.Subscriptions)
+	}
+	if !matched {
+		return l[DefaultOwnershipLimit]
+	}
+	return limit
+}
+
+func generousLimit(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// LimitedBinder is the echo binder refusing request bodies larger than Limit with ErrorTooLarge,
+// before they are read into memory
+type LimitedBinder struct {
+	echo.DefaultBinder
+	Limit int64
+}
+
+func (b *LimitedBinder) Bind(i any, c echo.Context) error {
+	req := c.Request()
+	if b.Limit > 0 && req.Body != nil {
+		if req.ContentLength > b.Limit {
+			return NewErrorTooLarge("request", b.Limit, req.ContentLength)
+		}
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, b.Limit)
+	}
+
+	err := b.DefaultBinder.Bind(i, c)
+
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return NewErrorTooLarge("request", b.Limit, 0)
+	}
+	return err
+}
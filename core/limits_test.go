@@ -0,0 +1,50 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitedBinder(t *testing.T) {
+	e := echo.New()
+	e.Binder = &LimitedBinder{Limit: 32}
+
+	bind := func(body string, streamed bool) error {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if streamed {
+			req.ContentLength = -1
+		}
+		var v map[string]any
+		return e.NewContext(req, httptest.NewRecorder()).Bind(&v)
+	}
+
+	assert.NoError(t, bind(`{"document":"short"}`, false))
+
+	err := bind(`{"document":"`+strings.Repeat("a", 64)+`"}`, false)
+	assert.Equal(t, NewErrorTooLarge("request", 32, 79), err)
+
+	// bodies without a length are cut off while they are read
+	err = bind(`{"document":"`+strings.Repeat("a", 64)+`"}`, true)
+	assert.True(t, errors.Is(err, ErrorTooLarge{}))
+}
+
+func TestOwnershipLimits(t *testing.T) {
+	limits := OwnershipLimits{
+		DefaultOwnershipLimit: {Timelines: 32, Subscriptions: 16},
+		"creator":             {Timelines: 256, Subscriptions: 16},
+		"_admin":              {Timelines: 0, Subscriptions: 64},
+	}
+
+	assert.Equal(t, OwnershipLimit{Timelines: 32, Subscriptions: 16}, limits.For(ParseTags("")))
+	assert.Equal(t, OwnershipLimit{Timelines: 256, Subscriptions: 16}, limits.For(ParseTags("creator")))
+	// the most generous limit of the tags wins, 0 being unlimited
+	assert.Equal(t, OwnershipLimit{Timelines: 0, Subscriptions: 64}, limits.For(ParseTags("creator,_admin")))
+	assert.Equal(t, OwnershipLimit{}, OwnershipLimits(nil).For(ParseTags("creator")))
+}
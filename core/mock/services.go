@@ -176,6 +176,21 @@ func (mr *MockAssociationServiceMockRecorder) Count(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockAssociationService)(nil).Count), ctx)
 }
 
+// CountReposts mocks base method.
+func (m *MockAssociationService) CountReposts(ctx context.Context, messageID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountReposts", ctx, messageID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountReposts indicates an expected call of CountReposts.
+func (mr *MockAssociationServiceMockRecorder) CountReposts(ctx, messageID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountReposts", reflect.TypeOf((*MockAssociationService)(nil).CountReposts), ctx, messageID)
+}
+
 // Create mocks base method.
 func (m *MockAssociationService) Create(ctx context.Context, mode core.CommitMode, document, signature string) (core.Association, []string, error) {
 	m.ctrl.T.Helper()
@@ -192,6 +207,21 @@ func (mr *MockAssociationServiceMockRecorder) Create(ctx, mode, document, signat
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAssociationService)(nil).Create), ctx, mode, document, signature)
 }
 
+// CreateBatch mocks base method.
+func (m *MockAssociationService) CreateBatch(ctx context.Context, commits []core.Commit) ([]core.BatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", ctx, commits)
+	ret0, _ := ret[0].([]core.BatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockAssociationServiceMockRecorder) CreateBatch(ctx, commits any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockAssociationService)(nil).CreateBatch), ctx, commits)
+}
+
 // Delete mocks base method.
 func (m *MockAssociationService) Delete(ctx context.Context, mode core.CommitMode, document, signature string) (core.Association, []string, error) {
 	m.ctrl.T.Helper()
@@ -298,6 +328,21 @@ func (mr *MockAssociationServiceMockRecorder) GetCountsBySchemaAndVariant(ctx, m
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountsBySchemaAndVariant", reflect.TypeOf((*MockAssociationService)(nil).GetCountsBySchemaAndVariant), ctx, messageID, schema)
 }
 
+// GetCountsBySchemaForTargets mocks base method.
+func (m *MockAssociationService) GetCountsBySchemaForTargets(ctx context.Context, targetIDs []string) (map[string]map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCountsBySchemaForTargets", ctx, targetIDs)
+	ret0, _ := ret[0].(map[string]map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCountsBySchemaForTargets indicates an expected call of GetCountsBySchemaForTargets.
+func (mr *MockAssociationServiceMockRecorder) GetCountsBySchemaForTargets(ctx, targetIDs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountsBySchemaForTargets", reflect.TypeOf((*MockAssociationService)(nil).GetCountsBySchemaForTargets), ctx, targetIDs)
+}
+
 // GetOwn mocks base method.
 func (m *MockAssociationService) GetOwn(ctx context.Context, author string) ([]core.Association, error) {
 	m.ctrl.T.Helper()
@@ -328,6 +373,65 @@ func (mr *MockAssociationServiceMockRecorder) GetOwnByTarget(ctx, targetID, auth
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOwnByTarget", reflect.TypeOf((*MockAssociationService)(nil).GetOwnByTarget), ctx, targetID, author)
 }
 
+// GetReposters mocks base method.
+func (m *MockAssociationService) GetReposters(ctx context.Context, messageID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReposters", ctx, messageID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReposters indicates an expected call of GetReposters.
+func (mr *MockAssociationServiceMockRecorder) GetReposters(ctx, messageID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReposters", reflect.TypeOf((*MockAssociationService)(nil).GetReposters), ctx, messageID)
+}
+
+// GetThread mocks base method.
+func (m *MockAssociationService) GetThread(ctx context.Context, messageID string, query core.ThreadQuery) (core.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThread", ctx, messageID, query)
+	ret0, _ := ret[0].(core.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThread indicates an expected call of GetThread.
+func (mr *MockAssociationServiceMockRecorder) GetThread(ctx, messageID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThread", reflect.TypeOf((*MockAssociationService)(nil).GetThread), ctx, messageID, query)
+}
+
+// GetThreadTimeline mocks base method.
+func (m *MockAssociationService) GetThreadTimeline(ctx context.Context, messageID string) (core.Timeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreadTimeline", ctx, messageID)
+	ret0, _ := ret[0].(core.Timeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThreadTimeline indicates an expected call of GetThreadTimeline.
+func (mr *MockAssociationServiceMockRecorder) GetThreadTimeline(ctx, messageID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreadTimeline", reflect.TypeOf((*MockAssociationService)(nil).GetThreadTimeline), ctx, messageID)
+}
+
+// ReconcileCounts mocks base method.
+func (m *MockAssociationService) ReconcileCounts(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileCounts", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReconcileCounts indicates an expected call of ReconcileCounts.
+func (mr *MockAssociationServiceMockRecorder) ReconcileCounts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileCounts", reflect.TypeOf((*MockAssociationService)(nil).ReconcileCounts), ctx)
+}
+
 // MockAuthService is a mock of AuthService interface.
 type MockAuthService struct {
 	ctrl     *gomock.Controller
@@ -351,6 +455,20 @@ func (m *MockAuthService) EXPECT() *MockAuthServiceMockRecorder {
 	return m.recorder
 }
 
+// AuthorizeCommit mocks base method.
+func (m *MockAuthService) AuthorizeCommit(ctx context.Context, document string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthorizeCommit", ctx, document)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AuthorizeCommit indicates an expected call of AuthorizeCommit.
+func (mr *MockAuthServiceMockRecorder) AuthorizeCommit(ctx, document any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthorizeCommit", reflect.TypeOf((*MockAuthService)(nil).AuthorizeCommit), ctx, document)
+}
+
 // IdentifyIdentity mocks base method.
 func (m *MockAuthService) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 	m.ctrl.T.Helper()
@@ -365,19 +483,65 @@ func (mr *MockAuthServiceMockRecorder) IdentifyIdentity(next any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdentifyIdentity", reflect.TypeOf((*MockAuthService)(nil).IdentifyIdentity), next)
 }
 
+// IssueDelegation mocks base method.
+func (m *MockAuthService) IssueDelegation(ctx context.Context, service, key string, scopes core.Scopes, until time.Time) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueDelegation", ctx, service, key, scopes, until)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueDelegation indicates an expected call of IssueDelegation.
+func (mr *MockAuthServiceMockRecorder) IssueDelegation(ctx, service, key, scopes, until any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueDelegation", reflect.TypeOf((*MockAuthService)(nil).IssueDelegation), ctx, service, key, scopes, until)
+}
+
 // IssuePassport mocks base method.
-func (m *MockAuthService) IssuePassport(ctx context.Context, requester string, key []core.Key) (string, error) {
+func (m *MockAuthService) IssuePassport(ctx context.Context, requester string, key []core.Key, audience []string, scopes core.Scopes) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IssuePassport", ctx, requester, key)
+	ret := m.ctrl.Call(m, "IssuePassport", ctx, requester, key, audience, scopes)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // IssuePassport indicates an expected call of IssuePassport.
-func (mr *MockAuthServiceMockRecorder) IssuePassport(ctx, requester, key any) *gomock.Call {
+func (mr *MockAuthServiceMockRecorder) IssuePassport(ctx, requester, key, audience, scopes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssuePassport", reflect.TypeOf((*MockAuthService)(nil).IssuePassport), ctx, requester, key, audience, scopes)
+}
+
+// IssueToken mocks base method.
+func (m *MockAuthService) IssueToken(ctx context.Context, token core.AuthToken) (core.AuthToken, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueToken", ctx, token)
+	ret0, _ := ret[0].(core.AuthToken)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IssueToken indicates an expected call of IssueToken.
+func (mr *MockAuthServiceMockRecorder) IssueToken(ctx, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueToken", reflect.TypeOf((*MockAuthService)(nil).IssueToken), ctx, token)
+}
+
+// ListTokens mocks base method.
+func (m *MockAuthService) ListTokens(ctx context.Context, owner string) ([]core.AuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTokens", ctx, owner)
+	ret0, _ := ret[0].([]core.AuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTokens indicates an expected call of ListTokens.
+func (mr *MockAuthServiceMockRecorder) ListTokens(ctx, owner any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssuePassport", reflect.TypeOf((*MockAuthService)(nil).IssuePassport), ctx, requester, key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTokens", reflect.TypeOf((*MockAuthService)(nil).ListTokens), ctx, owner)
 }
 
 // RateLimiter mocks base method.
@@ -394,6 +558,20 @@ func (mr *MockAuthServiceMockRecorder) RateLimiter(configMap any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RateLimiter", reflect.TypeOf((*MockAuthService)(nil).RateLimiter), configMap)
 }
 
+// RevokeToken mocks base method.
+func (m *MockAuthService) RevokeToken(ctx context.Context, owner, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockAuthServiceMockRecorder) RevokeToken(ctx, owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockAuthService)(nil).RevokeToken), ctx, owner, id)
+}
+
 // MockDomainService is a mock of DomainService interface.
 type MockDomainService struct {
 	ctrl     *gomock.Controller
@@ -417,6 +595,21 @@ func (m *MockDomainService) EXPECT() *MockDomainServiceMockRecorder {
 	return m.recorder
 }
 
+// Announce mocks base method.
+func (m *MockDomainService) Announce(ctx context.Context) ([]core.DomainAnnouncement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Announce", ctx)
+	ret0, _ := ret[0].([]core.DomainAnnouncement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Announce indicates an expected call of Announce.
+func (mr *MockDomainServiceMockRecorder) Announce(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Announce", reflect.TypeOf((*MockDomainService)(nil).Announce), ctx)
+}
+
 // Delete mocks base method.
 func (m *MockDomainService) Delete(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
@@ -431,6 +624,20 @@ func (mr *MockDomainServiceMockRecorder) Delete(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockDomainService)(nil).Delete), ctx, id)
 }
 
+// FederationStats mocks base method.
+func (m *MockDomainService) FederationStats(ctx context.Context) []core.FederationStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FederationStats", ctx)
+	ret0, _ := ret[0].([]core.FederationStats)
+	return ret0
+}
+
+// FederationStats indicates an expected call of FederationStats.
+func (mr *MockDomainServiceMockRecorder) FederationStats(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FederationStats", reflect.TypeOf((*MockDomainService)(nil).FederationStats), ctx)
+}
+
 // ForceFetch mocks base method.
 func (m *MockDomainService) ForceFetch(ctx context.Context, fqdn string) (core.Domain, error) {
 	m.ctrl.T.Helper()
@@ -491,6 +698,35 @@ func (mr *MockDomainServiceMockRecorder) GetByFQDN(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByFQDN", reflect.TypeOf((*MockDomainService)(nil).GetByFQDN), ctx, key)
 }
 
+// Hello mocks base method.
+func (m *MockDomainService) Hello(ctx context.Context, fqdn string) (core.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hello", ctx, fqdn)
+	ret0, _ := ret[0].(core.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hello indicates an expected call of Hello.
+func (mr *MockDomainServiceMockRecorder) Hello(ctx, fqdn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hello", reflect.TypeOf((*MockDomainService)(nil).Hello), ctx, fqdn)
+}
+
+// KeyStatus mocks base method.
+func (m *MockDomainService) KeyStatus(ctx context.Context) core.DomainKeyStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeyStatus", ctx)
+	ret0, _ := ret[0].(core.DomainKeyStatus)
+	return ret0
+}
+
+// KeyStatus indicates an expected call of KeyStatus.
+func (mr *MockDomainServiceMockRecorder) KeyStatus(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyStatus", reflect.TypeOf((*MockDomainService)(nil).KeyStatus), ctx)
+}
+
 // List mocks base method.
 func (m *MockDomainService) List(ctx context.Context) ([]core.Domain, error) {
 	m.ctrl.T.Helper()
@@ -506,6 +742,50 @@ func (mr *MockDomainServiceMockRecorder) List(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockDomainService)(nil).List), ctx)
 }
 
+// Peer mocks base method.
+func (m *MockDomainService) Peer(ctx context.Context, fqdn string) (core.DomainPeer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peer", ctx, fqdn)
+	ret0, _ := ret[0].(core.DomainPeer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peer indicates an expected call of Peer.
+func (mr *MockDomainServiceMockRecorder) Peer(ctx, fqdn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peer", reflect.TypeOf((*MockDomainService)(nil).Peer), ctx, fqdn)
+}
+
+// Peers mocks base method.
+func (m *MockDomainService) Peers(ctx context.Context) ([]core.DomainPeer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peers", ctx)
+	ret0, _ := ret[0].([]core.DomainPeer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peers indicates an expected call of Peers.
+func (mr *MockDomainServiceMockRecorder) Peers(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peers", reflect.TypeOf((*MockDomainService)(nil).Peers), ctx)
+}
+
+// RateLimitScale mocks base method.
+func (m *MockDomainService) RateLimitScale(ctx context.Context, fqdn string) float64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RateLimitScale", ctx, fqdn)
+	ret0, _ := ret[0].(float64)
+	return ret0
+}
+
+// RateLimitScale indicates an expected call of RateLimitScale.
+func (mr *MockDomainServiceMockRecorder) RateLimitScale(ctx, fqdn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RateLimitScale", reflect.TypeOf((*MockDomainService)(nil).RateLimitScale), ctx, fqdn)
+}
+
 // Update mocks base method.
 func (m *MockDomainService) Update(ctx context.Context, host core.Domain) error {
 	m.ctrl.T.Helper()
@@ -534,6 +814,21 @@ func (mr *MockDomainServiceMockRecorder) UpdateScrapeTime(ctx, id, scrapeTime an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScrapeTime", reflect.TypeOf((*MockDomainService)(nil).UpdateScrapeTime), ctx, id, scrapeTime)
 }
 
+// UpdateSettings mocks base method.
+func (m *MockDomainService) UpdateSettings(ctx context.Context, fqdn string, update core.DomainSettingsUpdate) (core.DomainPeer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSettings", ctx, fqdn, update)
+	ret0, _ := ret[0].(core.DomainPeer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSettings indicates an expected call of UpdateSettings.
+func (mr *MockDomainServiceMockRecorder) UpdateSettings(ctx, fqdn, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSettings", reflect.TypeOf((*MockDomainService)(nil).UpdateSettings), ctx, fqdn, update)
+}
+
 // Upsert mocks base method.
 func (m *MockDomainService) Upsert(ctx context.Context, host core.Domain) (core.Domain, error) {
 	m.ctrl.T.Helper()
@@ -587,6 +882,21 @@ func (mr *MockEntityServiceMockRecorder) Affiliation(ctx, mode, document, signat
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Affiliation", reflect.TypeOf((*MockEntityService)(nil).Affiliation), ctx, mode, document, signature, meta)
 }
 
+// AttachAlias mocks base method.
+func (m *MockEntityService) AttachAlias(ctx context.Context, ccid, alias string) (core.AliasStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AttachAlias", ctx, ccid, alias)
+	ret0, _ := ret[0].(core.AliasStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AttachAlias indicates an expected call of AttachAlias.
+func (mr *MockEntityServiceMockRecorder) AttachAlias(ctx, ccid, alias any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachAlias", reflect.TypeOf((*MockEntityService)(nil).AttachAlias), ctx, ccid, alias)
+}
+
 // Clean mocks base method.
 func (m *MockEntityService) Clean(ctx context.Context, ccid string) error {
 	m.ctrl.T.Helper()
@@ -630,6 +940,34 @@ func (mr *MockEntityServiceMockRecorder) Delete(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockEntityService)(nil).Delete), ctx, id)
 }
 
+// DetachAlias mocks base method.
+func (m *MockEntityService) DetachAlias(ctx context.Context, ccid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DetachAlias", ctx, ccid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DetachAlias indicates an expected call of DetachAlias.
+func (mr *MockEntityServiceMockRecorder) DetachAlias(ctx, ccid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachAlias", reflect.TypeOf((*MockEntityService)(nil).DetachAlias), ctx, ccid)
+}
+
+// ExpireTags mocks base method.
+func (m *MockEntityService) ExpireTags(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireTags", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpireTags indicates an expected call of ExpireTags.
+func (mr *MockEntityServiceMockRecorder) ExpireTags(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireTags", reflect.TypeOf((*MockEntityService)(nil).ExpireTags), ctx)
+}
+
 // Get mocks base method.
 func (m *MockEntityService) Get(ctx context.Context, ccid string) (core.Entity, error) {
 	m.ctrl.T.Helper()
@@ -645,6 +983,21 @@ func (mr *MockEntityServiceMockRecorder) Get(ctx, ccid any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockEntityService)(nil).Get), ctx, ccid)
 }
 
+// GetAliasStatus mocks base method.
+func (m *MockEntityService) GetAliasStatus(ctx context.Context, ccid string) (core.AliasStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAliasStatus", ctx, ccid)
+	ret0, _ := ret[0].(core.AliasStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAliasStatus indicates an expected call of GetAliasStatus.
+func (mr *MockEntityServiceMockRecorder) GetAliasStatus(ctx, ccid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAliasStatus", reflect.TypeOf((*MockEntityService)(nil).GetAliasStatus), ctx, ccid)
+}
+
 // GetByAlias mocks base method.
 func (m *MockEntityService) GetByAlias(ctx context.Context, alias string) (core.Entity, error) {
 	m.ctrl.T.Helper()
@@ -704,19 +1057,79 @@ func (mr *MockEntityServiceMockRecorder) IsUserExists(ctx, user any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUserExists", reflect.TypeOf((*MockEntityService)(nil).IsUserExists), ctx, user)
 }
 
+// IssueBadge mocks base method.
+func (m *MockEntityService) IssueBadge(ctx context.Context, id, name string, body any, expiresAt *time.Time) (core.Badge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueBadge", ctx, id, name, body, expiresAt)
+	ret0, _ := ret[0].(core.Badge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueBadge indicates an expected call of IssueBadge.
+func (mr *MockEntityServiceMockRecorder) IssueBadge(ctx, id, name, body, expiresAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueBadge", reflect.TypeOf((*MockEntityService)(nil).IssueBadge), ctx, id, name, body, expiresAt)
+}
+
 // List mocks base method.
-func (m *MockEntityService) List(ctx context.Context) ([]core.Entity, error) {
+func (m *MockEntityService) List(ctx context.Context, query core.EntityListQuery) ([]core.Entity, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List", ctx)
+	ret := m.ctrl.Call(m, "List", ctx, query)
 	ret0, _ := ret[0].([]core.Entity)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// List indicates an expected call of List.
+func (mr *MockEntityServiceMockRecorder) List(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockEntityService)(nil).List), ctx, query)
+}
+
+// ListBadges mocks base method.
+func (m *MockEntityService) ListBadges(ctx context.Context, id string) ([]core.Badge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBadges", ctx, id)
+	ret0, _ := ret[0].([]core.Badge)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// List indicates an expected call of List.
-func (mr *MockEntityServiceMockRecorder) List(ctx any) *gomock.Call {
+// ListBadges indicates an expected call of ListBadges.
+func (mr *MockEntityServiceMockRecorder) ListBadges(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockEntityService)(nil).List), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBadges", reflect.TypeOf((*MockEntityService)(nil).ListBadges), ctx, id)
+}
+
+// ListTags mocks base method.
+func (m *MockEntityService) ListTags(ctx context.Context, id string) ([]core.EntityTag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTags", ctx, id)
+	ret0, _ := ret[0].([]core.EntityTag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTags indicates an expected call of ListTags.
+func (mr *MockEntityServiceMockRecorder) ListTags(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTags", reflect.TypeOf((*MockEntityService)(nil).ListTags), ctx, id)
+}
+
+// OverrideScore mocks base method.
+func (m *MockEntityService) OverrideScore(ctx context.Context, id string, score int, fixed bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OverrideScore", ctx, id, score, fixed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OverrideScore indicates an expected call of OverrideScore.
+func (mr *MockEntityServiceMockRecorder) OverrideScore(ctx, id, score, fixed any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OverrideScore", reflect.TypeOf((*MockEntityService)(nil).OverrideScore), ctx, id, score, fixed)
 }
 
 // PullEntityFromRemote mocks base method.
@@ -734,6 +1147,93 @@ func (mr *MockEntityServiceMockRecorder) PullEntityFromRemote(ctx, id, domain an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PullEntityFromRemote", reflect.TypeOf((*MockEntityService)(nil).PullEntityFromRemote), ctx, id, domain)
 }
 
+// Refresh mocks base method.
+func (m *MockEntityService) Refresh(ctx context.Context, id string) (core.Entity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, id)
+	ret0, _ := ret[0].(core.Entity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockEntityServiceMockRecorder) Refresh(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockEntityService)(nil).Refresh), ctx, id)
+}
+
+// RefreshStale mocks base method.
+func (m *MockEntityService) RefreshStale(ctx context.Context, ttl time.Duration) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshStale", ctx, ttl)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshStale indicates an expected call of RefreshStale.
+func (mr *MockEntityServiceMockRecorder) RefreshStale(ctx, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshStale", reflect.TypeOf((*MockEntityService)(nil).RefreshStale), ctx, ttl)
+}
+
+// RemoveTag mocks base method.
+func (m *MockEntityService) RemoveTag(ctx context.Context, id, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTag", ctx, id, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveTag indicates an expected call of RemoveTag.
+func (mr *MockEntityServiceMockRecorder) RemoveTag(ctx, id, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTag", reflect.TypeOf((*MockEntityService)(nil).RemoveTag), ctx, id, name)
+}
+
+// RevokeBadge mocks base method.
+func (m *MockEntityService) RevokeBadge(ctx context.Context, id, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeBadge", ctx, id, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeBadge indicates an expected call of RevokeBadge.
+func (mr *MockEntityServiceMockRecorder) RevokeBadge(ctx, id, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeBadge", reflect.TypeOf((*MockEntityService)(nil).RevokeBadge), ctx, id, name)
+}
+
+// SetTag mocks base method.
+func (m *MockEntityService) SetTag(ctx context.Context, tag core.EntityTag) (core.EntityTag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTag", ctx, tag)
+	ret0, _ := ret[0].(core.EntityTag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTag indicates an expected call of SetTag.
+func (mr *MockEntityServiceMockRecorder) SetTag(ctx, tag any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTag", reflect.TypeOf((*MockEntityService)(nil).SetTag), ctx, tag)
+}
+
+// TagTaxonomy mocks base method.
+func (m *MockEntityService) TagTaxonomy() core.TagTaxonomy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TagTaxonomy")
+	ret0, _ := ret[0].(core.TagTaxonomy)
+	return ret0
+}
+
+// TagTaxonomy indicates an expected call of TagTaxonomy.
+func (mr *MockEntityServiceMockRecorder) TagTaxonomy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagTaxonomy", reflect.TypeOf((*MockEntityService)(nil).TagTaxonomy))
+}
+
 // Tombstone mocks base method.
 func (m *MockEntityService) Tombstone(ctx context.Context, mode core.CommitMode, document, signature string) (core.Entity, error) {
 	m.ctrl.T.Helper()
@@ -874,6 +1374,21 @@ func (mr *MockKeyServiceMockRecorder) GetRemoteKeyResolution(ctx, remote, keyID
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRemoteKeyResolution", reflect.TypeOf((*MockKeyService)(nil).GetRemoteKeyResolution), ctx, remote, keyID)
 }
 
+// ListSessions mocks base method.
+func (m *MockKeyService) ListSessions(ctx context.Context, owner string) ([]core.KeySession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, owner)
+	ret0, _ := ret[0].([]core.KeySession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockKeyServiceMockRecorder) ListSessions(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockKeyService)(nil).ListSessions), ctx, owner)
+}
+
 // ResolveSubkey mocks base method.
 func (m *MockKeyService) ResolveSubkey(ctx context.Context, keyID string) (string, error) {
 	m.ctrl.T.Helper()
@@ -904,6 +1419,20 @@ func (mr *MockKeyServiceMockRecorder) Revoke(ctx, mode, payload, signature any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockKeyService)(nil).Revoke), ctx, mode, payload, signature)
 }
 
+// Touch mocks base method.
+func (m *MockKeyService) Touch(ctx context.Context, keyID, userAgent string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", ctx, keyID, userAgent)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockKeyServiceMockRecorder) Touch(ctx, keyID, userAgent any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockKeyService)(nil).Touch), ctx, keyID, userAgent)
+}
+
 // MockMessageService is a mock of MessageService interface.
 type MockMessageService struct {
 	ctrl     *gomock.Controller
@@ -988,6 +1517,21 @@ func (mr *MockMessageServiceMockRecorder) Delete(ctx, mode, document, signature
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMessageService)(nil).Delete), ctx, mode, document, signature)
 }
 
+// Expire mocks base method.
+func (m *MockMessageService) Expire(ctx context.Context) ([]core.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", ctx)
+	ret0, _ := ret[0].([]core.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockMessageServiceMockRecorder) Expire(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockMessageService)(nil).Expire), ctx)
+}
+
 // GetAsGuest mocks base method.
 func (m *MockMessageService) GetAsGuest(ctx context.Context, id string) (core.Message, error) {
 	m.ctrl.T.Helper()
@@ -1018,6 +1562,21 @@ func (mr *MockMessageServiceMockRecorder) GetAsUser(ctx, id, requester any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAsUser", reflect.TypeOf((*MockMessageService)(nil).GetAsUser), ctx, id, requester)
 }
 
+// GetReceipts mocks base method.
+func (m *MockMessageService) GetReceipts(ctx context.Context, id, requester string) ([]core.DeliveryReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReceipts", ctx, id, requester)
+	ret0, _ := ret[0].([]core.DeliveryReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReceipts indicates an expected call of GetReceipts.
+func (mr *MockMessageServiceMockRecorder) GetReceipts(ctx, id, requester any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceipts", reflect.TypeOf((*MockMessageService)(nil).GetReceipts), ctx, id, requester)
+}
+
 // GetWithOwnAssociations mocks base method.
 func (m *MockMessageService) GetWithOwnAssociations(ctx context.Context, id, requester string) (core.Message, error) {
 	m.ctrl.T.Helper()
@@ -1271,19 +1830,49 @@ func (mr *MockProfileServiceMockRecorder) GetBySemanticID(ctx, semanticID, owner
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBySemanticID", reflect.TypeOf((*MockProfileService)(nil).GetBySemanticID), ctx, semanticID, owner)
 }
 
+// GetWithHint mocks base method.
+func (m *MockProfileService) GetWithHint(ctx context.Context, id, hint string) (core.Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithHint", ctx, id, hint)
+	ret0, _ := ret[0].(core.Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithHint indicates an expected call of GetWithHint.
+func (mr *MockProfileServiceMockRecorder) GetWithHint(ctx, id, hint any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithHint", reflect.TypeOf((*MockProfileService)(nil).GetWithHint), ctx, id, hint)
+}
+
+// Query mocks base method.
+func (m *MockProfileService) Query(ctx context.Context, author, schema string, limit int, since, until time.Time) ([]core.Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", ctx, author, schema, limit, since, until)
+	ret0, _ := ret[0].([]core.Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockProfileServiceMockRecorder) Query(ctx, author, schema, limit, since, until any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockProfileService)(nil).Query), ctx, author, schema, limit, since, until)
+}
+
 // Upsert mocks base method.
-func (m *MockProfileService) Upsert(ctx context.Context, mode core.CommitMode, document, signature string) (core.Profile, error) {
+func (m *MockProfileService) Upsert(ctx context.Context, mode core.CommitMode, document, signature, option string) (core.Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Upsert", ctx, mode, document, signature)
+	ret := m.ctrl.Call(m, "Upsert", ctx, mode, document, signature, option)
 	ret0, _ := ret[0].(core.Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Upsert indicates an expected call of Upsert.
-func (mr *MockProfileServiceMockRecorder) Upsert(ctx, mode, document, signature any) *gomock.Call {
+func (mr *MockProfileServiceMockRecorder) Upsert(ctx, mode, document, signature, option any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockProfileService)(nil).Upsert), ctx, mode, document, signature)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockProfileService)(nil).Upsert), ctx, mode, document, signature, option)
 }
 
 // MockSchemaService is a mock of SchemaService interface.
@@ -1339,6 +1928,43 @@ func (mr *MockSchemaServiceMockRecorder) UrlToID(ctx, url any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UrlToID", reflect.TypeOf((*MockSchemaService)(nil).UrlToID), ctx, url)
 }
 
+// MockSchemaValidator is a mock of SchemaValidator interface.
+type MockSchemaValidator struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchemaValidatorMockRecorder
+}
+
+// MockSchemaValidatorMockRecorder is the mock recorder for MockSchemaValidator.
+type MockSchemaValidatorMockRecorder struct {
+	mock *MockSchemaValidator
+}
+
+// NewMockSchemaValidator creates a new mock instance.
+func NewMockSchemaValidator(ctrl *gomock.Controller) *MockSchemaValidator {
+	mock := &MockSchemaValidator{ctrl: ctrl}
+	mock.recorder = &MockSchemaValidatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSchemaValidator) EXPECT() *MockSchemaValidatorMockRecorder {
+	return m.recorder
+}
+
+// Validate mocks base method.
+func (m *MockSchemaValidator) Validate(ctx context.Context, schemaURL string, body any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", ctx, schemaURL, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockSchemaValidatorMockRecorder) Validate(ctx, schemaURL, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockSchemaValidator)(nil).Validate), ctx, schemaURL, body)
+}
+
 // MockSemanticIDService is a mock of SemanticIDService interface.
 type MockSemanticIDService struct {
 	ctrl     *gomock.Controller
@@ -1420,6 +2046,21 @@ func (mr *MockSemanticIDServiceMockRecorder) Name(ctx, id, owner, target, docume
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockSemanticIDService)(nil).Name), ctx, id, owner, target, document, signature)
 }
 
+// Repoint mocks base method.
+func (m *MockSemanticIDService) Repoint(ctx context.Context, from, to string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Repoint", ctx, from, to)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Repoint indicates an expected call of Repoint.
+func (mr *MockSemanticIDServiceMockRecorder) Repoint(ctx, from, to any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Repoint", reflect.TypeOf((*MockSemanticIDService)(nil).Repoint), ctx, from, to)
+}
+
 // MockSocketManager is a mock of SocketManager interface.
 type MockSocketManager struct {
 	ctrl     *gomock.Controller
@@ -1504,6 +2145,30 @@ func (m *MockStoreService) EXPECT() *MockStoreServiceMockRecorder {
 	return m.recorder
 }
 
+// AddHook mocks base method.
+func (m *MockStoreService) AddHook(hook core.CommitHook) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddHook", hook)
+}
+
+// AddHook indicates an expected call of AddHook.
+func (mr *MockStoreServiceMockRecorder) AddHook(hook any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHook", reflect.TypeOf((*MockStoreService)(nil).AddHook), hook)
+}
+
+// AddListener mocks base method.
+func (m *MockStoreService) AddListener(listener core.CommitListener) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddListener", listener)
+}
+
+// AddListener indicates an expected call of AddListener.
+func (mr *MockStoreServiceMockRecorder) AddListener(listener any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddListener", reflect.TypeOf((*MockStoreService)(nil).AddListener), listener)
+}
+
 // CleanUserAllData mocks base method.
 func (m *MockStoreService) CleanUserAllData(ctx context.Context, target string) error {
 	m.ctrl.T.Helper()
@@ -1533,6 +2198,50 @@ func (mr *MockStoreServiceMockRecorder) Commit(ctx, mode, document, signature, o
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockStoreService)(nil).Commit), ctx, mode, document, signature, option, keys, IP)
 }
 
+// CommitAssociations mocks base method.
+func (m *MockStoreService) CommitAssociations(ctx context.Context, commits []core.Commit, keys []core.Key, IP string) ([]core.BatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitAssociations", ctx, commits, keys, IP)
+	ret0, _ := ret[0].([]core.BatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CommitAssociations indicates an expected call of CommitAssociations.
+func (mr *MockStoreServiceMockRecorder) CommitAssociations(ctx, commits, keys, IP any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitAssociations", reflect.TypeOf((*MockStoreService)(nil).CommitAssociations), ctx, commits, keys, IP)
+}
+
+// CompactRepository mocks base method.
+func (m *MockStoreService) CompactRepository(ctx context.Context, owner string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompactRepository", ctx, owner)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompactRepository indicates an expected call of CompactRepository.
+func (mr *MockStoreServiceMockRecorder) CompactRepository(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompactRepository", reflect.TypeOf((*MockStoreService)(nil).CompactRepository), ctx, owner)
+}
+
+// PruneExpired mocks base method.
+func (m *MockStoreService) PruneExpired(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneExpired", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneExpired indicates an expected call of PruneExpired.
+func (mr *MockStoreServiceMockRecorder) PruneExpired(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneExpired", reflect.TypeOf((*MockStoreService)(nil).PruneExpired), ctx)
+}
+
 // Restore mocks base method.
 func (m *MockStoreService) Restore(ctx context.Context, archive io.Reader, from, IP string) ([]core.BatchResult, error) {
 	m.ctrl.T.Helper()
@@ -1548,6 +2257,44 @@ func (mr *MockStoreServiceMockRecorder) Restore(ctx, archive, from, IP any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockStoreService)(nil).Restore), ctx, archive, from, IP)
 }
 
+// SetDurability mocks base method.
+func (m *MockStoreService) SetDurability(durability core.CommitDurability) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetDurability", durability)
+}
+
+// SetDurability indicates an expected call of SetDurability.
+func (mr *MockStoreServiceMockRecorder) SetDurability(durability any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDurability", reflect.TypeOf((*MockStoreService)(nil).SetDurability), durability)
+}
+
+// SetLimits mocks base method.
+func (m *MockStoreService) SetLimits(limits core.DocumentLimits) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetLimits", limits)
+}
+
+// SetLimits indicates an expected call of SetLimits.
+func (mr *MockStoreServiceMockRecorder) SetLimits(limits any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLimits", reflect.TypeOf((*MockStoreService)(nil).SetLimits), limits)
+}
+
+// StreamRepository mocks base method.
+func (m *MockStoreService) StreamRepository(ctx context.Context, owner string, since, until time.Time, w io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamRepository", ctx, owner, since, until, w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamRepository indicates an expected call of StreamRepository.
+func (mr *MockStoreServiceMockRecorder) StreamRepository(ctx, owner, since, until, w any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamRepository", reflect.TypeOf((*MockStoreService)(nil).StreamRepository), ctx, owner, since, until, w)
+}
+
 // SyncCommitFile mocks base method.
 func (m *MockStoreService) SyncCommitFile(ctx context.Context, owner string) (core.SyncStatus, error) {
 	m.ctrl.T.Helper()
@@ -1578,18 +2325,91 @@ func (mr *MockStoreServiceMockRecorder) SyncStatus(ctx, owner any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncStatus", reflect.TypeOf((*MockStoreService)(nil).SyncStatus), ctx, owner)
 }
 
-// ValidateDocument mocks base method.
-func (m *MockStoreService) ValidateDocument(ctx context.Context, document, signature string, keys []core.Key) error {
+// ValidateDocument mocks base method.
+func (m *MockStoreService) ValidateDocument(ctx context.Context, document, signature string, keys []core.Key) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateDocument", ctx, document, signature, keys)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateDocument indicates an expected call of ValidateDocument.
+func (mr *MockStoreServiceMockRecorder) ValidateDocument(ctx, document, signature, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateDocument", reflect.TypeOf((*MockStoreService)(nil).ValidateDocument), ctx, document, signature, keys)
+}
+
+// MockCommitHook is a mock of CommitHook interface.
+type MockCommitHook struct {
+	ctrl     *gomock.Controller
+	recorder *MockCommitHookMockRecorder
+}
+
+// MockCommitHookMockRecorder is the mock recorder for MockCommitHook.
+type MockCommitHookMockRecorder struct {
+	mock *MockCommitHook
+}
+
+// NewMockCommitHook creates a new mock instance.
+func NewMockCommitHook(ctrl *gomock.Controller) *MockCommitHook {
+	mock := &MockCommitHook{ctrl: ctrl}
+	mock.recorder = &MockCommitHookMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCommitHook) EXPECT() *MockCommitHookMockRecorder {
+	return m.recorder
+}
+
+// BeforeCommit mocks base method.
+func (m *MockCommitHook) BeforeCommit(ctx context.Context, mode core.CommitMode, document, signature, option string, keys []core.Key) (context.Context, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeforeCommit", ctx, mode, document, signature, option, keys)
+	ret0, _ := ret[0].(context.Context)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeforeCommit indicates an expected call of BeforeCommit.
+func (mr *MockCommitHookMockRecorder) BeforeCommit(ctx, mode, document, signature, option, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeforeCommit", reflect.TypeOf((*MockCommitHook)(nil).BeforeCommit), ctx, mode, document, signature, option, keys)
+}
+
+// MockCommitListener is a mock of CommitListener interface.
+type MockCommitListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockCommitListenerMockRecorder
+}
+
+// MockCommitListenerMockRecorder is the mock recorder for MockCommitListener.
+type MockCommitListenerMockRecorder struct {
+	mock *MockCommitListener
+}
+
+// NewMockCommitListener creates a new mock instance.
+func NewMockCommitListener(ctrl *gomock.Controller) *MockCommitListener {
+	mock := &MockCommitListener{ctrl: ctrl}
+	mock.recorder = &MockCommitListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCommitListener) EXPECT() *MockCommitListenerMockRecorder {
+	return m.recorder
+}
+
+// AfterCommit mocks base method.
+func (m *MockCommitListener) AfterCommit(ctx context.Context, mode core.CommitMode, typ, document, signature string) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ValidateDocument", ctx, document, signature, keys)
-	ret0, _ := ret[0].(error)
-	return ret0
+	m.ctrl.Call(m, "AfterCommit", ctx, mode, typ, document, signature)
 }
 
-// ValidateDocument indicates an expected call of ValidateDocument.
-func (mr *MockStoreServiceMockRecorder) ValidateDocument(ctx, document, signature, keys any) *gomock.Call {
+// AfterCommit indicates an expected call of AfterCommit.
+func (mr *MockCommitListenerMockRecorder) AfterCommit(ctx, mode, typ, document, signature any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateDocument", reflect.TypeOf((*MockStoreService)(nil).ValidateDocument), ctx, document, signature, keys)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AfterCommit", reflect.TypeOf((*MockCommitListener)(nil).AfterCommit), ctx, mode, typ, document, signature)
 }
 
 // MockSubscriptionService is a mock of SubscriptionService interface.
@@ -1742,6 +2562,49 @@ func (m *MockTimelineService) EXPECT() *MockTimelineServiceMockRecorder {
 	return m.recorder
 }
 
+// AllowsReader mocks base method.
+func (m *MockTimelineService) AllowsReader(ctx context.Context, timelines []string, requester string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowsReader", ctx, timelines, requester)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AllowsReader indicates an expected call of AllowsReader.
+func (mr *MockTimelineServiceMockRecorder) AllowsReader(ctx, timelines, requester any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowsReader", reflect.TypeOf((*MockTimelineService)(nil).AllowsReader), ctx, timelines, requester)
+}
+
+// Backfill mocks base method.
+func (m *MockTimelineService) Backfill(ctx context.Context, timeline string, chunks int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Backfill", ctx, timeline, chunks)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Backfill indicates an expected call of Backfill.
+func (mr *MockTimelineServiceMockRecorder) Backfill(ctx, timeline, chunks any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Backfill", reflect.TypeOf((*MockTimelineService)(nil).Backfill), ctx, timeline, chunks)
+}
+
+// CheckThrottle mocks base method.
+func (m *MockTimelineService) CheckThrottle(ctx context.Context, timelines []string, author, document string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckThrottle", ctx, timelines, author, document)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckThrottle indicates an expected call of CheckThrottle.
+func (mr *MockTimelineServiceMockRecorder) CheckThrottle(ctx, timelines, author, document any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckThrottle", reflect.TypeOf((*MockTimelineService)(nil).CheckThrottle), ctx, timelines, author, document)
+}
+
 // Clean mocks base method.
 func (m *MockTimelineService) Clean(ctx context.Context, ccid string) error {
 	m.ctrl.T.Helper()
@@ -1786,6 +2649,52 @@ func (mr *MockTimelineServiceMockRecorder) DeleteTimeline(ctx, mode, document an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTimeline", reflect.TypeOf((*MockTimelineService)(nil).DeleteTimeline), ctx, mode, document)
 }
 
+// Discover mocks base method.
+func (m *MockTimelineService) Discover(ctx context.Context, query core.TimelineDiscoverQuery) ([]core.DiscoveredTimeline, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Discover", ctx, query)
+	ret0, _ := ret[0].([]core.DiscoveredTimeline)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Discover indicates an expected call of Discover.
+func (mr *MockTimelineServiceMockRecorder) Discover(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Discover", reflect.TypeOf((*MockTimelineService)(nil).Discover), ctx, query)
+}
+
+// EnsureFirehose mocks base method.
+func (m *MockTimelineService) EnsureFirehose(ctx context.Context) (core.Timeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureFirehose", ctx)
+	ret0, _ := ret[0].(core.Timeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnsureFirehose indicates an expected call of EnsureFirehose.
+func (mr *MockTimelineServiceMockRecorder) EnsureFirehose(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureFirehose", reflect.TypeOf((*MockTimelineService)(nil).EnsureFirehose), ctx)
+}
+
+// EnsureThread mocks base method.
+func (m *MockTimelineService) EnsureThread(ctx context.Context, rootID string) (core.Timeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureThread", ctx, rootID)
+	ret0, _ := ret[0].(core.Timeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnsureThread indicates an expected call of EnsureThread.
+func (mr *MockTimelineServiceMockRecorder) EnsureThread(ctx, rootID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureThread", reflect.TypeOf((*MockTimelineService)(nil).EnsureThread), ctx, rootID)
+}
+
 // Event mocks base method.
 func (m *MockTimelineService) Event(ctx context.Context, mode core.CommitMode, document, signature string) (core.Event, error) {
 	m.ctrl.T.Helper()
@@ -1801,6 +2710,50 @@ func (mr *MockTimelineServiceMockRecorder) Event(ctx, mode, document, signature
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Event", reflect.TypeOf((*MockTimelineService)(nil).Event), ctx, mode, document, signature)
 }
 
+// ExpireItems mocks base method.
+func (m *MockTimelineService) ExpireItems(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireItems", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireItems indicates an expected call of ExpireItems.
+func (mr *MockTimelineServiceMockRecorder) ExpireItems(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireItems", reflect.TypeOf((*MockTimelineService)(nil).ExpireItems), ctx)
+}
+
+// FilterReadable mocks base method.
+func (m *MockTimelineService) FilterReadable(ctx context.Context, timelines []string) ([]string, []string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterReadable", ctx, timelines)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].([]string)
+	return ret0, ret1
+}
+
+// FilterReadable indicates an expected call of FilterReadable.
+func (mr *MockTimelineServiceMockRecorder) FilterReadable(ctx, timelines any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterReadable", reflect.TypeOf((*MockTimelineService)(nil).FilterReadable), ctx, timelines)
+}
+
+// GetBridges mocks base method.
+func (m *MockTimelineService) GetBridges() []core.BridgeStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBridges")
+	ret0, _ := ret[0].([]core.BridgeStatus)
+	return ret0
+}
+
+// GetBridges indicates an expected call of GetBridges.
+func (mr *MockTimelineServiceMockRecorder) GetBridges() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBridges", reflect.TypeOf((*MockTimelineService)(nil).GetBridges))
+}
+
 // GetChunks mocks base method.
 func (m *MockTimelineService) GetChunks(ctx context.Context, timelines []string, epoch string) (map[string]core.Chunk, error) {
 	m.ctrl.T.Helper()
@@ -1861,6 +2814,20 @@ func (mr *MockTimelineServiceMockRecorder) GetItem(ctx, timeline, id any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItem", reflect.TypeOf((*MockTimelineService)(nil).GetItem), ctx, timeline, id)
 }
 
+// GetMetrics mocks base method.
+func (m *MockTimelineService) GetMetrics() map[string]int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetrics")
+	ret0, _ := ret[0].(map[string]int64)
+	return ret0
+}
+
+// GetMetrics indicates an expected call of GetMetrics.
+func (mr *MockTimelineServiceMockRecorder) GetMetrics() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetrics", reflect.TypeOf((*MockTimelineService)(nil).GetMetrics))
+}
+
 // GetOwners mocks base method.
 func (m *MockTimelineService) GetOwners(ctx context.Context, timelines []string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -2026,6 +2993,36 @@ func (mr *MockTimelineServiceMockRecorder) LookupChunkItr(ctx, timeliens, epoch
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupChunkItr", reflect.TypeOf((*MockTimelineService)(nil).LookupChunkItr), ctx, timeliens, epoch)
 }
 
+// Merge mocks base method.
+func (m *MockTimelineService) Merge(ctx context.Context, timeline, into, requester string) (core.Timeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, timeline, into, requester)
+	ret0, _ := ret[0].(core.Timeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockTimelineServiceMockRecorder) Merge(ctx, timeline, into, requester any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockTimelineService)(nil).Merge), ctx, timeline, into, requester)
+}
+
+// MigrateDomainTimelines mocks base method.
+func (m *MockTimelineService) MigrateDomainTimelines(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateDomainTimelines", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrateDomainTimelines indicates an expected call of MigrateDomainTimelines.
+func (mr *MockTimelineServiceMockRecorder) MigrateDomainTimelines(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateDomainTimelines", reflect.TypeOf((*MockTimelineService)(nil).MigrateDomainTimelines), ctx)
+}
+
 // NormalizeTimelineID mocks base method.
 func (m *MockTimelineService) NormalizeTimelineID(ctx context.Context, timeline string) (string, error) {
 	m.ctrl.T.Helper()
@@ -2085,6 +3082,20 @@ func (mr *MockTimelineServiceMockRecorder) Query(ctx, timelineID, schema, owner,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockTimelineService)(nil).Query), ctx, timelineID, schema, owner, author, until, limit)
 }
 
+// QueryStream mocks base method.
+func (m *MockTimelineService) QueryStream(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(core.TimelineItem) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryStream", ctx, timelineID, schema, owner, author, until, limit, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QueryStream indicates an expected call of QueryStream.
+func (mr *MockTimelineServiceMockRecorder) QueryStream(ctx, timelineID, schema, owner, author, until, limit, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryStream", reflect.TypeOf((*MockTimelineService)(nil).QueryStream), ctx, timelineID, schema, owner, author, until, limit, fn)
+}
+
 // Realtime mocks base method.
 func (m *MockTimelineService) Realtime(ctx context.Context, request <-chan []string, response chan<- core.Event) {
 	m.ctrl.T.Helper()
@@ -2111,6 +3122,21 @@ func (mr *MockTimelineServiceMockRecorder) RemoveItemsByResourceID(ctx, resource
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveItemsByResourceID", reflect.TypeOf((*MockTimelineService)(nil).RemoveItemsByResourceID), ctx, resourceID)
 }
 
+// ResolveAliases mocks base method.
+func (m *MockTimelineService) ResolveAliases(ctx context.Context, timelines []string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveAliases", ctx, timelines)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveAliases indicates an expected call of ResolveAliases.
+func (mr *MockTimelineServiceMockRecorder) ResolveAliases(ctx, timelines any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveAliases", reflect.TypeOf((*MockTimelineService)(nil).ResolveAliases), ctx, timelines)
+}
+
 // Retract mocks base method.
 func (m *MockTimelineService) Retract(ctx context.Context, mode core.CommitMode, document, signature string) (core.TimelineItem, []string, error) {
 	m.ctrl.T.Helper()
@@ -2140,18 +3166,33 @@ func (mr *MockTimelineServiceMockRecorder) UpdateMetrics() *gomock.Call {
 }
 
 // UpsertTimeline mocks base method.
-func (m *MockTimelineService) UpsertTimeline(ctx context.Context, mode core.CommitMode, document, signature string) (core.Timeline, error) {
+func (m *MockTimelineService) UpsertTimeline(ctx context.Context, mode core.CommitMode, document, signature, option string) (core.Timeline, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpsertTimeline", ctx, mode, document, signature)
+	ret := m.ctrl.Call(m, "UpsertTimeline", ctx, mode, document, signature, option)
 	ret0, _ := ret[0].(core.Timeline)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpsertTimeline indicates an expected call of UpsertTimeline.
-func (mr *MockTimelineServiceMockRecorder) UpsertTimeline(ctx, mode, document, signature any) *gomock.Call {
+func (mr *MockTimelineServiceMockRecorder) UpsertTimeline(ctx, mode, document, signature, option any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertTimeline", reflect.TypeOf((*MockTimelineService)(nil).UpsertTimeline), ctx, mode, document, signature, option)
+}
+
+// WarmChunkCache mocks base method.
+func (m *MockTimelineService) WarmChunkCache(ctx context.Context, epoch string, count int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WarmChunkCache", ctx, epoch, count)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WarmChunkCache indicates an expected call of WarmChunkCache.
+func (mr *MockTimelineServiceMockRecorder) WarmChunkCache(ctx, epoch, count any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertTimeline", reflect.TypeOf((*MockTimelineService)(nil).UpsertTimeline), ctx, mode, document, signature)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WarmChunkCache", reflect.TypeOf((*MockTimelineService)(nil).WarmChunkCache), ctx, epoch, count)
 }
 
 // MockJobService is a mock of JobService interface.
@@ -2275,6 +3316,35 @@ func (m *MockNotificationService) EXPECT() *MockNotificationServiceMockRecorder
 	return m.recorder
 }
 
+// Delete mocks base method.
+func (m *MockNotificationService) Delete(ctx context.Context, vendorID, owner string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, vendorID, owner)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockNotificationServiceMockRecorder) Delete(ctx, vendorID, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockNotificationService)(nil).Delete), ctx, vendorID, owner)
+}
+
+// Get mocks base method.
+func (m *MockNotificationService) Get(ctx context.Context, vendorID, owner string) (core.NotificationSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, vendorID, owner)
+	ret0, _ := ret[0].(core.NotificationSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockNotificationServiceMockRecorder) Get(ctx, vendorID, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockNotificationService)(nil).Get), ctx, vendorID, owner)
+}
+
 // GetAllSubscriptions mocks base method.
 func (m *MockNotificationService) GetAllSubscriptions(ctx context.Context) ([]core.NotificationSubscription, error) {
 	m.ctrl.T.Helper()
@@ -2290,6 +3360,21 @@ func (mr *MockNotificationServiceMockRecorder) GetAllSubscriptions(ctx any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSubscriptions", reflect.TypeOf((*MockNotificationService)(nil).GetAllSubscriptions), ctx)
 }
 
+// GetPreference mocks base method.
+func (m *MockNotificationService) GetPreference(ctx context.Context, owner string) (core.NotificationPreference, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPreference", ctx, owner)
+	ret0, _ := ret[0].(core.NotificationPreference)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreference indicates an expected call of GetPreference.
+func (mr *MockNotificationServiceMockRecorder) GetPreference(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreference", reflect.TypeOf((*MockNotificationService)(nil).GetPreference), ctx, owner)
+}
+
 // Subscribe mocks base method.
 func (m *MockNotificationService) Subscribe(ctx context.Context, notification core.NotificationSubscription) (core.NotificationSubscription, error) {
 	m.ctrl.T.Helper()
@@ -2304,3 +3389,165 @@ func (mr *MockNotificationServiceMockRecorder) Subscribe(ctx, notification any)
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockNotificationService)(nil).Subscribe), ctx, notification)
 }
+
+// UpdatePreference mocks base method.
+func (m *MockNotificationService) UpdatePreference(ctx context.Context, preference core.NotificationPreference) (core.NotificationPreference, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePreference", ctx, preference)
+	ret0, _ := ret[0].(core.NotificationPreference)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePreference indicates an expected call of UpdatePreference.
+func (mr *MockNotificationServiceMockRecorder) UpdatePreference(ctx, preference any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePreference", reflect.TypeOf((*MockNotificationService)(nil).UpdatePreference), ctx, preference)
+}
+
+// MockMuteService is a mock of MuteService interface.
+type MockMuteService struct {
+	ctrl     *gomock.Controller
+	recorder *MockMuteServiceMockRecorder
+}
+
+// MockMuteServiceMockRecorder is the mock recorder for MockMuteService.
+type MockMuteServiceMockRecorder struct {
+	mock *MockMuteService
+}
+
+// NewMockMuteService creates a new mock instance.
+func NewMockMuteService(ctrl *gomock.Controller) *MockMuteService {
+	mock := &MockMuteService{ctrl: ctrl}
+	mock.recorder = &MockMuteServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMuteService) EXPECT() *MockMuteServiceMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockMuteService) Create(ctx context.Context, rule core.MuteRule) (core.MuteRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, rule)
+	ret0, _ := ret[0].(core.MuteRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockMuteServiceMockRecorder) Create(ctx, rule any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockMuteService)(nil).Create), ctx, rule)
+}
+
+// Delete mocks base method.
+func (m *MockMuteService) Delete(ctx context.Context, owner string, id uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockMuteServiceMockRecorder) Delete(ctx, owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMuteService)(nil).Delete), ctx, owner, id)
+}
+
+// Filter mocks base method.
+func (m *MockMuteService) Filter(ctx context.Context, owner string) (core.MuteFilter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Filter", ctx, owner)
+	ret0, _ := ret[0].(core.MuteFilter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Filter indicates an expected call of Filter.
+func (mr *MockMuteServiceMockRecorder) Filter(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Filter", reflect.TypeOf((*MockMuteService)(nil).Filter), ctx, owner)
+}
+
+// List mocks base method.
+func (m *MockMuteService) List(ctx context.Context, owner string) ([]core.MuteRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, owner)
+	ret0, _ := ret[0].([]core.MuteRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockMuteServiceMockRecorder) List(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockMuteService)(nil).List), ctx, owner)
+}
+
+// MockMuteFilter is a mock of MuteFilter interface.
+type MockMuteFilter struct {
+	ctrl     *gomock.Controller
+	recorder *MockMuteFilterMockRecorder
+}
+
+// MockMuteFilterMockRecorder is the mock recorder for MockMuteFilter.
+type MockMuteFilterMockRecorder struct {
+	mock *MockMuteFilter
+}
+
+// NewMockMuteFilter creates a new mock instance.
+func NewMockMuteFilter(ctrl *gomock.Controller) *MockMuteFilter {
+	mock := &MockMuteFilter{ctrl: ctrl}
+	mock.recorder = &MockMuteFilterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMuteFilter) EXPECT() *MockMuteFilterMockRecorder {
+	return m.recorder
+}
+
+// Empty mocks base method.
+func (m *MockMuteFilter) Empty() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Empty")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Empty indicates an expected call of Empty.
+func (mr *MockMuteFilterMockRecorder) Empty() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Empty", reflect.TypeOf((*MockMuteFilter)(nil).Empty))
+}
+
+// MutedEvent mocks base method.
+func (m *MockMuteFilter) MutedEvent(ctx context.Context, event core.Event) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MutedEvent", ctx, event)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// MutedEvent indicates an expected call of MutedEvent.
+func (mr *MockMuteFilterMockRecorder) MutedEvent(ctx, event any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MutedEvent", reflect.TypeOf((*MockMuteFilter)(nil).MutedEvent), ctx, event)
+}
+
+// MutedItems mocks base method.
+func (m *MockMuteFilter) MutedItems(ctx context.Context, items []core.TimelineItem) map[string]bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MutedItems", ctx, items)
+	ret0, _ := ret[0].(map[string]bool)
+	return ret0
+}
+
+// MutedItems indicates an expected call of MutedItems.
+func (mr *MockMuteFilterMockRecorder) MutedItems(ctx, items any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MutedItems", reflect.TypeOf((*MockMuteFilter)(nil).MutedItems), ctx, items)
+}
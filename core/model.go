@@ -11,6 +11,7 @@ type Event struct {
 	Resource  any           `json:"resource,omitempty"`
 	Document  string        `json:"document"`
 	Signature string        `json:"signature"`
+	Seq       int64         `json:"seq,omitempty"` // sequence number on websocket protocol v2 sessions
 }
 
 type Chunk struct {
@@ -26,6 +27,24 @@ type RequestContext struct {
 	Self            any
 	Resource        any
 	Params          map[string]any
+	Spam            *SpamVerdict // verdict on the committed document, nil if it was not inspected
+	Owned           int64        // resources of the created kind the requester already owns, on create actions
+	// names of the verified badges of the requester. filled from the request when the requester made it
+	RequesterBadges []string
+}
+
+const (
+	SpamLabelHam        = "ham"
+	SpamLabelSuspicious = "suspicious"
+	SpamLabelSpam       = "spam"
+)
+
+// SpamVerdict is how likely a document is spam. Score is between 0 and 1,
+// Details holds the score of each detector
+type SpamVerdict struct {
+	Score   float64            `json:"score"`
+	Label   string             `json:"label"`
+	Details map[string]float64 `json:"details,omitempty"`
 }
 
 type PolicyDocument struct {
@@ -67,6 +86,15 @@ type Config struct {
 	Dimension    string `yaml:"dimension"`
 	CCID         string `yaml:"ccid"`
 	CSID         string `yaml:"csid"`
+	// PreviousCSID is the CSID before the latest rotation. peers accept its signatures until PreviousCSIDUntil
+	PreviousCSID      string    `yaml:"previousCSID"`
+	PreviousCSIDUntil time.Time `yaml:"previousCSIDUntil"`
+	// OwnershipLimits bound the timelines and subscriptions an entity may create, by tag
+	OwnershipLimits OwnershipLimits `yaml:"ownershipLimits"`
+	// EntityTags are the tags the admin may give to entities, besides BuiltinEntityTags
+	EntityTags TagTaxonomy `yaml:"entityTags"`
+	// ThreadTimelines delivers the public replies to the messages of the domain to a timeline per conversation
+	ThreadTimelines bool `yaml:"threadTimelines"`
 }
 
 type ConfigInput struct {
@@ -75,6 +103,13 @@ type ConfigInput struct {
 	Registration string `yaml:"registration"` // open, invite, close
 	SiteKey      string `yaml:"sitekey"`
 	Dimension    string `yaml:"dimension"`
+	// set while the CSID is rotated, see GET /domain/keys
+	PreviousCSID      string    `yaml:"previousCSID"`
+	PreviousCSIDUntil time.Time `yaml:"previousCSIDUntil"`
+	// keyed by entity tag, DEFAULT for the others. 0 means unlimited
+	OwnershipLimits OwnershipLimits `yaml:"ownershipLimits"`
+	EntityTags      TagTaxonomy     `yaml:"entityTags"`
+	ThreadTimelines bool            `yaml:"threadTimelines"`
 }
 
 type SyncStatus struct {
@@ -92,3 +127,153 @@ type RateLimitConfig struct {
 }
 
 type RateLimitConfigMap map[string]RateLimitConfig
+
+// EntityListQuery is a filter for listing entities.
+// zero values are ignored. Cursor is an opaque keyset pagination token returned by the previous page.
+type EntityListQuery struct {
+	Domain   string
+	Tag      string
+	MinScore *int
+	MaxScore *int
+	Since    *time.Time
+	Until    *time.Time
+	Order    string // cdate, -cdate, score, -score
+	Cursor   string
+	Limit    int
+}
+
+// TimelineDiscoverQuery is a filter for discovering indexable timelines.
+// Cursor is an opaque pagination token returned by the previous page.
+type TimelineDiscoverQuery struct {
+	Schemas   []string
+	Cursor    string
+	Limit     int
+	Federated bool // also include timelines of known remote domains
+}
+
+// DiscoveredTimeline is a timeline with the statistics it is ranked by
+type DiscoveredTimeline struct {
+	Timeline
+	Activity    int64 `json:"activity"` // items posted recently
+	Subscribers int64 `json:"subscribers"`
+}
+
+// ThreadQuery controls how much of a reply tree is resolved.
+// zero values fall back to the defaults of the service.
+type ThreadQuery struct {
+	Depth     int    // levels of replies below the message
+	Limit     int    // replies in the whole tree
+	Requester string // ccid the read access is checked for. empty means guest
+}
+
+// ThreadNode is a message of a reply tree with the replies to it
+type ThreadNode struct {
+	Message   Message       `json:"message"`
+	Replies   []*ThreadNode `json:"replies,omitempty"`
+	Truncated bool          `json:"truncated,omitempty"` // some replies were left out by depth or limit
+}
+
+// Thread is the reply tree of a message with the chain of messages it replies to
+type Thread struct {
+	Ancestors     []Message   `json:"ancestors"` // the root of the conversation first
+	Message       *ThreadNode `json:"message"`
+	MissingParent string      `json:"missingParent,omitempty"` // parent which could not be resolved
+}
+
+// KeySession is a subkey of the requester with its usage, as listed for the session management.
+// the key was created at its ValidSince, and LastUsed is nil until it signs a request
+type KeySession struct {
+	Key
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+	UserAgent string     `json:"userAgent,omitempty"`
+}
+
+// FederationStats is the observed quality of requests to a remote domain over the recent window
+type FederationStats struct {
+	Domain      string     `json:"domain"`
+	Requests    int64      `json:"requests"`
+	Failures    int64      `json:"failures"`
+	SuccessRate float64    `json:"successRate"`
+	AvgLatency  float64    `json:"avgLatency"` // milliseconds
+	MaxLatency  float64    `json:"maxLatency"` // milliseconds
+	State       string     `json:"state"`      // online, offline or probing
+	OpenUntil   *time.Time `json:"openUntil,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastSeen    time.Time  `json:"lastSeen"`
+}
+
+// DomainKeyStatus is the state of the CSID rotation of the domain
+type DomainKeyStatus struct {
+	CSID              string               `json:"csid"`
+	PreviousCSID      string               `json:"previousCSID,omitempty"`
+	PreviousCSIDUntil *time.Time           `json:"previousCSIDUntil,omitempty"`
+	State             string               `json:"state"` // single, overlap, or retired once previousCSID can be removed
+	Announcements     []DomainAnnouncement `json:"announcements"`
+}
+
+// DomainPeer is the state of the federation with a remote domain, for the admin
+type DomainPeer struct {
+	Domain        Domain           `json:"domain"`
+	Blocked       bool             `json:"blocked"`  // tagged _block: its users and passports are refused
+	Silenced      bool             `json:"silenced"` // tagged _silenced: read by the policies with RequesterDomainHasTag
+	Settings      DomainSettings   `json:"settings"`
+	Entities      int64            `json:"entities"`                // entities of the domain known here
+	LastFetched   *time.Time       `json:"lastFetched,omitempty"`   // when the domain was last fetched from itself
+	LastDelivered *time.Time       `json:"lastDelivered,omitempty"` // when the domain last accepted a message relayed to it
+	Federation    *FederationStats `json:"federation,omitempty"`    // absent unless the domain was contacted recently
+	Bridge        *BridgeStatus    `json:"bridge,omitempty"`        // absent unless its timelines are relayed here
+}
+
+// DomainSettingsUpdate changes the settings of a remote domain. absent fields are kept
+type DomainSettingsUpdate struct {
+	Blocked        *bool    `json:"blocked,omitempty"`
+	Silenced       *bool    `json:"silenced,omitempty"`
+	RateLimitScale *float64 `json:"rateLimitScale,omitempty"`
+	Note           *string  `json:"note,omitempty"`
+}
+
+// DomainAnnouncement is the result of telling a peer about the keys of the domain with hello
+type DomainAnnouncement struct {
+	Domain       string    `json:"domain"`
+	Acknowledged bool      `json:"acknowledged"` // the peer holds the current CSID now
+	Error        string    `json:"error,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// DomainKeyRotation is a new key generated for the domain, with the values of the config applying it
+type DomainKeyRotation struct {
+	PrivateKey        string    `json:"privatekey"`
+	CSID              string    `json:"csid"`
+	CCID              string    `json:"ccid"`
+	PreviousCSID      string    `json:"previousCSID"`
+	PreviousCSIDUntil time.Time `json:"previousCSIDUntil"`
+}
+
+const (
+	AliasStatusVerified         = "verified"
+	AliasStatusMissingRecord    = "missing_record"
+	AliasStatusCCIDMismatch     = "ccid_mismatch"
+	AliasStatusInvalidSignature = "invalid_signature"
+)
+
+// AliasStatus is the progress of verifying an alias. the alias is proven by a TXT record at Record
+// holding "ccid=<ccid>" and "sig=<signature of the alias by the ccid>"
+type AliasStatus struct {
+	Alias  string `json:"alias"`
+	Record string `json:"record"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"` // what is wrong with the record, unless verified
+}
+
+// BridgeStatus is the state of the websocket connection relaying the events of a remote domain.
+// State is "connected", "connecting" or "backoff"; in backoff the next attempt is made after NextRetry
+type BridgeStatus struct {
+	Domain        string     `json:"domain"`
+	State         string     `json:"state"`
+	Timelines     int        `json:"timelines"`
+	Attempts      int        `json:"attempts"` // failures since the last successful connection
+	LastError     string     `json:"lastError,omitempty"`
+	ConnectedAt   *time.Time `json:"connectedAt,omitempty"`
+	LastHeartbeat *time.Time `json:"lastHeartbeat,omitempty"`
+	NextRetry     *time.Time `json:"nextRetry,omitempty"`
+}
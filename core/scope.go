@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scopes is the list of actions a scoped token is allowed to perform.
+// each scope is "action" or "action:target". an action ending with ".*" matches every action under it and "*" matches all.
+type Scopes []string
+
+func ParseScopes(input string) Scopes {
+	scopes := Scopes{}
+	for _, scope := range strings.Split(input, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func (s Scopes) ToString() string {
+	return strings.Join(s, ",")
+}
+
+// ValidateScope checks that the scope can be stored and propagated as is
+func ValidateScope(scope string) error {
+	action, _, _ := strings.Cut(scope, ":")
+	if action == "" {
+		return fmt.Errorf("empty action in scope: %s", scope)
+	}
+	if strings.ContainsAny(scope, ", \t\n") {
+		return fmt.Errorf("invalid character in scope: %s", scope)
+	}
+	return nil
+}
+
+func matchAction(pattern, action string) bool {
+	if pattern == "*" || pattern == action {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, ".*"); ok {
+		return strings.HasPrefix(action, prefix+".")
+	}
+	return false
+}
+
+// Allows reports whether the action is allowed on every target.
+// without targets, only scopes which are not limited to a target match.
+func (s Scopes) Allows(action string, targets ...string) bool {
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+
+	for _, target := range targets {
+		allowed := false
+		for _, scope := range s {
+			pattern, limit, limited := strings.Cut(scope, ":")
+			if !matchAction(pattern, action) {
+				continue
+			}
+			if !limited || (target != "" && limit == target) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
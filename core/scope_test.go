@@ -0,0 +1,26 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScopes(t *testing.T) {
+	scopes := ParseScopes("message.create:tl1@example.com, association.*")
+	assert.Equal(t, Scopes{"message.create:tl1@example.com", "association.*"}, scopes)
+	assert.Equal(t, "message.create:tl1@example.com,association.*", scopes.ToString())
+
+	assert.True(t, scopes.Allows("message.create", "tl1@example.com"))
+	assert.False(t, scopes.Allows("message.create", "tl1@example.com", "tl2@example.com"))
+	assert.False(t, scopes.Allows("message.create"))
+	assert.True(t, scopes.Allows("association.create", "anything"))
+	assert.True(t, scopes.Allows("association.delete"))
+	assert.False(t, scopes.Allows("profile.upsert"))
+
+	assert.True(t, Scopes{"*"}.Allows("timeline.upsert"))
+	assert.False(t, Scopes{}.Allows("timeline.upsert"))
+
+	assert.NoError(t, ValidateScope("message.create:tl1@example.com"))
+	assert.Error(t, ValidateScope(":tl1"))
+	assert.Error(t, ValidateScope("message.create,profile.upsert"))
+}
@@ -1,8 +1,10 @@
 package core
 
 import (
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Tags struct {
@@ -69,3 +71,94 @@ func (t *Tags) GetAsInt(key string) (int, bool) {
 	}
 	return result, true
 }
+
+// TagDefinition describes an entity tag the admin may give
+type TagDefinition struct {
+	Description string `yaml:"description" json:"description"`
+	// the tag takes a value, as in "name:value"
+	Valued bool `yaml:"valued" json:"valued"`
+	// the tag must expire within this duration. 0 allows permanent tags
+	MaxDuration time.Duration `yaml:"maxDuration" json:"maxDuration,omitempty"`
+}
+
+// TagTaxonomy is the set of entity tags the admin may give, keyed by name
+type TagTaxonomy map[string]TagDefinition
+
+// BuiltinEntityTags are the tags read by the domain itself. they are allowed whatever the configured taxonomy is
+var BuiltinEntityTags = TagTaxonomy{
+	"_admin":    {Description: "administrator of the domain"},
+	"_block":    {Description: "suspended. denied by the global policy"},
+	"_invite":   {Description: "may invite new users"},
+	"_silenced": {Description: "silenced by a moderator, usually for a while. read by the policies with RequesterHasTag"},
+}
+
+// Lookup returns the definition of the tag, from the built-in tags first
+func (t TagTaxonomy) Lookup(name string) (TagDefinition, bool) {
+	if definition, ok := BuiltinEntityTags[name]; ok {
+		return definition, true
+	}
+	definition, ok := t[name]
+	return definition, ok
+}
+
+// All returns the configured tags along with the built-in ones
+func (t TagTaxonomy) All() TagTaxonomy {
+	all := make(TagTaxonomy, len(t)+len(BuiltinEntityTags))
+	for name, definition := range t {
+		all[name] = definition
+	}
+	for name, definition := range BuiltinEntityTags {
+		all[name] = definition
+	}
+	return all
+}
+
+// Validate checks the tag against its definition
+func (t TagTaxonomy) Validate(tag EntityTag, now time.Time) error {
+	definition, ok := t.Lookup(tag.Name)
+	if !ok {
+		return NewErrorInvalidArgument("unknown tag: " + tag.Name)
+	}
+	if strings.ContainsAny(tag.Name, ",:") || strings.ContainsAny(tag.Value, ",:") {
+		return NewErrorInvalidArgument("tag names and values must not contain ',' or ':'")
+	}
+	if !definition.Valued && tag.Value != "" {
+		return NewErrorInvalidArgument("tag " + tag.Name + " takes no value")
+	}
+	if tag.ExpiresAt != nil && !tag.ExpiresAt.After(now) {
+		return NewErrorInvalidArgument("tag " + tag.Name + " is already expired")
+	}
+	if definition.MaxDuration > 0 && (tag.ExpiresAt == nil || tag.ExpiresAt.Sub(now) > definition.MaxDuration) {
+		return NewErrorInvalidArgument("tag " + tag.Name + " must expire within " + definition.MaxDuration.String())
+	}
+	return nil
+}
+
+// JoinEntityTags renders the tags in the form of Entity.Tag, sorted by name
+func JoinEntityTags(tags []EntityTag) string {
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		parts[i] = tag.Name
+		if tag.Value != "" {
+			parts[i] += ":" + tag.Value
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// HasTag tells whether the entity has the tag. the structured tags are read when they are loaded,
+// so that an expired tag is not honored while Entity.Tag is yet to be updated
+func (e Entity) HasTag(name string) bool {
+	if e.Tags == nil {
+		tags := ParseTags(e.Tag)
+		return tags.Has(name)
+	}
+	now := time.Now()
+	for _, tag := range e.Tags {
+		if tag.Name == name && (tag.ExpiresAt == nil || tag.ExpiresAt.After(now)) {
+			return true
+		}
+	}
+	return false
+}
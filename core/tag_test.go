@@ -3,6 +3,7 @@ package core
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestTags(t *testing.T) {
@@ -30,3 +31,46 @@ func TestTags(t *testing.T) {
 	tags3.Remove("key1")
 	assert.Equal(t, false, tags3.Has("key1"))
 }
+
+func TestTagTaxonomy(t *testing.T) {
+	now := time.Now()
+	in := func(d time.Duration) *time.Time {
+		at := now.Add(d)
+		return &at
+	}
+
+	taxonomy := TagTaxonomy{
+		"tier":  {Valued: true},
+		"trial": {MaxDuration: 24 * time.Hour},
+	}
+
+	assert.NoError(t, taxonomy.Validate(EntityTag{Name: "_silenced", ExpiresAt: in(time.Hour)}, now))
+	assert.NoError(t, taxonomy.Validate(EntityTag{Name: "tier", Value: "gold"}, now))
+	assert.NoError(t, taxonomy.Validate(EntityTag{Name: "trial", ExpiresAt: in(time.Hour)}, now))
+
+	assert.ErrorIs(t, taxonomy.Validate(EntityTag{Name: "unknown"}, now), ErrorInvalidArgument{})
+	assert.ErrorIs(t, taxonomy.Validate(EntityTag{Name: "_block", Value: "1"}, now), ErrorInvalidArgument{})
+	assert.ErrorIs(t, taxonomy.Validate(EntityTag{Name: "tier", Value: "a,b"}, now), ErrorInvalidArgument{})
+	assert.ErrorIs(t, taxonomy.Validate(EntityTag{Name: "trial"}, now), ErrorInvalidArgument{})
+	assert.ErrorIs(t, taxonomy.Validate(EntityTag{Name: "trial", ExpiresAt: in(48 * time.Hour)}, now), ErrorInvalidArgument{})
+	assert.ErrorIs(t, taxonomy.Validate(EntityTag{Name: "_silenced", ExpiresAt: in(-time.Hour)}, now), ErrorInvalidArgument{})
+
+	assert.Equal(t, "_admin,tier:gold", JoinEntityTags([]EntityTag{{Name: "tier", Value: "gold"}, {Name: "_admin"}}))
+}
+
+func TestEntityHasTag(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+
+	// the joined tags still list the expired one until it is removed
+	entity := Entity{
+		Tag:  "_admin,_silenced",
+		Tags: []EntityTag{{Name: "_admin"}, {Name: "_silenced", ExpiresAt: &expired}},
+	}
+	assert.True(t, entity.HasTag("_admin"))
+	assert.False(t, entity.HasTag("_silenced"))
+
+	// without the structured tags, the joined ones are read
+	entity = Entity{Tag: "_admin"}
+	assert.True(t, entity.HasTag("_admin"))
+	assert.False(t, entity.HasTag("_block"))
+}
@@ -0,0 +1,39 @@
+// Package coalesce shares one fetch among the concurrent callers asking for the same key,
+// so that a burst of requests for an uncached resource reaches its origin once
+package coalesce
+
+import (
+	"context"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Group coalesces the fetches of values of type T. the zero value is ready to use
+type Group[T any] struct {
+	group singleflight.Group
+}
+
+// Do calls fetch once for the concurrent calls with the same key and returns its result to all of them.
+// the fetch is detached from the cancellation of the caller which started it, keeping only its deadline,
+// so that a caller giving up doesn't fail the others. every caller still returns once its own ctx is done.
+// shared reports whether the result was handed to other callers as well, in which case it must not be modified
+func (g *Group[T]) Do(ctx context.Context, key string, fetch func(ctx context.Context) (T, error)) (T, bool, error) {
+	ch := g.group.DoChan(key, func() (any, error) {
+		detached := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			detached, cancel = context.WithDeadline(detached, deadline)
+			defer cancel()
+		}
+		return fetch(detached)
+	})
+
+	select {
+	case result := <-ch:
+		value, _ := result.Val.(T)
+		return value, result.Shared, result.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, false, ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	var group Group[string]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	fetch := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value", ctx.Err()
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, err := group.Do(context.Background(), "key", fetch)
+			assert.NoError(t, err)
+			results[i] = value
+		}()
+	}
+
+	// the first caller gives up, which must not fail the others waiting for the same fetch
+	cancelled, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := group.Do(cancelled, "key", fetch)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, value := range results {
+		assert.Equal(t, "value", value)
+	}
+
+	// the key is fetched again once the previous fetch finished
+	value, shared, err := group.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+		return "again", nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, shared)
+	assert.Equal(t, "again", value)
+}
@@ -0,0 +1,138 @@
+// Package database opens the postgres connection of the binaries with the pool and statement settings of the config,
+// instead of the defaults of gorm and database/sql
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Config tunes the connection pool and the statements. zero values keep the defaults
+type Config struct {
+	// MaxOpenConns and MaxIdleConns bound the connections of a pool. each tenant of the api has its own pool
+	MaxOpenConns int `yaml:"maxOpenConns"`
+	MaxIdleConns int `yaml:"maxIdleConns"`
+	// connections are closed after ConnMaxLifetime, or after being idle for ConnMaxIdleTime
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+	// PrepareStmt caches the statements gorm prepares, and reuses them on every connection
+	PrepareStmt bool `yaml:"prepareStmt"`
+	// QueryExecMode is how pgx executes queries: cache_statement (default), cache_describe, describe_exec,
+	// exec or simple_protocol. exec or simple_protocol are needed behind pgbouncer in transaction mode
+	QueryExecMode string `yaml:"queryExecMode"`
+	// StatementCacheCapacity is the number of statements pgx caches per connection
+	StatementCacheCapacity int `yaml:"statementCacheCapacity"`
+	// StatementTimeout aborts the statements running longer than it on the server
+	StatementTimeout time.Duration `yaml:"statementTimeout"`
+	// Timeouts bounds the statements with a context deadline and fails them with core.ErrorTimeout.
+	// keys are the operations of gorm (query, create, update, delete, row or raw), optionally narrowed
+	// to a table as operation:table. default applies to the operations not listed
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
+	// SearchPath is the postgres schema of the tenant the connections are opened for. gorm prefixes the tables
+	// it builds with the schema, and the search path resolves the tables named in raw sql to the same schema
+	SearchPath string `yaml:"-"`
+}
+
+var queryExecModes = []string{"cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol"}
+
+// Open connects to postgres with the dsn and applies the config to the connection and its pool
+func Open(dsn string, config Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	dsn, err := withParams(dsn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if gormConfig == nil {
+		gormConfig = &gorm.Config{}
+	}
+	gormConfig.PrepareStmt = config.PrepareStmt
+
+	db, err := gorm.Open(postgres.Open(dsn), gormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Use(&durabilityPlugin{})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Timeouts) > 0 {
+		err = db.Use(&timeoutPlugin{timeouts: config.Timeouts})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+
+	return db, nil
+}
+
+// withParams adds the settings pgx reads from the connection string to the dsn, which is either
+// a postgres:// url or a list of key=value pairs
+func withParams(dsn string, config Config) (string, error) {
+	params := [][2]string{}
+	if config.QueryExecMode != "" {
+		valid := false
+		for _, mode := range queryExecModes {
+			valid = valid || mode == config.QueryExecMode
+		}
+		if !valid {
+			return "", fmt.Errorf("unknown queryExecMode %q", config.QueryExecMode)
+		}
+		params = append(params, [2]string{"default_query_exec_mode", config.QueryExecMode})
+	}
+	if config.StatementCacheCapacity > 0 {
+		params = append(params, [2]string{"statement_cache_capacity", strconv.Itoa(config.StatementCacheCapacity)})
+	}
+	if config.StatementTimeout > 0 {
+		// a runtime parameter sent to the server when the connection is made, in milliseconds
+		params = append(params, [2]string{"statement_timeout", strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)})
+	}
+	if config.SearchPath != "" {
+		params = append(params, [2]string{"search_path", fmt.Sprintf("%q", config.SearchPath)})
+	}
+	if len(params) == 0 {
+		return dsn, nil
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("invalid dsn: %w", err)
+		}
+		query := u.Query()
+		for _, param := range params {
+			query.Set(param[0], param[1])
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	for _, param := range params {
+		dsn += " " + param[0] + "=" + param[1]
+	}
+	return strings.TrimSpace(dsn), nil
+}
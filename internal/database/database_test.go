@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWithParams(t *testing.T) {
+	config := Config{QueryExecMode: "exec", StatementTimeout: 5 * time.Second}
+
+	dsn, err := withParams("host=db user=postgres dbname=concurrent", config)
+	assert.NoError(t, err)
+	assert.Equal(t, "host=db user=postgres dbname=concurrent default_query_exec_mode=exec statement_timeout=5000", dsn)
+
+	dsn, err = withParams("postgres://postgres@db:5432/concurrent?sslmode=disable", config)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://postgres@db:5432/concurrent?default_query_exec_mode=exec&sslmode=disable&statement_timeout=5000", dsn)
+
+	dsn, err = withParams("host=db", Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, "host=db", dsn)
+
+	dsn, err = withParams("host=db", Config{SearchPath: "tenant"})
+	assert.NoError(t, err)
+	assert.Equal(t, `host=db search_path="tenant"`, dsn)
+
+	_, err = withParams("host=db", Config{QueryExecMode: "prepared"})
+	assert.Error(t, err)
+}
+
+func TestTimeoutLookup(t *testing.T) {
+	plugin := &timeoutPlugin{timeouts: map[string]time.Duration{
+		"default":        5 * time.Second,
+		"query":          3 * time.Second,
+		"query:messages": time.Second,
+		"raw":            0,
+	}}
+
+	assert.Equal(t, time.Second, plugin.timeout("query", "messages"))
+	assert.Equal(t, 3*time.Second, plugin.timeout("query", "entities"))
+	assert.Equal(t, 3*time.Second, plugin.timeout("query", ""))
+	assert.Equal(t, 5*time.Second, plugin.timeout("create", "messages"))
+	// explicitly unbounded
+	assert.Equal(t, time.Duration(0), plugin.timeout("raw", ""))
+}
+
+func TestWithoutTimeout(t *testing.T) {
+	plugin := &timeoutPlugin{timeouts: map[string]time.Duration{"default": time.Second}}
+
+	bounded := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: context.Background()}}
+	plugin.before("row")(bounded)
+	_, ok := bounded.Statement.Context.Deadline()
+	assert.True(t, ok)
+
+	exempt := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: context.Background()}}
+	exempt.Statement.Settings.Store(skipTimeoutKey, true)
+	plugin.before("row")(exempt)
+	_, ok = exempt.Statement.Context.Deadline()
+	assert.False(t, ok)
+}
+
+// recordingTx records the statements executed on it
+type recordingTx struct {
+	gorm.ConnPool
+	executed []string
+}
+
+func (tx *recordingTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tx.executed = append(tx.executed, query)
+	return nil, nil
+}
+
+func (tx *recordingTx) Commit() error   { return nil }
+func (tx *recordingTx) Rollback() error { return nil }
+
+func TestAsyncCommit(t *testing.T) {
+	plugin := &durabilityPlugin{}
+
+	tx := &recordingTx{}
+	async := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: WithAsyncCommit(context.Background()), ConnPool: tx}}
+	plugin.apply(async)
+	assert.Equal(t, []string{"SET LOCAL synchronous_commit TO OFF"}, tx.executed)
+
+	tx = &recordingTx{}
+	sync := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: context.Background(), ConnPool: tx}}
+	plugin.apply(sync)
+	assert.Empty(t, tx.executed)
+}
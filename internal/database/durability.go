@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type asyncCommitKey struct{}
+
+// WithAsyncCommit makes the transactions of the writes with the context return before postgres flushed them
+// to its WAL. a crash may lose them, but never leaves the database inconsistent
+func WithAsyncCommit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, asyncCommitKey{}, true)
+}
+
+// durabilityPlugin turns synchronous_commit off in the transactions of the writes with WithAsyncCommit.
+// SET LOCAL only lasts for the transaction, so the other writes on the connection keep the default
+type durabilityPlugin struct{}
+
+func (p *durabilityPlugin) Name() string {
+	return "concrnt:durability"
+}
+
+func (p *durabilityPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().After("gorm:begin_transaction").Before("gorm:create").Register("concrnt:durability_create", p.apply),
+		callbacks.Update().After("gorm:begin_transaction").Before("gorm:update").Register("concrnt:durability_update", p.apply),
+		callbacks.Delete().After("gorm:begin_transaction").Before("gorm:delete").Register("concrnt:durability_delete", p.apply),
+	)
+}
+
+func (p *durabilityPlugin) apply(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if db.Error != nil || ctx == nil {
+		return
+	}
+	if async, _ := ctx.Value(asyncCommitKey{}).(bool); !async {
+		return
+	}
+	// outside of a transaction the setting would not apply to the write
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); !ok {
+		return
+	}
+	_, err := db.Statement.ConnPool.ExecContext(ctx, "SET LOCAL synchronous_commit TO OFF")
+	if err != nil {
+		db.AddError(err)
+	}
+}
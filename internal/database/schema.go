@@ -0,0 +1,30 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// OpenSchema is Open for the tables of the tenant in the postgres schema: gorm prefixes the tables it builds
+// with the schema, and the search path of the connections resolves the tables of raw sql to it as well.
+// an empty schema is the default one of the domain
+func OpenSchema(dsn string, config Config, gormConfig *gorm.Config, schema string) (*gorm.DB, error) {
+	if gormConfig == nil {
+		gormConfig = &gorm.Config{}
+	}
+	if schema != "" {
+		gormConfig.NamingStrategy = gormschema.NamingStrategy{TablePrefix: schema + "."}
+		config.SearchPath = schema
+	}
+	return Open(dsn, config, gormConfig)
+}
+
+// CreateSchema creates the postgres schema of a tenant unless it exists. the default schema always exists
+func CreateSchema(db *gorm.DB, schema string) error {
+	if schema == "" {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %q", schema)).Error
+}
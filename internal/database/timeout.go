@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	timeoutInstanceKey = "concrnt:timeout"
+	skipTimeoutKey     = "concrnt:timeout:skip"
+)
+
+// WithoutTimeout exempts the statements of db from the Timeouts of the config. for the streams whose rows are
+// read for longer than a statement may take; the caller bounds them with a deadline of its own
+func WithoutTimeout(db *gorm.DB) *gorm.DB {
+	return db.Set(skipTimeoutKey, true)
+}
+
+// timeoutPlugin bounds each statement of the repositories with a context deadline, so that a slow query
+// fails its request with core.ErrorTimeout instead of holding it open. it is enforced by the client,
+// unlike StatementTimeout, so it also covers the wait for a connection of the pool
+type timeoutPlugin struct {
+	timeouts map[string]time.Duration
+}
+
+type statementDeadline struct {
+	parent  context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+func (p *timeoutPlugin) Name() string {
+	return "concrnt:timeout"
+}
+
+func (p *timeoutPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Query().Before("gorm:query").Register("concrnt:timeout_before_query", p.before("query")),
+		callbacks.Query().After("gorm:query").Register("concrnt:timeout_after_query", p.after("query")),
+		callbacks.Create().Before("gorm:create").Register("concrnt:timeout_before_create", p.before("create")),
+		callbacks.Create().After("gorm:create").Register("concrnt:timeout_after_create", p.after("create")),
+		callbacks.Update().Before("gorm:update").Register("concrnt:timeout_before_update", p.before("update")),
+		callbacks.Update().After("gorm:update").Register("concrnt:timeout_after_update", p.after("update")),
+		callbacks.Delete().Before("gorm:delete").Register("concrnt:timeout_before_delete", p.before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("concrnt:timeout_after_delete", p.after("delete")),
+		callbacks.Row().Before("gorm:row").Register("concrnt:timeout_before_row", p.before("row")),
+		callbacks.Row().After("gorm:row").Register("concrnt:timeout_after_row", p.after("row")),
+		callbacks.Raw().Before("gorm:raw").Register("concrnt:timeout_before_raw", p.before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("concrnt:timeout_after_raw", p.after("raw")),
+	)
+}
+
+// timeout returns the deadline of the operation on the table: operation:table is looked up first,
+// then operation, then default. 0 means the statement is not bounded
+func (p *timeoutPlugin) timeout(operation, table string) time.Duration {
+	if table != "" {
+		if timeout, ok := p.timeouts[operation+":"+table]; ok {
+			return timeout
+		}
+	}
+	if timeout, ok := p.timeouts[operation]; ok {
+		return timeout
+	}
+	return p.timeouts["default"]
+}
+
+func (p *timeoutPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if skip, _ := db.Get(skipTimeoutKey); skip == true {
+			return
+		}
+		timeout := p.timeout(operation, db.Statement.Table)
+		if timeout <= 0 || db.Statement.Context == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(db.Statement.Context, timeout)
+		db.InstanceSet(timeoutInstanceKey, statementDeadline{
+			parent:  db.Statement.Context,
+			cancel:  cancel,
+			timeout: timeout,
+		})
+		db.Statement.Context = ctx
+	}
+}
+
+func (p *timeoutPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(timeoutInstanceKey)
+		if !ok {
+			return
+		}
+		deadline := value.(statementDeadline)
+
+		if db.Error != nil && errors.Is(db.Statement.Context.Err(), context.DeadlineExceeded) && deadline.parent.Err() == nil {
+			db.Error = core.NewErrorTimeout("db."+operation, deadline.timeout, db.Error)
+		}
+
+		// the rows of Row and Rows are read after the callbacks return, so their deadline is kept
+		// and released when it expires
+		if operation != "row" {
+			deadline.cancel()
+			db.Statement.Context = deadline.parent
+		}
+	}
+}
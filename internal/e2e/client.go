@@ -0,0 +1,146 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Identity is an entity registered to an instance
+type Identity struct {
+	CCID       string
+	PrivateKey string
+	Home       *Instance
+}
+
+type response struct {
+	Status  string          `json:"status"`
+	Content json.RawMessage `json:"content"`
+	Error   string          `json:"error"`
+}
+
+// Register creates a new entity affiliated to the instance
+func (i *Instance) Register(t testing.TB) *Identity {
+	t.Helper()
+
+	privateKey, ccid := generateKey(t, "con")
+	identity := &Identity{
+		CCID:       ccid,
+		PrivateKey: privateKey,
+		Home:       i,
+	}
+
+	identity.Commit(t, core.AffiliationDocument{
+		Domain: i.FQDN,
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   ccid,
+			Type:     "affiliation",
+			SignedAt: time.Now(),
+		},
+	}, `{"info":"{}"}`)
+
+	return identity
+}
+
+// Sign marshals the document and signs it with the key of the identity
+func (id *Identity) Sign(t testing.TB, doc any) (string, string) {
+	t.Helper()
+
+	document, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal document: %v", err)
+	}
+	signature, err := core.SignBytes(document, id.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to sign document: %v", err)
+	}
+	return string(document), hex.EncodeToString(signature)
+}
+
+// Commit signs the document and commits it to the home instance of the identity
+func (id *Identity) Commit(t testing.TB, doc any, option string) json.RawMessage {
+	t.Helper()
+	return id.CommitTo(t, id.Home, doc, option)
+}
+
+// CommitTo signs the document and commits it to the instance
+func (id *Identity) CommitTo(t testing.TB, instance *Instance, doc any, option string) json.RawMessage {
+	t.Helper()
+
+	document, signature := id.Sign(t, doc)
+	body, err := json.Marshal(core.Commit{
+		Document:  document,
+		Signature: signature,
+		Option:    option,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal commit: %v", err)
+	}
+
+	resp, err := http.Post(instance.URL+"/api/v1/commit", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to commit to %s: %v", instance.FQDN, err)
+	}
+	res := readResponse(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("commit to %s failed with %d: %s", instance.FQDN, resp.StatusCode, res.Error)
+	}
+	return res.Content
+}
+
+// Get requests the path of the instance and decodes the content of the response into content.
+// it returns false when the response is not ok
+func (i *Instance) Get(t testing.TB, path string, content any) bool {
+	t.Helper()
+
+	resp, err := http.Get(i.URL + path)
+	if err != nil {
+		t.Fatalf("failed to get %s from %s: %v", path, i.FQDN, err)
+	}
+	res := readResponse(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if content != nil {
+		err = json.Unmarshal(res.Content, content)
+		if err != nil {
+			t.Fatalf("failed to unmarshal %s: %v", path, err)
+		}
+	}
+	return true
+}
+
+// Resolve makes the instance pull the entity from its home, as the gateway does for requests from remote entities.
+// instances of the cluster run without the gateway, so this has to be done before they accept commits of the entity
+func (i *Instance) Resolve(t testing.TB, id *Identity) core.Entity {
+	t.Helper()
+
+	var entity core.Entity
+	if !i.Get(t, "/api/v1/entity/"+id.CCID+"?hint="+url.QueryEscape(id.Home.FQDN), &entity) {
+		t.Fatalf("%s could not resolve %s", i.FQDN, id.CCID)
+	}
+	return entity
+}
+
+func readResponse(t testing.TB, resp *http.Response) response {
+	t.Helper()
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	var res response
+	json.Unmarshal(body, &res)
+	if res.Error == "" && resp.StatusCode != http.StatusOK {
+		res.Error = string(body)
+	}
+	return res
+}
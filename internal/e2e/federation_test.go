@@ -0,0 +1,101 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const markdownSchema = "https://schema.concrnt.world/m/markdown.json"
+
+func TestFederation(t *testing.T) {
+	cluster := NewCluster(t, "alpha.e2e.test", "beta.e2e.test")
+	alpha := cluster.Instance("alpha.e2e.test")
+	beta := cluster.Instance("beta.e2e.test")
+
+	alice := alpha.Register(t)
+	bob := beta.Register(t)
+
+	// the domain is discovered through the remapped address
+	var domain core.Domain
+	assert.True(t, alpha.Get(t, "/api/v1/domain/"+beta.FQDN, &domain))
+	assert.Equal(t, beta.CSID, domain.CSID)
+
+	// a timeline owned by beta, which anyone can post to
+	var community core.Timeline
+	json.Unmarshal(bob.Commit(t, core.TimelineDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   bob.CCID,
+			Owner:    beta.CSID,
+			Type:     "timeline",
+			Schema:   "https://schema.concrnt.world/t/empty.json",
+			Body:     map[string]any{},
+			SignedAt: time.Now(),
+		},
+		Indexable:   true,
+		DomainOwned: true,
+	}, ""), &community)
+	communityID := community.ID + "@" + beta.FQDN
+
+	// alice posts from alpha. alpha keeps the message and relays it to beta
+	beta.Resolve(t, alice)
+	var message core.Message
+	json.Unmarshal(alice.Commit(t, core.MessageDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   alice.CCID,
+			Type:     "message",
+			Schema:   markdownSchema,
+			Body:     map[string]any{"body": "hello from alpha"},
+			SignedAt: time.Now(),
+		},
+		Timelines: []string{communityID},
+	}, ""), &message)
+	assert.NotEmpty(t, message.ID)
+
+	contains := func(instance *Instance) func() bool {
+		return func() bool {
+			var items []core.TimelineItem
+			if !instance.Get(t, "/api/v1/timelines/recent?timelines="+communityID, &items) {
+				return false
+			}
+			for _, item := range items {
+				if item.ResourceID == message.ID {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	// distributed to the timeline on beta, and readable from alpha through its remote chunks
+	assert.Eventually(t, contains(beta), 10*time.Second, 200*time.Millisecond)
+	assert.Eventually(t, contains(alpha), 10*time.Second, 200*time.Millisecond)
+
+	var stored core.Message
+	assert.True(t, alpha.Get(t, "/api/v1/message/"+message.ID, &stored))
+	assert.Equal(t, alice.CCID, stored.Author)
+
+	// bob likes the message. associations are committed to the domain of the owner of the target
+	alpha.Resolve(t, bob)
+	bob.CommitTo(t, alpha, core.AssociationDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   bob.CCID,
+			Owner:    alice.CCID,
+			Type:     "association",
+			Schema:   "https://schema.concrnt.world/a/like.json",
+			Body:     map[string]any{},
+			SignedAt: time.Now(),
+		},
+		Target: message.ID,
+	}, "")
+
+	var counts map[string]int64
+	assert.True(t, alpha.Get(t, "/api/v1/message/"+message.ID+"/associationcounts", &counts))
+	assert.Equal(t, int64(1), counts["https://schema.concrnt.world/a/like.json"])
+}
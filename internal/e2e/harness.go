@@ -0,0 +1,357 @@
+// Package e2e runs complete concrnt instances for end-to-end tests.
+//
+// NewCluster builds cmd/api, starts postgres, redis and memcached containers for each domain with dockertest
+// and runs the api as a subprocess. the instances reach each other over plain http by the hostRemap setting.
+// the tests need docker and the go toolchain, so they are behind the e2e build tag:
+//
+//	go test -tags e2e ./internal/e2e/...
+package e2e
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-yaml/yaml"
+	"github.com/ory/dockertest"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	dimension   = "concrnt-e2e"
+	dbUser      = "postgres"
+	dbPassword  = "postgres"
+	dbName      = "concurrent"
+	dsnTemplate = "host=localhost user=%s password=%s dbname=%s port=%s sslmode=disable"
+)
+
+var (
+	buildOnce sync.Once
+	binary    string
+	buildErr  error
+)
+
+// Cluster is a set of instances which federate with each other
+type Cluster struct {
+	Instances []*Instance
+}
+
+// Instance is a running api process and its backing containers
+type Instance struct {
+	FQDN       string
+	URL        string
+	CSID       string
+	PrivateKey string
+
+	port    int
+	dir     string
+	logPath string
+	cmd     *exec.Cmd
+	exited  chan struct{}
+}
+
+// NewCluster starts an instance for each fqdn. everything is torn down at the end of the test
+func NewCluster(t testing.TB, fqdns ...string) *Cluster {
+	t.Helper()
+
+	bin := buildAPI(t)
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Fatalf("could not connect to docker: %v", err)
+	}
+	pool.MaxWait = 2 * time.Minute
+
+	// ports are decided up front so that every instance can be configured to reach the others
+	cluster := &Cluster{}
+	for _, fqdn := range fqdns {
+		privateKey, csid := generateKey(t, "ccs")
+		port := freePort(t)
+		cluster.Instances = append(cluster.Instances, &Instance{
+			FQDN:       fqdn,
+			URL:        "http://localhost:" + strconv.Itoa(port),
+			CSID:       csid,
+			PrivateKey: privateKey,
+			port:       port,
+			dir:        t.TempDir(),
+		})
+	}
+
+	for _, instance := range cluster.Instances {
+		instance.start(t, pool, bin, cluster.Instances)
+	}
+	for _, instance := range cluster.Instances {
+		instance.waitHealthy(t)
+	}
+
+	return cluster
+}
+
+// Instance returns the instance of the fqdn
+func (c *Cluster) Instance(fqdn string) *Instance {
+	for _, instance := range c.Instances {
+		if instance.FQDN == fqdn {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (i *Instance) start(t testing.TB, pool *dockertest.Pool, bin string, peers []*Instance) {
+	t.Helper()
+
+	dsn := startPostgres(t, pool)
+	redisAddr := startRedis(t, pool)
+	memcachedAddr := startMemcached(t, pool)
+
+	hostRemap := []map[string]any{}
+	for _, peer := range peers {
+		if peer.FQDN == i.FQDN {
+			continue
+		}
+		hostRemap = append(hostRemap, map[string]any{
+			"host":     peer.FQDN,
+			"remap":    "localhost:" + strconv.Itoa(peer.port),
+			"useHttps": false,
+		})
+	}
+
+	config := map[string]any{
+		"server": map[string]any{
+			"dsn":            dsn,
+			"redisAddr":      redisAddr,
+			"memcachedAddr":  memcachedAddr,
+			"repositoryPath": filepath.Join(i.dir, "repository"),
+			"hostRemap":      hostRemap,
+		},
+		"concrnt": map[string]any{
+			"fqdn":         i.FQDN,
+			"privatekey":   i.PrivateKey,
+			"registration": "open",
+			"dimension":    dimension,
+		},
+		"profile": map[string]any{
+			"nickname": i.FQDN,
+		},
+	}
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	configPath := filepath.Join(i.dir, "config.yaml")
+	err = os.WriteFile(configPath, configBytes, 0o644)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	i.logPath = filepath.Join(i.dir, "api.log")
+	logFile, err := os.Create(i.logPath)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+
+	i.cmd = exec.Command(bin)
+	i.cmd.Env = append(os.Environ(),
+		"CONCRNT_CONFIG="+configPath,
+		"CC_API_PORT="+strconv.Itoa(i.port),
+	)
+	i.cmd.Stdout = logFile
+	i.cmd.Stderr = logFile
+	err = i.cmd.Start()
+	if err != nil {
+		t.Fatalf("failed to start %s: %v", i.FQDN, err)
+	}
+	i.exited = make(chan struct{})
+	go func() {
+		i.cmd.Wait()
+		close(i.exited)
+	}()
+
+	t.Cleanup(func() {
+		i.cmd.Process.Kill()
+		<-i.exited
+		logFile.Close()
+		if t.Failed() {
+			i.dumpLog(t)
+		}
+	})
+}
+
+func (i *Instance) waitHealthy(t testing.TB) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		select {
+		case <-i.exited:
+			t.Fatalf("%s exited before becoming healthy", i.FQDN)
+		default:
+		}
+		resp, err := http.Get(i.URL + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatalf("%s did not become healthy", i.FQDN)
+}
+
+func (i *Instance) dumpLog(t testing.TB) {
+	log, err := os.ReadFile(i.logPath)
+	if err != nil {
+		return
+	}
+	t.Logf("log of %s:\n%s", i.FQDN, log)
+}
+
+// buildAPI builds cmd/api once per test binary
+func buildAPI(t testing.TB) string {
+	t.Helper()
+
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "concrnt-e2e")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		binary = filepath.Join(dir, "api")
+		out, err := exec.Command("go", "build", "-o", binary, "github.com/totegamma/concurrent/cmd/api").CombinedOutput()
+		if err != nil {
+			buildErr = fmt.Errorf("%w: %s", err, out)
+		}
+	})
+	if buildErr != nil {
+		t.Fatalf("failed to build api: %v", buildErr)
+	}
+	return binary
+}
+
+func startPostgres(t testing.TB, pool *dockertest.Pool) string {
+	t.Helper()
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "postgres",
+		Tag:        "latest",
+		Env: []string{
+			"POSTGRES_USER=" + dbUser,
+			"POSTGRES_PASSWORD=" + dbPassword,
+			"POSTGRES_DB=" + dbName,
+		},
+		ExposedPorts: []string{"5432/tcp"},
+	})
+	if err != nil {
+		t.Fatalf("could not start postgres: %v", err)
+	}
+	t.Cleanup(func() { pool.Purge(resource) })
+
+	dsn := fmt.Sprintf(dsnTemplate, dbUser, dbPassword, dbName, resource.GetPort("5432/tcp"))
+	err = pool.Retry(func() error {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		if err != nil {
+			return err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		defer sqlDB.Close()
+		return sqlDB.Ping()
+	})
+	if err != nil {
+		t.Fatalf("could not connect to postgres: %v", err)
+	}
+	return dsn
+}
+
+func startRedis(t testing.TB, pool *dockertest.Pool) string {
+	t.Helper()
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository:   "redis",
+		Tag:          "latest",
+		ExposedPorts: []string{"6379/tcp"},
+	})
+	if err != nil {
+		t.Fatalf("could not start redis: %v", err)
+	}
+	t.Cleanup(func() { pool.Purge(resource) })
+
+	addr := "localhost:" + resource.GetPort("6379/tcp")
+	err = pool.Retry(func() error {
+		rdb := redis.NewClient(&redis.Options{Addr: addr})
+		defer rdb.Close()
+		return rdb.Ping(context.Background()).Err()
+	})
+	if err != nil {
+		t.Fatalf("could not connect to redis: %v", err)
+	}
+	return addr
+}
+
+func startMemcached(t testing.TB, pool *dockertest.Pool) string {
+	t.Helper()
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository:   "memcached",
+		Tag:          "1.6.7",
+		ExposedPorts: []string{"11211/tcp"},
+	})
+	if err != nil {
+		t.Fatalf("could not start memcached: %v", err)
+	}
+	t.Cleanup(func() { pool.Purge(resource) })
+
+	addr := "localhost:" + resource.GetPort("11211/tcp")
+	err = pool.Retry(func() error {
+		return memcache.New(addr).Ping()
+	})
+	if err != nil {
+		t.Fatalf("could not connect to memcached: %v", err)
+	}
+	return addr
+}
+
+func freePort(t testing.TB) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// generateKey returns a new private key in hex and its address with the hrp ("con" for entities, "ccs" for domains)
+func generateKey(t testing.TB, hrp string) (string, string) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	privateKey := hex.EncodeToString(crypto.FromECDSA(key))
+	address, err := core.PrivKeyToAddr(privateKey, hrp)
+	if err != nil {
+		t.Fatalf("failed to derive address: %v", err)
+	}
+	return privateKey, address
+}
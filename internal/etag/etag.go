@@ -0,0 +1,97 @@
+// Package etag implements conditional GET (ETag / If-None-Match, Last-Modified / If-Modified-Since) for read endpoints
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FromSignatures derives a strong ETag from document signatures.
+// signatures change whenever the signed document changes, so they identify the representation.
+func FromSignatures(signatures ...string) string {
+	h := sha256.New()
+	for _, signature := range signatures {
+		h.Write([]byte(signature))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// FromContent derives a strong ETag from the json representation of v.
+// used for resources which are not signed documents such as chunks.
+func FromContent(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// VaryByRequester marks the response as depending on the requester, who is identified by the Authorization or the passport header.
+// responses made for a requester are kept out of shared caches
+func VaryByRequester(c echo.Context, requester bool) {
+	header := c.Response().Header()
+	header.Add(echo.HeaderVary, echo.HeaderAuthorization)
+	header.Add(echo.HeaderVary, "passport")
+	if requester {
+		header.Set(echo.HeaderCacheControl, "private")
+	}
+}
+
+// Respond writes the standard {"status": "ok", "content": content} response,
+// or 304 Not Modified if the request preconditions show the client already has it.
+// empty tag or zero modified disables the corresponding header.
+func Respond(c echo.Context, tag string, modified time.Time, content any) error {
+	header := c.Response().Header()
+	if tag != "" {
+		header.Set("ETag", tag)
+	}
+	if !modified.IsZero() {
+		header.Set(echo.HeaderLastModified, modified.UTC().Format(http.TimeFormat))
+	}
+
+	if NotModified(c.Request(), tag, modified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": content})
+}
+
+// NotModified evaluates If-None-Match and If-Modified-Since as RFC 9110 describes.
+// If-Modified-Since is ignored when If-None-Match is present.
+func NotModified(r *http.Request, tag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if tag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get(echo.HeaderIfModifiedSince); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// http dates have a resolution of one second
+		return !modified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
@@ -0,0 +1,68 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespond(t *testing.T) {
+	e := echo.New()
+	tag := FromSignatures("sig1", "sig2")
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 500, time.UTC)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	err := Respond(e.NewContext(req, rec), tag, modified, "body")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, tag, rec.Header().Get("ETag"))
+	assert.Equal(t, "Mon, 01 Jan 2024 00:00:00 GMT", rec.Header().Get("Last-Modified"))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+tag)
+	rec = httptest.NewRecorder()
+	err = Respond(e.NewContext(req, rec), tag, modified, "body")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2024 00:00:00 GMT")
+	rec = httptest.NewRecorder()
+	err = Respond(e.NewContext(req, rec), tag, modified, "body")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2024 00:00:00 GMT")
+	rec = httptest.NewRecorder()
+	err = Respond(e.NewContext(req, rec), tag, modified, "body")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestFromSignatures(t *testing.T) {
+	assert.Equal(t, FromSignatures("a", "b"), FromSignatures("a", "b"))
+	assert.NotEqual(t, FromSignatures("a", "b"), FromSignatures("ab"))
+}
+
+func TestVaryByRequester(t *testing.T) {
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	VaryByRequester(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), false)
+	assert.Equal(t, []string{"Authorization", "passport"}, rec.Header().Values("Vary"))
+	assert.Empty(t, rec.Header().Get("Cache-Control"))
+
+	rec = httptest.NewRecorder()
+	VaryByRequester(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), true)
+	assert.Equal(t, []string{"Authorization", "passport"}, rec.Header().Values("Vary"))
+	assert.Equal(t, "private", rec.Header().Get("Cache-Control"))
+}
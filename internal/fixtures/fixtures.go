@@ -0,0 +1,117 @@
+// Package fixtures builds signed documents for tests. documents are built from the structs of core and signed
+// with test keypairs when the test runs, so that tests don't embed documents and signatures which break
+// when the document formats change
+package fixtures
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// MarkdownSchema is the schema of the messages built by Message
+const MarkdownSchema = "https://schema.concrnt.world/m/markdown.json"
+
+// Keypair is a test identity. the same private key is an entity (CCID), a domain (CSID) or a subkey (CKID)
+type Keypair struct {
+	PrivateKey string
+	CCID       string
+	CSID       string
+	CKID       string
+}
+
+// well known test keypairs. they only ever sign test documents
+var (
+	User1  = MustKeypair("3fcfac6c211b743975de2d7b3f622c12694b8125daf4013562c5a1aefa3253a5") // con1mu9xruulec4y6hd0d369sdf325l94z4770m33d
+	User2  = MustKeypair("1ca30329e8d35217b2328bacfc21c5e3d762713edab0252eead1f4c1ac0b4d81")
+	Remote = MustKeypair("863183823d2c2a19101140eef0f905c872de1dae6470c9129a1547f3482cb612") // con1er7kuzrw6vtv6nrq98d4jg7n2r0ayz772zvwxz
+)
+
+// NewKeypair derives the addresses of the private key
+func NewKeypair(privateKey string) (Keypair, error) {
+	keypair := Keypair{PrivateKey: privateKey}
+	var err error
+	if keypair.CCID, err = core.PrivKeyToAddr(privateKey, "con"); err != nil {
+		return Keypair{}, err
+	}
+	if keypair.CSID, err = core.PrivKeyToAddr(privateKey, "ccs"); err != nil {
+		return Keypair{}, err
+	}
+	if keypair.CKID, err = core.PrivKeyToAddr(privateKey, "cck"); err != nil {
+		return Keypair{}, err
+	}
+	return keypair, nil
+}
+
+// MustKeypair is NewKeypair panicking on invalid keys
+func MustKeypair(privateKey string) Keypair {
+	keypair, err := NewKeypair(privateKey)
+	if err != nil {
+		panic(err)
+	}
+	return keypair
+}
+
+// RandomKeypair generates a new keypair, for tests needing identities of their own
+func RandomKeypair(t testing.TB) Keypair {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keypair, err := NewKeypair(hex.EncodeToString(crypto.FromECDSA(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keypair
+}
+
+// Base is the common part of the documents signed by the entity of the keypair
+func Base(signer Keypair, typ string) core.DocumentBase[any] {
+	return core.DocumentBase[any]{
+		Signer:   signer.CCID,
+		Type:     typ,
+		SignedAt: time.Now(),
+	}
+}
+
+// Message is a markdown message of the signer posted to the timelines
+func Message(signer Keypair, body any, timelines ...string) core.MessageDocument[any] {
+	base := Base(signer, "message")
+	base.Schema = MarkdownSchema
+	base.Body = body
+	return core.MessageDocument[any]{DocumentBase: base, Timelines: timelines}
+}
+
+// Association is an association of the signer to the target, delivered to the timelines
+func Association(signer Keypair, schema, target, owner string, body any, timelines ...string) core.AssociationDocument[any] {
+	base := Base(signer, "association")
+	base.Schema = schema
+	base.Owner = owner
+	base.Body = body
+	return core.AssociationDocument[any]{DocumentBase: base, Target: target, Timelines: timelines}
+}
+
+// Affiliation is the affiliation of the signer to the domain
+func Affiliation(signer Keypair, domain string) core.AffiliationDocument {
+	return core.AffiliationDocument{DocumentBase: Base(signer, "affiliation"), Domain: domain}
+}
+
+// Sign marshals the document and signs it with the keypair, returning the document and the hex signature
+func Sign(t testing.TB, signer Keypair, document any) (string, string) {
+	t.Helper()
+	documentBytes, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := core.SignBytes(documentBytes, signer.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(documentBytes), hex.EncodeToString(signature)
+}
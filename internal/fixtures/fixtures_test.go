@@ -0,0 +1,34 @@
+package fixtures
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d", User1.CCID)
+	assert.Equal(t, "con1er7kuzrw6vtv6nrq98d4jg7n2r0ayz772zvwxz", Remote.CCID)
+
+	document, signature := Sign(t, User1, Message(User1, map[string]any{"body": "hello"}, "home@"+User1.CCID))
+
+	var message core.MessageDocument[map[string]any]
+	assert.NoError(t, json.Unmarshal([]byte(document), &message))
+	assert.Equal(t, User1.CCID, message.Signer)
+	assert.Equal(t, "message", message.Type)
+	assert.Equal(t, []string{"home@" + User1.CCID}, message.Timelines)
+
+	signatureBytes, err := hex.DecodeString(signature)
+	assert.NoError(t, err)
+	assert.NoError(t, core.VerifySignature([]byte(document), signatureBytes, User1.CCID))
+
+	keypair := RandomKeypair(t)
+	document, signature = Sign(t, keypair, Affiliation(keypair, "example.com"))
+	signatureBytes, _ = hex.DecodeString(signature)
+	assert.NoError(t, core.VerifySignature([]byte(document), signatureBytes, keypair.CCID))
+	assert.Error(t, core.VerifySignature([]byte(document), signatureBytes, User1.CCID))
+}
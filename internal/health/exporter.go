@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"sync"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Recorder keeps the outcome of the last export of the telemetry.
+// exports run in the background, so their failures are reported by the next probe
+type Recorder struct {
+	mu  sync.Mutex
+	err error
+}
+
+// Record stores the outcome of an export
+func (r *Recorder) Record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// Probe fails while the last export failed
+func (r *Recorder) Probe(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+type spanExporter struct {
+	sdktrace.SpanExporter
+	recorder *Recorder
+}
+
+func (e spanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.recorder.Record(err)
+	return err
+}
+
+// SpanExporter records the outcome of each export of the exporter to the recorder
+func SpanExporter(exporter sdktrace.SpanExporter, recorder *Recorder) sdktrace.SpanExporter {
+	return spanExporter{exporter, recorder}
+}
+
+type metricExporter struct {
+	sdkmetric.Exporter
+	recorder *Recorder
+}
+
+func (e metricExporter) Export(ctx context.Context, metrics *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, metrics)
+	e.recorder.Record(err)
+	return err
+}
+
+// MetricExporter records the outcome of each export of the exporter to the recorder
+func MetricExporter(exporter sdkmetric.Exporter, recorder *Recorder) sdkmetric.Exporter {
+	return metricExporter{exporter, recorder}
+}
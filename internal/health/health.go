@@ -0,0 +1,153 @@
+// Package health serves the liveness and readiness probes of a process with the status of each of its dependencies
+package health
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+const defaultTimeout = 2 * time.Second
+
+// Check is a dependency probed on readiness
+type Check struct {
+	Name string
+	// the process is not ready while a critical check fails. the others only degrade it
+	Critical bool
+	Probe    func(ctx context.Context) error
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name     string  `json:"name"`
+	Status   Status  `json:"status"`
+	Critical bool    `json:"critical"`
+	Latency  float64 `json:"latencyMs"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Report is the status of the process and of each of its dependencies
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Checker runs the registered checks
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker creates a checker whose probes are given up after timeout. 0 means the default of 2 seconds
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a check. checks are registered while setting up, before serving
+func (c *Checker) Register(check Check) {
+	c.checks = append(c.checks, check)
+}
+
+// Run probes all dependencies at the same time. a probe which doesn't return in time fails,
+// so that a hung dependency is reported instead of hanging the probe of the process
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probe(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+func probe(ctx context.Context, check Check) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Probe(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Name:     check.Name,
+		Status:   StatusOK,
+		Critical: check.Critical,
+		Latency:  float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Livez answers as long as the process serves requests. dependencies are not probed,
+// so that an outage of the database doesn't get every replica restarted
+func Livez(c echo.Context) error {
+	return c.String(http.StatusOK, string(StatusOK))
+}
+
+// Readyz answers 503 while a critical dependency is down. degraded processes keep serving.
+// the report is returned as json with the verbose query parameter, and as the bare status otherwise
+func (c *Checker) Readyz(ctx echo.Context) error {
+	report := c.Run(ctx.Request().Context())
+
+	code := http.StatusOK
+	if report.Status == StatusDown {
+		code = http.StatusServiceUnavailable
+	}
+
+	if _, verbose := ctx.QueryParams()["verbose"]; verbose {
+		return ctx.JSON(code, report)
+	}
+	return ctx.String(code, string(report.Status))
+}
+
+// IsProbe tells whether the path is one of the probe endpoints, which are left out of logs, traces and metrics
+func IsProbe(path string) bool {
+	switch strings.TrimSuffix(path, "/") {
+	case "/livez", "/readyz", "/healthz":
+		return true
+	}
+	return false
+}
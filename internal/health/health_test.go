@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("unreachable") }
+	hung := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	checker := NewChecker(50 * time.Millisecond)
+	checker.Register(Check{Name: "postgres", Critical: true, Probe: ok})
+	checker.Register(Check{Name: "cache", Probe: failing})
+
+	report := checker.Run(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, StatusOK, report.Checks[0].Status)
+	assert.Equal(t, StatusDown, report.Checks[1].Status)
+	assert.Equal(t, "unreachable", report.Checks[1].Error)
+
+	// a hung probe fails once the timeout passes
+	checker.Register(Check{Name: "redis", Critical: true, Probe: hung})
+	start := time.Now()
+	report = checker.Run(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[2].Error)
+}
+
+func TestReadyz(t *testing.T) {
+	e := echo.New()
+	checker := NewChecker(0)
+	checker.Register(Check{Name: "redis", Critical: true, Probe: func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}})
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/readyz", nil), rec)
+	assert.NoError(t, checker.Readyz(c))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "down", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/healthz?verbose", nil), rec)
+	assert.NoError(t, checker.Readyz(c))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var report Report
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, "redis", report.Checks[0].Name)
+}
@@ -0,0 +1,451 @@
+// Package jsonschema validates decoded JSON values against a JSON Schema.
+// it covers the structural keywords used by concrnt document schemas; unknown keywords are ignored.
+// only local references ("#/...") are resolved.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a single validation failure
+type Error struct {
+	Path    string `json:"path"` // JSON pointer to the invalid value
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Schema is a compiled schema
+type Schema struct {
+	boolean *bool
+	ref     *Schema
+
+	types    []string
+	enum     []any
+	constant any
+	hasConst bool
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *float64
+	maxProperties        *float64
+
+	items    *Schema
+	minItems *float64
+	maxItems *float64
+
+	minLength *float64
+	maxLength *float64
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+}
+
+type compiler struct {
+	root  any
+	cache map[string]*Schema
+}
+
+// Compile parses a schema document
+func Compile(data []byte) (*Schema, error) {
+	var root any
+	err := json.Unmarshal(data, &root)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{root: root, cache: make(map[string]*Schema)}
+	return c.compileRef("#")
+}
+
+func (c *compiler) compileRef(ref string) (*Schema, error) {
+	if s, ok := c.cache[ref]; ok {
+		return s, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported reference: %s", ref)
+	}
+
+	node := c.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch n := node.(type) {
+			case map[string]any:
+				node = n[token]
+			case []any:
+				idx, err := strconv.Atoi(token)
+				if err != nil || idx < 0 || idx >= len(n) {
+					return nil, fmt.Errorf("unresolvable reference: %s", ref)
+				}
+				node = n[idx]
+			default:
+				return nil, fmt.Errorf("unresolvable reference: %s", ref)
+			}
+		}
+	}
+
+	// register before compiling so that recursive references terminate
+	s := &Schema{}
+	c.cache[ref] = s
+	err := c.fill(s, node)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *compiler) compile(node any) (*Schema, error) {
+	s := &Schema{}
+	err := c.fill(s, node)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *compiler) compileList(node any) ([]*Schema, error) {
+	list, ok := node.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of schemas")
+	}
+	schemas := make([]*Schema, len(list))
+	for i, item := range list {
+		s, err := c.compile(item)
+		if err != nil {
+			return nil, err
+		}
+		schemas[i] = s
+	}
+	return schemas, nil
+}
+
+func number(node any) *float64 {
+	if f, ok := node.(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+func (c *compiler) fill(s *Schema, node any) error {
+	var err error
+
+	var m map[string]any
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case bool:
+		s.boolean = &n
+		return nil
+	case map[string]any:
+		m = n
+	default:
+		return fmt.Errorf("schema must be an object or a boolean")
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		s.ref, err = c.compileRef(ref)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch t := m["type"].(type) {
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, v := range t {
+			if str, ok := v.(string); ok {
+				s.types = append(s.types, str)
+			}
+		}
+	}
+
+	if enum, ok := m["enum"].([]any); ok {
+		s.enum = enum
+	}
+	if constant, ok := m["const"]; ok {
+		s.constant = constant
+		s.hasConst = true
+	}
+
+	if props, ok := m["properties"].(map[string]any); ok {
+		s.properties = make(map[string]*Schema, len(props))
+		for key, prop := range props {
+			s.properties[key], err = c.compile(prop)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if required, ok := m["required"].([]any); ok {
+		for _, v := range required {
+			if str, ok := v.(string); ok {
+				s.required = append(s.required, str)
+			}
+		}
+	}
+	if additional, ok := m["additionalProperties"]; ok {
+		s.additionalProperties, err = c.compile(additional)
+		if err != nil {
+			return err
+		}
+	}
+	s.minProperties = number(m["minProperties"])
+	s.maxProperties = number(m["maxProperties"])
+
+	if items, ok := m["items"]; ok {
+		s.items, err = c.compile(items)
+		if err != nil {
+			return err
+		}
+	}
+	s.minItems = number(m["minItems"])
+	s.maxItems = number(m["maxItems"])
+
+	s.minLength = number(m["minLength"])
+	s.maxLength = number(m["maxLength"])
+	if pattern, ok := m["pattern"].(string); ok {
+		s.pattern, err = regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.minimum = number(m["minimum"])
+	s.maximum = number(m["maximum"])
+	s.exclusiveMinimum = number(m["exclusiveMinimum"])
+	s.exclusiveMaximum = number(m["exclusiveMaximum"])
+
+	if allOf, ok := m["allOf"]; ok {
+		s.allOf, err = c.compileList(allOf)
+		if err != nil {
+			return err
+		}
+	}
+	if anyOf, ok := m["anyOf"]; ok {
+		s.anyOf, err = c.compileList(anyOf)
+		if err != nil {
+			return err
+		}
+	}
+	if oneOf, ok := m["oneOf"]; ok {
+		s.oneOf, err = c.compileList(oneOf)
+		if err != nil {
+			return err
+		}
+	}
+	if not, ok := m["not"]; ok {
+		s.not, err = c.compile(not)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate validates v, a value decoded by encoding/json, and returns all failures
+func (s *Schema) Validate(v any) []Error {
+	return s.validate(v, "")
+}
+
+func typeOf(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func matchType(actual, expected string) bool {
+	return actual == expected || (expected == "number" && actual == "integer")
+}
+
+func equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func (s *Schema) validate(v any, path string) []Error {
+	if s.boolean != nil {
+		if *s.boolean {
+			return nil
+		}
+		return []Error{{Path: path, Keyword: "false", Message: "no value is allowed"}}
+	}
+
+	errs := []Error{}
+	fail := func(keyword, format string, args ...any) {
+		errs = append(errs, Error{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.ref != nil {
+		errs = append(errs, s.ref.validate(v, path)...)
+	}
+
+	actual := typeOf(v)
+	if len(s.types) > 0 {
+		ok := false
+		for _, t := range s.types {
+			if matchType(actual, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("type", "expected %s, got %s", strings.Join(s.types, " or "), actual)
+			// further keywords are meaningless for a value of the wrong type
+			return errs
+		}
+	}
+
+	if s.enum != nil {
+		ok := false
+		for _, e := range s.enum {
+			if equal(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("enum", "value is not one of the allowed values")
+		}
+	}
+	if s.hasConst && !equal(v, s.constant) {
+		fail("const", "value does not match the constant")
+	}
+
+	switch n := v.(type) {
+	case map[string]any:
+		for _, key := range s.required {
+			if _, ok := n[key]; !ok {
+				fail("required", "missing property %q", key)
+			}
+		}
+		if s.minProperties != nil && float64(len(n)) < *s.minProperties {
+			fail("minProperties", "must have at least %v properties", *s.minProperties)
+		}
+		if s.maxProperties != nil && float64(len(n)) > *s.maxProperties {
+			fail("maxProperties", "must have at most %v properties", *s.maxProperties)
+		}
+
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "/" + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+			if prop, ok := s.properties[key]; ok {
+				errs = append(errs, prop.validate(n[key], child)...)
+			} else if s.additionalProperties != nil {
+				errs = append(errs, s.additionalProperties.validate(n[key], child)...)
+			}
+		}
+
+	case []any:
+		if s.minItems != nil && float64(len(n)) < *s.minItems {
+			fail("minItems", "must have at least %v items", *s.minItems)
+		}
+		if s.maxItems != nil && float64(len(n)) > *s.maxItems {
+			fail("maxItems", "must have at most %v items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range n {
+				errs = append(errs, s.items.validate(item, path+"/"+strconv.Itoa(i))...)
+			}
+		}
+
+	case string:
+		length := float64(utf8.RuneCountInString(n))
+		if s.minLength != nil && length < *s.minLength {
+			fail("minLength", "must be at least %v characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("maxLength", "must be at most %v characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(n) {
+			fail("pattern", "does not match %s", s.pattern.String())
+		}
+
+	case float64:
+		if s.minimum != nil && n < *s.minimum {
+			fail("minimum", "must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && n > *s.maximum {
+			fail("maximum", "must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+			fail("exclusiveMinimum", "must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+			fail("exclusiveMaximum", "must be < %v", *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		errs = append(errs, sub.validate(v, path)...)
+	}
+	if len(s.anyOf) > 0 {
+		ok := false
+		for _, sub := range s.anyOf {
+			if len(sub.validate(v, path)) == 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("anyOf", "does not match any of the schemas")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if len(sub.validate(v, path)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("oneOf", "must match exactly one schema, matched %d", matched)
+		}
+	}
+	if s.not != nil && len(s.not.validate(v, path)) == 0 {
+		fail("not", "must not match the schema")
+	}
+
+	return errs
+}
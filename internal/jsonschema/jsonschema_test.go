@@ -0,0 +1,77 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"properties": {
+		"body": {"type": "string", "minLength": 1, "maxLength": 8},
+		"emojis": {
+			"type": "object",
+			"additionalProperties": {"$ref": "#/definitions/emoji"}
+		},
+		"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "maxItems": 2},
+		"kind": {"enum": ["a", "b"]},
+		"count": {"type": "integer", "minimum": 0}
+	},
+	"required": ["body"],
+	"definitions": {
+		"emoji": {
+			"type": "object",
+			"properties": {"imageURL": {"type": "string"}},
+			"required": ["imageURL"]
+		}
+	}
+}`
+
+func decode(t *testing.T, s string) any {
+	var v any
+	err := json.Unmarshal([]byte(s), &v)
+	assert.NoError(t, err)
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(testSchema))
+	assert.NoError(t, err)
+
+	errs := schema.Validate(decode(t, `{"body": "hello", "emojis": {"smile": {"imageURL": "x"}}, "tags": ["a"], "kind": "a", "count": 3}`))
+	assert.Len(t, errs, 0)
+
+	errs = schema.Validate(decode(t, `{"emojis": {"smile": {}}}`))
+	assert.Len(t, errs, 2)
+	assert.Equal(t, Error{Path: "", Keyword: "required", Message: `missing property "body"`}, errs[0])
+	assert.Equal(t, "/emojis/smile", errs[1].Path)
+
+	errs = schema.Validate(decode(t, `{"body": "too long body", "tags": ["A", "b", "c"], "kind": "c", "count": 1.5}`))
+	keywords := []string{}
+	for _, e := range errs {
+		keywords = append(keywords, e.Path+" "+e.Keyword)
+	}
+	assert.ElementsMatch(t, []string{"/body maxLength", "/count type", "/kind enum", "/tags maxItems", "/tags/0 pattern"}, keywords)
+
+	errs = schema.Validate(decode(t, `"not an object"`))
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "type", errs[0].Keyword)
+}
+
+func TestCompileRecursiveRef(t *testing.T) {
+	schema, err := Compile([]byte(`{
+		"type": "object",
+		"properties": {"children": {"type": "array", "items": {"$ref": "#"}}},
+		"additionalProperties": false
+	}`))
+	assert.NoError(t, err)
+
+	assert.Len(t, schema.Validate(decode(t, `{"children": [{"children": []}]}`)), 0)
+
+	errs := schema.Validate(decode(t, `{"children": [{"foo": 1}]}`))
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "/children/0/foo", errs[0].Path)
+}
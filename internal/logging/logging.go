@@ -0,0 +1,119 @@
+// Package logging carries a request scoped slog.Logger in the context,
+// so that every log line of a request can be correlated by its trace id and requester
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type ctxKey struct{}
+
+const (
+	traceIDKey = "traceID"
+	spanIDKey  = "spanID"
+)
+
+// NewContext returns a context carrying the logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger of the context, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Debug logs at debug level with the logger of the context
+func Debug(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).DebugContext(ctx, msg, args...)
+}
+
+// Info logs at info level with the logger of the context
+func Info(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).InfoContext(ctx, msg, args...)
+}
+
+// Warn logs at warn level with the logger of the context
+func Warn(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).WarnContext(ctx, msg, args...)
+}
+
+// Error logs at error level with the logger of the context
+func Error(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).ErrorContext(ctx, msg, args...)
+}
+
+// Middleware injects a logger carrying the trace id, the requester and the domain of the request.
+// it must run after the requester is identified.
+func Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		attrs := []any{slog.String("domain", c.Request().Host)}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			attrs = append(attrs, slog.String(traceIDKey, span.TraceID().String()))
+		}
+		if requester, ok := ctx.Value(core.RequesterIdCtxKey).(string); ok && requester != "" {
+			attrs = append(attrs, slog.String("requester", requester))
+		}
+		if domain, ok := ctx.Value(core.RequesterDomainCtxKey).(string); ok && domain != "" {
+			attrs = append(attrs, slog.String("requesterDomain", domain))
+		}
+
+		logger := FromContext(ctx).With(attrs...)
+		c.SetRequest(c.Request().WithContext(NewContext(ctx, logger)))
+		return next(c)
+	}
+}
+
+// Handler marks records as application logs and adds the trace and span id of the context.
+// the trace id is skipped if the logger already carries it.
+type Handler struct {
+	slog.Handler
+	traced bool
+}
+
+// NewHandler wraps a handler
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+// Handle adds the correlation attributes and passes the record to the wrapped handler
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(slog.String("type", "app"))
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		if !h.traced {
+			r.AddAttrs(slog.String(traceIDKey, span.SpanContext().TraceID().String()))
+		}
+		r.AddAttrs(slog.String(spanIDKey, span.SpanContext().SpanID().String()))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with the attributes added
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	traced := h.traced
+	for _, attr := range attrs {
+		if attr.Key == traceIDKey {
+			traced = true
+		}
+	}
+	return &Handler{Handler: h.Handler.WithAttrs(attrs), traced: traced}
+}
+
+// WithGroup returns a handler with the group opened
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name), traced: h.traced}
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(NewHandler(slog.NewJSONHandler(&buf, nil))))
+	defer slog.SetDefault(prev)
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "example.com"
+	ctx := trace.ContextWithSpanContext(req.Context(), spanContext)
+	ctx = context.WithValue(ctx, core.RequesterIdCtxKey, "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d")
+	req = req.WithContext(ctx)
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	err := Middleware(func(c echo.Context) error {
+		Info(c.Request().Context(), "hello", slog.String("module", "test"))
+		return nil
+	})(c)
+	assert.NoError(t, err)
+
+	// traceID must appear once even though both the logger and the handler know it
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte(`"traceID"`)))
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "hello", record["msg"])
+	assert.Equal(t, "example.com", record["domain"])
+	assert.Equal(t, "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d", record["requester"])
+	assert.Equal(t, spanContext.TraceID().String(), record["traceID"])
+	assert.Equal(t, spanContext.SpanID().String(), record["spanID"])
+	assert.Equal(t, "app", record["type"])
+}
+
+func TestFromContextDefault(t *testing.T) {
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+}
@@ -0,0 +1,244 @@
+// Package openapi builds an OpenAPI 3 document from registered echo routes
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+const Version = "3.0.3"
+
+// Document is the root of an OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Spec describes a route. Request and Response are zero values of go types the schema is derived from.
+// Response is wrapped with the standard {"status": "ok", "content": ...} envelope unless Raw is set.
+type Spec struct {
+	Summary  string
+	Tags     []string
+	Query    []string
+	Request  any
+	Response any
+	Raw      bool
+	Auth     bool
+}
+
+// Registry collects route specs
+type Registry struct {
+	title   string
+	version string
+	servers []Server
+
+	mu    sync.RWMutex
+	specs map[string]Spec
+}
+
+// NewRegistry creates a new Registry
+func NewRegistry(title, version string, servers ...string) *Registry {
+	s := make([]Server, len(servers))
+	for i, url := range servers {
+		s[i] = Server{URL: url}
+	}
+	return &Registry{
+		title:   title,
+		version: version,
+		servers: s,
+		specs:   make(map[string]Spec),
+	}
+}
+
+func specKey(method, path string) string {
+	return method + " " + path
+}
+
+// Describe registers a spec for the route
+func (r *Registry) Describe(method, path string, spec Spec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs[specKey(method, path)] = spec
+}
+
+// Route registers a handler to the group and describes it at once
+func (r *Registry) Route(g *echo.Group, method, path string, handler echo.HandlerFunc, spec Spec, m ...echo.MiddlewareFunc) *echo.Route {
+	r.Describe(method, path, spec)
+	return g.Add(method, path, handler, m...)
+}
+
+var pathParamRegex = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Build builds a document for given routes. routes without a spec are still listed.
+func (r *Registry) Build(routes []*echo.Route) Document {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	gen := newSchemaGenerator()
+	paths := make(map[string]map[string]Operation)
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+
+	for _, route := range routes {
+		if route.Method == echo.RouteNotFound || strings.HasSuffix(route.Path, "/*") {
+			continue
+		}
+
+		spec := r.specs[specKey(route.Method, route.Path)]
+		path := pathParamRegex.ReplaceAllString(route.Path, "{$1}")
+
+		op := Operation{
+			Summary:   spec.Summary,
+			Tags:      spec.Tags,
+			Responses: map[string]Response{},
+		}
+
+		for _, match := range pathParamRegex.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		for _, query := range spec.Query {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:   query,
+				In:     "query",
+				Schema: &Schema{Type: "string"},
+			})
+		}
+
+		if spec.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					echo.MIMEApplicationJSON: {Schema: gen.SchemaOf(spec.Request)},
+				},
+			}
+		}
+
+		response := Response{Description: "OK"}
+		if spec.Response != nil {
+			schema := gen.SchemaOf(spec.Response)
+			if !spec.Raw {
+				schema = &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"status":  {Type: "string"},
+						"content": schema,
+					},
+				}
+			}
+			response.Content = map[string]MediaType{
+				echo.MIMEApplicationJSON: {Schema: schema},
+			}
+		}
+		op.Responses["200"] = response
+		op.Responses["default"] = Response{
+			Description: "error",
+			Content: map[string]MediaType{
+				echo.MIMEApplicationJSON: {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"status":  {Type: "string"},
+						"error":   {Type: "string"},
+						"message": {Type: "string"},
+					},
+				}},
+			},
+		}
+
+		if spec.Auth {
+			op.Security = []map[string][]string{{"passport": {}}}
+		}
+
+		if _, ok := paths[path]; !ok {
+			paths[path] = make(map[string]Operation)
+		}
+		paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:   r.title,
+			Version: r.version,
+		},
+		Servers: r.servers,
+		Paths:   paths,
+		Components: Components{
+			Schemas: gen.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				"passport": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
+
+// Handler serves the document built from all routes of e
+func (r *Registry) Handler(e *echo.Echo) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, r.Build(e.Routes()))
+	}
+}
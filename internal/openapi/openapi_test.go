@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChild struct {
+	Name string `json:"name"`
+}
+
+type testResource struct {
+	ID       string            `json:"id"`
+	Count    int64             `json:"count"`
+	CDate    time.Time         `json:"cdate"`
+	Tags     []string          `json:"tags"`
+	Meta     map[string]string `json:"meta"`
+	Child    *testChild        `json:"child,omitempty"`
+	Children []testResource    `json:"children"`
+	Ignored  string            `json:"-"`
+	private  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	gen := newSchemaGenerator()
+
+	schema := gen.SchemaOf(testResource{})
+	assert.Equal(t, "#/components/schemas/openapi.testResource", schema.Ref)
+
+	component := gen.components["openapi.testResource"]
+	assert.Equal(t, "object", component.Type)
+	assert.Equal(t, "string", component.Properties["id"].Type)
+	assert.Equal(t, "int64", component.Properties["count"].Format)
+	assert.Equal(t, "date-time", component.Properties["cdate"].Format)
+	assert.Equal(t, "array", component.Properties["tags"].Type)
+	assert.Equal(t, "object", component.Properties["meta"].Type)
+	assert.Equal(t, "#/components/schemas/openapi.testChild", component.Properties["child"].Ref)
+	assert.Equal(t, "#/components/schemas/openapi.testResource", component.Properties["children"].Items.Ref)
+	assert.NotContains(t, component.Properties, "Ignored")
+	assert.NotContains(t, component.Properties, "private")
+}
+
+func TestBuild(t *testing.T) {
+	e := echo.New()
+	g := e.Group("")
+
+	handler := func(c echo.Context) error { return nil }
+
+	registry := NewRegistry("test", "v0", "https://example.com/api/v1")
+	registry.Route(g, http.MethodGet, "/resource/:id", handler, Spec{Summary: "get", Response: testResource{}, Query: []string{"hint"}})
+	g.POST("/undocumented", handler)
+
+	doc := registry.Build(e.Routes())
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, "https://example.com/api/v1", doc.Servers[0].URL)
+
+	get, ok := doc.Paths["/resource/{id}"]["get"]
+	assert.True(t, ok)
+	assert.Equal(t, "get", get.Summary)
+	assert.Len(t, get.Parameters, 2)
+	assert.Equal(t, "path", get.Parameters[0].In)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "#/components/schemas/openapi.testResource", get.Responses["200"].Content[echo.MIMEApplicationJSON].Schema.Properties["content"].Ref)
+
+	_, ok = doc.Paths["/undocumented"]["post"]
+	assert.True(t, ok)
+}
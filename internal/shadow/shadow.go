@@ -0,0 +1,178 @@
+// Package shadow runs a candidate implementation alongside the current one and reports divergences.
+// results of the candidate are never returned to callers.
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("shadow")
+
+const (
+	ResultMatch    = "match"
+	ResultDiverged = "diverged"
+	ResultError    = "error"
+	ResultTimeout  = "timeout"
+
+	defaultTimeout = 10 * time.Second
+)
+
+var comparisonMetrics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cc_shadow_comparisons_total",
+		Help: "number of shadow comparisons by result",
+	},
+	[]string{"target", "method", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(comparisonMetrics)
+}
+
+// Config configures a Runner
+type Config struct {
+	Target       string        // name of the shadowed component (used in logs and metrics)
+	SampleRate   float64       // 0.0 - 1.0, ratio of calls to be shadowed
+	MirrorWrites bool          // also apply write operations to the candidate
+	Timeout      time.Duration // timeout for a single candidate call
+}
+
+// Runner dispatches candidate calls and compares their results
+type Runner struct {
+	config Config
+}
+
+// NewRunner creates a new shadow Runner
+func NewRunner(config Config) *Runner {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Runner{config}
+}
+
+// MirrorWrites reports whether write operations should also be sent to the candidate
+func (r *Runner) MirrorWrites() bool {
+	return r.config.MirrorWrites
+}
+
+func (r *Runner) sampled() bool {
+	if r.config.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < r.config.SampleRate
+}
+
+// detach returns a context which survives the cancellation of the original request
+func (r *Runner) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), r.config.Timeout)
+}
+
+// Compare calls candidate in background and compares its result with the primary result.
+// results are compared by their JSON representation so that values loaded through
+// different storage layers (e.g. time.Time with different locations) compare equal.
+// primary is serialized before Compare returns, as the caller may modify it afterwards.
+func Compare[T any](ctx context.Context, r *Runner, method string, primary T, primaryErr error, candidate func(ctx context.Context) (T, error)) {
+	if r == nil || !r.sampled() {
+		return
+	}
+
+	snapshot := marshal(primary)
+
+	go func() {
+		ctx, cancel := r.detach(ctx)
+		defer cancel()
+
+		ctx, span := tracer.Start(ctx, "Shadow.Runner.Compare."+method)
+		defer span.End()
+
+		result, err := candidate(ctx)
+		r.report(ctx, method, classify(ctx, snapshot, primaryErr, result, err))
+	}()
+}
+
+// Mirror calls candidate in background and only reports its errors.
+func Mirror(ctx context.Context, r *Runner, method string, candidate func(ctx context.Context) error) {
+	if r == nil || !r.config.MirrorWrites {
+		return
+	}
+
+	go func() {
+		ctx, cancel := r.detach(ctx)
+		defer cancel()
+
+		ctx, span := tracer.Start(ctx, "Shadow.Runner.Mirror."+method)
+		defer span.End()
+
+		err := candidate(ctx)
+		if err != nil {
+			span.RecordError(err)
+			slog.WarnContext(
+				ctx, "shadow write failed",
+				slog.String("target", r.config.Target),
+				slog.String("method", method),
+				slog.String("error", err.Error()),
+				slog.String("module", "shadow"),
+			)
+			comparisonMetrics.WithLabelValues(r.config.Target, method, ResultError).Inc()
+		}
+	}()
+}
+
+// classify compares the candidate with primary, the JSON representation of the primary result
+func classify[T any](ctx context.Context, primary []byte, primaryErr error, candidate T, candidateErr error) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ResultTimeout
+	}
+	if (primaryErr == nil) != (candidateErr == nil) {
+		if candidateErr != nil {
+			return ResultError
+		}
+		return ResultDiverged
+	}
+	if primaryErr != nil {
+		// both failed. treat as match; error messages are implementation specific
+		return ResultMatch
+	}
+	candidateJSON := marshal(candidate)
+	if primary == nil || candidateJSON == nil || string(primary) != string(candidateJSON) {
+		return ResultDiverged
+	}
+	return ResultMatch
+}
+
+func (r *Runner) report(ctx context.Context, method, result string) {
+	comparisonMetrics.WithLabelValues(r.config.Target, method, result).Inc()
+	if result == ResultMatch {
+		return
+	}
+	slog.WarnContext(
+		ctx, "shadow result diverged",
+		slog.String("target", r.config.Target),
+		slog.String("method", method),
+		slog.String("result", result),
+		slog.String("module", "shadow"),
+	)
+}
+
+// Equal reports whether a and b have the same JSON representation
+func Equal[T any](a, b T) bool {
+	aj := marshal(a)
+	bj := marshal(b)
+	return aj != nil && bj != nil && string(aj) == string(bj)
+}
+
+// marshal returns the JSON representation of v, or nil if it has none
+func marshal(v any) []byte {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return j
+}
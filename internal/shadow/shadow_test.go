@@ -0,0 +1,76 @@
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	ctx := context.Background()
+	failure := fmt.Errorf("failure")
+
+	assert.Equal(t, ResultMatch, classify(ctx, marshal([]string{"a"}), nil, []string{"a"}, nil))
+	assert.Equal(t, ResultDiverged, classify(ctx, marshal([]string{"a"}), nil, []string{"b"}, nil))
+	assert.Equal(t, ResultError, classify(ctx, marshal("a"), nil, "", failure))
+	assert.Equal(t, ResultDiverged, classify(ctx, marshal(""), failure, "a", nil))
+	assert.Equal(t, ResultMatch, classify(ctx, marshal(""), failure, "", failure))
+	assert.Equal(t, ResultDiverged, classify(ctx, marshal(func() {}), nil, func() {}, nil))
+}
+
+func TestEqualTime(t *testing.T) {
+	now := time.Now()
+	assert.True(t, Equal(now, now.Round(0)))
+	assert.False(t, Equal(now, now.Add(time.Second)))
+}
+
+func TestCompare(t *testing.T) {
+	runner := NewRunner(Config{Target: "test", SampleRate: 1})
+
+	done := make(chan struct{})
+	Compare(context.Background(), runner, "Get", "primary", nil, func(ctx context.Context) (string, error) {
+		close(done)
+		return "candidate", nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("candidate was not called")
+	}
+
+	// sample rate 0 never calls candidate
+	runner = NewRunner(Config{Target: "test", SampleRate: 0})
+	Compare(context.Background(), runner, "Get", "primary", nil, func(ctx context.Context) (string, error) {
+		t.Fatal("candidate should not be called")
+		return "", nil
+	})
+	Mirror(context.Background(), runner, "Put", func(ctx context.Context) error {
+		t.Fatal("candidate should not be called")
+		return nil
+	})
+}
+
+func TestCompareModifiedPrimary(t *testing.T) {
+	runner := NewRunner(Config{Target: "modified", SampleRate: 1})
+
+	primary := []string{"a"}
+	modified := make(chan struct{})
+	Compare(context.Background(), runner, "List", primary, nil, func(ctx context.Context) ([]string, error) {
+		<-modified
+		return []string{"a"}, nil
+	})
+
+	// the caller reuses the slice it got after the call returned
+	primary[0] = "b"
+	close(modified)
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(comparisonMetrics.WithLabelValues("modified", "List", ResultMatch)) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Zero(t, testutil.ToFloat64(comparisonMetrics.WithLabelValues("modified", "List", ResultDiverged)))
+}
@@ -157,13 +157,16 @@ func CreateDB() (*gorm.DB, func()) {
 		&core.Message{},
 		&core.Profile{},
 		&core.Association{},
+		&core.AssociationCount{},
 		&core.Timeline{},
 		&core.TimelineItem{},
 		&core.Domain{},
 		&core.Entity{},
 		&core.EntityMeta{},
+		&core.EntityTag{},
 		&core.Ack{},
 		&core.Key{},
+		&core.KeyUsage{},
 		&core.UserKV{},
 		&core.Subscription{},
 		&core.SubscriptionItem{},
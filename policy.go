@@ -374,6 +374,7 @@ var globalPolicyJson = `
         }
     },
     "defaults": {
+        "timeline.read": true,
         "timeline.message.read": true,
         "message.association.attach": true,
         "timeline.association.attach": true,
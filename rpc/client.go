@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Client is the client of the internal service
+type Client interface {
+	GetEntity(ctx context.Context, id, hint string) (core.Entity, error)
+	Commit(ctx context.Context, req CommitRequest) (json.RawMessage, error)
+	PostItem(ctx context.Context, req PostItemRequest) (core.TimelineItem, error)
+	Close() error
+}
+
+type client struct {
+	conn *grpc.ClientConn
+}
+
+// NewClient connects to the internal service at addr. tlsConfig should present a client certificate (mTLS).
+// nil tlsConfig is only intended for tests.
+func NewClient(addr string, tlsConfig *tls.Config, opts ...grpc.DialOption) (Client, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	opts = append(
+		opts,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+		grpc.WithChainUnaryInterceptor(injectTrace),
+	)
+
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{conn}, nil
+}
+
+// injectTrace propagates the current trace to the server
+func injectTrace(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+}
+
+func (c *client) invoke(ctx context.Context, method string, req, res any) error {
+	return fromStatus(c.conn.Invoke(ctx, "/"+serviceName+"/"+method, req, res))
+}
+
+func (c *client) GetEntity(ctx context.Context, id, hint string) (core.Entity, error) {
+	ctx, span := tracer.Start(ctx, "Rpc.Client.GetEntity")
+	defer span.End()
+
+	var entity core.Entity
+	err := c.invoke(ctx, "GetEntity", &GetEntityRequest{ID: id, Hint: hint}, &entity)
+	if err != nil {
+		span.RecordError(err)
+		return core.Entity{}, err
+	}
+
+	return entity, nil
+}
+
+func (c *client) Commit(ctx context.Context, req CommitRequest) (json.RawMessage, error) {
+	ctx, span := tracer.Start(ctx, "Rpc.Client.Commit")
+	defer span.End()
+
+	var res CommitResponse
+	err := c.invoke(ctx, "Commit", &req, &res)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return res.Result, nil
+}
+
+func (c *client) PostItem(ctx context.Context, req PostItemRequest) (core.TimelineItem, error) {
+	ctx, span := tracer.Start(ctx, "Rpc.Client.PostItem")
+	defer span.End()
+
+	var item core.TimelineItem
+	err := c.invoke(ctx, "PostItem", &req, &item)
+	if err != nil {
+		span.RecordError(err)
+		return core.TimelineItem{}, err
+	}
+
+	return item, nil
+}
+
+func (c *client) Close() error {
+	return c.conn.Close()
+}
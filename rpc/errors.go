@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// toStatus converts core errors into grpc status so that they survive the transport
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, core.ErrorNotFound{}):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, core.ErrorPermissionDenied{}):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, core.ErrorAlreadyExists{}):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, core.ErrorAlreadyDeleted{}):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// fromStatus converts grpc status back into core errors
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return core.NewErrorNotFound()
+	case codes.PermissionDenied:
+		return core.NewErrorPermissionDenied()
+	case codes.AlreadyExists:
+		return core.NewErrorAlreadyExists()
+	case codes.FailedPrecondition:
+		return core.NewErrorAlreadyDeleted()
+	default:
+		return errors.New(st.Message())
+	}
+}
@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type GetEntityRequest struct {
+	ID   string `json:"id"`
+	Hint string `json:"hint,omitempty"`
+}
+
+type CommitRequest struct {
+	Mode      core.CommitMode `json:"mode"`
+	Document  string          `json:"document"`
+	Signature string          `json:"signature"`
+	Option    string          `json:"option,omitempty"`
+	Keys      []core.Key      `json:"keys,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+}
+
+type CommitResponse struct {
+	Result json.RawMessage `json:"result"`
+}
+
+type PostItemRequest struct {
+	Timeline  string            `json:"timeline"`
+	Item      core.TimelineItem `json:"item"`
+	Document  string            `json:"document"`
+	Signature string            `json:"signature"`
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+func setupClient(t *testing.T, srv InternalServer) Client {
+	lis := bufconn.Listen(1024 * 1024)
+	s := NewGRPCServer(srv, nil)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	c, err := NewClient(
+		"passthrough:///bufnet",
+		nil,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestGetEntity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), "con1").Return(core.Entity{ID: "con1", Domain: "example.com"}, nil)
+	mockEntity.EXPECT().Get(gomock.Any(), "con2").Return(core.Entity{}, core.NewErrorNotFound())
+
+	c := setupClient(t, NewServer(mockEntity, nil, nil))
+
+	entity, err := c.GetEntity(context.Background(), "con1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", entity.Domain)
+
+	_, err = c.GetEntity(context.Background(), "con2", "")
+	assert.ErrorIs(t, err, core.ErrorNotFound{})
+}
+
+func TestCommit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_core.NewMockStoreService(ctrl)
+	mockStore.EXPECT().
+		Commit(gomock.Any(), core.CommitModeExecute, "document", "signature", "", gomock.Any(), "").
+		Return(core.Message{ID: "m1"}, nil)
+
+	c := setupClient(t, NewServer(nil, mockStore, nil))
+
+	result, err := c.Commit(context.Background(), CommitRequest{Document: "document", Signature: "signature"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result), `"id":"m1"`)
+}
@@ -0,0 +1,188 @@
+// Package rpc provides the internal gRPC api used between concrnt components.
+// the public REST api is kept for external clients.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+var tracer = otel.Tracer("rpc")
+
+const serviceName = "concrnt.internal.v1.Internal"
+
+// InternalServer is the server api of the internal service
+type InternalServer interface {
+	GetEntity(ctx context.Context, req *GetEntityRequest) (*core.Entity, error)
+	Commit(ctx context.Context, req *CommitRequest) (*CommitResponse, error)
+	PostItem(ctx context.Context, req *PostItemRequest) (*core.TimelineItem, error)
+}
+
+type server struct {
+	entity   core.EntityService
+	store    core.StoreService
+	timeline core.TimelineService
+}
+
+// NewServer creates a new internal server backed by the services
+func NewServer(entity core.EntityService, store core.StoreService, timeline core.TimelineService) InternalServer {
+	return &server{
+		entity,
+		store,
+		timeline,
+	}
+}
+
+func (s *server) GetEntity(ctx context.Context, req *GetEntityRequest) (*core.Entity, error) {
+	ctx, span := tracer.Start(ctx, "Rpc.Server.GetEntity")
+	defer span.End()
+
+	var entity core.Entity
+	var err error
+	if req.Hint != "" {
+		entity, err = s.entity.GetWithHint(ctx, req.ID, req.Hint)
+	} else {
+		entity, err = s.entity.Get(ctx, req.ID)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err)
+	}
+
+	return &entity, nil
+}
+
+func (s *server) Commit(ctx context.Context, req *CommitRequest) (*CommitResponse, error) {
+	ctx, span := tracer.Start(ctx, "Rpc.Server.Commit")
+	defer span.End()
+
+	mode := req.Mode
+	if mode == core.CommitModeUnknown {
+		mode = core.CommitModeExecute
+	}
+
+	result, err := s.store.Commit(ctx, mode, req.Document, req.Signature, req.Option, req.Keys, req.IP)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err)
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err)
+	}
+
+	return &CommitResponse{Result: raw}, nil
+}
+
+func (s *server) PostItem(ctx context.Context, req *PostItemRequest) (*core.TimelineItem, error) {
+	ctx, span := tracer.Start(ctx, "Rpc.Server.PostItem")
+	defer span.End()
+
+	item, err := s.timeline.PostItem(ctx, req.Timeline, req.Item, req.Document, req.Signature)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err)
+	}
+
+	return &item, nil
+}
+
+func unaryHandler[Req any, Res any](method string, call func(InternalServer, context.Context, *Req) (*Res, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(InternalServer), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + serviceName + "/" + method,
+			}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return call(srv.(InternalServer), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*InternalServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("GetEntity", InternalServer.GetEntity),
+		unaryHandler("Commit", InternalServer.Commit),
+		unaryHandler("PostItem", InternalServer.PostItem),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// extractTrace continues the trace propagated by the client
+func extractTrace(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	return handler(ctx, req)
+}
+
+// NewGRPCServer creates a grpc server serving srv. tlsConfig should require client certificates (mTLS).
+// nil tlsConfig is only intended for tests.
+func NewGRPCServer(srv InternalServer, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(extractTrace),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := grpc.NewServer(opts...)
+	s.RegisterService(&serviceDesc, srv)
+	return s
+}
+
+// ListenAndServe serves srv on addr until the listener fails
+func ListenAndServe(addr string, srv InternalServer, tlsConfig *tls.Config) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return NewGRPCServer(srv, tlsConfig).Serve(lis)
+}
+
+// metadataCarrier adapts grpc metadata to propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -3,7 +3,6 @@
 package concurrent
 
 import (
-	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/google/wire"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
@@ -14,12 +13,14 @@ import (
 	"github.com/totegamma/concurrent/x/ack"
 	"github.com/totegamma/concurrent/x/association"
 	"github.com/totegamma/concurrent/x/auth"
+	"github.com/totegamma/concurrent/x/cache"
 	"github.com/totegamma/concurrent/x/domain"
 	"github.com/totegamma/concurrent/x/entity"
 	"github.com/totegamma/concurrent/x/job"
 	"github.com/totegamma/concurrent/x/jwt"
 	"github.com/totegamma/concurrent/x/key"
 	"github.com/totegamma/concurrent/x/message"
+	"github.com/totegamma/concurrent/x/mute"
 	"github.com/totegamma/concurrent/x/notification"
 	"github.com/totegamma/concurrent/x/policy"
 	"github.com/totegamma/concurrent/x/profile"
@@ -45,13 +46,14 @@ var jobServiceProvider = wire.NewSet(job.NewService, job.NewRepository)
 var entityServiceProvider = wire.NewSet(entity.NewService, entity.NewRepository, SetupJwtService, SetupSchemaService, SetupKeyService)
 
 // Lv2
-var timelineServiceProvider = wire.NewSet(timeline.NewService, timeline.NewRepository, SetupEntityService, SetupDomainService, SetupSchemaService, SetupSemanticidService, SetupSubscriptionService)
+var timelineServiceProvider = wire.NewSet(timeline.NewService, timeline.NewRepository, SetupEntityService, SetupDomainService, SetupSchemaService, SetupSemanticidService, SetupSubscriptionService, SetupMuteService)
+var muteServiceProvider = wire.NewSet(mute.NewService, mute.NewRepository, SetupEntityService)
 var subscriptionServiceProvider = wire.NewSet(subscription.NewService, subscription.NewRepository, SetupSchemaService, SetupEntityService)
 
 // Lv3
 var profileServiceProvider = wire.NewSet(profile.NewService, profile.NewRepository, SetupEntityService, SetupKeyService, SetupSchemaService, SetupSemanticidService)
-var authServiceProvider = wire.NewSet(auth.NewService, SetupEntityService, SetupDomainService, SetupKeyService)
-var ackServiceProvider = wire.NewSet(ack.NewService, ack.NewRepository, SetupEntityService, SetupKeyService)
+var authServiceProvider = wire.NewSet(auth.NewService, auth.NewRepository, SetupEntityService, SetupDomainService, SetupKeyService)
+var ackServiceProvider = wire.NewSet(ack.NewService, ack.NewRepository, SetupEntityService, SetupKeyService, SetupTimelineService)
 
 // Lv4
 var messageServiceProvider = wire.NewSet(message.NewService, message.NewRepository, SetupEntityService, SetupDomainService, SetupTimelineService, SetupKeyService, SetupSchemaService)
@@ -72,6 +74,7 @@ var storeServiceProvider = wire.NewSet(
 	SetupAckService,
 	SetupSubscriptionService,
 	SetupSemanticidService,
+	SetupAuthService,
 )
 
 // other
@@ -97,32 +100,37 @@ func SetupJobService(db *gorm.DB) core.JobService {
 	return nil
 }
 
-func SetupAckService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client client.Client, policy core.PolicyService, config core.Config) core.AckService {
+func SetupAckService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.AckService {
 	wire.Build(ackServiceProvider)
 	return nil
 }
 
-func SetupKeyService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client client.Client, config core.Config) core.KeyService {
+func SetupKeyService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client client.Client, config core.Config) core.KeyService {
 	wire.Build(keyServiceProvider)
 	return nil
 }
 
-func SetupMessageService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.MessageService {
+func SetupMessageService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.MessageService {
 	wire.Build(messageServiceProvider)
 	return nil
 }
 
-func SetupProfileService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client client.Client, policy core.PolicyService, config core.Config) core.ProfileService {
+func SetupProfileService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client client.Client, policy core.PolicyService, config core.Config) core.ProfileService {
 	wire.Build(profileServiceProvider)
 	return nil
 }
 
-func SetupAssociationService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.AssociationService {
+func SetupAssociationService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.AssociationService {
 	wire.Build(associationServiceProvider)
 	return nil
 }
 
-func SetupTimelineService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.TimelineService {
+func SetupMuteService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client client.Client, policy core.PolicyService, config core.Config) core.MuteService {
+	wire.Build(muteServiceProvider)
+	return nil
+}
+
+func SetupTimelineService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config) core.TimelineService {
 	wire.Build(timelineServiceProvider)
 	return nil
 }
@@ -132,12 +140,12 @@ func SetupDomainService(db *gorm.DB, client client.Client, config core.Config) c
 	return nil
 }
 
-func SetupEntityService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client client.Client, policy core.PolicyService, config core.Config) core.EntityService {
+func SetupEntityService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client client.Client, policy core.PolicyService, config core.Config) core.EntityService {
 	wire.Build(entityServiceProvider)
 	return nil
 }
 
-func SetupAuthService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client client.Client, policy core.PolicyService, config core.Config) core.AuthService {
+func SetupAuthService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client client.Client, policy core.PolicyService, config core.Config) core.AuthService {
 	wire.Build(authServiceProvider)
 	return nil
 }
@@ -152,12 +160,12 @@ func SetupSchemaService(db *gorm.DB) core.SchemaService {
 	return nil
 }
 
-func SetupStoreService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config, repositoryPath string) core.StoreService {
+func SetupStoreService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client client.Client, policy core.PolicyService, config core.Config, repositoryPath string, validator core.SchemaValidator) core.StoreService {
 	wire.Build(storeServiceProvider)
 	return nil
 }
 
-func SetupSubscriptionService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client client.Client, policy core.PolicyService, config core.Config) core.SubscriptionService {
+func SetupSubscriptionService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client client.Client, policy core.PolicyService, config core.Config) core.SubscriptionService {
 	wire.Build(subscriptionServiceProvider)
 	return nil
 }
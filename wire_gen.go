@@ -7,7 +7,6 @@
 package concurrent
 
 import (
-	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/google/wire"
 	"github.com/redis/go-redis/v9"
 	"github.com/totegamma/concurrent/client"
@@ -15,12 +14,14 @@ import (
 	"github.com/totegamma/concurrent/x/ack"
 	"github.com/totegamma/concurrent/x/association"
 	"github.com/totegamma/concurrent/x/auth"
+	"github.com/totegamma/concurrent/x/cache"
 	"github.com/totegamma/concurrent/x/domain"
 	"github.com/totegamma/concurrent/x/entity"
 	"github.com/totegamma/concurrent/x/job"
 	"github.com/totegamma/concurrent/x/jwt"
 	"github.com/totegamma/concurrent/x/key"
 	"github.com/totegamma/concurrent/x/message"
+	"github.com/totegamma/concurrent/x/mute"
 	"github.com/totegamma/concurrent/x/notification"
 	"github.com/totegamma/concurrent/x/policy"
 	"github.com/totegamma/concurrent/x/profile"
@@ -53,21 +54,22 @@ func SetupJobService(db *gorm.DB) core.JobService {
 	return jobService
 }
 
-func SetupAckService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client2 client.Client, policy2 core.PolicyService, config core.Config) core.AckService {
+func SetupAckService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.AckService {
 	repository := ack.NewRepository(db)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
 	keyService := SetupKeyService(db, rdb, mc, client2, config)
-	ackService := ack.NewService(repository, client2, entityService, keyService, config)
+	timelineService := SetupTimelineService(db, rdb, mc, keeper, client2, policy2, config)
+	ackService := ack.NewService(repository, client2, entityService, keyService, timelineService, config)
 	return ackService
 }
 
-func SetupKeyService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client2 client.Client, config core.Config) core.KeyService {
-	repository := key.NewRepository(db, mc, client2)
+func SetupKeyService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client2 client.Client, config core.Config) core.KeyService {
+	repository := key.NewRepository(db, rdb, mc, client2)
 	keyService := key.NewService(repository, config)
 	return keyService
 }
 
-func SetupMessageService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.MessageService {
+func SetupMessageService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.MessageService {
 	schemaService := SetupSchemaService(db)
 	repository := message.NewRepository(db, mc, schemaService)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
@@ -78,16 +80,16 @@ func SetupMessageService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, ke
 	return messageService
 }
 
-func SetupProfileService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client2 client.Client, policy2 core.PolicyService, config core.Config) core.ProfileService {
+func SetupProfileService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client2 client.Client, policy2 core.PolicyService, config core.Config) core.ProfileService {
 	schemaService := SetupSchemaService(db)
-	repository := profile.NewRepository(db, mc, schemaService)
+	repository := profile.NewRepository(db, mc, client2, schemaService)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
 	semanticIDService := SetupSemanticidService(db)
 	profileService := profile.NewService(repository, entityService, policy2, semanticIDService)
 	return profileService
 }
 
-func SetupAssociationService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.AssociationService {
+func SetupAssociationService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.AssociationService {
 	schemaService := SetupSchemaService(db)
 	repository := association.NewRepository(db, mc, schemaService)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
@@ -101,14 +103,22 @@ func SetupAssociationService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client
 	return associationService
 }
 
-func SetupTimelineService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.TimelineService {
+func SetupMuteService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client2 client.Client, policy2 core.PolicyService, config core.Config) core.MuteService {
+	repository := mute.NewRepository(db)
+	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
+	muteService := mute.NewService(repository, entityService)
+	return muteService
+}
+
+func SetupTimelineService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config) core.TimelineService {
 	schemaService := SetupSchemaService(db)
 	repository := timeline.NewRepository(db, rdb, mc, keeper, client2, schemaService, config)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
 	domainService := SetupDomainService(db, client2, config)
 	semanticIDService := SetupSemanticidService(db)
 	subscriptionService := SetupSubscriptionService(db, rdb, mc, client2, policy2, config)
-	timelineService := timeline.NewService(repository, entityService, domainService, semanticIDService, subscriptionService, policy2, config)
+	muteService := SetupMuteService(db, rdb, mc, client2, policy2, config)
+	timelineService := timeline.NewService(repository, entityService, domainService, semanticIDService, subscriptionService, muteService, policy2, config)
 	return timelineService
 }
 
@@ -118,7 +128,7 @@ func SetupDomainService(db *gorm.DB, client2 client.Client, config core.Config)
 	return domainService
 }
 
-func SetupEntityService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client2 client.Client, policy2 core.PolicyService, config core.Config) core.EntityService {
+func SetupEntityService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client2 client.Client, policy2 core.PolicyService, config core.Config) core.EntityService {
 	schemaService := SetupSchemaService(db)
 	repository := entity.NewRepository(db, mc, schemaService)
 	keyService := SetupKeyService(db, rdb, mc, client2, config)
@@ -127,11 +137,12 @@ func SetupEntityService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, cli
 	return entityService
 }
 
-func SetupAuthService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client2 client.Client, policy2 core.PolicyService, config core.Config) core.AuthService {
+func SetupAuthService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client2 client.Client, policy2 core.PolicyService, config core.Config) core.AuthService {
+	repository := auth.NewRepository(db)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
 	domainService := SetupDomainService(db, client2, config)
 	keyService := SetupKeyService(db, rdb, mc, client2, config)
-	authService := auth.NewService(rdb, config, entityService, domainService, keyService, policy2)
+	authService := auth.NewService(rdb, repository, config, entityService, domainService, keyService, policy2)
 	return authService
 }
 
@@ -147,26 +158,27 @@ func SetupSchemaService(db *gorm.DB) core.SchemaService {
 	return schemaService
 }
 
-func SetupStoreService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config, repositoryPath string) core.StoreService {
-	repository := store.NewRepository(db, rdb)
+func SetupStoreService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper timeline.Keeper, client2 client.Client, policy2 core.PolicyService, config core.Config, repositoryPath string, validator core.SchemaValidator) core.StoreService {
+	repository := store.NewRepository(db, rdb, repositoryPath)
 	keyService := SetupKeyService(db, rdb, mc, client2, config)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
 	messageService := SetupMessageService(db, rdb, mc, keeper, client2, policy2, config)
 	associationService := SetupAssociationService(db, rdb, mc, keeper, client2, policy2, config)
 	profileService := SetupProfileService(db, rdb, mc, client2, policy2, config)
 	timelineService := SetupTimelineService(db, rdb, mc, keeper, client2, policy2, config)
-	ackService := SetupAckService(db, rdb, mc, client2, policy2, config)
+	ackService := SetupAckService(db, rdb, mc, keeper, client2, policy2, config)
 	subscriptionService := SetupSubscriptionService(db, rdb, mc, client2, policy2, config)
 	semanticIDService := SetupSemanticidService(db)
-	storeService := store.NewService(repository, keyService, entityService, messageService, associationService, profileService, timelineService, ackService, subscriptionService, semanticIDService, config, repositoryPath)
+	authService := SetupAuthService(db, rdb, mc, client2, policy2, config)
+	storeService := store.NewService(repository, keyService, entityService, messageService, associationService, profileService, timelineService, ackService, subscriptionService, semanticIDService, authService, validator, config, repositoryPath)
 	return storeService
 }
 
-func SetupSubscriptionService(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, client2 client.Client, policy2 core.PolicyService, config core.Config) core.SubscriptionService {
+func SetupSubscriptionService(db *gorm.DB, rdb *redis.Client, mc cache.Cache, client2 client.Client, policy2 core.PolicyService, config core.Config) core.SubscriptionService {
 	schemaService := SetupSchemaService(db)
 	repository := subscription.NewRepository(db, schemaService)
 	entityService := SetupEntityService(db, rdb, mc, client2, policy2, config)
-	subscriptionService := subscription.NewService(repository, entityService, policy2)
+	subscriptionService := subscription.NewService(repository, entityService, policy2, config)
 	return subscriptionService
 }
 
@@ -205,16 +217,18 @@ var jobServiceProvider = wire.NewSet(job.NewService, job.NewRepository)
 var entityServiceProvider = wire.NewSet(entity.NewService, entity.NewRepository, SetupJwtService, SetupSchemaService, SetupKeyService)
 
 // Lv2
-var timelineServiceProvider = wire.NewSet(timeline.NewService, timeline.NewRepository, SetupEntityService, SetupDomainService, SetupSchemaService, SetupSemanticidService, SetupSubscriptionService)
+var timelineServiceProvider = wire.NewSet(timeline.NewService, timeline.NewRepository, SetupEntityService, SetupDomainService, SetupSchemaService, SetupSemanticidService, SetupSubscriptionService, SetupMuteService)
+
+var muteServiceProvider = wire.NewSet(mute.NewService, mute.NewRepository, SetupEntityService)
 
 var subscriptionServiceProvider = wire.NewSet(subscription.NewService, subscription.NewRepository, SetupSchemaService, SetupEntityService)
 
 // Lv3
 var profileServiceProvider = wire.NewSet(profile.NewService, profile.NewRepository, SetupEntityService, SetupKeyService, SetupSchemaService, SetupSemanticidService)
 
-var authServiceProvider = wire.NewSet(auth.NewService, SetupEntityService, SetupDomainService, SetupKeyService)
+var authServiceProvider = wire.NewSet(auth.NewService, auth.NewRepository, SetupEntityService, SetupDomainService, SetupKeyService)
 
-var ackServiceProvider = wire.NewSet(ack.NewService, ack.NewRepository, SetupEntityService, SetupKeyService)
+var ackServiceProvider = wire.NewSet(ack.NewService, ack.NewRepository, SetupEntityService, SetupKeyService, SetupTimelineService)
 
 // Lv4
 var messageServiceProvider = wire.NewSet(message.NewService, message.NewRepository, SetupEntityService, SetupDomainService, SetupTimelineService, SetupKeyService, SetupSchemaService)
@@ -232,6 +246,7 @@ var storeServiceProvider = wire.NewSet(store.NewService, store.NewRepository, Se
 	SetupAckService,
 	SetupSubscriptionService,
 	SetupSemanticidService,
+	SetupAuthService,
 )
 
 // other
@@ -3,7 +3,6 @@ package ack
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
@@ -14,16 +13,18 @@ type service struct {
 	client     client.Client
 	entity     core.EntityService
 	key        core.KeyService
+	timeline   core.TimelineService
 	config     core.Config
 }
 
 // NewService creates a new entity service
-func NewService(repository Repository, client client.Client, entity core.EntityService, key core.KeyService, config core.Config) core.AckService {
+func NewService(repository Repository, client client.Client, entity core.EntityService, key core.KeyService, timeline core.TimelineService, config core.Config) core.AckService {
 	return &service{
 		repository,
 		client,
 		entity,
 		key,
+		timeline,
 		config,
 	}
 }
@@ -69,12 +70,31 @@ func (s *service) Ack(ctx context.Context, mode core.CommitMode, document string
 			defer resp.Body.Close()
 		}
 
-		return s.repository.Ack(ctx, &core.Ack{
+		ack, err := s.repository.Ack(ctx, &core.Ack{
 			From:      doc.From,
 			To:        doc.To,
 			Document:  document,
 			Signature: signature,
 		})
+		if err != nil {
+			span.RecordError(err)
+			return ack, err
+		}
+
+		if to.Domain == s.config.FQDN {
+			// let the acked user know via the notification timeline
+			err = s.timeline.PublishEvent(ctx, core.Event{
+				Timeline:  core.NotificationTimelineSemanticID + "@" + doc.To,
+				Document:  document,
+				Signature: signature,
+				Resource:  ack,
+			})
+			if err != nil {
+				span.RecordError(err)
+			}
+		}
+
+		return ack, nil
 	case "unack":
 		to, err := s.entity.Get(ctx, doc.To)
 		if err != nil {
@@ -110,7 +130,7 @@ func (s *service) Ack(ctx context.Context, mode core.CommitMode, document string
 			Signature: signature,
 		})
 	default:
-		return core.Ack{}, fmt.Errorf("invalid object type")
+		return core.Ack{}, core.NewErrorInvalidArgument("invalid object type")
 	}
 }
 
@@ -0,0 +1,294 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+const (
+	retryBaseDelay = time.Minute
+	retryMaxDelay  = 6 * time.Hour
+)
+
+var deliveryMetrics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cc_activitypub_deliveries_total",
+		Help: "ActivityPub deliveries by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveryMetrics)
+}
+
+// deliveryJob is a signed POST of an activity to an inbox, queued in redis
+type deliveryJob struct {
+	Owner    string `json:"owner"`
+	Inbox    string `json:"inbox"`
+	Activity string `json:"activity"`
+	Attempt  int    `json:"attempt"`
+}
+
+// errPermanent marks deliveries which are not worth retrying
+type errPermanent struct {
+	status int
+}
+
+func (e errPermanent) Error() string {
+	return fmt.Sprintf("rejected with status %d", e.status)
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay > retryMaxDelay || delay <= 0 {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+// Publish converts a new message on a published timeline into a Create activity,
+// and a deletion of a published message into a Delete activity, and queues them for every follower.
+func (s *service) Publish(ctx context.Context, event core.Event) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.Publish")
+	defer span.End()
+
+	var doc core.DocumentBase[any]
+	err := json.Unmarshal([]byte(event.Document), &doc)
+	if err != nil {
+		return errors.Wrap(err, "failed to unmarshal document")
+	}
+
+	var activity Activity
+	var owner string
+
+	switch doc.Type {
+	case "message":
+		if event.Item == nil {
+			return nil
+		}
+		timelineID, _, _ := strings.Cut(event.Timeline, "@")
+		timeline, err := s.repository.GetTimeline(ctx, timelineID)
+		if err != nil {
+			return err
+		}
+		owner = timeline.Owner
+
+		// only the owner's own posts are published as the owner's actor
+		if doc.Signer != owner {
+			return nil
+		}
+
+		messageID := event.Item.ResourceID
+		note, ok := s.buildNote(messageID, owner, event.Document, doc.SignedAt)
+		if !ok {
+			return nil
+		}
+
+		// a message on several published timelines, or seen by several instances, is published once
+		first, err := s.repository.MarkPublished(ctx, messageID, owner, publishedTTL)
+		if err != nil || !first {
+			return err
+		}
+
+		activity = Activity{
+			Context: contextActivityStreams,
+			Type:    "Create",
+			ID:      note.ID + "/activity",
+			Actor:   note.AttributedTo,
+			Object:  note,
+			To:      note.To,
+			Cc:      note.Cc,
+		}
+
+	case "delete":
+		var deletion core.DeleteDocument
+		err := json.Unmarshal([]byte(event.Document), &deletion)
+		if err != nil || !strings.HasPrefix(deletion.Target, "m") {
+			return nil
+		}
+
+		owner, err = s.repository.GetPublished(ctx, deletion.Target)
+		if err != nil {
+			if errors.Is(err, core.ErrorNotFound{}) {
+				return nil
+			}
+			return err
+		}
+
+		first, err := s.repository.MarkPublished(ctx, deletion.Target+":deleted", owner, publishedTTL)
+		if err != nil || !first {
+			return err
+		}
+
+		actor := s.actorURL(owner)
+		noteID := s.noteURL(deletion.Target)
+		activity = Activity{
+			Context: contextActivityStreams,
+			Type:    "Delete",
+			ID:      noteID + "/delete",
+			Actor:   actor,
+			Object:  map[string]string{"id": noteID, "type": "Tombstone"},
+			To:      []string{publicCollection},
+			Cc:      []string{actor + "/followers"},
+		}
+
+	default:
+		return nil
+	}
+
+	return s.fanout(ctx, owner, activity)
+}
+
+// fanout queues the activity once per inbox, preferring shared inboxes
+func (s *service) fanout(ctx context.Context, owner string, activity Activity) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.fanout")
+	defer span.End()
+
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	followers, err := s.repository.ListFollowers(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	inboxes := make(map[string]bool)
+	for _, follower := range followers {
+		if follower.Suspended {
+			continue
+		}
+		inbox := follower.Inbox
+		if follower.SharedInbox != "" {
+			inbox = follower.SharedInbox
+		}
+		if inboxes[inbox] {
+			continue
+		}
+		inboxes[inbox] = true
+
+		err := s.repository.Enqueue(ctx, deliveryJob{Owner: owner, Inbox: inbox, Activity: string(data)})
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) post(ctx context.Context, job deliveryJob) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.post")
+	defer span.End()
+
+	key, err := s.privateKey(ctx, job.Owner)
+	if err != nil {
+		return err
+	}
+
+	// inboxes stored before https was required are dropped
+	if checkRemoteURL(job.Inbox) != nil {
+		return errPermanent{}
+	}
+
+	body := []byte(job.Activity)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.Inbox, bytes.NewReader(body))
+	if err != nil {
+		return errPermanent{}
+	}
+	req.Header.Set("Content-Type", activityContentType)
+
+	err = signRequest(req, s.actorURL(job.Owner)+"#main-key", key, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, remoteFetchMaxSize))
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests:
+		return errPermanent{status: res.StatusCode}
+	default:
+		return fmt.Errorf("unexpected status: %d", res.StatusCode)
+	}
+}
+
+// DeliverNext delivers one queued activity, waiting up to timeout for one.
+// failed deliveries are retried with exponential backoff until MaxAttempts.
+func (s *service) DeliverNext(ctx context.Context, timeout time.Duration) error {
+	job, err := s.repository.Dequeue(ctx, timeout)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return err
+	}
+
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.DeliverNext")
+	defer span.End()
+
+	err = s.post(ctx, job)
+	if err == nil {
+		deliveryMetrics.WithLabelValues("success").Inc()
+		return s.repository.RecordDelivery(ctx, job.Owner, job.Inbox, nil, s.config.SuspendAfter)
+	}
+
+	job.Attempt++
+	var permanent errPermanent
+	if !errors.As(err, &permanent) && job.Attempt < s.config.MaxAttempts {
+		deliveryMetrics.WithLabelValues("retry").Inc()
+		return s.repository.ScheduleRetry(ctx, job, time.Now().Add(retryDelay(job.Attempt)))
+	}
+
+	deliveryMetrics.WithLabelValues("failure").Inc()
+	logging.Warn(
+		ctx, "activitypub delivery dropped",
+		slog.String("inbox", job.Inbox),
+		slog.String("owner", job.Owner),
+		slog.Int("attempts", job.Attempt),
+		slog.String("error", err.Error()),
+		slog.String("module", "activitypub"),
+	)
+	return s.repository.RecordDelivery(ctx, job.Owner, job.Inbox, err, s.config.SuspendAfter)
+}
+
+func (s *service) PromoteRetries(ctx context.Context) error {
+	_, err := s.repository.PromoteRetries(ctx, time.Now())
+	return err
+}
+
+// DeliveryBacklog returns the number of deliveries waiting for a worker
+func (s *service) DeliveryBacklog(ctx context.Context) (int64, error) {
+	queued, _, err := s.repository.QueueDepth(ctx)
+	return queued, err
+}
+
+// RetryBacklog returns the number of failed deliveries waiting for a retry
+func (s *service) RetryBacklog(ctx context.Context) (int64, error) {
+	_, retrying, err := s.repository.QueueDepth(ctx)
+	return retrying, err
+}
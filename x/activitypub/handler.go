@@ -0,0 +1,210 @@
+package activitypub
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	apiPrefix        = "/api/v1"
+	maxInboxBodySize = 1 << 20
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	GetActor(c echo.Context) error
+	GetNote(c echo.Context) error
+	GetFollowers(c echo.Context) error
+	Inbox(c echo.Context) error
+	EnableTimeline(c echo.Context) error
+	DisableTimeline(c echo.Context) error
+	ListTimelines(c echo.Context) error
+	ListFollowers(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service: service}
+}
+
+func activityJSON(c echo.Context, body any) error {
+	c.Response().Header().Set(echo.HeaderContentType, activityContentType)
+	return c.JSON(http.StatusOK, body)
+}
+
+// GetActor returns the ActivityPub actor of a local entity
+func (h handler) GetActor(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.GetActor")
+	defer span.End()
+
+	actor, err := h.service.GetActor(ctx, c.Param("ccid"))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "actor not found"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return activityJSON(c, actor)
+}
+
+// GetNote returns a published message as a Note
+func (h handler) GetNote(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.GetNote")
+	defer span.End()
+
+	note, err := h.service.GetNote(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "note not found"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return activityJSON(c, note)
+}
+
+// GetFollowers returns the follower count of an actor
+func (h handler) GetFollowers(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.GetFollowers")
+	defer span.End()
+
+	followers, err := h.service.GetFollowers(ctx, c.Param("ccid"))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "actor not found"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return activityJSON(c, followers)
+}
+
+// Inbox receives signed activities from remote servers
+func (h handler) Inbox(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.Inbox")
+	defer span.End()
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxInboxBodySize))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	// the gateway strips the api prefix, but the remote server signed the public path
+	req := c.Request().Clone(ctx)
+	if !strings.HasPrefix(req.URL.Path, apiPrefix) {
+		req.URL.Path = apiPrefix + req.URL.Path
+		req.URL.RawPath = ""
+	}
+
+	err = h.service.Inbox(ctx, c.Param("ccid"), req, body)
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "actor not found"})
+		}
+		if errors.Is(err, core.ErrorPermissionDenied{}) {
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid signature"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// EnableTimeline publishes new messages of the timeline to ActivityPub
+func (h handler) EnableTimeline(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.EnableTimeline")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	timeline, err := h.service.EnableTimeline(ctx, requester, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "timeline not found"})
+		}
+		if errors.Is(err, core.ErrorPermissionDenied{}) {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "you are not the owner of the timeline"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": timeline})
+}
+
+// DisableTimeline stops publishing the timeline
+func (h handler) DisableTimeline(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.DisableTimeline")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	err := h.service.DisableTimeline(ctx, requester, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "timeline not found"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+// ListTimelines returns the published timelines of the requester
+func (h handler) ListTimelines(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.ListTimelines")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	timelines, err := h.service.ListTimelines(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": timelines})
+}
+
+// ListFollowers returns the ActivityPub followers of the requester with their delivery state
+func (h handler) ListFollowers(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ActivityPub.Handler.ListFollowers")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	followers, err := h.service.ListFollowers(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": followers})
+}
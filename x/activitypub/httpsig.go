@@ -0,0 +1,206 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxSignatureSkew bounds the difference between the Date header of a signed request and now
+const maxSignatureSkew = 12 * time.Hour
+
+func generateKey() (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	publicBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes})
+
+	return string(private), string(public), nil
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func parsePublicKey(data string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type")
+	}
+	return rsaKey, nil
+}
+
+func digest(body []byte) string {
+	hash := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// signingString builds the string to be signed from the listed headers
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, header := range headers {
+		switch header {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines = append(lines, "host: "+host)
+		default:
+			value := req.Header.Get(header)
+			if value == "" {
+				return "", fmt.Errorf("missing signed header: %s", header)
+			}
+			lines = append(lines, header+": "+value)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// signRequest signs the request with the draft-cavage http signature used across the fediverse.
+// body is nil for GET requests.
+func signRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	signing, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(signing))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID,
+		strings.Join(headers, " "),
+		base64.StdEncoding.EncodeToString(signature),
+	))
+
+	return nil
+}
+
+type signatureParams struct {
+	KeyID     string
+	Headers   []string
+	Signature []byte
+}
+
+func parseSignature(header string) (signatureParams, error) {
+	params := signatureParams{Headers: []string{"date"}}
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "keyId":
+			params.KeyID = value
+		case "headers":
+			params.Headers = strings.Fields(value)
+		case "signature":
+			signature, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return params, fmt.Errorf("invalid signature encoding")
+			}
+			params.Signature = signature
+		}
+	}
+	if params.KeyID == "" || params.Signature == nil {
+		return params, fmt.Errorf("incomplete signature header")
+	}
+	return params, nil
+}
+
+// verifyRequest verifies the http signature and digest of an incoming request and returns the key id.
+// fetchKey resolves the key id to the public key of its actor.
+func verifyRequest(req *http.Request, body []byte, fetchKey func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	params, err := parseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return "", err
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("invalid date header")
+	}
+	if skew := time.Since(date); skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return "", fmt.Errorf("date header is out of range")
+	}
+
+	signed := map[string]bool{}
+	for _, header := range params.Headers {
+		signed[header] = true
+	}
+	if !signed["(request-target)"] || !signed["date"] {
+		return "", fmt.Errorf("request-target and date must be signed")
+	}
+	if body != nil {
+		if !signed["digest"] || req.Header.Get("Digest") != digest(body) {
+			return "", fmt.Errorf("digest mismatch")
+		}
+	}
+
+	signing, err := signingString(req, params.Headers)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := fetchKey(params.KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(signing))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], params.Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature")
+	}
+
+	return params.KeyID, nil
+}
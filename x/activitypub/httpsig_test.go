@@ -0,0 +1,61 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	private, public, err := generateKey()
+	assert.NoError(t, err)
+
+	privateKey, err := parsePrivateKey(private)
+	assert.NoError(t, err)
+	publicKey, err := parsePublicKey(public)
+	assert.NoError(t, err)
+
+	body := []byte(`{"type":"Follow"}`)
+	req, err := http.NewRequest(http.MethodPost, "https://concrnt.example/api/v1/ap/actor/con1/inbox", bytes.NewReader(body))
+	assert.NoError(t, err)
+
+	err = signRequest(req, "https://remote.example/users/alice#main-key", privateKey, body)
+	assert.NoError(t, err)
+
+	fetchKey := func(keyID string) (*rsa.PublicKey, error) {
+		assert.Equal(t, "https://remote.example/users/alice#main-key", keyID)
+		return publicKey, nil
+	}
+
+	keyID, err := verifyRequest(req, body, fetchKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://remote.example/users/alice#main-key", keyID)
+
+	// tampered body
+	_, err = verifyRequest(req, []byte(`{"type":"Undo"}`), fetchKey)
+	assert.Error(t, err)
+
+	// path signed for another actor
+	req.URL.Path = "/api/v1/ap/actor/con2/inbox"
+	_, err = verifyRequest(req, body, fetchKey)
+	assert.Error(t, err)
+}
+
+func TestNoteContent(t *testing.T) {
+	content, ok := noteContent(`{"type":"message","body":{"body":"hello <world>\nbye"}}`)
+	assert.True(t, ok)
+	assert.Equal(t, "<p>hello &lt;world&gt;<br>bye</p>", content)
+
+	_, ok = noteContent(`{"type":"message","body":{"emoji":"x"}}`)
+	assert.False(t, ok)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 2*time.Minute, retryDelay(1))
+	assert.Equal(t, retryMaxDelay, retryDelay(20))
+	assert.Equal(t, retryMaxDelay, retryDelay(80))
+}
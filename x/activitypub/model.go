@@ -0,0 +1,71 @@
+package activitypub
+
+import (
+	"time"
+)
+
+const (
+	contextActivityStreams = "https://www.w3.org/ns/activitystreams"
+	contextSecurity        = "https://w3id.org/security/v1"
+	publicCollection       = "https://www.w3.org/ns/activitystreams#Public"
+	activityContentType    = "application/activity+json"
+)
+
+// PublicKey is the key of an actor used to verify its http signatures
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Endpoints holds the shared inbox of an actor
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// Actor is an ActivityPub actor
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	Type              string     `json:"type"`
+	ID                string     `json:"id"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+// Note is the object a concurrent message is published as
+type Note struct {
+	Context      any       `json:"@context,omitempty"`
+	Type         string    `json:"type"`
+	ID           string    `json:"id"`
+	AttributedTo string    `json:"attributedTo"`
+	Content      string    `json:"content"`
+	Published    time.Time `json:"published"`
+	To           []string  `json:"to"`
+	Cc           []string  `json:"cc,omitempty"`
+	URL          string    `json:"url,omitempty"`
+}
+
+// Activity is an ActivityPub activity. Object is either an id or an embedded object.
+type Activity struct {
+	Context any      `json:"@context,omitempty"`
+	Type    string   `json:"type"`
+	ID      string   `json:"id"`
+	Actor   string   `json:"actor"`
+	Object  any      `json:"object"`
+	To      []string `json:"to,omitempty"`
+	Cc      []string `json:"cc,omitempty"`
+}
+
+// OrderedCollection is used for the follower count of an actor
+type OrderedCollection struct {
+	Context    any    `json:"@context,omitempty"`
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	TotalItems int64  `json:"totalItems"`
+}
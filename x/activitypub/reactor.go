@@ -0,0 +1,106 @@
+package activitypub
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	reactorRefreshInterval = 10 * time.Second
+	deliveryPollTimeout    = 5 * time.Second
+)
+
+// Reactor watches published timelines and runs the delivery workers
+type Reactor interface {
+	Start(ctx context.Context)
+}
+
+type reactor struct {
+	service  Service
+	timeline core.TimelineService
+}
+
+// NewReactor creates a new reactor
+func NewReactor(service Service, timeline core.TimelineService) Reactor {
+	return &reactor{service: service, timeline: timeline}
+}
+
+func (r *reactor) Start(ctx context.Context) {
+	go r.watch(ctx)
+
+	for i := 0; i < r.service.Workers(); i++ {
+		go func() {
+			for ctx.Err() == nil {
+				err := r.service.DeliverNext(ctx, deliveryPollTimeout)
+				if err != nil && ctx.Err() == nil {
+					slog.Error("activitypub delivery failed", slog.String("error", err.Error()), slog.String("module", "activitypub"))
+					time.Sleep(time.Second)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(reactorRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := r.service.PromoteRetries(ctx)
+				if err != nil {
+					slog.Error("failed to promote activitypub retries", slog.String("error", err.Error()), slog.String("module", "activitypub"))
+				}
+			}
+		}
+	}()
+}
+
+// watch subscribes to the published timelines and refreshes the subscription when the set changes
+func (r *reactor) watch(ctx context.Context) {
+	request := make(chan []string)
+	events := make(chan core.Event)
+	go r.timeline.Realtime(ctx, request, events)
+
+	ticker := time.NewTicker(reactorRefreshInterval)
+	defer ticker.Stop()
+
+	// the new set is handed over inside the select, since Realtime may be blocked on sending an event
+	var current []string
+	var pending chan<- []string
+	refresh := func() {
+		timelines, err := r.service.PublicTimelines(ctx)
+		if err != nil {
+			slog.Error("failed to list activitypub timelines", slog.String("error", err.Error()), slog.String("module", "activitypub"))
+			return
+		}
+		slices.Sort(timelines)
+		if slices.Equal(timelines, current) {
+			return
+		}
+		current = timelines
+		pending = request
+	}
+
+	refresh()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case pending <- current:
+			pending = nil
+		case <-ticker.C:
+			refresh()
+		case event := <-events:
+			err := r.service.Publish(ctx, event)
+			if err != nil {
+				slog.Error("failed to publish to activitypub", slog.String("error", err.Error()), slog.String("module", "activitypub"))
+			}
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+var (
+	deliveryQueueKey = keyspace.ActivityPub.Key("delivery")
+	deliveryRetryKey = keyspace.ActivityPub.Key("delivery", "retry")
+)
+
+// Repository is the interface for activitypub repository
+type Repository interface {
+	GetActor(ctx context.Context, ccid string) (core.ApActor, error)
+	CreateActor(ctx context.Context, actor core.ApActor) (core.ApActor, error)
+
+	GetTimeline(ctx context.Context, timelineID string) (core.ApTimeline, error)
+	ListTimelines(ctx context.Context, owner string) ([]core.ApTimeline, error)
+	ListAllTimelines(ctx context.Context) ([]core.ApTimeline, error)
+	EnableTimeline(ctx context.Context, timeline core.ApTimeline) error
+	DisableTimeline(ctx context.Context, owner, timelineID string) error
+
+	UpsertFollower(ctx context.Context, follower core.ApFollower) error
+	DeleteFollower(ctx context.Context, owner, actor string) error
+	ListFollowers(ctx context.Context, owner string) ([]core.ApFollower, error)
+	CountFollowers(ctx context.Context, owner string) (int64, error)
+	RecordDelivery(ctx context.Context, owner, inbox string, deliveryErr error, suspendAfter int) error
+
+	Enqueue(ctx context.Context, job deliveryJob) error
+	Dequeue(ctx context.Context, timeout time.Duration) (deliveryJob, error)
+	ScheduleRetry(ctx context.Context, job deliveryJob, at time.Time) error
+	PromoteRetries(ctx context.Context, now time.Time) (int, error)
+	QueueDepth(ctx context.Context) (int64, int64, error)
+	MarkPublished(ctx context.Context, messageID, owner string, ttl time.Duration) (bool, error)
+	GetPublished(ctx context.Context, messageID string) (string, error)
+}
+
+type repository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewRepository creates a new activitypub repository
+func NewRepository(db *gorm.DB, rdb *redis.Client) Repository {
+	return &repository{db, rdb}
+}
+
+func (r *repository) GetActor(ctx context.Context, ccid string) (core.ApActor, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.GetActor")
+	defer span.End()
+
+	var actor core.ApActor
+	err := r.db.WithContext(ctx).First(&actor, "cc_id = ?", ccid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.ApActor{}, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return core.ApActor{}, err
+	}
+	return actor, nil
+}
+
+// CreateActor stores the actor unless one already exists, and returns the stored one
+func (r *repository) CreateActor(ctx context.Context, actor core.ApActor) (core.ApActor, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.CreateActor")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&actor).Error
+	if err != nil {
+		span.RecordError(err)
+		return core.ApActor{}, err
+	}
+	return r.GetActor(ctx, actor.CCID)
+}
+
+func (r *repository) GetTimeline(ctx context.Context, timelineID string) (core.ApTimeline, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.GetTimeline")
+	defer span.End()
+
+	var timeline core.ApTimeline
+	err := r.db.WithContext(ctx).First(&timeline, "timeline_id = ?", timelineID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.ApTimeline{}, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return core.ApTimeline{}, err
+	}
+	return timeline, nil
+}
+
+func (r *repository) ListTimelines(ctx context.Context, owner string) ([]core.ApTimeline, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.ListTimelines")
+	defer span.End()
+
+	var timelines []core.ApTimeline
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Find(&timelines).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return timelines, err
+}
+
+func (r *repository) ListAllTimelines(ctx context.Context) ([]core.ApTimeline, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.ListAllTimelines")
+	defer span.End()
+
+	var timelines []core.ApTimeline
+	err := r.db.WithContext(ctx).Find(&timelines).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return timelines, err
+}
+
+func (r *repository) EnableTimeline(ctx context.Context, timeline core.ApTimeline) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.EnableTimeline")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&timeline).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *repository) DisableTimeline(ctx context.Context, owner, timelineID string) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.DisableTimeline")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("timeline_id = ? AND owner = ?", timelineID, owner).Delete(&core.ApTimeline{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
+
+// UpsertFollower stores the follower. following again clears its failure record.
+func (r *repository) UpsertFollower(ctx context.Context, follower core.ApFollower) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.UpsertFollower")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "owner"}, {Name: "actor"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"inbox":        follower.Inbox,
+			"shared_inbox": follower.SharedInbox,
+			"failures":     0,
+			"last_error":   "",
+			"suspended":    false,
+		}),
+	}).Create(&follower).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *repository) DeleteFollower(ctx context.Context, owner, actor string) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.DeleteFollower")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Where("owner = ? AND actor = ?", owner, actor).Delete(&core.ApFollower{}).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *repository) ListFollowers(ctx context.Context, owner string) ([]core.ApFollower, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.ListFollowers")
+	defer span.End()
+
+	var followers []core.ApFollower
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Find(&followers).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return followers, err
+}
+
+func (r *repository) CountFollowers(ctx context.Context, owner string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.CountFollowers")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.ApFollower{}).Where("owner = ?", owner).Count(&count).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return count, err
+}
+
+// RecordDelivery updates the failure record of every follower of owner served by the inbox.
+// followers are suspended after suspendAfter consecutive failures.
+func (r *repository) RecordDelivery(ctx context.Context, owner, inbox string, deliveryErr error, suspendAfter int) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.RecordDelivery")
+	defer span.End()
+
+	query := r.db.WithContext(ctx).
+		Model(&core.ApFollower{}).
+		Where("owner = ? AND (inbox = ? OR shared_inbox = ?)", owner, inbox, inbox)
+
+	var err error
+	if deliveryErr == nil {
+		err = query.Where("failures > 0").Updates(map[string]any{"failures": 0, "last_error": ""}).Error
+	} else {
+		err = query.Updates(map[string]any{
+			"failures":     gorm.Expr("failures + 1"),
+			"last_error":   deliveryErr.Error(),
+			"last_failure": time.Now(),
+			"suspended":    gorm.Expr("failures + 1 >= ?", suspendAfter),
+		}).Error
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *repository) Enqueue(ctx context.Context, job deliveryJob) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.Enqueue")
+	defer span.End()
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	err = r.rdb.LPush(ctx, deliveryQueueKey, data).Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// Dequeue blocks until a job is available. redis.Nil is returned on timeout.
+func (r *repository) Dequeue(ctx context.Context, timeout time.Duration) (deliveryJob, error) {
+	result, err := r.rdb.BRPop(ctx, timeout, deliveryQueueKey).Result()
+	if err != nil {
+		return deliveryJob{}, err
+	}
+
+	var job deliveryJob
+	err = json.Unmarshal([]byte(result[1]), &job)
+	return job, err
+}
+
+func (r *repository) ScheduleRetry(ctx context.Context, job deliveryJob, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.ScheduleRetry")
+	defer span.End()
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	err = r.rdb.ZAdd(ctx, deliveryRetryKey, redis.Z{Score: float64(at.Unix()), Member: data}).Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// QueueDepth returns the number of queued deliveries and of deliveries waiting for a retry
+func (r *repository) QueueDepth(ctx context.Context) (int64, int64, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.QueueDepth")
+	defer span.End()
+
+	queued, err := r.rdb.LLen(ctx, deliveryQueueKey).Result()
+	if err != nil {
+		span.RecordError(err)
+		return 0, 0, err
+	}
+	retrying, err := r.rdb.ZCard(ctx, deliveryRetryKey).Result()
+	if err != nil {
+		span.RecordError(err)
+		return 0, 0, err
+	}
+	return queued, retrying, nil
+}
+
+// PromoteRetries moves retries which are due back to the queue
+func (r *repository) PromoteRetries(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.PromoteRetries")
+	defer span.End()
+
+	due, err := r.rdb.ZRangeByScore(ctx, deliveryRetryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	promoted := 0
+	for _, member := range due {
+		// only the instance which removed the member requeues it
+		removed, err := r.rdb.ZRem(ctx, deliveryRetryKey, member).Result()
+		if err != nil {
+			span.RecordError(err)
+			return promoted, err
+		}
+		if removed == 0 {
+			continue
+		}
+		err = r.rdb.LPush(ctx, deliveryQueueKey, member).Err()
+		if err != nil {
+			span.RecordError(err)
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// MarkPublished records that the message was published by owner. false is returned if it already was.
+func (r *repository) MarkPublished(ctx context.Context, messageID, owner string, ttl time.Duration) (bool, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.MarkPublished")
+	defer span.End()
+
+	ok, err := r.rdb.SetNX(ctx, keyspace.ActivityPub.Key("published", messageID), owner, ttl).Result()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return ok, err
+}
+
+// GetPublished returns the owner who published the message
+func (r *repository) GetPublished(ctx context.Context, messageID string) (string, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Repository.GetPublished")
+	defer span.End()
+
+	owner, err := r.rdb.Get(ctx, keyspace.ActivityPub.Key("published", messageID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return "", err
+	}
+	return owner, nil
+}
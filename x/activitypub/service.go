@@ -0,0 +1,483 @@
+// Package activitypub publishes messages of selected timelines to ActivityPub followers
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+var tracer = otel.Tracer("activitypub")
+
+const (
+	defaultDeliveryWorkers = 4
+	defaultMaxAttempts     = 8
+	defaultSuspendAfter    = 10
+	publishedTTL           = 30 * 24 * time.Hour
+	remoteFetchTimeout     = 10 * time.Second
+	remoteFetchMaxSize     = 1 << 20
+)
+
+// Config configures the bridge
+type Config struct {
+	FQDN            string
+	DeliveryWorkers int // concurrent deliveries per instance
+	MaxAttempts     int // attempts before a delivery is dropped
+	SuspendAfter    int // consecutive dropped deliveries before a follower is suspended
+}
+
+// Service is the interface for activitypub service
+type Service interface {
+	GetActor(ctx context.Context, ccid string) (Actor, error)
+	GetNote(ctx context.Context, messageID string) (Note, error)
+	GetFollowers(ctx context.Context, ccid string) (OrderedCollection, error)
+	Inbox(ctx context.Context, ccid string, req *http.Request, body []byte) error
+
+	EnableTimeline(ctx context.Context, requester, timelineID string) (core.ApTimeline, error)
+	DisableTimeline(ctx context.Context, requester, timelineID string) error
+	ListTimelines(ctx context.Context, requester string) ([]core.ApTimeline, error)
+	ListFollowers(ctx context.Context, requester string) ([]core.ApFollower, error)
+
+	PublicTimelines(ctx context.Context) ([]string, error)
+	Publish(ctx context.Context, event core.Event) error
+	DeliverNext(ctx context.Context, timeout time.Duration) error
+	PromoteRetries(ctx context.Context) error
+	DeliveryBacklog(ctx context.Context) (int64, error)
+	RetryBacklog(ctx context.Context) (int64, error)
+	Workers() int
+}
+
+type service struct {
+	repository Repository
+	entity     core.EntityService
+	message    core.MessageService
+	timeline   core.TimelineService
+	config     Config
+	client     *http.Client
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+}
+
+// NewService creates a new activitypub service
+func NewService(repository Repository, entity core.EntityService, message core.MessageService, timeline core.TimelineService, config Config) Service {
+	if config.DeliveryWorkers <= 0 {
+		config.DeliveryWorkers = defaultDeliveryWorkers
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.SuspendAfter <= 0 {
+		config.SuspendAfter = defaultSuspendAfter
+	}
+	return &service{
+		repository: repository,
+		entity:     entity,
+		message:    message,
+		timeline:   timeline,
+		config:     config,
+		client:     newClient(),
+		keys:       make(map[string]*rsa.PrivateKey),
+	}
+}
+
+func (s *service) base() string {
+	return "https://" + s.config.FQDN + apiPrefix + "/ap"
+}
+
+func (s *service) actorURL(ccid string) string {
+	return s.base() + "/actor/" + ccid
+}
+
+func (s *service) noteURL(messageID string) string {
+	return s.base() + "/note/" + messageID
+}
+
+func (s *service) Workers() int {
+	return s.config.DeliveryWorkers
+}
+
+// privateKey returns the parsed signing key of a local actor
+func (s *service) privateKey(ctx context.Context, ccid string) (*rsa.PrivateKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[ccid]
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	actor, err := s.repository.GetActor(ctx, ccid)
+	if err != nil {
+		return nil, err
+	}
+	key, err = parsePrivateKey(actor.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.keys[ccid] = key
+	s.mu.Unlock()
+	return key, nil
+}
+
+// GetActor returns the actor of a local entity which has published at least one timeline
+func (s *service) GetActor(ctx context.Context, ccid string) (Actor, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.GetActor")
+	defer span.End()
+
+	actor, err := s.repository.GetActor(ctx, ccid)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	entity, err := s.entity.Get(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return Actor{}, err
+	}
+	if entity.Domain != s.config.FQDN {
+		return Actor{}, core.NewErrorNotFound()
+	}
+
+	username := ccid
+	if entity.Alias != nil && *entity.Alias != "" {
+		username = *entity.Alias
+	}
+
+	id := s.actorURL(ccid)
+	return Actor{
+		Context:           []string{contextActivityStreams, contextSecurity},
+		Type:              "Person",
+		ID:                id,
+		PreferredUsername: username,
+		Inbox:             id + "/inbox",
+		Followers:         id + "/followers",
+		PublicKey: PublicKey{
+			ID:           id + "#main-key",
+			Owner:        id,
+			PublicKeyPem: actor.PublicKey,
+		},
+	}, nil
+}
+
+// noteContent renders the text body of a message as html. false is returned for bodies without text.
+func noteContent(document string) (string, bool) {
+	var doc core.DocumentBase[map[string]any]
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return "", false
+	}
+	text, ok := doc.Body["body"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return "", false
+	}
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + "</p>", true
+}
+
+func (s *service) buildNote(messageID, owner, document string, published time.Time) (Note, bool) {
+	content, ok := noteContent(document)
+	if !ok {
+		return Note{}, false
+	}
+	actor := s.actorURL(owner)
+	return Note{
+		Type:         "Note",
+		ID:           s.noteURL(messageID),
+		AttributedTo: actor,
+		Content:      content,
+		Published:    published,
+		To:           []string{publicCollection},
+		Cc:           []string{actor + "/followers"},
+	}, true
+}
+
+// GetNote returns a message which was published to ActivityPub
+func (s *service) GetNote(ctx context.Context, messageID string) (Note, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.GetNote")
+	defer span.End()
+
+	owner, err := s.repository.GetPublished(ctx, messageID)
+	if err != nil {
+		return Note{}, err
+	}
+
+	message, err := s.message.GetAsGuest(ctx, messageID)
+	if err != nil {
+		return Note{}, core.NewErrorNotFound()
+	}
+
+	note, ok := s.buildNote(messageID, owner, message.Document, message.CDate)
+	if !ok {
+		return Note{}, core.NewErrorNotFound()
+	}
+	note.Context = contextActivityStreams
+	return note, nil
+}
+
+func (s *service) GetFollowers(ctx context.Context, ccid string) (OrderedCollection, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.GetFollowers")
+	defer span.End()
+
+	if _, err := s.repository.GetActor(ctx, ccid); err != nil {
+		return OrderedCollection{}, err
+	}
+
+	count, err := s.repository.CountFollowers(ctx, ccid)
+	if err != nil {
+		return OrderedCollection{}, err
+	}
+
+	return OrderedCollection{
+		Context:    contextActivityStreams,
+		Type:       "OrderedCollection",
+		ID:         s.actorURL(ccid) + "/followers",
+		TotalItems: count,
+	}, nil
+}
+
+// EnableTimeline publishes new messages of the timeline to the followers of its owner.
+// the actor key pair of the owner is created on first use.
+func (s *service) EnableTimeline(ctx context.Context, requester, timelineID string) (core.ApTimeline, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.EnableTimeline")
+	defer span.End()
+
+	timeline, err := s.timeline.GetTimeline(ctx, timelineID)
+	if err != nil {
+		return core.ApTimeline{}, err
+	}
+	if timeline.Owner != requester {
+		return core.ApTimeline{}, core.NewErrorPermissionDenied()
+	}
+
+	if _, err := s.repository.GetActor(ctx, requester); err != nil {
+		if !errors.Is(err, core.ErrorNotFound{}) {
+			return core.ApTimeline{}, err
+		}
+		private, public, err := generateKey()
+		if err != nil {
+			span.RecordError(err)
+			return core.ApTimeline{}, err
+		}
+		_, err = s.repository.CreateActor(ctx, core.ApActor{CCID: requester, PublicKey: public, PrivateKey: private})
+		if err != nil {
+			return core.ApTimeline{}, err
+		}
+	}
+
+	entry := core.ApTimeline{TimelineID: timeline.ID, Owner: requester}
+	err = s.repository.EnableTimeline(ctx, entry)
+	if err != nil {
+		return core.ApTimeline{}, err
+	}
+	return s.repository.GetTimeline(ctx, timeline.ID)
+}
+
+func (s *service) DisableTimeline(ctx context.Context, requester, timelineID string) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.DisableTimeline")
+	defer span.End()
+
+	timeline, err := s.timeline.GetTimeline(ctx, timelineID)
+	if err != nil {
+		return err
+	}
+	return s.repository.DisableTimeline(ctx, requester, timeline.ID)
+}
+
+func (s *service) ListTimelines(ctx context.Context, requester string) ([]core.ApTimeline, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.ListTimelines")
+	defer span.End()
+
+	return s.repository.ListTimelines(ctx, requester)
+}
+
+func (s *service) ListFollowers(ctx context.Context, requester string) ([]core.ApFollower, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.ListFollowers")
+	defer span.End()
+
+	return s.repository.ListFollowers(ctx, requester)
+}
+
+// PublicTimelines returns full ids of every published timeline
+func (s *service) PublicTimelines(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.PublicTimelines")
+	defer span.End()
+
+	timelines, err := s.repository.ListAllTimelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(timelines))
+	for i, timeline := range timelines {
+		ids[i] = timeline.TimelineID + "@" + s.config.FQDN
+	}
+	return ids, nil
+}
+
+// fetchActor retrieves a remote actor. the request is signed by the local actor as some servers require it.
+func (s *service) fetchActor(ctx context.Context, url string, signer string) (Actor, error) {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.fetchActor")
+	defer span.End()
+
+	err := checkRemoteURL(url)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Actor{}, err
+	}
+	req.Header.Set("Accept", activityContentType)
+
+	key, err := s.privateKey(ctx, signer)
+	if err != nil {
+		return Actor{}, err
+	}
+	err = signRequest(req, s.actorURL(signer)+"#main-key", key, nil)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return Actor{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Actor{}, fmt.Errorf("failed to fetch actor: %d", res.StatusCode)
+	}
+
+	var actor Actor
+	err = json.NewDecoder(io.LimitReader(res.Body, remoteFetchMaxSize)).Decode(&actor)
+	if err != nil {
+		return Actor{}, err
+	}
+	if actor.ID != url || actor.Inbox == "" {
+		return Actor{}, fmt.Errorf("invalid actor document")
+	}
+	if err := checkRemoteURL(actor.Inbox); err != nil {
+		return Actor{}, err
+	}
+	if actor.Endpoints != nil && actor.Endpoints.SharedInbox != "" {
+		if err := checkRemoteURL(actor.Endpoints.SharedInbox); err != nil {
+			return Actor{}, err
+		}
+	}
+	return actor, nil
+}
+
+type incomingActivity struct {
+	Type   string          `json:"type"`
+	ID     string          `json:"id"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// objectID returns the id of an object which is either embedded or referenced
+func objectID(object json.RawMessage) string {
+	var id string
+	if json.Unmarshal(object, &id) == nil {
+		return id
+	}
+	var embedded struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(object, &embedded)
+	return embedded.ID
+}
+
+// Inbox accepts Follow and Undo Follow activities addressed to a local actor.
+// other activities are ignored.
+func (s *service) Inbox(ctx context.Context, ccid string, req *http.Request, body []byte) error {
+	ctx, span := tracer.Start(ctx, "ActivityPub.Service.Inbox")
+	defer span.End()
+
+	if _, err := s.repository.GetActor(ctx, ccid); err != nil {
+		return err
+	}
+
+	var activity incomingActivity
+	err := json.Unmarshal(body, &activity)
+	if err != nil {
+		return errors.Wrap(err, "invalid activity")
+	}
+
+	var remote Actor
+	_, err = verifyRequest(req, body, func(keyID string) (*rsa.PublicKey, error) {
+		owner, _, _ := strings.Cut(keyID, "#")
+		if owner != activity.Actor {
+			return nil, fmt.Errorf("signer is not the actor of the activity")
+		}
+		remote, err = s.fetchActor(ctx, owner, ccid)
+		if err != nil {
+			return nil, err
+		}
+		if remote.PublicKey.ID != keyID {
+			return nil, fmt.Errorf("unknown key")
+		}
+		return parsePublicKey(remote.PublicKey.PublicKeyPem)
+	})
+	if err != nil {
+		span.RecordError(err)
+		return core.NewErrorPermissionDenied()
+	}
+
+	local := s.actorURL(ccid)
+
+	switch activity.Type {
+	case "Follow":
+		if objectID(activity.Object) != local {
+			return fmt.Errorf("follow target mismatch")
+		}
+
+		sharedInbox := ""
+		if remote.Endpoints != nil {
+			sharedInbox = remote.Endpoints.SharedInbox
+		}
+		err = s.repository.UpsertFollower(ctx, core.ApFollower{
+			Owner:       ccid,
+			Actor:       remote.ID,
+			Inbox:       remote.Inbox,
+			SharedInbox: sharedInbox,
+		})
+		if err != nil {
+			return err
+		}
+
+		accept, err := json.Marshal(Activity{
+			Context: contextActivityStreams,
+			Type:    "Accept",
+			ID:      local + "#accepts/" + activity.ID,
+			Actor:   local,
+			Object:  json.RawMessage(body),
+		})
+		if err != nil {
+			return err
+		}
+		return s.repository.Enqueue(ctx, deliveryJob{Owner: ccid, Inbox: remote.Inbox, Activity: string(accept)})
+
+	case "Undo":
+		var undone incomingActivity
+		if json.Unmarshal(activity.Object, &undone) == nil && undone.Type != "" && undone.Type != "Follow" {
+			return nil
+		}
+		return s.repository.DeleteFollower(ctx, ccid, remote.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package activitypub
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// actor documents and inboxes come from unauthenticated requests, so the client only reaches
+// public https servers. otherwise the inbox would let anyone make the domain request its own network
+
+// checkRemoteURL checks that the url is an https url the bridge may request
+func checkRemoteURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("remote url must be https: %s", raw)
+	}
+	return nil
+}
+
+// isPublicIP reports whether the address is routable on the internet
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified())
+}
+
+// refusePrivate is the control of the dialer, run with the resolved address of every connection
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("refused to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// newClient creates the http client of the bridge
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: remoteFetchTimeout,
+		Control: refusePrivate,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the remote, so the addresses could not be checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   remoteFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return checkRemoteURL(req.URL.String())
+		},
+	}
+}
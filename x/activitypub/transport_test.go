@@ -0,0 +1,45 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRemoteURL(t *testing.T) {
+	assert.NoError(t, checkRemoteURL("https://remote.example/users/alice"))
+	assert.Error(t, checkRemoteURL("http://remote.example/users/alice"))
+	assert.Error(t, checkRemoteURL("file:///etc/passwd"))
+	assert.Error(t, checkRemoteURL("https:///users/alice"))
+}
+
+func TestRefusePrivate(t *testing.T) {
+	assert.NoError(t, refusePrivate("tcp", "93.184.216.34:443", nil))
+	assert.NoError(t, refusePrivate("tcp6", "[2606:2800:220:1:248:1893:25c8:1946]:443", nil))
+
+	for _, address := range []string{
+		"127.0.0.1:443",
+		"[::1]:443",
+		"10.0.0.1:443",
+		"172.16.0.1:443",
+		"192.168.1.1:443",
+		"169.254.169.254:80",
+		"[fe80::1]:443",
+		"[fd00::1]:443",
+		"0.0.0.0:443",
+	} {
+		assert.Error(t, refusePrivate("tcp", address, nil), address)
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached the loopback server")
+	}))
+	defer server.Close()
+
+	_, err := newClient().Get(server.URL)
+	assert.Error(t, err)
+}
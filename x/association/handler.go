@@ -4,6 +4,7 @@ package association
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"github.com/totegamma/concurrent/core"
@@ -19,6 +20,9 @@ type Handler interface {
 	GetCounts(c echo.Context) error
 	GetOwnByTarget(c echo.Context) error
 	GetAttached(c echo.Context) error
+	GetReposters(c echo.Context) error
+	GetThread(c echo.Context) error
+	GetThreadTimeline(c echo.Context) error
 }
 
 type handler struct {
@@ -130,3 +134,71 @@ func (h handler) GetFiltered(c echo.Context) error {
 		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": associations})
 	}
 }
+
+// GetReposters returns the repost count and reposters of a message
+func (h handler) GetReposters(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Association.Handler.GetReposters")
+	defer span.End()
+
+	messageID := c.Param("id")
+
+	count, err := h.service.CountReposts(ctx, messageID)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	reposters, err := h.service.GetReposters(ctx, messageID)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": echo.Map{"count": count, "reposters": reposters}})
+}
+
+// GetThread returns the reply tree of a message
+func (h handler) GetThread(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Association.Handler.GetThread")
+	defer span.End()
+
+	messageID := c.Param("id")
+	requester, _ := ctx.Value(core.RequesterIdCtxKey).(string)
+
+	query := core.ThreadQuery{Requester: requester}
+	for param, target := range map[string]*int{"depth": &query.Depth, "limit": &query.Limit} {
+		if value := c.QueryParam(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid " + param})
+			}
+			*target = n
+		}
+	}
+
+	thread, err := h.service.GetThread(ctx, messageID, query)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Message not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": thread})
+}
+
+// GetThreadTimeline returns the timeline the replies of the conversation of a message are delivered to
+func (h handler) GetThreadTimeline(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Association.Handler.GetThreadTimeline")
+	defer span.End()
+
+	timeline, err := h.service.GetThreadTimeline(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Thread timeline not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": timeline})
+}
@@ -2,6 +2,8 @@ package association
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"gorm.io/gorm"
 	"log/slog"
 	"strconv"
@@ -9,32 +11,42 @@ import (
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/pkg/errors"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
+	"gorm.io/gorm/clause"
 )
 
+// summaries are dropped on every change of the target, the expiration only bounds the staleness after Clean
+const associationCountsTTL = 600
+
 // Repository is the interface for association repository
 type Repository interface {
 	Create(ctx context.Context, association core.Association) (core.Association, error)
+	CreateBatch(ctx context.Context, associations []core.Association) ([]bool, error)
 	Get(ctx context.Context, id string) (core.Association, error)
 	GetOwn(ctx context.Context, author string) ([]core.Association, error)
 	Delete(ctx context.Context, id string) error
 	GetByTarget(ctx context.Context, targetID string) ([]core.Association, error)
 	GetCountsBySchema(ctx context.Context, messageID string) (map[string]int64, error)
+	GetCountsBySchemaForTargets(ctx context.Context, targetIDs []string) (map[string]map[string]int64, error)
 	GetBySchema(ctx context.Context, messageID string, schema string) ([]core.Association, error)
 	GetCountsBySchemaAndVariant(ctx context.Context, messageID string, schema string) (map[string]int64, error)
 	GetBySchemaAndVariant(ctx context.Context, messageID string, schema string, variant string) ([]core.Association, error)
 	GetOwnByTarget(ctx context.Context, targetID, author string) ([]core.Association, error)
+	GetReposters(ctx context.Context, messageID string) ([]string, error)
+	CountReposts(ctx context.Context, messageID string) (int64, error)
 	Count(ctx context.Context) (int64, error)
 	Clean(ctx context.Context, ccid string) error
+	ReconcileCounts(ctx context.Context) (int64, error)
 }
 
 type repository struct {
 	db     *gorm.DB
-	mc     *memcache.Client
+	mc     cache.Cache
 	schema core.SchemaService
 }
 
 // NewRepository creates a new association repository
-func NewRepository(db *gorm.DB, mc *memcache.Client, schema core.SchemaService) Repository {
+func NewRepository(db *gorm.DB, mc cache.Cache, schema core.SchemaService) Repository {
 	return &repository{db, mc, schema}
 }
 
@@ -99,7 +111,13 @@ func (r *repository) Create(ctx context.Context, association core.Association) (
 	}
 	association.SchemaID = schemaID
 
-	err = r.db.WithContext(ctx).Create(&association).Error
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&association).Error
+		if err != nil {
+			return err
+		}
+		return addCount(tx, association.Target, association.SchemaID, association.Variant, 1)
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return association, core.NewErrorAlreadyExists()
@@ -109,12 +127,76 @@ func (r *repository) Create(ctx context.Context, association core.Association) (
 	}
 
 	r.mc.Increment("association_count", 1)
+	r.mc.Delete(associationCountsKey(association.Target))
 
 	association.ID = "a" + association.ID
 
 	return association, nil
 }
 
+// CreateBatch inserts the associations in a single transaction. associations which already exist,
+// by their id or their content, are skipped. the result tells which ones were inserted, at their index
+func (r *repository) CreateBatch(ctx context.Context, associations []core.Association) ([]bool, error) {
+	ctx, span := tracer.Start(ctx, "Association.Repository.CreateBatch")
+	defer span.End()
+
+	rows := make([]core.Association, len(associations))
+	for i, association := range associations {
+		if len(association.ID) == 27 {
+			if association.ID[0] != 'a' {
+				return nil, errors.New("association ID must start with 'a'. got " + association.ID)
+			}
+			association.ID = association.ID[1:]
+		}
+		if len(association.ID) != 26 {
+			return nil, errors.New("association ID must be 26 characters long. got " + association.ID)
+		}
+
+		schemaID, err := r.schema.UrlToID(ctx, association.Schema)
+		if err != nil {
+			return nil, err
+		}
+		association.SchemaID = schemaID
+		rows[i] = association
+	}
+
+	inserted := make([]bool, len(rows))
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range rows {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows[i])
+			if result.Error != nil {
+				return result.Error
+			}
+			inserted[i] = result.RowsAffected > 0
+			if inserted[i] {
+				err := addCount(tx, rows[i].Target, rows[i].SchemaID, rows[i].Variant, 1)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var count uint64
+	for i, row := range rows {
+		if !inserted[i] {
+			continue
+		}
+		count++
+		r.mc.Delete(associationCountsKey(row.Target))
+	}
+	if count > 0 {
+		r.mc.Increment("association_count", count)
+	}
+
+	return inserted, nil
+}
+
 // Get returns a Association by ID
 func (r *repository) Get(ctx context.Context, id string) (core.Association, error) {
 	ctx, span := tracer.Start(ctx, "Association.Repository.Get")
@@ -186,13 +268,20 @@ func (r *repository) Delete(ctx context.Context, id string) error {
 	}
 
 	var deleted core.Association
-	err := r.db.WithContext(ctx).Where("id = $1", id).Delete(&core.Association{}).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Returning{}).Where("id = $1", id).Delete(&deleted)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return addCount(tx, deleted.Target, deleted.SchemaID, deleted.Variant, -1)
+	})
 	if err != nil {
 		span.RecordError(err)
 		return err
 	}
 
 	r.mc.Decrement("association_count", 1)
+	r.mc.Delete(associationCountsKey(deleted.Target))
 
 	deleted.ID = "a" + deleted.ID
 
@@ -232,7 +321,7 @@ func (r *repository) GetCountsBySchema(ctx context.Context, messageID string) (m
 		Count    int64
 	}
 
-	err := r.db.WithContext(ctx).Model(&core.Association{}).Select("schema_id, count(*) as count").Where("target = ?", messageID).Group("schema_id").Scan(&counts).Error
+	err := r.db.WithContext(ctx).Model(&core.AssociationCount{}).Select("schema_id, sum(count) as count").Where("target = ?", messageID).Group("schema_id").Scan(&counts).Error
 	if err != nil {
 		return nil, err
 	}
@@ -249,6 +338,86 @@ func (r *repository) GetCountsBySchema(ctx context.Context, messageID string) (m
 	return result, nil
 }
 
+// GetCountsBySchemaForTargets returns the number of associations by schema for each of the targets.
+// the summaries are cached in memcached and dropped when an association to the target is created or deleted
+func (r *repository) GetCountsBySchemaForTargets(ctx context.Context, targetIDs []string) (map[string]map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "Association.Repository.GetCountsBySchemaForTargets")
+	defer span.End()
+
+	result := make(map[string]map[string]int64, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(targetIDs))
+	for i, targetID := range targetIDs {
+		keys[i] = associationCountsKey(targetID)
+	}
+
+	cached, err := r.mc.GetMulti(keys)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	missing := make([]string, 0)
+	for _, targetID := range targetIDs {
+		item, ok := cached[associationCountsKey(targetID)]
+		if ok {
+			var counts map[string]int64
+			if err := json.Unmarshal(item.Value, &counts); err == nil {
+				result[targetID] = counts
+				continue
+			}
+		}
+		missing = append(missing, targetID)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	var counts []struct {
+		Target   string
+		SchemaID uint
+		Count    int64
+	}
+
+	err = r.db.WithContext(ctx).
+		Model(&core.AssociationCount{}).
+		Select("target, schema_id, sum(count) as count").
+		Where("target IN ?", missing).
+		Group("target, schema_id").
+		Scan(&counts).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	for _, targetID := range missing {
+		result[targetID] = make(map[string]int64)
+	}
+	for _, count := range counts {
+		schemaUrl, err := r.schema.IDToUrl(ctx, count.SchemaID)
+		if err != nil {
+			continue
+		}
+		result[count.Target][schemaUrl] = count.Count
+	}
+
+	for _, targetID := range missing {
+		value, err := json.Marshal(result[targetID])
+		if err != nil {
+			continue
+		}
+		r.mc.Set(&memcache.Item{Key: associationCountsKey(targetID), Value: value, Expiration: associationCountsTTL})
+	}
+
+	return result, nil
+}
+
+func associationCountsKey(targetID string) string {
+	return "association_counts:" + targetID
+}
+
 // GetOwnByTarget returns all associations which target is specified message and owned by specified owner
 func (r *repository) GetOwnByTarget(ctx context.Context, targetID, author string) ([]core.Association, error) {
 	ctx, span := tracer.Start(ctx, "Association.Repository.GetOwnByTarget")
@@ -315,7 +484,7 @@ func (r *repository) GetCountsBySchemaAndVariant(ctx context.Context, messageID,
 		return nil, err
 	}
 
-	err = r.db.WithContext(ctx).Model(&core.Association{}).Select("variant, count(*) as count").Where("target = ? AND schema_id = ?", messageID, schemaID).Group("variant").Scan(&counts).Error
+	err = r.db.WithContext(ctx).Model(&core.AssociationCount{}).Select("variant, count").Where("target = ? AND schema_id = ?", messageID, schemaID).Scan(&counts).Error
 	if err != nil {
 		return nil, err
 	}
@@ -361,7 +530,27 @@ func (r *repository) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "Association.Repository.Clean")
 	defer span.End()
 
-	err := r.db.WithContext(ctx).Where("owner = ?", ccid).Delete(&core.Association{}).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deleted []core.Association
+		err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "target"}, {Name: "schema_id"}, {Name: "variant"}}}).
+			Where("owner = ?", ccid).
+			Delete(&deleted).Error
+		if err != nil {
+			return err
+		}
+
+		removed := make(map[core.AssociationCount]int64)
+		for _, association := range deleted {
+			removed[core.AssociationCount{Target: association.Target, SchemaID: association.SchemaID, Variant: association.Variant}]++
+		}
+		for key, count := range removed {
+			err := addCount(tx, key.Target, key.SchemaID, key.Variant, -count)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		span.RecordError(err)
 		return err
@@ -369,3 +558,106 @@ func (r *repository) Clean(ctx context.Context, ccid string) error {
 
 	return nil
 }
+
+// addCount adds delta to the association count of the target, schema and variant in tx.
+// counts which drop to zero are removed, so that the table holds only the variants in use
+func addCount(tx *gorm.DB, target string, schemaID uint, variant string, delta int64) error {
+	if delta > 0 {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "target"}, {Name: "schema_id"}, {Name: "variant"}},
+			DoUpdates: clause.Assignments(map[string]any{"count": gorm.Expr("? + ?", clause.Column{Name: "count"}, delta)}),
+		}).Create(&core.AssociationCount{Target: target, SchemaID: schemaID, Variant: variant, Count: delta}).Error
+	}
+
+	key := tx.Where("target = ? AND schema_id = ? AND variant = ?", target, schemaID, variant)
+	err := key.Session(&gorm.Session{}).Model(&core.AssociationCount{}).Update("count", gorm.Expr("? + ?", clause.Column{Name: "count"}, delta)).Error
+	if err != nil {
+		return err
+	}
+	return key.Session(&gorm.Session{}).Where("count <= 0").Delete(&core.AssociationCount{}).Error
+}
+
+// ReconcileCounts recomputes the association counts from the associations and fixes the ones which drifted,
+// e.g. by associations removed outside of the repository. returns the number of counts fixed.
+// it runs in a repeatable read transaction, so that a count changed concurrently fails the run instead of being overwritten
+func (r *repository) ReconcileCounts(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Association.Repository.ReconcileCounts")
+	defer span.End()
+
+	stmt := &gorm.Statement{DB: r.db}
+	err := stmt.Parse(&core.AssociationCount{})
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	var fixed int64
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		actual := tx.Model(&core.Association{}).Select("target, schema_id, variant, count(*)").Group("target, schema_id, variant")
+		result := tx.Exec(
+			"INSERT INTO ? AS c (target, schema_id, variant, count) ? "+
+				"ON CONFLICT (target, schema_id, variant) DO UPDATE SET count = excluded.count WHERE c.count <> excluded.count",
+			clause.Table{Name: stmt.Schema.Table}, actual,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		fixed += result.RowsAffected
+
+		result = tx.
+			Where("(target, schema_id, variant) NOT IN (?)", tx.Model(&core.Association{}).Select("target, schema_id, variant")).
+			Delete(&core.AssociationCount{})
+		if result.Error != nil {
+			return result.Error
+		}
+		fixed += result.RowsAffected
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	return fixed, nil
+}
+
+// GetReposters returns authors who reposted specified message, most recent first
+func (r *repository) GetReposters(ctx context.Context, messageID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Association.Repository.GetReposters")
+	defer span.End()
+
+	var reposters []string
+	err := r.db.WithContext(ctx).
+		Model(&core.Association{}).
+		Select("author").
+		Where("target = ? AND variant = ?", messageID, core.AssociationVariantRepost).
+		Group("author").
+		Order("max(c_date) DESC").
+		Pluck("author", &reposters).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return reposters, nil
+}
+
+// CountReposts returns the number of reposts of specified message.
+// it is read from the association counts, which the reconcile job keeps in line with the associations
+func (r *repository) CountReposts(ctx context.Context, messageID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Association.Repository.CountReposts")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&core.AssociationCount{}).
+		Select("coalesce(sum(count), 0)").
+		Where("target = ? AND variant = ?", messageID, core.AssociationVariantRepost).
+		Scan(&count).Error
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	return count, nil
+}
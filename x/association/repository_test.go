@@ -114,6 +114,18 @@ func TestRepository(t *testing.T) {
 		assert.Equal(t, 2, len(results))
 	}
 
+	// test GetCountsBySchemaForTargets, the second call is served from the cache
+	for i := 0; i < 2; i++ {
+		summaries, err := repo.GetCountsBySchemaForTargets(ctx, []string{messageID, "m00000000000000000000000000"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]int64{
+				"https://schema.concrnt.world/a/like.json":     1,
+				"https://schema.concrnt.world/a/reaction.json": 3,
+			}, summaries[messageID])
+			assert.Empty(t, summaries["m00000000000000000000000000"])
+		}
+	}
+
 	// test GetBySchema
 	associations, err := repo.GetBySchema(ctx, messageID, "https://schema.concrnt.world/a/like.json")
 	if assert.NoError(t, err) {
@@ -137,3 +149,152 @@ func TestRepository(t *testing.T) {
 	}
 
 }
+
+func TestCreateBatch(t *testing.T) {
+	message := core.Message{
+		ID:        "QXS1PZ7GH3WM4T2K0676PETFAR",
+		Author:    "con18fyqn098jsf6cnw2r8hkjt7zeftfa0vqvjr6fe",
+		Schema:    "https://schema.concrnt.world/m/markdown.json",
+		Document:  "{}",
+		Signature: "DUMMY",
+	}
+	err := db.WithContext(ctx).Create(&message).Error
+	assert.NoError(t, err)
+
+	reaction := func(id, variant, unique string) core.Association {
+		return core.Association{
+			ID:        id,
+			Author:    "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4",
+			Schema:    "https://schema.concrnt.world/a/reaction.json",
+			Target:    "m" + message.ID,
+			Document:  "{}",
+			Variant:   variant,
+			Unique:    unique,
+			Signature: "DUMMY",
+		}
+	}
+
+	inserted, err := repo.CreateBatch(ctx, []core.Association{
+		reaction("a8FAN3CEJ5MZ1XQ70676PETFAR", "smile", "batch-0"),
+		reaction("aK2B7VZS1W0D3TMC0676PETFAR", "heart", "batch-1"),
+		// the same content as the first one
+		reaction("aP4X9D2MAEK7QNSZ0676PETFAR", "smile", "batch-0"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false}, inserted)
+
+	counts, err := repo.GetCountsBySchemaAndVariant(ctx, "m"+message.ID, "https://schema.concrnt.world/a/reaction.json")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"smile": 1, "heart": 1}, counts)
+
+	// a retried batch inserts nothing
+	inserted, err = repo.CreateBatch(ctx, []core.Association{
+		reaction("a8FAN3CEJ5MZ1XQ70676PETFAR", "smile", "batch-0"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false}, inserted)
+}
+
+func TestAssociationCounts(t *testing.T) {
+	message := core.Message{
+		ID:        "R7TW2K4N9XQ3BMC50676PETFAR",
+		Author:    "con18fyqn098jsf6cnw2r8hkjt7zeftfa0vqvjr6fe",
+		Schema:    "https://schema.concrnt.world/m/markdown.json",
+		Document:  "{}",
+		Signature: "DUMMY",
+	}
+	err := db.WithContext(ctx).Create(&message).Error
+	assert.NoError(t, err)
+
+	messageID := "m" + message.ID
+	schema := "https://schema.concrnt.world/a/reaction.json"
+
+	reaction, err := repo.Create(ctx, core.Association{
+		ID:        "C3NV8J1QW5ZK7TDM0676PETFAR",
+		Author:    "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4",
+		Owner:     "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4",
+		Schema:    schema,
+		Target:    messageID,
+		Document:  "{}",
+		Variant:   "smile",
+		Unique:    "counts-0",
+		Signature: "DUMMY",
+	})
+	assert.NoError(t, err)
+
+	counts, err := repo.GetCountsBySchemaAndVariant(ctx, messageID, schema)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"smile": 1}, counts)
+
+	// the count is removed along with the last association of the variant
+	err = repo.Delete(ctx, reaction.ID)
+	assert.NoError(t, err)
+
+	counts, err = repo.GetCountsBySchemaAndVariant(ctx, messageID, schema)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	// a count drifted by a change outside of the repository is fixed by the reconciliation
+	err = db.WithContext(ctx).Create(&core.AssociationCount{Target: messageID, SchemaID: 1, Variant: "ghost", Count: 3}).Error
+	assert.NoError(t, err)
+
+	fixed, err := repo.ReconcileCounts(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), fixed)
+
+	var remaining int64
+	err = db.WithContext(ctx).Model(&core.AssociationCount{}).Where("target = ?", messageID).Count(&remaining).Error
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), remaining)
+}
+
+func TestRepostCounts(t *testing.T) {
+	message := core.Message{
+		ID:        "K2PX7H3NCQ4W9EJD0676PETFAR",
+		Author:    "con18fyqn098jsf6cnw2r8hkjt7zeftfa0vqvjr6fe",
+		Schema:    "https://schema.concrnt.world/m/markdown.json",
+		Document:  "{}",
+		Signature: "DUMMY",
+	}
+	err := db.WithContext(ctx).Create(&message).Error
+	assert.NoError(t, err)
+
+	messageID := "m" + message.ID
+	repost := func(id, author string) core.Association {
+		created, err := repo.Create(ctx, core.Association{
+			ID:        id,
+			Author:    author,
+			Owner:     author,
+			Schema:    "https://schema.concrnt.world/a/repost.json",
+			Target:    messageID,
+			Document:  "{}",
+			Variant:   core.AssociationVariantRepost,
+			Unique:    "repost-" + id,
+			Signature: "DUMMY",
+		})
+		assert.NoError(t, err)
+		return created
+	}
+
+	// reposts are counted in the association counts
+	first := repost("V8ZQ1M5TKC2XR7HN0676PETFAR", "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4")
+	count, err := repo.CountReposts(ctx, messageID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// which follow the reposts created and deleted
+	repost("B4JW6T9PDN3QY8FK0676PETFAR", "con18fyqn098jsf6cnw2r8hkjt7zeftfa0vqvjr6fe")
+	count, err = repo.CountReposts(ctx, messageID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	reposters, err := repo.GetReposters(ctx, messageID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4", "con18fyqn098jsf6cnw2r8hkjt7zeftfa0vqvjr6fe"}, reposters)
+
+	err = repo.Delete(ctx, first.ID)
+	assert.NoError(t, err)
+	count, err = repo.CountReposts(ctx, messageID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/totegamma/concurrent/cdid"
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 	"github.com/totegamma/concurrent/x/policy"
 )
 
@@ -82,269 +84,80 @@ func (s *service) Clean(ctx context.Context, ccid string) error {
 	return nil
 }
 
-// PostAssociation creates a new association
-// If targetType is messages, it also posts the association to the target message's timelines
-// returns the created association
-func (s *service) Create(ctx context.Context, mode core.CommitMode, document string, signature string) (core.Association, []string, error) {
-	ctx, span := tracer.Start(ctx, "Association.Service.Create")
+// ReconcileCounts fixes the association counts which drifted from the associations
+func (s *service) ReconcileCounts(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Association.Service.ReconcileCounts")
 	defer span.End()
 
-	var doc core.AssociationDocument[any]
-	err := json.Unmarshal([]byte(document), &doc)
+	fixed, err := s.repo.ReconcileCounts(ctx)
 	if err != nil {
 		span.RecordError(err)
-		return core.Association{}, []string{}, err
+		return err
 	}
-
-	hash := core.GetHash([]byte(document))
-	hash10 := [10]byte{}
-	copy(hash10[:], hash[:10])
-	signedAt := doc.SignedAt
-	id := "a" + cdid.New(hash10, signedAt).String()
-
-	signer, err := s.entity.Get(ctx, doc.Signer)
-	if err != nil {
-		span.RecordError(err)
-		return core.Association{}, []string{}, err
+	if fixed > 0 {
+		logging.Warn(ctx, "association counts reconciled", slog.String("module", "association"), slog.Int64("fixed", fixed))
 	}
 
-	isLocalEntry := false
+	return nil
+}
 
-	if core.IsCCID(doc.Owner) {
-		ownerEntity, err := s.entity.Get(ctx, doc.Owner)
-		if err != nil {
-			span.RecordError(err)
-			return core.Association{}, []string{}, err
-		}
-		if ownerEntity.Domain == s.config.FQDN {
-			isLocalEntry = true
-		}
-	} else if core.IsCSID(doc.Owner) {
-		if doc.Owner == s.config.CSID {
-			isLocalEntry = true
-		}
-	} else {
-		return core.Association{}, []string{}, errors.New("invalid owner")
-	}
+// PostAssociation creates a new association
+// If targetType is messages, it also posts the association to the target message's timelines
+// returns the created association
+func (s *service) Create(ctx context.Context, mode core.CommitMode, document string, signature string) (core.Association, []string, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.Create")
+	defer span.End()
 
-	bodyStr, err := json.Marshal(doc.Body)
+	doc, association, signer, isLocalEntry, err := s.prepare(ctx, document, signature)
 	if err != nil {
 		span.RecordError(err)
 		return core.Association{}, []string{}, err
 	}
-
-	uniqueKey := doc.Signer + doc.Schema + doc.Target + doc.Variant + string(bodyStr)
-	uniqueHash := core.GetHash([]byte(uniqueKey))
-	unique := hex.EncodeToString(uniqueHash[:16])
-
-	association := core.Association{
-		ID:        id,
-		Author:    doc.Signer,
-		Owner:     doc.Owner,
-		Schema:    doc.Schema,
-		Target:    doc.Target,
-		Document:  document,
-		Signature: signature,
-		Timelines: doc.Timelines,
-		Variant:   doc.Variant,
-		Unique:    unique,
-	}
+	timelines := association.Timelines
 
 	if isLocalEntry { // signerが自ドメイン管轄の場合、リソースを作成
 
-		switch doc.Target[0] {
-		case 'm': // message
-			target, err := s.message.GetAsUser(ctx, association.Target, signer)
-			if err != nil {
-				span.RecordError(err)
-				return core.Association{}, []string{}, err
-			}
-
-			timelinePolicyResults := make([]core.PolicyEvalResult, len(target.Timelines))
-			for i, timelineID := range target.Timelines {
-				timeline, err := s.timeline.GetTimelineAutoDomain(ctx, timelineID)
-				if err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					continue
-				}
-
-				var params map[string]any = make(map[string]any)
-				if timeline.PolicyParams != nil {
-					json.Unmarshal([]byte(*timeline.PolicyParams), &params)
-				}
-
-				result, err := s.policy.TestWithPolicyURL(
-					ctx,
-					timeline.Policy,
-					core.RequestContext{
-						Self:     timeline,
-						Params:   params,
-						Document: doc,
-					},
-					"timeline.message.association.attach",
-				)
-				if err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					timelinePolicyResults[i] = core.PolicyEvalResultDefault
-					continue
-				}
-
-				timelinePolicyResults[i] = result
-			}
-
-			timelinePolicyResult := policy.AccumulateOr(timelinePolicyResults)
-			timelinePolicyIsDominant, timlinePolicyAllowed := policy.IsDominant(timelinePolicyResult)
-			if timelinePolicyIsDominant && !timlinePolicyAllowed {
-				return association, []string{}, core.ErrorPermissionDenied{}
-			}
-
-			var params map[string]any = make(map[string]any)
-			if target.PolicyParams != nil {
-				json.Unmarshal([]byte(*target.PolicyParams), &params)
-			}
-
-			messagePolicyResult, err := s.policy.TestWithPolicyURL(
-				ctx,
-				target.Policy,
-				core.RequestContext{
-					Requester: signer,
-					Self:      target,
-					Params:    params,
-					Document:  doc,
-				},
-				"message.association.attach",
-			)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
-
-			}
-
-			result := s.policy.Summerize([]core.PolicyEvalResult{timelinePolicyResult, messagePolicyResult}, "message.association.attach", nil)
-			if !result {
-				return association, []string{}, core.ErrorPermissionDenied{}
-			}
+		err = s.checkAttach(ctx, doc, association, signer)
+		if err != nil {
+			return association, []string{}, err
+		}
 
-		case 'p': // profile
-			target, err := s.profile.Get(ctx, association.Target)
+		// a post over the writeRateLimit of a timeline is refused before the association is stored
+		if mode != core.CommitModeLocalOnlyExec {
+			err = s.timeline.CheckThrottle(ctx, timelines, association.Author, document)
 			if err != nil {
 				span.RecordError(err)
 				return association, []string{}, err
 			}
+		}
 
-			var params map[string]any = make(map[string]any)
-			if target.PolicyParams != nil {
-				err := json.Unmarshal([]byte(*target.PolicyParams), &params)
-				if err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					span.RecordError(err)
-				}
-			}
-
-			policyEvalResult, err := s.policy.TestWithPolicyURL(
-				ctx,
-				target.Policy,
-				core.RequestContext{
-					Requester: signer,
-					Self:      target,
-					Params:    params,
-					Document:  doc,
-				},
-				"profile.association.attach",
-			)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
-			}
-
-			result := s.policy.Summerize([]core.PolicyEvalResult{policyEvalResult}, "profile.association.attach", nil)
-			if !result {
-				return association, []string{}, core.ErrorPermissionDenied{}
-			}
-
-		case 't': // timeline
-			target, err := s.timeline.GetTimeline(ctx, association.Target)
-			if err != nil {
+		created, err := s.repo.Create(ctx, association)
+		if err != nil {
+			if !errors.Is(err, core.ErrorAlreadyExists{}) {
 				span.RecordError(err)
 				return association, []string{}, err
 			}
-
-			var params map[string]any = make(map[string]any)
-			if target.PolicyParams != nil {
-				err := json.Unmarshal([]byte(*target.PolicyParams), &params)
-				if err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					span.RecordError(err)
-				}
-			}
-
-			policyEvalResult, err := s.policy.TestWithPolicyURL(
-				ctx,
-				target.Policy,
-				core.RequestContext{
-					Requester: signer,
-					Self:      target,
-					Params:    params,
-					Document:  doc,
-				},
-				"timeline.association.attach",
-			)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
-			}
-
-			result := s.policy.Summerize([]core.PolicyEvalResult{policyEvalResult}, "timeline.association.attach", nil)
-			if !result {
-				return association, []string{}, core.ErrorPermissionDenied{}
+			// the same document submitted again continues with the stored association.
+			// a different association with the same content is still a conflict.
+			existing, gerr := s.repo.Get(ctx, association.ID)
+			if gerr != nil || existing.Signature != signature {
+				return association, []string{}, core.NewErrorAlreadyExists()
 			}
+			created = existing
+		}
+		association = created
 
-		case 's': // subscription
-			target, err := s.subscription.GetSubscription(ctx, association.Target)
+		if s.config.ThreadTimelines && isReply(association) {
+			err = s.deliverToThread(ctx, association)
 			if err != nil {
+				logging.Warn(ctx, "failed to deliver reply to thread timeline", slog.String("error", err.Error()), slog.String("module", "association"))
 				span.RecordError(err)
-				return association, []string{}, err
 			}
-
-			var params map[string]any = make(map[string]any)
-			if target.PolicyParams != nil {
-				err := json.Unmarshal([]byte(*target.PolicyParams), &params)
-				if err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					span.RecordError(err)
-				}
-			}
-
-			policyEvalResult, err := s.policy.TestWithPolicyURL(
-				ctx,
-				target.Policy,
-				core.RequestContext{
-					Requester: signer,
-					Self:      target,
-					Params:    params,
-					Document:  doc,
-				},
-				"subscription.association.attach",
-			)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
-			}
-
-			result := s.policy.Summerize([]core.PolicyEvalResult{policyEvalResult}, "subscription.association.attach", nil)
-			if !result {
-				return association, []string{}, core.ErrorPermissionDenied{}
-			}
-		}
-
-		association, err = s.repo.Create(ctx, association)
-		if err != nil {
-			if errors.Is(err, core.ErrorAlreadyExists{}) {
-				return association, []string{}, core.NewErrorAlreadyExists()
-			}
-			span.RecordError(err)
-			return association, []string{}, err
 		}
 	}
 
 	destinations := make(map[string][]string)
-	for _, timeline := range doc.Timelines {
+	for _, timeline := range timelines {
 		normalized, err := s.timeline.NormalizeTimelineID(ctx, timeline)
 		if err != nil {
 			span.RecordError(err)
@@ -389,7 +202,7 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 
 				err = s.timeline.PublishEvent(ctx, event)
 				if err != nil {
-					slog.ErrorContext(ctx, "failed to publish event", slog.String("error", err.Error()), slog.String("module", "timeline"))
+					logging.Error(ctx, "failed to publish event", slog.String("error", err.Error()), slog.String("module", "timeline"))
 					span.RecordError(err)
 					continue
 				}
@@ -448,7 +261,7 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 					}
 					err := s.timeline.PublishEvent(ctx, event)
 					if err != nil {
-						slog.ErrorContext(ctx, "failed to publish message to Redis", slog.String("error", err.Error()), slog.String("module", "association"))
+						logging.Error(ctx, "failed to publish message to Redis", slog.String("error", err.Error()), slog.String("module", "association"))
 						span.RecordError(err)
 						return association, []string{}, err
 					}
@@ -504,6 +317,314 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 	return association, affected, nil
 }
 
+// CreateBatch stores associations of local owners at once, e.g. reactions imported from another platform.
+// each document is checked like Create, then the accepted ones are inserted in a single transaction.
+// unlike Create they are neither posted to timelines nor relayed to other domains, since imported reactions are history.
+// the result of each document is reported at its index
+func (s *service) CreateBatch(ctx context.Context, commits []core.Commit) ([]core.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.CreateBatch")
+	defer span.End()
+
+	results := make([]core.BatchResult, len(commits))
+	accepted := make([]core.Association, 0, len(commits))
+	indexes := make([]int, 0, len(commits))
+	for i, commit := range commits {
+		doc, association, signer, isLocalEntry, err := s.prepare(ctx, commit.Document, commit.Signature)
+		results[i].ID = association.ID
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if !isLocalEntry {
+			results[i].Error = "the owner is not on this domain"
+			continue
+		}
+
+		err = s.checkAttach(ctx, doc, association, signer)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		accepted = append(accepted, association)
+		indexes = append(indexes, i)
+	}
+
+	if len(accepted) == 0 {
+		return results, nil
+	}
+
+	inserted, err := s.repo.CreateBatch(ctx, accepted)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for j, ok := range inserted {
+		if !ok {
+			results[indexes[j]].Error = core.NewErrorAlreadyExists().Error()
+		}
+	}
+
+	return results, nil
+}
+
+// prepare builds the association of a document. isLocalEntry tells that its owner is on this domain,
+// in which case the association is stored here
+func (s *service) prepare(ctx context.Context, document, signature string) (core.AssociationDocument[any], core.Association, core.Entity, bool, error) {
+	var doc core.AssociationDocument[any]
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return doc, core.Association{}, core.Entity{}, false, err
+	}
+
+	hash := core.GetHash([]byte(document))
+	hash10 := [10]byte{}
+	copy(hash10[:], hash[:10])
+	signedAt := doc.SignedAt
+	id := "a" + cdid.New(hash10, signedAt).String()
+
+	signer, err := s.entity.Get(ctx, doc.Signer)
+	if err != nil {
+		return doc, core.Association{}, core.Entity{}, false, err
+	}
+
+	isLocalEntry := false
+
+	if core.IsCCID(doc.Owner) {
+		ownerEntity, err := s.entity.Get(ctx, doc.Owner)
+		if err != nil {
+			return doc, core.Association{}, core.Entity{}, false, err
+		}
+		if ownerEntity.Domain == s.config.FQDN {
+			isLocalEntry = true
+		}
+	} else if core.IsCSID(doc.Owner) {
+		if doc.Owner == s.config.CSID {
+			isLocalEntry = true
+		}
+	} else {
+		return doc, core.Association{}, core.Entity{}, false, core.NewErrorInvalidArgument("invalid owner")
+	}
+
+	bodyStr, err := json.Marshal(doc.Body)
+	if err != nil {
+		return doc, core.Association{}, core.Entity{}, false, err
+	}
+
+	uniqueKey := doc.Signer + doc.Schema + doc.Target + doc.Variant + string(bodyStr)
+	uniqueHash := core.GetHash([]byte(uniqueKey))
+	unique := hex.EncodeToString(uniqueHash[:16])
+
+	timelines := doc.Timelines
+	if doc.Variant == core.AssociationVariantRepost && signer.Domain == s.config.FQDN {
+		timelines = withRepostTimeline(timelines, doc.Signer)
+	}
+
+	association := core.Association{
+		ID:        id,
+		Author:    doc.Signer,
+		Owner:     doc.Owner,
+		Schema:    doc.Schema,
+		Target:    doc.Target,
+		Document:  document,
+		Signature: signature,
+		Timelines: timelines,
+		Variant:   doc.Variant,
+		Unique:    unique,
+	}
+
+	return doc, association, signer, isLocalEntry, nil
+}
+
+// checkAttach tests the policies of the target, and of the timelines of a target message, for the association
+func (s *service) checkAttach(ctx context.Context, doc core.AssociationDocument[any], association core.Association, signer core.Entity) error {
+	ctx, span := tracer.Start(ctx, "Association.Service.CheckAttach")
+	defer span.End()
+
+	switch doc.Target[0] {
+	case 'm': // message
+		target, err := s.message.GetAsUser(ctx, association.Target, signer)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		timelinePolicyResults := make([]core.PolicyEvalResult, len(target.Timelines))
+		for i, timelineID := range target.Timelines {
+			timeline, err := s.timeline.GetTimelineAutoDomain(ctx, timelineID)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				continue
+			}
+
+			var params map[string]any = make(map[string]any)
+			if timeline.PolicyParams != nil {
+				json.Unmarshal([]byte(*timeline.PolicyParams), &params)
+			}
+
+			result, err := s.policy.TestWithPolicyURL(
+				ctx,
+				timeline.Policy,
+				core.RequestContext{
+					Self:     timeline,
+					Params:   params,
+					Document: doc,
+				},
+				"timeline.message.association.attach",
+			)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				timelinePolicyResults[i] = core.PolicyEvalResultDefault
+				continue
+			}
+
+			timelinePolicyResults[i] = result
+		}
+
+		timelinePolicyResult := policy.AccumulateOr(timelinePolicyResults)
+		timelinePolicyIsDominant, timlinePolicyAllowed := policy.IsDominant(timelinePolicyResult)
+		if timelinePolicyIsDominant && !timlinePolicyAllowed {
+			return core.ErrorPermissionDenied{}
+		}
+
+		var params map[string]any = make(map[string]any)
+		if target.PolicyParams != nil {
+			json.Unmarshal([]byte(*target.PolicyParams), &params)
+		}
+
+		messagePolicyResult, err := s.policy.TestWithPolicyURL(
+			ctx,
+			target.Policy,
+			core.RequestContext{
+				Requester: signer,
+				Self:      target,
+				Params:    params,
+				Document:  doc,
+			},
+			"message.association.attach",
+		)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+
+		}
+
+		result := s.policy.Summerize([]core.PolicyEvalResult{timelinePolicyResult, messagePolicyResult}, "message.association.attach", nil)
+		if !result {
+			return core.ErrorPermissionDenied{}
+		}
+
+	case 'p': // profile
+		target, err := s.profile.Get(ctx, association.Target)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		var params map[string]any = make(map[string]any)
+		if target.PolicyParams != nil {
+			err := json.Unmarshal([]byte(*target.PolicyParams), &params)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				span.RecordError(err)
+			}
+		}
+
+		policyEvalResult, err := s.policy.TestWithPolicyURL(
+			ctx,
+			target.Policy,
+			core.RequestContext{
+				Requester: signer,
+				Self:      target,
+				Params:    params,
+				Document:  doc,
+			},
+			"profile.association.attach",
+		)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		result := s.policy.Summerize([]core.PolicyEvalResult{policyEvalResult}, "profile.association.attach", nil)
+		if !result {
+			return core.ErrorPermissionDenied{}
+		}
+
+	case 't': // timeline
+		target, err := s.timeline.GetTimeline(ctx, association.Target)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		var params map[string]any = make(map[string]any)
+		if target.PolicyParams != nil {
+			err := json.Unmarshal([]byte(*target.PolicyParams), &params)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				span.RecordError(err)
+			}
+		}
+
+		policyEvalResult, err := s.policy.TestWithPolicyURL(
+			ctx,
+			target.Policy,
+			core.RequestContext{
+				Requester: signer,
+				Self:      target,
+				Params:    params,
+				Document:  doc,
+			},
+			"timeline.association.attach",
+		)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		result := s.policy.Summerize([]core.PolicyEvalResult{policyEvalResult}, "timeline.association.attach", nil)
+		if !result {
+			return core.ErrorPermissionDenied{}
+		}
+
+	case 's': // subscription
+		target, err := s.subscription.GetSubscription(ctx, association.Target)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		var params map[string]any = make(map[string]any)
+		if target.PolicyParams != nil {
+			err := json.Unmarshal([]byte(*target.PolicyParams), &params)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				span.RecordError(err)
+			}
+		}
+
+		policyEvalResult, err := s.policy.TestWithPolicyURL(
+			ctx,
+			target.Policy,
+			core.RequestContext{
+				Requester: signer,
+				Self:      target,
+				Params:    params,
+				Document:  doc,
+			},
+			"subscription.association.attach",
+		)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		result := s.policy.Summerize([]core.PolicyEvalResult{policyEvalResult}, "subscription.association.attach", nil)
+		if !result {
+			return core.ErrorPermissionDenied{}
+		}
+	}
+
+	return nil
+}
+
 // Get returns an association by ID
 func (s *service) Get(ctx context.Context, id string) (core.Association, error) {
 	ctx, span := tracer.Start(ctx, "Association.Service.Get")
@@ -589,7 +710,7 @@ func (s *service) Delete(ctx context.Context, mode core.CommitMode, document, si
 		}
 		err := s.timeline.PublishEvent(ctx, event)
 		if err != nil {
-			slog.ErrorContext(ctx, "failed to publish message to Redis", slog.String("error", err.Error()), slog.String("module", "association"))
+			logging.Error(ctx, "failed to publish message to Redis", slog.String("error", err.Error()), slog.String("module", "association"))
 			span.RecordError(err)
 			return targetAssociation, []string{}, err
 		}
@@ -626,7 +747,7 @@ func (s *service) Delete(ctx context.Context, mode core.CommitMode, document, si
 				}
 				err := s.timeline.PublishEvent(ctx, event)
 				if err != nil {
-					slog.ErrorContext(ctx, "failed to publish message to Redis", slog.String("error", err.Error()), slog.String("module", "association"))
+					logging.Error(ctx, "failed to publish message to Redis", slog.String("error", err.Error()), slog.String("module", "association"))
 					span.RecordError(err)
 					return targetAssociation, []string{}, err
 				}
@@ -700,6 +821,14 @@ func (s *service) GetBySchema(ctx context.Context, messageID string, schema stri
 	return s.repo.GetBySchema(ctx, messageID, schema)
 }
 
+// GetCountsBySchemaForTargets returns the number of associations by schema for each of the targets
+func (s *service) GetCountsBySchemaForTargets(ctx context.Context, targetIDs []string) (map[string]map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.GetCountsBySchemaForTargets")
+	defer span.End()
+
+	return s.repo.GetCountsBySchemaForTargets(ctx, targetIDs)
+}
+
 // GetCountsBySchemaAndVariant returns the number of associations by schema and variant
 func (s *service) GetCountsBySchemaAndVariant(ctx context.Context, messageID string, schema string) (map[string]int64, error) {
 	ctx, span := tracer.Start(ctx, "Association.Service.GetCountsBySchemaAndVariant")
@@ -723,3 +852,30 @@ func (s *service) GetOwnByTarget(ctx context.Context, targetID, author string) (
 
 	return s.repo.GetOwnByTarget(ctx, targetID, author)
 }
+
+// withRepostTimeline appends the reposter's home timeline to timelines unless it is already included
+func withRepostTimeline(timelines []string, reposter string) []string {
+	home := core.RepostTimelineSemanticID + "@" + reposter
+	for _, timeline := range timelines {
+		if timeline == home {
+			return timelines
+		}
+	}
+	return append(slices.Clone(timelines), home)
+}
+
+// GetReposters returns authors who reposted specified message
+func (s *service) GetReposters(ctx context.Context, messageID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.GetReposters")
+	defer span.End()
+
+	return s.repo.GetReposters(ctx, messageID)
+}
+
+// CountReposts returns the number of reposts of specified message
+func (s *service) CountReposts(ctx context.Context, messageID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.CountReposts")
+	defer span.End()
+
+	return s.repo.CountReposts(ctx, messageID)
+}
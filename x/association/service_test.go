@@ -0,0 +1,128 @@
+package association
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+const (
+	localFQDN    = "local.example.com"
+	reposter     = "con1n42l2lektua69gvza8xhksq3t2we8nnlkmzct4"
+	remoteAuthor = "con18fyqn098jsf6cnw2r8hkjt7zeftfa0vqvjr6fe"
+	repostTarget = "mD895NMA837R0C6B90676P2S1J4"
+)
+
+// createdRepository stores nothing, it hands back what is created
+type createdRepository struct {
+	Repository
+	created []core.Association
+}
+
+func (r *createdRepository) Create(ctx context.Context, association core.Association) (core.Association, error) {
+	r.created = append(r.created, association)
+	return association, nil
+}
+
+func repostDocument(t *testing.T, signer string, timelines []string) string {
+	document, err := json.Marshal(core.AssociationDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   signer,
+			Owner:    signer,
+			Type:     "association",
+			Schema:   "https://schema.concrnt.world/a/repost.json",
+			SignedAt: time.Now(),
+		},
+		Timelines: timelines,
+		Variant:   core.AssociationVariantRepost,
+		Target:    repostTarget,
+	})
+	assert.NoError(t, err)
+	return string(document)
+}
+
+func TestCreateRepostFanOut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	home := core.RepostTimelineSemanticID + "@" + reposter
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), reposter).Return(core.Entity{ID: reposter, Domain: localFQDN}, nil).AnyTimes()
+
+	mockMessage := mock_core.NewMockMessageService(ctrl)
+	mockMessage.EXPECT().GetAsUser(gomock.Any(), repostTarget, gomock.Any()).Return(core.Message{ID: repostTarget}, nil).AnyTimes()
+
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockPolicy.EXPECT().TestWithPolicyURL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(core.PolicyEvalResultDefault, nil).AnyTimes()
+	mockPolicy.EXPECT().Summerize(gomock.Any(), gomock.Any(), gomock.Any()).Return(true).AnyTimes()
+
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().NormalizeTimelineID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (string, error) {
+		return "taaaaaaaaaaaaaaaaaaaaaaaaaa@" + localFQDN, nil
+	}).AnyTimes()
+	mockTimeline.EXPECT().PublishEvent(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTimeline.EXPECT().GetOwners(gomock.Any(), gomock.Any()).Return([]string{}, nil).AnyTimes()
+	mockTimeline.EXPECT().CheckThrottle(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	repo := &createdRepository{}
+	s := &service{
+		repo:     repo,
+		entity:   mockEntity,
+		message:  mockMessage,
+		policy:   mockPolicy,
+		timeline: mockTimeline,
+		config:   core.Config{FQDN: localFQDN},
+	}
+
+	// the repost is posted into the home timeline of the reposter besides the timelines it names
+	posted := []string{}
+	mockTimeline.EXPECT().PostItem(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, timeline string, item core.TimelineItem, _, _ string) (core.TimelineItem, error) {
+			posted = append(posted, timeline)
+			return item, nil
+		}).Times(2)
+
+	const listed = "tbbbbbbbbbbbbbbbbbbbbbbbbbb@" + localFQDN
+	created, _, err := s.Create(context.Background(), core.CommitModeExecute, repostDocument(t, reposter, []string{listed}), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{listed, home}, []string(created.Timelines))
+	assert.ElementsMatch(t, []string{listed, home}, posted)
+	assert.Len(t, repo.created, 1)
+
+	// the home timeline is not posted into twice when the document already names it
+	posted = posted[:0]
+	mockTimeline.EXPECT().PostItem(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, timeline string, item core.TimelineItem, _, _ string) (core.TimelineItem, error) {
+			posted = append(posted, timeline)
+			return item, nil
+		}).Times(1)
+
+	created, _, err = s.Create(context.Background(), core.CommitModeExecute, repostDocument(t, reposter, []string{home}), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{home}, []string(created.Timelines))
+	assert.Equal(t, []string{home}, posted)
+}
+
+func TestPrepareRemoteRepost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), remoteAuthor).Return(core.Entity{ID: remoteAuthor, Domain: "remote.example.com"}, nil).AnyTimes()
+
+	s := &service{entity: mockEntity, config: core.Config{FQDN: localFQDN}}
+
+	// the home timeline of a remote reposter is filled by its own domain
+	_, association, _, isLocalEntry, err := s.prepare(context.Background(), repostDocument(t, remoteAuthor, nil), "")
+	assert.NoError(t, err)
+	assert.False(t, isLocalEntry)
+	assert.Empty(t, association.Timelines)
+}
@@ -0,0 +1,324 @@
+package association
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+	defaultThreadLimit = 50
+	maxThreadLimit     = 200
+
+	// parents followed upwards at most. also stops reply cycles
+	maxThreadAncestors = 20
+)
+
+// replyAssociationBody is the body of an association linking a message to one of its replies
+type replyAssociationBody struct {
+	MessageID     string `json:"messageId"`
+	MessageAuthor string `json:"messageAuthor"`
+}
+
+// replyMessageBody is the part of a reply message body pointing at its parent
+type replyMessageBody struct {
+	ReplyToMessageID     string `json:"replyToMessageId"`
+	ReplyToMessageAuthor string `json:"replyToMessageAuthor"`
+}
+
+func isReply(association core.Association) bool {
+	return association.Variant == core.AssociationVariantReply || association.Schema == core.ReplyAssociationSchema
+}
+
+// parentOf returns the message the given message replies to, if any
+func parentOf(message core.Message) (replyMessageBody, bool) {
+	var doc core.MessageDocument[replyMessageBody]
+	if err := json.Unmarshal([]byte(message.Document), &doc); err != nil {
+		return replyMessageBody{}, false
+	}
+	return doc.Body, doc.Body.ReplyToMessageID != ""
+}
+
+// threadResolver resolves the messages of a single thread request
+type threadResolver struct {
+	s         *service
+	requester *core.Entity
+	local     map[string]bool // messages hosted on this domain, whose replies are known here
+}
+
+// resolve returns the message from this domain, or from the domain of author if it is hosted elsewhere
+func (r *threadResolver) resolve(ctx context.Context, id, author string) (core.Message, error) {
+	var message core.Message
+	var err error
+	if r.requester != nil {
+		message, err = r.s.message.GetAsUser(ctx, id, *r.requester)
+	} else {
+		message, err = r.s.message.GetAsGuest(ctx, id)
+	}
+	if err == nil {
+		r.local[message.ID] = true
+		return message, nil
+	}
+	if !errors.Is(err, core.ErrorNotFound{}) || author == "" {
+		return core.Message{}, err
+	}
+
+	entity, err := r.s.entity.Get(ctx, author)
+	if err != nil {
+		return core.Message{}, err
+	}
+	if entity.Domain == r.s.config.FQDN {
+		return core.Message{}, core.NewErrorNotFound()
+	}
+
+	return r.s.client.GetMessage(ctx, entity.Domain, id, nil)
+}
+
+// replies returns the reply associations of a message in the order they were made
+func (r *threadResolver) replies(ctx context.Context, messageID string) ([]replyAssociationBody, error) {
+	associations, err := r.s.repo.GetByTarget(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(associations, func(i, j int) bool {
+		return associations[i].CDate.Before(associations[j].CDate)
+	})
+
+	var result []replyAssociationBody
+	for _, association := range associations {
+		if !isReply(association) {
+			continue
+		}
+		var doc core.AssociationDocument[replyAssociationBody]
+		if err := json.Unmarshal([]byte(association.Document), &doc); err != nil || doc.Body.MessageID == "" {
+			continue
+		}
+		result = append(result, doc.Body)
+	}
+	return result, nil
+}
+
+// GetThread resolves the messages a message replies to and the tree of replies below it.
+// parents hosted on other domains are fetched from them. replies are only expanded for messages
+// hosted on this domain, since the reply associations of remote messages are kept by their domain.
+func (s *service) GetThread(ctx context.Context, messageID string, query core.ThreadQuery) (core.Thread, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.GetThread")
+	defer span.End()
+
+	depth := query.Depth
+	if depth <= 0 {
+		depth = defaultThreadDepth
+	} else if depth > maxThreadDepth {
+		depth = maxThreadDepth
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultThreadLimit
+	} else if limit > maxThreadLimit {
+		limit = maxThreadLimit
+	}
+
+	span.SetAttributes(attribute.Int("depth", depth), attribute.Int("limit", limit))
+
+	r := &threadResolver{s: s, local: make(map[string]bool)}
+	if query.Requester != "" {
+		requester, err := s.entity.Get(ctx, query.Requester)
+		if err != nil {
+			span.RecordError(err)
+			return core.Thread{}, err
+		}
+		r.requester = &requester
+	}
+
+	message, err := r.resolve(ctx, messageID, "")
+	if err != nil {
+		span.RecordError(err)
+		return core.Thread{}, err
+	}
+
+	thread := core.Thread{
+		Ancestors: []core.Message{},
+		Message:   &core.ThreadNode{Message: message},
+	}
+
+	// walk up to the root of the conversation
+	visited := map[string]bool{message.ID: true}
+	current := message
+	for len(thread.Ancestors) < maxThreadAncestors {
+		parent, ok := parentOf(current)
+		if !ok || visited[parent.ReplyToMessageID] {
+			break
+		}
+		visited[parent.ReplyToMessageID] = true
+
+		current, err = r.resolve(ctx, parent.ReplyToMessageID, parent.ReplyToMessageAuthor)
+		if err != nil {
+			span.RecordError(err)
+			thread.MissingParent = parent.ReplyToMessageID
+			break
+		}
+		visited[current.ID] = true
+		thread.Ancestors = append(thread.Ancestors, current)
+	}
+	for i, j := 0, len(thread.Ancestors)-1; i < j; i, j = i+1, j-1 {
+		thread.Ancestors[i], thread.Ancestors[j] = thread.Ancestors[j], thread.Ancestors[i]
+	}
+
+	// expand the replies level by level so that shallow replies are preferred over deep ones
+	level := []*core.ThreadNode{thread.Message}
+	for d := 0; len(level) > 0; d++ {
+		var next []*core.ThreadNode
+		for _, node := range level {
+			if !r.local[node.Message.ID] {
+				continue
+			}
+
+			replies, err := r.replies(ctx, node.Message.ID)
+			if err != nil {
+				span.RecordError(err)
+				return core.Thread{}, err
+			}
+			if len(replies) == 0 {
+				continue
+			}
+			if d >= depth || limit <= 0 {
+				node.Truncated = true
+				continue
+			}
+
+			for _, reply := range replies {
+				if limit <= 0 {
+					node.Truncated = true
+					break
+				}
+				if visited[reply.MessageID] {
+					continue
+				}
+
+				child, err := r.resolve(ctx, reply.MessageID, reply.MessageAuthor)
+				if err != nil {
+					// deleted or unreadable replies are left out
+					span.RecordError(err)
+					continue
+				}
+				visited[reply.MessageID] = true
+				visited[child.ID] = true
+
+				childNode := &core.ThreadNode{Message: child}
+				node.Replies = append(node.Replies, childNode)
+				next = append(next, childNode)
+				limit--
+			}
+		}
+		level = next
+	}
+
+	return thread, nil
+}
+
+// threadRoot returns the id of the message which started the conversation of the message.
+// an ancestor which can't be read ends the walk, and its id is taken as the root, so that the replies below it
+// keep to the same thread
+func (r *threadResolver) threadRoot(ctx context.Context, message core.Message) string {
+	root := message.ID
+	visited := map[string]bool{message.ID: true}
+	current := message
+	for i := 0; i < maxThreadAncestors; i++ {
+		parent, ok := parentOf(current)
+		if !ok || visited[parent.ReplyToMessageID] {
+			break
+		}
+		visited[parent.ReplyToMessageID] = true
+		root = parent.ReplyToMessageID
+
+		next, err := r.resolve(ctx, parent.ReplyToMessageID, parent.ReplyToMessageAuthor)
+		if err != nil {
+			break
+		}
+		visited[next.ID] = true
+		root = next.ID
+		current = next
+	}
+	return root
+}
+
+// deliverToThread posts the reply of a reply association to the thread timeline of its conversation on this domain,
+// creating the timeline for the first reply. only public replies are delivered, as thread timelines can be read by anyone.
+// each domain keeps the thread timeline of the reply associations it stores, which are the replies to its messages
+func (s *service) deliverToThread(ctx context.Context, association core.Association) error {
+	ctx, span := tracer.Start(ctx, "Association.Service.deliverToThread")
+	defer span.End()
+
+	var doc core.AssociationDocument[replyAssociationBody]
+	err := json.Unmarshal([]byte(association.Document), &doc)
+	if err != nil || doc.Body.MessageID == "" {
+		return core.NewErrorInvalidArgument("invalid reply association body")
+	}
+
+	r := &threadResolver{s: s, local: make(map[string]bool)}
+	reply, err := r.resolve(ctx, doc.Body.MessageID, doc.Body.MessageAuthor)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	root := r.threadRoot(ctx, reply)
+	span.SetAttributes(attribute.String("root", root))
+
+	thread, err := s.timeline.EnsureThread(ctx, root)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	timeline := thread.ID + "@" + s.config.FQDN
+
+	posted, err := s.timeline.PostItem(ctx, timeline, core.TimelineItem{
+		ResourceID: reply.ID,
+		Owner:      reply.Author,
+		Schema:     reply.Schema,
+		CDate:      reply.CDate,
+	}, reply.Document, reply.Signature)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return s.timeline.PublishEvent(ctx, core.Event{
+		Timeline:  timeline,
+		Item:      &posted,
+		Document:  reply.Document,
+		Signature: reply.Signature,
+		Resource:  reply,
+	})
+}
+
+// GetThreadTimeline returns the thread timeline of the conversation the message is part of.
+// it exists once a reply was made in the conversation while thread timelines were enabled
+func (s *service) GetThreadTimeline(ctx context.Context, messageID string) (core.Timeline, error) {
+	ctx, span := tracer.Start(ctx, "Association.Service.GetThreadTimeline")
+	defer span.End()
+
+	r := &threadResolver{s: s, local: make(map[string]bool)}
+	message, err := r.resolve(ctx, messageID, "")
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+
+	root := r.threadRoot(ctx, message)
+	normalized, err := s.timeline.NormalizeTimelineID(ctx, core.ThreadTimelineSemanticIDPrefix+root+"@"+s.config.FQDN)
+	if err != nil {
+		return core.Timeline{}, core.NewErrorNotFound()
+	}
+
+	return s.timeline.GetTimeline(ctx, normalized)
+}
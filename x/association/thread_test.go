@@ -0,0 +1,56 @@
+package association
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+func TestParentOf(t *testing.T) {
+	reply := core.Message{Document: `{"signer":"con1a","type":"message","schema":"https://schema.concrnt.world/m/reply.json","body":{"body":"hi","replyToMessageId":"mparent","replyToMessageAuthor":"con1b"}}`}
+	parent, ok := parentOf(reply)
+	assert.True(t, ok)
+	assert.Equal(t, "mparent", parent.ReplyToMessageID)
+	assert.Equal(t, "con1b", parent.ReplyToMessageAuthor)
+
+	plain := core.Message{Document: `{"signer":"con1a","type":"message","body":{"body":"hi"}}`}
+	_, ok = parentOf(plain)
+	assert.False(t, ok)
+}
+
+func TestIsReply(t *testing.T) {
+	assert.True(t, isReply(core.Association{Schema: core.ReplyAssociationSchema}))
+	assert.True(t, isReply(core.Association{Variant: core.AssociationVariantReply}))
+	assert.False(t, isReply(core.Association{Variant: core.AssociationVariantRepost}))
+}
+
+func TestThreadRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reply := func(id, parent string) core.Message {
+		return core.Message{ID: id, Document: `{"body":{"replyToMessageId":"` + parent + `"}}`}
+	}
+
+	mockMessage := mock_core.NewMockMessageService(ctrl)
+	mockMessage.EXPECT().GetAsGuest(gomock.Any(), "m2").Return(reply("m2", "m1"), nil).AnyTimes()
+	mockMessage.EXPECT().GetAsGuest(gomock.Any(), "m1").Return(core.Message{ID: "m1", Document: `{"body":{}}`}, nil).AnyTimes()
+	mockMessage.EXPECT().GetAsGuest(gomock.Any(), "m5").Return(core.Message{}, core.NewErrorNotFound()).AnyTimes()
+
+	r := &threadResolver{s: &service{message: mockMessage}, local: make(map[string]bool)}
+	ctx := context.Background()
+
+	assert.Equal(t, "m1", r.threadRoot(ctx, reply("m3", "m2")))
+	assert.Equal(t, "m1", r.threadRoot(ctx, core.Message{ID: "m1", Document: `{"body":{}}`}))
+
+	// an unreadable ancestor is the root of the replies below it
+	assert.Equal(t, "m5", r.threadRoot(ctx, reply("m6", "m5")))
+
+	// cycles end the walk
+	assert.Equal(t, "m7", r.threadRoot(ctx, reply("m7", "m7")))
+}
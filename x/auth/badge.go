@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// verifiedBadges returns the names of the badges of the entity which its domain signed and which are not expired.
+// accepts tells whether a signer signs for the domain
+func verifiedBadges(entity core.Entity, domain string, accepts func(signer string) bool, now time.Time) []string {
+	names := make([]string, 0, len(entity.Badges))
+	for _, badge := range entity.Badges {
+		var doc core.BadgeDocument
+		err := json.Unmarshal([]byte(badge.Document), &doc)
+		if err != nil {
+			continue
+		}
+		if doc.Type != "badge" || doc.Domain != domain || doc.Entity != entity.ID || doc.Name == "" {
+			continue
+		}
+		if doc.ExpiresAt != nil && !doc.ExpiresAt.After(now) {
+			continue
+		}
+		if !accepts(doc.Signer) {
+			continue
+		}
+		signature, err := hex.DecodeString(badge.Signature)
+		if err != nil {
+			continue
+		}
+		if core.VerifySignature([]byte(badge.Document), signature, doc.Signer) != nil {
+			continue
+		}
+		names = append(names, doc.Name)
+	}
+	return names
+}
+
+// passportBadges verifies the badges carried by the entity of an accepted passport against the keys of its domain
+func (s *service) passportBadges(ctx context.Context, passportDoc core.PassportDocument) []string {
+	if len(passportDoc.Entity.Badges) == 0 {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "Auth.Service.passportBadges")
+	defer span.End()
+
+	domain, err := s.domain.GetByFQDN(ctx, passportDoc.Domain)
+	if err != nil {
+		span.RecordError(err)
+		return nil
+	}
+	now := time.Now()
+	return verifiedBadges(passportDoc.Entity, passportDoc.Domain, func(signer string) bool {
+		return domain.AcceptsCSID(signer, now)
+	}, now)
+}
+
+// localBadges returns the names of the live badges of a local entity
+func (s *service) localBadges(ctx context.Context, id string) []string {
+	badges, err := s.entity.ListBadges(ctx, id)
+	if err != nil {
+		trace.SpanFromContext(ctx).RecordError(err)
+		return nil
+	}
+	names := make([]string, len(badges))
+	for i, badge := range badges {
+		names[i] = badge.Name
+	}
+	return names
+}
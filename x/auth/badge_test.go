@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestVerifiedBadges(t *testing.T) {
+	csid, err := core.PrivKeyToAddr(RemoteDomainPriv, "ccs")
+	assert.NoError(t, err)
+	accepts := func(signer string) bool { return signer == csid }
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+
+	issue := func(entity, name string, expiresAt *time.Time) core.Badge {
+		document, err := json.Marshal(core.BadgeDocument{
+			DocumentBase: core.DocumentBase[any]{Signer: csid, Type: "badge", SignedAt: now},
+			Domain:       RemoteDomainFQDN,
+			Entity:       entity,
+			Name:         name,
+			ExpiresAt:    expiresAt,
+		})
+		assert.NoError(t, err)
+		signature, err := core.SignBytes(document, RemoteDomainPriv)
+		assert.NoError(t, err)
+		return core.Badge{Name: name, Document: string(document), Signature: hex.EncodeToString(signature)}
+	}
+
+	tampered := issue(User1ID, "admin", nil)
+	tampered.Document = strings.Replace(tampered.Document, `"admin"`, `"owner"`, 1)
+
+	entity := core.Entity{
+		ID: User1ID,
+		Badges: []core.Badge{
+			issue(User1ID, "verified", nil),
+			issue(User1ID, "moderator", &past),
+			issue(RemoteDomainCCID, "staff", nil),
+			tampered,
+		},
+	}
+
+	assert.Equal(t, []string{"verified"}, verifiedBadges(entity, RemoteDomainFQDN, accepts, now))
+	assert.Empty(t, verifiedBadges(entity, "other.example.com", accepts, now))
+	assert.Empty(t, verifiedBadges(entity, RemoteDomainFQDN, func(string) bool { return false }, now))
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xinguang/go-recaptcha"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	CaptchaProviderRecaptcha   = "recaptcha"
+	CaptchaProviderRecaptchaV3 = "recaptchav3"
+	CaptchaProviderHCaptcha    = "hcaptcha"
+	CaptchaProviderTurnstile   = "turnstile"
+	CaptchaProviderNoop        = "noop"
+
+	recaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+	hcaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+	turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+	defaultRecaptchaV3MinScore = 0.5
+	captchaVerifyTimeout       = 5 * time.Second
+)
+
+// CaptchaProvider verifies a captcha challenge response sent by the client
+type CaptchaProvider interface {
+	Name() string
+	Verify(ctx context.Context, response, remoteIP string) error
+}
+
+// CaptchaConfig selects and configures a CaptchaProvider
+type CaptchaConfig struct {
+	Provider string
+	Secret   string
+	MinScore float64 // recaptchav3 only
+	Action   string  // recaptchav3 only
+}
+
+// NewCaptchaProvider creates a CaptchaProvider from config.
+// empty provider name is treated as "recaptcha" for backward compatibility.
+func NewCaptchaProvider(config CaptchaConfig) (CaptchaProvider, error) {
+	switch config.Provider {
+	case "", CaptchaProviderRecaptcha:
+		validator, err := recaptcha.NewWithSecert(config.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return &recaptchaProvider{validator}, nil
+	case CaptchaProviderRecaptchaV3:
+		minScore := config.MinScore
+		if minScore <= 0 {
+			minScore = defaultRecaptchaV3MinScore
+		}
+		return &siteVerifyProvider{
+			name:      CaptchaProviderRecaptchaV3,
+			endpoint:  recaptchaVerifyURL,
+			secret:    config.Secret,
+			minScore:  minScore,
+			action:    config.Action,
+			client:    &http.Client{Timeout: captchaVerifyTimeout},
+			withScore: true,
+		}, nil
+	case CaptchaProviderHCaptcha:
+		return &siteVerifyProvider{
+			name:     CaptchaProviderHCaptcha,
+			endpoint: hcaptchaVerifyURL,
+			secret:   config.Secret,
+			client:   &http.Client{Timeout: captchaVerifyTimeout},
+		}, nil
+	case CaptchaProviderTurnstile:
+		return &siteVerifyProvider{
+			name:     CaptchaProviderTurnstile,
+			endpoint: turnstileVerifyURL,
+			secret:   config.Secret,
+			client:   &http.Client{Timeout: captchaVerifyTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider: %s", config.Provider)
+	}
+}
+
+// recaptchaProvider is reCAPTCHA v2 (checkbox) provider
+type recaptchaProvider struct {
+	validator *recaptcha.ReCAPTCHA
+}
+
+func (p *recaptchaProvider) Name() string {
+	return CaptchaProviderRecaptcha
+}
+
+func (p *recaptchaProvider) Verify(ctx context.Context, response, remoteIP string) error {
+	return p.validator.Verify(response)
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      float64  `json:"score"`
+	Action     string   `json:"action"`
+	Hostname   string   `json:"hostname"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// siteVerifyProvider covers providers which implement the "siteverify" protocol
+// (reCAPTCHA v3, hCaptcha and Cloudflare Turnstile share the same request shape)
+type siteVerifyProvider struct {
+	name      string
+	endpoint  string
+	secret    string
+	minScore  float64
+	action    string
+	withScore bool
+	client    *http.Client
+}
+
+func (p *siteVerifyProvider) Name() string {
+	return p.name
+}
+
+func (p *siteVerifyProvider) Verify(ctx context.Context, response, remoteIP string) error {
+	ctx, span := tracer.Start(ctx, "Auth.CaptchaProvider.Verify")
+	defer span.End()
+
+	form := url.Values{}
+	form.Set("secret", p.secret)
+	form.Set("response", response)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result siteVerifyResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if !result.Success {
+		return fmt.Errorf("%s verification failed: %v", p.name, result.ErrorCodes)
+	}
+
+	if p.withScore {
+		if result.Score < p.minScore {
+			return fmt.Errorf("%s score too low: %f < %f", p.name, result.Score, p.minScore)
+		}
+		if p.action != "" && result.Action != p.action {
+			return fmt.Errorf("%s action mismatch: %s != %s", p.name, result.Action, p.action)
+		}
+	}
+
+	return nil
+}
+
+type noopCaptchaProvider struct {
+	result bool
+}
+
+// NewNoopCaptchaProvider creates a provider which always returns the given result without any network access.
+// intended for tests only, so it can't be selected by NewCaptchaProvider.
+func NewNoopCaptchaProvider(result bool) CaptchaProvider {
+	return &noopCaptchaProvider{result}
+}
+
+func (p *noopCaptchaProvider) Name() string {
+	return CaptchaProviderNoop
+}
+
+func (p *noopCaptchaProvider) Verify(ctx context.Context, response, remoteIP string) error {
+	if !p.result {
+		return fmt.Errorf("captcha verification failed")
+	}
+	return nil
+}
+
+// Captcha verifies the "captcha" header with given provider and marks the request context as verified
+func Captcha(provider CaptchaProvider) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, span := tracer.Start(c.Request().Context(), "Auth.Service.Captcha")
+			defer span.End()
+
+			challenge := c.Request().Header.Get("captcha")
+			if challenge != "" {
+				err := provider.Verify(ctx, challenge, c.RealIP())
+				if err == nil {
+					span.AddEvent("captcha verified")
+					ctx = context.WithValue(ctx, core.CaptchaVerifiedKey, true)
+				} else {
+					span.AddEvent("captcha verification failed")
+					span.RecordError(err)
+				}
+			} else {
+				span.AddEvent("captcha challenge not found")
+			}
+
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
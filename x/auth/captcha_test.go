@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSiteVerifyServer(t *testing.T, response siteVerifyResponse) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "token", r.PostForm.Get("response"))
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestSiteVerifyProvider(t *testing.T) {
+	ctx := context.Background()
+
+	success := newSiteVerifyServer(t, siteVerifyResponse{Success: true})
+	defer success.Close()
+
+	provider := &siteVerifyProvider{name: CaptchaProviderHCaptcha, endpoint: success.URL, secret: "secret", client: success.Client()}
+	assert.NoError(t, provider.Verify(ctx, "token", ""))
+
+	failure := newSiteVerifyServer(t, siteVerifyResponse{Success: false, ErrorCodes: []string{"invalid-input-response"}})
+	defer failure.Close()
+
+	provider = &siteVerifyProvider{name: CaptchaProviderTurnstile, endpoint: failure.URL, secret: "secret", client: failure.Client()}
+	assert.Error(t, provider.Verify(ctx, "token", ""))
+}
+
+func TestSiteVerifyProviderScore(t *testing.T) {
+	ctx := context.Background()
+
+	server := newSiteVerifyServer(t, siteVerifyResponse{Success: true, Score: 0.3, Action: "register"})
+	defer server.Close()
+
+	provider := &siteVerifyProvider{name: CaptchaProviderRecaptchaV3, endpoint: server.URL, secret: "secret", client: server.Client(), withScore: true, minScore: 0.5}
+	assert.Error(t, provider.Verify(ctx, "token", ""))
+
+	provider.minScore = 0.2
+	assert.NoError(t, provider.Verify(ctx, "token", ""))
+
+	provider.action = "login"
+	assert.Error(t, provider.Verify(ctx, "token", ""))
+}
+
+func TestNewCaptchaProvider(t *testing.T) {
+	// the noop provider would let every captcha pass, so the config can't select it
+	_, err := NewCaptchaProvider(CaptchaConfig{Provider: CaptchaProviderNoop})
+	assert.Error(t, err)
+
+	provider, err := NewCaptchaProvider(CaptchaConfig{Provider: CaptchaProviderTurnstile, Secret: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, CaptchaProviderTurnstile, provider.Name())
+
+	_, err = NewCaptchaProvider(CaptchaConfig{Provider: "unknown"})
+	assert.Error(t, err)
+
+	assert.NoError(t, NewNoopCaptchaProvider(true).Verify(context.Background(), "anything", ""))
+	assert.Error(t, NewNoopCaptchaProvider(false).Verify(context.Background(), "anything", ""))
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// delegationMaxValidity bounds how long a delegation is valid, as it can't be revoked but by rotating the CSID
+const delegationMaxValidity = 90 * 24 * time.Hour
+
+// IssueDelegation signs a delegation for the key of a companion service, so that it can sign passports
+// limited to the scopes without holding the private key of the domain
+func (s *service) IssueDelegation(ctx context.Context, serviceName, key string, scopes core.Scopes, until time.Time) (string, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.IssueDelegation")
+	defer span.End()
+
+	if serviceName == "" {
+		return "", core.NewErrorInvalidArgument("service is required")
+	}
+	if !core.IsCSID(key) {
+		return "", core.NewErrorInvalidArgument("key must be a CSID")
+	}
+	if key == s.config.CSID {
+		return "", core.NewErrorInvalidArgument("key must not be the CSID of the domain")
+	}
+	if len(scopes) == 0 {
+		return "", core.NewErrorInvalidArgument("a delegation needs scopes")
+	}
+	for _, scope := range scopes {
+		if err := core.ValidateScope(scope); err != nil {
+			return "", core.NewErrorInvalidArgument(err.Error())
+		}
+	}
+	now := time.Now()
+	if !until.After(now) || until.Sub(now) > delegationMaxValidity {
+		return "", core.NewErrorInvalidArgument("until must be within " + delegationMaxValidity.String())
+	}
+
+	document, err := json.Marshal(core.DelegationDocument{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   s.config.CSID,
+			Type:     "delegation",
+			SignedAt: now,
+		},
+		Domain:  s.config.FQDN,
+		Service: serviceName,
+		Key:     key,
+		Scopes:  scopes,
+		Until:   until,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	signature, err := core.SignBytes(document, s.config.PrivateKey)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	delegation, err := json.Marshal(core.Passport{
+		Document:  string(document),
+		Signature: hex.EncodeToString(signature),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(delegation), nil
+}
+
+// SignDelegatedPassport signs the passport with the key of a companion service, embedding its delegation.
+// the passport is for the entities of the domain which issued the delegation, as the ones issued by the domain itself
+func SignDelegatedPassport(delegation, privateKey string, doc core.PassportDocument) (string, error) {
+	delegationJson, err := base64.URLEncoding.DecodeString(delegation)
+	if err != nil {
+		return "", err
+	}
+	var signed core.Passport
+	err = json.Unmarshal(delegationJson, &signed)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := core.PrivKeyToAddr(privateKey, "ccs")
+	if err != nil {
+		return "", err
+	}
+
+	doc.Signer = signer
+	doc.Type = "passport"
+	doc.SignedAt = time.Now()
+	doc.Delegation = &signed
+
+	document, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	signature, err := core.SignBytes(document, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	passport, err := json.Marshal(core.Passport{
+		Document:  string(document),
+		Signature: hex.EncodeToString(signature),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(passport), nil
+}
+
+// verifyDelegation checks the delegation of the key which signed the passport and returns the scopes
+// the passport is limited to. those are the scopes of the passport, which the delegation must allow,
+// or the ones of the delegation if the passport has none
+func (s *service) verifyDelegation(ctx context.Context, doc core.PassportDocument, domain core.Domain) (core.Scopes, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.verifyDelegation")
+	defer span.End()
+
+	var delegation core.DelegationDocument
+	err := json.Unmarshal([]byte(doc.Delegation.Document), &delegation)
+	if err != nil {
+		return nil, err
+	}
+
+	if delegation.Type != "delegation" {
+		return nil, fmt.Errorf("invalid delegation type: %s", delegation.Type)
+	}
+	if delegation.Domain != doc.Domain || delegation.Key != doc.Signer {
+		return nil, fmt.Errorf("delegation is not for the signer of the passport")
+	}
+	now := time.Now()
+	if !now.Before(delegation.Until) {
+		return nil, fmt.Errorf("delegation expired at %s", delegation.Until)
+	}
+	if len(delegation.Scopes) == 0 {
+		return nil, fmt.Errorf("delegation has no scopes")
+	}
+
+	if !domain.AcceptsCSID(delegation.Signer, now) {
+		span.AddEvent("force fetch domain")
+		domain, err = s.domain.ForceFetch(ctx, domain.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !domain.AcceptsCSID(delegation.Signer, now) {
+			return nil, fmt.Errorf("delegation is not signed by the domain")
+		}
+	}
+
+	signature, err := hex.DecodeString(doc.Delegation.Signature)
+	if err != nil {
+		return nil, err
+	}
+	err = core.VerifySignature([]byte(doc.Delegation.Document), signature, delegation.Signer)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := core.Scopes(delegation.Scopes)
+	if len(doc.Scopes) == 0 {
+		return allowed, nil
+	}
+	for _, scope := range doc.Scopes {
+		action, target, _ := strings.Cut(scope, ":")
+		if !allowed.Allows(action, target) {
+			return nil, fmt.Errorf("scope %s is not delegated to %s", scope, delegation.Service)
+		}
+	}
+	return core.Scopes(doc.Scopes), nil
+}
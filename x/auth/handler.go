@@ -2,10 +2,13 @@
 package auth
 
 import (
+	"errors"
 	"github.com/labstack/echo/v4"
 	"github.com/totegamma/concurrent/core"
 	"go.opentelemetry.io/otel"
 	"net/http"
+	"strings"
+	"time"
 )
 
 var tracer = otel.Tracer("auth")
@@ -13,6 +16,10 @@ var tracer = otel.Tracer("auth")
 // Handler is the interface for handling HTTP requests
 type Handler interface {
 	GetPassport(c echo.Context) error
+	IssueDelegation(c echo.Context) error
+	ListTokens(c echo.Context) error
+	IssueToken(c echo.Context) error
+	RevokeToken(c echo.Context) error
 }
 
 type handler struct {
@@ -37,7 +44,32 @@ func (h *handler) GetPassport(c echo.Context) error {
 
 	keys, ok := ctx.Value(core.RequesterKeychainKey).([]core.Key)
 
-	response, err := h.service.IssuePassport(ctx, requester, keys)
+	// ?audience=a.example.com,b.example.com&scopes=timeline.read limits where and for what the passport is used
+	var audience []string
+	if audienceStr := c.QueryParam("audience"); audienceStr != "" {
+		audience = strings.Split(audienceStr, ",")
+	}
+	var scopes core.Scopes
+	if scopesStr := c.QueryParam("scopes"); scopesStr != "" {
+		scopes = core.ParseScopes(scopesStr)
+	}
+	// a scoped token can not widen its scopes with a passport
+	if tokenScopes, scoped := ctx.Value(core.RequesterScopesKey).(core.Scopes); scoped {
+		if scopes == nil {
+			scopes = tokenScopes
+		}
+		for _, scope := range scopes {
+			action, target, _ := strings.Cut(scope, ":")
+			if !tokenScopes.Allows(action, target) {
+				return c.JSON(http.StatusForbidden, echo.Map{"error": "scope is not allowed by your token: " + scope})
+			}
+		}
+	}
+
+	response, err := h.service.IssuePassport(ctx, requester, keys, audience, scopes)
+	if errors.Is(err, core.ErrorInvalidArgument{}) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
 	if err != nil {
 		span.RecordError(err)
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
@@ -45,3 +77,130 @@ func (h *handler) GetPassport(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, echo.Map{"content": response})
 }
+
+// DelegationRequest is the request body of IssueDelegation
+type DelegationRequest struct {
+	Service string    `json:"service"`
+	Key     string    `json:"key"` // the CSID the service signs passports with
+	Scopes  []string  `json:"scopes"`
+	Until   time.Time `json:"until"`
+}
+
+// IssueDelegation lets a companion service sign passports with its own key, limited to the scopes
+func (h *handler) IssueDelegation(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Auth.Handler.IssueDelegation")
+	defer span.End()
+
+	var request DelegationRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request"})
+	}
+
+	delegation, err := h.service.IssueDelegation(ctx, request.Service, request.Key, request.Scopes, request.Until)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": delegation})
+}
+
+// TokenRequest is the request body of IssueToken
+type TokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	KeyID     string     `json:"keyID"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// IssuedToken is the response of IssueToken. Secret is shown only once.
+type IssuedToken struct {
+	core.AuthToken
+	Secret string `json:"secret"`
+}
+
+// tokenRequester returns the requester if it may manage tokens. scoped tokens can not mint or revoke others.
+func tokenRequester(c echo.Context) (string, bool) {
+	ctx := c.Request().Context()
+	if _, scoped := ctx.Value(core.RequesterScopesKey).(core.Scopes); scoped {
+		return "", false
+	}
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	return requester, ok
+}
+
+// ListTokens returns scoped tokens of the requester
+func (h *handler) ListTokens(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Auth.Handler.ListTokens")
+	defer span.End()
+
+	requester, ok := tokenRequester(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	tokens, err := h.service.ListTokens(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": tokens})
+}
+
+// IssueToken mints a new scoped token
+func (h *handler) IssueToken(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Auth.Handler.IssueToken")
+	defer span.End()
+
+	requester, ok := tokenRequester(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	var request TokenRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	token, secret, err := h.service.IssueToken(ctx, core.AuthToken{
+		Owner:     requester,
+		Name:      request.Name,
+		KeyID:     request.KeyID,
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		if errors.Is(err, core.ErrorPermissionDenied{}) {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "the key does not belong to you"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{"status": "ok", "content": IssuedToken{AuthToken: token, Secret: secret}})
+}
+
+// RevokeToken revokes a scoped token of the requester
+func (h *handler) RevokeToken(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Auth.Handler.RevokeToken")
+	defer span.End()
+
+	requester, ok := tokenRequester(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	err := h.service.RevokeToken(ctx, requester, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "token not found"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
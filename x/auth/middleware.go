@@ -7,18 +7,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 	"github.com/totegamma/concurrent/x/jwt"
 	"github.com/totegamma/concurrent/x/key"
-	"github.com/xinguang/go-recaptcha"
+	"github.com/totegamma/concurrent/x/keyspace"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Principal int
@@ -45,6 +49,10 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 		// リクエストに必要な情報を補完するのに使う。
 		passportHeader := c.Request().Header.Get("passport")
 
+		// badges the verified passport carries, for the entity it was issued to
+		var passportEntity string
+		var passportBadges []string
+
 		if passportHeader != "" {
 			ctx = context.WithValue(ctx, core.RequesterPassportKey, passportHeader)
 
@@ -73,6 +81,21 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 				goto skipCheckPassport
 			}
 
+			if !passportAccepted(passportDoc, s.config.FQDN) {
+				span.RecordError(fmt.Errorf("passport is not for this domain"))
+				goto skipCheckPassport
+			}
+
+			// delegated passports are verified every time, as their delegation may have expired
+			if passportDoc.Delegation == nil && s.isPassportVerified(ctx, passport) {
+				span.AddEvent("passport cache hit")
+				ctx = context.WithValue(ctx, core.RequesterKeychainKey, passportDoc.Keys)
+				ctx = withPassportScopes(ctx, passportDoc)
+				passportEntity = passportDoc.Entity.ID
+				passportBadges = s.passportBadges(ctx, passportDoc)
+				goto skipCheckPassport
+			}
+
 			domain, err := s.domain.GetByFQDN(ctx, passportDoc.Domain)
 			if err != nil {
 				span.RecordError(errors.Wrap(err, "failed to get domain by fqdn"))
@@ -85,13 +108,27 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 				goto skipCheckPassport
 			}
 
-			if core.IsCSID(passportDoc.Signer) && domain.CSID == "" {
+			// the passport is signed by the domain, or by a companion service the domain delegated to
+			if passportDoc.Delegation != nil {
+				scopes, err := s.verifyDelegation(ctx, passportDoc, domain)
+				if err != nil {
+					span.RecordError(errors.Wrap(err, "failed to verify delegation of passport"))
+					goto skipCheckPassport
+				}
+				passportDoc.Scopes = scopes
+				span.SetAttributes(attribute.String("PassportDelegatedKey", passportDoc.Signer))
+			} else if passportDoc.Signer != domain.CCID && !domain.AcceptsCSID(passportDoc.Signer, time.Now()) {
+				// a signer the domain is not known with may be its new CSID after a rotation
 				span.AddEvent("force fetch domain")
-				_, err := s.domain.ForceFetch(ctx, domain.ID)
+				domain, err = s.domain.ForceFetch(ctx, domain.ID)
 				if err != nil {
 					span.RecordError(errors.Wrap(err, "failed to force fetch domain"))
 					goto skipCheckPassport
 				}
+				if !domain.AcceptsCSID(passportDoc.Signer, time.Now()) {
+					span.RecordError(fmt.Errorf("passport is not signed by the domain"))
+					goto skipCheckPassport
+				}
 			}
 
 			err = core.VerifySignature([]byte(passport.Document), signatureBytes, passportDoc.Signer)
@@ -136,10 +173,18 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 				}
 			}
 
+			if passportDoc.Delegation == nil {
+				s.markPassportVerified(ctx, passport, passportDoc.Keys)
+			}
+
 			ctx = context.WithValue(ctx, core.RequesterKeychainKey, passportDoc.Keys)
+			ctx = withPassportScopes(ctx, passportDoc)
+			passportEntity = passportDoc.Entity.ID
+			passportBadges = s.passportBadges(ctx, passportDoc)
 		}
 	skipCheckPassport:
 
+		var ccid, subkey string
 		if authHeader != "" {
 			split := strings.Split(authHeader, " ")
 			if len(split) != 2 {
@@ -153,53 +198,60 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 				goto skipCheckAuthorization
 			}
 
-			claims, err := jwt.Validate(token)
-			if err != nil {
-				span.RecordError(errors.Wrap(err, "jwt validation failed"))
-				goto skipCheckAuthorization
-			}
+			if strings.HasPrefix(token, scopedTokenPrefix) {
+				owner, scopes, err := s.resolveToken(ctx, token)
+				if err != nil {
+					span.RecordError(errors.Wrap(err, "scoped token validation failed"))
+					goto skipCheckAuthorization
+				}
+				ccid = owner
+				ctx = context.WithValue(ctx, core.RequesterScopesKey, scopes)
+				span.SetAttributes(attribute.String("RequesterScopes", scopes.ToString()))
+			} else {
+				claims, err := jwt.Validate(token)
+				if err != nil {
+					span.RecordError(errors.Wrap(err, "jwt validation failed"))
+					goto skipCheckAuthorization
+				}
 
-			if claims.Audience != s.config.FQDN {
-				span.RecordError(fmt.Errorf("jwt is not for this domain"))
-				goto skipCheckAuthorization
-			}
+				if claims.Audience != s.config.FQDN {
+					span.RecordError(fmt.Errorf("jwt is not for this domain"))
+					goto skipCheckAuthorization
+				}
 
-			if claims.Subject != "concrnt" {
-				span.RecordError(fmt.Errorf("invalid subject"))
+				if claims.Subject != "concrnt" {
+					span.RecordError(fmt.Errorf("invalid subject"))
+					goto skipCheckAuthorization
+				}
+
+				ctx, ccid, err = s.resolveIssuer(ctx, claims.Issuer)
+				if err != nil {
+					span.RecordError(err)
+					goto skipCheckAuthorization
+				}
+				if core.IsCKID(claims.Issuer) {
+					subkey = claims.Issuer
+				}
+			}
+		} else if signatureHeader := c.Request().Header.Get(core.RequestSignatureHeader); signatureHeader != "" {
+			// scripted clients sign the request instead of holding a JWT
+			signer, err := s.verifyRequestSignature(ctx, c.Request(), signatureHeader)
+			if err != nil {
+				span.RecordError(errors.Wrap(err, "request signature validation failed"))
 				goto skipCheckAuthorization
 			}
 
-			var ccid string
-			if core.IsCCID(claims.Issuer) {
-				ccid = claims.Issuer
-			} else if core.IsCKID(claims.Issuer) {
-				if providedKeyChain, ok := ctx.Value(core.RequesterKeychainKey).([]core.Key); ok {
-					ccid, err = key.ValidateKeyResolution(providedKeyChain)
-					if err != nil {
-						span.RecordError(errors.Wrap(err, "failed to validate key resolution"))
-						goto skipCheckAuthorization
-					}
-				} else {
-
-					keys, err := s.key.GetKeyResolution(ctx, claims.Issuer)
-					if err != nil {
-						span.RecordError(errors.Wrap(err, "failed to get key resolution"))
-						goto skipCheckAuthorization
-					}
-					ctx = context.WithValue(ctx, core.RequesterKeychainKey, keys)
-
-					ccid, err = s.key.ResolveSubkey(ctx, claims.Issuer)
-					if err != nil {
-						span.RecordError(errors.Wrap(err, "failed to resolve subkey"))
-						goto skipCheckAuthorization
-					}
-
-				}
-			} else {
-				span.RecordError(fmt.Errorf("invalid issuer"))
+			ctx, ccid, err = s.resolveIssuer(ctx, signer)
+			if err != nil {
+				span.RecordError(err)
 				goto skipCheckAuthorization
 			}
+			if core.IsCKID(signer) {
+				subkey = signer
+			}
+		}
 
+		if ccid != "" {
 			entity, err := s.entity.Get(ctx, ccid)
 			if err != nil {
 				span.RecordError(errors.Wrap(err, "failed to get entity"))
@@ -224,7 +276,9 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 					if !ok {
 						keys = nil
 					}
-					passport, err := s.IssuePassport(ctx, ccid, keys)
+					// passports issued for a scoped token carry its scopes to the remote domains
+					scopes, _ := ctx.Value(core.RequesterScopesKey).(core.Scopes)
+					passport, err := s.IssuePassport(ctx, ccid, keys, nil, scopes)
 					if err == nil {
 						ctx = context.WithValue(ctx, core.RequesterPassportKey, passport)
 
@@ -237,6 +291,18 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 				span.SetAttributes(attribute.String("RequesterId", ccid))
 				ctx = context.WithValue(ctx, core.RequesterTypeCtxKey, core.LocalUser)
 				span.SetAttributes(attribute.String("RequesterType", core.RequesterTypeString(core.LocalUser)))
+				ctx = context.WithValue(ctx, core.RequesterBadgesKey, s.localBadges(ctx, ccid))
+
+				// the last use of the subkey is listed with the sessions of the user. it is recorded
+				// in the background so that the request doesn't wait for it
+				if subkey != "" {
+					go func(ctx context.Context, userAgent string) {
+						err := s.key.Touch(ctx, subkey, userAgent)
+						if err != nil {
+							logging.Warn(ctx, "failed to record key usage", slog.String("module", "auth"), slog.String("key", subkey), slog.String("error", err.Error()))
+						}
+					}(context.WithoutCancel(ctx), c.Request().UserAgent())
+				}
 			} else {
 
 				domain, err = s.domain.GetByFQDN(ctx, entity.Domain)
@@ -262,6 +328,10 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 				span.SetAttributes(attribute.String("RequesterDomain", entity.Domain))
 				ctx = context.WithValue(ctx, core.RequesterDomainTagsKey, domainTags)
 				span.SetAttributes(attribute.String("RequesterDomainTags", domain.Tag))
+				if passportEntity == ccid {
+					ctx = context.WithValue(ctx, core.RequesterBadgesKey, passportBadges)
+					span.SetAttributes(attribute.StringSlice("RequesterBadges", passportBadges))
+				}
 			}
 
 			_, err = s.entity.GetMeta(ctx, ccid)
@@ -291,11 +361,73 @@ func (s *service) IdentifyIdentity(next echo.HandlerFunc) echo.HandlerFunc {
 
 		}
 	skipCheckAuthorization:
+		// other domains sign the chunk fetches they make for their users
+		if domainPassportHeader := c.Request().Header.Get(core.DomainPassportHeader); domainPassportHeader != "" {
+			ctx = s.identifyDomain(ctx, domainPassportHeader)
+		}
+
 		c.SetRequest(c.Request().WithContext(ctx))
 		return next(c)
 	}
 }
 
+// resolveIssuer returns the entity of the key which authenticated the request.
+// subkeys bound to scoped tokens are limited to the scopes of those tokens, and others to the scopes they were enacted with
+func (s *service) resolveIssuer(ctx context.Context, issuer string) (context.Context, string, error) {
+	if core.IsCCID(issuer) {
+		return ctx, issuer, nil
+	}
+	if !core.IsCKID(issuer) {
+		return ctx, "", fmt.Errorf("invalid issuer")
+	}
+
+	var ccid string
+	var err error
+	keys, ok := ctx.Value(core.RequesterKeychainKey).([]core.Key)
+	if ok {
+		ccid, err = key.ValidateKeyResolution(keys)
+		if err != nil {
+			return ctx, "", errors.Wrap(err, "failed to validate key resolution")
+		}
+	} else {
+		keys, err = s.key.GetKeyResolution(ctx, issuer)
+		if err != nil {
+			return ctx, "", errors.Wrap(err, "failed to get key resolution")
+		}
+		ctx = context.WithValue(ctx, core.RequesterKeychainKey, keys)
+
+		ccid, err = s.key.ResolveSubkey(ctx, issuer)
+		if err != nil {
+			return ctx, "", errors.Wrap(err, "failed to resolve subkey")
+		}
+	}
+
+	scopes, bound, err := s.keyScopes(ctx, issuer)
+	if err != nil {
+		return ctx, "", errors.Wrap(err, "failed to get scopes of subkey")
+	}
+	if bound && len(scopes) == 0 {
+		return ctx, "", fmt.Errorf("every token of the subkey is expired or revoked")
+	}
+	if !bound {
+		// a subkey enacted with scopes is limited by them on every domain. commits check the scopes
+		// of the whole keychain again, so the innermost limit is enough to keep the key from other writes
+		signed, err := key.SignedScopes(keys)
+		if err != nil {
+			return ctx, "", errors.Wrap(err, "failed to get scopes of subkey")
+		}
+		if len(signed) > 0 {
+			scopes, bound = signed[0], true
+		}
+	}
+	if bound {
+		ctx = context.WithValue(ctx, core.RequesterScopesKey, scopes)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("RequesterScopes", scopes.ToString()))
+	}
+
+	return ctx, ccid, nil
+}
+
 func ReceiveGatewayAuthPropagation(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		ctx, span := tracer.Start(c.Request().Context(), "Auth.Service.ReceiveGatewayAuthPropagation")
@@ -310,6 +442,10 @@ func ReceiveGatewayAuthPropagation(next echo.HandlerFunc) echo.HandlerFunc {
 		reqCaptchaVerifiedHeader := c.Request().Header.Get(core.CaptchaVerifiedHeader)
 		reqPassportHeader := c.Request().Header.Get(core.RequesterPassportHeader)
 		reqRegisteredHeader := c.Request().Header.Get(core.RequesterIsRegisteredHeader)
+		reqScopesHeader := c.Request().Header.Get(core.RequesterScopesHeader)
+		reqPassportScopesHeader := c.Request().Header.Get(core.RequesterPassportScopesHeader)
+		reqVouchedIdHeader := c.Request().Header.Get(core.RequesterVouchedIdHeader)
+		reqBadgesHeader := c.Request().Header.Get(core.RequesterBadgesHeader)
 
 		if reqTypeHeader != "" {
 			reqType, err := strconv.Atoi(reqTypeHeader)
@@ -366,11 +502,97 @@ func ReceiveGatewayAuthPropagation(next echo.HandlerFunc) echo.HandlerFunc {
 			}
 		}
 
+		if reqScopesHeader != "" {
+			ctx = context.WithValue(ctx, core.RequesterScopesKey, core.ParseScopes(reqScopesHeader))
+			span.SetAttributes(attribute.String("RequesterScopes", reqScopesHeader))
+		}
+
+		if reqPassportScopesHeader != "" {
+			ctx = context.WithValue(ctx, core.RequesterPassportScopesKey, core.ParseScopes(reqPassportScopesHeader))
+			span.SetAttributes(attribute.String("RequesterPassportScopes", reqPassportScopesHeader))
+		}
+
+		if reqVouchedIdHeader != "" {
+			ctx = context.WithValue(ctx, core.RequesterVouchedIdCtxKey, reqVouchedIdHeader)
+			span.SetAttributes(attribute.String("RequesterVouchedId", reqVouchedIdHeader))
+		}
+
+		if reqBadgesHeader != "" {
+			ctx = context.WithValue(ctx, core.RequesterBadgesKey, strings.Split(reqBadgesHeader, ","))
+			span.SetAttributes(attribute.String("RequesterBadges", reqBadgesHeader))
+		}
+
 		c.SetRequest(c.Request().WithContext(ctx))
 		return next(c)
 	}
 }
 
+// scopedRoutes are the routes which let writes made with a scoped token or passport through, as "METHOD path"
+var scopedRoutes sync.Map
+
+// AllowScoped opts the route out of ScopeGuard. its handler is responsible for checking the scopes of the request
+func AllowScoped(route *echo.Route) *echo.Route {
+	scopedRoutes.Store(route.Method+" "+route.Path, true)
+	return route
+}
+
+// ScopeGuard rejects requests made with a scoped token or passport, except for reads and the routes opted out with AllowScoped.
+// commits are checked against the scopes by the store, reads of passports by RequireScope.
+func ScopeGuard(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx, span := tracer.Start(c.Request().Context(), "Auth.Service.ScopeGuard")
+		defer span.End()
+
+		_, scoped := ctx.Value(core.RequesterScopesKey).(core.Scopes)
+		_, passportScoped := ctx.Value(core.RequesterPassportScopesKey).(core.Scopes)
+		if scoped || passportScoped {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				if _, allowed := scopedRoutes.Load(c.Request().Method + " " + c.Path()); !allowed {
+					return c.JSON(http.StatusForbidden, echo.Map{
+						"error":  "you are not authorized to perform this action",
+						"detail": "scoped tokens can only commit documents",
+					})
+				}
+			}
+		}
+
+		return next(c)
+	}
+}
+
+// RequireScope rejects requests made with a scoped passport which does not allow the action.
+// the id param of the route, if any, is the target of the action.
+// scoped tokens are not limited on reads, so only the scopes of passports are checked
+func RequireScope(action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, span := tracer.Start(c.Request().Context(), "Auth.Service.RequireScope")
+			defer span.End()
+
+			scopes, scoped := ctx.Value(core.RequesterPassportScopesKey).(core.Scopes)
+			if !scoped {
+				return next(c)
+			}
+
+			var targets []string
+			if id := c.Param("id"); id != "" {
+				targets = append(targets, id)
+			}
+
+			if !scopes.Allows(action, targets...) {
+				return c.JSON(http.StatusForbidden, echo.Map{
+					"error":  "you are not authorized to perform this action",
+					"detail": "your passport does not allow " + action,
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
 func Restrict(principal Principal) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -427,31 +649,6 @@ func Restrict(principal Principal) echo.MiddlewareFunc {
 	}
 }
 
-func Recaptcha(validator *recaptcha.ReCAPTCHA) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			ctx, span := tracer.Start(c.Request().Context(), "Auth.Service.Recaptcha")
-			defer span.End()
-
-			challenge := c.Request().Header.Get("captcha")
-			if challenge != "" {
-				err := validator.Verify(challenge)
-				if err == nil {
-					span.AddEvent("captcha verified")
-					ctx = context.WithValue(ctx, core.CaptchaVerifiedKey, true)
-				} else {
-					span.AddEvent("captcha verification failed")
-				}
-			} else {
-				span.AddEvent("captcha challenge not found")
-			}
-
-			c.SetRequest(c.Request().WithContext(ctx))
-			return next(c)
-		}
-	}
-}
-
 func (s *service) RateLimiter(configMap core.RateLimitConfigMap) echo.MiddlewareFunc {
 
 	routerEcho := echo.New()
@@ -534,12 +731,17 @@ func (s *service) RateLimiter(configMap core.RateLimitConfigMap) echo.Middleware
 				path = "DEFAULT"
 			}
 
+			// the admin may lower (or raise) the limits of the users of a remote domain
+			if domain, ok := ctx.Value(core.RequesterDomainCtxKey).(string); ok && domain != s.config.FQDN {
+				config = scaleRateLimit(config, s.domain.RateLimitScale(ctx, domain))
+			}
+
 			requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
 			if !ok {
 				requester = c.RealIP()
 			}
 
-			key := "rate_limit:" + requester + ":" + path
+			key := keyspace.RateLimit.Key(requester, path)
 
 			// Get the current value of the bucket
 			val, err := s.rdb.Get(ctx, key).Result()
@@ -611,3 +813,14 @@ func (s *service) RateLimiter(configMap core.RateLimitConfigMap) echo.Middleware
 		}
 	}
 }
+
+// scaleRateLimit multiplies both the burst and the sustained rate of the limit. 0 keeps it,
+// and a bucket holds one request at least
+func scaleRateLimit(config core.RateLimitConfig, scale float64) core.RateLimitConfig {
+	if scale <= 0 {
+		return config
+	}
+	config.BucketSize = max(1, int(float64(config.BucketSize)*scale))
+	config.RefillSpan = config.RefillSpan / scale
+	return config
+}
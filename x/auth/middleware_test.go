@@ -1,13 +1,19 @@
 package auth
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -54,6 +60,7 @@ func TestLocalRootSuccess(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().ListBadges(gomock.Any(), gomock.Any()).Return([]core.Badge{}, nil).AnyTimes()
 	mockEntity.EXPECT().Get(gomock.Any(), gomock.Any()).Return(core.Entity{
 		ID:     User1ID,
 		Domain: "local.example.com",
@@ -68,7 +75,7 @@ func TestLocalRootSuccess(t *testing.T) {
 		FQDN: "local.example.com",
 	}
 
-	service := NewService(nil, config, mockEntity, mockDomain, mockKey, mockPolicy)
+	service := NewService(nil, nil, config, mockEntity, mockDomain, mockKey, mockPolicy)
 
 	c, req, rec, traceID := testutil.CreateHttpRequest()
 
@@ -108,6 +115,7 @@ func TestRemoteRootSuccess(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().ListBadges(gomock.Any(), gomock.Any()).Return([]core.Badge{}, nil).AnyTimes()
 	mockEntity.EXPECT().Affiliation(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Entity{
 		ID:     User1ID,
 		Domain: RemoteDomainFQDN,
@@ -134,7 +142,7 @@ func TestRemoteRootSuccess(t *testing.T) {
 		FQDN: "local.example.com",
 	}
 
-	service := NewService(nil, config, mockEntity, mockDomain, mockKey, mockPolicy)
+	service := NewService(nil, nil, config, mockEntity, mockDomain, mockKey, mockPolicy)
 	c, req, rec, traceID := testutil.CreateHttpRequest()
 
 	fmt.Print("traceID: ", traceID, "\n")
@@ -204,3 +212,355 @@ func TestRemoteRootSuccess(t *testing.T) {
 	log.Println(traceID)
 
 }
+
+func createPassport(t *testing.T, doc core.PassportDocument) string {
+	docJson, err := json.Marshal(doc)
+	if !assert.NoError(t, err) {
+		log.Fatal(err)
+	}
+	signatureBytes, err := core.SignBytes(docJson, RemoteDomainPriv)
+	if !assert.NoError(t, err) {
+		log.Fatal(err)
+	}
+	passportJson, _ := json.Marshal(core.Passport{
+		Document:  string(docJson),
+		Signature: hex.EncodeToString(signatureBytes),
+	})
+	return base64.URLEncoding.EncodeToString(passportJson)
+}
+
+func TestRemotePassportAudience(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().ListBadges(gomock.Any(), gomock.Any()).Return([]core.Badge{}, nil).AnyTimes()
+	mockEntity.EXPECT().Affiliation(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Entity{
+		ID:           User1ID,
+		Domain:       RemoteDomainFQDN,
+		IsScoreFixed: true,
+	}, nil).Times(1)
+	mockEntity.EXPECT().Get(gomock.Any(), gomock.Any()).Return(core.Entity{
+		ID:     User1ID,
+		Domain: RemoteDomainFQDN,
+	}, nil).AnyTimes()
+	mockEntity.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(core.EntityMeta{}, nil).AnyTimes()
+
+	mockDomain := mock_core.NewMockDomainService(ctrl)
+	mockDomain.EXPECT().GetByFQDN(gomock.Any(), RemoteDomainFQDN).Return(core.Domain{
+		ID:   RemoteDomainFQDN,
+		CCID: RemoteDomainCCID,
+	}, nil).AnyTimes()
+
+	mockKey := mock_core.NewMockKeyService(ctrl)
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockPolicy.EXPECT().TestWithGlobalPolicy(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.PolicyEvalResultAllow, nil).AnyTimes()
+
+	config := core.Config{
+		FQDN: "local.example.com",
+	}
+
+	service := NewService(nil, nil, config, mockEntity, mockDomain, mockKey, mockPolicy)
+
+	identify := func(audience string) context.Context {
+		c, req, _, _ := testutil.CreateHttpRequest()
+		req.Header.Set("Authorization", "Bearer "+createJwt(t, User1Priv, jwt.Claims{
+			Issuer:   User1ID,
+			Subject:  "concrnt",
+			Audience: "local.example.com",
+		}))
+		req.Header.Set("passport", createPassport(t, core.PassportDocument{
+			DocumentBase: core.DocumentBase[any]{
+				Signer: RemoteDomainCCID,
+			},
+			Domain: RemoteDomainFQDN,
+			Entity: core.Entity{
+				ID:     User1ID,
+				Domain: RemoteDomainFQDN,
+			},
+			Keys:     []core.Key{},
+			Audience: []string{AudienceHash(audience)},
+			Scopes:   []string{"timeline.read"},
+		}))
+
+		err := service.IdentifyIdentity(func(c echo.Context) error { return nil })(c)
+		assert.NoError(t, err)
+		return c.Request().Context()
+	}
+
+	// a passport for another domain is ignored
+	ctx := identify("other.example.com")
+	assert.Nil(t, ctx.Value(core.RequesterKeychainKey))
+	assert.Nil(t, ctx.Value(core.RequesterPassportScopesKey))
+
+	ctx = identify("LOCAL.example.com")
+	assert.Len(t, ctx.Value(core.RequesterKeychainKey).([]core.Key), 0)
+	assert.Equal(t, core.Scopes{"timeline.read"}, ctx.Value(core.RequesterPassportScopesKey))
+}
+
+func TestRequireScope(t *testing.T) {
+	handler := RequireScope("timeline.read")(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	serve := func(scopes core.Scopes, id string) int {
+		c, _, rec, _ := testutil.CreateHttpRequest()
+		if scopes != nil {
+			c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), core.RequesterPassportScopesKey, scopes)))
+		}
+		if id != "" {
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+		}
+		assert.NoError(t, handler(c))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(nil, ""))
+	assert.Equal(t, http.StatusOK, serve(core.Scopes{"timeline.*"}, ""))
+	assert.Equal(t, http.StatusOK, serve(core.Scopes{"timeline.read:tl1"}, "tl1"))
+	assert.Equal(t, http.StatusForbidden, serve(core.Scopes{"timeline.read:tl1"}, "tl2"))
+	assert.Equal(t, http.StatusForbidden, serve(core.Scopes{"message.read"}, ""))
+}
+
+func TestDomainPassport(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	csid, err := core.PrivKeyToAddr(RemoteDomainPriv, "ccs")
+	assert.NoError(t, err)
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().ListBadges(gomock.Any(), gomock.Any()).Return([]core.Badge{}, nil).AnyTimes()
+	mockEntity.EXPECT().Get(gomock.Any(), User1ID).Return(core.Entity{
+		ID:     User1ID,
+		Domain: RemoteDomainFQDN,
+	}, nil).AnyTimes()
+	mockEntity.EXPECT().Get(gomock.Any(), SubKey1ID).Return(core.Entity{
+		ID:     SubKey1ID,
+		Domain: "other.example.com",
+	}, nil).AnyTimes()
+
+	mockDomain := mock_core.NewMockDomainService(ctrl)
+	mockDomain.EXPECT().GetByFQDN(gomock.Any(), RemoteDomainFQDN).Return(core.Domain{
+		ID:   RemoteDomainFQDN,
+		CCID: RemoteDomainCCID,
+		CSID: csid,
+	}, nil).AnyTimes()
+
+	mockKey := mock_core.NewMockKeyService(ctrl)
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+
+	config := core.Config{
+		FQDN: "local.example.com",
+	}
+
+	service := NewService(nil, nil, config, mockEntity, mockDomain, mockKey, mockPolicy)
+
+	identify := func(audience, requester string, signedAt time.Time) context.Context {
+		docJson, err := json.Marshal(core.DomainPassportDocument{
+			DocumentBase: core.DocumentBase[any]{
+				Signer:   csid,
+				Type:     "domainpassport",
+				SignedAt: signedAt,
+			},
+			Domain:    RemoteDomainFQDN,
+			Audience:  audience,
+			Requester: requester,
+		})
+		assert.NoError(t, err)
+		signatureBytes, err := core.SignBytes(docJson, RemoteDomainPriv)
+		assert.NoError(t, err)
+		passportJson, _ := json.Marshal(core.Passport{
+			Document:  string(docJson),
+			Signature: hex.EncodeToString(signatureBytes),
+		})
+
+		c, req, _, _ := testutil.CreateHttpRequest()
+		req.Header.Set(core.DomainPassportHeader, base64.URLEncoding.EncodeToString(passportJson))
+
+		err = service.IdentifyIdentity(func(c echo.Context) error { return nil })(c)
+		assert.NoError(t, err)
+		return c.Request().Context()
+	}
+
+	ctx := identify("local.example.com", User1ID, time.Now())
+	assert.Equal(t, RemoteDomainFQDN, ctx.Value(core.RequesterDomainCtxKey))
+	assert.Equal(t, User1ID, ctx.Value(core.RequesterVouchedIdCtxKey))
+	// the vouched entity does not authenticate the request
+	assert.Nil(t, ctx.Value(core.RequesterIdCtxKey))
+
+	// the domain may only vouch for its own entities
+	ctx = identify("local.example.com", SubKey1ID, time.Now())
+	assert.Equal(t, RemoteDomainFQDN, ctx.Value(core.RequesterDomainCtxKey))
+	assert.Nil(t, ctx.Value(core.RequesterVouchedIdCtxKey))
+
+	ctx = identify("other.example.com", User1ID, time.Now())
+	assert.Nil(t, ctx.Value(core.RequesterDomainCtxKey))
+	assert.Nil(t, ctx.Value(core.RequesterVouchedIdCtxKey))
+
+	ctx = identify("local.example.com", User1ID, time.Now().Add(-time.Hour))
+	assert.Nil(t, ctx.Value(core.RequesterDomainCtxKey))
+	assert.Nil(t, ctx.Value(core.RequesterVouchedIdCtxKey))
+}
+
+func TestDelegatedPassport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	remoteCSID, err := core.PrivKeyToAddr(RemoteDomainPriv, "ccs")
+	assert.NoError(t, err)
+	servicePriv, err := core.GeneratePrivateKey()
+	assert.NoError(t, err)
+	serviceCSID, err := core.PrivKeyToAddr(servicePriv, "ccs")
+	assert.NoError(t, err)
+
+	remote := NewService(nil, nil, core.Config{FQDN: RemoteDomainFQDN, CSID: remoteCSID, PrivateKey: RemoteDomainPriv}, nil, nil, nil, nil)
+	delegation, err := remote.IssueDelegation(context.Background(), "media-proxy", serviceCSID, core.Scopes{"timeline.read"}, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().ListBadges(gomock.Any(), gomock.Any()).Return([]core.Badge{}, nil).AnyTimes()
+	mockEntity.EXPECT().Affiliation(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Entity{
+		ID:           User1ID,
+		Domain:       RemoteDomainFQDN,
+		IsScoreFixed: true,
+	}, nil).AnyTimes()
+	mockEntity.EXPECT().Get(gomock.Any(), gomock.Any()).Return(core.Entity{
+		ID:     User1ID,
+		Domain: RemoteDomainFQDN,
+	}, nil).AnyTimes()
+	mockEntity.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(core.EntityMeta{}, nil).AnyTimes()
+
+	mockDomain := mock_core.NewMockDomainService(ctrl)
+	mockDomain.EXPECT().GetByFQDN(gomock.Any(), RemoteDomainFQDN).Return(core.Domain{
+		ID:   RemoteDomainFQDN,
+		CCID: RemoteDomainCCID,
+		CSID: remoteCSID,
+	}, nil).AnyTimes()
+	mockDomain.EXPECT().ForceFetch(gomock.Any(), RemoteDomainFQDN).Return(core.Domain{
+		ID:   RemoteDomainFQDN,
+		CCID: RemoteDomainCCID,
+		CSID: remoteCSID,
+	}, nil).AnyTimes()
+
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockPolicy.EXPECT().TestWithGlobalPolicy(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.PolicyEvalResultAllow, nil).AnyTimes()
+
+	service := NewService(nil, nil, core.Config{FQDN: "local.example.com"}, mockEntity, mockDomain, mock_core.NewMockKeyService(ctrl), mockPolicy)
+
+	identify := func(passport string) context.Context {
+		c, req, _, _ := testutil.CreateHttpRequest()
+		req.Header.Set("Authorization", "Bearer "+createJwt(t, User1Priv, jwt.Claims{
+			Issuer:   User1ID,
+			Subject:  "concrnt",
+			Audience: "local.example.com",
+		}))
+		req.Header.Set("passport", passport)
+
+		err := service.IdentifyIdentity(func(c echo.Context) error { return nil })(c)
+		assert.NoError(t, err)
+		return c.Request().Context()
+	}
+
+	signed := func(delegation, priv string, scopes []string) string {
+		passport, err := SignDelegatedPassport(delegation, priv, core.PassportDocument{
+			Domain: RemoteDomainFQDN,
+			Entity: core.Entity{ID: User1ID, Domain: RemoteDomainFQDN},
+			Keys:   []core.Key{},
+			Scopes: scopes,
+		})
+		assert.NoError(t, err)
+		return passport
+	}
+
+	// the passport is limited to the delegated scopes
+	ctx := identify(signed(delegation, servicePriv, nil))
+	assert.Equal(t, core.Scopes{"timeline.read"}, ctx.Value(core.RequesterPassportScopesKey))
+
+	// scopes beyond the delegation are not accepted
+	ctx = identify(signed(delegation, servicePriv, []string{"message.delete"}))
+	assert.Nil(t, ctx.Value(core.RequesterKeychainKey))
+
+	// the delegation is bound to the key of the service
+	otherPriv, err := core.GeneratePrivateKey()
+	assert.NoError(t, err)
+	ctx = identify(signed(delegation, otherPriv, nil))
+	assert.Nil(t, ctx.Value(core.RequesterKeychainKey))
+
+	// without a delegation, a key which is not the domain's is not accepted
+	otherCSID, err := core.PrivKeyToAddr(otherPriv, "ccs")
+	assert.NoError(t, err)
+	docJson, _ := json.Marshal(core.PassportDocument{
+		DocumentBase: core.DocumentBase[any]{Signer: otherCSID, Type: "passport"},
+		Domain:       RemoteDomainFQDN,
+		Entity:       core.Entity{ID: User1ID, Domain: RemoteDomainFQDN},
+		Keys:         []core.Key{},
+	})
+	signature, _ := core.SignBytes(docJson, otherPriv)
+	passportJson, _ := json.Marshal(core.Passport{Document: string(docJson), Signature: hex.EncodeToString(signature)})
+	ctx = identify(base64.URLEncoding.EncodeToString(passportJson))
+	assert.Nil(t, ctx.Value(core.RequesterKeychainKey))
+}
+
+func TestRequestSignature(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().ListBadges(gomock.Any(), gomock.Any()).Return([]core.Badge{}, nil).AnyTimes()
+	mockEntity.EXPECT().Get(gomock.Any(), User1ID).Return(core.Entity{
+		ID:     User1ID,
+		Domain: "local.example.com",
+	}, nil).AnyTimes()
+	mockEntity.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(core.EntityMeta{}, nil).AnyTimes()
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockPolicy.EXPECT().TestWithGlobalPolicy(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.PolicyEvalResultAllow, nil).AnyTimes()
+
+	service := NewService(nil, nil, core.Config{FQDN: "local.example.com"}, mockEntity, mock_core.NewMockDomainService(ctrl), mock_core.NewMockKeyService(ctrl), mockPolicy)
+
+	identify := func(tamper func(req *http.Request)) (context.Context, string) {
+		body := `{"document":"{}"}`
+		req := httptest.NewRequest(http.MethodPost, "https://local.example.com/api/v1/commit?dry=true", strings.NewReader(body))
+		assert.NoError(t, SignRequest(req, User1ID, User1Priv))
+		tamper(req)
+
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		var received string
+		h := service.IdentifyIdentity(func(c echo.Context) error {
+			data, err := io.ReadAll(c.Request().Body)
+			received = string(data)
+			return err
+		})
+		assert.NoError(t, h(c))
+		return c.Request().Context(), received
+	}
+
+	ctx, body := identify(func(req *http.Request) {})
+	assert.Equal(t, core.LocalUser, ctx.Value(core.RequesterTypeCtxKey))
+	assert.Equal(t, User1ID, ctx.Value(core.RequesterIdCtxKey))
+	assert.Equal(t, `{"document":"{}"}`, body)
+
+	// the signature covers the body and the path
+	ctx, body = identify(func(req *http.Request) {
+		req.Body = io.NopCloser(strings.NewReader(`{"document":"{ }"}`))
+	})
+	assert.Nil(t, ctx.Value(core.RequesterIdCtxKey))
+	assert.Equal(t, `{"document":"{ }"}`, body)
+
+	ctx, _ = identify(func(req *http.Request) {
+		req.URL.RawQuery = "dry=false"
+	})
+	assert.Nil(t, ctx.Value(core.RequesterIdCtxKey))
+}
+
+func TestScaleRateLimit(t *testing.T) {
+	config := core.RateLimitConfig{BucketSize: 100, RefillSpan: 1}
+
+	assert.Equal(t, config, scaleRateLimit(config, 0))
+	assert.Equal(t, core.RateLimitConfig{BucketSize: 10, RefillSpan: 10}, scaleRateLimit(config, 0.1))
+	assert.Equal(t, core.RateLimitConfig{BucketSize: 200, RefillSpan: 0.5}, scaleRateLimit(config, 2))
+	assert.Equal(t, 1, scaleRateLimit(config, 0.001).BucketSize)
+}
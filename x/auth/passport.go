@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+// passportCacheTTL bounds how long a verified passport is trusted without re-verification.
+// revoked keys invalidate the cache immediately (see key.repository.Revoke).
+// subkeys are revoked at the home domain of their entity, which doesn't always tell us,
+// so passports carrying them are re-verified sooner
+const (
+	passportCacheTTL       = 10 * time.Minute
+	subkeyPassportCacheTTL = time.Minute
+)
+
+// a domain passport is signed for each request, so it is accepted only shortly after it was signed.
+// domainPassportSkew allows for clocks running ahead of ours
+const (
+	domainPassportMaxAge = 5 * time.Minute
+	domainPassportSkew   = time.Minute
+)
+
+// passportHash identifies a passport by both its document and signature,
+// so a cached signature can not be reused with another document.
+func passportHash(passport core.Passport) string {
+	hash := sha256.Sum256([]byte(passport.Document + "." + passport.Signature))
+	return hex.EncodeToString(hash[:])
+}
+
+// isPassportVerified reports whether the passport has been verified recently
+func (s *service) isPassportVerified(ctx context.Context, passport core.Passport) bool {
+	ctx, span := tracer.Start(ctx, "Auth.Service.isPassportVerified")
+	defer span.End()
+
+	if s.rdb == nil {
+		return false
+	}
+
+	exists, err := s.rdb.Exists(ctx, keyspace.Passport.Key(passportHash(passport))).Result()
+	if err != nil {
+		span.RecordError(err)
+		return false
+	}
+
+	return exists > 0
+}
+
+// markPassportVerified caches the verification result and indexes it by every key in the passport
+// so that a revocation of one of them can drop the entry.
+func (s *service) markPassportVerified(ctx context.Context, passport core.Passport, keys []core.Key) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.markPassportVerified")
+	defer span.End()
+
+	if s.rdb == nil || !keyspace.Passport.Allow(s.rdb) {
+		return
+	}
+
+	hash := passportHash(passport)
+	ttl := passportCacheTTL
+	if len(keys) > 0 {
+		ttl = subkeyPassportCacheTTL
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.Set(ctx, keyspace.Passport.Key(hash), "1", ttl)
+	for _, key := range keys {
+		index := keyspace.Passport.Key("key", key.ID)
+		pipe.SAdd(ctx, index, hash)
+		pipe.Expire(ctx, index, ttl)
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+}
+
+// AudienceHash is how a domain appears in the audience of a passport.
+// the fqdn is hashed so that a passport does not disclose which domains its holder talks to
+func AudienceHash(fqdn string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(fqdn)))
+	return hex.EncodeToString(hash[:])
+}
+
+// passportAccepted reports whether the domain is in the audience of the passport
+func passportAccepted(doc core.PassportDocument, fqdn string) bool {
+	if len(doc.Audience) == 0 {
+		return true
+	}
+	return slices.Contains(doc.Audience, AudienceHash(fqdn))
+}
+
+// withPassportScopes limits the request to the scopes of the passport, if it has any
+func withPassportScopes(ctx context.Context, doc core.PassportDocument) context.Context {
+	if len(doc.Scopes) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, core.RequesterPassportScopesKey, core.Scopes(doc.Scopes))
+}
+
+// verifyDomainPassport checks that the domain passport was signed for this domain, recently,
+// by a known domain which is not blocked
+func (s *service) verifyDomainPassport(ctx context.Context, header string) (core.DomainPassportDocument, core.Domain, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.verifyDomainPassport")
+	defer span.End()
+
+	var passport core.Passport
+	var doc core.DomainPassportDocument
+
+	passportJson, err := base64.URLEncoding.DecodeString(header)
+	if err != nil {
+		return doc, core.Domain{}, err
+	}
+	err = json.Unmarshal(passportJson, &passport)
+	if err != nil {
+		return doc, core.Domain{}, err
+	}
+	err = json.Unmarshal([]byte(passport.Document), &doc)
+	if err != nil {
+		return doc, core.Domain{}, err
+	}
+
+	if doc.Type != "domainpassport" {
+		return doc, core.Domain{}, fmt.Errorf("not a domain passport: %s", doc.Type)
+	}
+	if doc.Audience != s.config.FQDN {
+		return doc, core.Domain{}, fmt.Errorf("domain passport is for %s", doc.Audience)
+	}
+	if doc.Domain == s.config.FQDN {
+		return doc, core.Domain{}, fmt.Errorf("domain passport of this domain")
+	}
+	now := time.Now()
+	if doc.SignedAt.Before(now.Add(-domainPassportMaxAge)) || doc.SignedAt.After(now.Add(domainPassportSkew)) {
+		return doc, core.Domain{}, fmt.Errorf("domain passport is expired")
+	}
+
+	domain, err := s.domain.GetByFQDN(ctx, doc.Domain)
+	if err != nil {
+		return doc, core.Domain{}, err
+	}
+	tags := core.ParseTags(domain.Tag)
+	if tags.Has("_block") {
+		return doc, domain, fmt.Errorf("domain is blocked")
+	}
+
+	// a signer the domain is not known with may be its new CSID after a rotation
+	if !domain.AcceptsCSID(doc.Signer, now) {
+		span.AddEvent("force fetch domain")
+		domain, err = s.domain.ForceFetch(ctx, doc.Domain)
+		if err != nil {
+			return doc, core.Domain{}, err
+		}
+		if !domain.AcceptsCSID(doc.Signer, now) {
+			return doc, domain, fmt.Errorf("domain passport is not signed by its domain")
+		}
+	}
+
+	signature, err := hex.DecodeString(passport.Signature)
+	if err != nil {
+		return doc, domain, err
+	}
+	err = core.VerifySignature([]byte(passport.Document), signature, doc.Signer)
+	if err != nil {
+		return doc, domain, err
+	}
+
+	return doc, domain, nil
+}
+
+// identifyDomain adds the domain of a valid domain passport to ctx, and the entity it vouches for.
+// the entity is taken only if it belongs to that domain. an invalid passport leaves the request anonymous
+func (s *service) identifyDomain(ctx context.Context, header string) context.Context {
+	ctx, span := tracer.Start(ctx, "Auth.Service.identifyDomain")
+	defer span.End()
+
+	doc, domain, err := s.verifyDomainPassport(ctx, header)
+	if err != nil {
+		span.RecordError(err)
+		return ctx
+	}
+
+	if _, ok := ctx.Value(core.RequesterDomainCtxKey).(string); !ok {
+		ctx = context.WithValue(ctx, core.RequesterDomainCtxKey, domain.ID)
+		ctx = context.WithValue(ctx, core.RequesterDomainTagsKey, core.ParseTags(domain.Tag))
+	}
+
+	if doc.Requester == "" {
+		return ctx
+	}
+	entity, err := s.entity.Get(ctx, doc.Requester)
+	if err != nil {
+		span.RecordError(err)
+		return ctx
+	}
+	if entity.Domain != doc.Domain {
+		span.RecordError(fmt.Errorf("%s is not an entity of %s", doc.Requester, doc.Domain))
+		return ctx
+	}
+	return context.WithValue(ctx, core.RequesterVouchedIdCtxKey, entity.ID)
+}
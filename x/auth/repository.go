@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for scoped token storage
+type Repository interface {
+	CreateToken(ctx context.Context, token core.AuthToken) (core.AuthToken, error)
+	GetToken(ctx context.Context, id string) (core.AuthToken, error)
+	ListTokens(ctx context.Context, owner string) ([]core.AuthToken, error)
+	ListTokensByKey(ctx context.Context, keyID string) ([]core.AuthToken, error)
+	RevokeToken(ctx context.Context, owner, id string) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new auth repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+func (r *repository) CreateToken(ctx context.Context, token core.AuthToken) (core.AuthToken, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Repository.CreateToken")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Create(&token).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return token, err
+}
+
+func (r *repository) GetToken(ctx context.Context, id string) (core.AuthToken, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Repository.GetToken")
+	defer span.End()
+
+	var token core.AuthToken
+	err := r.db.WithContext(ctx).First(&token, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.AuthToken{}, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return core.AuthToken{}, err
+	}
+	return token, nil
+}
+
+func (r *repository) ListTokens(ctx context.Context, owner string) ([]core.AuthToken, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Repository.ListTokens")
+	defer span.End()
+
+	var tokens []core.AuthToken
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Order("c_date DESC").Find(&tokens).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return tokens, err
+}
+
+// ListTokensByKey returns every token bound to the subkey, including expired and revoked ones
+func (r *repository) ListTokensByKey(ctx context.Context, keyID string) ([]core.AuthToken, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Repository.ListTokensByKey")
+	defer span.End()
+
+	var tokens []core.AuthToken
+	err := r.db.WithContext(ctx).Where("key_id = ?", keyID).Find(&tokens).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return tokens, err
+}
+
+func (r *repository) RevokeToken(ctx context.Context, owner, id string) error {
+	ctx, span := tracer.Start(ctx, "Auth.Repository.RevokeToken")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).
+		Model(&core.AuthToken{}).
+		Where("id = ? AND owner = ? AND revoked_at IS NULL", id, owner).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
@@ -14,31 +14,45 @@ import (
 )
 
 type service struct {
-	rdb    *redis.Client
-	config core.Config
-	entity core.EntityService
-	domain core.DomainService
-	key    core.KeyService
-	policy core.PolicyService
+	rdb        *redis.Client
+	repository Repository
+	config     core.Config
+	entity     core.EntityService
+	domain     core.DomainService
+	key        core.KeyService
+	policy     core.PolicyService
 }
 
 // NewService creates a new auth service
 func NewService(
 	rdb *redis.Client,
+	repository Repository,
 	config core.Config,
 	entity core.EntityService,
 	domain core.DomainService,
 	key core.KeyService,
 	policy core.PolicyService,
 ) core.AuthService {
-	return &service{rdb, config, entity, domain, key, policy}
+	return &service{rdb, repository, config, entity, domain, key, policy}
 }
 
-// GetPassport takes client signed JWT and returns server signed JWT
-func (s *service) IssuePassport(ctx context.Context, requester string, keys []core.Key) (string, error) {
+// GetPassport takes client signed JWT and returns server signed JWT.
+// the passport is accepted only by the audience domains and allows only the scopes, if they are given
+func (s *service) IssuePassport(ctx context.Context, requester string, keys []core.Key, audience []string, scopes core.Scopes) (string, error) {
 	ctx, span := tracer.Start(ctx, "Auth.Service.IssuePassport")
 	defer span.End()
 
+	for _, scope := range scopes {
+		if err := core.ValidateScope(scope); err != nil {
+			return "", core.NewErrorInvalidArgument(err.Error())
+		}
+	}
+
+	hashedAudience := make([]string, len(audience))
+	for i, fqdn := range audience {
+		hashedAudience[i] = AudienceHash(fqdn)
+	}
+
 	entity, err := s.entity.Get(ctx, requester)
 	if err != nil {
 		span.RecordError(err)
@@ -49,10 +63,18 @@ func (s *service) IssuePassport(ctx context.Context, requester string, keys []co
 		return "", fmt.Errorf("You are not a local entity")
 	}
 
+	entity.Badges, err = s.entity.ListBadges(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
 	documentObj := core.PassportDocument{
-		Domain: s.config.FQDN,
-		Entity: entity,
-		Keys:   keys,
+		Domain:   s.config.FQDN,
+		Entity:   entity,
+		Keys:     keys,
+		Audience: hashedAudience,
+		Scopes:   scopes,
 		DocumentBase: core.DocumentBase[any]{
 			Signer:   s.config.CSID,
 			Type:     "passport",
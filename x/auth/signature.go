@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+const (
+	// requestSignatureMaxSkew is how far signedAt of a signed request may be from the clock of the domain
+	requestSignatureMaxSkew = 5 * time.Minute
+	// requestSignatureMaxBody bounds the body read to verify a signature
+	requestSignatureMaxBody = core.DefaultMaxRequestBytes
+)
+
+// RequestSignaturePayload is what is signed to authenticate a request: the method, the domain the request is for,
+// the path with the query, signedAt in unix milliseconds and the body, separated by newlines
+func RequestSignaturePayload(method, fqdn, uri string, signedAt int64, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(method + "\n" + fqdn + "\n" + uri + "\n" + strconv.FormatInt(signedAt, 10) + "\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// SignRequest signs the request with the key of signer, a CCID or a CKID, and sets the signature header.
+// the body of the request is read and replaced so that it can still be sent
+func SignRequest(req *http.Request, signer, privateKey string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	signedAt := time.Now().UnixMilli()
+	signature, err := core.SignBytes(RequestSignaturePayload(req.Method, req.URL.Host, req.URL.RequestURI(), signedAt, body), privateKey)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(core.RequestSignatureHeader, fmt.Sprintf("signer=%s,signedAt=%d,signature=%s", signer, signedAt, hex.EncodeToString(signature)))
+	return nil
+}
+
+// verifyRequestSignature checks the signature header of the request and returns the key which signed it.
+// the body is read and put back for the handlers. a signed request is accepted only once
+func (s *service) verifyRequestSignature(ctx context.Context, req *http.Request, header string) (string, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.verifyRequestSignature")
+	defer span.End()
+
+	signer, payload, err := s.checkRequestSignature(req, header)
+	if err != nil {
+		return "", err
+	}
+
+	if s.rdb != nil {
+		ok, err := s.rdb.SetNX(ctx, replayKey(signer, payload), "1", 2*requestSignatureMaxSkew).Result()
+		if err != nil {
+			span.RecordError(err)
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("signature is already used")
+		}
+	}
+
+	return signer, nil
+}
+
+// replayKey identifies a signed request for the replay check. it is derived from what was signed rather than from
+// the signature, since an ecdsa signature can be malleated into another one which is valid for the same payload
+func replayKey(signer string, payload []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(signer + "\n"))
+	hash.Write(payload)
+	return keyspace.JTI.Key("signature", hex.EncodeToString(hash.Sum(nil)))
+}
+
+// checkRequestSignature verifies the signature header against the request, and returns the signer and the signed payload
+func (s *service) checkRequestSignature(req *http.Request, header string) (string, []byte, error) {
+	var signer, signatureHex, signedAtStr string
+	for _, param := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "signer":
+			signer = value
+		case "signedAt":
+			signedAtStr = value
+		case "signature":
+			signatureHex = value
+		}
+	}
+	if signer == "" || signedAtStr == "" || signatureHex == "" {
+		return "", nil, fmt.Errorf("signer, signedAt and signature are required")
+	}
+	if !core.IsCCID(signer) && !core.IsCKID(signer) {
+		return "", nil, fmt.Errorf("signer must be a CCID or a CKID")
+	}
+
+	signedAt, err := strconv.ParseInt(signedAtStr, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid signedAt")
+	}
+	skew := time.Since(time.UnixMilli(signedAt))
+	if skew > requestSignatureMaxSkew || skew < -requestSignatureMaxSkew {
+		return "", nil, fmt.Errorf("signedAt is too far from now")
+	}
+
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid signature")
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(req.Body, requestSignatureMaxBody+1))
+		// the handler reads the body whatever comes of the verification
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		if err != nil {
+			return "", nil, err
+		}
+		if len(body) > requestSignatureMaxBody {
+			return "", nil, fmt.Errorf("body is too large to verify")
+		}
+	}
+
+	payload := RequestSignaturePayload(req.Method, s.config.FQDN, req.URL.RequestURI(), signedAt, body)
+	err = core.VerifySignature(payload, signature, signer)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return signer, payload, nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestReplayMalleatedSignature(t *testing.T) {
+	s := &service{config: core.Config{FQDN: "local.example.com"}}
+	body := `{"document":"{}"}`
+
+	req := httptest.NewRequest(http.MethodPost, "https://local.example.com/api/v1/commit", strings.NewReader(body))
+	assert.NoError(t, SignRequest(req, User1ID, User1Priv))
+	header := req.Header.Get(core.RequestSignatureHeader)
+
+	signer, payload, err := s.checkRequestSignature(req, header)
+	assert.NoError(t, err)
+	assert.Equal(t, User1ID, signer)
+
+	// the same request signed with (r, n-s) and the other recovery id is another valid signature
+	params := strings.Split(header, ",")
+	signature, err := hex.DecodeString(strings.TrimPrefix(params[2], "signature="))
+	assert.NoError(t, err)
+	sValue := new(big.Int).SetBytes(signature[32:64])
+	malleated := append([]byte{}, signature[:32]...)
+	malleated = append(malleated, new(big.Int).Sub(crypto.S256().Params().N, sValue).FillBytes(make([]byte, 32))...)
+	malleated = append(malleated, signature[64]^1)
+	malleatedHeader := fmt.Sprintf("%s,%s,signature=%s", params[0], params[1], hex.EncodeToString(malleated))
+
+	replay := httptest.NewRequest(http.MethodPost, "https://local.example.com/api/v1/commit", strings.NewReader(body))
+	replayedSigner, replayedPayload, err := s.checkRequestSignature(replay, malleatedHeader)
+	assert.NoError(t, err)
+	assert.Equal(t, signer, replayedSigner)
+
+	// so the replay is told from the signed request, not from its signature
+	assert.Equal(t, replayKey(signer, payload), replayKey(replayedSigner, replayedPayload))
+
+	other := httptest.NewRequest(http.MethodPost, "https://local.example.com/api/v1/commit", strings.NewReader(`{"document":"[]"}`))
+	assert.NoError(t, SignRequest(other, User1ID, User1Priv))
+	_, otherPayload, err := s.checkRequestSignature(other, other.Header.Get(core.RequestSignatureHeader))
+	assert.NoError(t, err)
+	assert.NotEqual(t, replayKey(signer, payload), replayKey(signer, otherPayload))
+}
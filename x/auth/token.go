@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/key"
+)
+
+// scopedTokenPrefix distinguishes scoped tokens from jwts in the authorization header.
+// a token is "cct.<id>.<secret>"; only the sha256 of the secret is stored.
+const scopedTokenPrefix = "cct."
+
+// accountActions can never be performed with a scoped token, since they would let it escape its scopes
+var accountActions = map[string]bool{
+	"affiliation": true,
+	"tombstone":   true,
+	"enact":       true,
+	"revoke":      true,
+}
+
+func hashTokenSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func tokenActive(token core.AuthToken, now time.Time) bool {
+	return token.RevokedAt == nil && (token.ExpiresAt == nil || now.Before(*token.ExpiresAt))
+}
+
+// IssueToken mints a scoped token for a local user. the returned secret is not recoverable later.
+func (s *service) IssueToken(ctx context.Context, token core.AuthToken) (core.AuthToken, string, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.IssueToken")
+	defer span.End()
+
+	if len(token.Scopes) == 0 {
+		return core.AuthToken{}, "", fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range token.Scopes {
+		if err := core.ValidateScope(scope); err != nil {
+			return core.AuthToken{}, "", err
+		}
+	}
+
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return core.AuthToken{}, "", fmt.Errorf("expiresAt is in the past")
+	}
+
+	if token.KeyID != "" {
+		owner, err := s.key.ResolveSubkey(ctx, token.KeyID)
+		if err != nil {
+			span.RecordError(err)
+			return core.AuthToken{}, "", errors.Wrap(err, "failed to resolve subkey")
+		}
+		if owner != token.Owner {
+			return core.AuthToken{}, "", core.NewErrorPermissionDenied()
+		}
+	}
+
+	idBytes := make([]byte, 16)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return core.AuthToken{}, "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return core.AuthToken{}, "", err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	token.ID = hex.EncodeToString(idBytes)
+	token.SecretHash = hashTokenSecret(secret)
+	token.RevokedAt = nil
+
+	created, err := s.repository.CreateToken(ctx, token)
+	if err != nil {
+		span.RecordError(err)
+		return core.AuthToken{}, "", err
+	}
+
+	return created, scopedTokenPrefix + created.ID + "." + secret, nil
+}
+
+// ListTokens returns the tokens minted by owner
+func (s *service) ListTokens(ctx context.Context, owner string) ([]core.AuthToken, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.ListTokens")
+	defer span.End()
+
+	return s.repository.ListTokens(ctx, owner)
+}
+
+// RevokeToken revokes the token. a bound subkey stays limited to the scopes of its other active tokens.
+func (s *service) RevokeToken(ctx context.Context, owner, id string) error {
+	ctx, span := tracer.Start(ctx, "Auth.Service.RevokeToken")
+	defer span.End()
+
+	return s.repository.RevokeToken(ctx, owner, id)
+}
+
+// resolveToken authenticates a scoped token and returns its owner and scopes
+func (s *service) resolveToken(ctx context.Context, raw string) (string, core.Scopes, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.resolveToken")
+	defer span.End()
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, scopedTokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return "", nil, fmt.Errorf("malformed token")
+	}
+
+	token, err := s.repository.GetToken(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.SecretHash), []byte(hashTokenSecret(secret))) != 1 {
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	if !tokenActive(token, time.Now()) {
+		return "", nil, fmt.Errorf("token is expired or revoked")
+	}
+
+	return token.Owner, core.Scopes(token.Scopes), nil
+}
+
+// keyScopes returns the scopes a subkey is limited to. ok is false when the key is not bound to any token.
+func (s *service) keyScopes(ctx context.Context, keyID string) (core.Scopes, bool, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Service.keyScopes")
+	defer span.End()
+
+	if s.repository == nil {
+		return nil, false, nil
+	}
+
+	tokens, err := s.repository.ListTokensByKey(ctx, keyID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, false, err
+	}
+	if len(tokens) == 0 {
+		return nil, false, nil
+	}
+
+	scopes := core.Scopes{}
+	now := time.Now()
+	for _, token := range tokens {
+		if tokenActive(token, now) {
+			scopes = append(scopes, token.Scopes...)
+		}
+	}
+	return scopes, true, nil
+}
+
+// commitAction maps a document to the action and the targets it is checked against
+func commitAction(document string) (string, []string, error) {
+	var doc struct {
+		Type         string   `json:"type"`
+		Timelines    []string `json:"timelines"`
+		Timeline     string   `json:"timeline"`
+		Target       string   `json:"target"`
+		Subscription string   `json:"subscription"`
+	}
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to unmarshal document")
+	}
+
+	switch doc.Type {
+	case "message", "association":
+		return doc.Type + ".create", doc.Timelines, nil
+	case "profile", "timeline", "subscription":
+		return doc.Type + ".upsert", nil, nil
+	case "delete":
+		kind := "document"
+		if len(doc.Target) > 0 {
+			switch doc.Target[0] {
+			case 'm':
+				kind = "message"
+			case 'a':
+				kind = "association"
+			case 'p':
+				kind = "profile"
+			case 't':
+				kind = "timeline"
+			case 's':
+				kind = "subscription"
+			}
+		}
+		return kind + ".delete", []string{doc.Target}, nil
+	case "retract", "event":
+		return "timeline." + doc.Type, []string{doc.Timeline}, nil
+	case "subscribe", "unsubscribe":
+		return "subscription." + doc.Type, []string{doc.Subscription}, nil
+	case "ack":
+		return "ack.create", nil, nil
+	case "unack":
+		return "ack.delete", nil, nil
+	default:
+		return doc.Type, nil, nil
+	}
+}
+
+// AuthorizeCommit checks the document against the scopes of the requesting token and of the signing subkey
+func (s *service) AuthorizeCommit(ctx context.Context, document string) error {
+	ctx, span := tracer.Start(ctx, "Auth.Service.AuthorizeCommit")
+	defer span.End()
+
+	var limits []core.Scopes
+	if scopes, ok := ctx.Value(core.RequesterScopesKey).(core.Scopes); ok {
+		limits = append(limits, scopes)
+	}
+	if scopes, ok := ctx.Value(core.RequesterPassportScopesKey).(core.Scopes); ok {
+		limits = append(limits, scopes)
+	}
+
+	var base core.DocumentBase[any]
+	err := json.Unmarshal([]byte(document), &base)
+	if err != nil {
+		return errors.Wrap(err, "failed to unmarshal document")
+	}
+
+	if base.KeyID != "" {
+		// the scopes signed into the keychain are checked on every domain, so that a subkey can't escape them
+		// by committing to a domain other than its own. the tokens bound to it are only known by its own domain
+		keychain, err := s.key.GetKeyResolution(ctx, base.KeyID)
+		if err != nil {
+			span.RecordError(err)
+			return errors.Wrap(err, "failed to get key resolution")
+		}
+		signed, err := key.SignedScopes(keychain)
+		if err != nil {
+			return errors.Wrap(err, "failed to get scopes of subkey")
+		}
+		limits = append(limits, signed...)
+
+		scopes, bound, err := s.keyScopes(ctx, base.KeyID)
+		if err != nil {
+			return err
+		}
+		if bound {
+			limits = append(limits, scopes)
+		}
+	}
+
+	if len(limits) == 0 {
+		return nil
+	}
+
+	action, targets, err := commitAction(document)
+	if err != nil {
+		return err
+	}
+
+	if accountActions[action] {
+		return core.NewErrorPermissionDenied()
+	}
+
+	for _, scopes := range limits {
+		if !scopes.Allows(action, targets...) {
+			return core.NewErrorPermissionDenied()
+		}
+	}
+
+	return nil
+}
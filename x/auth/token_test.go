@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+func TestCommitAction(t *testing.T) {
+	action, targets, err := commitAction(`{"type":"message","timelines":["tl1@example.com","tl2@example.com"]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "message.create", action)
+	assert.Equal(t, []string{"tl1@example.com", "tl2@example.com"}, targets)
+
+	action, targets, err = commitAction(`{"type":"delete","target":"mxxxxxxxxxxxxxxxxxxxxxxxxx"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "message.delete", action)
+	assert.Equal(t, []string{"mxxxxxxxxxxxxxxxxxxxxxxxxx"}, targets)
+
+	action, _, err = commitAction(`{"type":"enact"}`)
+	assert.NoError(t, err)
+	assert.True(t, accountActions[action])
+}
+
+func TestAuthorizeCommitWithScopes(t *testing.T) {
+	s := &service{}
+	ctx := context.WithValue(context.Background(), core.RequesterScopesKey, core.Scopes{"message.create:tl1@example.com"})
+
+	err := s.AuthorizeCommit(ctx, `{"type":"message","timelines":["tl1@example.com"]}`)
+	assert.NoError(t, err)
+
+	err = s.AuthorizeCommit(ctx, `{"type":"message","timelines":["tl1@example.com","tl2@example.com"]}`)
+	assert.ErrorIs(t, err, core.ErrorPermissionDenied{})
+
+	err = s.AuthorizeCommit(ctx, `{"type":"profile"}`)
+	assert.ErrorIs(t, err, core.ErrorPermissionDenied{})
+
+	all := context.WithValue(context.Background(), core.RequesterScopesKey, core.Scopes{"*"})
+	err = s.AuthorizeCommit(all, `{"type":"enact"}`)
+	assert.ErrorIs(t, err, core.ErrorPermissionDenied{})
+
+	// requests without scopes are not restricted
+	err = s.AuthorizeCommit(context.Background(), `{"type":"profile"}`)
+	assert.NoError(t, err)
+}
+
+func TestAuthorizeCommitWithSignedScopes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the subkey is checked against the scopes it was enacted with on any domain, even without a token bound to it
+	mockKey := mock_core.NewMockKeyService(ctrl)
+	mockKey.EXPECT().GetKeyResolution(gomock.Any(), "cck1ydda2qj3nr32hulm65vj2g746f06hy36wzh9ke").Return([]core.Key{{
+		ID:            "cck1ydda2qj3nr32hulm65vj2g746f06hy36wzh9ke",
+		EnactDocument: `{"type":"enact","target":"cck1ydda2qj3nr32hulm65vj2g746f06hy36wzh9ke","scopes":["message.create:tl1@example.com"]}`,
+	}}, nil).AnyTimes()
+
+	s := &service{key: mockKey}
+	ctx := context.Background()
+
+	err := s.AuthorizeCommit(ctx, `{"type":"message","keyID":"cck1ydda2qj3nr32hulm65vj2g746f06hy36wzh9ke","timelines":["tl1@example.com"]}`)
+	assert.NoError(t, err)
+
+	err = s.AuthorizeCommit(ctx, `{"type":"message","keyID":"cck1ydda2qj3nr32hulm65vj2g746f06hy36wzh9ke","timelines":["tl2@example.com"]}`)
+	assert.ErrorIs(t, err, core.ErrorPermissionDenied{})
+
+	err = s.AuthorizeCommit(ctx, `{"type":"enact","keyID":"cck1ydda2qj3nr32hulm65vj2g746f06hy36wzh9ke"}`)
+	assert.ErrorIs(t, err, core.ErrorPermissionDenied{})
+}
+
+func TestScopeGuard(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	AllowScoped(e.POST("/scoped", ok, ScopeGuard))
+	e.POST("/unscoped", ok, ScopeGuard)
+
+	request := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), core.RequesterScopesKey, core.Scopes{"*"}))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("/scoped"))
+	assert.Equal(t, http.StatusForbidden, request("/unscoped"))
+}
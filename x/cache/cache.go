@@ -0,0 +1,112 @@
+// Package cache abstracts the shared cache of the resources, chunks and counters.
+// the interface follows the memcache client, so memcached is used as is,
+// and redis or an in-process cache serve deployments without memcached
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is a memcached compatible key value cache.
+// items expire after Expiration seconds, or at the unix time when it is over 30 days
+type Cache interface {
+	Get(key string) (*memcache.Item, error)
+	GetMulti(keys []string) (map[string]*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Add(item *memcache.Item) error
+	Replace(item *memcache.Item) error
+	Prepend(item *memcache.Item) error
+	Delete(key string) error
+	Increment(key string, delta uint64) (uint64, error)
+	Decrement(key string, delta uint64) (uint64, error)
+	FlushAll() error
+	Close() error
+}
+
+var _ Cache = (*memcache.Client)(nil)
+
+const (
+	BackendMemcached = "memcached"
+	BackendRedis     = "redis"
+	BackendMemory    = "memory"
+
+	defaultMemorySize = 256 << 20
+)
+
+// Config selects the backend of the cache
+type Config struct {
+	Backend string `yaml:"backend"` // memcached (default), redis or memory
+	// memcached addresses replicated to, e.g. in other regions. reads are served by the primary address
+	Replicas []string `yaml:"replicas"`
+	// bytes the in-process cache holds. defaults to 256MiB
+	MemorySize int64 `yaml:"memorySize"`
+}
+
+// New creates the cache of the backend. memcachedAddr is the primary memcached,
+// rdb is used by the redis backend
+func New(config Config, memcachedAddr string, rdb *redis.Client) (Cache, error) {
+	switch config.Backend {
+	case "", BackendMemcached:
+		primary := memcache.New(memcachedAddr)
+		if len(config.Replicas) == 0 {
+			return primary, nil
+		}
+		replicas := make([]Cache, len(config.Replicas))
+		for i, addr := range config.Replicas {
+			replicas[i] = memcache.New(addr)
+		}
+		return NewReplicated(primary, replicas...), nil
+	case BackendRedis:
+		if rdb == nil {
+			return nil, fmt.Errorf("redis cache requires redis")
+		}
+		return NewRedis(rdb), nil
+	case BackendMemory:
+		size := config.MemorySize
+		if size <= 0 {
+			size = defaultMemorySize
+		}
+		return NewMemory(size)
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", config.Backend)
+	}
+}
+
+// relative expirations of memcached are at most 30 days, larger values are unix times
+const maxRelativeExpiration = 30 * 24 * 60 * 60
+
+// ttl converts the expiration of an item to a duration. 0 means the item does not expire.
+// an expiration already passed is returned as a negative duration
+func ttl(expiration int32, now time.Time) time.Duration {
+	switch {
+	case expiration == 0:
+		return 0
+	case expiration < 0:
+		return -1
+	case expiration > maxRelativeExpiration:
+		d := time.Unix(int64(expiration), 0).Sub(now)
+		if d == 0 {
+			return -1
+		}
+		return d
+	default:
+		return time.Duration(expiration) * time.Second
+	}
+}
+
+// Ping checks that the cache answers. a missing key is an answer as well
+func Ping(c Cache) error {
+	if pinger, ok := c.(interface{ Ping() error }); ok {
+		return pinger.Ping()
+	}
+	_, err := c.Get("health")
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
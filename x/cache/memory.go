@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/dgraph-io/ristretto"
+)
+
+// memory is an in-process cache. it is not shared between processes,
+// so it suits single instance deployments without memcached
+type memory struct {
+	// serializes the writes, so that read-modify-write operations are atomic
+	mu    sync.Mutex
+	cache *ristretto.Cache
+}
+
+type memoryEntry struct {
+	value []byte
+	flags uint32
+}
+
+// NewMemory creates an in-process cache holding about size bytes
+func NewMemory(size int64) (Cache, error) {
+	// ristretto recommends counters of 10 times the number of items, assuming items of 1KiB
+	counters := max(size/1024*10, 10000)
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: counters,
+		MaxCost:     size,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &memory{cache: cache}, nil
+}
+
+func (m *memory) get(key string) (memoryEntry, bool) {
+	value, ok := m.cache.Get(key)
+	if !ok {
+		return memoryEntry{}, false
+	}
+	return value.(memoryEntry), true
+}
+
+// set stores the entry. ttl 0 keeps it until evicted
+func (m *memory) set(key string, entry memoryEntry, ttl time.Duration) {
+	if ttl < 0 {
+		m.cache.Del(key)
+		return
+	}
+	m.cache.SetWithTTL(key, entry, int64(len(key)+len(entry.value)), ttl)
+	// sets are buffered, wait for it to be visible to the following reads
+	m.cache.Wait()
+}
+
+// remaining returns the ttl left on the key, to keep it on updates
+func (m *memory) remaining(key string) time.Duration {
+	d, _ := m.cache.GetTTL(key)
+	return d
+}
+
+func (m *memory) Get(key string) (*memcache.Item, error) {
+	entry, ok := m.get(key)
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return &memcache.Item{Key: key, Value: append([]byte(nil), entry.value...), Flags: entry.flags}, nil
+}
+
+func (m *memory) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	items := make(map[string]*memcache.Item, len(keys))
+	for _, key := range keys {
+		if item, err := m.Get(key); err == nil {
+			items[key] = item
+		}
+	}
+	return items, nil
+}
+
+func (m *memory) Set(item *memcache.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(item.Key, memoryEntry{append([]byte(nil), item.Value...), item.Flags}, ttl(item.Expiration, time.Now()))
+	return nil
+}
+
+func (m *memory) Add(item *memcache.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(item.Key); ok {
+		return memcache.ErrNotStored
+	}
+	m.set(item.Key, memoryEntry{append([]byte(nil), item.Value...), item.Flags}, ttl(item.Expiration, time.Now()))
+	return nil
+}
+
+func (m *memory) Replace(item *memcache.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(item.Key); !ok {
+		return memcache.ErrNotStored
+	}
+	m.set(item.Key, memoryEntry{append([]byte(nil), item.Value...), item.Flags}, ttl(item.Expiration, time.Now()))
+	return nil
+}
+
+// Prepend puts the value before the stored one. like memcached, the expiration and flags of the item are ignored
+func (m *memory) Prepend(item *memcache.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.get(item.Key)
+	if !ok {
+		return memcache.ErrNotStored
+	}
+	value := make([]byte, 0, len(item.Value)+len(entry.value))
+	value = append(append(value, item.Value...), entry.value...)
+	m.set(item.Key, memoryEntry{value, entry.flags}, m.remaining(item.Key))
+	return nil
+}
+
+func (m *memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(key); !ok {
+		return memcache.ErrCacheMiss
+	}
+	m.cache.Del(key)
+	return nil
+}
+
+func (m *memory) Increment(key string, delta uint64) (uint64, error) {
+	return m.add(key, func(value uint64) uint64 { return value + delta })
+}
+
+// Decrement subtracts delta from the value. like memcached, the value does not go below 0
+func (m *memory) Decrement(key string, delta uint64) (uint64, error) {
+	return m.add(key, func(value uint64) uint64 {
+		if delta > value {
+			return 0
+		}
+		return value - delta
+	})
+}
+
+func (m *memory) add(key string, fn func(uint64) uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.get(key)
+	if !ok {
+		return 0, memcache.ErrCacheMiss
+	}
+	value, err := strconv.ParseUint(string(entry.value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	value = fn(value)
+	m.set(key, memoryEntry{[]byte(strconv.FormatUint(value, 10)), entry.flags}, m.remaining(key))
+	return value, nil
+}
+
+func (m *memory) FlushAll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cache.Clear()
+	return nil
+}
+
+func (m *memory) Close() error {
+	m.cache.Close()
+	return nil
+}
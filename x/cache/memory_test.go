@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMemory(t *testing.T) Cache {
+	c, err := NewMemory(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestMemory(t *testing.T) {
+	c := newTestMemory(t)
+
+	_, err := c.Get("key")
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+
+	assert.ErrorIs(t, c.Replace(&memcache.Item{Key: "key", Value: []byte("a")}), memcache.ErrNotStored)
+	assert.ErrorIs(t, c.Prepend(&memcache.Item{Key: "key", Value: []byte("a")}), memcache.ErrNotStored)
+
+	assert.NoError(t, c.Add(&memcache.Item{Key: "key", Value: []byte("b")}))
+	assert.ErrorIs(t, c.Add(&memcache.Item{Key: "key", Value: []byte("c")}), memcache.ErrNotStored)
+	assert.NoError(t, c.Prepend(&memcache.Item{Key: "key", Value: []byte("a,")}))
+
+	item, err := c.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b", string(item.Value))
+
+	items, err := c.GetMulti([]string{"key", "missing"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	assert.NoError(t, c.Delete("key"))
+	assert.ErrorIs(t, c.Delete("key"), memcache.ErrCacheMiss)
+}
+
+func TestMemoryCounter(t *testing.T) {
+	c := newTestMemory(t)
+
+	_, err := c.Increment("count", 1)
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+
+	assert.NoError(t, c.Set(&memcache.Item{Key: "count", Value: []byte("1")}))
+
+	value, err := c.Increment("count", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), value)
+
+	value, err = c.Decrement("count", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), value)
+}
+
+func TestMemoryExpiration(t *testing.T) {
+	c := newTestMemory(t)
+
+	assert.NoError(t, c.Set(&memcache.Item{Key: "key", Value: []byte("a"), Expiration: 1}))
+	_, err := c.Get("key")
+	assert.NoError(t, err)
+
+	time.Sleep(1500 * time.Millisecond)
+	_, err = c.Get("key")
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+}
+
+func TestReplicated(t *testing.T) {
+	primary := newTestMemory(t)
+	replica := newTestMemory(t)
+	c := NewReplicated(primary, replica)
+
+	assert.NoError(t, c.Set(&memcache.Item{Key: "key", Value: []byte("b")}))
+	assert.NoError(t, c.Prepend(&memcache.Item{Key: "key", Value: []byte("a,")}))
+	assert.NoError(t, c.Set(&memcache.Item{Key: "count", Value: []byte("1")}))
+	_, err := c.Increment("count", 1)
+	assert.NoError(t, err)
+
+	item, err := replica.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b", string(item.Value))
+
+	item, err = replica.Get("count")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(item.Value))
+
+	assert.NoError(t, c.Delete("key"))
+	_, err = replica.Get("key")
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	assert.Equal(t, time.Duration(0), ttl(0, now))
+	assert.Equal(t, 10*time.Second, ttl(10, now))
+	assert.Equal(t, 60*time.Second, ttl(1700000060, now))
+	assert.Less(t, ttl(1699999990, now), time.Duration(0))
+}
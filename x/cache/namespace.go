@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrNamespaceFlush is returned by FlushAll of a namespaced cache, as the backend can't drop the keys of one namespace only
+var ErrNamespaceFlush = errors.New("a namespaced cache can't be flushed")
+
+// namespaced prefixes every key with its namespace, so that domains sharing one backend never read each other's entries
+type namespaced struct {
+	inner  Cache
+	prefix string
+}
+
+// NewNamespaced creates a cache storing its keys under the namespace in c
+func NewNamespaced(c Cache, namespace string) Cache {
+	return &namespaced{c, namespace + ":"}
+}
+
+func (n *namespaced) key(key string) string {
+	return n.prefix + key
+}
+
+// item copies the item with the key in the namespace, the caller's item is left untouched
+func (n *namespaced) item(item *memcache.Item) *memcache.Item {
+	prefixed := *item
+	prefixed.Key = n.key(item.Key)
+	return &prefixed
+}
+
+func (n *namespaced) Get(key string) (*memcache.Item, error) {
+	item, err := n.inner.Get(n.key(key))
+	if err != nil {
+		return nil, err
+	}
+	item.Key = key
+	return item, nil
+}
+
+func (n *namespaced) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = n.key(key)
+	}
+	items, err := n.inner.GetMulti(prefixed)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*memcache.Item, len(items))
+	for key, item := range items {
+		key = strings.TrimPrefix(key, n.prefix)
+		item.Key = key
+		result[key] = item
+	}
+	return result, nil
+}
+
+func (n *namespaced) Set(item *memcache.Item) error {
+	return n.inner.Set(n.item(item))
+}
+
+func (n *namespaced) Add(item *memcache.Item) error {
+	return n.inner.Add(n.item(item))
+}
+
+func (n *namespaced) Replace(item *memcache.Item) error {
+	return n.inner.Replace(n.item(item))
+}
+
+func (n *namespaced) Prepend(item *memcache.Item) error {
+	return n.inner.Prepend(n.item(item))
+}
+
+func (n *namespaced) Delete(key string) error {
+	return n.inner.Delete(n.key(key))
+}
+
+func (n *namespaced) Increment(key string, delta uint64) (uint64, error) {
+	return n.inner.Increment(n.key(key), delta)
+}
+
+func (n *namespaced) Decrement(key string, delta uint64) (uint64, error) {
+	return n.inner.Decrement(n.key(key), delta)
+}
+
+func (n *namespaced) FlushAll() error {
+	return ErrNamespaceFlush
+}
+
+// Close leaves the shared backend open, its owner closes it
+func (n *namespaced) Close() error {
+	return nil
+}
+
+// namespacedScanner lists the keys of a namespace in the keys of the namespaced cache
+type namespacedScanner struct {
+	inner  Scanner
+	prefix string
+}
+
+// NewNamespacedScanner creates the scanner of the cache NewNamespaced creates for the same namespace
+func NewNamespacedScanner(s Scanner, namespace string) Scanner {
+	return &namespacedScanner{s, namespace + ":"}
+}
+
+func (n *namespacedScanner) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	return n.inner.Scan(ctx, n.prefix+prefix, func(keys []string) error {
+		stripped := make([]string, len(keys))
+		for i, key := range keys {
+			stripped[i] = strings.TrimPrefix(key, n.prefix)
+		}
+		return fn(stripped)
+	})
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaced(t *testing.T) {
+	shared := newTestMemory(t)
+	a := NewNamespaced(shared, "a.example.com")
+	b := NewNamespaced(shared, "b.example.com")
+
+	assert.NoError(t, a.Set(&memcache.Item{Key: "tl:readers", Value: []byte("a")}))
+	assert.NoError(t, b.Set(&memcache.Item{Key: "tl:readers", Value: []byte("b")}))
+
+	item, err := a.Get("tl:readers")
+	assert.NoError(t, err)
+	assert.Equal(t, "tl:readers", item.Key)
+	assert.Equal(t, "a", string(item.Value))
+
+	items, err := b.GetMulti([]string{"tl:readers", "missing"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "b", string(items["tl:readers"].Value))
+
+	_, err = shared.Get("tl:readers")
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+
+	assert.NoError(t, a.Delete("tl:readers"))
+	_, err = a.Get("tl:readers")
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+	_, err = b.Get("tl:readers")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, a.FlushAll(), ErrNamespaceFlush)
+}
+
+func TestNamespacedScanner(t *testing.T) {
+	scanner := NewNamespacedScanner(listScanner{"a.example.com:tl:itr:1", "b.example.com:tl:itr:2"}, "a.example.com")
+
+	var found []string
+	err := scanner.Scan(context.Background(), "tl:itr:", func(keys []string) error {
+		found = append(found, keys...)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tl:itr:1"}, found)
+}
+
+// listScanner lists the fixed keys starting with the prefix
+type listScanner []string
+
+func (l listScanner) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	var keys []string
+	for _, key := range l {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return fn(keys)
+}
@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+// redisCache keeps the cache in the cache namespace of redis. flags of the items are not stored
+type redisCache struct {
+	rdb *redis.Client
+}
+
+// NewRedis creates a cache on redis
+func NewRedis(rdb *redis.Client) Cache {
+	return &redisCache{rdb}
+}
+
+// operations of the memcache client take no context
+func (r *redisCache) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 3*time.Second)
+}
+
+func (r *redisCache) Get(key string) (*memcache.Item, error) {
+	ctx, cancel := r.ctx()
+	defer cancel()
+
+	value, err := r.rdb.Get(ctx, keyspace.Cache.Key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, memcache.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &memcache.Item{Key: key, Value: value}, nil
+}
+
+func (r *redisCache) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	items := make(map[string]*memcache.Item, len(keys))
+	if len(keys) == 0 {
+		return items, nil
+	}
+
+	ctx, cancel := r.ctx()
+	defer cancel()
+
+	namespaced := make([]string, len(keys))
+	for i, key := range keys {
+		namespaced[i] = keyspace.Cache.Key(key)
+	}
+	values, err := r.rdb.MGet(ctx, namespaced...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if str, ok := value.(string); ok {
+			items[keys[i]] = &memcache.Item{Key: keys[i], Value: []byte(str)}
+		}
+	}
+	return items, nil
+}
+
+// store sets the value with the SET mode: "" always, "NX" if absent or "XX" if present
+func (r *redisCache) store(item *memcache.Item, mode string) error {
+	ctx, cancel := r.ctx()
+	defer cancel()
+
+	d := ttl(item.Expiration, time.Now())
+	if d < 0 {
+		r.rdb.Del(ctx, keyspace.Cache.Key(item.Key))
+		return nil
+	}
+
+	// new keys are not written while a namespace of the key is over its budget
+	if mode != "XX" && !keyspace.AllowKey(r.rdb, keyspace.Cache.Key(item.Key)) {
+		return memcache.ErrNotStored
+	}
+
+	result, err := r.rdb.SetArgs(ctx, keyspace.Cache.Key(item.Key), item.Value, redis.SetArgs{Mode: mode, TTL: d}).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && result != "OK") {
+		return memcache.ErrNotStored
+	}
+	return err
+}
+
+func (r *redisCache) Set(item *memcache.Item) error {
+	err := r.store(item, "")
+	if errors.Is(err, memcache.ErrNotStored) {
+		// an unconditional set is not expected to fail, the cache is best effort
+		return nil
+	}
+	return err
+}
+
+func (r *redisCache) Add(item *memcache.Item) error {
+	return r.store(item, "NX")
+}
+
+func (r *redisCache) Replace(item *memcache.Item) error {
+	return r.store(item, "XX")
+}
+
+var prependScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1] .. value, 'KEEPTTL')
+return 1
+`)
+
+func (r *redisCache) Prepend(item *memcache.Item) error {
+	ctx, cancel := r.ctx()
+	defer cancel()
+
+	stored, err := prependScript.Run(ctx, r.rdb, []string{keyspace.Cache.Key(item.Key)}, item.Value).Int()
+	if err != nil {
+		return err
+	}
+	if stored == 0 {
+		return memcache.ErrNotStored
+	}
+	return nil
+}
+
+func (r *redisCache) Delete(key string) error {
+	ctx, cancel := r.ctx()
+	defer cancel()
+
+	deleted, err := r.rdb.Del(ctx, keyspace.Cache.Key(key)).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return memcache.ErrCacheMiss
+	}
+	return nil
+}
+
+// counters are unsigned and do not go below 0 as in memcached.
+// -1 tells the key is missing
+var addScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return -1
+end
+local result = tonumber(value) + tonumber(ARGV[1])
+if result < 0 then
+	result = 0
+end
+redis.call('SET', KEYS[1], string.format('%d', result), 'KEEPTTL')
+return result
+`)
+
+func (r *redisCache) add(key string, delta int64) (uint64, error) {
+	ctx, cancel := r.ctx()
+	defer cancel()
+
+	result, err := addScript.Run(ctx, r.rdb, []string{keyspace.Cache.Key(key)}, strconv.FormatInt(delta, 10)).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if result < 0 {
+		return 0, memcache.ErrCacheMiss
+	}
+	return uint64(result), nil
+}
+
+func (r *redisCache) Increment(key string, delta uint64) (uint64, error) {
+	return r.add(key, int64(delta))
+}
+
+func (r *redisCache) Decrement(key string, delta uint64) (uint64, error) {
+	return r.add(key, -int64(delta))
+}
+
+// FlushAll deletes the keys of the cache namespace. the rest of redis is kept
+func (r *redisCache) FlushAll() error {
+	ctx := context.Background()
+	iter := r.rdb.Scan(ctx, 0, keyspace.Cache.Pattern(), 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) >= 1000 {
+			if err := r.rdb.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return r.rdb.Del(ctx, keys...).Err()
+	}
+	return nil
+}
+
+// Close does nothing, redis is shared with the rest of the process
+func (r *redisCache) Close() error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"log/slog"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// replicated reads from the primary cache and writes to the replicas as well,
+// so that instances in other regions reading their local replica see the same entries.
+// writes to the replicas are best effort: their failures are logged and the result of the primary is returned
+type replicated struct {
+	primary  Cache
+	replicas []Cache
+}
+
+// NewReplicated creates a cache replicating the writes of primary to the replicas
+func NewReplicated(primary Cache, replicas ...Cache) Cache {
+	return &replicated{primary, replicas}
+}
+
+func (r *replicated) replicate(op string, fn func(Cache) error) {
+	for _, replica := range r.replicas {
+		err := fn(replica)
+		// misses are expected, the replicas only hold what was written since they started
+		if err != nil && err != memcache.ErrCacheMiss && err != memcache.ErrNotStored {
+			slog.Warn("failed to replicate cache write", slog.String("module", "cache"), slog.String("op", op), slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (r *replicated) Get(key string) (*memcache.Item, error) {
+	return r.primary.Get(key)
+}
+
+func (r *replicated) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	return r.primary.GetMulti(keys)
+}
+
+func (r *replicated) Set(item *memcache.Item) error {
+	err := r.primary.Set(item)
+	r.replicate("set", func(c Cache) error { return c.Set(item) })
+	return err
+}
+
+func (r *replicated) Add(item *memcache.Item) error {
+	err := r.primary.Add(item)
+	if err == nil {
+		r.replicate("add", func(c Cache) error { return c.Set(item) })
+	}
+	return err
+}
+
+func (r *replicated) Replace(item *memcache.Item) error {
+	err := r.primary.Replace(item)
+	if err == nil {
+		r.replicate("replace", func(c Cache) error { return c.Set(item) })
+	}
+	return err
+}
+
+// Prepend replicates the whole value of the primary, a replica missing the key would otherwise stay behind
+func (r *replicated) Prepend(item *memcache.Item) error {
+	err := r.primary.Prepend(item)
+	if err != nil {
+		return err
+	}
+	r.copy("prepend", item.Key, item.Expiration)
+	return nil
+}
+
+func (r *replicated) Delete(key string) error {
+	err := r.primary.Delete(key)
+	r.replicate("delete", func(c Cache) error { return c.Delete(key) })
+	return err
+}
+
+func (r *replicated) Increment(key string, delta uint64) (uint64, error) {
+	value, err := r.primary.Increment(key, delta)
+	if err == nil {
+		r.copy("increment", key, 0)
+	}
+	return value, err
+}
+
+func (r *replicated) Decrement(key string, delta uint64) (uint64, error) {
+	value, err := r.primary.Decrement(key, delta)
+	if err == nil {
+		r.copy("decrement", key, 0)
+	}
+	return value, err
+}
+
+// copy writes the value of the key in the primary to the replicas.
+// the expiration left in the primary is unknown, so the copy keeps the given one
+func (r *replicated) copy(op, key string, expiration int32) {
+	item, err := r.primary.Get(key)
+	if err != nil {
+		return
+	}
+	item.Expiration = expiration
+	r.replicate(op, func(c Cache) error { return c.Set(item) })
+}
+
+func (r *replicated) FlushAll() error {
+	err := r.primary.FlushAll()
+	r.replicate("flush", func(c Cache) error { return c.FlushAll() })
+	return err
+}
+
+func (r *replicated) Close() error {
+	err := r.primary.Close()
+	for _, replica := range r.replicas {
+		replica.Close()
+	}
+	return err
+}
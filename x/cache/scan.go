@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+// ErrScanUnsupported is returned by the scanners of the backends which can't list their keys
+var ErrScanUnsupported = errors.New("the cache backend can't list its keys")
+
+const scanBatchSize = 1000
+
+// Scanner lists the keys of the cache, so that the keys which outlived their resources can be swept
+type Scanner interface {
+	// Scan calls fn with batches of the keys starting with prefix. a key may be listed more than once
+	Scan(ctx context.Context, prefix string, fn func(keys []string) error) error
+}
+
+// NewScanner creates the scanner of the backend New creates for the same arguments.
+// the in-process cache can't list its keys and bounds its size by itself
+func NewScanner(config Config, memcachedAddr string, rdb *redis.Client) Scanner {
+	switch config.Backend {
+	case "", BackendMemcached:
+		return &memcachedScanner{addr: memcachedAddr}
+	case BackendRedis:
+		return &redisScanner{rdb: rdb}
+	default:
+		return unsupportedScanner{}
+	}
+}
+
+type unsupportedScanner struct{}
+
+func (unsupportedScanner) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	return ErrScanUnsupported
+}
+
+// memcachedScanner lists the keys with the lru crawler of memcached (1.4.31 or later).
+// replicas are not scanned, as deletions through the cache reach them as well
+type memcachedScanner struct {
+	addr string
+}
+
+const memcachedScanTimeout = 5 * time.Minute
+
+func (m *memcachedScanner) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(memcachedScanTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	_, err = conn.Write([]byte("lru_crawler metadump all\r\n"))
+	if err != nil {
+		return err
+	}
+
+	// each line is "key=<url encoded key> exp=<unix time> la=... cas=... fetch=... cls=... size=...", then END
+	batch := make([]string, 0, scanBatchSize)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "END" {
+			break
+		}
+		if !strings.HasPrefix(line, "key=") {
+			return fmt.Errorf("lru_crawler metadump: %s", line)
+		}
+
+		encoded, _, _ := strings.Cut(line[len("key="):], " ")
+		key, err := url.QueryUnescape(encoded)
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		batch = append(batch, key)
+		if len(batch) >= scanBatchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// redisScanner lists the keys of the cache namespace with SCAN
+type redisScanner struct {
+	rdb *redis.Client
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func (r *redisScanner) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	if r.rdb == nil {
+		return ErrScanUnsupported
+	}
+
+	namespace := keyspace.Cache.Key("")
+	match := globEscaper.Replace(namespace+prefix) + "*"
+
+	var cursor uint64
+	for {
+		keys, next, err := r.rdb.Scan(ctx, cursor, match, scanBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			for i, key := range keys {
+				keys[i] = strings.TrimPrefix(key, namespace)
+			}
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemcachedScan(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		if line != "lru_crawler metadump all\r\n" {
+			conn.Write([]byte("ERROR\r\n"))
+			return
+		}
+		conn.Write([]byte(
+			"key=tl%3Aitr%3Atx%40example.com%3A600 exp=-1 la=1 cas=1 fetch=no cls=1 size=64\r\n" +
+				"key=tl%3Anorm%3Atx exp=900 la=1 cas=2 fetch=no cls=1 size=64\r\n" +
+				"key=tl%3Aitr%3Aty%40example.com%3A1200 exp=-1 la=1 cas=3 fetch=no cls=1 size=64\r\n" +
+				"END\r\n",
+		))
+	}()
+
+	scanner := NewScanner(Config{}, listener.Addr().String(), nil)
+	var keys []string
+	err = scanner.Scan(context.Background(), "tl:itr:", func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tl:itr:tx@example.com:600", "tl:itr:ty@example.com:1200"}, keys)
+
+	// the in-process cache can't be listed
+	err = NewScanner(Config{Backend: BackendMemory}, "", nil).Scan(context.Background(), "", func([]string) error { return nil })
+	assert.ErrorIs(t, err, ErrScanUnsupported)
+}
@@ -0,0 +1,65 @@
+package deletion
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	List(c echo.Context) error
+	Get(c echo.Context) error
+	Retry(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// List returns the deletions running or failed
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Deletion.Handler.List")
+	defer span.End()
+
+	deletions, err := h.service.List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": deletions})
+}
+
+// Get returns the progress of the deletion of an entity
+func (h handler) Get(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Deletion.Handler.Get")
+	defer span.End()
+
+	deletion, err := h.service.Get(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": deletion})
+}
+
+// Retry resumes a failed deletion from the step which failed
+func (h handler) Retry(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Deletion.Handler.Retry")
+	defer span.End()
+
+	deletion, err := h.service.Retry(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": deletion})
+}
@@ -0,0 +1,111 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for account deletion repository
+type Repository interface {
+	Create(ctx context.Context, deletion core.AccountDeletion) (core.AccountDeletion, bool, error)
+	Get(ctx context.Context, id string) (core.AccountDeletion, error)
+	Update(ctx context.Context, deletion core.AccountDeletion) error
+	ListUnfinished(ctx context.Context) ([]core.AccountDeletion, error)
+	ListStale(ctx context.Context, before time.Time) ([]core.AccountDeletion, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new account deletion repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// Create records a new deletion. a deletion already recorded for the entity is returned as is,
+// and created tells whether this call recorded it
+func (r *repository) Create(ctx context.Context, deletion core.AccountDeletion) (core.AccountDeletion, bool, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Repository.Create")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deletion)
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return core.AccountDeletion{}, false, result.Error
+	}
+
+	recorded, err := r.Get(ctx, deletion.ID)
+	if err != nil {
+		return core.AccountDeletion{}, false, err
+	}
+	return recorded, result.RowsAffected > 0, nil
+}
+
+func (r *repository) Get(ctx context.Context, id string) (core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Repository.Get")
+	defer span.End()
+
+	var deletion core.AccountDeletion
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&deletion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.AccountDeletion{}, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return core.AccountDeletion{}, err
+	}
+
+	return deletion, nil
+}
+
+// Update saves the progress of the deletion. it also renews its lease, see ListStale
+func (r *repository) Update(ctx context.Context, deletion core.AccountDeletion) error {
+	ctx, span := tracer.Start(ctx, "Deletion.Repository.Update")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Model(&core.AccountDeletion{}).Where("id = ?", deletion.ID).Updates(map[string]any{
+		"step":     deletion.Step,
+		"status":   deletion.Status,
+		"attempts": deletion.Attempts,
+		"error":    deletion.Error,
+		"m_date":   time.Now(),
+	}).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// ListUnfinished returns the deletions running or failed, oldest first
+func (r *repository) ListUnfinished(ctx context.Context) ([]core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Repository.ListUnfinished")
+	defer span.End()
+
+	var deletions []core.AccountDeletion
+	err := r.db.WithContext(ctx).Where("status <> ?", StatusCompleted).Order("c_date ASC").Find(&deletions).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return deletions, err
+}
+
+// ListStale returns the running deletions whose progress was not saved since before,
+// i.e. those whose process crashed or whose last step failed
+func (r *repository) ListStale(ctx context.Context, before time.Time) ([]core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Repository.ListStale")
+	defer span.End()
+
+	var deletions []core.AccountDeletion
+	err := r.db.WithContext(ctx).Where("status = ? AND m_date < ?", StatusRunning, before).Order("c_date ASC").Find(&deletions).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return deletions, err
+}
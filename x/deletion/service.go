@@ -0,0 +1,325 @@
+// Package deletion deletes the data of a local entity across the modules once it commits its tombstone.
+// the deletion is a saga of idempotent steps whose progress is saved after each step,
+// so that a deletion interrupted by a crash or a failing module is resumed where it stopped
+package deletion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/client"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("deletion")
+
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+
+	// StepNotify relays the tombstone to the peer domains, before the keys verifying it are deleted
+	StepNotify = "notify"
+	// StepDone is the step of completed deletions
+	StepDone = "done"
+)
+
+const (
+	// a step failing this many times fails the deletion until an admin retries it
+	maxAttempts = 5
+	// running deletions not saved for this long are resumed. steps failing are retried after it as well
+	staleAfter    = 10 * time.Minute
+	notifyTimeout = 10 * time.Second
+)
+
+// Cleaner deletes the data a module holds for an entity. cleaning twice must be harmless
+type Cleaner interface {
+	Clean(ctx context.Context, ccid string) error
+}
+
+// Modules are the modules holding the data of an entity. they are cleaned in the order of the fields,
+// the entity last so that it stays known until everything else is gone
+type Modules struct {
+	Messages      Cleaner
+	Associations  Cleaner
+	Profiles      Cleaner
+	Timelines     Cleaner
+	Subscriptions Cleaner
+	SemanticIDs   Cleaner
+	UserKV        Cleaner
+	Media         Cleaner // files stored for the entity, like the archives of its exports
+	Keys          Cleaner
+	Entity        Cleaner
+}
+
+type step struct {
+	name string
+	run  func(ctx context.Context, deletion core.AccountDeletion) error
+}
+
+func clean(cleaner Cleaner) func(ctx context.Context, deletion core.AccountDeletion) error {
+	return func(ctx context.Context, deletion core.AccountDeletion) error {
+		return cleaner.Clean(ctx, deletion.ID)
+	}
+}
+
+// Service is the interface for account deletion service
+type Service interface {
+	core.CommitListener
+	Start(ctx context.Context, ccid, document, signature string) (core.AccountDeletion, error)
+	Get(ctx context.Context, ccid string) (core.AccountDeletion, error)
+	List(ctx context.Context) ([]core.AccountDeletion, error)
+	Retry(ctx context.Context, ccid string) (core.AccountDeletion, error)
+	Resume(ctx context.Context) error
+}
+
+type service struct {
+	repo    Repository
+	entity  core.EntityService
+	domain  core.DomainService
+	client  client.Client
+	config  core.Config
+	steps   []step
+	running sync.Map // ccid -> struct{}, deletions run by this instance
+}
+
+// NewService creates a new account deletion service
+func NewService(repo Repository, entity core.EntityService, domain core.DomainService, client client.Client, config core.Config, modules Modules) Service {
+	s := &service{
+		repo:   repo,
+		entity: entity,
+		domain: domain,
+		client: client,
+		config: config,
+	}
+	s.steps = []step{
+		{StepNotify, s.notify},
+		{"messages", clean(modules.Messages)},
+		{"associations", clean(modules.Associations)},
+		{"profiles", clean(modules.Profiles)},
+		{"timelines", clean(modules.Timelines)},
+		{"subscriptions", clean(modules.Subscriptions)},
+		{"semanticids", clean(modules.SemanticIDs)},
+		{"userkv", clean(modules.UserKV)},
+		{"media", clean(modules.Media)},
+		{"keys", clean(modules.Keys)},
+		{"entity", clean(modules.Entity)},
+	}
+	return s
+}
+
+// AfterCommit starts the deletion of local entities committing their tombstone
+func (s *service) AfterCommit(ctx context.Context, mode core.CommitMode, typ, document, signature string) {
+	if typ != "tombstone" || mode == core.CommitModeDryRun {
+		return
+	}
+
+	var doc core.TombstoneDocument
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return
+	}
+
+	signer, err := s.entity.Get(ctx, doc.Signer)
+	if err != nil || signer.Domain != s.config.FQDN {
+		return
+	}
+
+	_, err = s.Start(ctx, doc.Signer, document, signature)
+	if err != nil {
+		logging.Error(ctx, "failed to start account deletion", slog.String("module", "deletion"), slog.String("ccid", doc.Signer), slog.String("error", err.Error()))
+	}
+}
+
+// Start records the deletion of the entity and runs it in the background.
+// starting a deletion already recorded returns its progress without running it again
+func (s *service) Start(ctx context.Context, ccid, document, signature string) (core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Service.Start")
+	defer span.End()
+
+	deletion, created, err := s.repo.Create(ctx, core.AccountDeletion{
+		ID:        ccid,
+		Step:      s.steps[0].name,
+		Status:    StatusRunning,
+		Document:  document,
+		Signature: signature,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return core.AccountDeletion{}, err
+	}
+
+	// a deletion recorded before, e.g. by a replayed tombstone, is already run by Start or Resume
+	if created {
+		go s.run(context.WithoutCancel(ctx), deletion)
+	}
+	return deletion, nil
+}
+
+func (s *service) Get(ctx context.Context, ccid string) (core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Service.Get")
+	defer span.End()
+
+	return s.repo.Get(ctx, ccid)
+}
+
+// List returns the deletions not completed yet
+func (s *service) List(ctx context.Context) ([]core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Service.List")
+	defer span.End()
+
+	return s.repo.ListUnfinished(ctx)
+}
+
+// Retry runs a failed deletion again from the step which failed
+func (s *service) Retry(ctx context.Context, ccid string) (core.AccountDeletion, error) {
+	ctx, span := tracer.Start(ctx, "Deletion.Service.Retry")
+	defer span.End()
+
+	deletion, err := s.repo.Get(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return core.AccountDeletion{}, err
+	}
+	if deletion.Status != StatusFailed {
+		return core.AccountDeletion{}, core.NewErrorInvalidArgument(fmt.Sprintf("deletion is %s", deletion.Status))
+	}
+
+	deletion.Status = StatusRunning
+	deletion.Attempts = 0
+	err = s.repo.Update(ctx, deletion)
+	if err != nil {
+		span.RecordError(err)
+		return core.AccountDeletion{}, err
+	}
+
+	go s.run(context.WithoutCancel(ctx), deletion)
+	return deletion, nil
+}
+
+// Resume runs the deletions interrupted by a crash, and those whose step failed, again
+func (s *service) Resume(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Deletion.Service.Resume")
+	defer span.End()
+
+	stale, err := s.repo.ListStale(ctx, time.Now().Add(-staleAfter))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	span.SetAttributes(attribute.Int("stale", len(stale)))
+	for _, deletion := range stale {
+		s.run(ctx, deletion)
+	}
+	return nil
+}
+
+// run executes the steps of the deletion from its current step, saving the progress after each of them.
+// a failing step is counted and left for Resume
+func (s *service) run(ctx context.Context, deletion core.AccountDeletion) {
+	if _, running := s.running.LoadOrStore(deletion.ID, struct{}{}); running {
+		return
+	}
+	defer s.running.Delete(deletion.ID)
+
+	ctx, span := tracer.Start(ctx, "Deletion.Service.Run")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("ccid", deletion.ID), attribute.String("step", deletion.Step))
+
+	start := 0
+	for i, step := range s.steps {
+		if step.name == deletion.Step {
+			start = i
+			break
+		}
+	}
+
+	for _, step := range s.steps[start:] {
+		deletion.Step = step.name
+		err := s.repo.Update(ctx, deletion)
+		if err != nil {
+			span.RecordError(err)
+			return
+		}
+
+		err = step.run(ctx, deletion)
+		if err != nil {
+			span.RecordError(err)
+			deletion.Attempts++
+			deletion.Error = fmt.Sprintf("%s: %s", step.name, err.Error())
+			if deletion.Attempts >= maxAttempts {
+				deletion.Status = StatusFailed
+			}
+			logging.Warn(ctx, "account deletion step failed", slog.String("module", "deletion"), slog.String("ccid", deletion.ID), slog.String("step", step.name), slog.String("error", err.Error()))
+			err = s.repo.Update(ctx, deletion)
+			if err != nil {
+				span.RecordError(err)
+			}
+			return
+		}
+		deletion.Attempts = 0
+		deletion.Error = ""
+	}
+
+	deletion.Step = StepDone
+	deletion.Status = StatusCompleted
+	err := s.repo.Update(ctx, deletion)
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+	logging.Info(ctx, "account deleted", slog.String("module", "deletion"), slog.String("ccid", deletion.ID))
+}
+
+// notify relays the tombstone to the known domains, so that they drop what they cached of the entity.
+// peers which can't be reached are only logged, a deletion is not held back by them
+func (s *service) notify(ctx context.Context, deletion core.AccountDeletion) error {
+	ctx, span := tracer.Start(ctx, "Deletion.Service.Notify")
+	defer span.End()
+
+	if deletion.Document == "" {
+		return nil
+	}
+
+	packet, err := json.Marshal(core.Commit{
+		Document:  deletion.Document,
+		Signature: deletion.Signature,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	domains, err := s.domain.List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	failed := 0
+	for _, domain := range domains {
+		if domain.ID == s.config.FQDN {
+			continue
+		}
+		notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		_, err := s.client.Commit(notifyCtx, domain.ID, string(packet), nil, nil)
+		cancel()
+		if err != nil {
+			failed++
+			logging.Warn(ctx, "failed to relay tombstone", slog.String("module", "deletion"), slog.String("domain", domain.ID), slog.String("error", err.Error()))
+		}
+	}
+
+	span.SetAttributes(attribute.Int("domains", len(domains)), attribute.Int("failed", failed))
+	return nil
+}
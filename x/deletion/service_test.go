@@ -0,0 +1,172 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type memoryRepository struct {
+	deletions map[string]core.AccountDeletion
+}
+
+func (r *memoryRepository) Create(ctx context.Context, deletion core.AccountDeletion) (core.AccountDeletion, bool, error) {
+	if existing, ok := r.deletions[deletion.ID]; ok {
+		return existing, false, nil
+	}
+	r.deletions[deletion.ID] = deletion
+	return deletion, true, nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, id string) (core.AccountDeletion, error) {
+	deletion, ok := r.deletions[id]
+	if !ok {
+		return core.AccountDeletion{}, core.NewErrorNotFound()
+	}
+	return deletion, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, deletion core.AccountDeletion) error {
+	deletion.MDate = time.Now()
+	r.deletions[deletion.ID] = deletion
+	return nil
+}
+
+func (r *memoryRepository) ListUnfinished(ctx context.Context) ([]core.AccountDeletion, error) {
+	var result []core.AccountDeletion
+	for _, deletion := range r.deletions {
+		if deletion.Status != StatusCompleted {
+			result = append(result, deletion)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) ListStale(ctx context.Context, before time.Time) ([]core.AccountDeletion, error) {
+	var result []core.AccountDeletion
+	for _, deletion := range r.deletions {
+		if deletion.Status == StatusRunning && deletion.MDate.Before(before) {
+			result = append(result, deletion)
+		}
+	}
+	return result, nil
+}
+
+// recorder cleans the modules by recording their names. fails makes a module fail that many times
+type recorder struct {
+	cleaned []string
+	fails   map[string]int
+}
+
+type recordingCleaner struct {
+	name     string
+	recorder *recorder
+}
+
+func (c recordingCleaner) Clean(ctx context.Context, ccid string) error {
+	if c.recorder.fails[c.name] > 0 {
+		c.recorder.fails[c.name]--
+		return errors.New("unavailable")
+	}
+	c.recorder.cleaned = append(c.recorder.cleaned, c.name)
+	return nil
+}
+
+func newTestService(repo Repository, rec *recorder) *service {
+	cleaner := func(name string) Cleaner { return recordingCleaner{name, rec} }
+	return NewService(repo, nil, nil, nil, core.Config{FQDN: "local.example.com"}, Modules{
+		Messages:      cleaner("messages"),
+		Associations:  cleaner("associations"),
+		Profiles:      cleaner("profiles"),
+		Timelines:     cleaner("timelines"),
+		Subscriptions: cleaner("subscriptions"),
+		SemanticIDs:   cleaner("semanticids"),
+		UserKV:        cleaner("userkv"),
+		Media:         cleaner("media"),
+		Keys:          cleaner("keys"),
+		Entity:        cleaner("entity"),
+	}).(*service)
+}
+
+const ccid = "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"
+
+func TestRun(t *testing.T) {
+	repo := &memoryRepository{deletions: map[string]core.AccountDeletion{}}
+	rec := &recorder{fails: map[string]int{}}
+	s := newTestService(repo, rec)
+
+	deletion, _, _ := repo.Create(context.Background(), core.AccountDeletion{ID: ccid, Step: StepNotify, Status: StatusRunning})
+	s.run(context.Background(), deletion)
+
+	assert.Equal(t, []string{"messages", "associations", "profiles", "timelines", "subscriptions", "semanticids", "userkv", "media", "keys", "entity"}, rec.cleaned)
+	result, _ := repo.Get(context.Background(), ccid)
+	assert.Equal(t, StepDone, result.Step)
+	assert.Equal(t, StatusCompleted, result.Status)
+}
+
+func TestRunResumesFailedStep(t *testing.T) {
+	repo := &memoryRepository{deletions: map[string]core.AccountDeletion{}}
+	rec := &recorder{fails: map[string]int{"timelines": 1}}
+	s := newTestService(repo, rec)
+
+	deletion, _, _ := repo.Create(context.Background(), core.AccountDeletion{ID: ccid, Step: StepNotify, Status: StatusRunning})
+	s.run(context.Background(), deletion)
+
+	result, _ := repo.Get(context.Background(), ccid)
+	assert.Equal(t, "timelines", result.Step)
+	assert.Equal(t, StatusRunning, result.Status)
+	assert.Equal(t, 1, result.Attempts)
+	assert.Equal(t, "timelines: unavailable", result.Error)
+
+	// nothing is stale until the lease expires
+	assert.NoError(t, s.Resume(context.Background()))
+	assert.Equal(t, []string{"messages", "associations", "profiles"}, rec.cleaned)
+
+	result.MDate = time.Now().Add(-2 * staleAfter)
+	repo.deletions[ccid] = result
+	assert.NoError(t, s.Resume(context.Background()))
+
+	assert.Equal(t, []string{"messages", "associations", "profiles", "timelines", "subscriptions", "semanticids", "userkv", "media", "keys", "entity"}, rec.cleaned)
+	result, _ = repo.Get(context.Background(), ccid)
+	assert.Equal(t, StatusCompleted, result.Status)
+	assert.Equal(t, 0, result.Attempts)
+	assert.Empty(t, result.Error)
+}
+
+func TestRunFailsAfterMaxAttempts(t *testing.T) {
+	repo := &memoryRepository{deletions: map[string]core.AccountDeletion{}}
+	rec := &recorder{fails: map[string]int{"keys": maxAttempts}}
+	s := newTestService(repo, rec)
+
+	deletion, _, _ := repo.Create(context.Background(), core.AccountDeletion{ID: ccid, Step: StepNotify, Status: StatusRunning})
+	for i := 0; i < maxAttempts; i++ {
+		deletion, _ = repo.Get(context.Background(), ccid)
+		s.run(context.Background(), deletion)
+	}
+
+	result, _ := repo.Get(context.Background(), ccid)
+	assert.Equal(t, "keys", result.Step)
+	assert.Equal(t, StatusFailed, result.Status)
+
+	_, err := s.Retry(context.Background(), "unknown")
+	assert.ErrorIs(t, err, core.NewErrorNotFound())
+}
+
+func TestStartRecorded(t *testing.T) {
+	repo := &memoryRepository{deletions: map[string]core.AccountDeletion{}}
+	rec := &recorder{fails: map[string]int{}}
+	s := newTestService(repo, rec)
+
+	// the tombstone is replayed while its deletion runs elsewhere
+	repo.deletions[ccid] = core.AccountDeletion{ID: ccid, Step: "timelines", Status: StatusRunning, MDate: time.Now()}
+
+	deletion, err := s.Start(context.Background(), ccid, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "timelines", deletion.Step)
+	assert.Never(t, func() bool { return len(rec.cleaned) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+}
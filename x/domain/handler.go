@@ -16,15 +16,28 @@ var tracer = otel.Tracer("domain")
 type Handler interface {
 	Get(c echo.Context) error
 	List(c echo.Context) error
+	Federation(c echo.Context) error
+	Hello(c echo.Context) error
+	Keys(c echo.Context) error
+	Announce(c echo.Context) error
+	Peers(c echo.Context) error
+	Peer(c echo.Context) error
+	UpdateSettings(c echo.Context) error
+}
+
+// HelloRequest is the body of Hello
+type HelloRequest struct {
+	FQDN string `json:"fqdn"`
 }
 
 type handler struct {
-	service core.DomainService
+	service  core.DomainService
+	timeline core.TimelineService
 }
 
-// NewHandler creates a new handler
-func NewHandler(service core.DomainService) Handler {
-	return &handler{service}
+// NewHandler creates a new handler. the timeline service tells the state of the bridges to the peers
+func NewHandler(service core.DomainService, timeline core.TimelineService) Handler {
+	return &handler{service, timeline}
 }
 
 // Get returns a host by ID
@@ -57,3 +70,115 @@ func (h handler) List(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": hosts})
 }
+
+// Federation returns success rate, latency and circuit breaker state of remote domains
+func (h handler) Federation(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.Federation")
+	defer span.End()
+
+	stats := h.service.FederationStats(ctx)
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": stats})
+}
+
+// Hello is called by a known domain to have its record fetched again
+func (h handler) Hello(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.Hello")
+	defer span.End()
+
+	var request HelloRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	domain, err := h.service.Hello(ctx, request.FQDN)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": domain})
+}
+
+// Keys returns the state of the CSID rotation of this domain
+func (h handler) Keys(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.Keys")
+	defer span.End()
+
+	status := h.service.KeyStatus(ctx)
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": status})
+}
+
+// Announce tells the known domains to fetch the current CSID of this domain
+func (h handler) Announce(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.Announce")
+	defer span.End()
+
+	announcements, err := h.service.Announce(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": announcements})
+}
+
+// Peers returns the state of the federation with every known remote domain
+func (h handler) Peers(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.Peers")
+	defer span.End()
+
+	peers, err := h.service.Peers(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	bridges := h.bridges()
+	for i := range peers {
+		peers[i].Bridge = bridges[peers[i].Domain.ID]
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": peers})
+}
+
+// Peer returns the state of the federation with a remote domain
+func (h handler) Peer(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.Peer")
+	defer span.End()
+
+	peer, err := h.service.Peer(ctx, c.Param("fqdn"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	peer.Bridge = h.bridges()[peer.Domain.ID]
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": peer})
+}
+
+// UpdateSettings changes the settings of a remote domain
+func (h handler) UpdateSettings(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Domain.Handler.UpdateSettings")
+	defer span.End()
+
+	var update core.DomainSettingsUpdate
+	err := c.Bind(&update)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	peer, err := h.service.UpdateSettings(ctx, c.Param("fqdn"), update)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	peer.Bridge = h.bridges()[peer.Domain.ID]
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": peer})
+}
+
+func (h handler) bridges() map[string]*core.BridgeStatus {
+	bridges := make(map[string]*core.BridgeStatus)
+	for _, bridge := range h.timeline.GetBridges() {
+		bridges[bridge.Domain] = &bridge
+	}
+	return bridges
+}
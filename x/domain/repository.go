@@ -20,6 +20,12 @@ type Repository interface {
 	Delete(ctx context.Context, id string) error
 	UpdateScrapeTime(ctx context.Context, id string, scrapeTime time.Time) error
 	Update(ctx context.Context, host core.Domain) error
+	UpdateTag(ctx context.Context, id string, tag string) error
+	GetSettings(ctx context.Context, fqdn string) (core.DomainSettings, error)
+	ListSettings(ctx context.Context) (map[string]core.DomainSettings, error)
+	UpsertSettings(ctx context.Context, settings core.DomainSettings) (core.DomainSettings, error)
+	CountEntities(ctx context.Context, fqdn string) (map[string]int64, error)
+	LastDelivered(ctx context.Context, fqdn string) (map[string]time.Time, error)
 }
 
 type repository struct {
@@ -71,8 +77,12 @@ func (r *repository) GetByCSID(ctx context.Context, csid string) (core.Domain, e
 	ctx, span := tracer.Start(ctx, "Domain.Repository.GetByCSID")
 	defer span.End()
 
+	// a domain rotating its CSID still signs with the previous one for a while
 	var host core.Domain
-	err := r.db.WithContext(ctx).First(&host, "cs_id = ?", csid).Error
+	err := r.db.WithContext(ctx).
+		Where("cs_id = ?", csid).
+		Or("previous_cs_id = ? AND previous_cs_id_until > ?", csid, time.Now()).
+		First(&host).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return core.Domain{}, core.NewErrorNotFound()
@@ -127,3 +137,114 @@ func (r *repository) Update(ctx context.Context, host core.Domain) error {
 
 	return r.db.WithContext(ctx).Model(&core.Domain{}).Where("id = ?", host.ID).Updates(&host).Error
 }
+
+// UpdateTag replaces the tags of a host
+func (r *repository) UpdateTag(ctx context.Context, id string, tag string) error {
+	ctx, span := tracer.Start(ctx, "Domain.Repository.UpdateTag")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&core.Domain{}).Where("id = ?", id).Update("tag", tag).Error
+}
+
+// GetSettings returns the settings of a domain. a domain without settings has the zero ones
+func (r *repository) GetSettings(ctx context.Context, fqdn string) (core.DomainSettings, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Repository.GetSettings")
+	defer span.End()
+
+	var settings core.DomainSettings
+	err := r.db.WithContext(ctx).First(&settings, "domain = ?", fqdn).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.DomainSettings{Domain: fqdn}, nil
+		}
+		span.RecordError(err)
+		return core.DomainSettings{}, err
+	}
+	return settings, nil
+}
+
+// ListSettings returns the settings of every domain which has some, by domain
+func (r *repository) ListSettings(ctx context.Context) (map[string]core.DomainSettings, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Repository.ListSettings")
+	defer span.End()
+
+	var list []core.DomainSettings
+	err := r.db.WithContext(ctx).Find(&list).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result := make(map[string]core.DomainSettings, len(list))
+	for _, settings := range list {
+		result[settings.Domain] = settings
+	}
+	return result, nil
+}
+
+// UpsertSettings saves the settings of a domain
+func (r *repository) UpsertSettings(ctx context.Context, settings core.DomainSettings) (core.DomainSettings, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Repository.UpsertSettings")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Save(&settings).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return settings, err
+}
+
+// CountEntities counts the entities known here by domain. an empty fqdn counts them for every domain
+func (r *repository) CountEntities(ctx context.Context, fqdn string) (map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Repository.CountEntities")
+	defer span.End()
+
+	var rows []struct {
+		Domain string
+		Count  int64
+	}
+	query := r.db.WithContext(ctx).Model(&core.Entity{}).Select("domain, count(*) as count").Group("domain")
+	if fqdn != "" {
+		query = query.Where("domain = ?", fqdn)
+	}
+	err := query.Scan(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.Domain] = row.Count
+	}
+	return result, nil
+}
+
+// LastDelivered returns when each domain last accepted a relayed message. an empty fqdn looks at every domain
+func (r *repository) LastDelivered(ctx context.Context, fqdn string) (map[string]time.Time, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Repository.LastDelivered")
+	defer span.End()
+
+	var rows []struct {
+		Domain string
+		At     time.Time
+	}
+	query := r.db.WithContext(ctx).Model(&core.DeliveryReceipt{}).
+		Select("domain, max(m_date) as at").
+		Where("status = ?", core.DeliveryAccepted).
+		Group("domain")
+	if fqdn != "" {
+		query = query.Where("domain = ?", fqdn)
+	}
+	err := query.Scan(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		result[row.Domain] = row.At
+	}
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const defaultKeyOverlap = 7 * 24 * time.Hour
+
+// ownedColumns are the columns naming the entity which signed or owns a row
+var ownedColumns = []struct {
+	model  any
+	column string
+}{
+	{&core.Message{}, "author"},
+	{&core.Association{}, "author"},
+	{&core.Profile{}, "author"},
+	{&core.Timeline{}, "author"},
+	{&core.Timeline{}, "owner"},
+	{&core.Subscription{}, "author"},
+	{&core.Subscription{}, "owner"},
+	{&core.Ack{}, "from"},
+}
+
+// OwnedResources counts the rows the entity signed or owns, by table. tables without any are left out
+func OwnedResources(ctx context.Context, db *gorm.DB, ccid string) (map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "Domain.OwnedResources")
+	defer span.End()
+
+	owned := map[string]int64{}
+	for _, owner := range ownedColumns {
+		var count int64
+		err := db.WithContext(ctx).Model(owner.model).Where(fmt.Sprintf("%q = ?", owner.column), ccid).Count(&count).Error
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(owner.model); err != nil {
+			return nil, err
+		}
+		owned[stmt.Schema.Table] += count
+	}
+	return owned, nil
+}
+
+// RotateKey generates the next key of the domain. nothing changes until the config is updated with it:
+// privatekey is replaced by the new key, and previousCSID keeps the current CSID valid for peers
+// during the overlap. once the domain restarted with it, Announce tells the peers.
+// the timelines the domain signed with its CSID, like the firehose and the threads, are signed again and
+// named under the new CSID once the domain runs with it. the entity of the domain changes along with the key,
+// and what the current one signed can't be moved to the new one, so the rotation is refused while it owns resources
+func RotateKey(ctx context.Context, db *gorm.DB, config core.Config, overlap time.Duration) (core.DomainKeyRotation, error) {
+	ctx, span := tracer.Start(ctx, "Domain.RotateKey")
+	defer span.End()
+
+	if overlap <= 0 {
+		overlap = defaultKeyOverlap
+	}
+
+	owned, err := OwnedResources(ctx, db, config.CCID)
+	if err != nil {
+		return core.DomainKeyRotation{}, err
+	}
+	if len(owned) > 0 {
+		tables := make([]string, 0, len(owned))
+		for table, count := range owned {
+			tables = append(tables, fmt.Sprintf("%s: %d", table, count))
+		}
+		sort.Strings(tables)
+		return core.DomainKeyRotation{}, fmt.Errorf("%s still owns resources (%s)", config.CCID, strings.Join(tables, ", "))
+	}
+
+	privateKey, err := core.GeneratePrivateKey()
+	if err != nil {
+		span.RecordError(err)
+		return core.DomainKeyRotation{}, err
+	}
+	csid, err := core.PrivKeyToAddr(privateKey, "ccs")
+	if err != nil {
+		return core.DomainKeyRotation{}, err
+	}
+	ccid, err := core.PrivKeyToAddr(privateKey, "con")
+	if err != nil {
+		return core.DomainKeyRotation{}, err
+	}
+
+	return core.DomainKeyRotation{
+		PrivateKey:        privateKey,
+		CSID:              csid,
+		CCID:              ccid,
+		PreviousCSID:      config.CSID,
+		PreviousCSIDUntil: time.Now().Add(overlap).Truncate(time.Second),
+	}, nil
+}
@@ -2,22 +2,31 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
 )
 
+// peers are told about a rotation a few at a time
+const announceConcurrency = 8
+
 type service struct {
 	repository Repository
 	client     client.Client
 	config     core.Config
+
+	mu            sync.Mutex
+	announcements []core.DomainAnnouncement
+	scales        map[string]cachedScale
 }
 
 // NewService creates a new host service
 func NewService(repository Repository, client client.Client, config core.Config) core.DomainService {
-	return &service{repository, client, config}
+	return &service{repository: repository, client: client, config: config}
 }
 
 // Upsert creates new host
@@ -37,7 +46,7 @@ func (s *service) Get(ctx context.Context, id string) (core.Domain, error) {
 	}
 
 	if core.IsCSID(id) {
-		if id == s.config.CSID {
+		if id == s.config.CSID || (id == s.config.PreviousCSID && time.Now().Before(s.config.PreviousCSIDUntil)) {
 			return core.Domain{
 				ID:        s.config.FQDN,
 				Dimension: s.config.Dimension,
@@ -70,19 +79,14 @@ func (s *service) GetByFQDN(ctx context.Context, fqdn string) (core.Domain, erro
 		return core.Domain{}, fmt.Errorf("domain is not in the same dimension")
 	}
 
-	_, err = s.repository.Upsert(ctx, domain)
-	if err != nil {
-		return core.Domain{}, err
-	}
-
-	return domain, nil
+	return s.save(ctx, domain)
 }
 
 func (s *service) ForceFetch(ctx context.Context, fqdn string) (core.Domain, error) {
 	ctx, span := tracer.Start(ctx, "Domain.Service.ForceFetch")
 	defer span.End()
 
-	domain, err := s.client.GetDomain(ctx, fqdn, nil)
+	domain, err := s.client.GetDomain(ctx, fqdn, &client.Options{NoCache: true})
 	if err != nil {
 		return core.Domain{}, err
 	}
@@ -91,12 +95,27 @@ func (s *service) ForceFetch(ctx context.Context, fqdn string) (core.Domain, err
 		return core.Domain{}, fmt.Errorf("domain is not in the same dimension")
 	}
 
-	_, err = s.repository.Upsert(ctx, domain)
-	if err != nil {
+	return s.save(ctx, domain)
+}
+
+// save stores a domain fetched from itself. the tags and the score are decided here,
+// so a domain can't e.g. lift its block by telling about itself again
+func (s *service) save(ctx context.Context, fetched core.Domain) (core.Domain, error) {
+	existing, err := s.repository.GetByFQDN(ctx, fetched.ID)
+	if err == nil {
+		fetched.Tag = existing.Tag
+		fetched.Score = existing.Score
+		fetched.IsScoreFixed = existing.IsScoreFixed
+	} else if errors.Is(err, core.ErrorNotFound{}) {
+		fetched.Tag = ""
+		fetched.Score = 0
+		fetched.IsScoreFixed = false
+	} else {
 		return core.Domain{}, err
 	}
+	fetched.LastScraped = time.Now()
 
-	return domain, nil
+	return s.repository.Upsert(ctx, fetched)
 }
 
 // GetByCCID returns domain by CCID
@@ -138,3 +157,111 @@ func (s *service) UpdateScrapeTime(ctx context.Context, id string, scrapeTime ti
 
 	return s.repository.UpdateScrapeTime(ctx, id, scrapeTime)
 }
+
+// FederationStats returns the observed quality of the remote domains contacted recently
+func (s *service) FederationStats(ctx context.Context) []core.FederationStats {
+	_, span := tracer.Start(ctx, "Domain.Service.FederationStats")
+	defer span.End()
+
+	return s.client.FederationStats()
+}
+
+// Hello is called by a known domain which changed, e.g. rotated its CSID. the domain is fetched again
+// from itself over https, so nothing of the request is trusted. unknown domains are not fetched
+func (s *service) Hello(ctx context.Context, fqdn string) (core.Domain, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Service.Hello")
+	defer span.End()
+
+	if fqdn == "" || fqdn == s.config.FQDN {
+		return core.Domain{}, core.NewErrorInvalidArgument("fqdn must be a remote domain")
+	}
+
+	_, err := s.repository.GetByFQDN(ctx, fqdn)
+	if err != nil {
+		return core.Domain{}, err
+	}
+
+	domain, err := s.ForceFetch(ctx, fqdn)
+	if err != nil {
+		span.RecordError(err)
+		return core.Domain{}, err
+	}
+	return domain, nil
+}
+
+// Announce says hello to every known domain, so that they fetch the current CSID of this one.
+// the results are kept for KeyStatus
+func (s *service) Announce(ctx context.Context) ([]core.DomainAnnouncement, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Service.Announce")
+	defer span.End()
+
+	domains, err := s.repository.GetList(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	peers := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain.ID == s.config.FQDN {
+			continue
+		}
+		tags := core.ParseTags(domain.Tag)
+		if tags.Has("_block") {
+			continue
+		}
+		peers = append(peers, domain.ID)
+	}
+
+	announcements := make([]core.DomainAnnouncement, len(peers))
+	sem := make(chan struct{}, announceConcurrency)
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, peer string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			announcement := core.DomainAnnouncement{Domain: peer}
+			known, err := s.client.Hello(ctx, peer, s.config.FQDN, nil)
+			if err != nil {
+				announcement.Error = err.Error()
+			} else {
+				announcement.Acknowledged = known.CSID == s.config.CSID
+			}
+			announcement.At = time.Now()
+			announcements[i] = announcement
+		}(i, peer)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.announcements = announcements
+	s.mu.Unlock()
+
+	return announcements, nil
+}
+
+// KeyStatus returns the state of the CSID rotation, with the results of the latest Announce of this process
+func (s *service) KeyStatus(ctx context.Context) core.DomainKeyStatus {
+	_, span := tracer.Start(ctx, "Domain.Service.KeyStatus")
+	defer span.End()
+
+	status := core.DomainKeyStatus{CSID: s.config.CSID, State: "single"}
+	if s.config.PreviousCSID != "" {
+		until := s.config.PreviousCSIDUntil
+		status.PreviousCSID = s.config.PreviousCSID
+		status.PreviousCSIDUntil = &until
+		status.State = "overlap"
+		if !time.Now().Before(until) {
+			status.State = "retired"
+		}
+	}
+
+	s.mu.Lock()
+	status.Announcements = append([]core.DomainAnnouncement{}, s.announcements...)
+	s.mu.Unlock()
+
+	return status
+}
@@ -0,0 +1,218 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	tagBlocked  = "_block"
+	tagSilenced = "_silenced"
+
+	// the rate limit scales are looked up for every request of a remote user, and changes take this long to apply
+	scaleCacheTTL = time.Minute
+)
+
+type cachedScale struct {
+	scale   float64
+	expires time.Time
+}
+
+// Peers returns the state of the federation with every known remote domain
+func (s *service) Peers(ctx context.Context) ([]core.DomainPeer, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Service.Peers")
+	defer span.End()
+
+	domains, err := s.repository.GetList(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	settings, err := s.repository.ListSettings(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	entities, err := s.repository.CountEntities(ctx, "")
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	delivered, err := s.repository.LastDelivered(ctx, "")
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	stats := s.federationStats()
+
+	peers := make([]core.DomainPeer, 0, len(domains))
+	for _, domain := range domains {
+		if domain.ID == s.config.FQDN {
+			continue
+		}
+		domainSettings, ok := settings[domain.ID]
+		if !ok {
+			domainSettings = core.DomainSettings{Domain: domain.ID}
+		}
+		peers = append(peers, s.peer(domain, domainSettings, entities, delivered, stats))
+	}
+	return peers, nil
+}
+
+// Peer returns the state of the federation with a known remote domain
+func (s *service) Peer(ctx context.Context, fqdn string) (core.DomainPeer, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Service.Peer")
+	defer span.End()
+
+	if fqdn == s.config.FQDN {
+		return core.DomainPeer{}, core.NewErrorInvalidArgument("fqdn must be a remote domain")
+	}
+
+	domain, err := s.repository.GetByFQDN(ctx, fqdn)
+	if err != nil {
+		return core.DomainPeer{}, err
+	}
+	settings, err := s.repository.GetSettings(ctx, fqdn)
+	if err != nil {
+		span.RecordError(err)
+		return core.DomainPeer{}, err
+	}
+	entities, err := s.repository.CountEntities(ctx, fqdn)
+	if err != nil {
+		span.RecordError(err)
+		return core.DomainPeer{}, err
+	}
+	delivered, err := s.repository.LastDelivered(ctx, fqdn)
+	if err != nil {
+		span.RecordError(err)
+		return core.DomainPeer{}, err
+	}
+
+	return s.peer(domain, settings, entities, delivered, s.federationStats()), nil
+}
+
+// UpdateSettings applies the changes of the admin to a known remote domain. blocking and silencing tag the domain
+func (s *service) UpdateSettings(ctx context.Context, fqdn string, update core.DomainSettingsUpdate) (core.DomainPeer, error) {
+	ctx, span := tracer.Start(ctx, "Domain.Service.UpdateSettings")
+	defer span.End()
+
+	if fqdn == s.config.FQDN {
+		return core.DomainPeer{}, core.NewErrorInvalidArgument("fqdn must be a remote domain")
+	}
+	if update.RateLimitScale != nil && *update.RateLimitScale < 0 {
+		return core.DomainPeer{}, core.NewErrorInvalidArgument("rateLimitScale must not be negative")
+	}
+
+	domain, err := s.repository.GetByFQDN(ctx, fqdn)
+	if err != nil {
+		return core.DomainPeer{}, err
+	}
+
+	if update.Blocked != nil || update.Silenced != nil {
+		tags := core.ParseTags(domain.Tag)
+		tags.Remove("")
+		for tag, value := range map[string]*bool{tagBlocked: update.Blocked, tagSilenced: update.Silenced} {
+			if value == nil {
+				continue
+			}
+			if *value {
+				tags.Add(tag, "")
+			} else {
+				tags.Remove(tag)
+			}
+		}
+		err = s.repository.UpdateTag(ctx, fqdn, tags.ToString())
+		if err != nil {
+			span.RecordError(err)
+			return core.DomainPeer{}, err
+		}
+	}
+
+	if update.RateLimitScale != nil || update.Note != nil {
+		settings, err := s.repository.GetSettings(ctx, fqdn)
+		if err != nil {
+			span.RecordError(err)
+			return core.DomainPeer{}, err
+		}
+		if update.RateLimitScale != nil {
+			settings.RateLimitScale = *update.RateLimitScale
+		}
+		if update.Note != nil {
+			settings.Note = *update.Note
+		}
+		_, err = s.repository.UpsertSettings(ctx, settings)
+		if err != nil {
+			span.RecordError(err)
+			return core.DomainPeer{}, err
+		}
+
+		s.mu.Lock()
+		delete(s.scales, fqdn)
+		s.mu.Unlock()
+	}
+
+	return s.Peer(ctx, fqdn)
+}
+
+// RateLimitScale returns the multiplier of the rate limits of the users of a domain, or 0 to keep them.
+// errors keep them as well, so that a failing lookup doesn't lock the users out
+func (s *service) RateLimitScale(ctx context.Context, fqdn string) float64 {
+	ctx, span := tracer.Start(ctx, "Domain.Service.RateLimitScale")
+	defer span.End()
+
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.scales[fqdn]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.scale
+	}
+
+	settings, err := s.repository.GetSettings(ctx, fqdn)
+	if err != nil {
+		span.RecordError(err)
+		return 0
+	}
+
+	s.mu.Lock()
+	if s.scales == nil {
+		s.scales = make(map[string]cachedScale)
+	}
+	s.scales[fqdn] = cachedScale{scale: settings.RateLimitScale, expires: now.Add(scaleCacheTTL)}
+	s.mu.Unlock()
+
+	return settings.RateLimitScale
+}
+
+func (s *service) federationStats() map[string]core.FederationStats {
+	stats := make(map[string]core.FederationStats)
+	for _, peer := range s.client.FederationStats() {
+		stats[peer.Domain] = peer
+	}
+	return stats
+}
+
+// peer assembles the state of a domain. the bridge is known by the timelines, which fill it in
+func (s *service) peer(domain core.Domain, settings core.DomainSettings, entities map[string]int64, delivered map[string]time.Time, stats map[string]core.FederationStats) core.DomainPeer {
+	tags := core.ParseTags(domain.Tag)
+	peer := core.DomainPeer{
+		Domain:   domain,
+		Blocked:  tags.Has(tagBlocked),
+		Silenced: tags.Has(tagSilenced),
+		Settings: settings,
+		Entities: entities[domain.ID],
+	}
+	if !domain.LastScraped.IsZero() {
+		lastFetched := domain.LastScraped
+		peer.LastFetched = &lastFetched
+	}
+	if at, ok := delivered[domain.ID]; ok {
+		peer.LastDelivered = &at
+	}
+	if stat, ok := stats[domain.ID]; ok {
+		peer.Federation = &stat
+	}
+	return peer
+}
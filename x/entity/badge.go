@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// badge names are joined with commas when passed on by the gateway
+var badgeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// IssueBadge signs a badge for the local entity with the CSID of the domain, replacing the one of the same name
+func (s *service) IssueBadge(ctx context.Context, id, name string, body any, expiresAt *time.Time) (core.Badge, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.IssueBadge")
+	defer span.End()
+
+	if !badgeNameRegexp.MatchString(name) {
+		return core.Badge{}, core.NewErrorInvalidArgument("invalid badge name")
+	}
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return core.Badge{}, core.NewErrorInvalidArgument("badge already expired")
+	}
+
+	entity, err := s.repository.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return core.Badge{}, err
+	}
+	if entity.Domain != s.config.FQDN {
+		return core.Badge{}, core.NewErrorInvalidArgument("badges are issued only to local entities")
+	}
+
+	document, err := json.Marshal(core.BadgeDocument{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   s.config.CSID,
+			Type:     "badge",
+			Body:     body,
+			SignedAt: now,
+		},
+		Domain:    s.config.FQDN,
+		Entity:    entity.ID,
+		Name:      name,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return core.Badge{}, err
+	}
+
+	signatureBytes, err := core.SignBytes(document, s.config.PrivateKey)
+	if err != nil {
+		span.RecordError(err)
+		return core.Badge{}, err
+	}
+
+	return s.repository.SetBadge(ctx, core.Badge{
+		EntityID:  entity.ID,
+		Name:      name,
+		Document:  string(document),
+		Signature: hex.EncodeToString(signatureBytes),
+		ExpiresAt: expiresAt,
+		CDate:     now,
+	})
+}
+
+// RevokeBadge removes the badge from the entity. copies already passed on stay valid until they expire,
+// as do passports, so receivers should keep them only for a short while
+func (s *service) RevokeBadge(ctx context.Context, id, name string) error {
+	ctx, span := tracer.Start(ctx, "Entity.Service.RevokeBadge")
+	defer span.End()
+
+	return s.repository.RemoveBadge(ctx, id, name)
+}
+
+// ListBadges returns the live badges of the entity
+func (s *service) ListBadges(ctx context.Context, id string) ([]core.Badge, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.ListBadges")
+	defer span.End()
+
+	return s.repository.ListBadges(ctx, id, time.Now())
+}
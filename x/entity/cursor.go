@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	entityListDefaultLimit = 100
+	entityListMaxLimit     = 1000
+)
+
+// entityCursor is a keyset pagination position. Value is the sort key of the last entity of the page.
+type entityCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func (c entityCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeEntityCursor(s string) (entityCursor, error) {
+	var cursor entityCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, errors.Wrap(err, "invalid cursor")
+	}
+	err = json.Unmarshal(b, &cursor)
+	if err != nil {
+		return cursor, errors.Wrap(err, "invalid cursor")
+	}
+	return cursor, nil
+}
+
+// parseEntityOrder returns the column and direction of the order. prefix "-" means descending.
+// default is newest first.
+func parseEntityOrder(order string) (string, bool, error) {
+	if order == "" {
+		return "c_date", true, nil
+	}
+	desc := strings.HasPrefix(order, "-")
+	switch strings.TrimPrefix(order, "-") {
+	case "cdate":
+		return "c_date", desc, nil
+	case "score":
+		return "score", desc, nil
+	default:
+		return "", false, errors.New("unknown order: " + order)
+	}
+}
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityCursor(t *testing.T) {
+	cursor := entityCursor{Value: "2024-01-01T00:00:00Z", ID: "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"}
+	decoded, err := decodeEntityCursor(cursor.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	_, err = decodeEntityCursor("!!invalid")
+	assert.Error(t, err)
+}
+
+func TestParseEntityOrder(t *testing.T) {
+	column, desc, err := parseEntityOrder("")
+	assert.NoError(t, err)
+	assert.Equal(t, "c_date", column)
+	assert.True(t, desc)
+
+	column, desc, err = parseEntityOrder("score")
+	assert.NoError(t, err)
+	assert.Equal(t, "score", column)
+	assert.False(t, desc)
+
+	column, desc, err = parseEntityOrder("-score")
+	assert.NoError(t, err)
+	assert.Equal(t, "score", column)
+	assert.True(t, desc)
+
+	_, _, err = parseEntityOrder("id")
+	assert.Error(t, err)
+}
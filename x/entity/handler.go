@@ -2,12 +2,17 @@
 package entity
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 	"go.opentelemetry.io/otel"
 )
 
@@ -18,15 +23,28 @@ type Handler interface {
 	Get(c echo.Context) error
 	GetSelf(c echo.Context) error
 	List(c echo.Context) error
+	Refresh(c echo.Context) error
+	WebFinger(c echo.Context) error
+	GetAlias(c echo.Context) error
+	PutAlias(c echo.Context) error
+	DeleteAlias(c echo.Context) error
+	GetTagTaxonomy(c echo.Context) error
+	ListTags(c echo.Context) error
+	PutTag(c echo.Context) error
+	DeleteTag(c echo.Context) error
+	ListBadges(c echo.Context) error
+	PutBadge(c echo.Context) error
+	DeleteBadge(c echo.Context) error
 }
 
 type handler struct {
-	service core.EntityService
+	service   core.EntityService
+	webfinger WebFingerConfig
 }
 
 // NewHandler creates a new handler
-func NewHandler(service core.EntityService) Handler {
-	return &handler{service: service}
+func NewHandler(service core.EntityService, webfinger WebFingerConfig) Handler {
+	return &handler{service: service, webfinger: webfinger}
 }
 
 // Get returns an entity by ID
@@ -45,6 +63,7 @@ func (h handler) Get(c echo.Context) error {
 			span.RecordError(err)
 			return c.JSON(http.StatusInternalServerError, echo.Map{"status": "error", "message": err.Error()})
 		}
+		h.attachBadges(ctx, &entity)
 		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entity})
 	}
 
@@ -61,6 +80,21 @@ func (h handler) Get(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"status": "error", "message": err.Error()})
 	}
 
+	h.attachBadges(ctx, &entity)
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entity})
+}
+
+// Refresh pulls a remote entity from its domain again
+func (h handler) Refresh(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.Refresh")
+	defer span.End()
+
+	entity, err := h.service.Refresh(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entity})
 }
 
@@ -83,18 +117,272 @@ func (h handler) GetSelf(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"status": "error", "message": err.Error()})
 	}
 
+	h.attachBadges(ctx, &entity)
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entity})
 }
 
 // List returns a list of entities
+// query: domain, tag, minscore, maxscore, since, until (unix seconds of registration), order, cursor, limit
 func (h handler) List(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.List")
 	defer span.End()
 
-	entities, err := h.service.List(ctx)
+	query := core.EntityListQuery{
+		Domain: c.QueryParam("domain"),
+		Tag:    c.QueryParam("tag"),
+		Order:  c.QueryParam("order"),
+		Cursor: c.QueryParam("cursor"),
+	}
+
+	if _, _, err := parseEntityOrder(query.Order); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if query.Cursor != "" {
+		if _, err := decodeEntityCursor(query.Cursor); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+	}
+
+	for param, target := range map[string]**int{"minscore": &query.MinScore, "maxscore": &query.MaxScore} {
+		if value := c.QueryParam(param); value != "" {
+			score, err := strconv.Atoi(value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid " + param})
+			}
+			*target = &score
+		}
+	}
+
+	for param, target := range map[string]**time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.QueryParam(param); value != "" {
+			epoch, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid " + param})
+			}
+			t := time.Unix(epoch, 0)
+			*target = &t
+		}
+	}
+
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid limit"})
+		}
+		query.Limit = limit
+	}
+
+	entities, next, err := h.service.List(ctx, query)
 	if err != nil {
 		span.RecordError(err)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entities})
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entities, "next": next})
+}
+
+// AliasRequest is the request body of PutAlias
+type AliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// PutAlias attaches an alias to the requester once its DNS record is verified.
+// an alias not verified yet is answered with 202 and the status of the record
+func (h handler) PutAlias(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.PutAlias")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	var request AliasRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request"})
+	}
+
+	status, err := h.service.AttachAlias(ctx, requester, request.Alias)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	code := http.StatusOK
+	if status.Status != core.AliasStatusVerified {
+		code = http.StatusAccepted
+	}
+	return c.JSON(code, echo.Map{"status": "ok", "content": status})
+}
+
+// GetAlias verifies the DNS record of the alias of the requester again
+func (h handler) GetAlias(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.GetAlias")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	status, err := h.service.GetAliasStatus(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": status})
+}
+
+// DeleteAlias removes the alias of the requester
+func (h handler) DeleteAlias(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.DeleteAlias")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	err := h.service.DetachAlias(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+// GetTagTaxonomy returns the tags the admin may give to entities
+func (h handler) GetTagTaxonomy(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": h.service.TagTaxonomy()})
+}
+
+// ListTags returns the live tags of an entity
+func (h handler) ListTags(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.ListTags")
+	defer span.End()
+
+	tags, err := h.service.ListTags(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": tags})
+}
+
+// TagRequest is the request body of PutTag. the tag is permanent without expiresAt
+type TagRequest struct {
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// PutTag gives a tag to an entity
+func (h handler) PutTag(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.PutTag")
+	defer span.End()
+
+	var request TagRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"status": "error", "message": "invalid request"})
+	}
+
+	author, _ := ctx.Value(core.RequesterIdCtxKey).(string)
+	tag, err := h.service.SetTag(ctx, core.EntityTag{
+		EntityID:  c.Param("id"),
+		Name:      c.Param("name"),
+		Value:     request.Value,
+		ExpiresAt: request.ExpiresAt,
+		Author:    author,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": tag})
+}
+
+// DeleteTag removes a tag from an entity
+func (h handler) DeleteTag(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.DeleteTag")
+	defer span.End()
+
+	err := h.service.RemoveTag(ctx, c.Param("id"), c.Param("name"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+// attachBadges attaches the live badges to a local entity, so that whoever reads it can check them
+func (h handler) attachBadges(ctx context.Context, entity *core.Entity) {
+	if entity.Domain != h.webfinger.FQDN {
+		return
+	}
+	badges, err := h.service.ListBadges(ctx, entity.ID)
+	if err != nil {
+		logging.Warn(ctx, "failed to list badges", slog.String("module", "entity"), slog.String("error", err.Error()))
+		return
+	}
+	entity.Badges = badges
+}
+
+// ListBadges returns the live badges of an entity
+func (h handler) ListBadges(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.ListBadges")
+	defer span.End()
+
+	badges, err := h.service.ListBadges(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": badges})
+}
+
+// BadgeRequest is the request body of PutBadge. body is signed into the badge as it is,
+// and the badge is permanent without expiresAt
+type BadgeRequest struct {
+	Body      any        `json:"body"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// PutBadge issues a badge to a local entity
+func (h handler) PutBadge(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.PutBadge")
+	defer span.End()
+
+	var request BadgeRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"status": "error", "message": "invalid request"})
+	}
+
+	badge, err := h.service.IssueBadge(ctx, c.Param("id"), c.Param("name"), request.Body, request.ExpiresAt)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": badge})
+}
+
+// DeleteBadge revokes a badge of an entity
+func (h handler) DeleteBadge(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.DeleteBadge")
+	defer span.End()
+
+	err := h.service.RevokeBadge(ctx, c.Param("id"), c.Param("name"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
 }
@@ -2,14 +2,19 @@ package entity
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
 )
 
 // Repository is the interface for host repository
@@ -17,26 +22,38 @@ type Repository interface {
 	Get(ctx context.Context, key string) (core.Entity, error)
 	GetByAlias(ctx context.Context, alias string) (core.Entity, error)
 	SetAlias(ctx context.Context, id, alias string) error
+	UnsetAlias(ctx context.Context, id string) error
 	GetMeta(ctx context.Context, key string) (core.EntityMeta, error)
 	Upsert(ctx context.Context, entity core.Entity) (core.Entity, error)
 	UpsertWithMeta(ctx context.Context, entity core.Entity, meta core.EntityMeta) (core.Entity, core.EntityMeta, error)
 	UpdateScore(ctx context.Context, id string, score int) error
-	UpdateTag(ctx context.Context, id, tag string) error
+	OverrideScore(ctx context.Context, id string, score int, fixed bool) error
+	ListTags(ctx context.Context, id string) ([]core.EntityTag, error)
+	SetTag(ctx context.Context, tag core.EntityTag) (core.EntityTag, error)
+	RemoveTag(ctx context.Context, id, name string) error
+	ReplaceTags(ctx context.Context, id string, tags []core.EntityTag) error
+	ExpireTags(ctx context.Context, now time.Time) (int64, error)
+	ListBadges(ctx context.Context, id string, now time.Time) ([]core.Badge, error)
+	SetBadge(ctx context.Context, badge core.Badge) (core.Badge, error)
+	RemoveBadge(ctx context.Context, id, name string) error
 	SetTombstone(ctx context.Context, id, document, signature string) error
-	GetList(ctx context.Context) ([]core.Entity, error)
+	GetList(ctx context.Context, query core.EntityListQuery) ([]core.Entity, string, error)
 	Delete(ctx context.Context, key string) error
 	DeleteMeta(ctx context.Context, ccid string) error
 	Count(ctx context.Context) (int64, error)
+	SetLastFetched(ctx context.Context, id string, fetched time.Time) error
+	StaleDomains(ctx context.Context, before time.Time, exclude string) ([]string, error)
+	ListStale(ctx context.Context, domain string, before time.Time, limit int) ([]core.Entity, error)
 }
 
 type repository struct {
 	db     *gorm.DB
-	mc     *memcache.Client
+	mc     cache.Cache
 	schema core.SchemaService
 }
 
 // NewRepository creates a new host repository
-func NewRepository(db *gorm.DB, mc *memcache.Client, schema core.SchemaService) Repository {
+func NewRepository(db *gorm.DB, mc cache.Cache, schema core.SchemaService) Repository {
 	return &repository{db, mc, schema}
 }
 
@@ -82,8 +99,8 @@ func (r *repository) SetTombstone(ctx context.Context, id, document, signature s
 	ctx, span := tracer.Start(ctx, "Entity.Repository.SetTombstone")
 	defer span.End()
 
-	err := r.db.Model(&core.Entity{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"tombstone_payload":   document,
+	err := r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"tombstone_document":  document,
 		"tombstone_signature": signature,
 	}).Error
 
@@ -105,6 +122,15 @@ func (r *repository) Get(ctx context.Context, key string) (core.Entity, error) {
 		return core.Entity{}, err
 	}
 
+	// the joined tags tell whether there are any to load
+	if entity.Tag != "" {
+		entity.Tags, err = liveTags(r.db.WithContext(ctx), entity.ID)
+		if err != nil {
+			span.RecordError(err)
+			return core.Entity{}, err
+		}
+	}
+
 	return entity, nil
 }
 
@@ -132,6 +158,13 @@ func (r *repository) SetAlias(ctx context.Context, id, alias string) error {
 	return r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).Update("alias", alias).Error
 }
 
+func (r *repository) UnsetAlias(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.UnsetAlias")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).Update("alias", nil).Error
+}
+
 func (r *repository) GetMeta(ctx context.Context, key string) (core.EntityMeta, error) {
 	ctx, span := tracer.Start(ctx, "Entity.Repository.GetMeta")
 	defer span.End()
@@ -202,14 +235,91 @@ func (r *repository) UpsertWithMeta(ctx context.Context, entity core.Entity, met
 	return entity, meta, nil
 }
 
-// GetList returns all entities
-func (r *repository) GetList(ctx context.Context) ([]core.Entity, error) {
+// GetList returns entities matching the query and the cursor of the next page
+func (r *repository) GetList(ctx context.Context, query core.EntityListQuery) ([]core.Entity, string, error) {
 	ctx, span := tracer.Start(ctx, "Entity.Repository.GetList")
 	defer span.End()
 
+	column, desc, err := parseEntityOrder(query.Order)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = entityListDefaultLimit
+	}
+	if limit > entityListMaxLimit {
+		limit = entityListMaxLimit
+	}
+
+	q := r.db.WithContext(ctx).Model(&core.Entity{})
+
+	if query.Domain != "" {
+		q = q.Where("domain = ?", query.Domain)
+	}
+	if query.Tag != "" {
+		// tag is a comma separated list of key or key:value
+		q = q.Where("tag ~ ?", "(^|,)"+regexp.QuoteMeta(query.Tag)+"(:[^,]*)?(,|$)")
+	}
+	if query.MinScore != nil {
+		q = q.Where("score >= ?", *query.MinScore)
+	}
+	if query.MaxScore != nil {
+		q = q.Where("score <= ?", *query.MaxScore)
+	}
+	if query.Since != nil {
+		q = q.Where("c_date >= ?", *query.Since)
+	}
+	if query.Until != nil {
+		q = q.Where("c_date < ?", *query.Until)
+	}
+
+	op := ">"
+	direction := "ASC"
+	if desc {
+		op = "<"
+		direction = "DESC"
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeEntityCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		var value any
+		if column == "c_date" {
+			value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+		} else {
+			value, err = strconv.Atoi(cursor.Value)
+		}
+		if err != nil {
+			return nil, "", errors.Wrap(err, "invalid cursor")
+		}
+		q = q.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, op), value, cursor.ID)
+	}
+
 	var entities []core.Entity
-	err := r.db.WithContext(ctx).Model(&core.Entity{}).Find(&entities).Error
-	return entities, err
+	err = q.Order(column + " " + direction).Order("id " + direction).Limit(limit + 1).Find(&entities).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, "", err
+	}
+
+	next := ""
+	if len(entities) > limit {
+		entities = entities[:limit]
+		last := entities[limit-1]
+		cursor := entityCursor{ID: last.ID}
+		if column == "c_date" {
+			cursor.Value = last.CDate.Format(time.RFC3339Nano)
+		} else {
+			cursor.Value = strconv.Itoa(last.Score)
+		}
+		next = cursor.encode()
+	}
+
+	return entities, next, nil
 }
 
 // Delete deletes a entity
@@ -217,7 +327,13 @@ func (r *repository) Delete(ctx context.Context, id string) error {
 	ctx, span := tracer.Start(ctx, "Entity.Repository.Delete")
 	defer span.End()
 
-	err := r.db.WithContext(ctx).Delete(&core.Entity{}, "id = ?", id).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&core.EntityTag{}, "entity_id = ?", id).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&core.Entity{}, "id = ?", id).Error
+	})
 
 	if err == nil {
 		r.mc.Decrement("entity_count", 1)
@@ -233,9 +349,231 @@ func (r *repository) UpdateScore(ctx context.Context, id string, score int) erro
 	return r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).Update("score", score).Error
 }
 
-func (r *repository) UpdateTag(ctx context.Context, id, tag string) error {
-	ctx, span := tracer.Start(ctx, "Entity.Repository.UpdateTag")
+func (r *repository) OverrideScore(ctx context.Context, id string, score int, fixed bool) error {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.OverrideScore")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).Updates(map[string]any{
+		"score":          score,
+		"is_score_fixed": fixed,
+	}).Error
+}
+
+// ListTags returns the live tags of the entity
+func (r *repository) ListTags(ctx context.Context, id string) ([]core.EntityTag, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.ListTags")
+	defer span.End()
+
+	tags, err := liveTags(r.db.WithContext(ctx), id)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return tags, err
+}
+
+// SetTag gives the tag to the entity, replacing its value and expiry if the entity already has it
+func (r *repository) SetTag(ctx context.Context, tag core.EntityTag) (core.EntityTag, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.SetTag")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at", "author"}),
+		}).Create(&tag).Error
+		if err != nil {
+			return err
+		}
+		return joinTags(tx, tag.EntityID)
+	})
+	if err != nil {
+		span.RecordError(err)
+		return core.EntityTag{}, err
+	}
+
+	return tag, nil
+}
+
+// RemoveTag removes the tag from the entity
+func (r *repository) RemoveTag(ctx context.Context, id, name string) error {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.RemoveTag")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("entity_id = ? AND name = ?", id, name).Delete(&core.EntityTag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return core.NewErrorNotFound()
+		}
+		return joinTags(tx, id)
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// ReplaceTags replaces all the tags of the entity
+func (r *repository) ReplaceTags(ctx context.Context, id string, tags []core.EntityTag) error {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.ReplaceTags")
 	defer span.End()
 
-	return r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).Update("tag", tag).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("entity_id = ?", id).Delete(&core.EntityTag{}).Error
+		if err != nil {
+			return err
+		}
+		if len(tags) > 0 {
+			err = tx.Create(&tags).Error
+			if err != nil {
+				return err
+			}
+		}
+		return joinTags(tx, id)
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// ExpireTags removes the tags expired by now and returns how many were removed
+func (r *repository) ExpireTags(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.ExpireTags")
+	defer span.End()
+
+	var expired []core.EntityTag
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "entity_id"}}}).
+			Where("expires_at <= ?", now).
+			Delete(&expired).Error
+		if err != nil {
+			return err
+		}
+
+		joined := make(map[string]bool)
+		for _, tag := range expired {
+			if joined[tag.EntityID] {
+				continue
+			}
+			joined[tag.EntityID] = true
+			err := joinTags(tx, tag.EntityID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	return int64(len(expired)), nil
+}
+
+// ListBadges returns the badges of the entity not expired by now
+func (r *repository) ListBadges(ctx context.Context, id string, now time.Time) ([]core.Badge, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.ListBadges")
+	defer span.End()
+
+	badges := make([]core.Badge, 0)
+	err := r.db.WithContext(ctx).
+		Where("entity_id = ? AND (expires_at IS NULL OR expires_at > ?)", id, now).
+		Order("name").
+		Find(&badges).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return badges, err
+}
+
+// SetBadge stores the badge, replacing the one of the same name the entity already has
+func (r *repository) SetBadge(ctx context.Context, badge core.Badge) (core.Badge, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.SetBadge")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "entity_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"document", "signature", "expires_at", "c_date"}),
+	}).Create(&badge).Error
+	if err != nil {
+		span.RecordError(err)
+		return core.Badge{}, err
+	}
+
+	return badge, nil
+}
+
+// RemoveBadge removes the badge from the entity
+func (r *repository) RemoveBadge(ctx context.Context, id, name string) error {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.RemoveBadge")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("entity_id = ? AND name = ?", id, name).Delete(&core.Badge{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
+
+func liveTags(db *gorm.DB, id string) ([]core.EntityTag, error) {
+	tags := make([]core.EntityTag, 0)
+	err := db.Where("entity_id = ? AND (expires_at IS NULL OR expires_at > ?)", id, time.Now()).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+// joinTags writes the live tags of the entity to Entity.Tag
+func joinTags(tx *gorm.DB, id string) error {
+	tags, err := liveTags(tx, id)
+	if err != nil {
+		return err
+	}
+	return tx.Model(&core.Entity{}).Where("id = ?", id).Update("tag", core.JoinEntityTags(tags)).Error
+}
+
+func (r *repository) SetLastFetched(ctx context.Context, id string, fetched time.Time) error {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.SetLastFetched")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&core.Entity{}).Where("id = ?", id).UpdateColumn("last_fetched", fetched).Error
+}
+
+// StaleDomains returns the domains having live entities not fetched since before, other than exclude
+func (r *repository) StaleDomains(ctx context.Context, before time.Time, exclude string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.StaleDomains")
+	defer span.End()
+
+	var domains []string
+	err := r.db.WithContext(ctx).Model(&core.Entity{}).
+		Where("domain <> ? AND tombstone_document IS NULL AND (last_fetched IS NULL OR last_fetched < ?)", exclude, before).
+		Distinct().
+		Pluck("domain", &domains).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return domains, err
+}
+
+// ListStale returns the live entities of the domain not fetched since before, least recently fetched first
+func (r *repository) ListStale(ctx context.Context, domain string, before time.Time, limit int) ([]core.Entity, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Repository.ListStale")
+	defer span.End()
+
+	var entities []core.Entity
+	err := r.db.WithContext(ctx).
+		Where("domain = ? AND tombstone_document IS NULL AND (last_fetched IS NULL OR last_fetched < ?)", domain, before).
+		Order("last_fetched ASC NULLS FIRST").
+		Limit(limit).
+		Find(&entities).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return entities, err
 }
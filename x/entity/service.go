@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"log/slog"
 	"net"
 	"strconv"
 	"strings"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 	"github.com/totegamma/concurrent/x/jwt"
 )
 
@@ -80,9 +83,86 @@ func (s *service) PullEntityFromRemote(ctx context.Context, id, remote string) (
 		return core.Entity{}, err
 	}
 
+	// the entity moved to this domain is no longer pulled from the remote
+	if created.Domain == s.config.FQDN {
+		return created, nil
+	}
+
+	fetched := time.Now()
+	err = s.repository.SetLastFetched(ctx, id, fetched)
+	if err != nil {
+		span.RecordError(err)
+		return core.Entity{}, err
+	}
+	created.LastFetched = &fetched
+
 	return created, nil
 }
 
+// Refresh pulls a known remote entity from its domain again, bypassing the response cache
+func (s *service) Refresh(ctx context.Context, id string) (core.Entity, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.Refresh")
+	defer span.End()
+
+	entity, err := s.repository.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return core.Entity{}, err
+	}
+	if entity.Domain == s.config.FQDN {
+		return core.Entity{}, core.NewErrorInvalidArgument("local entities are not refreshed")
+	}
+
+	s.client.InvalidateEntity(entity.Domain, id)
+	return s.PullEntityFromRemote(ctx, id, entity.Domain)
+}
+
+// refreshBatchSize bounds the entities refreshed per domain in a run
+const refreshBatchSize = 100
+
+// RefreshStale refreshes the remote entities not fetched within the ttl and returns how many were refreshed.
+// a domain failing to answer is left for the next run
+func (s *service) RefreshStale(ctx context.Context, ttl time.Duration) (int, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.RefreshStale")
+	defer span.End()
+
+	before := time.Now().Add(-ttl)
+	domains, err := s.repository.StaleDomains(ctx, before, s.config.FQDN)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	refreshed := 0
+	for _, domain := range domains {
+		entities, err := s.repository.ListStale(ctx, domain, before, refreshBatchSize)
+		if err != nil {
+			span.RecordError(err)
+			return refreshed, err
+		}
+
+		for _, entity := range entities {
+			if ctx.Err() != nil {
+				return refreshed, ctx.Err()
+			}
+
+			s.client.InvalidateEntity(domain, entity.ID)
+			_, err := s.PullEntityFromRemote(ctx, entity.ID, domain)
+			if err != nil {
+				span.RecordError(err)
+				if errors.Is(err, core.ErrorRemoteUnavailable{}) {
+					break
+				}
+				continue
+			}
+			refreshed++
+		}
+	}
+
+	span.SetAttributes(attribute.Int("refreshed", refreshed))
+	return refreshed, nil
+}
+
 // Total returns the count number of entities
 func (s *service) Count(ctx context.Context) (int64, error) {
 	ctx, span := tracer.Start(ctx, "Entity.Service.Count")
@@ -121,7 +201,7 @@ func (s *service) Affiliation(ctx context.Context, mode core.CommitMode, documen
 		if s.config.SiteKey != "" {
 			captchaVerified, ok := ctx.Value(core.CaptchaVerifiedKey).(bool)
 			if !ok || !captchaVerified {
-				return core.Entity{}, errors.New("Captcha verification failed")
+				return core.Entity{}, fmt.Errorf("%w: captcha verification failed", core.NewErrorUnauthorized())
 			}
 		}
 
@@ -160,7 +240,7 @@ func (s *service) Affiliation(ctx context.Context, mode core.CommitMode, documen
 			return entity, nil
 		case "invite":
 			if opts.Invitation == "" {
-				return core.Entity{}, fmt.Errorf("invitation code is required")
+				return core.Entity{}, core.NewErrorInvalidArgument("invitation code is required")
 			}
 
 			claims, err := jwt.Validate(opts.Invitation)
@@ -169,7 +249,7 @@ func (s *service) Affiliation(ctx context.Context, mode core.CommitMode, documen
 				return core.Entity{}, err
 			}
 			if claims.Subject != "CONCRNT_INVITE" {
-				return core.Entity{}, fmt.Errorf("invalid invitation code")
+				return core.Entity{}, core.NewErrorInvalidArgument("invalid invitation code")
 			}
 
 			ok, err := s.jwtService.CheckJTI(ctx, claims.JWTID)
@@ -192,7 +272,7 @@ func (s *service) Affiliation(ctx context.Context, mode core.CommitMode, documen
 
 			if core.IsCSID(inviterID) {
 				if inviterID != s.config.CSID {
-					return core.Entity{}, fmt.Errorf("inviter is not allowed to invite")
+					return core.Entity{}, fmt.Errorf("%w: inviter is not allowed to invite", core.NewErrorPermissionDenied())
 				}
 			} else {
 				inviter, err := s.repository.Get(ctx, inviterID)
@@ -212,7 +292,7 @@ func (s *service) Affiliation(ctx context.Context, mode core.CommitMode, documen
 				}
 
 				if policyResult == core.PolicyEvalResultNever || policyResult == core.PolicyEvalResultDeny {
-					return core.Entity{}, fmt.Errorf("inviter is not allowed to invite")
+					return core.Entity{}, fmt.Errorf("%w: inviter is not allowed to invite", core.NewErrorPermissionDenied())
 				}
 			}
 
@@ -268,6 +348,7 @@ func (s *service) Affiliation(ctx context.Context, mode core.CommitMode, documen
 			newEntity.Tag = existence.Tag
 			newEntity.IsScoreFixed = existence.IsScoreFixed
 			newEntity.Score = existence.Score
+			newEntity.LastFetched = existence.LastFetched
 		}
 
 		created, err := s.repository.Upsert(ctx, newEntity)
@@ -298,7 +379,7 @@ func (s *service) Tombstone(ctx context.Context, mode core.CommitMode, document,
 		return core.Entity{}, err
 	}
 
-	return core.Entity{}, nil
+	return s.repository.Get(ctx, doc.Signer)
 }
 
 // Get returns entity by ccid
@@ -347,7 +428,53 @@ func (s *service) GetByAlias(ctx context.Context, alias string) (core.Entity, er
 		return entity, nil
 	}
 
-	txtrecords, _ := net.LookupTXT("_concrnt." + alias)
+	record := lookupAlias(alias)
+	status := verifyAlias(alias, record, "")
+	if status.Status != core.AliasStatusVerified {
+		return core.Entity{}, errors.New(status.Detail)
+	}
+	ccid := record["ccid"]
+
+	entity, err = s.Get(ctx, ccid)
+	if err == nil { // local entity
+		err = s.repository.SetAlias(ctx, ccid, alias)
+		if err != nil {
+			span.RecordError(err)
+			return core.Entity{}, err
+		}
+		entity.Alias = &alias
+		return entity, nil
+	}
+
+	// remote entity
+	entity, err = s.PullEntityFromRemote(ctx, ccid, record["hint"])
+	if err != nil {
+		span.RecordError(err)
+		return core.Entity{}, err
+	}
+
+	err = s.repository.SetAlias(ctx, ccid, alias)
+	if err != nil {
+		span.RecordError(err)
+		return core.Entity{}, err
+	}
+
+	entity.Alias = &alias
+
+	return entity, nil
+}
+
+// lookupTXT is replaced in tests
+var lookupTXT = net.LookupTXT
+
+// aliasRecordName is where the TXT record proving an alias is published
+func aliasRecordName(alias string) string {
+	return "_concrnt." + alias
+}
+
+// lookupAlias reads the key=value pairs of the TXT record of the alias
+func lookupAlias(alias string) map[string]string {
+	txtrecords, _ := lookupTXT(aliasRecordName(alias))
 
 	var kv = make(map[string]string)
 
@@ -358,61 +485,130 @@ func (s *service) GetByAlias(ctx context.Context, alias string) (core.Entity, er
 		}
 	}
 
-	ccid, ok := kv["ccid"]
+	return kv
+}
+
+// verifyAlias checks that the record proves the alias belongs to the ccid. an empty ccid accepts any owner
+func verifyAlias(alias string, record map[string]string, ccid string) core.AliasStatus {
+	status := core.AliasStatus{Alias: alias, Record: aliasRecordName(alias)}
+
+	owner, ok := record["ccid"]
 	if !ok {
-		return core.Entity{}, errors.New("ccid not found")
+		status.Status = core.AliasStatusMissingRecord
+		status.Detail = "ccid not found"
+		return status
+	}
+	if ccid != "" && owner != ccid {
+		status.Status = core.AliasStatusCCIDMismatch
+		status.Detail = fmt.Sprintf("the record points to %s", owner)
+		return status
 	}
 
-	sig, ok := kv["sig"]
+	sig, ok := record["sig"]
 	if !ok {
-		return core.Entity{}, errors.New("sig not found")
+		status.Status = core.AliasStatusMissingRecord
+		status.Detail = "sig not found"
+		return status
 	}
 
 	signatureBytes, err := hex.DecodeString(sig)
+	if err == nil {
+		err = core.VerifySignature([]byte(alias), signatureBytes, owner)
+	}
 	if err != nil {
-		return core.Entity{}, err
+		status.Status = core.AliasStatusInvalidSignature
+		status.Detail = err.Error()
+		return status
+	}
+
+	status.Status = core.AliasStatusVerified
+	return status
+}
+
+// normalizeAlias lowercases the alias and checks it is a domain name
+func normalizeAlias(alias string) (string, error) {
+	alias = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(alias)), ".")
+	if !strings.Contains(alias, ".") || strings.ContainsAny(alias, " /:@") || len(alias) > 253 {
+		return "", core.NewErrorInvalidArgument("alias must be a domain name")
 	}
+	return alias, nil
+}
 
-	err = core.VerifySignature([]byte(alias), signatureBytes, ccid)
+// AttachAlias sets the alias of a local entity once its DNS record is verified.
+// until then the status tells what is missing and the alias is not stored
+func (s *service) AttachAlias(ctx context.Context, ccid, alias string) (core.AliasStatus, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.AttachAlias")
+	defer span.End()
+
+	alias, err := normalizeAlias(alias)
 	if err != nil {
-		return core.Entity{}, err
+		return core.AliasStatus{}, err
 	}
 
-	entity, err = s.Get(ctx, ccid)
-	if err == nil { // local entity
-		err = s.repository.SetAlias(ctx, ccid, alias)
+	status := verifyAlias(alias, lookupAlias(alias), ccid)
+	if status.Status != core.AliasStatusVerified {
+		return status, nil
+	}
+
+	// the record moved to this entity, so whoever held the alias before loses it
+	holder, err := s.repository.GetByAlias(ctx, alias)
+	if err == nil && holder.ID != ccid {
+		err = s.repository.UnsetAlias(ctx, holder.ID)
 		if err != nil {
 			span.RecordError(err)
-			return core.Entity{}, err
+			return core.AliasStatus{}, err
 		}
-		entity.Alias = &alias
-		return entity, nil
+		s.client.InvalidateEntity(holder.Domain, holder.ID)
 	}
 
-	// remote entity
-	entity, err = s.PullEntityFromRemote(ctx, ccid, kv["hint"])
+	err = s.repository.SetAlias(ctx, ccid, alias)
 	if err != nil {
 		span.RecordError(err)
-		return core.Entity{}, err
+		return core.AliasStatus{}, err
 	}
+	s.client.InvalidateEntity(s.config.FQDN, ccid)
 
-	err = s.repository.SetAlias(ctx, ccid, alias)
+	return status, nil
+}
+
+// GetAliasStatus verifies the DNS record of the alias of the entity again
+func (s *service) GetAliasStatus(ctx context.Context, ccid string) (core.AliasStatus, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.GetAliasStatus")
+	defer span.End()
+
+	entity, err := s.repository.Get(ctx, ccid)
 	if err != nil {
 		span.RecordError(err)
-		return core.Entity{}, err
+		return core.AliasStatus{}, err
+	}
+	if entity.Alias == nil {
+		return core.AliasStatus{}, core.NewErrorNotFound()
 	}
 
-	entity.Alias = &alias
+	return verifyAlias(*entity.Alias, lookupAlias(*entity.Alias), ccid), nil
+}
 
-	return entity, nil
+// DetachAlias removes the alias of the entity
+func (s *service) DetachAlias(ctx context.Context, ccid string) error {
+	ctx, span := tracer.Start(ctx, "Entity.Service.DetachAlias")
+	defer span.End()
+
+	err := s.repository.UnsetAlias(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	s.client.InvalidateEntity(s.config.FQDN, ccid)
+
+	return nil
 }
 
-// List returns all entities
-func (s *service) List(ctx context.Context) ([]core.Entity, error) {
+// List returns entities matching the query and the cursor of the next page
+func (s *service) List(ctx context.Context, query core.EntityListQuery) ([]core.Entity, string, error) {
 	ctx, span := tracer.Start(ctx, "Entity.Service.List")
 	defer span.End()
 
-	return s.repository.GetList(ctx)
+	return s.repository.GetList(ctx, query)
 }
 
 // IsUserExists returns true if user exists
@@ -435,12 +631,94 @@ func (s *service) UpdateScore(ctx context.Context, id string, score int) error {
 	return s.repository.UpdateScore(ctx, id, score)
 }
 
-// UpdateTag updates entity tag
+// OverrideScore sets entity score. a fixed score is kept as is by the scoring rules and passports
+func (s *service) OverrideScore(ctx context.Context, id string, score int, fixed bool) error {
+	ctx, span := tracer.Start(ctx, "Entity.Service.OverrideScore")
+	defer span.End()
+
+	return s.repository.OverrideScore(ctx, id, score, fixed)
+}
+
+// UpdateTag replaces all the tags of the entity with the joined ones, as in Entity.Tag. the tags are permanent
 func (s *service) UpdateTag(ctx context.Context, id, tag string) error {
 	ctx, span := tracer.Start(ctx, "Entity.Service.UpdateTag")
 	defer span.End()
 
-	return s.repository.UpdateTag(ctx, id, tag)
+	author, _ := ctx.Value(core.RequesterIdCtxKey).(string)
+	now := time.Now()
+
+	tags := make([]core.EntityTag, 0)
+	for name, value := range core.ParseTags(tag).Body {
+		if name == "" {
+			continue
+		}
+		entityTag := core.EntityTag{EntityID: id, Name: name, Value: value, Author: author}
+		err := s.config.EntityTags.Validate(entityTag, now)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, entityTag)
+	}
+
+	return s.repository.ReplaceTags(ctx, id, tags)
+}
+
+// TagTaxonomy returns the tags the admin may give to entities
+func (s *service) TagTaxonomy() core.TagTaxonomy {
+	return s.config.EntityTags.All()
+}
+
+// ListTags returns the live tags of the entity
+func (s *service) ListTags(ctx context.Context, id string) ([]core.EntityTag, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.ListTags")
+	defer span.End()
+
+	return s.repository.ListTags(ctx, id)
+}
+
+// SetTag gives the tag to the entity, if the taxonomy allows it
+func (s *service) SetTag(ctx context.Context, tag core.EntityTag) (core.EntityTag, error) {
+	ctx, span := tracer.Start(ctx, "Entity.Service.SetTag")
+	defer span.End()
+
+	err := s.config.EntityTags.Validate(tag, time.Now())
+	if err != nil {
+		return core.EntityTag{}, err
+	}
+
+	_, err = s.repository.Get(ctx, tag.EntityID)
+	if err != nil {
+		span.RecordError(err)
+		return core.EntityTag{}, err
+	}
+
+	return s.repository.SetTag(ctx, tag)
+}
+
+// RemoveTag removes the tag from the entity
+func (s *service) RemoveTag(ctx context.Context, id, name string) error {
+	ctx, span := tracer.Start(ctx, "Entity.Service.RemoveTag")
+	defer span.End()
+
+	return s.repository.RemoveTag(ctx, id, name)
+}
+
+// ExpireTags removes the expired tags. they are no longer honored by the policies once expired,
+// but Entity.Tag keeps them until this runs
+func (s *service) ExpireTags(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Entity.Service.ExpireTags")
+	defer span.End()
+
+	expired, err := s.repository.ExpireTags(ctx, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if expired > 0 {
+		logging.Info(ctx, "entity tags expired", slog.String("module", "entity"), slog.Int64("count", expired))
+	}
+
+	return nil
 }
 
 // Delete deletes entity
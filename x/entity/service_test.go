@@ -0,0 +1,177 @@
+package entity
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/client"
+	"github.com/totegamma/concurrent/client/mock"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/fixtures"
+)
+
+// memoryRepository implements the methods of Repository used by refreshing
+type memoryRepository struct {
+	Repository
+	entities map[string]core.Entity
+}
+
+func (r *memoryRepository) Get(ctx context.Context, key string) (core.Entity, error) {
+	entity, ok := r.entities[key]
+	if !ok {
+		return core.Entity{}, core.NewErrorNotFound()
+	}
+	return entity, nil
+}
+
+func (r *memoryRepository) Upsert(ctx context.Context, entity core.Entity) (core.Entity, error) {
+	r.entities[entity.ID] = entity
+	return entity, nil
+}
+
+func (r *memoryRepository) SetLastFetched(ctx context.Context, id string, fetched time.Time) error {
+	entity := r.entities[id]
+	entity.LastFetched = &fetched
+	r.entities[id] = entity
+	return nil
+}
+
+func (r *memoryRepository) StaleDomains(ctx context.Context, before time.Time, exclude string) ([]string, error) {
+	return []string{"a.example.com", "b.example.com"}, nil
+}
+
+func (r *memoryRepository) ListStale(ctx context.Context, domain string, before time.Time, limit int) ([]core.Entity, error) {
+	var stale []core.Entity
+	for _, entity := range r.entities {
+		if entity.Domain == domain {
+			stale = append(stale, entity)
+		}
+	}
+	return stale, nil
+}
+
+func newRemoteEntity(t *testing.T, domain, name string) core.Entity {
+	keypair := fixtures.RandomKeypair(t)
+	document, signature := fixtures.Sign(t, keypair, fixtures.Affiliation(keypair, domain))
+
+	return core.Entity{
+		ID:                   keypair.CCID,
+		Domain:               domain,
+		Tag:                  name,
+		AffiliationDocument:  document,
+		AffiliationSignature: signature,
+	}
+}
+
+func TestRefreshStale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alice := newRemoteEntity(t, "a.example.com", "alice")
+	bob := newRemoteEntity(t, "b.example.com", "bob")
+	carol := newRemoteEntity(t, "b.example.com", "carol")
+
+	repo := &memoryRepository{entities: map[string]core.Entity{alice.ID: alice, bob.ID: bob, carol.ID: carol}}
+	mockClient := mock_client.NewMockClient(ctrl)
+	s := &service{repository: repo, client: mockClient, config: core.Config{FQDN: "local.example.com"}}
+
+	mockClient.EXPECT().InvalidateEntity(gomock.Any(), gomock.Any()).AnyTimes()
+	mockClient.EXPECT().GetEntity(gomock.Any(), "a.example.com", alice.ID, gomock.Any()).Return(alice, nil)
+	// the first failure of an unavailable domain skips the rest of its entities
+	mockClient.EXPECT().GetEntity(gomock.Any(), "b.example.com", gomock.Any(), gomock.Any()).
+		Return(core.Entity{}, core.NewErrorRemoteUnavailable("b.example.com", client.ErrDomainOffline)).Times(1)
+
+	refreshed, err := s.RefreshStale(context.Background(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, refreshed)
+	assert.NotNil(t, repo.entities[alice.ID].LastFetched)
+	assert.Nil(t, repo.entities[bob.ID].LastFetched)
+
+	_, err = s.Refresh(context.Background(), "unknown")
+	assert.True(t, errors.Is(err, core.ErrorNotFound{}))
+}
+
+func (r *memoryRepository) GetByAlias(ctx context.Context, alias string) (core.Entity, error) {
+	for _, entity := range r.entities {
+		if entity.Alias != nil && *entity.Alias == alias {
+			return entity, nil
+		}
+	}
+	return core.Entity{}, core.NewErrorNotFound()
+}
+
+func (r *memoryRepository) SetAlias(ctx context.Context, id, alias string) error {
+	entity := r.entities[id]
+	entity.Alias = &alias
+	r.entities[id] = entity
+	return nil
+}
+
+func (r *memoryRepository) UnsetAlias(ctx context.Context, id string) error {
+	entity := r.entities[id]
+	entity.Alias = nil
+	r.entities[id] = entity
+	return nil
+}
+
+func TestAttachAlias(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	keypair := fixtures.RandomKeypair(t)
+	ccid := keypair.CCID
+	signature, err := core.SignBytes([]byte("alice.example.com"), keypair.PrivateKey)
+	assert.NoError(t, err)
+
+	stale := "alice.example.com"
+	previous := core.Entity{ID: "con1previous", Domain: "local.example.com", Alias: &stale}
+	repo := &memoryRepository{entities: map[string]core.Entity{
+		ccid:        {ID: ccid, Domain: "local.example.com"},
+		previous.ID: previous,
+	}}
+	mockClient := mock_client.NewMockClient(ctrl)
+	mockClient.EXPECT().InvalidateEntity(gomock.Any(), gomock.Any()).AnyTimes()
+	s := &service{repository: repo, client: mockClient, config: core.Config{FQDN: "local.example.com"}}
+
+	records := map[string][]string{}
+	lookupTXT = func(name string) ([]string, error) {
+		return records[name], nil
+	}
+	defer func() { lookupTXT = net.LookupTXT }()
+
+	status, err := s.AttachAlias(context.Background(), ccid, "Alice.Example.com.")
+	assert.NoError(t, err)
+	assert.Equal(t, core.AliasStatusMissingRecord, status.Status)
+	assert.Equal(t, "_concrnt.alice.example.com", status.Record)
+	assert.Nil(t, repo.entities[ccid].Alias)
+
+	records["_concrnt.alice.example.com"] = []string{"ccid=" + previous.ID, "sig=" + hex.EncodeToString(signature)}
+	status, err = s.AttachAlias(context.Background(), ccid, "alice.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, core.AliasStatusCCIDMismatch, status.Status)
+
+	records["_concrnt.alice.example.com"] = []string{"ccid=" + ccid, "sig=" + hex.EncodeToString(signature)}
+	status, err = s.AttachAlias(context.Background(), ccid, "alice.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, core.AliasStatusVerified, status.Status)
+	assert.Equal(t, "alice.example.com", *repo.entities[ccid].Alias)
+	assert.Nil(t, repo.entities[previous.ID].Alias)
+
+	status, err = s.GetAliasStatus(context.Background(), ccid)
+	assert.NoError(t, err)
+	assert.Equal(t, core.AliasStatusVerified, status.Status)
+
+	assert.NoError(t, s.DetachAlias(context.Background(), ccid))
+	_, err = s.GetAliasStatus(context.Background(), ccid)
+	assert.True(t, errors.Is(err, core.ErrorNotFound{}))
+
+	_, err = s.AttachAlias(context.Background(), ccid, "localhost")
+	assert.True(t, errors.Is(err, core.ErrorInvalidArgument{}))
+}
@@ -0,0 +1,152 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	relConcrntEntity  = "https://concrnt.world/ns/entity"
+	relConcrntProfile = "https://concrnt.world/ns/profile"
+	relSelf           = "self"
+)
+
+// WebFingerConfig is the configuration of the webfinger endpoint
+type WebFingerConfig struct {
+	FQDN string
+	// ActorURL is the url template of the ActivityPub actor. {ccid} and {alias} are replaced.
+	// the actor link is omitted when empty.
+	ActorURL string
+}
+
+type webFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+type webFingerResponse struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []webFingerLink `json:"links"`
+}
+
+// parseWebFingerResource splits a resource into the account name and the host.
+// accepts acct:name@host, bare name@host and https://host/ urls, which name the alias host itself.
+func parseWebFingerResource(resource string) (string, string, error) {
+	if strings.HasPrefix(resource, "https://") || strings.HasPrefix(resource, "http://") {
+		u, err := url.Parse(resource)
+		if err != nil || u.Host == "" {
+			return "", "", fmt.Errorf("invalid resource")
+		}
+		return u.Hostname(), u.Hostname(), nil
+	}
+
+	resource = strings.TrimPrefix(resource, "acct:")
+	resource = strings.TrimPrefix(resource, "@")
+	name, host, ok := strings.Cut(resource, "@")
+	if !ok || name == "" || host == "" {
+		return "", "", fmt.Errorf("invalid resource")
+	}
+	return name, host, nil
+}
+
+// WebFinger resolves acct:alias@domain and acct:ccid@domain to a concurrent entity (RFC 7033)
+// query: resource, rel
+func (h handler) WebFinger(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Entity.Handler.WebFinger")
+	defer span.End()
+
+	resource := c.QueryParam("resource")
+	name, host, err := parseWebFingerResource(resource)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var entity core.Entity
+	switch {
+	case core.IsCCID(name):
+		if host != h.webfinger.FQDN {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "entity not found"})
+		}
+		entity, err = h.service.Get(ctx, name)
+	case name == host:
+		// https://alias/
+		entity, err = h.service.GetByAlias(ctx, name)
+	case host == h.webfinger.FQDN:
+		// acct:alias@domain. the alias is looked up in the alias table, then verified by its dns txt record
+		entity, err = h.service.GetByAlias(ctx, name)
+	default:
+		// acct:name@alias. the alias domain delegates webfinger to this server
+		entity, err = h.service.GetByAlias(ctx, host)
+	}
+	if err != nil {
+		// unverifiable aliases are reported as not found as well
+		if !errors.Is(err, core.ErrorNotFound{}) {
+			span.RecordError(err)
+		}
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "entity not found"})
+	}
+
+	if entity.Domain != h.webfinger.FQDN {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "entity not found"})
+	}
+
+	response := h.webFingerResponse(resource, entity, c.QueryParams()["rel"])
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/jrd+json")
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h handler) webFingerResponse(resource string, entity core.Entity, rels []string) webFingerResponse {
+	base := "https://" + h.webfinger.FQDN + "/api/v1"
+
+	alias := ""
+	if entity.Alias != nil {
+		alias = *entity.Alias
+	}
+
+	subject := resource
+	if !strings.Contains(subject, ":") {
+		subject = "acct:" + strings.TrimPrefix(subject, "@")
+	}
+
+	aliases := []string{"acct:" + entity.ID + "@" + h.webfinger.FQDN}
+	if alias != "" {
+		aliases = append(aliases, "acct:"+alias+"@"+h.webfinger.FQDN, "https://"+alias)
+	}
+
+	links := []webFingerLink{
+		{Rel: relConcrntEntity, Type: "application/json", Href: base + "/entity/" + entity.ID},
+		{Rel: relConcrntProfile, Type: "application/json", Href: base + "/profiles?author=" + url.QueryEscape(entity.ID)},
+	}
+	if h.webfinger.ActorURL != "" && (alias != "" || !strings.Contains(h.webfinger.ActorURL, "{alias}")) {
+		actor := strings.NewReplacer("{ccid}", entity.ID, "{alias}", alias).Replace(h.webfinger.ActorURL)
+		links = append(links, webFingerLink{Rel: relSelf, Type: "application/activity+json", Href: actor})
+	}
+
+	if len(rels) > 0 {
+		filtered := []webFingerLink{}
+		for _, link := range links {
+			for _, rel := range rels {
+				if link.Rel == rel {
+					filtered = append(filtered, link)
+					break
+				}
+			}
+		}
+		links = filtered
+	}
+
+	return webFingerResponse{
+		Subject: subject,
+		Aliases: aliases,
+		Links:   links,
+	}
+}
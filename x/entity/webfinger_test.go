@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestParseWebFingerResource(t *testing.T) {
+	name, host, err := parseWebFingerResource("acct:alice.example.com@concrnt.example")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice.example.com", name)
+	assert.Equal(t, "concrnt.example", host)
+
+	name, host, err = parseWebFingerResource("https://alice.example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice.example.com", name)
+	assert.Equal(t, "alice.example.com", host)
+
+	_, _, err = parseWebFingerResource("acct:alice")
+	assert.Error(t, err)
+}
+
+func TestWebFingerResponse(t *testing.T) {
+	alias := "alice.example.com"
+	entity := core.Entity{ID: "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2", Domain: "concrnt.example", Alias: &alias}
+
+	h := handler{webfinger: WebFingerConfig{FQDN: "concrnt.example", ActorURL: "https://concrnt.example/ap/acct/{alias}"}}
+	res := h.webFingerResponse("acct:alice.example.com@concrnt.example", entity, nil)
+	assert.Equal(t, "acct:alice.example.com@concrnt.example", res.Subject)
+	assert.Contains(t, res.Aliases, "acct:"+entity.ID+"@concrnt.example")
+	assert.Len(t, res.Links, 3)
+	assert.Equal(t, "https://concrnt.example/api/v1/entity/"+entity.ID, res.Links[0].Href)
+	assert.Equal(t, "https://concrnt.example/ap/acct/alice.example.com", res.Links[2].Href)
+
+	res = h.webFingerResponse("acct:alice.example.com@concrnt.example", entity, []string{"self"})
+	assert.Len(t, res.Links, 1)
+	assert.Equal(t, "self", res.Links[0].Rel)
+
+	entity.Alias = nil
+	res = h.webFingerResponse("acct:"+entity.ID+"@concrnt.example", entity, nil)
+	assert.Len(t, res.Links, 2)
+}
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Request(c echo.Context) error
+	Status(c echo.Context) error
+	Download(c echo.Context) error
+	RequestSnapshot(c echo.Context) error
+	SnapshotStatus(c echo.Context) error
+	DownloadSnapshot(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service: service}
+}
+
+// Request starts an export of everything stored about the requester
+func (h handler) Request(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Export.Handler.Request")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	job, err := h.service.Request(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"status": "ok", "content": job})
+}
+
+// Status returns the job of an export to be polled until it is completed
+func (h handler) Status(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Export.Handler.Status")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	job, err := h.service.Status(ctx, requester, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "export not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": job})
+}
+
+// Download returns the archive of a completed export
+func (h handler) Download(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Export.Handler.Download")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	f, err := h.service.Open(ctx, requester, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "export not found"})
+		}
+		if errors.Is(err, ErrorNotReady) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="concrnt-`+requester+`.zip"`)
+	http.ServeContent(c.Response(), c.Request(), "export.zip", info.ModTime(), f)
+	return nil
+}
+
+// RequestSnapshot starts a snapshot of a timeline to archive it or to serve it from a read-only mirror
+func (h handler) RequestSnapshot(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Export.Handler.RequestSnapshot")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+	tags, _ := ctx.Value(core.RequesterTagCtxKey).(core.Tags)
+
+	var request struct {
+		Format string `json:"format"`
+	}
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	job, err := h.service.RequestSnapshot(ctx, requester, tags.Has("_admin"), c.Param("id"), request.Format)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"status": "ok", "content": job})
+}
+
+// SnapshotStatus returns the job of a snapshot. the result of a completed job tells where the snapshot is stored
+func (h handler) SnapshotStatus(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Export.Handler.SnapshotStatus")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	job, err := h.service.SnapshotStatus(ctx, requester, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "snapshot not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": job})
+}
+
+// DownloadSnapshot returns a completed snapshot stored on disk
+func (h handler) DownloadSnapshot(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Export.Handler.DownloadSnapshot")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	f, result, err := h.service.OpenSnapshot(ctx, requester, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "snapshot not found"})
+		}
+		if errors.Is(err, ErrorNotReady) || errors.Is(err, ErrorStoredRemotely) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error(), "location": result.Location})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	name := result.Timeline + "." + result.Format
+	c.Response().Header().Set(echo.HeaderContentType, snapshotContentType(result.Format))
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`"`)
+	http.ServeContent(c.Response(), c.Request(), name, info.ModTime(), f)
+	return nil
+}
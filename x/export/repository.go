@@ -0,0 +1,202 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// rows read from the database at once while building an archive
+const exportBatchSize = 1000
+
+// Repository is the interface for reading the data of a user to be exported
+type Repository interface {
+	EachMessage(ctx context.Context, author string, fn func([]core.Message) error) error
+	EachAssociation(ctx context.Context, author string, fn func([]core.Association) error) error
+	ListKV(ctx context.Context, owner string) ([]core.UserKV, error)
+	EachTimelineItem(ctx context.Context, timeline string, fn func([]core.TimelineItem) error) error
+	GetMessages(ctx context.Context, ids []string) (map[string]core.Message, error)
+	GetAssociations(ctx context.Context, ids []string) (map[string]core.Association, error)
+	GetSchemaURLs(ctx context.Context, ids []uint) (map[uint]string, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new export repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// EachMessage calls fn with the messages authored by the user in batches
+func (r *repository) EachMessage(ctx context.Context, author string, fn func([]core.Message) error) error {
+	ctx, span := tracer.Start(ctx, "Export.Repository.EachMessage")
+	defer span.End()
+
+	var messages []core.Message
+	return r.db.WithContext(ctx).
+		Where("author = ?", author).
+		FindInBatches(&messages, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			// the primary key of the last row is the cursor of the next batch, so the rows are copied
+			// before the id is prefixed
+			batchRows := make([]core.Message, len(messages))
+			for i, row := range messages {
+				row.ID = "m" + row.ID
+				batchRows[i] = row
+			}
+			return fn(batchRows)
+		}).Error
+}
+
+// EachAssociation calls fn with the associations authored by the user in batches
+func (r *repository) EachAssociation(ctx context.Context, author string, fn func([]core.Association) error) error {
+	ctx, span := tracer.Start(ctx, "Export.Repository.EachAssociation")
+	defer span.End()
+
+	var associations []core.Association
+	return r.db.WithContext(ctx).
+		Where("author = ?", author).
+		FindInBatches(&associations, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			// the primary key of the last row is the cursor of the next batch, so the rows are copied
+			// before the id is prefixed
+			batchRows := make([]core.Association, len(associations))
+			for i, row := range associations {
+				row.ID = "a" + row.ID
+				batchRows[i] = row
+			}
+			return fn(batchRows)
+		}).Error
+}
+
+// ListKV returns every userkv entry of the user
+func (r *repository) ListKV(ctx context.Context, owner string) ([]core.UserKV, error) {
+	ctx, span := tracer.Start(ctx, "Export.Repository.ListKV")
+	defer span.End()
+
+	var kvs []core.UserKV
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Order("key ASC").Find(&kvs).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return kvs, nil
+}
+
+// EachTimelineItem calls fn with the items of the local timeline in batches, oldest first
+func (r *repository) EachTimelineItem(ctx context.Context, timeline string, fn func([]core.TimelineItem) error) error {
+	ctx, span := tracer.Start(ctx, "Export.Repository.EachTimelineItem")
+	defer span.End()
+
+	// the primary key of the items is composite, so the batches are paged by hand on (cdate, resource id)
+	var cursorDate time.Time
+	var cursorID string
+	for {
+		var items []core.TimelineItem
+		query := r.db.WithContext(ctx).Where("timeline_id = ?", timeline)
+		if cursorID != "" {
+			query = query.Where("(c_date, resource_id) > (?, ?)", cursorDate, cursorID)
+		}
+		err := query.Order("c_date ASC, resource_id ASC").Limit(exportBatchSize).Find(&items).Error
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		last := items[len(items)-1]
+		cursorDate, cursorID = last.CDate, last.ResourceID
+
+		err = fn(items)
+		if err != nil {
+			return err
+		}
+		if len(items) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// GetMessages returns the messages stored on this domain by their prefixed ids
+func (r *repository) GetMessages(ctx context.Context, ids []string) (map[string]core.Message, error) {
+	ctx, span := tracer.Start(ctx, "Export.Repository.GetMessages")
+	defer span.End()
+
+	result := make(map[string]core.Message, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var messages []core.Message
+	err := r.db.WithContext(ctx).Where("id IN ?", trimPrefixes(ids)).Find(&messages).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, message := range messages {
+		message.ID = "m" + message.ID
+		result[message.ID] = message
+	}
+	return result, nil
+}
+
+// GetAssociations returns the associations stored on this domain by their prefixed ids
+func (r *repository) GetAssociations(ctx context.Context, ids []string) (map[string]core.Association, error) {
+	ctx, span := tracer.Start(ctx, "Export.Repository.GetAssociations")
+	defer span.End()
+
+	result := make(map[string]core.Association, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var associations []core.Association
+	err := r.db.WithContext(ctx).Where("id IN ?", trimPrefixes(ids)).Find(&associations).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, association := range associations {
+		association.ID = "a" + association.ID
+		result[association.ID] = association
+	}
+	return result, nil
+}
+
+// GetSchemaURLs returns the urls of the schemas
+func (r *repository) GetSchemaURLs(ctx context.Context, ids []uint) (map[uint]string, error) {
+	ctx, span := tracer.Start(ctx, "Export.Repository.GetSchemaURLs")
+	defer span.End()
+
+	result := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var schemas []core.Schema
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&schemas).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, schema := range schemas {
+		result[schema.ID] = schema.URL
+	}
+	return result, nil
+}
+
+// trimPrefixes drops the type prefix of the resource ids, which is not stored in the tables
+func trimPrefixes(ids []string) []string {
+	trimmed := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if len(id) == 27 {
+			trimmed = append(trimmed, id[1:])
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,351 @@
+// Package export assembles everything the server stores about a user into a downloadable archive
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("export")
+
+const (
+	// JobType is the type of the job building an archive
+	JobType = "export"
+
+	// archives are removed this long after they were built
+	archiveRetention = 7 * 24 * time.Hour
+
+	defaultExportPath = "/tmp/concrnt"
+)
+
+// ErrorNotReady is returned when the archive of an export job is not built yet
+var ErrorNotReady = errors.New("export is not ready")
+
+// Service is the interface for user data exports
+type Service interface {
+	Request(ctx context.Context, requester string) (core.Job, error)
+	Status(ctx context.Context, requester, id string) (core.Job, error)
+	Open(ctx context.Context, requester, id string) (*os.File, error)
+	Build(ctx context.Context, job *core.Job) (string, error)
+	Sweep(ctx context.Context) error
+	Clean(ctx context.Context, ccid string) error
+	RequestSnapshot(ctx context.Context, requester string, admin bool, timeline, format string) (core.Job, error)
+	SnapshotStatus(ctx context.Context, requester, id string) (core.Job, error)
+	OpenSnapshot(ctx context.Context, requester, id string) (*os.File, SnapshotResult, error)
+	BuildSnapshot(ctx context.Context, job *core.Job) (string, error)
+}
+
+type service struct {
+	repo         Repository
+	job          core.JobService
+	entity       core.EntityService
+	store        core.StoreService
+	timeline     core.TimelineService
+	root         string
+	snapshotRoot string
+	snapshot     SnapshotConfig
+}
+
+// NewService creates a new export service. archives are written under root/exports,
+// and timeline snapshots under root/snapshots unless they are uploaded to s3
+func NewService(
+	repo Repository,
+	job core.JobService,
+	entity core.EntityService,
+	store core.StoreService,
+	timeline core.TimelineService,
+	root string,
+	snapshot SnapshotConfig,
+) Service {
+	if root == "" {
+		root = defaultExportPath
+	}
+	if snapshot.Storage == "" {
+		snapshot.Storage = SnapshotStorageDisk
+	}
+	return &service{
+		repo,
+		job,
+		entity,
+		store,
+		timeline,
+		filepath.Join(root, "exports"),
+		filepath.Join(root, "snapshots"),
+		snapshot,
+	}
+}
+
+func (s *service) archivePath(owner, id string) string {
+	return filepath.Join(s.root, owner, id+".zip")
+}
+
+// Request enqueues an export of the requester. an export already in progress is returned instead of a new one
+func (s *service) Request(ctx context.Context, requester string) (core.Job, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.Request")
+	defer span.End()
+
+	jobs, err := s.job.List(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return core.Job{}, err
+	}
+	for _, job := range jobs {
+		if job.Type == JobType && (job.Status == "pending" || job.Status == "running") {
+			return job, nil
+		}
+	}
+
+	return s.job.Create(ctx, requester, JobType, "{}", time.Now())
+}
+
+// Status returns the export job of the requester
+func (s *service) Status(ctx context.Context, requester, id string) (core.Job, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.Status")
+	defer span.End()
+
+	return s.findJob(ctx, requester, id, JobType)
+}
+
+func (s *service) findJob(ctx context.Context, requester, id, typ string) (core.Job, error) {
+	jobs, err := s.job.List(ctx, requester)
+	if err != nil {
+		return core.Job{}, err
+	}
+	for _, job := range jobs {
+		if job.ID == id && job.Type == typ {
+			return job, nil
+		}
+	}
+
+	return core.Job{}, core.NewErrorNotFound()
+}
+
+// Open returns the archive of a completed export job of the requester
+func (s *service) Open(ctx context.Context, requester, id string) (*os.File, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.Open")
+	defer span.End()
+
+	job, err := s.Status(ctx, requester, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != "completed" {
+		return nil, ErrorNotReady
+	}
+
+	f, err := os.Open(s.archivePath(requester, job.ID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// already swept
+			return nil, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return nil, err
+	}
+	return f, nil
+}
+
+// Build writes the archive of the author of the job. it is called by the job reactor
+func (s *service) Build(ctx context.Context, job *core.Job) (string, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.Build")
+	defer span.End()
+
+	path := s.archivePath(job.Author, job.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	// written to a temporary file first so that a half written archive is never served
+	tmp, err := os.CreateTemp(filepath.Dir(path), job.ID+".*.tmp")
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	err = s.writeArchive(ctx, tmp, job.Author)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	logging.Info(ctx, "export archive built",
+		slog.String("module", "export"),
+		slog.String("owner", job.Author),
+		slog.Int64("size", info.Size()),
+	)
+
+	result, _ := json.Marshal(map[string]any{
+		"size":      info.Size(),
+		"expiresAt": time.Now().Add(archiveRetention),
+	})
+	return string(result), nil
+}
+
+func (s *service) writeArchive(ctx context.Context, w io.Writer, owner string) error {
+	archive := zip.NewWriter(w)
+
+	entity, err := s.entity.Get(ctx, owner)
+	if err != nil {
+		return errors.Wrap(err, "failed to get entity")
+	}
+	meta, err := s.entity.GetMeta(ctx, owner)
+	if err != nil && !errors.Is(err, core.ErrorNotFound{}) {
+		return errors.Wrap(err, "failed to get entity meta")
+	}
+	err = writeJSON(archive, "entity.json", map[string]any{
+		"entity": entity,
+		"meta":   meta,
+	})
+	if err != nil {
+		return err
+	}
+
+	kvs, err := s.repo.ListKV(ctx, owner)
+	if err != nil {
+		return errors.Wrap(err, "failed to list userkv")
+	}
+	err = writeJSON(archive, "kv.json", kvs)
+	if err != nil {
+		return err
+	}
+
+	messages, err := archive.Create("messages.ndjson")
+	if err != nil {
+		return err
+	}
+	err = s.repo.EachMessage(ctx, owner, func(batch []core.Message) error {
+		return writeLines(messages, batch)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to export messages")
+	}
+
+	associations, err := archive.Create("associations.ndjson")
+	if err != nil {
+		return err
+	}
+	err = s.repo.EachAssociation(ctx, owner, func(batch []core.Association) error {
+		return writeLines(associations, batch)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to export associations")
+	}
+
+	// the signed commit log, which can be restored to another server with /repository
+	repository, err := archive.Create("repository.log")
+	if err != nil {
+		return err
+	}
+	err = s.store.StreamRepository(ctx, owner, time.Time{}, time.Now(), repository)
+	if err != nil {
+		return errors.Wrap(err, "failed to export repository")
+	}
+
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, name string, v any) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeLines[T any](w io.Writer, rows []T) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clean removes the archives of the entity, for the deletion of its account
+func (s *service) Clean(ctx context.Context, ccid string) error {
+	ctx, span := tracer.Start(ctx, "Export.Service.Clean")
+	defer span.End()
+
+	if !core.IsCCID(ccid) {
+		return fmt.Errorf("invalid ccid: %s", ccid)
+	}
+
+	err := os.RemoveAll(filepath.Join(s.root, ccid))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// Sweep removes archives older than the retention
+func (s *service) Sweep(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Export.Service.Sweep")
+	defer span.End()
+
+	threshold := time.Now().Add(-archiveRetention)
+	removed := 0
+	err := filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !(strings.HasSuffix(path, ".zip") || strings.HasSuffix(path, ".tmp")) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().Before(threshold) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to sweep exports: %w", err)
+	}
+
+	if removed > 0 {
+		logging.Info(ctx, "expired exports removed", slog.String("module", "export"), slog.Int("count", removed))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+const user1ID = "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+
+type memoryRepository struct {
+	messages     []core.Message
+	associations []core.Association
+	kvs          []core.UserKV
+	items        []core.TimelineItem
+	schemas      map[uint]string
+}
+
+func (r *memoryRepository) EachMessage(ctx context.Context, author string, fn func([]core.Message) error) error {
+	return fn(r.messages)
+}
+
+func (r *memoryRepository) EachAssociation(ctx context.Context, author string, fn func([]core.Association) error) error {
+	return fn(r.associations)
+}
+
+func (r *memoryRepository) ListKV(ctx context.Context, owner string) ([]core.UserKV, error) {
+	return r.kvs, nil
+}
+
+func (r *memoryRepository) EachTimelineItem(ctx context.Context, timeline string, fn func([]core.TimelineItem) error) error {
+	return fn(r.items)
+}
+
+func (r *memoryRepository) GetMessages(ctx context.Context, ids []string) (map[string]core.Message, error) {
+	result := map[string]core.Message{}
+	for _, message := range r.messages {
+		if slices.Contains(ids, message.ID) {
+			result[message.ID] = message
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) GetAssociations(ctx context.Context, ids []string) (map[string]core.Association, error) {
+	result := map[string]core.Association{}
+	for _, association := range r.associations {
+		if slices.Contains(ids, association.ID) {
+			result[association.ID] = association
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) GetSchemaURLs(ctx context.Context, ids []uint) (map[uint]string, error) {
+	return r.schemas, nil
+}
+
+func TestBuildArchive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), user1ID).Return(core.Entity{ID: user1ID}, nil)
+	mockEntity.EXPECT().GetMeta(gomock.Any(), user1ID).Return(core.EntityMeta{ID: user1ID}, nil)
+
+	mockStore := mock_core.NewMockStoreService(ctrl)
+	mockStore.EXPECT().StreamRepository(gomock.Any(), user1ID, time.Time{}, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, owner string, since, until time.Time, w io.Writer) error {
+			_, err := io.WriteString(w, "log\n")
+			return err
+		},
+	)
+
+	job := core.Job{ID: "job1", Author: user1ID, Type: JobType, Status: "running"}
+	mockJob := mock_core.NewMockJobService(ctrl)
+	mockJob.EXPECT().List(gomock.Any(), user1ID).DoAndReturn(func(ctx context.Context, requester string) ([]core.Job, error) {
+		return []core.Job{job}, nil
+	}).Times(2)
+
+	repo := &memoryRepository{
+		messages:     []core.Message{{ID: "m1", Author: user1ID}, {ID: "m2", Author: user1ID}},
+		associations: []core.Association{{ID: "a1", Author: user1ID}},
+		kvs:          []core.UserKV{{Owner: user1ID, Key: "k", Value: "v"}},
+	}
+	service := NewService(repo, mockJob, mockEntity, mockStore, nil, t.TempDir(), SnapshotConfig{})
+
+	// not downloadable until the job is completed
+	_, err := service.Open(context.Background(), user1ID, "job1")
+	assert.ErrorIs(t, err, ErrorNotReady)
+
+	_, err = service.Build(context.Background(), &job)
+	assert.NoError(t, err)
+
+	job.Status = "completed"
+	f, err := service.Open(context.Background(), user1ID, "job1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	archive, err := zip.NewReader(f, info.Size())
+	assert.NoError(t, err)
+
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"entity.json", "kv.json", "messages.ndjson", "associations.ndjson", "repository.log"}, names)
+
+	messages, err := archive.Open("messages.ndjson")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(messages)
+	assert.Equal(t, 2, strings.Count(string(body), "\n"))
+}
+
+func TestBuildSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	timeline := core.Timeline{ID: "t00000000000000000000000000", Owner: user1ID, Author: user1ID}
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().GetTimeline(gomock.Any(), "home@example.com").Return(timeline, nil)
+	mockTimeline.EXPECT().GetTimeline(gomock.Any(), timeline.ID).Return(timeline, nil).Times(2)
+
+	mockJob := mock_core.NewMockJobService(ctrl)
+	mockJob.EXPECT().Create(gomock.Any(), user1ID, SnapshotJobType, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, requester, typ, payload string, scheduled time.Time) (core.Job, error) {
+			return core.Job{ID: "job1", Author: requester, Type: typ, Payload: payload, Status: "pending"}, nil
+		},
+	)
+
+	repo := &memoryRepository{
+		messages: []core.Message{{ID: "m00000000000000000000000001", Author: user1ID, SchemaID: 1}},
+		items: []core.TimelineItem{
+			{ResourceID: "m00000000000000000000000001", TimelineID: timeline.ID[1:], SchemaID: 1},
+			{ResourceID: "m00000000000000000000000002", TimelineID: timeline.ID[1:], SchemaID: 1},
+		},
+		schemas: map[uint]string{1: "https://schema.concrnt.world/m/markdown.json"},
+	}
+	service := NewService(repo, mockJob, nil, nil, mockTimeline, t.TempDir(), SnapshotConfig{})
+
+	// only the owner can take a snapshot unless the requester is an admin
+	_, err := service.RequestSnapshot(context.Background(), "con1other", false, timeline.ID, "")
+	assert.ErrorIs(t, err, core.NewErrorPermissionDenied())
+
+	job, err := service.RequestSnapshot(context.Background(), user1ID, false, "home@example.com", SnapshotFormatNDJSON)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result, err := service.BuildSnapshot(context.Background(), &job)
+	if !assert.NoError(t, err) {
+		return
+	}
+	job.Status = "completed"
+	job.Result = result
+	mockJob.EXPECT().List(gomock.Any(), user1ID).Return([]core.Job{job}, nil)
+
+	f, snapshot, err := service.OpenSnapshot(context.Background(), user1ID, "job1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	assert.Equal(t, 2, snapshot.Items)
+	assert.Equal(t, 1, snapshot.Unresolved)
+
+	scanner := bufio.NewScanner(f)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Len(t, lines, 3)
+
+	var header SnapshotHeader
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, timeline.ID, header.Timeline.ID)
+
+	var item SnapshotItem
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &item))
+	assert.Equal(t, "https://schema.concrnt.world/m/markdown.json", item.Schema)
+	if assert.NotNil(t, item.Message) {
+		assert.Equal(t, "https://schema.concrnt.world/m/markdown.json", item.Message.Schema)
+	}
+}
+
+func TestClean(t *testing.T) {
+	root := t.TempDir()
+	service := NewService(nil, nil, nil, nil, nil, root, SnapshotConfig{})
+
+	ccid := "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"
+	archive := filepath.Join(root, "exports", ccid, "job.zip")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(archive), 0o755))
+	assert.NoError(t, os.WriteFile(archive, []byte("zip"), 0o644))
+
+	assert.NoError(t, service.Clean(context.Background(), ccid))
+	_, err := os.Stat(filepath.Dir(archive))
+	assert.True(t, os.IsNotExist(err))
+
+	// never a path outside of the archives
+	assert.Error(t, service.Clean(context.Background(), ".."))
+}
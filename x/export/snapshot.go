@@ -0,0 +1,362 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+const (
+	// SnapshotJobType is the type of the job building a timeline snapshot
+	SnapshotJobType = "timeline.snapshot"
+
+	SnapshotFormatJSON   = "json"
+	SnapshotFormatNDJSON = "ndjson"
+
+	SnapshotStorageDisk = "disk"
+	SnapshotStorageS3   = "s3"
+)
+
+// ErrorStoredRemotely is returned when a snapshot is downloaded from the server while it was uploaded to s3
+var ErrorStoredRemotely = errors.New("snapshot is not stored on this server")
+
+// SnapshotConfig is where timeline snapshots are stored
+type SnapshotConfig struct {
+	Storage string   `yaml:"storage"` // disk (default) or s3
+	S3      S3Config `yaml:"s3"`
+}
+
+// SnapshotPayload is the payload of a snapshot job
+type SnapshotPayload struct {
+	Timeline string `json:"timeline"`
+	Format   string `json:"format"`
+}
+
+// SnapshotResult is the result of a completed snapshot job
+type SnapshotResult struct {
+	Timeline   string `json:"timeline"`
+	Format     string `json:"format"`
+	Items      int    `json:"items"`
+	Unresolved int    `json:"unresolved"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Storage    string `json:"storage"`
+	Location   string `json:"location,omitempty"`
+}
+
+// SnapshotHeader is the first line of a ndjson snapshot, and the head of a json snapshot
+type SnapshotHeader struct {
+	Timeline   core.Timeline `json:"timeline"`
+	ExportedAt time.Time     `json:"exportedAt"`
+}
+
+// SnapshotItem is a timeline item with the document it points to. resources which are not stored on this domain
+// are left unresolved
+type SnapshotItem struct {
+	core.TimelineItem
+	Message     *core.Message     `json:"message,omitempty"`
+	Association *core.Association `json:"association,omitempty"`
+}
+
+func (s *service) snapshotPath(timeline, id, format string) string {
+	return filepath.Join(s.snapshotRoot, timeline, id+"."+format)
+}
+
+// RequestSnapshot enqueues a snapshot of a local timeline. only its owner, its author and admins can take one
+func (s *service) RequestSnapshot(ctx context.Context, requester string, admin bool, timeline, format string) (core.Job, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.RequestSnapshot")
+	defer span.End()
+
+	if format == "" {
+		format = SnapshotFormatJSON
+	}
+	if format != SnapshotFormatJSON && format != SnapshotFormatNDJSON {
+		return core.Job{}, core.NewErrorInvalidArgument("format must be json or ndjson")
+	}
+
+	tl, err := s.timeline.GetTimeline(ctx, timeline)
+	if err != nil {
+		span.RecordError(err)
+		return core.Job{}, err
+	}
+	if !admin && tl.Owner != requester && tl.Author != requester {
+		return core.Job{}, core.NewErrorPermissionDenied()
+	}
+
+	payload, err := json.Marshal(SnapshotPayload{Timeline: tl.ID, Format: format})
+	if err != nil {
+		return core.Job{}, err
+	}
+
+	return s.job.Create(ctx, requester, SnapshotJobType, string(payload), time.Now())
+}
+
+// SnapshotStatus returns the snapshot job of the requester
+func (s *service) SnapshotStatus(ctx context.Context, requester, id string) (core.Job, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.SnapshotStatus")
+	defer span.End()
+
+	return s.findJob(ctx, requester, id, SnapshotJobType)
+}
+
+// OpenSnapshot returns the file of a completed snapshot job of the requester stored on disk
+func (s *service) OpenSnapshot(ctx context.Context, requester, id string) (*os.File, SnapshotResult, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.OpenSnapshot")
+	defer span.End()
+
+	job, err := s.findJob(ctx, requester, id, SnapshotJobType)
+	if err != nil {
+		return nil, SnapshotResult{}, err
+	}
+	if job.Status != "completed" {
+		return nil, SnapshotResult{}, ErrorNotReady
+	}
+
+	var result SnapshotResult
+	err = json.Unmarshal([]byte(job.Result), &result)
+	if err != nil {
+		span.RecordError(err)
+		return nil, SnapshotResult{}, err
+	}
+	if result.Storage != SnapshotStorageDisk {
+		return nil, result, ErrorStoredRemotely
+	}
+
+	f, err := os.Open(s.snapshotPath(result.Timeline, job.ID, result.Format))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, result, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return nil, result, err
+	}
+	return f, result, nil
+}
+
+// BuildSnapshot writes the snapshot of the timeline of the job. it is called by the job reactor
+func (s *service) BuildSnapshot(ctx context.Context, job *core.Job) (string, error) {
+	ctx, span := tracer.Start(ctx, "Export.Service.BuildSnapshot")
+	defer span.End()
+
+	var payload SnapshotPayload
+	err := json.Unmarshal([]byte(job.Payload), &payload)
+	if err != nil {
+		span.RecordError(err)
+		return "", errors.Wrap(err, "invalid snapshot payload")
+	}
+	span.SetAttributes(attribute.String("timeline", payload.Timeline), attribute.String("format", payload.Format))
+
+	tl, err := s.timeline.GetTimeline(ctx, payload.Timeline)
+	if err != nil {
+		span.RecordError(err)
+		return "", errors.Wrap(err, "failed to get timeline")
+	}
+
+	path := s.snapshotPath(tl.ID, job.ID, payload.Format)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	// written to a temporary file first so that a half written snapshot is never served
+	tmp, err := os.CreateTemp(filepath.Dir(path), job.ID+".*.tmp")
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	buffered := bufio.NewWriter(io.MultiWriter(tmp, hash))
+
+	result := SnapshotResult{
+		Timeline: tl.ID,
+		Format:   payload.Format,
+		Storage:  SnapshotStorageDisk,
+	}
+	err = s.writeSnapshot(ctx, buffered, tl, payload.Format, &result)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	if err := buffered.Flush(); err != nil {
+		return "", err
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return "", err
+	}
+	result.Size = info.Size()
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if s.snapshot.Storage == SnapshotStorageS3 {
+		key := s.snapshot.S3.Prefix + "snapshots/" + tl.ID + "/" + job.ID + "." + payload.Format
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		err = s3Put(ctx, s.snapshot.S3, key, snapshotContentType(payload.Format), tmp, result.Size, result.SHA256)
+		if err != nil {
+			span.RecordError(err)
+			return "", err
+		}
+		result.Storage = SnapshotStorageS3
+		result.Location = s.snapshot.S3.Bucket + "/" + key
+	} else {
+		if err := tmp.Close(); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			span.RecordError(err)
+			return "", err
+		}
+	}
+
+	logging.Info(ctx, "timeline snapshot built",
+		slog.String("module", "export"),
+		slog.String("timeline", tl.ID),
+		slog.Int("items", result.Items),
+		slog.Int64("size", result.Size),
+		slog.String("storage", result.Storage),
+	)
+
+	resultJSON, _ := json.Marshal(result)
+	return string(resultJSON), nil
+}
+
+// writeSnapshot writes the timeline and its items oldest first. a json snapshot is a single object holding
+// the items, a ndjson snapshot is the header line followed by a line per item
+func (s *service) writeSnapshot(ctx context.Context, w io.Writer, tl core.Timeline, format string, result *SnapshotResult) error {
+	header := SnapshotHeader{Timeline: tl, ExportedAt: time.Now()}
+	encoder := json.NewEncoder(w)
+
+	if format == SnapshotFormatNDJSON {
+		if err := encoder.Encode(header); err != nil {
+			return err
+		}
+	} else {
+		timeline, err := json.Marshal(header.Timeline)
+		if err != nil {
+			return err
+		}
+		exportedAt, _ := json.Marshal(header.ExportedAt)
+		_, err = io.WriteString(w, `{"timeline":`+string(timeline)+`,"exportedAt":`+string(exportedAt)+`,"items":[`)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.repo.EachTimelineItem(ctx, tl.ID[1:], func(batch []core.TimelineItem) error {
+		items, err := s.resolveItems(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if item.Message == nil && item.Association == nil {
+				result.Unresolved++
+			}
+			if format == SnapshotFormatJSON && result.Items > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if format == SnapshotFormatNDJSON {
+				err = encoder.Encode(item)
+			} else {
+				var line []byte
+				line, err = json.Marshal(item)
+				if err == nil {
+					_, err = w.Write(line)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			result.Items++
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to export timeline items")
+	}
+
+	if format == SnapshotFormatJSON {
+		_, err = io.WriteString(w, "]}\n")
+	}
+	return err
+}
+
+// resolveItems attaches the messages and associations of the items stored on this domain
+func (s *service) resolveItems(ctx context.Context, batch []core.TimelineItem) ([]SnapshotItem, error) {
+	var messageIDs, associationIDs []string
+	for _, item := range batch {
+		switch {
+		case strings.HasPrefix(item.ResourceID, "m"):
+			messageIDs = append(messageIDs, item.ResourceID)
+		case strings.HasPrefix(item.ResourceID, "a"):
+			associationIDs = append(associationIDs, item.ResourceID)
+		}
+	}
+
+	messages, err := s.repo.GetMessages(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	associations, err := s.repo.GetAssociations(ctx, associationIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaIDs := make([]uint, 0, len(batch))
+	for _, item := range batch {
+		schemaIDs = append(schemaIDs, item.SchemaID)
+	}
+	for _, message := range messages {
+		schemaIDs = append(schemaIDs, message.SchemaID, message.PolicyID)
+	}
+	for _, association := range associations {
+		schemaIDs = append(schemaIDs, association.SchemaID)
+	}
+	urls, err := s.repo.GetSchemaURLs(ctx, schemaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]SnapshotItem, len(batch))
+	for i, item := range batch {
+		item.Schema = urls[item.SchemaID]
+		items[i] = SnapshotItem{TimelineItem: item}
+		if message, ok := messages[item.ResourceID]; ok {
+			message.Schema = urls[message.SchemaID]
+			message.Policy = urls[message.PolicyID]
+			items[i].Message = &message
+		}
+		if association, ok := associations[item.ResourceID]; ok {
+			association.Schema = urls[association.SchemaID]
+			items[i].Association = &association
+		}
+	}
+	return items, nil
+}
+
+func snapshotContentType(format string) string {
+	if format == SnapshotFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
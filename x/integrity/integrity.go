@@ -0,0 +1,251 @@
+// Package integrity finds rows of a module referring to rows of another module which do not exist anymore,
+// e.g. timeline items of deleted messages. the modules remove their own rows only, so a crash between two
+// deletions or a partial restore leaves such references behind. Run reports them and optionally removes them.
+package integrity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+var tracer = otel.Tracer("integrity")
+
+// DefaultSamples is the number of findings reported for each check
+const DefaultSamples = 20
+
+// Options selects what Run does
+type Options struct {
+	// FQDN of the domain. references are only checked for rows of its local entities,
+	// the others may refer to resources stored on their own domain
+	FQDN string
+	// Checks to run. empty runs all of them
+	Checks []string
+	// Repair removes the rows holding a dangling reference
+	Repair bool
+	// Samples is the number of findings reported for each check
+	Samples int
+}
+
+// Finding is a row holding a dangling reference
+type Finding struct {
+	ID        string `json:"id"`
+	Reference string `json:"reference"`
+}
+
+// CheckResult is the result of a check
+type CheckResult struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Found       int64     `json:"found"`
+	Repaired    int64     `json:"repaired"`
+	Samples     []Finding `json:"samples"`
+}
+
+// Report is the result of Run
+type Report struct {
+	Checks   []CheckResult `json:"checks"`
+	Found    int64         `json:"found"`
+	Repaired int64         `json:"repaired"`
+}
+
+// check finds the rows of a table whose reference does not resolve
+type check struct {
+	name        string
+	description string
+	model       any
+	// id and reference are the columns reported for a finding
+	id        string
+	reference string
+	// dangling scopes the query of model to the rows with a dangling reference
+	dangling func(db *gorm.DB, fqdn string) *gorm.DB
+}
+
+// localOwner restricts rows to those of local entities
+func localOwner(db *gorm.DB, column, fqdn string) *gorm.DB {
+	entities := db.Session(&gorm.Session{NewDB: true}).Model(&core.Entity{}).Select("id").Where("domain = ?", fqdn)
+	return db.Where(column+" IN (?)", entities)
+}
+
+// missingIn matches rows whose typed reference (the id of the table with a one letter prefix) is not in the table
+func missingIn(db *gorm.DB, column, prefix string, table any) *gorm.DB {
+	ids := db.Session(&gorm.Session{NewDB: true}).Model(table).Select("id")
+	return db.Where(column+" LIKE ?", prefix+"%").Where("substring("+column+" from 2) NOT IN (?)", ids)
+}
+
+var checks = []check{
+	{
+		name:        "timeline_item_message",
+		description: "timeline items of messages which do not exist",
+		model:       &core.TimelineItem{},
+		id:          "timeline_id || '/' || resource_id",
+		reference:   "resource_id",
+		dangling: func(db *gorm.DB, fqdn string) *gorm.DB {
+			return missingIn(localOwner(db.Model(&core.TimelineItem{}), "owner", fqdn), "resource_id", "m", &core.Message{})
+		},
+	},
+	{
+		name:        "timeline_item_association",
+		description: "timeline items of associations which do not exist",
+		model:       &core.TimelineItem{},
+		id:          "timeline_id || '/' || resource_id",
+		reference:   "resource_id",
+		dangling: func(db *gorm.DB, fqdn string) *gorm.DB {
+			return missingIn(localOwner(db.Model(&core.TimelineItem{}), "owner", fqdn), "resource_id", "a", &core.Association{})
+		},
+	},
+	{
+		name:        "timeline_item_timeline",
+		description: "timeline items of timelines which do not exist",
+		model:       &core.TimelineItem{},
+		id:          "timeline_id || '/' || resource_id",
+		reference:   "timeline_id",
+		dangling: func(db *gorm.DB, fqdn string) *gorm.DB {
+			timelines := db.Session(&gorm.Session{NewDB: true}).Model(&core.Timeline{}).Select("id")
+			return db.Model(&core.TimelineItem{}).Where("timeline_id NOT IN (?)", timelines)
+		},
+	},
+	{
+		name:        "association_message",
+		description: "associations targeting local messages which do not exist",
+		model:       &core.Association{},
+		id:          "'a' || id",
+		reference:   "target",
+		dangling: func(db *gorm.DB, fqdn string) *gorm.DB {
+			return missingIn(localOwner(db.Model(&core.Association{}), "owner", fqdn), "target", "m", &core.Message{})
+		},
+	},
+	{
+		name:        "association_association",
+		description: "associations targeting local associations which do not exist",
+		model:       &core.Association{},
+		id:          "'a' || id",
+		reference:   "target",
+		dangling: func(db *gorm.DB, fqdn string) *gorm.DB {
+			return missingIn(localOwner(db.Model(&core.Association{}), "owner", fqdn), "target", "a", &core.Association{})
+		},
+	},
+	{
+		name:        "semanticid_timeline",
+		description: "semantic ids naming timelines which do not exist",
+		model:       &core.SemanticID{},
+		id:          "owner || '/' || id",
+		reference:   "target",
+		dangling: func(db *gorm.DB, fqdn string) *gorm.DB {
+			return missingIn(db.Model(&core.SemanticID{}), "target", "t", &core.Timeline{})
+		},
+	},
+}
+
+// Names returns the names of the checks, in the order they run
+func Names() []string {
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Run runs the checks on the tables of db. with Repair, the rows found by a check are removed in a transaction
+// of their own, so that a failing check does not revert the others
+func Run(ctx context.Context, db *gorm.DB, opts Options) (Report, error) {
+	ctx, span := tracer.Start(ctx, "Integrity.Run")
+	defer span.End()
+
+	if opts.FQDN == "" {
+		return Report{}, errors.New("the fqdn of the domain is required")
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = DefaultSamples
+	}
+
+	selected, err := selectChecks(opts.Checks)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Checks: []CheckResult{}}
+	for _, c := range selected {
+		result, err := c.run(ctx, db, opts)
+		if err != nil {
+			span.RecordError(err)
+			return report, fmt.Errorf("%s: %w", c.name, err)
+		}
+		report.Checks = append(report.Checks, result)
+		report.Found += result.Found
+		report.Repaired += result.Repaired
+	}
+
+	span.SetAttributes(attribute.Int64("found", report.Found), attribute.Int64("repaired", report.Repaired))
+	return report, nil
+}
+
+func selectChecks(names []string) ([]check, error) {
+	if len(names) == 0 {
+		return checks, nil
+	}
+	selected := make([]check, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, c := range checks {
+			if c.name == name {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown check: %s", name)
+		}
+	}
+	return selected, nil
+}
+
+func (c check) run(ctx context.Context, db *gorm.DB, opts Options) (CheckResult, error) {
+	ctx, span := tracer.Start(ctx, "Integrity.Check."+c.name)
+	defer span.End()
+
+	result := CheckResult{Name: c.name, Description: c.description, Samples: []Finding{}}
+
+	db = db.WithContext(ctx)
+	err := c.dangling(db, opts.FQDN).Count(&result.Found).Error
+	if err != nil {
+		return result, err
+	}
+	if result.Found == 0 {
+		return result, nil
+	}
+
+	err = c.dangling(db, opts.FQDN).
+		Select(c.id + " AS id, " + c.reference + " AS reference").
+		Order("id").
+		Limit(opts.Samples).
+		Scan(&result.Samples).Error
+	if err != nil {
+		return result, err
+	}
+
+	if !opts.Repair {
+		return result, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		deleted := c.dangling(tx, opts.FQDN).Delete(c.model)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.Repaired = deleted.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,26 @@
+package integrity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectChecks(t *testing.T) {
+	all, err := selectChecks(nil)
+	assert.NoError(t, err)
+	assert.Len(t, all, len(Names()))
+
+	selected, err := selectChecks([]string{"semanticid_timeline", "timeline_item_message"})
+	assert.NoError(t, err)
+	assert.Equal(t, "semanticid_timeline", selected[0].name)
+	assert.Equal(t, "timeline_item_message", selected[1].name)
+
+	_, err = selectChecks([]string{"timeline_item_message", "unknown"})
+	assert.EqualError(t, err, "unknown check: unknown")
+
+	// the local entities can not be told without the fqdn
+	_, err = Run(context.Background(), nil, Options{})
+	assert.Error(t, err)
+}
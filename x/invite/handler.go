@@ -0,0 +1,46 @@
+package invite
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Tree(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// Tree returns the invitation tree of the domain. root narrows it to the subtree of an entity,
+// and depth is how many levels of invitees are rendered
+func (h handler) Tree(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Invite.Handler.Tree")
+	defer span.End()
+
+	depth := 0
+	if value := c.QueryParam("depth"); value != "" {
+		var err error
+		depth, err = strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid depth"})
+		}
+	}
+
+	report, err := h.service.Tree(ctx, c.QueryParam("root"), depth)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": report})
+}
@@ -0,0 +1,65 @@
+package invite
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for invite repository
+type Repository interface {
+	ListMembers(ctx context.Context) ([]Member, error)
+}
+
+type repository struct {
+	db     *gorm.DB
+	config core.Config
+}
+
+// NewRepository creates a new invite repository
+func NewRepository(db *gorm.DB, config core.Config) Repository {
+	return &repository{db, config}
+}
+
+// ListMembers returns the local entities with their inviters. suspended entities are those tagged _block
+func (r *repository) ListMembers(ctx context.Context) ([]Member, error) {
+	ctx, span := tracer.Start(ctx, "Invite.Repository.ListMembers")
+	defer span.End()
+
+	naming := r.db.NamingStrategy
+	var rows []struct {
+		ID        string
+		Inviter   *string
+		Tag       string
+		Tombstone bool
+		CDate     time.Time
+	}
+	err := r.db.WithContext(ctx).
+		Table(naming.TableName("Entity")+" AS e").
+		Select("e.id, m.inviter, e.tag, e.tombstone_document IS NOT NULL AS tombstone, e.c_date").
+		Joins("LEFT JOIN "+naming.TableName("EntityMeta")+" AS m ON m.id = e.id").
+		Where("e.domain = ?", r.config.FQDN).
+		Scan(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	members := make([]Member, len(rows))
+	for i, row := range rows {
+		tags := core.ParseTags(row.Tag)
+		members[i] = Member{
+			CCID:      row.ID,
+			Suspended: tags.Has("_block"),
+			Deleted:   row.Tombstone,
+			CDate:     row.CDate,
+		}
+		if row.Inviter != nil {
+			members[i].Inviter = *row.Inviter
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,49 @@
+// Package invite renders the invitation graph of the domain for admins investigating abuse on invite-only domains
+package invite
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("invite")
+
+const (
+	defaultMaxDepth = 3
+	topInviters     = 20
+)
+
+// Service is the interface for invite service
+type Service interface {
+	Tree(ctx context.Context, root string, maxDepth int) (Report, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new invite service
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+// Tree returns the invitation tree of the domain, or the subtree of the root, rendered down to maxDepth levels
+func (s *service) Tree(ctx context.Context, root string, maxDepth int) (Report, error) {
+	ctx, span := tracer.Start(ctx, "Invite.Service.Tree")
+	defer span.End()
+
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+	span.SetAttributes(attribute.String("root", root), attribute.Int("maxDepth", maxDepth))
+
+	members, err := s.repo.ListMembers(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return Report{}, err
+	}
+
+	return Build(members, root, maxDepth, topInviters), nil
+}
@@ -0,0 +1,182 @@
+package invite
+
+import (
+	"sort"
+	"time"
+)
+
+// Member is a local entity with the entity who invited it
+type Member struct {
+	CCID      string    `json:"ccid"`
+	Inviter   string    `json:"inviter,omitempty"`
+	Suspended bool      `json:"suspended"`
+	Deleted   bool      `json:"deleted"`
+	CDate     time.Time `json:"cdate"`
+}
+
+// Node is a member in the invitation tree. the counts cover the whole subtree, even below the rendered depth
+type Node struct {
+	Member
+	Depth                int     `json:"depth"`
+	Invited              int     `json:"invited"`     // invited directly
+	Descendants          int     `json:"descendants"` // invited directly or transitively
+	SuspendedDescendants int     `json:"suspendedDescendants"`
+	SuspendedRate        float64 `json:"suspendedRate"` // of the descendants
+	Children             []*Node `json:"children,omitempty"`
+}
+
+// InviterStats is an inviter ranked by the suspensions among the members it brought in
+type InviterStats struct {
+	CCID                 string  `json:"ccid"`
+	Suspended            bool    `json:"suspended"`
+	Invited              int     `json:"invited"`
+	Descendants          int     `json:"descendants"`
+	SuspendedDescendants int     `json:"suspendedDescendants"`
+	SuspendedRate        float64 `json:"suspendedRate"`
+}
+
+// Report is the invitation graph of the domain
+type Report struct {
+	Members   int `json:"members"`
+	Invited   int `json:"invited"` // members with an inviter
+	Suspended int `json:"suspended"`
+	MaxDepth  int `json:"maxDepth"`
+	// SuspendedRateInvitedBySuspended is the suspension rate of the members invited by a suspended member, to
+	// compare with SuspendedRate of all members. a higher rate means suspended users bring in more abusers
+	SuspendedRate                   float64        `json:"suspendedRate"`
+	SuspendedRateInvitedBySuspended float64        `json:"suspendedRateInvitedBySuspended"`
+	Roots                           []*Node        `json:"roots"`
+	TopInviters                     []InviterStats `json:"topInviters"`
+}
+
+// Build renders the invitation tree of the members. with a root only its subtree is rendered, and children
+// deeper than maxDepth below the rendered roots are left out. members whose inviter isn't a member, e.g. the
+// first admin or an inviter who left, are roots. inviters are ranked by their suspended descendants
+func Build(members []Member, root string, maxDepth, topInviters int) Report {
+	nodes := make(map[string]*Node, len(members))
+	for _, member := range members {
+		nodes[member.CCID] = &Node{Member: member}
+	}
+
+	children := map[string][]*Node{}
+	var roots []*Node
+	for _, member := range members {
+		node := nodes[member.CCID]
+		if _, ok := nodes[member.Inviter]; ok && member.Inviter != member.CCID {
+			children[member.Inviter] = append(children[member.Inviter], node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, list := range children {
+		sort.Slice(list, func(i, j int) bool { return list[i].CDate.Before(list[j].CDate) })
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].CDate.Before(roots[j].CDate) })
+
+	report := Report{Members: len(members)}
+	invitedBySuspended, suspendedInvitedBySuspended := 0, 0
+
+	// counts are gathered from the leaves. members in an invitation cycle have no root and are not reached
+	visited := map[string]bool{}
+	var walk func(node *Node, depth int)
+	walk = func(node *Node, depth int) {
+		visited[node.CCID] = true
+		node.Depth = depth
+		if depth > report.MaxDepth {
+			report.MaxDepth = depth
+		}
+		for _, child := range children[node.CCID] {
+			if visited[child.CCID] {
+				continue
+			}
+			walk(child, depth+1)
+			node.Invited++
+			node.Descendants += 1 + child.Descendants
+			node.SuspendedDescendants += child.SuspendedDescendants
+			if child.Suspended {
+				node.SuspendedDescendants++
+			}
+			if node.Suspended {
+				invitedBySuspended++
+				if child.Suspended {
+					suspendedInvitedBySuspended++
+				}
+			}
+		}
+		if node.Descendants > 0 {
+			node.SuspendedRate = float64(node.SuspendedDescendants) / float64(node.Descendants)
+		}
+	}
+	for _, node := range roots {
+		walk(node, 0)
+	}
+
+	inviters := []InviterStats{}
+	for _, node := range nodes {
+		if node.Inviter != "" {
+			report.Invited++
+		}
+		if node.Suspended {
+			report.Suspended++
+		}
+		if node.Invited > 0 {
+			inviters = append(inviters, InviterStats{
+				CCID:                 node.CCID,
+				Suspended:            node.Suspended,
+				Invited:              node.Invited,
+				Descendants:          node.Descendants,
+				SuspendedDescendants: node.SuspendedDescendants,
+				SuspendedRate:        node.SuspendedRate,
+			})
+		}
+	}
+	if report.Members > 0 {
+		report.SuspendedRate = float64(report.Suspended) / float64(report.Members)
+	}
+	if invitedBySuspended > 0 {
+		report.SuspendedRateInvitedBySuspended = float64(suspendedInvitedBySuspended) / float64(invitedBySuspended)
+	}
+
+	sort.Slice(inviters, func(i, j int) bool {
+		if inviters[i].SuspendedDescendants != inviters[j].SuspendedDescendants {
+			return inviters[i].SuspendedDescendants > inviters[j].SuspendedDescendants
+		}
+		if inviters[i].Descendants != inviters[j].Descendants {
+			return inviters[i].Descendants > inviters[j].Descendants
+		}
+		return inviters[i].CCID < inviters[j].CCID
+	})
+	if len(inviters) > topInviters {
+		inviters = inviters[:topInviters]
+	}
+	report.TopInviters = inviters
+
+	if root != "" {
+		roots = nil
+		if node, ok := nodes[root]; ok {
+			roots = []*Node{node}
+		}
+	}
+	for _, node := range roots {
+		render(node, children, node.Depth+maxDepth, visited)
+	}
+	if roots == nil {
+		roots = []*Node{}
+	}
+	report.Roots = roots
+	return report
+}
+
+// render attaches the children of the node down to the depth
+func render(node *Node, children map[string][]*Node, depth int, visited map[string]bool) {
+	if node.Depth >= depth {
+		return
+	}
+	for _, child := range children[node.CCID] {
+		if !visited[child.CCID] {
+			continue
+		}
+		node.Children = append(node.Children, child)
+		render(child, children, depth, visited)
+	}
+}
@@ -0,0 +1,55 @@
+package invite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	at := func(minutes int) time.Time { return time.Unix(0, 0).Add(time.Duration(minutes) * time.Minute) }
+
+	// admin invited alice and bob. bob, suspended, invited carol and dave who were suspended as well
+	members := []Member{
+		{CCID: "admin", CDate: at(0)},
+		{CCID: "alice", Inviter: "admin", CDate: at(1)},
+		{CCID: "bob", Inviter: "admin", Suspended: true, CDate: at(2)},
+		{CCID: "carol", Inviter: "bob", Suspended: true, CDate: at(3)},
+		{CCID: "dave", Inviter: "bob", Suspended: true, CDate: at(4)},
+		{CCID: "erin", Inviter: "alice", CDate: at(5)},
+		// invited by an entity who is no longer a member
+		{CCID: "frank", Inviter: "gone", CDate: at(6)},
+	}
+
+	report := Build(members, "", 1, 10)
+	assert.Equal(t, 7, report.Members)
+	assert.Equal(t, 6, report.Invited)
+	assert.Equal(t, 3, report.Suspended)
+	assert.Equal(t, 2, report.MaxDepth)
+	assert.InDelta(t, 1.0, report.SuspendedRateInvitedBySuspended, 0.001)
+
+	assert.Len(t, report.Roots, 2)
+	admin := report.Roots[0]
+	assert.Equal(t, "admin", admin.CCID)
+	assert.Equal(t, 2, admin.Invited)
+	assert.Equal(t, 5, admin.Descendants)
+	assert.Equal(t, 3, admin.SuspendedDescendants)
+	// rendered one level down, while the counts cover the whole subtree
+	assert.Len(t, admin.Children, 2)
+	assert.Empty(t, admin.Children[1].Children)
+	assert.Equal(t, 2, admin.Children[1].Descendants)
+	assert.Equal(t, "frank", report.Roots[1].CCID)
+
+	assert.Equal(t, "admin", report.TopInviters[0].CCID)
+	assert.Equal(t, "bob", report.TopInviters[1].CCID)
+	assert.InDelta(t, 1.0, report.TopInviters[1].SuspendedRate, 0.001)
+
+	report = Build(members, "bob", 3, 10)
+	assert.Len(t, report.Roots, 1)
+	assert.Equal(t, 1, report.Roots[0].Depth)
+	assert.Len(t, report.Roots[0].Children, 2)
+
+	report = Build(members, "nobody", 3, 10)
+	assert.Empty(t, report.Roots)
+}
@@ -5,45 +5,131 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/export"
+	"github.com/totegamma/concurrent/x/reverify"
+	"github.com/totegamma/concurrent/x/scheduler"
 )
 
 type reactor struct {
-	store core.StoreService
-	job   core.JobService
+	store     core.StoreService
+	job       core.JobService
+	timeline  core.TimelineService
+	export    export.Service
+	reverify  reverify.Service
+	scheduler scheduler.Scheduler
+	config    ReactorConfig
 }
 
 type Reactor interface {
 	Start(ctx context.Context)
 }
 
+// ReactorConfig configures periodic tasks of the reactor
+type ReactorConfig struct {
+	// number of the most subscribed timelines to pre-warm on each epoch rollover. 0 disables warming.
+	ChunkWarmupCount int
+	// when to warm relative to the epoch boundary. negative value warms the upcoming epoch ahead of time.
+	ChunkWarmupOffset time.Duration
+}
+
 // Newreactor creates a new reactor
 func NewReactor(
 	store core.StoreService,
 	job core.JobService,
+	timeline core.TimelineService,
+	export export.Service,
+	reverify reverify.Service,
+	scheduler scheduler.Scheduler,
+	config ReactorConfig,
 ) Reactor {
 	return &reactor{
 		store,
 		job,
+		timeline,
+		export,
+		reverify,
+		scheduler,
+		config,
 	}
 }
 
-// Boot starts reactor
+// Boot registers the periodic tasks of the reactor to the scheduler
 func (r *reactor) Start(ctx context.Context) {
 	slog.Info("reactor start!")
 
-	ticker60 := time.NewTicker(60 * time.Second)
-	go func() {
-		for {
-			select {
-			case <-ticker60.C:
-				ctx, span := tracer.Start(ctx, "reactor.Boot.DispatchJobs")
-				r.dispatchJobs(ctx)
-				span.End()
-				break
-			}
+	err := r.scheduler.Register(scheduler.Job{
+		Name: "job.dispatch",
+		Spec: "@every 1m",
+		Run: func(ctx context.Context) error {
+			r.dispatchJobs(ctx)
+			return nil
+		},
+	})
+	if err != nil {
+		slog.Error("failed to register job dispatcher", slog.String("error", err.Error()))
+	}
+
+	// archives are kept on the local disk of the instance which built them
+	err = r.scheduler.Register(scheduler.Job{
+		Name:        "export.sweep",
+		Spec:        "@hourly",
+		PerInstance: true,
+		Run:         r.export.Sweep,
+	})
+	if err != nil {
+		slog.Error("failed to register export sweeper", slog.String("error", err.Error()))
+	}
+
+	if r.config.ChunkWarmupCount > 0 {
+		err := r.scheduler.Register(scheduler.Job{
+			Name: "timeline.chunkWarmup",
+			Schedule: scheduler.ScheduleFunc(func(now time.Time) time.Time {
+				at, _ := r.nextWarmupTime(now)
+				return at
+			}),
+			Timeout: 5 * time.Minute,
+			Run:     r.warmChunkCache,
+		})
+		if err != nil {
+			slog.Error("failed to register chunk warmup", slog.String("error", err.Error()))
 		}
-	}()
+	}
+}
+
+// nextWarmupTime returns the next time to warm chunk cache and the epoch to be warmed
+func (r *reactor) nextWarmupTime(now time.Time) (time.Time, string) {
+	epoch := core.Time2Chunk(now)
+	for {
+		boundary := core.EpochTime(epoch)
+		at := boundary.Add(r.config.ChunkWarmupOffset)
+		if at.After(now) {
+			return at, epoch
+		}
+		epoch = core.NextChunk(epoch)
+	}
+}
+
+// warmChunkCache warms chunk cache of the most subscribed timelines on each epoch rollover,
+// so that the first readers after the boundary don't all hit the database at once.
+func (r *reactor) warmChunkCache(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "reactor.WarmChunkCache")
+	defer span.End()
+
+	// the run is scheduled at boundary + offset, so the epoch is the one starting at now - offset
+	epoch := core.Time2Chunk(time.Now().Add(-r.config.ChunkWarmupOffset))
+
+	warmed, err := r.timeline.WarmChunkCache(ctx, epoch, r.config.ChunkWarmupCount)
+	if err != nil {
+		span.RecordError(err)
+		return errors.Wrapf(err, "failed to warm chunk cache of epoch %s", epoch)
+	}
+
+	logging.Info(ctx, "chunk cache warmed", slog.String("epoch", epoch), slog.Int("timelines", len(warmed)))
+	return nil
 }
 
 func (a *reactor) dispatchJobs(ctx context.Context) {
@@ -58,10 +144,16 @@ func (a *reactor) dispatchJobs(ctx context.Context) {
 	switch job.Type {
 	case "clean":
 		go a.dispatchJob(ctx, job, a.jobClean)
+	case export.JobType:
+		go a.dispatchJob(ctx, job, a.export.Build)
+	case export.SnapshotJobType:
+		go a.dispatchJob(ctx, job, a.export.BuildSnapshot)
+	case reverify.JobType:
+		go a.dispatchJob(ctx, job, a.reverify.Run)
 	case "hello":
 		go a.dispatchJob(ctx, job, a.JobHello)
 	default:
-		slog.ErrorContext(ctx, "unknown job type",
+		logging.Error(ctx, "unknown job type",
 			slog.String("type", job.Type),
 		)
 		a.job.Complete(ctx, job.ID, "failed", "unknown job type")
@@ -74,19 +166,20 @@ func (a *reactor) dispatchJob(ctx context.Context, job *core.Job, fn func(contex
 
 	result, err := fn(ctx, job)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to process job", slog.String("error", err.Error()))
+		logging.Error(ctx, "failed to process job", slog.String("error", err.Error()))
 
 		_, err = a.job.Complete(ctx, job.ID, "failed: "+result, err.Error())
 		if err != nil {
 			span.RecordError(err)
-			slog.ErrorContext(ctx, "failed to complete job", slog.String("error", err.Error()))
+			logging.Error(ctx, "failed to complete job", slog.String("error", err.Error()))
 		}
+		return
 	}
 
 	_, err = a.job.Complete(ctx, job.ID, "completed", result)
 	if err != nil {
 		span.RecordError(err)
-		slog.ErrorContext(ctx, "failed to complete job", slog.String("error", err.Error()))
+		logging.Error(ctx, "failed to complete job", slog.String("error", err.Error()))
 	}
 }
 
@@ -0,0 +1,32 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestNextWarmupTime(t *testing.T) {
+	epoch := "6000"
+	boundary := core.EpochTime(epoch)
+
+	// right after the boundary the current epoch is warmed
+	r := &reactor{config: ReactorConfig{ChunkWarmupOffset: 5 * time.Second}}
+	at, warmed := r.nextWarmupTime(boundary)
+	assert.Equal(t, boundary.Add(5*time.Second), at)
+	assert.Equal(t, epoch, warmed)
+
+	// once warmed, the next run is for the next epoch
+	at, warmed = r.nextWarmupTime(boundary.Add(5 * time.Second))
+	assert.Equal(t, core.EpochTime(core.NextChunk(epoch)).Add(5*time.Second), at)
+	assert.Equal(t, core.NextChunk(epoch), warmed)
+
+	// a negative offset warms the upcoming epoch ahead of its boundary
+	r = &reactor{config: ReactorConfig{ChunkWarmupOffset: -time.Minute}}
+	at, warmed = r.nextWarmupTime(boundary.Add(time.Minute))
+	assert.Equal(t, core.EpochTime(core.NextChunk(epoch)).Add(-time.Minute), at)
+	assert.Equal(t, core.NextChunk(epoch), warmed)
+}
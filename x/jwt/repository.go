@@ -3,6 +3,7 @@ package jwt
 import (
 	"context"
 	"github.com/redis/go-redis/v9"
+	"github.com/totegamma/concurrent/x/keyspace"
 	"time"
 )
 
@@ -26,7 +27,7 @@ func (r *repository) CheckJTI(ctx context.Context, jti string) (bool, error) {
 	defer span.End()
 
 	// check if jti exists
-	exists, err := r.rdb.Exists(ctx, "jti:"+jti).Result()
+	exists, err := r.rdb.Exists(ctx, keyspace.JTI.Key(jti)).Result()
 	if err != nil {
 		span.RecordError(err)
 		return false, err
@@ -45,7 +46,7 @@ func (r *repository) InvalidateJTI(ctx context.Context, jti string, exp time.Tim
 
 	// set jti with expiration
 	expiration := time.Until(exp)
-	err := r.rdb.Set(ctx, "jti:"+jti, "1", expiration).Err()
+	err := r.rdb.Set(ctx, keyspace.JTI.Key(jti), "1", expiration).Err()
 	if err != nil {
 		span.RecordError(err)
 		return err
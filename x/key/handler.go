@@ -1,6 +1,8 @@
 package key
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/labstack/echo/v4"
 	"go.opentelemetry.io/otel"
 	"net/http"
@@ -14,15 +16,18 @@ var tracer = otel.Tracer("key")
 type Handler interface {
 	GetKeyResolution(c echo.Context) error
 	GetKeyMine(c echo.Context) error
+	ListSessions(c echo.Context) error
+	Revoke(c echo.Context) error
 }
 
 type handler struct {
 	service core.KeyService
+	store   core.StoreService
 }
 
 // NewHandler creates a new handler
-func NewHandler(service core.KeyService) Handler {
-	return &handler{service}
+func NewHandler(service core.KeyService, store core.StoreService) Handler {
+	return &handler{service, store}
 }
 
 // GetKeyResolution is used for get key resolution
@@ -59,3 +64,74 @@ func (h *handler) GetKeyMine(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, echo.Map{"content": response})
 }
+
+// ListSessions returns the subkeys of the requester with their last use, for a client to show the signed in sessions
+func (h *handler) ListSessions(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Key.Handler.ListSessions")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	sessions, err := h.service.ListSessions(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": sessions})
+}
+
+// Revoke revokes a single subkey of the requester. the body is a signed revoke document of the key,
+// which is committed as if it was posted to /commit so that it is kept in the repository of the user
+func (h *handler) Revoke(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Key.Handler.Revoke")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	var request core.Commit
+	err := c.Bind(&request)
+	if err != nil {
+		if errors.Is(err, core.ErrorTooLarge{}) {
+			return err
+		}
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	var document core.RevokeDocument
+	err = json.Unmarshal([]byte(request.Document), &document)
+	if err != nil || document.Type != "revoke" || document.Target != c.Param("id") {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "document is not a revocation of the key"})
+	}
+
+	keys, err := h.service.GetKeyResolution(ctx, document.Target)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "key not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(keys) == 0 || keys[0].Root != requester {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "the key is not yours"})
+	}
+
+	chain, ok := ctx.Value(core.RequesterKeychainKey).([]core.Key)
+	if !ok {
+		chain = []core.Key{}
+	}
+
+	result, err := h.store.Commit(ctx, core.CommitModeExecute, request.Document, request.Signature, request.Option, chain, c.RealIP())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": result})
+}
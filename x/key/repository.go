@@ -7,10 +7,14 @@ import (
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/keyspace"
 )
 
 type Repository interface {
@@ -18,22 +22,30 @@ type Repository interface {
 	Revoke(ctx context.Context, keyID string, payload string, signature string, signedAt time.Time) (core.Key, error)
 	Get(ctx context.Context, keyID string) (core.Key, error)
 	GetAll(ctx context.Context, owner string) ([]core.Key, error)
+	GetUsages(ctx context.Context, keyIDs []string) (map[string]core.KeyUsage, error)
+	Touch(ctx context.Context, keyID, userAgent string) error
 	GetRemoteKeyResolution(ctx context.Context, remote string, keyID string) ([]core.Key, error)
+	Forget(ctx context.Context, keyID string)
 	Clean(ctx context.Context, ccid string) error
 }
 
+// the usage of a key is written to the database at most once in this span
+const keyUsageResolution = time.Minute
+
 type repository struct {
 	db     *gorm.DB
-	mc     *memcache.Client
+	rdb    *redis.Client
+	mc     cache.Cache
 	client client.Client
 }
 
 func NewRepository(
 	db *gorm.DB,
-	mc *memcache.Client,
+	rdb *redis.Client,
+	mc cache.Cache,
 	client client.Client,
 ) Repository {
-	return &repository{db, mc, client}
+	return &repository{db, rdb, mc, client}
 }
 
 func (r *repository) GetRemoteKeyResolution(ctx context.Context, remote string, keyID string) ([]core.Key, error) {
@@ -132,9 +144,50 @@ func (r *repository) Revoke(ctx context.Context, keyID string, payload string, s
 		return core.Key{}, err
 	}
 
+	r.invalidatePassports(ctx, keyID)
+
 	return key, nil
 }
 
+// Forget drops what is cached about a key of another domain, its resolution and the passports carrying it
+func (r *repository) Forget(ctx context.Context, keyID string) {
+	ctx, span := tracer.Start(ctx, "Key.Repository.Forget")
+	defer span.End()
+
+	err := r.mc.Delete(keyID)
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		span.RecordError(err)
+	}
+	r.invalidatePassports(ctx, keyID)
+}
+
+// invalidatePassports drops verified passport cache entries which carry the revoked key
+func (r *repository) invalidatePassports(ctx context.Context, keyID string) {
+	ctx, span := tracer.Start(ctx, "Key.Repository.invalidatePassports")
+	defer span.End()
+
+	if r.rdb == nil {
+		return
+	}
+
+	index := keyspace.Passport.Key("key", keyID)
+	hashes, err := r.rdb.SMembers(ctx, index).Result()
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+
+	keys := []string{index}
+	for _, hash := range hashes {
+		keys = append(keys, keyspace.Passport.Key(hash))
+	}
+
+	err = r.rdb.Del(ctx, keys...).Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+}
+
 func (r *repository) GetAll(ctx context.Context, owner string) ([]core.Key, error) {
 	ctx, span := tracer.Start(ctx, "Key.Repository.GetAll")
 	defer span.End()
@@ -148,11 +201,70 @@ func (r *repository) GetAll(ctx context.Context, owner string) ([]core.Key, erro
 	return keys, nil
 }
 
+// GetUsages returns the usage of the keys which were used at least once
+func (r *repository) GetUsages(ctx context.Context, keyIDs []string) (map[string]core.KeyUsage, error) {
+	ctx, span := tracer.Start(ctx, "Key.Repository.GetUsages")
+	defer span.End()
+
+	result := make(map[string]core.KeyUsage, len(keyIDs))
+	if len(keyIDs) == 0 {
+		return result, nil
+	}
+
+	var usages []core.KeyUsage
+	err := r.db.WithContext(ctx).Where("key_id IN ?", keyIDs).Find(&usages).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, usage := range usages {
+		result[usage.KeyID] = usage
+	}
+	return result, nil
+}
+
+// Touch records that the key signed a request now. the database is written once per keyUsageResolution
+// at most, the requests in between are only counted against the redis marker
+func (r *repository) Touch(ctx context.Context, keyID, userAgent string) error {
+	ctx, span := tracer.Start(ctx, "Key.Repository.Touch")
+	defer span.End()
+
+	if r.rdb != nil {
+		first, err := r.rdb.SetNX(ctx, keyspace.KeyUsage.Key(keyID), 1, keyUsageResolution).Result()
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if !first {
+			return nil
+		}
+	}
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_used", "user_agent"}),
+	}).Create(&core.KeyUsage{
+		KeyID:     keyID,
+		LastUsed:  time.Now(),
+		UserAgent: userAgent,
+	}).Error
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
 func (r *repository) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "Key.Repository.Clean")
 	defer span.End()
 
-	err := r.db.Where("root = ?", ccid).Delete(&core.Key{}).Error
+	err := r.db.Where("key_id IN (?)", r.db.Model(&core.Key{}).Select("id").Where("root = ?", ccid)).Delete(&core.KeyUsage{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Where("root = ?", ccid).Delete(&core.Key{}).Error
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/fixtures"
 	"github.com/totegamma/concurrent/internal/testutil"
 	"testing"
 	"time"
@@ -17,20 +18,29 @@ func TestRepository(t *testing.T) {
 	db, cleanup_db := testutil.CreateDB()
 	defer cleanup_db()
 
+	rdb, cleanup_rdb := testutil.CreateRDB()
+	defer cleanup_rdb()
+
 	mc, cleanup_mc := testutil.CreateMC()
 	defer cleanup_mc()
 
 	client := client.NewClient()
-	repo := NewRepository(db, mc, client)
+	repo := NewRepository(db, rdb, mc, client)
+
+	subkey := fixtures.User2
+	enactDocument, enactSignature := fixtures.Sign(t, fixtures.User1, core.EnactDocument{
+		DocumentBase: fixtures.Base(fixtures.User1, "enact"),
+		Target:       subkey.CKID,
+		Root:         fixtures.User1.CCID,
+		Parent:       fixtures.User1.CCID,
+	})
 
 	newkey := core.Key{
-		ID:              "cck1v26je8uyhc9x6xgcw26d3cne20s44atr7a94em",
-		Root:            "con1fk8zlkrfmens3sgj7dzcu3gsw8v9kkysrf8dt5",
-		Parent:          "con1fk8zlkrfmens3sgj7dzcu3gsw8v9kkysrf8dt5",
-		EnactDocument:   "{}",                                                                                                                                 //TODO: change to real payload
-		EnactSignature:  "8c3e365f8b085d4823eb6c824d0eceeb5a2fc194b4055052260042a3a2d40f88002eb2ccbeac62169f4c579ae1831075e887d6e7a4ac9f0ce6a91306de54ba3301", //TODO: change to real signature
-		RevokeDocument:  nil,
-		RevokeSignature: nil,
+		ID:             subkey.CKID,
+		Root:           fixtures.User1.CCID,
+		Parent:         fixtures.User1.CCID,
+		EnactDocument:  enactDocument,
+		EnactSignature: enactSignature,
 	}
 
 	created, err := repo.Enact(ctx, newkey)
@@ -45,13 +55,11 @@ func TestRepository(t *testing.T) {
 		assert.Equal(t, created.ID, found.ID)
 	}
 
-	modified, err := repo.Revoke(
-		ctx,
-		created.ID,
-		"{}",
-		"413d2b0eddf46846a0f5aa16d5cb94644877a4c17ceb76a7639166ea037166ce0fd16b0555ed9c99803a43ac2b8fa21fad5e66968bed9b10a4e709683abfe3c400",
-		time.Now(),
-	)
+	revokeDocument, revokeSignature := fixtures.Sign(t, fixtures.User1, core.RevokeDocument{
+		DocumentBase: fixtures.Base(fixtures.User1, "revoke"),
+		Target:       subkey.CKID,
+	})
+	modified, err := repo.Revoke(ctx, created.ID, revokeDocument, revokeSignature, time.Now())
 
 	if assert.NoError(t, err) {
 		assert.NotZero(t, modified.RevokeDocument)
@@ -62,4 +70,17 @@ func TestRepository(t *testing.T) {
 	if assert.NoError(t, err) {
 		assert.Equal(t, modified.ID, found.ID)
 	}
+
+	err = repo.Touch(ctx, created.ID, "first")
+	assert.NoError(t, err)
+
+	// uses within keyUsageResolution are not written again
+	err = repo.Touch(ctx, created.ID, "second")
+	assert.NoError(t, err)
+
+	usages, err := repo.GetUsages(ctx, []string{created.ID})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "first", usages[created.ID].UserAgent)
+		assert.NotZero(t, usages[created.ID].LastUsed)
+	}
 }
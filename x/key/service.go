@@ -5,10 +5,18 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/totegamma/concurrent/core"
 )
 
+// user agents longer than this are truncated before they are recorded
+const maxUserAgentLength = 256
+
 type service struct {
 	repository Repository
 	config     core.Config
@@ -44,6 +52,12 @@ func (s *service) Enact(ctx context.Context, mode core.CommitMode, payload, sign
 		return core.Key{}, fmt.Errorf("Parent is not matched with the signer")
 	}
 
+	for _, scope := range object.Scopes {
+		if err := core.ValidateScope(scope); err != nil {
+			return core.Key{}, core.NewErrorInvalidArgument(err.Error())
+		}
+	}
+
 	key := core.Key{
 		ID:             object.Target,
 		Root:           object.Root,
@@ -75,11 +89,16 @@ func (s *service) Revoke(ctx context.Context, mode core.CommitMode, payload, sig
 	}
 
 	if object.Type != "revoke" {
-		return core.Key{}, fmt.Errorf("Invalid type: %s", object.Type)
+		return core.Key{}, core.NewErrorInvalidArgument(fmt.Sprintf("invalid type: %s", object.Type))
 	}
 
 	targetKeyResolution, err := s.GetKeyResolution(ctx, object.Target)
 	if err != nil {
+		// the key belongs to another domain, which keeps it. what we cached about it is dropped,
+		// so that it is resolved again from its domain instead of being trusted until the cache expires
+		if errors.Is(err, core.ErrorNotFound{}) {
+			s.repository.Forget(ctx, object.Target)
+		}
 		span.RecordError(err)
 		return core.Key{}, err
 	}
@@ -108,6 +127,23 @@ func (s *service) Revoke(ctx context.Context, mode core.CommitMode, payload, sig
 	return revoked, nil
 }
 
+// SignedScopes returns the scopes the keys of the chain were enacted with, from the subkey to its topmost parent.
+// keys enacted without scopes don't limit the chain
+func SignedScopes(keys []core.Key) ([]core.Scopes, error) {
+	var limits []core.Scopes
+	for _, key := range keys {
+		var enact core.EnactDocument
+		err := json.Unmarshal([]byte(key.EnactDocument), &enact)
+		if err != nil {
+			return nil, err
+		}
+		if len(enact.Scopes) > 0 {
+			limits = append(limits, core.Scopes(enact.Scopes))
+		}
+	}
+	return limits, nil
+}
+
 func ValidateKeyResolution(keys []core.Key) (string, error) {
 
 	var rootKey string
@@ -236,6 +272,63 @@ func (s *service) GetAllKeys(ctx context.Context, owner string) ([]core.Key, err
 	return s.repository.GetAll(ctx, owner)
 }
 
+// ListSessions returns the subkeys enacted by the owner with when and from which user agent they were last used,
+// most recently used first
+func (s *service) ListSessions(ctx context.Context, owner string) ([]core.KeySession, error) {
+	ctx, span := tracer.Start(ctx, "Key.Service.ListSessions")
+	defer span.End()
+
+	keys, err := s.repository.GetAll(ctx, owner)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	usages, err := s.repository.GetUsages(ctx, ids)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	sessions := make([]core.KeySession, len(keys))
+	for i, key := range keys {
+		sessions[i] = core.KeySession{Key: key}
+		if usage, ok := usages[key.ID]; ok {
+			sessions[i].LastUsed = &usage.LastUsed
+			sessions[i].UserAgent = usage.UserAgent
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return lastActive(sessions[i]).After(lastActive(sessions[j]))
+	})
+
+	return sessions, nil
+}
+
+func lastActive(session core.KeySession) time.Time {
+	if session.LastUsed != nil {
+		return *session.LastUsed
+	}
+	return session.ValidSince
+}
+
+// Touch records the use of a subkey by a request of the user agent
+func (s *service) Touch(ctx context.Context, keyID, userAgent string) error {
+	ctx, span := tracer.Start(ctx, "Key.Service.Touch")
+	defer span.End()
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	return s.repository.Touch(ctx, keyID, userAgent)
+}
+
 func (s *service) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "Key.Service.Clean")
 	defer span.End()
@@ -0,0 +1,40 @@
+package key
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// remoteKeyRepository knows none of the keys, as they belong to another domain
+type remoteKeyRepository struct {
+	Repository
+	forgotten []string
+}
+
+func (r *remoteKeyRepository) Get(ctx context.Context, keyID string) (core.Key, error) {
+	return core.Key{}, core.NewErrorNotFound()
+}
+
+func (r *remoteKeyRepository) Forget(ctx context.Context, keyID string) {
+	r.forgotten = append(r.forgotten, keyID)
+}
+
+func TestRevokeRemoteKey(t *testing.T) {
+	repo := &remoteKeyRepository{}
+	s := &service{repository: repo}
+
+	document, err := json.Marshal(core.RevokeDocument{
+		DocumentBase: core.DocumentBase[any]{Signer: "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2", Type: "revoke"},
+		Target:       "cck1remotekey",
+	})
+	assert.NoError(t, err)
+
+	_, err = s.Revoke(context.Background(), core.CommitModeExecute, string(document), "")
+	assert.ErrorIs(t, err, core.ErrorNotFound{})
+	assert.Equal(t, []string{"cck1remotekey"}, repo.forgotten)
+}
@@ -0,0 +1,158 @@
+package keyspace
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Namespace is a group of redis keys owned by one feature.
+// Critical namespaces hold state which must not be lost silently (e.g. revoked jti),
+// so writes to them are never refused even when their budget is exceeded.
+// budgets and usage are kept per redis database, as every tenant has its own one.
+type Namespace struct {
+	Name     string
+	Prefix   string
+	Match    string // optional key pattern, defaults to "<prefix>:*". only '*' is a wildcard
+	Critical bool
+
+	usages sync.Map // database -> *usage
+}
+
+// usage is the state of a namespace in one redis database
+type usage struct {
+	budget atomic.Int64
+	keys   atomic.Int64
+}
+
+var (
+	JTI             = &Namespace{Name: "jti", Prefix: "jti", Critical: true}
+	RateLimit       = &Namespace{Name: "rate_limit", Prefix: "rate_limit", Critical: true}
+	Policy          = &Namespace{Name: "policy", Prefix: "policy"}
+	Passport        = &Namespace{Name: "passport", Prefix: "passport"}
+	TimelineRetract = &Namespace{Name: "timeline_retract", Prefix: "timeline", Match: "timeline:*:deleted", Critical: true}
+	Store           = &Namespace{Name: "store", Prefix: "store", Critical: true}
+	ActivityPub     = &Namespace{Name: "activitypub", Prefix: "ap", Critical: true}
+	Scheduler       = &Namespace{Name: "scheduler", Prefix: "scheduler", Critical: true}
+	ReadState       = &Namespace{Name: "readstate", Prefix: "readstate", Critical: true}
+	Quota           = &Namespace{Name: "quota", Prefix: "quota", Critical: true}
+	Trend           = &Namespace{Name: "trend", Prefix: "trend"}
+	Cache           = &Namespace{Name: "cache", Prefix: "cache"}
+	Realtime        = &Namespace{Name: "realtime", Prefix: "realtime"}
+	Relay           = &Namespace{Name: "relay", Prefix: "relay"}
+	KeyUsage        = &Namespace{Name: "key_usage", Prefix: "keyusage"}
+	Backfill        = &Namespace{Name: "backfill", Prefix: "backfill"}
+	// the namespaces below are parts of the ones above, measured on their own
+	Normalization    = &Namespace{Name: "normalization", Prefix: "cache", Match: "cache:*tl:norm:*"}
+	Outbox           = &Namespace{Name: "outbox", Prefix: "ap", Match: "ap:published:*", Critical: true}
+	Session          = &Namespace{Name: "session", Prefix: "realtime", Match: "realtime:session:*", Critical: true}
+	DefaultNamespace = []*Namespace{JTI, RateLimit, Policy, Passport, TimelineRetract, Store, ActivityPub, Scheduler, ReadState, Quota, Trend, Cache, Realtime, Relay, KeyUsage, Backfill, Normalization, Outbox, Session}
+)
+
+// Key builds a redis key in the namespace
+func (n *Namespace) Key(parts ...string) string {
+	return n.Prefix + ":" + strings.Join(parts, ":")
+}
+
+// Pattern returns a pattern which matches all keys in the namespace
+func (n *Namespace) Pattern() string {
+	if n.Match != "" {
+		return n.Match
+	}
+	return n.Prefix + ":*"
+}
+
+// Contains reports whether the key belongs to the namespace
+func (n *Namespace) Contains(key string) bool {
+	return match(n.Pattern(), key)
+}
+
+func (n *Namespace) in(rdb *redis.Client) *usage {
+	u, _ := n.usages.LoadOrStore(Database(rdb), &usage{})
+	return u.(*usage)
+}
+
+// SetBudget sets the maximum number of keys allowed in the namespace of the database. 0 means unlimited.
+func (n *Namespace) SetBudget(rdb *redis.Client, budget int64) {
+	n.in(rdb).budget.Store(budget)
+}
+
+// Budget returns the maximum number of keys allowed in the namespace of the database
+func (n *Namespace) Budget(rdb *redis.Client) int64 {
+	return n.in(rdb).budget.Load()
+}
+
+// Usage returns the number of keys estimated at the last check of the database
+func (n *Namespace) Usage(rdb *redis.Client) int64 {
+	return n.in(rdb).keys.Load()
+}
+
+// Exceeded reports whether the last estimated usage in the database is over the budget
+func (n *Namespace) Exceeded(rdb *redis.Client) bool {
+	u := n.in(rdb)
+	budget := u.budget.Load()
+	return budget > 0 && u.keys.Load() >= budget
+}
+
+// Allow reports whether a new key may be written to the namespace of the database.
+// critical namespaces are always allowed; only alarms are raised for them.
+func (n *Namespace) Allow(rdb *redis.Client) bool {
+	if n.Critical {
+		return true
+	}
+	return !n.Exceeded(rdb)
+}
+
+// AllowKey reports whether the key may be written to the database,
+// checking every namespace the key belongs to
+func AllowKey(rdb *redis.Client, key string) bool {
+	for _, ns := range DefaultNamespace {
+		if ns.Contains(key) && !ns.Allow(rdb) {
+			return false
+		}
+	}
+	return true
+}
+
+// Database identifies the redis database of the client
+func Database(rdb *redis.Client) string {
+	if rdb == nil {
+		return ""
+	}
+	options := rdb.Options()
+	return options.Addr + "/" + strconv.Itoa(options.DB)
+}
+
+// Find returns the namespace registered with given name
+func Find(name string) (*Namespace, bool) {
+	for _, ns := range DefaultNamespace {
+		if ns.Name == name {
+			return ns, true
+		}
+	}
+	return nil, false
+}
+
+// match matches the key against a glob pattern where '*' is the only wildcard
+func match(pattern, key string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == key
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	return len(key) >= len(last) && strings.HasSuffix(key, last)
+}
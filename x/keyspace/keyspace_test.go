@@ -0,0 +1,88 @@
+package keyspace
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceAllow(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 0})
+	tenant := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+
+	ns := &Namespace{Name: "cache", Prefix: "cache"}
+	assert.Equal(t, "cache:a:b", ns.Key("a", "b"))
+	assert.Equal(t, "cache:*", ns.Pattern())
+
+	// unlimited
+	ns.in(rdb).keys.Store(100)
+	assert.True(t, ns.Allow(rdb))
+
+	ns.SetBudget(rdb, 100)
+	assert.True(t, ns.Exceeded(rdb))
+	assert.False(t, ns.Allow(rdb))
+
+	// the database of another tenant is budgeted on its own
+	ns.SetBudget(tenant, 100)
+	ns.in(tenant).keys.Store(10)
+	assert.False(t, ns.Exceeded(tenant))
+	assert.True(t, ns.Allow(tenant))
+
+	critical := &Namespace{Name: "jti", Prefix: "jti", Critical: true}
+	critical.SetBudget(rdb, 1)
+	critical.in(rdb).keys.Store(2)
+	assert.True(t, critical.Exceeded(rdb))
+	assert.True(t, critical.Allow(rdb))
+}
+
+func TestNamespaceContains(t *testing.T) {
+	assert.True(t, JTI.Contains("jti:abc"))
+	assert.False(t, JTI.Contains("jtix"))
+	assert.True(t, TimelineRetract.Contains("timeline:t1@example.com:deleted"))
+	assert.False(t, TimelineRetract.Contains("timeline:t1@example.com"))
+	assert.True(t, Normalization.Contains("cache:tl:norm:t1"))
+	assert.True(t, Normalization.Contains("cache:example.com:tl:norm:t1"))
+	assert.False(t, Normalization.Contains("cache:tl:body:t1"))
+	assert.True(t, Outbox.Contains("ap:published:m1"))
+	assert.True(t, Session.Contains("realtime:session:token:meta"))
+}
+
+func TestAllowKey(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 14})
+	t.Cleanup(func() {
+		Cache.SetBudget(rdb, 0)
+		Normalization.SetBudget(rdb, 0)
+	})
+
+	Normalization.SetBudget(rdb, 1)
+	Normalization.in(rdb).keys.Store(1)
+	assert.False(t, AllowKey(rdb, "cache:tl:norm:t1"))
+	assert.True(t, AllowKey(rdb, "cache:tl:body:t1"))
+}
+
+func TestExtrapolate(t *testing.T) {
+	namespaces := []*Namespace{JTI, Cache, Normalization}
+	samples := []sample{
+		{"jti:a", 100},
+		{"jti:b", 100},
+		{"cache:tl:norm:t1", 50},
+		{"cache:tl:body:t1", 1000},
+	}
+
+	result := extrapolate(namespaces, samples, 1000)
+	assert.Equal(t, estimation{keys: 500, bytes: 50000}, result["jti"])
+	assert.Equal(t, estimation{keys: 500, bytes: 262500}, result["cache"])
+	assert.Equal(t, estimation{keys: 250, bytes: 12500}, result["normalization"])
+
+	assert.Empty(t, extrapolate(namespaces, nil, 1000))
+}
+
+func TestParseInfo(t *testing.T) {
+	info := "# Memory\r\nused_memory:1024\r\nmaxmemory:2048\r\nmaxmemory_policy:allkeys-lru\r\n\r\n# Stats\r\nevicted_keys:3\r\n"
+	stats := parseInfo(info)
+	assert.Equal(t, "1024", stats["used_memory"])
+	assert.Equal(t, "2048", stats["maxmemory"])
+	assert.Equal(t, "allkeys-lru", stats["maxmemory_policy"])
+	assert.Equal(t, "3", stats["evicted_keys"])
+}
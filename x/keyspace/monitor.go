@@ -0,0 +1,366 @@
+package keyspace
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("keyspace")
+
+const (
+	defaultInterval    = 60 * time.Second
+	defaultWarnRatio   = 0.8
+	defaultSampleSize  = 1000
+	nonEvictingPolicy  = "noeviction"
+	volatileEvictStart = "volatile-"
+	allkeysEvictStart  = "allkeys-"
+)
+
+var (
+	keyCountMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_redis_keyspace_keys",
+			Help: "estimated number of redis keys per feature namespace",
+		},
+		[]string{"domain", "namespace"},
+	)
+	keyBytesMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_redis_keyspace_bytes",
+			Help: "estimated memory used by the keys of each feature namespace",
+		},
+		[]string{"domain", "namespace"},
+	)
+	keyBudgetMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_redis_keyspace_budget",
+			Help: "key budget per feature namespace (0 = unlimited)",
+		},
+		[]string{"domain", "namespace"},
+	)
+	memoryMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_redis_memory_bytes",
+			Help: "redis memory usage",
+		},
+		[]string{"domain", "type"},
+	)
+	evictedMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_redis_evicted_keys",
+			Help: "number of keys evicted by redis since startup",
+		},
+		[]string{"domain"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(keyCountMetrics, keyBytesMetrics, keyBudgetMetrics, memoryMetrics, evictedMetrics)
+}
+
+// Config configures the keyspace Monitor
+type Config struct {
+	Domain     string           // fqdn of the domain owning the database, used as the metric label
+	Budgets    map[string]int64 // namespace name -> max keys
+	WarnRatio  float64          // used_memory / maxmemory ratio to alarm at
+	SampleSize int              // number of random keys sampled at each check
+	Interval   time.Duration
+}
+
+// Monitor periodically measures redis key usage per namespace and memory pressure
+type Monitor interface {
+	Start(ctx context.Context)
+	Check(ctx context.Context) error
+}
+
+type monitor struct {
+	rdb        *redis.Client
+	domain     string
+	namespaces []*Namespace
+	warnRatio  float64
+	sampleSize int
+	interval   time.Duration
+	evicted    int64
+}
+
+// NewMonitor creates a new keyspace monitor of the redis database of one domain
+func NewMonitor(rdb *redis.Client, config Config) Monitor {
+	for name, budget := range config.Budgets {
+		ns, ok := Find(name)
+		if !ok {
+			slog.Warn("unknown keyspace namespace in budget config", slog.String("namespace", name), slog.String("module", "keyspace"))
+			continue
+		}
+		ns.SetBudget(rdb, budget)
+	}
+
+	sampleSize := config.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultSampleSize
+	}
+
+	warnRatio := config.WarnRatio
+	if warnRatio <= 0 {
+		warnRatio = defaultWarnRatio
+	}
+
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &monitor{
+		rdb:        rdb,
+		domain:     config.Domain,
+		namespaces: DefaultNamespace,
+		warnRatio:  warnRatio,
+		sampleSize: sampleSize,
+		interval:   interval,
+		evicted:    -1,
+	}
+}
+
+// Start checks eviction policy once and then watches usage periodically
+func (m *monitor) Start(ctx context.Context) {
+	m.checkEvictionPolicy(ctx)
+
+	ticker := time.NewTicker(m.interval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				err := m.Check(ctx)
+				if err != nil {
+					slog.ErrorContext(ctx, "failed to check redis keyspace", slog.String("domain", m.domain), slog.String("error", err.Error()), slog.String("module", "keyspace"))
+				}
+			}
+		}
+	}()
+}
+
+// Check estimates the usage of every namespace and reads the memory stats once
+func (m *monitor) Check(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Keyspace.Monitor.Check")
+	defer span.End()
+
+	estimates, err := m.estimate(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	for _, ns := range m.namespaces {
+		count := estimates[ns.Name].keys
+		ns.in(m.rdb).keys.Store(count)
+
+		keyCountMetrics.WithLabelValues(m.domain, ns.Name).Set(float64(count))
+		keyBytesMetrics.WithLabelValues(m.domain, ns.Name).Set(float64(estimates[ns.Name].bytes))
+		keyBudgetMetrics.WithLabelValues(m.domain, ns.Name).Set(float64(ns.Budget(m.rdb)))
+
+		if ns.Exceeded(m.rdb) {
+			level := slog.LevelWarn
+			if ns.Critical {
+				level = slog.LevelError
+			}
+			slog.Log(
+				ctx, level, "redis keyspace budget exceeded",
+				slog.String("domain", m.domain),
+				slog.String("namespace", ns.Name),
+				slog.Int64("usage", count),
+				slog.Int64("budget", ns.Budget(m.rdb)),
+				slog.Bool("critical", ns.Critical),
+				slog.String("module", "keyspace"),
+			)
+		}
+	}
+
+	info, err := m.rdb.Info(ctx, "memory", "stats").Result()
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	stats := parseInfo(info)
+
+	used, _ := strconv.ParseInt(stats["used_memory"], 10, 64)
+	max, _ := strconv.ParseInt(stats["maxmemory"], 10, 64)
+	evicted, _ := strconv.ParseInt(stats["evicted_keys"], 10, 64)
+
+	memoryMetrics.WithLabelValues(m.domain, "used").Set(float64(used))
+	memoryMetrics.WithLabelValues(m.domain, "max").Set(float64(max))
+	evictedMetrics.WithLabelValues(m.domain).Set(float64(evicted))
+
+	if max > 0 && float64(used)/float64(max) >= m.warnRatio {
+		slog.ErrorContext(
+			ctx, "redis memory usage is approaching maxmemory. eviction may drop auth/jti state",
+			slog.String("domain", m.domain),
+			slog.Int64("used", used),
+			slog.Int64("maxmemory", max),
+			slog.String("policy", stats["maxmemory_policy"]),
+			slog.String("module", "keyspace"),
+		)
+	}
+
+	if m.evicted >= 0 && evicted > m.evicted {
+		slog.ErrorContext(
+			ctx, "redis evicted keys",
+			slog.String("domain", m.domain),
+			slog.Int64("evicted", evicted-m.evicted),
+			slog.String("policy", stats["maxmemory_policy"]),
+			slog.String("module", "keyspace"),
+		)
+	}
+	m.evicted = evicted
+
+	return nil
+}
+
+// estimation is the usage of a namespace extrapolated from the sampled keys
+type estimation struct {
+	keys  int64
+	bytes int64
+}
+
+// estimate samples random keys of the database instead of scanning it,
+// so that a check costs the same however many keys the database holds
+func (m *monitor) estimate(ctx context.Context) (map[string]estimation, error) {
+	ctx, span := tracer.Start(ctx, "Keyspace.Monitor.Estimate")
+	defer span.End()
+
+	total, err := m.rdb.DBSize(ctx).Result()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if total == 0 {
+		return map[string]estimation{}, nil
+	}
+
+	size := m.sampleSize
+	if int64(size) > total {
+		size = int(total)
+	}
+
+	pipe := m.rdb.Pipeline()
+	randoms := make([]*redis.StringCmd, size)
+	for i := range randoms {
+		randoms[i] = pipe.RandomKey(ctx)
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	keys := make([]string, 0, size)
+	for _, cmd := range randoms {
+		if key, err := cmd.Result(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	// keys may expire between the two pipelines, they are then counted with no memory
+	pipe = m.rdb.Pipeline()
+	usages := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		usages[i] = pipe.MemoryUsage(ctx, key)
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	samples := make([]sample, len(keys))
+	for i, key := range keys {
+		bytes, _ := usages[i].Result()
+		samples[i] = sample{key, bytes}
+	}
+
+	return extrapolate(m.namespaces, samples, total), nil
+}
+
+// sample is a randomly picked key and its memory usage
+type sample struct {
+	key   string
+	bytes int64
+}
+
+// extrapolate scales what the samples show of each namespace to the total number of keys
+func extrapolate(namespaces []*Namespace, samples []sample, total int64) map[string]estimation {
+	result := make(map[string]estimation, len(namespaces))
+	if len(samples) == 0 {
+		return result
+	}
+
+	for _, ns := range namespaces {
+		var keys, bytes int64
+		for _, s := range samples {
+			if ns.Contains(s.key) {
+				keys++
+				bytes += s.bytes
+			}
+		}
+		result[ns.Name] = estimation{
+			keys:  keys * total / int64(len(samples)),
+			bytes: bytes * total / int64(len(samples)),
+		}
+	}
+	return result
+}
+
+// checkEvictionPolicy warns when the configured maxmemory-policy is able to evict critical keys
+func (m *monitor) checkEvictionPolicy(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "Keyspace.Monitor.CheckEvictionPolicy")
+	defer span.End()
+
+	info, err := m.rdb.Info(ctx, "memory").Result()
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "failed to read redis memory info", slog.String("error", err.Error()), slog.String("module", "keyspace"))
+		return
+	}
+	stats := parseInfo(info)
+
+	policy := stats["maxmemory_policy"]
+	if stats["maxmemory"] == "0" || policy == "" || policy == nonEvictingPolicy {
+		return
+	}
+
+	// jti and rate limit keys always carry a TTL, so both volatile-* and allkeys-* may evict them.
+	if strings.HasPrefix(policy, volatileEvictStart) || strings.HasPrefix(policy, allkeysEvictStart) {
+		slog.WarnContext(
+			ctx, "redis maxmemory-policy can evict critical keys. consider 'noeviction' or a dedicated instance",
+			slog.String("domain", m.domain),
+			slog.String("policy", policy),
+			slog.String("module", "keyspace"),
+		)
+	}
+}
+
+// parseInfo parses the output of the redis INFO command into key/value pairs
+func parseInfo(info string) map[string]string {
+	result := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		result[split[0]] = split[1]
+	}
+	return result
+}
@@ -0,0 +1,249 @@
+// Package loadshed rejects low priority requests while the database or redis respond slowly,
+// so that commits and authentication stay responsive when the domain is saturated
+package loadshed
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+var tracer = otel.Tracer("loadshed")
+
+const (
+	defaultInterval     = time.Second
+	defaultDBLatency    = 200 * time.Millisecond
+	defaultRedisLatency = 50 * time.Millisecond
+	defaultRetryAfter   = 5 * time.Second
+
+	// weight of the newest sample in the moving average of the latencies
+	smoothing = 0.3
+	// at this pressure the requests of authenticated users are shed too
+	shedAllPressure = 2.0
+	// a probe which fails counts as this many times its threshold
+	failurePressure = 4.0
+)
+
+var (
+	pressureMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cc_loadshed_pressure",
+			Help: "smoothed backend latency relative to its threshold",
+		},
+		[]string{"fqdn", "probe"},
+	)
+	rejectedMetrics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cc_loadshed_rejected_total",
+			Help: "requests rejected by load shedding",
+		},
+		[]string{"fqdn", "priority"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(pressureMetrics, rejectedMetrics)
+}
+
+// Config configures the load shedding. it is off unless enabled
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often the backends are probed
+	Interval time.Duration `yaml:"interval"`
+	// DBLatency and RedisLatency are the latencies of a ping over which the backend is considered saturated
+	DBLatency    time.Duration `yaml:"dbLatency"`
+	RedisLatency time.Duration `yaml:"redisLatency"`
+	// RetryAfter is sent to the rejected clients
+	RetryAfter time.Duration `yaml:"retryAfter"`
+}
+
+// WithDefaults returns the config with the unset values filled
+func (c Config) WithDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.DBLatency <= 0 {
+		c.DBLatency = defaultDBLatency
+	}
+	if c.RedisLatency <= 0 {
+		c.RedisLatency = defaultRedisLatency
+	}
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = defaultRetryAfter
+	}
+	return c
+}
+
+// Priority is how important a request is to keep serving under load
+type Priority int
+
+const (
+	// PriorityLow are anonymous reads, shed first
+	PriorityLow Priority = iota
+	// PriorityNormal are the requests of authenticated requesters and writes
+	PriorityNormal
+	// PriorityCritical are commits and authentication, never shed
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	default:
+		return "critical"
+	}
+}
+
+// Classify returns the priority of a request. the requester is known once the auth middleware has run
+func Classify(c echo.Context) Priority {
+	path := c.Path()
+	if path == "/commit" || strings.HasPrefix(path, "/auth/") {
+		return PriorityCritical
+	}
+
+	method := c.Request().Method
+	requesterType, _ := c.Request().Context().Value(core.RequesterTypeCtxKey).(int)
+	if requesterType == core.Unknown && (method == http.MethodGet || method == http.MethodHead) {
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// Probe measures the latency of a backend
+type Probe struct {
+	Name      string
+	Threshold time.Duration
+	Ping      func(ctx context.Context) error
+}
+
+// Shedder keeps the pressure of the backends from periodic probes, and rejects requests by it
+type Shedder struct {
+	fqdn      string
+	config    Config
+	probes    []Probe
+	now       func() time.Time
+	mu        sync.RWMutex
+	pressures map[string]float64
+}
+
+// New creates a new Shedder probing the backends
+func New(fqdn string, config Config, probes ...Probe) *Shedder {
+	return &Shedder{
+		fqdn:      fqdn,
+		config:    config.WithDefaults(),
+		probes:    probes,
+		now:       time.Now,
+		pressures: map[string]float64{},
+	}
+}
+
+// Start probes the backends every interval until the context is done
+func (s *Shedder) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(s.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				s.Sample(ctx)
+			}
+		}
+	}()
+}
+
+// Sample probes every backend once and updates its pressure
+func (s *Shedder) Sample(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "LoadShed.Shedder.Sample")
+	defer span.End()
+
+	for _, probe := range s.probes {
+		pctx, cancel := context.WithTimeout(ctx, probe.Threshold*time.Duration(failurePressure))
+		start := s.now()
+		err := probe.Ping(pctx)
+		elapsed := s.now().Sub(start)
+		cancel()
+
+		pressure := float64(elapsed) / float64(probe.Threshold)
+		if err != nil {
+			span.RecordError(err)
+			pressure = math.Max(pressure, failurePressure)
+		}
+		s.record(ctx, probe.Name, pressure)
+	}
+}
+
+func (s *Shedder) record(ctx context.Context, name string, pressure float64) {
+	s.mu.Lock()
+	previous, ok := s.pressures[name]
+	if ok {
+		pressure = smoothing*pressure + (1-smoothing)*previous
+	}
+	s.pressures[name] = pressure
+	s.mu.Unlock()
+
+	pressureMetrics.WithLabelValues(s.fqdn, name).Set(pressure)
+
+	if pressure >= 1 && (!ok || previous < 1) {
+		slog.WarnContext(ctx, "backend is saturated, shedding load", slog.String("probe", name), slog.Float64("pressure", pressure), slog.String("module", "loadshed"))
+	} else if pressure < 1 && ok && previous >= 1 {
+		slog.InfoContext(ctx, "backend recovered, stopped shedding load", slog.String("probe", name), slog.Float64("pressure", pressure), slog.String("module", "loadshed"))
+	}
+}
+
+// Pressure returns the highest pressure of the backends. 1 is a backend at its threshold
+func (s *Shedder) Pressure() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	max := 0.0
+	for _, pressure := range s.pressures {
+		max = math.Max(max, pressure)
+	}
+	return max
+}
+
+// Allow returns whether a request of the priority is served at the current pressure
+func (s *Shedder) Allow(priority Priority) bool {
+	if !s.config.Enabled || priority == PriorityCritical {
+		return true
+	}
+	pressure := s.Pressure()
+	if priority == PriorityLow {
+		return pressure < 1
+	}
+	return pressure < shedAllPressure
+}
+
+// Middleware rejects the requests which are not allowed with 503 and Retry-After
+func (s *Shedder) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		priority := Classify(c)
+		if s.Allow(priority) {
+			return next(c)
+		}
+
+		rejectedMetrics.WithLabelValues(s.fqdn, priority.String()).Inc()
+		c.Response().Header().Set("Retry-After", strconv.FormatInt(int64(s.config.RetryAfter.Seconds()), 10))
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{"status": "error", "error": "the server is overloaded, try again later"})
+	}
+}
@@ -0,0 +1,87 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestShedder(t *testing.T) {
+	// every ping takes the latency of the probe on the fake clock
+	latency := map[string]time.Duration{"db": 10 * time.Millisecond, "redis": time.Millisecond}
+	var failure error
+	clock := time.Unix(0, 0)
+
+	probe := func(name string, threshold time.Duration) Probe {
+		return Probe{Name: name, Threshold: threshold, Ping: func(ctx context.Context) error {
+			clock = clock.Add(latency[name])
+			return failure
+		}}
+	}
+	s := New("example.com", Config{Enabled: true}, probe("db", 100*time.Millisecond), probe("redis", 10*time.Millisecond))
+	s.now = func() time.Time { return clock }
+
+	s.Sample(context.Background())
+	assert.InDelta(t, 0.1, s.Pressure(), 0.001)
+	assert.True(t, s.Allow(PriorityLow))
+
+	// the moving average rises over 1 on the second slow sample
+	latency["db"] = 300 * time.Millisecond
+	s.Sample(context.Background())
+	assert.True(t, s.Allow(PriorityLow))
+	s.Sample(context.Background())
+	assert.False(t, s.Allow(PriorityLow))
+	assert.True(t, s.Allow(PriorityNormal))
+
+	// failing probes shed everything but the critical requests
+	failure = errors.New("connection refused")
+	for i := 0; i < 5; i++ {
+		s.Sample(context.Background())
+	}
+	assert.False(t, s.Allow(PriorityNormal))
+	assert.True(t, s.Allow(PriorityCritical))
+
+	e := echo.New()
+	handler := s.Middleware(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/timelines/recent", nil), rec)
+	c.SetPath("/timelines/recent")
+	assert.NoError(t, handler(c))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodPost, "/commit", nil), rec)
+	c.SetPath("/commit")
+	assert.NoError(t, handler(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// disabled shedders allow everything
+	assert.True(t, New("example.com", Config{}).Allow(PriorityLow))
+}
+
+func TestClassify(t *testing.T) {
+	e := echo.New()
+	classify := func(method, path string, requesterType int) Priority {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), core.RequesterTypeCtxKey, requesterType))
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetPath(path)
+		return Classify(c)
+	}
+
+	assert.Equal(t, PriorityCritical, classify(http.MethodPost, "/commit", core.Unknown))
+	assert.Equal(t, PriorityCritical, classify(http.MethodGet, "/auth/passport", core.LocalUser))
+	assert.Equal(t, PriorityLow, classify(http.MethodGet, "/timelines/recent", core.Unknown))
+	assert.Equal(t, PriorityNormal, classify(http.MethodGet, "/timelines/recent", core.LocalUser))
+	assert.Equal(t, PriorityNormal, classify(http.MethodPost, "/notification", core.Unknown))
+}
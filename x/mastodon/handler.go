@@ -0,0 +1,147 @@
+package mastodon
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests.
+// responses are the entities as they are, not wrapped in status and content like the rest of the api,
+// and errors are {"error": ...} from core.HTTPErrorHandler, which is what Mastodon clients expect
+type Handler interface {
+	Instance(c echo.Context) error
+	PublicTimeline(c echo.Context) error
+	GetAccount(c echo.Context) error
+	LookupAccount(c echo.Context) error
+	AccountStatuses(c echo.Context) error
+	GetStatus(c echo.Context) error
+	GetStatusContext(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service: service}
+}
+
+// Instance returns the description of the domain
+func (h handler) Instance(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.Instance")
+	defer span.End()
+
+	instance, err := h.service.Instance(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, instance)
+}
+
+// PublicTimeline returns the public timeline of the domain
+func (h handler) PublicTimeline(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.PublicTimeline")
+	defer span.End()
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	statuses, err := h.service.PublicTimeline(ctx, c.QueryParam("max_id"), limit)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return statusPage(c, statuses)
+}
+
+// GetAccount returns an account by its id
+func (h handler) GetAccount(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.GetAccount")
+	defer span.End()
+
+	account, err := h.service.Account(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, account)
+}
+
+// LookupAccount returns an account by its acct
+func (h handler) LookupAccount(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.LookupAccount")
+	defer span.End()
+
+	account, err := h.service.LookupAccount(ctx, c.QueryParam("acct"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, account)
+}
+
+// AccountStatuses returns the statuses of an account
+func (h handler) AccountStatuses(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.AccountStatuses")
+	defer span.End()
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	statuses, err := h.service.AccountStatuses(ctx, c.Param("id"), c.QueryParam("max_id"), limit)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return statusPage(c, statuses)
+}
+
+// GetStatus returns a status by its id
+func (h handler) GetStatus(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.GetStatus")
+	defer span.End()
+
+	status, err := h.service.Status(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// GetStatusContext returns the thread of a status. it is empty until threads are mapped
+func (h handler) GetStatusContext(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mastodon.Handler.GetStatusContext")
+	defer span.End()
+
+	_, err := h.service.Status(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, Context{Ancestors: []Status{}, Descendants: []Status{}})
+}
+
+// statusPage writes the statuses with the Link header clients page with
+func statusPage(c echo.Context, statuses []Status) error {
+	if len(statuses) > 0 {
+		next := *c.Request().URL
+		query := next.Query()
+		query.Set("max_id", statuses[len(statuses)-1].ID)
+		next.RawQuery = query.Encode()
+		c.Response().Header().Set("Link", "<"+pageURL(c, next)+">; rel=\"next\"")
+	}
+	return c.JSON(http.StatusOK, statuses)
+}
+
+func pageURL(c echo.Context, u url.URL) string {
+	u.Scheme = "https"
+	u.Host = c.Request().Host
+	// the gateway strips the prefix of the api before passing requests on
+	if !strings.HasPrefix(u.Path, "/api/v1/") {
+		u.Path = "/api/v1" + u.Path
+	}
+	return u.String()
+}
@@ -0,0 +1,81 @@
+package mastodon
+
+import (
+	"time"
+)
+
+// Account is a concurrent entity as a Mastodon account
+type Account struct {
+	ID             string    `json:"id"`
+	Username       string    `json:"username"`
+	Acct           string    `json:"acct"`
+	DisplayName    string    `json:"display_name"`
+	Locked         bool      `json:"locked"`
+	Bot            bool      `json:"bot"`
+	CreatedAt      time.Time `json:"created_at"`
+	Note           string    `json:"note"`
+	URL            string    `json:"url"`
+	Avatar         string    `json:"avatar"`
+	AvatarStatic   string    `json:"avatar_static"`
+	Header         string    `json:"header"`
+	HeaderStatic   string    `json:"header_static"`
+	FollowersCount int64     `json:"followers_count"`
+	FollowingCount int64     `json:"following_count"`
+	StatusesCount  int64     `json:"statuses_count"`
+	Emojis         []any     `json:"emojis"`
+	Fields         []any     `json:"fields"`
+}
+
+// Status is a concurrent message as a Mastodon status.
+// clients expect every list to be present, so they are empty rather than omitted
+type Status struct {
+	ID                 string    `json:"id"`
+	URI                string    `json:"uri"`
+	URL                string    `json:"url"`
+	CreatedAt          time.Time `json:"created_at"`
+	Account            Account   `json:"account"`
+	Content            string    `json:"content"`
+	Visibility         string    `json:"visibility"`
+	Sensitive          bool      `json:"sensitive"`
+	SpoilerText        string    `json:"spoiler_text"`
+	MediaAttachments   []any     `json:"media_attachments"`
+	Mentions           []any     `json:"mentions"`
+	Tags               []any     `json:"tags"`
+	Emojis             []any     `json:"emojis"`
+	RepliesCount       int64     `json:"replies_count"`
+	ReblogsCount       int64     `json:"reblogs_count"`
+	FavouritesCount    int64     `json:"favourites_count"`
+	InReplyToID        *string   `json:"in_reply_to_id"`
+	InReplyToAccountID *string   `json:"in_reply_to_account_id"`
+	Reblog             *Status   `json:"reblog"`
+	Language           *string   `json:"language"`
+}
+
+// Context is the thread around a status. threads are not mapped yet, so both are empty
+type Context struct {
+	Ancestors   []Status `json:"ancestors"`
+	Descendants []Status `json:"descendants"`
+}
+
+// InstanceStats are the counts shown by clients on the instance page
+type InstanceStats struct {
+	UserCount   int64 `json:"user_count"`
+	StatusCount int64 `json:"status_count"`
+	DomainCount int64 `json:"domain_count"`
+}
+
+// Instance describes the domain to Mastodon clients (v1)
+type Instance struct {
+	URI              string            `json:"uri"`
+	Title            string            `json:"title"`
+	ShortDescription string            `json:"short_description"`
+	Description      string            `json:"description"`
+	Email            string            `json:"email"`
+	Version          string            `json:"version"`
+	URLs             map[string]string `json:"urls"`
+	Stats            InstanceStats     `json:"stats"`
+	Languages        []string          `json:"languages"`
+	Registrations    bool              `json:"registrations"`
+	ApprovalRequired bool              `json:"approval_required"`
+	InvitesEnabled   bool              `json:"invites_enabled"`
+}
@@ -0,0 +1,324 @@
+// Package mastodon exposes a read-only subset of the Mastodon client API,
+// so that Mastodon clients can browse public timelines, statuses and accounts of the domain
+package mastodon
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/cdid"
+	"github.com/totegamma/concurrent/core"
+)
+
+var tracer = otel.Tracer("mastodon")
+
+const (
+	defaultLimit = 20
+	maxLimit     = 40
+
+	// compatibleVersion is the Mastodon version clients see, as they enable features by it
+	compatibleVersion = "4.0.0"
+
+	// mainProfileSemanticID is the profile clients of concurrent keep the name and the icons of a user in
+	mainProfileSemanticID = "world.concrnt.p"
+)
+
+// Config configures the facade
+type Config struct {
+	FQDN         string
+	Title        string
+	Description  string
+	Email        string
+	Version      string // version of concurrent, appended to compatibleVersion
+	Registration bool
+}
+
+// Service is the interface for mastodon service
+type Service interface {
+	Instance(ctx context.Context) (Instance, error)
+	PublicTimeline(ctx context.Context, maxID string, limit int) ([]Status, error)
+	Account(ctx context.Context, id string) (Account, error)
+	LookupAccount(ctx context.Context, acct string) (Account, error)
+	AccountStatuses(ctx context.Context, id, maxID string, limit int) ([]Status, error)
+	Status(ctx context.Context, id string) (Status, error)
+}
+
+type service struct {
+	entity   core.EntityService
+	message  core.MessageService
+	profile  core.ProfileService
+	timeline core.TimelineService
+	config   Config
+}
+
+// NewService creates a new mastodon service
+func NewService(entity core.EntityService, message core.MessageService, profile core.ProfileService, timeline core.TimelineService, config Config) Service {
+	return &service{
+		entity:   entity,
+		message:  message,
+		profile:  profile,
+		timeline: timeline,
+		config:   config,
+	}
+}
+
+func (s *service) base() string {
+	return "https://" + s.config.FQDN
+}
+
+// Instance describes the domain
+func (s *service) Instance(ctx context.Context) (Instance, error) {
+	ctx, span := tracer.Start(ctx, "Mastodon.Service.Instance")
+	defer span.End()
+
+	users, err := s.entity.Count(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return Instance{}, err
+	}
+	statuses, err := s.message.Count(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return Instance{}, err
+	}
+
+	version := compatibleVersion
+	if s.config.Version != "" {
+		version += " (compatible; concrnt " + s.config.Version + ")"
+	}
+
+	return Instance{
+		URI:              s.config.FQDN,
+		Title:            s.config.Title,
+		ShortDescription: s.config.Description,
+		Description:      s.config.Description,
+		Email:            s.config.Email,
+		Version:          version,
+		URLs:             map[string]string{},
+		Stats:            InstanceStats{UserCount: users, StatusCount: statuses},
+		Languages:        []string{},
+		Registrations:    s.config.Registration,
+	}, nil
+}
+
+// PublicTimeline returns the messages of the firehose of the domain
+func (s *service) PublicTimeline(ctx context.Context, maxID string, limit int) ([]Status, error) {
+	ctx, span := tracer.Start(ctx, "Mastodon.Service.PublicTimeline")
+	defer span.End()
+
+	return s.timelineStatuses(ctx, core.FirehoseTimelineSemanticID+"@"+s.config.FQDN, maxID, limit)
+}
+
+// Account returns the account of an entity
+func (s *service) Account(ctx context.Context, id string) (Account, error) {
+	ctx, span := tracer.Start(ctx, "Mastodon.Service.Account")
+	defer span.End()
+
+	entity, err := s.entity.Get(ctx, id)
+	if err != nil {
+		return Account{}, err
+	}
+	return s.account(ctx, entity), nil
+}
+
+// LookupAccount finds an account by its acct, which is the alias or the CCID of a local entity
+func (s *service) LookupAccount(ctx context.Context, acct string) (Account, error) {
+	ctx, span := tracer.Start(ctx, "Mastodon.Service.LookupAccount")
+	defer span.End()
+
+	acct = strings.TrimPrefix(acct, "@")
+	name, domain, found := strings.Cut(acct, "@")
+	if found && domain != s.config.FQDN {
+		return Account{}, core.NewErrorNotFound()
+	}
+
+	if core.IsCCID(name) {
+		return s.Account(ctx, name)
+	}
+
+	entity, err := s.entity.GetByAlias(ctx, name)
+	if err != nil {
+		return Account{}, err
+	}
+	return s.account(ctx, entity), nil
+}
+
+// AccountStatuses returns the messages of the home timeline of an entity
+func (s *service) AccountStatuses(ctx context.Context, id, maxID string, limit int) ([]Status, error) {
+	ctx, span := tracer.Start(ctx, "Mastodon.Service.AccountStatuses")
+	defer span.End()
+
+	if !core.IsCCID(id) {
+		return nil, core.NewErrorNotFound()
+	}
+
+	return s.timelineStatuses(ctx, core.RepostTimelineSemanticID+"@"+id, maxID, limit)
+}
+
+// Status returns a public message
+func (s *service) Status(ctx context.Context, id string) (Status, error) {
+	ctx, span := tracer.Start(ctx, "Mastodon.Service.Status")
+	defer span.End()
+
+	message, err := s.message.GetAsGuest(ctx, id)
+	if err != nil {
+		return Status{}, err
+	}
+
+	status, ok := s.status(ctx, message, map[string]Account{})
+	if !ok {
+		return Status{}, core.NewErrorNotFound()
+	}
+	return status, nil
+}
+
+// timelineStatuses returns the messages of the timeline older than maxID.
+// items which aren't public messages with a text body are skipped
+func (s *service) timelineStatuses(ctx context.Context, timeline, maxID string, limit int) ([]Status, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	until := time.Now()
+	if maxID != "" {
+		t, err := cdid.TimeOf(maxID)
+		if err != nil {
+			return nil, core.NewErrorInvalidArgument("invalid max_id")
+		}
+		until = t
+	}
+
+	items, err := s.timeline.GetRecentItems(ctx, []string{timeline}, until, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := map[string]Account{}
+	statuses := []Status{}
+	for _, item := range items {
+		if !strings.HasPrefix(item.ResourceID, "m") || item.ResourceID == maxID {
+			continue
+		}
+		message, err := s.message.GetAsGuest(ctx, item.ResourceID)
+		if err != nil {
+			continue
+		}
+		status, ok := s.status(ctx, message, accounts)
+		if !ok {
+			continue
+		}
+		statuses = append(statuses, status)
+		if len(statuses) >= limit {
+			break
+		}
+	}
+
+	return statuses, nil
+}
+
+// status maps the message. accounts caches the authors within a page
+func (s *service) status(ctx context.Context, message core.Message, accounts map[string]Account) (Status, bool) {
+	content, ok := statusContent(message.Document)
+	if !ok {
+		return Status{}, false
+	}
+
+	account, cached := accounts[message.Author]
+	if !cached {
+		entity, err := s.entity.Get(ctx, message.Author)
+		if err != nil {
+			return Status{}, false
+		}
+		account = s.account(ctx, entity)
+		accounts[message.Author] = account
+	}
+
+	url := s.base() + "/api/v1/message/" + message.ID
+	return Status{
+		ID:               message.ID,
+		URI:              url,
+		URL:              url,
+		CreatedAt:        message.CDate,
+		Account:          account,
+		Content:          content,
+		Visibility:       "public",
+		MediaAttachments: []any{},
+		Mentions:         []any{},
+		Tags:             []any{},
+		Emojis:           []any{},
+	}, true
+}
+
+// account maps the entity, with the name and the icons of its main profile when it has one
+func (s *service) account(ctx context.Context, entity core.Entity) Account {
+	username := entity.ID
+	if entity.Alias != nil && *entity.Alias != "" {
+		username = *entity.Alias
+	}
+	acct := username
+	if entity.Domain != s.config.FQDN {
+		acct = username + "@" + entity.Domain
+	}
+
+	account := Account{
+		ID:          entity.ID,
+		Username:    username,
+		Acct:        acct,
+		DisplayName: username,
+		CreatedAt:   entity.CDate,
+		URL:         "https://" + entity.Domain + "/api/v1/entity/" + entity.ID,
+		Emojis:      []any{},
+		Fields:      []any{},
+	}
+
+	profile, err := s.profile.GetBySemanticID(ctx, mainProfileSemanticID, entity.ID)
+	if err != nil {
+		return account
+	}
+	var doc core.DocumentBase[struct {
+		Username    string `json:"username"`
+		Description string `json:"description"`
+		Avatar      string `json:"avatar"`
+		Banner      string `json:"banner"`
+	}]
+	if json.Unmarshal([]byte(profile.Document), &doc) != nil {
+		return account
+	}
+	if doc.Body.Username != "" {
+		account.DisplayName = doc.Body.Username
+	}
+	if doc.Body.Description != "" {
+		account.Note = renderText(doc.Body.Description)
+	}
+	account.Avatar = doc.Body.Avatar
+	account.AvatarStatic = doc.Body.Avatar
+	account.Header = doc.Body.Banner
+	account.HeaderStatic = doc.Body.Banner
+	return account
+}
+
+// statusContent renders the text body of a message as html. false is returned for bodies without text.
+func statusContent(document string) (string, bool) {
+	var doc core.DocumentBase[map[string]any]
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return "", false
+	}
+	text, ok := doc.Body["body"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return "", false
+	}
+	return renderText(text), true
+}
+
+func renderText(text string) string {
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + "</p>"
+}
@@ -0,0 +1,70 @@
+package mastodon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+const (
+	user1ID    = "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+	message1ID = "mx0a1ezpvs1rhdxr5069y8ejm9r"
+	message2ID = "mx0a1ezpvs1rhdxr5069y8ejm9g"
+)
+
+func TestPublicTimeline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alias := "alice"
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), user1ID).Return(core.Entity{ID: user1ID, Domain: "local.example.com", Alias: &alias}, nil).Times(1)
+
+	mockProfile := mock_core.NewMockProfileService(ctrl)
+	mockProfile.EXPECT().GetBySemanticID(gomock.Any(), mainProfileSemanticID, user1ID).Return(core.Profile{
+		Document: `{"body":{"username":"Alice","description":"<hi>","avatar":"https://example.com/a.png"}}`,
+	}, nil).Times(1)
+
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().GetRecentItems(gomock.Any(), []string{"world.concrnt.t-local@local.example.com"}, gomock.Any(), 3).Return([]core.TimelineItem{
+		{ResourceID: message1ID},
+		{ResourceID: "a0a1ezpvs1rhdxr5069y8ejm9r"},
+		{ResourceID: message2ID},
+	}, nil)
+
+	mockMessage := mock_core.NewMockMessageService(ctrl)
+	mockMessage.EXPECT().GetAsGuest(gomock.Any(), message1ID).Return(core.Message{
+		ID:       message1ID,
+		Author:   user1ID,
+		Document: `{"body":{"body":"hello\nworld"}}`,
+		CDate:    time.Now(),
+	}, nil)
+	mockMessage.EXPECT().GetAsGuest(gomock.Any(), message2ID).Return(core.Message{
+		ID:       message2ID,
+		Author:   user1ID,
+		Document: `{"body":{"body":"bye"}}`,
+	}, nil)
+
+	service := NewService(mockEntity, mockMessage, mockProfile, mockTimeline, Config{FQDN: "local.example.com"})
+
+	statuses, err := service.PublicTimeline(context.Background(), "", 2)
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, message1ID, statuses[0].ID)
+		assert.Equal(t, "<p>hello<br>world</p>", statuses[0].Content)
+		assert.Equal(t, "alice", statuses[0].Account.Acct)
+		assert.Equal(t, "Alice", statuses[0].Account.DisplayName)
+		assert.Equal(t, "<p>&lt;hi&gt;</p>", statuses[0].Account.Note)
+		assert.Equal(t, "https://example.com/a.png", statuses[1].Account.Avatar)
+		assert.NotNil(t, statuses[1].MediaAttachments)
+	}
+
+	_, err = service.PublicTimeline(context.Background(), "invalid", 2)
+	assert.ErrorIs(t, err, core.ErrorInvalidArgument{})
+}
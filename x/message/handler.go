@@ -4,9 +4,11 @@ package message
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/etag"
 	"go.opentelemetry.io/otel"
 )
 
@@ -15,6 +17,7 @@ var tracer = otel.Tracer("message")
 // Handler is the interface for handling HTTP requests
 type Handler interface {
 	Get(c echo.Context) error
+	GetReceipts(c echo.Context) error
 }
 
 type handler struct {
@@ -53,8 +56,44 @@ func (h handler) Get(c echo.Context) error {
 			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 		}
 	}
-	return c.JSON(http.StatusOK, echo.Map{
-		"status":  "ok",
-		"content": message,
-	})
+
+	// the response varies with the requester because of ownAssociations and the policies
+	etag.VaryByRequester(c, ok)
+	tag, modified := messageVersion(message)
+	return etag.Respond(c, tag, modified, message)
+}
+
+// GetReceipts returns whether the remote domains the message was relayed to accepted it
+func (h handler) GetReceipts(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Message.Handler.GetReceipts")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	receipts, err := h.service.GetReceipts(ctx, c.Param("id"), requester)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": receipts})
+}
+
+// messageVersion returns the etag and last modified time of the message including its associations
+func messageVersion(message core.Message) (string, time.Time) {
+	signatures := []string{message.Signature}
+	modified := message.CDate
+	for _, associations := range [][]core.Association{message.Associations, message.OwnAssociations} {
+		signatures = append(signatures, "")
+		for _, association := range associations {
+			signatures = append(signatures, association.Signature)
+			if association.CDate.After(modified) {
+				modified = association.CDate
+			}
+		}
+	}
+	return etag.FromSignatures(signatures...), modified
 }
@@ -0,0 +1,85 @@
+package message
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const maxMentions = 20
+
+// mentionFields lists the body fields scanned for mentions for each message schema.
+// messages of other schemas are not scanned.
+var mentionFields = map[string][]string{
+	"https://schema.concrnt.world/m/markdown.json":  {"body"},
+	"https://schema.concrnt.world/m/plaintext.json": {"body"},
+	"https://schema.concrnt.world/m/reply.json":     {"body"},
+	"https://schema.concrnt.world/m/reroute.json":   {"body"},
+}
+
+// @ must not follow a word character so that mail addresses are not treated as mentions
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@.])@(con1[qpzry9x8gf2tvdw0s3jn54khce6mua7l]{38}|[a-zA-Z0-9][a-zA-Z0-9-]*(?:\.[a-zA-Z0-9-]+)+)`)
+
+// extractMentions returns ccids and aliases mentioned in the message body in order of appearance
+func extractMentions(schema string, body any) []string {
+	fields, ok := mentionFields[schema]
+	if !ok {
+		return nil
+	}
+
+	obj, ok := body.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var mentions []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		text, ok := obj[field].(string)
+		if !ok {
+			continue
+		}
+		for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+			mention := strings.TrimSuffix(match[1], ".")
+			if seen[mention] {
+				continue
+			}
+			seen[mention] = true
+			mentions = append(mentions, mention)
+			if len(mentions) >= maxMentions {
+				return mentions
+			}
+		}
+	}
+
+	return mentions
+}
+
+// resolveMentions resolves mentions in the message to entities. the signer and unknown entities are skipped.
+func (s *service) resolveMentions(ctx context.Context, doc core.MessageDocument[any]) []core.Entity {
+	ctx, span := tracer.Start(ctx, "Message.Service.resolveMentions")
+	defer span.End()
+
+	var entities []core.Entity
+	for _, mention := range extractMentions(doc.Schema, doc.Body) {
+		var entity core.Entity
+		var err error
+		if core.IsCCID(mention) {
+			entity, err = s.entity.Get(ctx, mention)
+		} else {
+			entity, err = s.entity.GetByAlias(ctx, mention)
+		}
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		if entity.ID == doc.Signer {
+			continue
+		}
+		entities = append(entities, entity)
+	}
+
+	return entities
+}
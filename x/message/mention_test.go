@@ -0,0 +1,21 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractMentions(t *testing.T) {
+	schema := "https://schema.concrnt.world/m/markdown.json"
+
+	body := map[string]any{
+		"body": "hi @con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2 and @alice.example.com. mail me at bob@example.com @con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2",
+	}
+	mentions := extractMentions(schema, body)
+	assert.Equal(t, []string{"con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2", "alice.example.com"}, mentions)
+
+	assert.Empty(t, extractMentions("https://example.com/unknown.json", body))
+	assert.Empty(t, extractMentions(schema, "not an object"))
+	assert.Empty(t, extractMentions(schema, map[string]any{"body": "@notanalias"}))
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"log/slog"
 	"strconv"
+	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
 )
 
 // Repository is the interface for message repository
@@ -20,16 +23,19 @@ type Repository interface {
 	Delete(ctx context.Context, key string) error
 	Clean(ctx context.Context, ccid string) error
 	Count(ctx context.Context) (int64, error)
+	Expire(ctx context.Context, now time.Time, limit int) ([]core.Message, error)
+	SaveReceipt(ctx context.Context, receipt core.DeliveryReceipt) error
+	GetReceipts(ctx context.Context, id string) ([]core.DeliveryReceipt, error)
 }
 
 type repository struct {
 	db     *gorm.DB
-	mc     *memcache.Client
+	mc     cache.Cache
 	schema core.SchemaService
 }
 
 // NewRepository creates a new message repository
-func NewRepository(db *gorm.DB, mc *memcache.Client, schema core.SchemaService) Repository {
+func NewRepository(db *gorm.DB, mc cache.Cache, schema core.SchemaService) Repository {
 	return &repository{db, mc, schema}
 }
 
@@ -177,7 +183,7 @@ func (r *repository) Get(ctx context.Context, id string) (core.Message, error) {
 	}
 
 	var message core.Message
-	err = r.db.WithContext(ctx).First(&message, "id = ?", id).Error
+	err = r.db.WithContext(ctx).First(&message, "id = ? AND (expires_at IS NULL OR expires_at > ?)", id, time.Now()).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return core.Message{}, core.NewErrorNotFound()
@@ -201,7 +207,7 @@ func (r *repository) GetWithOwnAssociations(ctx context.Context, id string, ccid
 	id, err := r.normalizeDBID(id)
 
 	var message core.Message
-	err = r.db.WithContext(ctx).First(&message, "id = ?", id).Error
+	err = r.db.WithContext(ctx).First(&message, "id = ? AND (expires_at IS NULL OR expires_at > ?)", id, time.Now()).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return core.Message{}, core.NewErrorNotFound()
@@ -241,19 +247,90 @@ func (r *repository) Delete(ctx context.Context, id string) error {
 		return err
 	}
 
+	err = r.db.WithContext(ctx).Where("message = ?", "m"+id).Delete(&core.DeliveryReceipt{}).Error
+	if err != nil {
+		return err
+	}
+
 	r.mc.Decrement("message_count", 1)
 
 	return nil
 }
 
+// Expire deletes up to limit messages which expired by now, together with their delivery receipts, and returns them
+func (r *repository) Expire(ctx context.Context, now time.Time, limit int) ([]core.Message, error) {
+	ctx, span := tracer.Start(ctx, "Message.Repository.Expire")
+	defer span.End()
+
+	var expired []core.Message
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := tx.Model(&core.Message{}).Select("id").Where("expires_at <= ?", now).Order("expires_at").Limit(limit)
+		err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "author"}, {Name: "timelines"}}}).
+			Where("id IN (?)", ids).
+			Delete(&expired).Error
+		if err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		receipts := make([]string, len(expired))
+		for i, message := range expired {
+			receipts[i] = "m" + message.ID
+		}
+		return tx.Where("message IN ?", receipts).Delete(&core.DeliveryReceipt{}).Error
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	for i := range expired {
+		expired[i].ID = "m" + expired[i].ID
+	}
+	if len(expired) > 0 {
+		r.mc.Decrement("message_count", uint64(len(expired)))
+	}
+
+	return expired, nil
+}
+
 func (r *repository) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "Message.Repository.Clean")
 	defer span.End()
 
-	err := r.db.WithContext(ctx).Where("author = ?", ccid).Delete(&core.Message{}).Error
+	messages := r.db.Model(&core.Message{}).Select("'m' || id").Where("author = ?", ccid)
+	err := r.db.WithContext(ctx).Where("message IN (?)", messages).Delete(&core.DeliveryReceipt{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = r.db.WithContext(ctx).Where("author = ?", ccid).Delete(&core.Message{}).Error
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// SaveReceipt records the delivery of a message to a domain, replacing the previous status
+func (r *repository) SaveReceipt(ctx context.Context, receipt core.DeliveryReceipt) error {
+	ctx, span := tracer.Start(ctx, "Message.Repository.SaveReceipt")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message"}, {Name: "domain"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timelines", "status", "error", "m_date"}),
+	}).Create(&receipt).Error
+}
+
+// GetReceipts returns the deliveries of the message to remote domains
+func (r *repository) GetReceipts(ctx context.Context, id string) ([]core.DeliveryReceipt, error) {
+	ctx, span := tracer.Start(ctx, "Message.Repository.GetReceipts")
+	defer span.End()
+
+	var receipts []core.DeliveryReceipt
+	err := r.db.WithContext(ctx).Where("message = ?", id).Order("domain").Find(&receipts).Error
+	return receipts, err
+}
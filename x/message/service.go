@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/codes"
@@ -14,6 +16,7 @@ import (
 	"github.com/totegamma/concurrent/cdid"
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 	"github.com/totegamma/concurrent/x/policy"
 )
 
@@ -154,6 +157,11 @@ func (s *service) GetAsGuest(ctx context.Context, id string) (core.Message, erro
 		return core.Message{}, err
 	}
 
+	// a message in private timelines only is read by their readers, whichever endpoint serves it
+	if !s.timeline.AllowsReader(ctx, message.Timelines, "") {
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
+	}
+
 	isPublic, err := s.isMessagePublic(ctx, message)
 	if err != nil {
 		span.RecordError(err)
@@ -161,7 +169,7 @@ func (s *service) GetAsGuest(ctx context.Context, id string) (core.Message, erro
 	}
 
 	if !isPublic {
-		return core.Message{}, fmt.Errorf("no read access")
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
 	}
 
 	return message, nil
@@ -177,6 +185,10 @@ func (s *service) GetAsUser(ctx context.Context, id string, requester core.Entit
 		return core.Message{}, err
 	}
 
+	if !s.timeline.AllowsReader(ctx, message.Timelines, requester.ID) {
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
+	}
+
 	var defaults map[string]bool
 	if message.PolicyDefaults != nil {
 		json.Unmarshal([]byte(*message.PolicyDefaults), &defaults)
@@ -222,7 +234,7 @@ func (s *service) GetAsUser(ctx context.Context, id string, requester core.Entit
 	timelinePolicyResult := s.policy.AccumulateOr(timelinePolicyResults, "timeline.message.read", &defaults)
 	timelinePolicyIsDominant, timelinePolicyAllowed := policy.IsDominant(timelinePolicyResult)
 	if timelinePolicyIsDominant && !timelinePolicyAllowed {
-		return core.Message{}, fmt.Errorf("no read access")
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
 	}
 
 	messagePolicyResult := core.PolicyEvalResultDefault
@@ -245,7 +257,7 @@ func (s *service) GetAsUser(ctx context.Context, id string, requester core.Entit
 
 	result := s.policy.Summerize([]core.PolicyEvalResult{timelinePolicyResult, messagePolicyResult}, "message.read", &defaults)
 	if !result {
-		return core.Message{}, fmt.Errorf("no read access")
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
 	}
 
 	return message, nil
@@ -262,6 +274,10 @@ func (s *service) GetWithOwnAssociations(ctx context.Context, id string, request
 		return core.Message{}, err
 	}
 
+	if !s.timeline.AllowsReader(ctx, message.Timelines, requester) {
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
+	}
+
 	var defaults map[string]bool
 	if message.PolicyDefaults != nil {
 		json.Unmarshal([]byte(*message.PolicyDefaults), &defaults)
@@ -313,7 +329,7 @@ func (s *service) GetWithOwnAssociations(ctx context.Context, id string, request
 	timelinePolicyResult := s.policy.AccumulateOr(timelinePolicyResults, "timeline.message.read", &defaults)
 	timelinePolicyIsDominant, timelinePolicyAllowed := policy.IsDominant(timelinePolicyResult)
 	if timelinePolicyIsDominant && !timelinePolicyAllowed {
-		return core.Message{}, fmt.Errorf("no read access")
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
 	}
 
 	messagePolicyResult := core.PolicyEvalResultDefault
@@ -336,7 +352,7 @@ func (s *service) GetWithOwnAssociations(ctx context.Context, id string, request
 
 	result := s.policy.Summerize([]core.PolicyEvalResult{timelinePolicyResult, messagePolicyResult}, "message.read", &defaults)
 	if !result {
-		return core.Message{}, fmt.Errorf("no read access")
+		return core.Message{}, core.NewErrorPolicyDenied("message.read")
 	}
 
 	return message, nil
@@ -357,6 +373,11 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 		return created, []string{}, err
 	}
 
+	// a message which expired on the way is not delivered at all
+	if doc.ExpiresAt != nil && !doc.ExpiresAt.After(time.Now()) {
+		return created, []string{}, core.NewErrorInvalidArgument("message already expired")
+	}
+
 	hash := core.GetHash([]byte(document))
 	hash10 := [10]byte{}
 	copy(hash10[:], hash[:10])
@@ -379,6 +400,27 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 		policydefaults = &doc.PolicyDefaults
 	}
 
+	// writes to a merged timeline are rejected with the timeline it was merged into, before anything is stored
+	if signer.Domain == s.config.FQDN && mode != core.CommitModeLocalOnlyExec {
+		merged, err := s.timeline.ResolveAliases(ctx, doc.Timelines)
+		if err != nil {
+			span.RecordError(err)
+			return core.Message{}, []string{}, err
+		}
+		for _, timeline := range doc.Timelines {
+			if into, ok := merged[timeline]; ok {
+				return core.Message{}, []string{}, core.NewErrorTimelineMerged(timeline, into)
+			}
+		}
+
+		// likewise a post over the writeRateLimit of a timeline is refused before the message is stored
+		err = s.timeline.CheckThrottle(ctx, doc.Timelines, doc.Signer, document)
+		if err != nil {
+			span.RecordError(err)
+			return core.Message{}, []string{}, err
+		}
+	}
+
 	if signer.Domain == s.config.FQDN { // signerが自ドメイン管轄の場合、リソースを作成
 
 		message := core.Message{
@@ -391,12 +433,22 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 			Document:       document,
 			Signature:      signature,
 			Timelines:      doc.Timelines,
+			ExpiresAt:      doc.ExpiresAt,
 		}
 
 		created, err = s.repo.Create(ctx, message)
 		if err != nil {
-			span.RecordError(err)
-			return message, []string{}, err
+			// the same document submitted again continues with the stored message,
+			// so that a retry completes the delivery of an interrupted commit
+			if !errors.Is(err, core.ErrorAlreadyExists{}) {
+				span.RecordError(err)
+				return message, []string{}, err
+			}
+			existing, gerr := s.repo.Get(ctx, id)
+			if gerr != nil || existing.Signature != signature {
+				return message, []string{}, err
+			}
+			created = existing
 		}
 
 	}
@@ -421,12 +473,32 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 		destinations[domain] = append(destinations[domain], timelineID)
 	}
 
+	// mentioned local users get the message in their notification timeline.
+	// domains of remote users receive the commit and do the same for their users.
+	for _, mentioned := range s.resolveMentions(ctx, doc) {
+		if mentioned.Domain == s.config.FQDN {
+			notification := core.NotificationTimelineSemanticID + "@" + mentioned.ID
+			if !slices.Contains(destinations[mentioned.Domain], notification) {
+				destinations[mentioned.Domain] = append(destinations[mentioned.Domain], notification)
+			}
+		} else if _, ok := destinations[mentioned.Domain]; !ok {
+			destinations[mentioned.Domain] = []string{}
+		}
+	}
+
 	ispublic, err := s.isMessagePublic(ctx, created)
 	if err != nil {
 		span.RecordError(err)
 		return core.Message{}, []string{}, err
 	}
 
+	// public messages of local users posted to an indexable timeline of the domain also go to its firehose
+	if ispublic && signer.Domain == s.config.FQDN {
+		if firehose, ok := s.firehoseFor(ctx, destinations[s.config.FQDN]); ok {
+			destinations[s.config.FQDN] = append(destinations[s.config.FQDN], firehose)
+		}
+	}
+
 	sendDocument := ""
 	sendSignature := ""
 	var sendResource *core.Message
@@ -446,6 +518,7 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 					Owner:      doc.Signer,
 					TimelineID: timeline,
 					Schema:     doc.Schema,
+					ExpiresAt:  doc.ExpiresAt,
 				}
 
 				if !doc.SignedAt.IsZero() {
@@ -470,7 +543,7 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 
 					err = s.timeline.PublishEvent(ctx, event)
 					if err != nil {
-						slog.ErrorContext(ctx, "failed to publish event", slog.String("error", err.Error()), slog.String("module", "timeline"))
+						logging.Error(ctx, "failed to publish event", slog.String("error", err.Error()), slog.String("module", "timeline"))
 						span.RecordError(errors.Wrap(err, "failed to publish event"))
 						continue
 					}
@@ -495,12 +568,10 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 				continue
 			}
 
-			s.client.Commit(ctx, domain, string(packetStr), nil, nil)
+			s.deliver(ctx, id, domain, timelines, string(packetStr))
 		}
 	}
 
-	// TODO: 実際に送信できたstreamの一覧を返すべき
-
 	affected, err := s.timeline.GetOwners(ctx, doc.Timelines)
 	if err != nil {
 		span.RecordError(err)
@@ -513,6 +584,40 @@ func (s *service) Create(ctx context.Context, mode core.CommitMode, document str
 	return created, affected, nil
 }
 
+// firehoseFor returns the firehose of the domain when a message posted to the local timelines goes to it:
+// any of them is indexable and has not opted out, and the firehose is not one of them already
+func (s *service) firehoseFor(ctx context.Context, timelines []string) (string, bool) {
+	ctx, span := tracer.Start(ctx, "Message.Service.firehoseFor")
+	defer span.End()
+
+	firehose, err := s.timeline.NormalizeTimelineID(ctx, core.FirehoseTimelineSemanticID+"@"+s.config.FQDN)
+	if err != nil {
+		span.RecordError(errors.Wrap(err, "failed to resolve firehose"))
+		return "", false
+	}
+
+	eligible := false
+	for _, timelineID := range timelines {
+		normalized, err := s.timeline.NormalizeTimelineID(ctx, timelineID)
+		if err != nil {
+			continue
+		}
+		if normalized == firehose {
+			return "", false
+		}
+		timeline, err := s.timeline.GetTimelineAutoDomain(ctx, normalized)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		// the firehose is public, so the messages of private timelines never reach it
+		if timeline.Indexable && !timeline.FirehoseOptOut && len(timeline.Readers) == 0 {
+			eligible = true
+		}
+	}
+	return firehose, eligible
+}
+
 // Delete deletes a message by ID
 // It also emits a delete event to the sockets
 func (s *service) Delete(ctx context.Context, mode core.CommitMode, document, signature string) (core.Message, []string, error) {
@@ -617,3 +722,89 @@ func (s *service) Clean(ctx context.Context, ccid string) error {
 
 	return s.repo.Clean(ctx, ccid)
 }
+
+// expireBatchSize bounds the messages deleted by one statement when pruning
+const expireBatchSize = 1000
+
+// Expire deletes the messages which expired and returns them, so that their commits can be dropped from the logs.
+// their timeline items carry the same expiry and are pruned by the timeline service
+func (s *service) Expire(ctx context.Context) ([]core.Message, error) {
+	ctx, span := tracer.Start(ctx, "Message.Service.Expire")
+	defer span.End()
+
+	var expired []core.Message
+	now := time.Now()
+	for {
+		batch, err := s.repo.Expire(ctx, now, expireBatchSize)
+		if err != nil {
+			span.RecordError(err)
+			return expired, err
+		}
+		expired = append(expired, batch...)
+		if len(batch) < expireBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// deliver relays the commit of the message to the domain, recording a receipt of whether it was accepted.
+// the receipt stays pending when the domain doesn't answer
+func (s *service) deliver(ctx context.Context, id, domain string, timelines []string, packet string) {
+	ctx, span := tracer.Start(ctx, "Message.Service.deliver")
+	defer span.End()
+
+	receipt := core.DeliveryReceipt{
+		Message:   id,
+		Domain:    domain,
+		Timelines: timelines,
+		Status:    core.DeliveryPending,
+	}
+	err := s.repo.SaveReceipt(ctx, receipt)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	resp, err := s.client.Commit(ctx, domain, packet, nil, nil)
+	switch {
+	case err != nil:
+		receipt.Error = err.Error()
+	case resp.StatusCode/100 == 2:
+		receipt.Status = core.DeliveryAccepted
+	default:
+		receipt.Status = core.DeliveryRejected
+		receipt.Error = resp.Status
+		if resp.Body != nil {
+			var body struct {
+				Error string `json:"error"`
+			}
+			if json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body) == nil && body.Error != "" {
+				receipt.Error += ": " + body.Error
+			}
+		}
+	}
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+
+	err = s.repo.SaveReceipt(ctx, receipt)
+	if err != nil {
+		span.RecordError(err)
+	}
+}
+
+// GetReceipts returns the deliveries of the message to remote domains. only its author can see them
+func (s *service) GetReceipts(ctx context.Context, id string, requester string) ([]core.DeliveryReceipt, error) {
+	ctx, span := tracer.Start(ctx, "Message.Service.GetReceipts")
+	defer span.End()
+
+	message, err := s.repo.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if message.Author != requester {
+		return nil, core.NewErrorPermissionDenied()
+	}
+
+	return s.repo.GetReceipts(ctx, message.ID)
+}
@@ -0,0 +1,196 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/client/mock"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+func TestFirehoseFor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	firehose := "t00000000000000000000000ff@example.com"
+	timelines := map[string]core.Timeline{
+		"t000000000000000000000000a@example.com": {Indexable: true},
+		"t000000000000000000000000b@example.com": {Indexable: true, FirehoseOptOut: true},
+		"t000000000000000000000000c@example.com": {Indexable: false},
+		firehose:                                 {Indexable: true},
+	}
+
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().NormalizeTimelineID(gomock.Any(), core.FirehoseTimelineSemanticID+"@example.com").Return(firehose, nil).AnyTimes()
+	mockTimeline.EXPECT().NormalizeTimelineID(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (string, error) {
+		return id, nil
+	}).AnyTimes()
+	mockTimeline.EXPECT().GetTimelineAutoDomain(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (core.Timeline, error) {
+		return timelines[id], nil
+	}).AnyTimes()
+
+	s := &service{timeline: mockTimeline, config: core.Config{FQDN: "example.com"}}
+	ctx := context.Background()
+
+	got, ok := s.firehoseFor(ctx, []string{"t000000000000000000000000c@example.com", "t000000000000000000000000a@example.com"})
+	assert.True(t, ok)
+	assert.Equal(t, firehose, got)
+
+	// opted out or not indexable
+	_, ok = s.firehoseFor(ctx, []string{"t000000000000000000000000b@example.com", "t000000000000000000000000c@example.com"})
+	assert.False(t, ok)
+
+	// posted to the firehose itself
+	_, ok = s.firehoseFor(ctx, []string{"t000000000000000000000000a@example.com", firehose})
+	assert.False(t, ok)
+}
+
+// receiptRepository keeps the receipts in memory
+type receiptRepository struct {
+	Repository
+	receipts map[string]core.DeliveryReceipt
+}
+
+func (r *receiptRepository) SaveReceipt(ctx context.Context, receipt core.DeliveryReceipt) error {
+	r.receipts[receipt.Domain] = receipt
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockClient(ctrl)
+	mockClient.EXPECT().Commit(gomock.Any(), "a.example.com", gomock.Any(), nil, nil).Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"status":"ok"}`))}, nil)
+	mockClient.EXPECT().Commit(gomock.Any(), "b.example.com", gomock.Any(), nil, nil).Return(&http.Response{
+		StatusCode: http.StatusForbidden,
+		Status:     "403 Forbidden",
+		Body:       io.NopCloser(strings.NewReader(`{"status":"error","error":"Policy Denied: timeline.distribute"}`)),
+	}, nil)
+	mockClient.EXPECT().Commit(gomock.Any(), "c.example.com", gomock.Any(), nil, nil).Return(&http.Response{}, core.NewErrorRemoteUnavailable("c.example.com", nil))
+
+	repo := &receiptRepository{receipts: map[string]core.DeliveryReceipt{}}
+	s := &service{repo: repo, client: mockClient, config: core.Config{FQDN: "example.com"}}
+
+	for _, domain := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		s.deliver(context.Background(), "m00000000000000000000000001", domain, []string{"t0@" + domain}, "{}")
+	}
+
+	assert.Equal(t, core.DeliveryAccepted, repo.receipts["a.example.com"].Status)
+	assert.Equal(t, []string{"t0@a.example.com"}, repo.receipts["a.example.com"].Timelines)
+	assert.Equal(t, core.DeliveryRejected, repo.receipts["b.example.com"].Status)
+	assert.Equal(t, "403 Forbidden: Policy Denied: timeline.distribute", repo.receipts["b.example.com"].Error)
+	assert.Equal(t, core.DeliveryPending, repo.receipts["c.example.com"].Status)
+	assert.Equal(t, "Remote Unavailable: c.example.com", repo.receipts["c.example.com"].Error)
+}
+
+// expiringRepository hands out the expired messages in batches
+type expiringRepository struct {
+	Repository
+	expired []core.Message
+}
+
+func (r *expiringRepository) Expire(ctx context.Context, now time.Time, limit int) ([]core.Message, error) {
+	n := min(limit, len(r.expired))
+	batch := r.expired[:n]
+	r.expired = r.expired[n:]
+	return batch, nil
+}
+
+func TestExpire(t *testing.T) {
+	expired := make([]core.Message, expireBatchSize+1)
+	s := &service{repo: &expiringRepository{expired: expired}}
+
+	got, err := s.Expire(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, got, expireBatchSize+1)
+}
+
+// messageRepository serves fixed messages
+type messageRepository struct {
+	Repository
+	messages map[string]core.Message
+}
+
+func (r *messageRepository) Get(ctx context.Context, id string) (core.Message, error) {
+	return r.messages[id], nil
+}
+
+func TestGetPrivateMessage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := "m00000000000000000000000001"
+	private := "t00000000000000000000000001@example.com"
+	stranger := core.Entity{ID: "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"}
+
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().AllowsReader(gomock.Any(), []string{private}, "").Return(false)
+	mockTimeline.EXPECT().AllowsReader(gomock.Any(), []string{private}, stranger.ID).Return(false)
+
+	repo := &messageRepository{messages: map[string]core.Message{
+		id: {ID: id, Timelines: []string{private}},
+	}}
+	s := &service{repo: repo, timeline: mockTimeline, config: core.Config{FQDN: "example.com"}}
+
+	_, err := s.GetAsGuest(context.Background(), id)
+	assert.ErrorIs(t, err, core.NewErrorPolicyDenied("message.read"))
+
+	_, err = s.GetAsUser(context.Background(), id, stranger)
+	assert.ErrorIs(t, err, core.NewErrorPolicyDenied("message.read"))
+}
+
+// storingRepository records the messages it stores
+type storingRepository struct {
+	Repository
+	created []core.Message
+}
+
+func (r *storingRepository) Create(ctx context.Context, message core.Message) (core.Message, error) {
+	r.created = append(r.created, message)
+	return message, nil
+}
+
+func TestCreateThrottled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	author := "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+	timeline := "t00000000000000000000000001@example.com"
+	limited := core.NewErrorRateLimited("timeline.distribute", "t00000000000000000000000001", 2, time.Minute, 20*time.Second)
+
+	document, err := json.Marshal(core.MessageDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   author,
+			Type:     "message",
+			Body:     map[string]any{"body": "hello"},
+			SignedAt: time.Now(),
+		},
+		Timelines: []string{timeline},
+	})
+	assert.NoError(t, err)
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), author).Return(core.Entity{ID: author, Domain: "example.com"}, nil)
+
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().ResolveAliases(gomock.Any(), []string{timeline}).Return(map[string]string{}, nil)
+	mockTimeline.EXPECT().CheckThrottle(gomock.Any(), []string{timeline}, author, string(document)).Return(limited)
+
+	repo := &storingRepository{}
+	s := &service{repo: repo, entity: mockEntity, timeline: mockTimeline, config: core.Config{FQDN: "example.com"}}
+
+	// the post is refused with the limit before the message is stored
+	_, _, err = s.Create(context.Background(), core.CommitModeExecute, string(document), "")
+	assert.Equal(t, limited, err)
+	assert.Empty(t, repo.created)
+}
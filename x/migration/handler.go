@@ -0,0 +1,35 @@
+package migration
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Status(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// Status returns the migrations and whether they are applied to the database
+func (h handler) Status(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Migration.Handler.Status")
+	defer span.End()
+
+	status, err := h.service.Status(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": status})
+}
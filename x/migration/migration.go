@@ -0,0 +1,242 @@
+// Package migration applies versioned changes to the database schema.
+// changes are SQL files in sql/ named "<version>_<name>.up.sql" with an optional ".down.sql",
+// or Go migrations registered in goMigrations for changes AutoMigrate derives from the models.
+package migration
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// Models are the tables AutoMigrate keeps in sync with the structs when the implicit migration is enabled
+var Models = []any{
+	&core.Schema{},
+	&core.Message{},
+	&core.Profile{},
+	&core.Association{},
+	&core.Timeline{},
+	&core.TimelineItem{},
+	&core.Domain{},
+	&core.Entity{},
+	&core.EntityMeta{},
+	&core.Ack{},
+	&core.Key{},
+	&core.UserKV{},
+	&core.Subscription{},
+	&core.SubscriptionItem{},
+	&core.SemanticID{},
+	&core.Job{},
+	&core.CommitLog{},
+	&core.CommitOwner{},
+	&core.NotificationSubscription{},
+	&core.NotificationPreference{},
+	&core.AuthToken{},
+	&core.ApActor{},
+	&core.ApTimeline{},
+	&core.ApFollower{},
+	&core.ReadState{},
+	&core.EntityScoreHistory{},
+	&core.MuteRule{},
+	&core.InvalidDocument{},
+	&core.SpamFeature{},
+	&core.QuarantinedDocument{},
+	&core.AccountDeletion{},
+	&core.RelayPeer{},
+	&core.KeyUsage{},
+	&core.Recommendation{},
+	&core.RecommendationOptOut{},
+	&core.DeliveryReceipt{},
+	&core.TimelineAlias{},
+	&core.AssociationCount{},
+	&core.EntityTag{},
+	&core.DomainSettings{},
+	&core.Badge{},
+}
+
+// Migration is a versioned change of the database schema
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // nil if the change can't be reverted
+}
+
+// goMigrations are the migrations written in Go. new tables are added with autoMigrate of their models
+var goMigrations = []Migration{
+	{Version: 1, Name: "baseline", Up: autoMigrate(Models...)},
+	{Version: 4, Name: "spam", Up: autoMigrate(&core.SpamFeature{}, &core.QuarantinedDocument{}), Down: dropTables(&core.SpamFeature{}, &core.QuarantinedDocument{})},
+	{Version: 6, Name: "account_deletion", Up: autoMigrate(&core.AccountDeletion{}), Down: dropTables(&core.AccountDeletion{})},
+	{Version: 7, Name: "relay_peer", Up: autoMigrate(&core.RelayPeer{}), Down: dropTables(&core.RelayPeer{})},
+	{Version: 8, Name: "key_usage", Up: autoMigrate(&core.KeyUsage{}), Down: dropTables(&core.KeyUsage{})},
+	{Version: 9, Name: "document_version", Up: autoMigrate(&core.Profile{}, &core.Timeline{}), Down: dropColumns("version", &core.Profile{}, &core.Timeline{})},
+	{Version: 10, Name: "recommendation", Up: autoMigrate(&core.Recommendation{}, &core.RecommendationOptOut{}), Down: dropTables(&core.Recommendation{}, &core.RecommendationOptOut{})},
+	{Version: 11, Name: "delivery_receipt", Up: autoMigrate(&core.DeliveryReceipt{}), Down: dropTables(&core.DeliveryReceipt{})},
+	{Version: 12, Name: "schema_deprecation", Up: autoMigrate(&core.Schema{}), Down: steps(dropColumns("replaced_by", &core.Schema{}), dropColumns("deprecated_at", &core.Schema{}))},
+	{Version: 13, Name: "timeline_alias", Up: autoMigrate(&core.TimelineAlias{}), Down: dropTables(&core.TimelineAlias{})},
+	{Version: 14, Name: "timeline_readers", Up: autoMigrate(&core.Timeline{}), Down: dropColumns("readers", &core.Timeline{})},
+	{Version: 15, Name: "domain_previous_csid", Up: autoMigrate(&core.Domain{}), Down: steps(dropColumns("previous_cs_id_until", &core.Domain{}), dropColumns("previous_cs_id", &core.Domain{}))},
+	{Version: 16, Name: "association_counts", Up: steps(autoMigrate(&core.AssociationCount{}), backfillAssociationCounts), Down: dropTables(&core.AssociationCount{})},
+	{Version: 17, Name: "entity_tags", Up: steps(autoMigrate(&core.EntityTag{}), backfillEntityTags), Down: dropTables(&core.EntityTag{})},
+	{Version: 18, Name: "domain_settings", Up: autoMigrate(&core.DomainSettings{}), Down: dropTables(&core.DomainSettings{})},
+	{Version: 19, Name: "badges", Up: autoMigrate(&core.Badge{}), Down: dropTables(&core.Badge{})},
+	{Version: 20, Name: "ephemeral_messages", Up: autoMigrate(&core.Message{}, &core.TimelineItem{}), Down: dropColumns("expires_at", &core.Message{}, &core.TimelineItem{})},
+}
+
+func autoMigrate(models ...any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.AutoMigrate(models...)
+	}
+}
+
+func dropTables(models ...any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(models...)
+	}
+}
+
+func dropColumns(column string, models ...any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, model := range models {
+			err := tx.Migrator().DropColumn(model, column)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func steps(migrations ...func(tx *gorm.DB) error) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, migration := range migrations {
+			err := migration(tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func execSQL(query string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(query).Error
+	}
+}
+
+// backfillAssociationCounts fills association_counts from the existing associations.
+// counts written by live writes or an earlier run are replaced, as the associations are the source of truth
+func backfillAssociationCounts(tx *gorm.DB) error {
+	stmt := &gorm.Statement{DB: tx}
+	err := stmt.Parse(&core.AssociationCount{})
+	if err != nil {
+		return err
+	}
+	counts := tx.Model(&core.Association{}).Select("target, schema_id, variant, count(*)").Group("target, schema_id, variant")
+	return tx.Exec(
+		"INSERT INTO ? (target, schema_id, variant, count) ? ON CONFLICT (target, schema_id, variant) DO UPDATE SET count = EXCLUDED.count",
+		clause.Table{Name: stmt.Schema.Table}, counts,
+	).Error
+}
+
+// backfillEntityTags splits the joined tags of the entities into entity_tags.
+// entries Tags can't parse, like "a:b:c", are left out as they were never honored
+func backfillEntityTags(tx *gorm.DB) error {
+	stmt := &gorm.Statement{DB: tx}
+	err := stmt.Parse(&core.EntityTag{})
+	if err != nil {
+		return err
+	}
+	tags := tx.Model(&core.Entity{}).
+		Select("id, split_part(entry, ':', 1), split_part(entry, ':', 2)").
+		Joins("CROSS JOIN unnest(string_to_array(tag, ',')) AS entry").
+		Where("entry <> '' AND entry NOT LIKE '%:%:%'")
+	return tx.Exec("INSERT INTO ? (entity_id, name, value) ? ON CONFLICT DO NOTHING", clause.Table{Name: stmt.Schema.Table}, tags).Error
+}
+
+// All returns the migrations sorted by version
+func All() ([]Migration, error) {
+	migrations, err := load(sqlFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations = append(migrations, goMigrations...)
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicated migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// load reads the SQL migrations in the directory
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		var direction string
+		base := entry.Name()
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		stem := strings.TrimSuffix(base, "."+direction+".sql")
+		versionStr, name, ok := strings.Cut(stem, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", base)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", base)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, base))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = execSQL(string(body))
+		} else {
+			migration.Down = execSQL(string(body))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(fstest.MapFS{
+		"sql/0003_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON b (c);")},
+		"sql/0003_add_index.down.sql": {Data: []byte("DROP INDEX a;")},
+		"sql/0004_add_column.up.sql":  {Data: []byte("ALTER TABLE b ADD COLUMN d text;")},
+		"sql/README.md":               {Data: []byte("ignored")},
+	}, "sql")
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	byVersion := map[int64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	assert.Equal(t, "add_index", byVersion[3].Name)
+	assert.NotNil(t, byVersion[3].Down)
+	assert.Equal(t, "add_column", byVersion[4].Name)
+	assert.Nil(t, byVersion[4].Down)
+
+	_, err = load(fstest.MapFS{"sql/0005_orphan.down.sql": {Data: []byte("")}}, "sql")
+	assert.Error(t, err)
+
+	_, err = load(fstest.MapFS{"sql/noversion.up.sql": {Data: []byte("")}}, "sql")
+	assert.Error(t, err)
+}
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, migrations) {
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "baseline", migrations[0].Name)
+	}
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+	}
+}
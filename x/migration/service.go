@@ -0,0 +1,231 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("migration")
+
+// ErrorIrreversible is returned when the latest migration has no down migration
+var ErrorIrreversible = errors.New("migration can't be reverted")
+
+// Status is the state of a migration in the database
+type Status struct {
+	Version    int64      `json:"version"`
+	Name       string     `json:"name"`
+	Applied    bool       `json:"applied"`
+	AppliedAt  *time.Time `json:"appliedAt,omitempty"`
+	Reversible bool       `json:"reversible"`
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:text"`
+	AppliedAt time.Time `gorm:"type:timestamp with time zone;not null;default:clock_timestamp()"`
+}
+
+// Service is the interface for migration service
+type Service interface {
+	Status(ctx context.Context) ([]Status, error)
+	Pending(ctx context.Context) ([]Status, error)
+	Up(ctx context.Context) ([]Status, error)
+	Down(ctx context.Context) (Status, error)
+}
+
+type service struct {
+	db     *gorm.DB
+	schema string
+}
+
+// NewService creates a new migration service.
+// schema is the postgres schema of a tenant, or empty for the default one
+func NewService(db *gorm.DB, schema string) Service {
+	return &service{db, schema}
+}
+
+func (s *service) applied(ctx context.Context, db *gorm.DB) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	err := db.WithContext(ctx).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (s *service) prepare(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&schemaMigration{})
+}
+
+// Status returns all migrations and whether they are applied
+func (s *service) Status(ctx context.Context) ([]Status, error) {
+	ctx, span := tracer.Start(ctx, "Migration.Service.Status")
+	defer span.End()
+
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.prepare(ctx); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	applied, err := s.applied(ctx, s.db)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result := make([]Status, len(migrations))
+	for i, migration := range migrations {
+		result[i] = Status{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			Reversible: migration.Down != nil,
+		}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			result[i].Applied = true
+			result[i].AppliedAt = &appliedAt
+		}
+	}
+	return result, nil
+}
+
+// Pending returns the migrations not applied yet
+func (s *service) Pending(ctx context.Context) ([]Status, error) {
+	status, err := s.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]Status, 0)
+	for _, migration := range status {
+		if !migration.Applied {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// transaction runs fn holding the migration lock of the schema, so that instances booting together apply each migration once
+func (s *service) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "migration:"+s.schema).Error
+		if err != nil {
+			return err
+		}
+		if s.schema != "" {
+			err = tx.Exec(fmt.Sprintf("SET LOCAL search_path TO %q", s.schema)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// Up applies the pending migrations in order and returns them. it stops at the first failure
+func (s *service) Up(ctx context.Context) ([]Status, error) {
+	ctx, span := tracer.Start(ctx, "Migration.Service.Up")
+	defer span.End()
+
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.prepare(ctx); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	done := make([]Status, 0)
+	for _, migration := range migrations {
+		ran := false
+		err := s.transaction(ctx, func(tx *gorm.DB) error {
+			applied, err := s.applied(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if _, ok := applied[migration.Version]; ok {
+				return nil
+			}
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name}).Error
+		})
+		if err != nil {
+			span.RecordError(err)
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if !ran {
+			continue
+		}
+
+		logging.Info(ctx, "migration applied",
+			slog.String("module", "migration"),
+			slog.String("schema", s.schema),
+			slog.Int64("version", migration.Version),
+			slog.String("name", migration.Name),
+		)
+		done = append(done, Status{Version: migration.Version, Name: migration.Name, Applied: true, Reversible: migration.Down != nil})
+	}
+	return done, nil
+}
+
+// Down reverts the latest applied migration and returns it
+func (s *service) Down(ctx context.Context) (Status, error) {
+	ctx, span := tracer.Start(ctx, "Migration.Service.Down")
+	defer span.End()
+
+	migrations, err := All()
+	if err != nil {
+		return Status{}, err
+	}
+	if err := s.prepare(ctx); err != nil {
+		span.RecordError(err)
+		return Status{}, err
+	}
+
+	var reverted Status
+	err = s.transaction(ctx, func(tx *gorm.DB) error {
+		applied, err := s.applied(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("%w: %d_%s", ErrorIrreversible, migration.Version, migration.Name)
+			}
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			reverted = Status{Version: migration.Version, Name: migration.Name, Reversible: true}
+			return tx.Where("version = ?", migration.Version).Delete(&schemaMigration{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return Status{}, err
+	}
+	return reverted, nil
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_commit_logs_key_id;
@@ -0,0 +1,2 @@
+-- documents signed with a key are looked up when the key is revoked
+CREATE INDEX IF NOT EXISTS idx_commit_logs_key_id ON commit_logs ((document->>'keyID'));
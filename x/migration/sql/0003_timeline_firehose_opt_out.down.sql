@@ -0,0 +1 @@
+ALTER TABLE timelines DROP COLUMN IF EXISTS firehose_opt_out;
@@ -0,0 +1,2 @@
+-- timelines can opt out of the firehose of the domain
+ALTER TABLE timelines ADD COLUMN IF NOT EXISTS firehose_opt_out boolean DEFAULT false;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_entities_last_fetched;
+ALTER TABLE entities DROP COLUMN IF EXISTS last_fetched;
@@ -0,0 +1,3 @@
+-- remote entities record when they were last pulled from their domain
+ALTER TABLE entities ADD COLUMN IF NOT EXISTS last_fetched timestamp with time zone DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS idx_entities_last_fetched ON entities (domain, last_fetched);
@@ -0,0 +1,186 @@
+package mute
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// filter is the compiled form of the rules of a user
+type filter struct {
+	repo   Repository
+	entity core.EntityService
+
+	keywords []string
+	authors  map[string]bool
+	schemas  map[string]bool
+	domains  map[string]bool
+
+	// domains of the authors seen so far. a realtime connection keeps its filter for long
+	mu       sync.Mutex
+	domainOf map[string]string
+}
+
+func newFilter(repo Repository, entity core.EntityService, rules []core.MuteRule) *filter {
+	f := &filter{
+		repo:     repo,
+		entity:   entity,
+		authors:  map[string]bool{},
+		schemas:  map[string]bool{},
+		domains:  map[string]bool{},
+		domainOf: map[string]string{},
+	}
+	for _, rule := range rules {
+		switch rule.Type {
+		case TypeKeyword:
+			f.keywords = append(f.keywords, strings.ToLower(rule.Value))
+		case TypeAuthor:
+			f.authors[rule.Value] = true
+		case TypeSchema:
+			f.schemas[rule.Value] = true
+		case TypeDomain:
+			f.domains[rule.Value] = true
+		}
+	}
+	return f
+}
+
+// Empty reports whether the filter hides nothing
+func (f *filter) Empty() bool {
+	return len(f.keywords) == 0 && len(f.authors) == 0 && len(f.schemas) == 0 && len(f.domains) == 0
+}
+
+// needsDocument reports whether a rule looks into the document of an item
+func (f *filter) needsDocument() bool {
+	return len(f.keywords) > 0 || len(f.schemas) > 0
+}
+
+func (f *filter) domain(ctx context.Context, ccid string) string {
+	f.mu.Lock()
+	domain, ok := f.domainOf[ccid]
+	f.mu.Unlock()
+	if ok {
+		return domain
+	}
+
+	// an entity we can't resolve is in no domain, so it is never muted by a domain rule
+	entity, err := f.entity.Get(ctx, ccid)
+	if err == nil {
+		domain = entity.Domain
+	}
+
+	f.mu.Lock()
+	f.domainOf[ccid] = domain
+	f.mu.Unlock()
+	return domain
+}
+
+// mutedAuthor checks the rules which only need the author of an item
+func (f *filter) mutedAuthor(ctx context.Context, item core.TimelineItem) bool {
+	author := item.Owner
+	if item.Author != nil {
+		author = *item.Author
+	}
+	if f.authors[author] {
+		return true
+	}
+	if len(f.domains) > 0 && f.domains[f.domain(ctx, author)] {
+		return true
+	}
+	return item.Schema != "" && f.schemas[item.Schema]
+}
+
+// mutedDocument checks the schema and the keyword rules against a document
+func (f *filter) mutedDocument(document string) bool {
+	var doc struct {
+		Schema string `json:"schema"`
+		Body   any    `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return false
+	}
+	if f.schemas[doc.Schema] {
+		return true
+	}
+	if len(f.keywords) == 0 {
+		return false
+	}
+
+	var text strings.Builder
+	collectText(doc.Body, &text)
+	content := strings.ToLower(text.String())
+	for _, keyword := range f.keywords {
+		if strings.Contains(content, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectText writes every string of a document body, so that keywords match whatever field they are in
+func collectText(v any, w *strings.Builder) {
+	switch v := v.(type) {
+	case string:
+		w.WriteString(v)
+		w.WriteByte('\n')
+	case []any:
+		for _, e := range v {
+			collectText(e, w)
+		}
+	case map[string]any:
+		for _, e := range v {
+			collectText(e, w)
+		}
+	}
+}
+
+// MutedItems returns the resource ids of the muted items.
+// keywords and schemas are only checked against messages stored on this domain
+func (f *filter) MutedItems(ctx context.Context, items []core.TimelineItem) map[string]bool {
+	ctx, span := tracer.Start(ctx, "Mute.Filter.MutedItems")
+	defer span.End()
+
+	muted := map[string]bool{}
+	pending := make([]string, 0)
+	for _, item := range items {
+		if f.mutedAuthor(ctx, item) {
+			muted[item.ResourceID] = true
+			continue
+		}
+		pending = append(pending, item.ResourceID)
+	}
+
+	if len(pending) == 0 || !f.needsDocument() {
+		return muted
+	}
+
+	documents, err := f.repo.GetDocuments(ctx, pending)
+	if err != nil {
+		// better show a muted item than nothing at all
+		span.RecordError(err)
+		return muted
+	}
+	for id, document := range documents {
+		if f.mutedDocument(document) {
+			muted[id] = true
+		}
+	}
+	return muted
+}
+
+// MutedEvent reports whether a realtime event carries a muted item
+func (f *filter) MutedEvent(ctx context.Context, event core.Event) bool {
+	if event.Item == nil {
+		return false
+	}
+	if f.mutedAuthor(ctx, *event.Item) {
+		return true
+	}
+	if f.needsDocument() && event.Document != "" {
+		return f.mutedDocument(event.Document)
+	}
+	return false
+}
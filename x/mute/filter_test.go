@@ -0,0 +1,76 @@
+package mute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+type documentRepository struct {
+	Repository
+	documents map[string]string
+}
+
+func (r documentRepository) GetDocuments(_ context.Context, resourceIDs []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, id := range resourceIDs {
+		if document, ok := r.documents[id]; ok {
+			result[id] = document
+		}
+	}
+	return result, nil
+}
+
+func TestMutedItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	entity := mock_core.NewMockEntityService(ctrl)
+	entity.EXPECT().Get(gomock.Any(), "con1remote").Return(core.Entity{ID: "con1remote", Domain: "spam.example.com"}, nil).Times(1)
+	entity.EXPECT().Get(gomock.Any(), gomock.Any()).Return(core.Entity{Domain: "local.example.com"}, nil).AnyTimes()
+
+	repo := documentRepository{documents: map[string]string{
+		"m1": `{"schema":"https://schema.concrnt.world/m/markdown.json","body":{"body":"Buy NOW"}}`,
+		"m2": `{"schema":"https://example.com/bot.json","body":{"body":"beep"}}`,
+		"m3": `{"schema":"https://schema.concrnt.world/m/markdown.json","body":{"body":"hello"}}`,
+	}}
+
+	filter := newFilter(repo, entity, []core.MuteRule{
+		{Type: TypeKeyword, Value: "buy now"},
+		{Type: TypeAuthor, Value: "con1muted"},
+		{Type: TypeSchema, Value: "https://example.com/bot.json"},
+		{Type: TypeDomain, Value: "spam.example.com"},
+	})
+	assert.False(t, filter.Empty())
+
+	muted := filter.MutedItems(context.Background(), []core.TimelineItem{
+		{ResourceID: "m1", Owner: "con1alice"},
+		{ResourceID: "m2", Owner: "con1alice"},
+		{ResourceID: "m3", Owner: "con1alice"},
+		{ResourceID: "m4", Owner: "con1muted"},
+		{ResourceID: "m5", Owner: "con1remote"},
+		{ResourceID: "m6", Owner: "con1remote"},
+	})
+
+	assert.Equal(t, map[string]bool{"m1": true, "m2": true, "m4": true, "m5": true, "m6": true}, muted)
+}
+
+func TestMutedEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	entity := mock_core.NewMockEntityService(ctrl)
+	filter := newFilter(documentRepository{}, entity, []core.MuteRule{
+		{Type: TypeKeyword, Value: "spoiler"},
+	})
+
+	item := &core.TimelineItem{ResourceID: "m1", Owner: "con1alice"}
+	assert.True(t, filter.MutedEvent(context.Background(), core.Event{Item: item, Document: `{"body":{"body":"big SPOILER ahead"}}`}))
+	assert.False(t, filter.MutedEvent(context.Background(), core.Event{Item: item, Document: `{"body":{"body":"hello"}}`}))
+	assert.False(t, filter.MutedEvent(context.Background(), core.Event{Document: `{"body":{"body":"spoiler"}}`}))
+}
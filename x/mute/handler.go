@@ -0,0 +1,106 @@
+package mute
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	List(c echo.Context) error
+	Create(c echo.Context) error
+	Delete(c echo.Context) error
+}
+
+type handler struct {
+	service core.MuteService
+}
+
+// NewHandler creates a new handler
+func NewHandler(service core.MuteService) Handler {
+	return &handler{service}
+}
+
+type createRequest struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// List returns the mute rules of the requester
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mute.Handler.List")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	rules, err := h.service.List(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": rules})
+}
+
+// Create adds a mute rule of the requester
+func (h handler) Create(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mute.Handler.Create")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	var request createRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	rule, err := h.service.Create(ctx, core.MuteRule{
+		Owner: requester,
+		Type:  request.Type,
+		Value: request.Value,
+	})
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, ErrorInvalidRule) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, echo.Map{"status": "ok", "content": rule})
+}
+
+// Delete removes a mute rule of the requester
+func (h handler) Delete(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Mute.Handler.Delete")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid id"})
+	}
+
+	err = h.service.Delete(ctx, requester, uint(id))
+	if err != nil {
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "mute rule not found"})
+		}
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
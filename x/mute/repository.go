@@ -0,0 +1,103 @@
+package mute
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for mute repository
+type Repository interface {
+	List(ctx context.Context, owner string) ([]core.MuteRule, error)
+	Count(ctx context.Context, owner string) (int64, error)
+	Create(ctx context.Context, rule core.MuteRule) (core.MuteRule, error)
+	Delete(ctx context.Context, owner string, id uint) error
+	GetDocuments(ctx context.Context, resourceIDs []string) (map[string]string, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new mute repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// List returns the rules of the owner
+func (r *repository) List(ctx context.Context, owner string) ([]core.MuteRule, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Repository.List")
+	defer span.End()
+
+	var rules []core.MuteRule
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// Count returns the number of rules of the owner
+func (r *repository) Count(ctx context.Context, owner string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Repository.Count")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.MuteRule{}).Where("owner = ?", owner).Count(&count).Error
+	return count, err
+}
+
+// Create stores a rule
+func (r *repository) Create(ctx context.Context, rule core.MuteRule) (core.MuteRule, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Repository.Create")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Create(&rule).Error
+	return rule, err
+}
+
+// Delete removes a rule of the owner
+func (r *repository) Delete(ctx context.Context, owner string, id uint) error {
+	ctx, span := tracer.Start(ctx, "Mute.Repository.Delete")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("owner = ? AND id = ?", owner, id).Delete(&core.MuteRule{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
+
+// GetDocuments returns the documents of the locally stored messages, keyed by resource id.
+// messages of other domains are not in the result
+func (r *repository) GetDocuments(ctx context.Context, resourceIDs []string) (map[string]string, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Repository.GetDocuments")
+	defer span.End()
+
+	ids := make([]string, 0, len(resourceIDs))
+	for _, resourceID := range resourceIDs {
+		if strings.HasPrefix(resourceID, "m") {
+			ids = append(ids, resourceID[1:])
+		}
+	}
+	if len(ids) == 0 {
+		return map[string]string{}, nil
+	}
+
+	var messages []core.Message
+	err := r.db.WithContext(ctx).Select("id", "document").Where("id IN ?", ids).Find(&messages).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	documents := make(map[string]string, len(messages))
+	for _, message := range messages {
+		documents["m"+message.ID] = message.Document
+	}
+	return documents, nil
+}
@@ -0,0 +1,92 @@
+// Package mute stores the mute rules of users and filters what they read by them
+package mute
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+var tracer = otel.Tracer("mute")
+
+const (
+	TypeKeyword = "keyword"
+	TypeAuthor  = "author"
+	TypeSchema  = "schema"
+	TypeDomain  = "domain"
+
+	maxRulesPerUser = 500
+	maxValueLength  = 256
+)
+
+// ErrorInvalidRule is returned for rules which can't be stored
+var ErrorInvalidRule = core.NewErrorInvalidArgument("invalid mute rule")
+
+type service struct {
+	repo   Repository
+	entity core.EntityService
+}
+
+// NewService creates a new mute service
+func NewService(repo Repository, entity core.EntityService) core.MuteService {
+	return &service{repo, entity}
+}
+
+// List returns the rules of the owner
+func (s *service) List(ctx context.Context, owner string) ([]core.MuteRule, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Service.List")
+	defer span.End()
+
+	return s.repo.List(ctx, owner)
+}
+
+// Create adds a rule of the owner
+func (s *service) Create(ctx context.Context, rule core.MuteRule) (core.MuteRule, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Service.Create")
+	defer span.End()
+
+	switch rule.Type {
+	case TypeKeyword, TypeAuthor, TypeSchema, TypeDomain:
+	default:
+		return core.MuteRule{}, fmt.Errorf("%w: unknown type %q", ErrorInvalidRule, rule.Type)
+	}
+	if rule.Value == "" || len(rule.Value) > maxValueLength {
+		return core.MuteRule{}, fmt.Errorf("%w: value must be 1 to %d bytes", ErrorInvalidRule, maxValueLength)
+	}
+
+	count, err := s.repo.Count(ctx, rule.Owner)
+	if err != nil {
+		span.RecordError(err)
+		return core.MuteRule{}, err
+	}
+	if count >= maxRulesPerUser {
+		return core.MuteRule{}, fmt.Errorf("%w: too many rules", ErrorInvalidRule)
+	}
+
+	rule.ID = 0
+	return s.repo.Create(ctx, rule)
+}
+
+// Delete removes a rule of the owner
+func (s *service) Delete(ctx context.Context, owner string, id uint) error {
+	ctx, span := tracer.Start(ctx, "Mute.Service.Delete")
+	defer span.End()
+
+	return s.repo.Delete(ctx, owner, id)
+}
+
+// Filter returns the filter made of the rules of the owner
+func (s *service) Filter(ctx context.Context, owner string) (core.MuteFilter, error) {
+	ctx, span := tracer.Start(ctx, "Mute.Service.Filter")
+	defer span.End()
+
+	rules, err := s.repo.List(ctx, owner)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return newFilter(s.repo, s.entity, rules), nil
+}
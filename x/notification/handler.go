@@ -15,6 +15,8 @@ type Handler interface {
 	Subscribe(c echo.Context) error
 	Delete(c echo.Context) error
 	Get(c echo.Context) error
+	GetPreference(c echo.Context) error
+	UpdatePreference(c echo.Context) error
 }
 
 type handler struct {
@@ -76,3 +78,51 @@ func (h *handler) Get(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, echo.Map{"content": subscription})
 }
+
+func (h *handler) GetPreference(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Notification.Handler.GetPreference")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	preference, err := h.service.GetPreference(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"content": preference})
+}
+
+func (h *handler) UpdatePreference(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Notification.Handler.UpdatePreference")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	var preference core.NotificationPreference
+	err := c.Bind(&preference)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	if preference.RateLimit < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "rateLimit must not be negative"})
+	}
+	preference.Owner = requester
+
+	preference, err = h.service.UpdatePreference(ctx, preference)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"content": preference})
+}
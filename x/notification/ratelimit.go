@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"sync"
+	"time"
+)
+
+// rateCap counts notifications per key in fixed one hour windows.
+// windows whose hour has passed are removed, at most once an hour
+type rateCap struct {
+	mu      sync.Mutex
+	windows map[string]rateWindow
+	swept   time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateCap() *rateCap {
+	return &rateCap{windows: make(map[string]rateWindow)}
+}
+
+// allow reports whether another notification can be sent for key and counts it if so
+func (r *rateCap) allow(key string, limit int, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.swept) >= time.Hour {
+		r.sweep(now)
+	}
+
+	window := r.windows[key]
+	if now.Sub(window.start) >= time.Hour {
+		window = rateWindow{start: now}
+	}
+
+	if window.count >= limit {
+		r.windows[key] = window
+		return false
+	}
+
+	window.count++
+	r.windows[key] = window
+	return true
+}
+
+// sweep deletes the windows which ended before now. the caller holds the lock
+func (r *rateCap) sweep(now time.Time) {
+	for key, window := range r.windows {
+		if now.Sub(window.start) >= time.Hour {
+			delete(r.windows, key)
+		}
+	}
+	r.swept = now
+}
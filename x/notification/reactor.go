@@ -6,6 +6,7 @@ import (
 	"io"
 	"log/slog"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/SherClockHolmes/webpush-go"
@@ -13,17 +14,48 @@ import (
 	"github.com/totegamma/concurrent/core"
 )
 
+const (
+	defaultRateLimit   = 60 // per hour
+	preferenceCacheTTL = time.Minute
+)
+
+// ReactorConfig configures which events are pushed and how often
+type ReactorConfig struct {
+	Triggers  []Trigger
+	RateLimit int // default notifications per hour per subscription
+}
+
 type reactor struct {
 	service  core.NotificationService
 	timeline core.TimelineService
 	opts     webpush.Options
+	config   ReactorConfig
+	rateCap  *rateCap
+
+	mu          sync.Mutex
+	preferences map[string]cachedPreference
+	swept       time.Time
 }
 
-func NewReactor(service core.NotificationService, timeline core.TimelineService, opts webpush.Options) Reactor {
+type cachedPreference struct {
+	preference core.NotificationPreference
+	fetchedAt  time.Time
+}
+
+func NewReactor(service core.NotificationService, timeline core.TimelineService, opts webpush.Options, config ReactorConfig) Reactor {
+	if config.Triggers == nil {
+		config.Triggers = DefaultTriggers
+	}
+	if config.RateLimit <= 0 {
+		config.RateLimit = defaultRateLimit
+	}
 	return &reactor{
-		service:  service,
-		timeline: timeline,
-		opts:     opts,
+		service:     service,
+		timeline:    timeline,
+		opts:        opts,
+		config:      config,
+		rateCap:     newRateCap(),
+		preferences: make(map[string]cachedPreference),
 	}
 }
 
@@ -36,6 +68,96 @@ type Worker struct {
 	Routine context.CancelFunc
 }
 
+func (r *reactor) getPreference(ctx context.Context, owner string) core.NotificationPreference {
+	r.mu.Lock()
+	cached, ok := r.preferences[owner]
+	r.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < preferenceCacheTTL {
+		return cached.preference
+	}
+
+	preference, err := r.service.GetPreference(ctx, owner)
+	if err != nil {
+		slog.Error("error getting notification preference", slog.String("error", err.Error()))
+		return core.NotificationPreference{Owner: owner}
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	// expired preferences are dropped, so that owners seen once are not kept forever
+	if now.Sub(r.swept) >= preferenceCacheTTL {
+		for key, cached := range r.preferences {
+			if now.Sub(cached.fetchedAt) >= preferenceCacheTTL {
+				delete(r.preferences, key)
+			}
+		}
+		r.swept = now
+	}
+	r.preferences[owner] = cachedPreference{preference: preference, fetchedAt: now}
+	r.mu.Unlock()
+
+	return preference
+}
+
+// shouldNotify decides whether the event is pushed to the subscription
+func (r *reactor) shouldNotify(ctx context.Context, sub core.NotificationSubscription, event core.Event) (string, bool) {
+	preference := r.getPreference(ctx, sub.Owner)
+
+	reason := ""
+	if trigger, ok := matchTrigger(r.config.Triggers, preference, sub.Owner, event.Document); ok {
+		reason = trigger.Name
+	} else {
+		// subscriptions can also list schemas to be notified regardless of triggers
+		var doc core.DocumentBase[any]
+		err := json.Unmarshal([]byte(event.Document), &doc)
+		if err != nil {
+			slog.Error("error unmarshalling document", slog.String("error", err.Error()))
+			return "", false
+		}
+		if !slices.Contains(sub.Schemas, doc.Schema) || slices.Contains(preference.Muted, doc.Signer) {
+			return "", false
+		}
+		reason = doc.Schema
+	}
+
+	limit := preference.RateLimit
+	if limit <= 0 {
+		limit = r.config.RateLimit
+	}
+	// counted per subscription so that each device of the user gets the same cap
+	if !r.rateCap.allow(sub.VendorID+sub.Owner, limit, time.Now()) {
+		slog.Info("notification rate capped", slog.String("owner", sub.Owner), slog.String("reason", reason))
+		return "", false
+	}
+
+	return reason, true
+}
+
+func (r *reactor) send(sub core.NotificationSubscription, subscription *webpush.Subscription, reason string, event core.Event) {
+	resp, err := webpush.SendNotification([]byte(event.Document), subscription, &r.opts)
+	if err != nil {
+		slog.Error("error sending notification", slog.String("error", err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			slog.Error("error reading response body", slog.String("error", err.Error()))
+			return
+		}
+
+		slog.Error("notification failed",
+			slog.String("vendorID", sub.VendorID),
+			slog.String("owner", sub.Owner),
+			slog.String("reason", reason),
+			slog.String("status", resp.Status),
+			slog.String("body", string(body)),
+		)
+	}
+}
+
 func (r *reactor) Start(ctx context.Context) {
 
 	ticker10 := time.NewTicker(10 * time.Second)
@@ -90,41 +212,11 @@ func (r *reactor) Start(ctx context.Context) {
 							close(realtime)
 							return
 						case event := <-realtime:
-
-							var doc core.DocumentBase[any]
-							err := json.Unmarshal([]byte(event.Document), &doc)
-							if err != nil {
-								slog.Error("error unmarshalling document", slog.String("error", err.Error()))
-								continue
-							}
-
-							if !slices.Contains(sub.Schemas, doc.Schema) {
+							reason, ok := r.shouldNotify(ctx, sub, event)
+							if !ok {
 								continue
 							}
-
-							// Send Notification
-							resp, err := webpush.SendNotification([]byte(event.Document), &subscription, &r.opts)
-							if err != nil {
-								slog.Error("error sending notification", slog.String("error", err.Error()))
-								continue
-							}
-							defer resp.Body.Close()
-
-							if resp.StatusCode != 201 {
-								body, err := io.ReadAll(resp.Body)
-								if err != nil {
-									slog.Error("error reading response body", slog.String("error", err.Error()))
-									continue
-								}
-
-								slog.Error("notification failed",
-									slog.String("vendorID", sub.VendorID),
-									slog.String("owner", sub.Owner),
-									slog.String("schema", doc.Schema),
-									slog.String("status", resp.Status),
-									slog.String("body", string(body)),
-								)
-							}
+							r.send(sub, &subscription, reason, event)
 						}
 					}
 				}(workerctx, sub)
@@ -3,6 +3,7 @@ package notification
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 
 	"github.com/totegamma/concurrent/core"
@@ -13,6 +14,8 @@ type Repo interface {
 	Subscribe(ctx context.Context, notification core.NotificationSubscription) (core.NotificationSubscription, error)
 	GetAllSubscriptions(ctx context.Context) ([]core.NotificationSubscription, error)
 	Delete(ctx context.Context, vendorID, owner string) error
+	GetPreference(ctx context.Context, owner string) (core.NotificationPreference, error)
+	UpsertPreference(ctx context.Context, preference core.NotificationPreference) (core.NotificationPreference, error)
 }
 
 type repository struct {
@@ -71,3 +74,32 @@ func (r *repository) Get(ctx context.Context, vendorID, owner string) (core.Noti
 
 	return notification, nil
 }
+
+func (r *repository) GetPreference(ctx context.Context, owner string) (core.NotificationPreference, error) {
+	ctx, span := tracer.Start(ctx, "Notification.Repository.GetPreference")
+	defer span.End()
+
+	var preference core.NotificationPreference
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).First(&preference).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.NotificationPreference{Owner: owner}, nil
+		}
+		span.RecordError(err)
+		return core.NotificationPreference{}, err
+	}
+
+	return preference, nil
+}
+
+func (r *repository) UpsertPreference(ctx context.Context, preference core.NotificationPreference) (core.NotificationPreference, error) {
+	ctx, span := tracer.Start(ctx, "Notification.Repository.UpsertPreference")
+	defer span.End()
+
+	if err := r.db.WithContext(ctx).Save(&preference).Error; err != nil {
+		span.RecordError(err)
+		return core.NotificationPreference{}, err
+	}
+
+	return preference, nil
+}
@@ -63,3 +63,17 @@ func (s *service) Get(ctx context.Context, vendorID, owner string) (core.Notific
 
 	return subscription, nil
 }
+
+func (s *service) GetPreference(ctx context.Context, owner string) (core.NotificationPreference, error) {
+	ctx, span := tracer.Start(ctx, "Notification.Service.GetPreference")
+	defer span.End()
+
+	return s.repo.GetPreference(ctx, owner)
+}
+
+func (s *service) UpdatePreference(ctx context.Context, preference core.NotificationPreference) (core.NotificationPreference, error) {
+	ctx, span := tracer.Start(ctx, "Notification.Service.UpdatePreference")
+	defer span.End()
+
+	return s.repo.UpsertPreference(ctx, preference)
+}
@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Trigger decides which events are pushed to whom.
+// Type is the document type the trigger reacts to. The recipient is derived from the document:
+// the owner of the target for "association" and the acked entity for "ack".
+type Trigger struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     string   `yaml:"type" json:"type"`
+	Schemas  []string `yaml:"schemas" json:"schemas"`   // empty matches any schema
+	Variants []string `yaml:"variants" json:"variants"` // empty matches any variant
+}
+
+// DefaultTriggers are used when no trigger is configured
+var DefaultTriggers = []Trigger{
+	{Name: "mention", Type: "association", Schemas: []string{"https://schema.concrnt.world/a/mention.json"}},
+	{Name: "reply", Type: "association", Schemas: []string{"https://schema.concrnt.world/a/reply.json"}},
+	{Name: "ack", Type: "ack"},
+}
+
+type triggerDocument struct {
+	core.DocumentBase[any]
+	Variant string `json:"variant"`
+	To      string `json:"to"`
+}
+
+func recipientOf(doc triggerDocument) string {
+	switch doc.Type {
+	case "association":
+		return doc.Owner
+	case "ack":
+		return doc.To
+	default:
+		return ""
+	}
+}
+
+// matchTrigger returns the first trigger enabled in the preference which matches the event document for the owner
+func matchTrigger(triggers []Trigger, preference core.NotificationPreference, owner, document string) (Trigger, bool) {
+	var doc triggerDocument
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return Trigger{}, false
+	}
+
+	// own actions and muted entities are never notified
+	if doc.Signer == owner || slices.Contains(preference.Muted, doc.Signer) {
+		return Trigger{}, false
+	}
+
+	if recipientOf(doc) != owner {
+		return Trigger{}, false
+	}
+
+	for _, trigger := range triggers {
+		if len(preference.Triggers) > 0 && !slices.Contains(preference.Triggers, trigger.Name) {
+			continue
+		}
+		if trigger.Type != doc.Type {
+			continue
+		}
+		if len(trigger.Schemas) > 0 && !slices.Contains(trigger.Schemas, doc.Schema) {
+			continue
+		}
+		if len(trigger.Variants) > 0 && !slices.Contains(trigger.Variants, doc.Variant) {
+			continue
+		}
+		return trigger, true
+	}
+
+	return Trigger{}, false
+}
@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestMatchTrigger(t *testing.T) {
+	owner := "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"
+	other := "con1fk8zlkrfmens3sgj7dzcu3gsw8v9kkysrf8dt5"
+
+	mention := `{"signer":"` + other + `","owner":"` + owner + `","type":"association","schema":"https://schema.concrnt.world/a/mention.json"}`
+	like := `{"signer":"` + other + `","owner":"` + owner + `","type":"association","schema":"https://schema.concrnt.world/a/like.json"}`
+	self := `{"signer":"` + owner + `","owner":"` + owner + `","type":"association","schema":"https://schema.concrnt.world/a/mention.json"}`
+	ack := `{"signer":"` + other + `","type":"ack","from":"` + other + `","to":"` + owner + `"}`
+
+	preference := core.NotificationPreference{Owner: owner}
+
+	trigger, ok := matchTrigger(DefaultTriggers, preference, owner, mention)
+	assert.True(t, ok)
+	assert.Equal(t, "mention", trigger.Name)
+
+	trigger, ok = matchTrigger(DefaultTriggers, preference, owner, ack)
+	assert.True(t, ok)
+	assert.Equal(t, "ack", trigger.Name)
+
+	_, ok = matchTrigger(DefaultTriggers, preference, owner, like)
+	assert.False(t, ok)
+
+	_, ok = matchTrigger(DefaultTriggers, preference, owner, self)
+	assert.False(t, ok)
+
+	_, ok = matchTrigger(DefaultTriggers, preference, other, mention)
+	assert.False(t, ok)
+
+	preference.Triggers = []string{"ack"}
+	_, ok = matchTrigger(DefaultTriggers, preference, owner, mention)
+	assert.False(t, ok)
+
+	preference.Triggers = nil
+	preference.Muted = []string{other}
+	_, ok = matchTrigger(DefaultTriggers, preference, owner, ack)
+	assert.False(t, ok)
+}
+
+func TestRateCap(t *testing.T) {
+	rc := newRateCap()
+	now := time.Now()
+
+	assert.True(t, rc.allow("a", 2, now))
+	assert.True(t, rc.allow("a", 2, now))
+	assert.False(t, rc.allow("a", 2, now))
+	assert.True(t, rc.allow("b", 2, now))
+	assert.True(t, rc.allow("a", 2, now.Add(time.Hour)))
+
+	// the window of b ended, so it is removed
+	assert.Len(t, rc.windows, 1)
+}
@@ -12,6 +12,7 @@ import (
 	"go.opentelemetry.io/otel/codes"
 
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
 )
 
 var (
@@ -35,7 +36,7 @@ func (r *repository) Get(ctx context.Context, url string) (core.Policy, error) {
 	defer span.End()
 
 	// check cache
-	key := fmt.Sprintf("policy:%s", url)
+	key := keyspace.Policy.Key(url)
 	val, err := r.rdb.Get(ctx, key).Result()
 	if err == nil {
 		var policy core.Policy
@@ -100,6 +101,12 @@ func (r *repository) Get(ctx context.Context, url string) (core.Policy, error) {
 		return core.Policy{}, err
 	}
 
+	// skip caching when the policy cache is over its keyspace budget
+	if !keyspace.Policy.Allow(r.rdb) {
+		span.AddEvent("policy cache budget exceeded")
+		return policy, nil
+	}
+
 	err = r.rdb.Set(ctx, key, jsonStr, 10*time.Minute).Err() // 10 minutes
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
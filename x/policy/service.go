@@ -203,6 +203,13 @@ func (s service) test(ctx context.Context, policy core.Policy, context core.Requ
 		return core.PolicyEvalResultDefault, nil
 	}
 
+	// the badges of the requester were verified when the request was authenticated
+	if context.RequesterBadges == nil && context.Requester.ID != "" {
+		if requester, _ := ctx.Value(core.RequesterIdCtxKey).(string); requester == context.Requester.ID {
+			context.RequesterBadges, _ = ctx.Value(core.RequesterBadgesKey).([]string)
+		}
+	}
+
 	result, err := s.eval(statement.Condition, context)
 	resultJson, _ := json.MarshalIndent(result, "", "  ")
 	span.SetAttributes(attribute.String("result", string(resultJson)))
@@ -687,10 +694,24 @@ func (s service) eval(expr core.Expr, requestCtx core.RequestContext) (core.Eval
 			}, err
 		}
 
-		tags := core.ParseTags(requestCtx.Requester.Tag)
 		return core.EvalResult{
 			Operator: "RequesterHasTag",
-			Result:   tags.Has(target),
+			Result:   requestCtx.Requester.HasTag(target),
+		}, nil
+
+	case "RequesterHasBadge":
+		target, ok := expr.Constant.(string)
+		if !ok {
+			err := fmt.Errorf("bad argument type for RequesterHasBadge. Expected string but got %s\n", reflect.TypeOf(expr.Constant))
+			return core.EvalResult{
+				Operator: "RequesterHasBadge",
+				Error:    err.Error(),
+			}, err
+		}
+
+		return core.EvalResult{
+			Operator: "RequesterHasBadge",
+			Result:   slices.Contains(requestCtx.RequesterBadges, target),
 		}, nil
 
 	case "RequesterID":
@@ -715,6 +736,46 @@ func (s service) eval(expr core.Expr, requestCtx core.RequestContext) (core.Eval
 			Result:   tags.Has(target),
 		}, nil
 
+	case "SpamLabel":
+		label := ""
+		if requestCtx.Spam != nil {
+			label = requestCtx.Spam.Label
+		}
+		return core.EvalResult{
+			Operator: "SpamLabel",
+			Result:   label,
+		}, nil
+
+	case "SpamScoreAbove":
+		threshold, ok := expr.Constant.(float64)
+		if !ok {
+			err := fmt.Errorf("bad argument type for SpamScoreAbove. Expected number but got %s\n", reflect.TypeOf(expr.Constant))
+			return core.EvalResult{
+				Operator: "SpamScoreAbove",
+				Error:    err.Error(),
+			}, err
+		}
+
+		return core.EvalResult{
+			Operator: "SpamScoreAbove",
+			Result:   requestCtx.Spam != nil && requestCtx.Spam.Score > threshold,
+		}, nil
+
+	case "RequesterOwnsFewerThan":
+		limit, ok := expr.Constant.(float64)
+		if !ok {
+			err := fmt.Errorf("bad argument type for RequesterOwnsFewerThan. Expected number but got %s\n", reflect.TypeOf(expr.Constant))
+			return core.EvalResult{
+				Operator: "RequesterOwnsFewerThan",
+				Error:    err.Error(),
+			}, err
+		}
+
+		return core.EvalResult{
+			Operator: "RequesterOwnsFewerThan",
+			Result:   float64(requestCtx.Owned) < limit,
+		}, nil
+
 	default:
 		err := fmt.Errorf("unknown operator: %s\n", expr.Operator)
 		return core.EvalResult{
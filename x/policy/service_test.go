@@ -1,6 +1,7 @@
 package policy
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -246,6 +247,85 @@ func TestPolicyTimelineCreatorIsLocalUserOrHasTag(t *testing.T) {
 	}
 }
 
+func TestPolicyRequesterOwnsFewerThan(t *testing.T) {
+
+	const policyJson = `
+    {
+        "statements": {
+            "timeline.create": {
+                "condition": {
+                    "op": "Or",
+                    "args": [
+                        {
+                            "op": "RequesterOwnsFewerThan",
+                            "const": 10
+                        },
+                        {
+                            "op": "RequesterHasTag",
+                            "const": "timeline_creator"
+                        }
+                    ]
+                }
+            }
+        }
+    }`
+
+	var policy core.Policy
+	json.Unmarshal([]byte(policyJson), &policy)
+
+	ctx, _ := testutil.SetupTraceCtx()
+	result, err := s.Test(ctx, policy, core.RequestContext{Owned: 9}, "timeline.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultAllow, result)
+
+	result, err = s.Test(ctx, policy, core.RequestContext{Owned: 10}, "timeline.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultDeny, result)
+
+	result, err = s.Test(ctx, policy, core.RequestContext{Requester: core.Entity{Tag: "timeline_creator"}, Owned: 10}, "timeline.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultAllow, result)
+}
+
+func TestPolicyRequesterHasBadge(t *testing.T) {
+
+	const policyJson = `
+    {
+        "statements": {
+            "timeline.message.create": {
+                "condition": {
+                    "op": "RequesterHasBadge",
+                    "const": "verified"
+                }
+            }
+        }
+    }`
+
+	var policy core.Policy
+	json.Unmarshal([]byte(policyJson), &policy)
+
+	ctx, _ := testutil.SetupTraceCtx()
+	result, err := s.Test(ctx, policy, core.RequestContext{RequesterBadges: []string{"moderator", "verified"}}, "timeline.message.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultAllow, result)
+
+	result, err = s.Test(ctx, policy, core.RequestContext{RequesterBadges: []string{"moderator"}}, "timeline.message.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultDeny, result)
+
+	// the badges verified for the request are used only for the requester of the request
+	alice := core.Entity{ID: "con1fk8zlkrfmens3sgj7dzcu3gsw8v9kkysrf8dt5"}
+	ctx = context.WithValue(ctx, core.RequesterIdCtxKey, alice.ID)
+	ctx = context.WithValue(ctx, core.RequesterBadgesKey, []string{"verified"})
+	result, err = s.Test(ctx, policy, core.RequestContext{Requester: alice}, "timeline.message.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultAllow, result)
+
+	result, err = s.Test(ctx, policy, core.RequestContext{Requester: core.Entity{ID: "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"}}, "timeline.message.create")
+	assert.NoError(t, err)
+	assert.Equal(t, core.PolicyEvalResultDeny, result)
+}
+
 // 2. messageのread
 func TestPolicyMessageRead(t *testing.T) {
 	// globalでの処理
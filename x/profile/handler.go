@@ -11,6 +11,7 @@ import (
 	"go.opentelemetry.io/otel"
 
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/etag"
 )
 
 var tracer = otel.Tracer("profile")
@@ -31,7 +32,7 @@ func NewHandler(service core.ProfileService) Handler {
 	return &handler{service: service}
 }
 
-// Get returns a profile by id
+// Get returns a profile by id. a profile of another domain is fetched from the domain given as hint
 func (h handler) Get(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Profile.Handler.Get")
 	defer span.End()
@@ -41,16 +42,25 @@ func (h handler) Get(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request", "message": "id is required"})
 	}
 
-	profile, err := h.service.Get(ctx, id)
+	var profile core.Profile
+	var err error
+	if hint := c.QueryParam("hint"); hint != "" {
+		profile, err = h.service.GetWithHint(ctx, id, hint)
+	} else {
+		profile, err = h.service.Get(ctx, id)
+	}
 	if err != nil {
 		if errors.Is(err, core.ErrorNotFound{}) {
 			return c.JSON(http.StatusNotFound, echo.Map{"error": "Profile not found"})
 		}
+		if errors.Is(err, core.ErrorRemoteUnavailable{}) {
+			return c.JSON(http.StatusBadGateway, echo.Map{"error": err.Error()})
+		}
 		span.RecordError(err)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": profile})
+	return etag.Respond(c, etag.FromSignatures(profile.Signature), profile.MDate, profile)
 }
 
 func (h handler) GetBySemanticID(c echo.Context) error {
@@ -73,7 +83,7 @@ func (h handler) GetBySemanticID(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": profile})
+	return etag.Respond(c, etag.FromSignatures(profile.Signature), profile.MDate, profile)
 }
 
 // Query returns a profile by author and schema
@@ -2,6 +2,7 @@ package profile
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"slices"
 	"strconv"
@@ -11,13 +12,18 @@ import (
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 
+	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/cache"
 )
 
 // Repository is the interface for profile repository
 type Repository interface {
 	Upsert(ctx context.Context, profile core.Profile) (core.Profile, error)
+	Update(ctx context.Context, profile core.Profile, version int64) (core.Profile, error)
 	Get(ctx context.Context, id string) (core.Profile, error)
+	GetFromRemote(ctx context.Context, host, id string) (core.Profile, error)
 	GetByAuthorAndSchema(ctx context.Context, owner string, schema string) ([]core.Profile, error)
 	GetByAuthor(ctx context.Context, owner string) ([]core.Profile, error)
 	GetBySchema(ctx context.Context, schema string) ([]core.Profile, error)
@@ -29,15 +35,23 @@ type Repository interface {
 
 type repository struct {
 	db     *gorm.DB
-	mc     *memcache.Client
+	mc     cache.Cache
+	client client.Client
 	schema core.SchemaService
 }
 
 // NewRepository creates a new profile repository
-func NewRepository(db *gorm.DB, mc *memcache.Client, schema core.SchemaService) Repository {
-	return &repository{db, mc, schema}
+func NewRepository(db *gorm.DB, mc cache.Cache, client client.Client, schema core.SchemaService) Repository {
+	return &repository{db, mc, client, schema}
 }
 
+const (
+	remoteCachePrefix = "profile:data:"
+	remoteCacheTTL    = 60 * 60 * 24 * 7 // 7 days
+	remoteFreshPrefix = "profile:fresh:"
+	remoteFreshTTL    = 60 * 5 // 5 minutes
+)
+
 func (r *repository) setCurrentCount() {
 	var count int64
 	err := r.db.Model(&core.Profile{}).Count(&count).Error
@@ -163,7 +177,7 @@ func (r *repository) Upsert(ctx context.Context, profile core.Profile) (core.Pro
 	var count int64
 	err = r.db.Model(&core.Profile{}).Count(&count).Error
 	if err != nil {
-		slog.Error(
+		logging.Error(ctx,
 			"failed to count associations",
 			slog.String("error", err.Error()),
 		)
@@ -179,6 +193,50 @@ func (r *repository) Upsert(ctx context.Context, profile core.Profile) (core.Pro
 	return profile, nil
 }
 
+// Update replaces the profile only if it is still at the version, and bumps the version
+func (r *repository) Update(ctx context.Context, profile core.Profile, version int64) (core.Profile, error) {
+	ctx, span := tracer.Start(ctx, "Profile.Repository.Update")
+	defer span.End()
+
+	err := r.preProcess(ctx, &profile)
+	if err != nil {
+		return profile, err
+	}
+	profile.Version = version + 1
+
+	result := r.db.WithContext(ctx).
+		Model(&core.Profile{}).
+		Where("id = ? AND version = ?", profile.ID, version).
+		Select("*").
+		Omit("c_date").
+		Updates(&profile)
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return profile, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var current core.Profile
+		err = r.db.WithContext(ctx).Select("version").Where("id = ?", profile.ID).First(&current).Error
+		if err != nil {
+			span.RecordError(err)
+			return profile, err
+		}
+		return profile, core.NewErrorConflict(current.Version)
+	}
+
+	err = r.db.WithContext(ctx).Where("id = ?", profile.ID).First(&profile).Error
+	if err != nil {
+		return profile, err
+	}
+
+	err = r.postProcess(ctx, &profile)
+	if err != nil {
+		return profile, err
+	}
+
+	return profile, nil
+}
+
 // Get returns a profile by owner and schema
 func (r *repository) GetByAuthorAndSchema(ctx context.Context, owner string, schema string) ([]core.Profile, error) {
 	ctx, span := tracer.Start(ctx, "Profile.Repository.GetByAuthorAndSchema")
@@ -299,6 +357,61 @@ func (r *repository) Get(ctx context.Context, id string) (core.Profile, error) {
 	return profile, nil
 }
 
+// GetFromRemote returns a profile of another domain. it is served from the cache, which is revalidated
+// in background once it is older than a few minutes
+func (r *repository) GetFromRemote(ctx context.Context, host, id string) (core.Profile, error) {
+	ctx, span := tracer.Start(ctx, "Profile.Repository.GetFromRemote")
+	defer span.End()
+
+	var profile *core.Profile
+	item, err := r.mc.Get(remoteCachePrefix + id + "@" + host)
+	if err == nil {
+		err = json.Unmarshal(item.Value, &profile)
+		if err != nil {
+			span.RecordError(err)
+		}
+	}
+
+	if profile == nil {
+		return r.getFromRemote(ctx, host, id)
+	}
+
+	_, err = r.mc.Get(remoteFreshPrefix + id + "@" + host)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		go r.getFromRemote(context.WithoutCancel(ctx), host, id)
+	}
+
+	return *profile, nil
+}
+
+func (r *repository) getFromRemote(ctx context.Context, host, id string) (core.Profile, error) {
+	ctx, span := tracer.Start(ctx, "Profile.Repository.getFromRemote")
+	defer span.End()
+
+	profile, err := r.client.GetProfile(ctx, host, id, nil)
+	if err != nil {
+		span.RecordError(err)
+		return core.Profile{}, err
+	}
+
+	body, err := json.Marshal(profile)
+	if err != nil {
+		span.RecordError(err)
+		return profile, nil
+	}
+
+	err = r.mc.Set(&memcache.Item{Key: remoteCachePrefix + id + "@" + host, Value: body, Expiration: remoteCacheTTL})
+	if err == nil {
+		err = r.mc.Set(&memcache.Item{Key: remoteFreshPrefix + id + "@" + host, Value: []byte("1"), Expiration: remoteFreshTTL})
+	}
+	if err != nil {
+		span.RecordError(err)
+		logging.Error(ctx, "fail to save cache", slog.String("error", err.Error()), slog.String("module", "profile"))
+	}
+
+	return profile, nil
+}
+
 func (r *repository) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "Profile.Repository.Clean")
 	defer span.End()
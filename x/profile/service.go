@@ -48,6 +48,29 @@ func (s *service) Get(ctx context.Context, id string) (core.Profile, error) {
 	return s.repo.Get(ctx, id)
 }
 
+// GetWithHint returns a profile by id. a profile which is not stored here is fetched from the domain of the hint
+func (s *service) GetWithHint(ctx context.Context, id, hint string) (core.Profile, error) {
+	ctx, span := tracer.Start(ctx, "Profile.Service.GetWithHint")
+	defer span.End()
+
+	profile, err := s.repo.Get(ctx, id)
+	if err == nil || !errors.Is(err, core.ErrorNotFound{}) {
+		return profile, err
+	}
+
+	if hint == "" {
+		return core.Profile{}, err
+	}
+
+	profile, err = s.repo.GetFromRemote(ctx, hint, id)
+	if err != nil {
+		span.RecordError(err)
+		return core.Profile{}, err
+	}
+
+	return profile, nil
+}
+
 func (s *service) GetBySemanticID(ctx context.Context, semanticID, owner string) (core.Profile, error) {
 	ctx, span := tracer.Start(ctx, "Profile.Service.GetBySemanticID")
 	defer span.End()
@@ -93,7 +116,7 @@ func (s *service) GetBySchema(ctx context.Context, schema string) ([]core.Profil
 }
 
 // Upsert creates new profile if the signature is valid
-func (s *service) Upsert(ctx context.Context, mode core.CommitMode, document, signature string) (core.Profile, error) {
+func (s *service) Upsert(ctx context.Context, mode core.CommitMode, document, signature, option string) (core.Profile, error) {
 	ctx, span := tracer.Start(ctx, "Profile.Service.Upsert")
 	defer span.End()
 
@@ -104,6 +127,18 @@ func (s *service) Upsert(ctx context.Context, mode core.CommitMode, document, si
 		return core.Profile{}, err
 	}
 
+	var opts upsertOption
+	if option != "" {
+		err = json.Unmarshal([]byte(option), &opts)
+		if err != nil {
+			return core.Profile{}, core.NewErrorInvalidArgument("invalid option")
+		}
+	}
+
+	// the version the update is applied over. 0 creates the profile, or overwrites it when forced
+	var basis int64
+	version := int64(1)
+
 	if doc.SemanticID != "" {
 		existingID, err := s.semanticid.Lookup(ctx, doc.SemanticID, doc.Signer)
 		if err == nil {
@@ -193,6 +228,17 @@ func (s *service) Upsert(ctx context.Context, mode core.CommitMode, document, si
 		if !result {
 			return core.Profile{}, errors.New("policy failed")
 		}
+
+		// replayed commits are applied in the order of the repository, whatever the version they were made over
+		if !opts.Force && mode != core.CommitModeLocalOnlyExec {
+			err = checkVersion(existance.Document, existance.Version, doc.SignedAt, doc.Version)
+			if err != nil {
+				span.RecordError(err)
+				return core.Profile{}, err
+			}
+			basis = existance.Version
+		}
+		version = existance.Version + 1
 	}
 
 	var policyparams *string = nil
@@ -208,9 +254,16 @@ func (s *service) Upsert(ctx context.Context, mode core.CommitMode, document, si
 		Policy:       doc.Policy,
 		PolicyParams: policyparams,
 		Signature:    signature,
+		Version:      version,
 	}
 
-	saved, err := s.repo.Upsert(ctx, profile)
+	var saved core.Profile
+	if basis > 0 {
+		// another update may have been stored since the profile was read
+		saved, err = s.repo.Update(ctx, profile, basis)
+	} else {
+		saved, err = s.repo.Upsert(ctx, profile)
+	}
 	if err != nil {
 		span.RecordError(err)
 		return core.Profile{}, err
@@ -227,6 +280,25 @@ func (s *service) Upsert(ctx context.Context, mode core.CommitMode, document, si
 	return saved, nil
 }
 
+// upsertOption is the option of a profile commit. Force overwrites the profile even if the update was made
+// over an older version
+type upsertOption struct {
+	Force bool `json:"force"`
+}
+
+// checkVersion refuses an update signed before the stored document, or made over another version than the stored one
+func checkVersion(stored string, current int64, signedAt time.Time, basis int64) error {
+	var base core.DocumentBase[any]
+	err := json.Unmarshal([]byte(stored), &base)
+	if err == nil && signedAt.Before(base.SignedAt) {
+		return core.NewErrorConflict(current)
+	}
+	if basis != 0 && basis != current {
+		return core.NewErrorConflict(current)
+	}
+	return nil
+}
+
 // Delete deletes profile
 func (s *service) Delete(ctx context.Context, mode core.CommitMode, document string) (core.Profile, error) {
 	ctx, span := tracer.Start(ctx, "Profile.Service.Delete")
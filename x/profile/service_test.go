@@ -0,0 +1,53 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// remoteRepository stores one local profile and serves the others from remote domains
+type remoteRepository struct {
+	Repository
+	remote map[string]core.Profile
+}
+
+func (r *remoteRepository) Get(ctx context.Context, id string) (core.Profile, error) {
+	if id == "plocal" {
+		return core.Profile{ID: id}, nil
+	}
+	return core.Profile{}, core.NewErrorNotFound()
+}
+
+func (r *remoteRepository) GetFromRemote(ctx context.Context, host, id string) (core.Profile, error) {
+	profile, ok := r.remote[id+"@"+host]
+	if !ok {
+		return core.Profile{}, core.NewErrorRemoteUnavailable(host, errors.New("dial tcp"))
+	}
+	return profile, nil
+}
+
+func TestGetWithHint(t *testing.T) {
+	s := &service{repo: &remoteRepository{remote: map[string]core.Profile{
+		"premote@remote.example.com": {ID: "premote", Author: "con1remote"},
+	}}}
+	ctx := context.Background()
+
+	profile, err := s.GetWithHint(ctx, "plocal", "remote.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "plocal", profile.ID)
+
+	profile, err = s.GetWithHint(ctx, "premote", "remote.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "con1remote", profile.Author)
+
+	_, err = s.GetWithHint(ctx, "premote", "")
+	assert.ErrorIs(t, err, core.ErrorNotFound{})
+
+	_, err = s.GetWithHint(ctx, "premote", "offline.example.com")
+	assert.ErrorIs(t, err, core.ErrorRemoteUnavailable{})
+}
@@ -0,0 +1,43 @@
+package quota
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Get(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// Get returns the consumption of the requester today
+func (h handler) Get(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Quota.Handler.Get")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+	tags, _ := ctx.Value(core.RequesterTagCtxKey).(core.Tags)
+
+	report, err := h.service.Report(ctx, requester, tags)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": report})
+}
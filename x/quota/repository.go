@@ -0,0 +1,71 @@
+package quota
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+// counters of a day are kept a bit longer than the day so that the usage of yesterday can still be shown
+const usageTTL = 48 * time.Hour
+
+// Repository is the interface for quota repository
+type Repository interface {
+	Get(ctx context.Context, ccid, day string) (Usage, error)
+	Add(ctx context.Context, ccid, day string, usage Usage) error
+}
+
+type repository struct {
+	rdb *redis.Client
+}
+
+// NewRepository creates a new quota repository
+func NewRepository(rdb *redis.Client) Repository {
+	return &repository{rdb}
+}
+
+func usageKey(ccid, day string) string {
+	return keyspace.Quota.Key(ccid, day)
+}
+
+// Get returns the consumption of the entity on the day
+func (r *repository) Get(ctx context.Context, ccid, day string) (Usage, error) {
+	ctx, span := tracer.Start(ctx, "Quota.Repository.Get")
+	defer span.End()
+
+	fields, err := r.rdb.HGetAll(ctx, usageKey(ccid, day)).Result()
+	if err != nil {
+		span.RecordError(err)
+		return Usage{}, err
+	}
+
+	var usage Usage
+	usage.Requests, _ = strconv.ParseInt(fields["requests"], 10, 64)
+	usage.Bytes, _ = strconv.ParseInt(fields["bytes"], 10, 64)
+	usage.Commits, _ = strconv.ParseInt(fields["commits"], 10, 64)
+	return usage, nil
+}
+
+// Add adds the consumption of a request to the counters of the day
+func (r *repository) Add(ctx context.Context, ccid, day string, usage Usage) error {
+	ctx, span := tracer.Start(ctx, "Quota.Repository.Add")
+	defer span.End()
+
+	key := usageKey(ccid, day)
+	pipe := r.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, key, "requests", usage.Requests)
+	pipe.HIncrBy(ctx, key, "bytes", usage.Bytes)
+	if usage.Commits > 0 {
+		pipe.HIncrBy(ctx, key, "commits", usage.Commits)
+	}
+	pipe.Expire(ctx, key, usageTTL)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
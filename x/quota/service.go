@@ -0,0 +1,179 @@
+// Package quota accounts the API consumption of each entity and enforces daily quotas.
+// the gateway counts the requests, and the api serves the consumption to the users.
+package quota
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("quota")
+
+// DefaultLimit is the name of the limit applied to entities without a tag having its own limit
+const DefaultLimit = "DEFAULT"
+
+// Limit is the daily allowance of an entity. 0 means unlimited
+type Limit struct {
+	Requests int64 `yaml:"requests" json:"requests"`
+	Bytes    int64 `yaml:"bytes" json:"bytes"`
+	Commits  int64 `yaml:"commits" json:"commits"`
+}
+
+// Usage is the consumption of an entity in a day
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Bytes    int64 `json:"bytes"`
+	Commits  int64 `json:"commits"`
+}
+
+// Report is the consumption of an entity today against its limit
+type Report struct {
+	Day   string    `json:"day"`
+	Usage Usage     `json:"usage"`
+	Limit Limit     `json:"limit"`
+	Reset time.Time `json:"reset"`
+}
+
+// Service is the interface for quota service
+type Service interface {
+	Middleware(next echo.HandlerFunc) echo.HandlerFunc
+	Report(ctx context.Context, ccid string, tags core.Tags) (Report, error)
+}
+
+type service struct {
+	repo   Repository
+	limits map[string]Limit
+	now    func() time.Time
+}
+
+// NewService creates a new quota service.
+// limits are keyed by entity tag, with DefaultLimit for the others. nil limits only account the consumption
+func NewService(repo Repository, limits map[string]Limit) Service {
+	if limits == nil {
+		limits = map[string]Limit{}
+	}
+	return &service{repo: repo, limits: limits, now: time.Now}
+}
+
+// day returns the accounting day and when it ends. days are in UTC
+func (s *service) day() (string, time.Time) {
+	now := s.now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
+
+// limitFor returns the limit of an entity. when several of its tags have a limit, the most generous one wins for each counter
+func (s *service) limitFor(tags core.Tags) Limit {
+	var limit Limit
+	matched := false
+	for tag, tagLimit := range s.limits {
+		if tag == DefaultLimit || !tags.Has(tag) {
+			continue
+		}
+		if !matched {
+			limit = tagLimit
+			matched = true
+			continue
+		}
+		limit.Requests = generous(limit.Requests, tagLimit.Requests)
+		limit.Bytes = generous(limit.Bytes, tagLimit.Bytes)
+		limit.Commits = generous(limit.Commits, tagLimit.Commits)
+	}
+	if !matched {
+		return s.limits[DefaultLimit]
+	}
+	return limit
+}
+
+func generous(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// exceeded tells which counter of the limit the request would go over, or an empty string
+func exceeded(limit Limit, usage Usage, commit bool) string {
+	switch {
+	case limit.Requests > 0 && usage.Requests >= limit.Requests:
+		return "requests"
+	case limit.Bytes > 0 && usage.Bytes >= limit.Bytes:
+		return "bytes"
+	case commit && limit.Commits > 0 && usage.Commits >= limit.Commits:
+		return "commits"
+	}
+	return ""
+}
+
+func isCommit(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/commit")
+}
+
+// Middleware accounts the requests of registered entities and refuses them when the quota of the day is used up.
+// counting is best effort: the requests pass when redis is unavailable
+func (s *service) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		ccid, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+		if !ok || c.Request().Method == http.MethodOptions {
+			return next(c)
+		}
+		tags, _ := ctx.Value(core.RequesterTagCtxKey).(core.Tags)
+
+		day, reset := s.day()
+		limit := s.limitFor(tags)
+		commit := isCommit(c.Request())
+
+		usage, err := s.repo.Get(ctx, ccid, day)
+		if err != nil {
+			logging.Warn(ctx, "failed to get quota usage", slog.String("module", "quota"), slog.String("error", err.Error()))
+		} else if counter := exceeded(limit, usage, commit); counter != "" {
+			return core.NewErrorQuotaExceeded("daily "+counter, reset)
+		}
+
+		err = next(c)
+
+		consumed := Usage{Requests: 1, Bytes: c.Response().Size}
+		if c.Request().ContentLength > 0 {
+			consumed.Bytes += c.Request().ContentLength
+		}
+		if commit && c.Response().Status < http.StatusBadRequest {
+			consumed.Commits = 1
+		}
+		if addErr := s.repo.Add(ctx, ccid, day, consumed); addErr != nil {
+			logging.Warn(ctx, "failed to account quota usage", slog.String("module", "quota"), slog.String("error", addErr.Error()))
+		}
+
+		return err
+	}
+}
+
+// Report returns the consumption of the entity today
+func (s *service) Report(ctx context.Context, ccid string, tags core.Tags) (Report, error) {
+	ctx, span := tracer.Start(ctx, "Quota.Service.Report")
+	defer span.End()
+
+	day, reset := s.day()
+	usage, err := s.repo.Get(ctx, ccid, day)
+	if err != nil {
+		span.RecordError(err)
+		return Report{}, err
+	}
+
+	return Report{
+		Day:   day,
+		Usage: usage,
+		Limit: s.limitFor(tags),
+		Reset: reset,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type memoryRepository struct {
+	usage map[string]Usage
+}
+
+func (r *memoryRepository) Get(_ context.Context, ccid, day string) (Usage, error) {
+	return r.usage[ccid+day], nil
+}
+
+func (r *memoryRepository) Add(_ context.Context, ccid, day string, usage Usage) error {
+	current := r.usage[ccid+day]
+	current.Requests += usage.Requests
+	current.Bytes += usage.Bytes
+	current.Commits += usage.Commits
+	r.usage[ccid+day] = current
+	return nil
+}
+
+func TestLimitFor(t *testing.T) {
+	s := NewService(&memoryRepository{}, map[string]Limit{
+		DefaultLimit: {Requests: 1000},
+		"_bot":       {Requests: 100, Commits: 10},
+		"_trusted":   {Requests: 500, Commits: 0},
+	}).(*service)
+
+	assert.Equal(t, Limit{Requests: 1000}, s.limitFor(core.NewTags()))
+	assert.Equal(t, Limit{Requests: 100, Commits: 10}, s.limitFor(core.ParseTags("_bot")))
+	assert.Equal(t, Limit{Requests: 500, Commits: 0}, s.limitFor(core.ParseTags("_bot,_trusted")))
+	assert.Equal(t, Limit{}, NewService(&memoryRepository{}, nil).(*service).limitFor(core.ParseTags("_bot")))
+}
+
+func TestMiddleware(t *testing.T) {
+	repo := &memoryRepository{usage: map[string]Usage{}}
+	s := NewService(repo, map[string]Limit{"_bot": {Requests: 10, Commits: 1}}).(*service)
+	s.now = func() time.Time { return time.Date(2024, 1, 2, 23, 0, 0, 0, time.FixedZone("JST", 9*60*60)) }
+
+	e := echo.New()
+	e.HTTPErrorHandler = core.HTTPErrorHandler(e)
+	handler := s.Middleware(func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	commit := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/commit", strings.NewReader("{}"))
+		ctx := context.WithValue(req.Context(), core.RequesterIdCtxKey, "con1bot")
+		ctx = context.WithValue(ctx, core.RequesterTagCtxKey, core.ParseTags("_bot"))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req.WithContext(ctx), rec)
+		if err := handler(c); err != nil {
+			e.HTTPErrorHandler(err, c)
+		}
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, commit().Code)
+	assert.Equal(t, Usage{Requests: 1, Bytes: 4, Commits: 1}, repo.usage["con1bot2024-01-02"])
+
+	rec := commit()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1704240000", rec.Header().Get("X-Quota-Reset"))
+
+	// anonymous requests are not accounted
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/domain", nil)
+	assert.NoError(t, handler(e.NewContext(req, httptest.NewRecorder())))
+	assert.Len(t, repo.usage, 1)
+}
@@ -0,0 +1,111 @@
+package readstate
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	List(c echo.Context) error
+	Set(c echo.Context) error
+	Merge(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+type setRequest struct {
+	ItemID string    `json:"itemId"`
+	ReadAt time.Time `json:"readAt"`
+}
+
+type mergeRequest struct {
+	States []core.ReadState `json:"states"`
+}
+
+func (h handler) respondError(c echo.Context, err error) error {
+	if errors.Is(err, ErrorInvalidState) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+}
+
+// List returns all read states of the requester
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ReadState.Handler.List")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	states, err := h.service.List(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return h.respondError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": states})
+}
+
+// Set moves the read position of a timeline or a subscription forward
+func (h handler) Set(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ReadState.Handler.Set")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	var request setRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	state, err := h.service.Set(ctx, requester, core.ReadState{
+		Target: c.Param("target"),
+		ItemID: request.ItemID,
+		ReadAt: request.ReadAt,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return h.respondError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": state})
+}
+
+// Merge applies the read states of a device and returns the merged states of all devices
+func (h handler) Merge(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ReadState.Handler.Merge")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	var request mergeRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	states, err := h.service.Merge(ctx, requester, request.States)
+	if err != nil {
+		span.RecordError(err)
+		return h.respondError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": states})
+}
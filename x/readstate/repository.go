@@ -0,0 +1,238 @@
+package readstate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+const (
+	// read states of a user stay in redis this long after the last access
+	cacheTTL = 7 * 24 * time.Hour
+
+	// marks the hash of a user as loaded from the database, so that an empty user isn't reloaded every time
+	loadedField = "_loaded"
+)
+
+// owners with writes not flushed to the database yet
+var dirtyKey = keyspace.ReadState.Key("dirty")
+
+func stateKey(owner string) string {
+	return keyspace.ReadState.Key("user", owner)
+}
+
+// advanceScript stores the state only if it is newer than the stored one, and marks the owner dirty.
+// KEYS: state hash, dirty set. ARGV: target, encoded state, readAt in ms, owner, ttl in seconds
+var advanceScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if current and tonumber(string.match(current, '^(%d+)')) >= tonumber(ARGV[3]) then
+	redis.call('EXPIRE', KEYS[1], ARGV[5])
+	return current
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+redis.call('SADD', KEYS[2], ARGV[4])
+return ARGV[2]
+`)
+
+// Repository is the interface for readstate repository
+type Repository interface {
+	List(ctx context.Context, owner string) ([]core.ReadState, error)
+	Advance(ctx context.Context, state core.ReadState) (core.ReadState, error)
+	PopDirty(ctx context.Context, count int64) ([]string, error)
+	MarkDirty(ctx context.Context, owners ...string) error
+	CountDirty(ctx context.Context) (int64, error)
+	Persist(ctx context.Context, states []core.ReadState) error
+}
+
+type repository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewRepository creates a new readstate repository
+func NewRepository(db *gorm.DB, rdb *redis.Client) Repository {
+	return &repository{db, rdb}
+}
+
+// encodeState encodes a state into a hash value as "readAtMs mdateMs itemID".
+// readAt comes first so that the script can compare it.
+func encodeState(state core.ReadState) string {
+	return fmt.Sprintf("%d %d %s", state.ReadAt.UnixMilli(), state.MDate.UnixMilli(), state.ItemID)
+}
+
+func decodeState(owner, target, value string) (core.ReadState, error) {
+	split := strings.SplitN(value, " ", 3)
+	if len(split) != 3 {
+		return core.ReadState{}, fmt.Errorf("invalid read state: %s", value)
+	}
+	readAt, err := strconv.ParseInt(split[0], 10, 64)
+	if err != nil {
+		return core.ReadState{}, err
+	}
+	mdate, err := strconv.ParseInt(split[1], 10, 64)
+	if err != nil {
+		return core.ReadState{}, err
+	}
+	return core.ReadState{
+		Owner:  owner,
+		Target: target,
+		ItemID: split[2],
+		ReadAt: time.UnixMilli(readAt).UTC(),
+		MDate:  time.UnixMilli(mdate).UTC(),
+	}, nil
+}
+
+// load copies the states of the owner from the database into redis unless they are already there
+func (r *repository) load(ctx context.Context, owner string) error {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.load")
+	defer span.End()
+
+	key := stateKey(owner)
+	loaded, err := r.rdb.HExists(ctx, key, loadedField).Result()
+	if err != nil {
+		return err
+	}
+	if loaded {
+		return nil
+	}
+
+	var states []core.ReadState
+	err = r.db.WithContext(ctx).Where("owner = ?", owner).Find(&states).Error
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	// HSETNX keeps the states written while loading
+	pipe := r.rdb.TxPipeline()
+	for _, state := range states {
+		pipe.HSetNX(ctx, key, state.Target, encodeState(state))
+	}
+	pipe.HSet(ctx, key, loadedField, "1")
+	pipe.Expire(ctx, key, cacheTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// List returns all read states of the owner
+func (r *repository) List(ctx context.Context, owner string) ([]core.ReadState, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.List")
+	defer span.End()
+
+	if err := r.load(ctx, owner); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	values, err := r.rdb.HGetAll(ctx, stateKey(owner)).Result()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	states := make([]core.ReadState, 0, len(values))
+	for target, value := range values {
+		if target == loadedField {
+			continue
+		}
+		state, err := decodeState(owner, target, value)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Advance stores the state if it is newer than the current one, and returns the state in effect
+func (r *repository) Advance(ctx context.Context, state core.ReadState) (core.ReadState, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.Advance")
+	defer span.End()
+
+	if err := r.load(ctx, state.Owner); err != nil {
+		span.RecordError(err)
+		return core.ReadState{}, err
+	}
+
+	value, err := advanceScript.Run(
+		ctx,
+		r.rdb,
+		[]string{stateKey(state.Owner), dirtyKey},
+		state.Target,
+		encodeState(state),
+		state.ReadAt.UnixMilli(),
+		state.Owner,
+		int64(cacheTTL/time.Second),
+	).Text()
+	if err != nil {
+		span.RecordError(err)
+		return core.ReadState{}, err
+	}
+
+	return decodeState(state.Owner, state.Target, value)
+}
+
+// PopDirty takes up to count owners with unflushed writes
+func (r *repository) PopDirty(ctx context.Context, count int64) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.PopDirty")
+	defer span.End()
+
+	return r.rdb.SPopN(ctx, dirtyKey, count).Result()
+}
+
+// CountDirty returns the number of owners waiting to be flushed
+func (r *repository) CountDirty(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.CountDirty")
+	defer span.End()
+
+	return r.rdb.SCard(ctx, dirtyKey).Result()
+}
+
+// MarkDirty puts owners back to be flushed again
+func (r *repository) MarkDirty(ctx context.Context, owners ...string) error {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.MarkDirty")
+	defer span.End()
+
+	if len(owners) == 0 {
+		return nil
+	}
+	members := make([]any, len(owners))
+	for i, owner := range owners {
+		members[i] = owner
+	}
+	return r.rdb.SAdd(ctx, dirtyKey, members...).Err()
+}
+
+// Persist upserts the states into the database. older states never overwrite newer ones.
+func (r *repository) Persist(ctx context.Context, states []core.ReadState) error {
+	ctx, span := tracer.Start(ctx, "ReadState.Repository.Persist")
+	defer span.End()
+
+	if len(states) == 0 {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "owner"}, {Name: "target"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"item_id": gorm.Expr("CASE WHEN excluded.read_at > read_states.read_at THEN excluded.item_id ELSE read_states.item_id END"),
+			"read_at": gorm.Expr("GREATEST(excluded.read_at, read_states.read_at)"),
+			"m_date":  gorm.Expr("GREATEST(excluded.m_date, read_states.m_date)"),
+		}),
+	}).Create(&states).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+// Package readstate keeps the last read positions of users so that they are shared across devices
+package readstate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("readstate")
+
+const (
+	maxTargetLength = 256
+	maxMergeStates  = 500
+
+	// owners flushed to the database at once
+	flushBatchSize = 100
+
+	// readAt may be ahead of the server clock by this much
+	allowedClockSkew = 5 * time.Minute
+)
+
+// ErrorInvalidState is returned for read states which can't be stored
+var ErrorInvalidState = core.NewErrorInvalidArgument("invalid read state")
+
+// Service is the interface for readstate service
+type Service interface {
+	List(ctx context.Context, owner string) ([]core.ReadState, error)
+	Set(ctx context.Context, owner string, state core.ReadState) (core.ReadState, error)
+	Merge(ctx context.Context, owner string, states []core.ReadState) ([]core.ReadState, error)
+	Flush(ctx context.Context) error
+	Pending(ctx context.Context) (int64, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new readstate service
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+// List returns the read states of the owner
+func (s *service) List(ctx context.Context, owner string) ([]core.ReadState, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Service.List")
+	defer span.End()
+
+	return s.repo.List(ctx, owner)
+}
+
+func validate(state core.ReadState, now time.Time) error {
+	if state.Target == "" || len(state.Target) > maxTargetLength {
+		return fmt.Errorf("%w: invalid target", ErrorInvalidState)
+	}
+	if state.ReadAt.IsZero() {
+		return fmt.Errorf("%w: readAt is required", ErrorInvalidState)
+	}
+	if state.ReadAt.After(now.Add(allowedClockSkew)) {
+		return fmt.Errorf("%w: readAt is in the future", ErrorInvalidState)
+	}
+	return nil
+}
+
+// Set moves the read position of a target forward. a position older than the current one is ignored,
+// and the current one is returned instead.
+func (s *service) Set(ctx context.Context, owner string, state core.ReadState) (core.ReadState, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Service.Set")
+	defer span.End()
+
+	now := time.Now()
+	if err := validate(state, now); err != nil {
+		return core.ReadState{}, err
+	}
+
+	state.Owner = owner
+	state.MDate = now
+	return s.repo.Advance(ctx, state)
+}
+
+// Merge applies the read states reported by a device and returns every state of the owner,
+// so that the device can catch up with the others in one round trip.
+func (s *service) Merge(ctx context.Context, owner string, states []core.ReadState) ([]core.ReadState, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Service.Merge")
+	defer span.End()
+
+	if len(states) > maxMergeStates {
+		return nil, fmt.Errorf("%w: too many states (max %d)", ErrorInvalidState, maxMergeStates)
+	}
+
+	now := time.Now()
+	for _, state := range states {
+		if err := validate(state, now); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, state := range states {
+		state.Owner = owner
+		state.MDate = now
+		if _, err := s.repo.Advance(ctx, state); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	return s.repo.List(ctx, owner)
+}
+
+// Pending returns the number of users whose read states are not flushed yet
+func (s *service) Pending(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ReadState.Service.Pending")
+	defer span.End()
+
+	return s.repo.CountDirty(ctx)
+}
+
+// Flush writes the states changed since the last flush to the database
+func (s *service) Flush(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "ReadState.Service.Flush")
+	defer span.End()
+
+	flushed := 0
+	for {
+		owners, err := s.repo.PopDirty(ctx, flushBatchSize)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if len(owners) == 0 {
+			break
+		}
+
+		var states []core.ReadState
+		for _, owner := range owners {
+			ownerStates, err := s.repo.List(ctx, owner)
+			if err != nil {
+				span.RecordError(err)
+				s.repo.MarkDirty(ctx, owners...)
+				return err
+			}
+			states = append(states, ownerStates...)
+		}
+
+		if err := s.repo.Persist(ctx, states); err != nil {
+			span.RecordError(err)
+			s.repo.MarkDirty(ctx, owners...)
+			return err
+		}
+		flushed += len(owners)
+
+		if len(owners) < flushBatchSize {
+			break
+		}
+	}
+
+	if flushed > 0 {
+		logging.Debug(ctx, "read states flushed", slog.String("module", "readstate"), slog.Int("owners", flushed))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package readstate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestEncodeState(t *testing.T) {
+	state := core.ReadState{
+		Owner:  "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d",
+		Target: "tqn5v8bk9c0x7r1z8m0ekc7k6f4@example.com",
+		ItemID: "mjv6n0q3w2e8r5t1y7u9i4o2p0",
+		ReadAt: time.UnixMilli(1718445600123).UTC(),
+		MDate:  time.UnixMilli(1718445700456).UTC(),
+	}
+
+	decoded, err := decodeState(state.Owner, state.Target, encodeState(state))
+	assert.NoError(t, err)
+	assert.Equal(t, state, decoded)
+
+	_, err = decodeState(state.Owner, state.Target, "broken")
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+
+	assert.NoError(t, validate(core.ReadState{Target: "t", ReadAt: now}, now))
+	assert.NoError(t, validate(core.ReadState{Target: "t", ReadAt: now.Add(time.Minute)}, now))
+
+	for _, state := range []core.ReadState{
+		{Target: "", ReadAt: now},
+		{Target: "t"},
+		{Target: "t", ReadAt: now.Add(time.Hour)},
+	} {
+		err := validate(state, now)
+		assert.True(t, errors.Is(err, ErrorInvalidState))
+	}
+}
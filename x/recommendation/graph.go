@@ -0,0 +1,162 @@
+package recommendation
+
+import (
+	"math"
+	"sort"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	ReasonAck          = "ack"
+	ReasonSubscription = "subscription"
+
+	// weight of a timeline subscribed by an entity the user acks. the timelines of co-subscribers are
+	// weighted by the cosine similarity of the subscriptions, which is at most 1
+	ackWeight = 1.0
+)
+
+// Graph is what the recommendations are computed from
+type Graph struct {
+	// Subscribed are the timelines each user subscribes to, in any of their subscriptions
+	Subscribed map[string]map[string]bool
+	// Shared are the timelines each user subscribes to in their indexable subscriptions. only these are
+	// used to recommend timelines to the others
+	Shared map[string]map[string]bool
+	// Acks are the entities each entity validly acks
+	Acks map[string][]string
+	// OptOut are the users who opted out of the recommendations
+	OptOut map[string]bool
+	// Hidden are the timelines never recommended, the local ones which are not indexable
+	Hidden map[string]bool
+
+	subscribers map[string][]string
+}
+
+// NewGraph creates an empty graph
+func NewGraph() *Graph {
+	return &Graph{
+		Subscribed: map[string]map[string]bool{},
+		Shared:     map[string]map[string]bool{},
+		Acks:       map[string][]string{},
+		OptOut:     map[string]bool{},
+		Hidden:     map[string]bool{},
+	}
+}
+
+// AddSubscription records that the owner subscribes to the timeline
+func (g *Graph) AddSubscription(owner, timeline string, indexable bool) {
+	add(g.Subscribed, owner, timeline)
+	if indexable {
+		add(g.Shared, owner, timeline)
+	}
+	g.subscribers = nil
+}
+
+func add(sets map[string]map[string]bool, owner, timeline string) {
+	if sets[owner] == nil {
+		sets[owner] = map[string]bool{}
+	}
+	sets[owner][timeline] = true
+}
+
+// index builds the users sharing each timeline, to find the co-subscribers of a user
+func (g *Graph) index() {
+	if g.subscribers != nil {
+		return
+	}
+	g.subscribers = map[string][]string{}
+	for owner, timelines := range g.Shared {
+		for timeline := range timelines {
+			g.subscribers[timeline] = append(g.subscribers[timeline], owner)
+		}
+	}
+}
+
+type candidate struct {
+	ack, subscription float64
+	supporters        map[string]bool
+}
+
+// Recommend returns up to limit timelines for the owner, best first. a timeline is scored by the entities the
+// owner acks subscribing to it, and by the users sharing subscriptions with the owner weighted by their
+// similarity. timelines supported by fewer than minSupport users are left out, so that a recommendation
+// doesn't give away the subscriptions of a single user
+func (g *Graph) Recommend(owner string, limit, minSupport int) []core.Recommendation {
+	if g.OptOut[owner] {
+		return nil
+	}
+	g.index()
+
+	subscribed := g.Subscribed[owner]
+	candidates := map[string]*candidate{}
+	vote := func(timeline, supporter string, weight float64, fromAck bool) {
+		if subscribed[timeline] || g.Hidden[timeline] {
+			return
+		}
+		c, ok := candidates[timeline]
+		if !ok {
+			c = &candidate{supporters: map[string]bool{}}
+			candidates[timeline] = c
+		}
+		if fromAck {
+			c.ack += weight
+		} else {
+			c.subscription += weight
+		}
+		c.supporters[supporter] = true
+	}
+
+	for _, acked := range g.Acks[owner] {
+		if acked == owner || g.OptOut[acked] {
+			continue
+		}
+		for timeline := range g.Shared[acked] {
+			vote(timeline, acked, ackWeight, true)
+		}
+	}
+
+	overlaps := map[string]int{}
+	for timeline := range subscribed {
+		for _, other := range g.subscribers[timeline] {
+			if other != owner && !g.OptOut[other] {
+				overlaps[other]++
+			}
+		}
+	}
+	for other, overlap := range overlaps {
+		similarity := float64(overlap) / math.Sqrt(float64(len(subscribed))*float64(len(g.Shared[other])))
+		for timeline := range g.Shared[other] {
+			vote(timeline, other, similarity, false)
+		}
+	}
+
+	recommendations := make([]core.Recommendation, 0, len(candidates))
+	for timeline, c := range candidates {
+		if len(c.supporters) < minSupport {
+			continue
+		}
+		reason := ReasonSubscription
+		if c.ack >= c.subscription {
+			reason = ReasonAck
+		}
+		recommendations = append(recommendations, core.Recommendation{
+			Owner:      owner,
+			Timeline:   timeline,
+			Score:      c.ack + c.subscription,
+			Reason:     reason,
+			Supporters: len(c.supporters),
+		})
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Timeline < recommendations[j].Timeline
+	})
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations
+}
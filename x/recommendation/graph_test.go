@@ -0,0 +1,44 @@
+package recommendation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommend(t *testing.T) {
+	graph := NewGraph()
+
+	// alice acks bob and carol, and shares a timeline with dave and erin
+	graph.Acks["alice"] = []string{"bob", "carol"}
+	graph.AddSubscription("alice", "tHome@local", true)
+	graph.AddSubscription("bob", "tCooking@local", true)
+	graph.AddSubscription("carol", "tCooking@local", true)
+	graph.AddSubscription("carol", "tPrivate@local", true)
+	graph.AddSubscription("dave", "tHome@local", true)
+	graph.AddSubscription("dave", "tMusic@remote", true)
+	graph.AddSubscription("erin", "tHome@local", true)
+	graph.AddSubscription("erin", "tMusic@remote", true)
+	graph.AddSubscription("erin", "tSecret@remote", false)
+	// only bob supports it
+	graph.AddSubscription("bob", "tLonely@local", true)
+	graph.Hidden["tPrivate@local"] = true
+
+	recommendations := graph.Recommend("alice", 10, 2)
+	assert.Len(t, recommendations, 2)
+
+	assert.Equal(t, "tCooking@local", recommendations[0].Timeline)
+	assert.Equal(t, ReasonAck, recommendations[0].Reason)
+	assert.Equal(t, 2, recommendations[0].Supporters)
+	assert.InDelta(t, 2.0, recommendations[0].Score, 0.001)
+
+	assert.Equal(t, "tMusic@remote", recommendations[1].Timeline)
+	assert.Equal(t, ReasonSubscription, recommendations[1].Reason)
+	assert.InDelta(t, 2/1.4142, recommendations[1].Score, 0.001)
+
+	// opting out drops carol's and erin's subscriptions from the signals
+	graph.OptOut["carol"] = true
+	graph.OptOut["erin"] = true
+	assert.Empty(t, graph.Recommend("alice", 10, 2))
+	assert.Nil(t, graph.Recommend("carol", 10, 2))
+}
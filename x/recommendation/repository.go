@@ -0,0 +1,177 @@
+package recommendation
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for recommendation repository
+type Repository interface {
+	LoadGraph(ctx context.Context) (*Graph, error)
+	ListLocalUsers(ctx context.Context) ([]string, error)
+	Replace(ctx context.Context, owner string, recommendations []core.Recommendation) error
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	List(ctx context.Context, owner string) ([]core.Recommendation, error)
+	IsOptedOut(ctx context.Context, owner string) (bool, error)
+	OptOut(ctx context.Context, owner string) error
+	OptIn(ctx context.Context, owner string) error
+}
+
+type repository struct {
+	db     *gorm.DB
+	config core.Config
+}
+
+// NewRepository creates a new recommendation repository
+func NewRepository(db *gorm.DB, config core.Config) Repository {
+	return &repository{db, config}
+}
+
+// LoadGraph reads the subscriptions, the valid acks, the opt-outs and the hidden timelines of the domain
+func (r *repository) LoadGraph(ctx context.Context) (*Graph, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.LoadGraph")
+	defer span.End()
+
+	graph := NewGraph()
+	naming := r.db.NamingStrategy
+
+	var edges []struct {
+		Owner     string
+		Timeline  string
+		Indexable bool
+	}
+	err := r.db.WithContext(ctx).
+		Table(naming.TableName("SubscriptionItem") + " AS i").
+		Select("s.owner AS owner, i.id AS timeline, s.indexable AS indexable").
+		Joins("JOIN " + naming.TableName("Subscription") + " AS s ON s.id = i.subscription").
+		Scan(&edges).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, edge := range edges {
+		graph.AddSubscription(edge.Owner, edge.Timeline, edge.Indexable)
+	}
+
+	var acks []core.Ack
+	err = r.db.WithContext(ctx).Select("\"from\", \"to\"").Where("valid = true").Find(&acks).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, ack := range acks {
+		graph.Acks[ack.From] = append(graph.Acks[ack.From], ack.To)
+	}
+
+	var optOuts []string
+	err = r.db.WithContext(ctx).Model(&core.RecommendationOptOut{}).Pluck("owner", &optOuts).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, owner := range optOuts {
+		graph.OptOut[owner] = true
+	}
+
+	// subscription items point to local timelines by any of these forms
+	var hidden []string
+	err = r.db.WithContext(ctx).Model(&core.Timeline{}).Where("indexable = false").Pluck("id", &hidden).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, id := range hidden {
+		graph.Hidden["t"+id] = true
+		graph.Hidden["t"+id+"@"+r.config.FQDN] = true
+		graph.Hidden[id+"@"+r.config.FQDN] = true
+	}
+
+	return graph, nil
+}
+
+// ListLocalUsers returns the local entities which are not deleted
+func (r *repository) ListLocalUsers(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.ListLocalUsers")
+	defer span.End()
+
+	var users []string
+	err := r.db.WithContext(ctx).
+		Model(&core.Entity{}).
+		Where("domain = ? AND tombstone_document IS NULL", r.config.FQDN).
+		Order("id").
+		Pluck("id", &users).Error
+	return users, err
+}
+
+// Replace replaces the recommendations of the owner
+func (r *repository) Replace(ctx context.Context, owner string, recommendations []core.Recommendation) error {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.Replace")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("owner = ?", owner).Delete(&core.Recommendation{}).Error
+		if err != nil {
+			return err
+		}
+		if len(recommendations) == 0 {
+			return nil
+		}
+		return tx.Create(&recommendations).Error
+	})
+}
+
+// DeleteBefore deletes the recommendations computed before the time, left over from users who are gone
+func (r *repository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.DeleteBefore")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("c_date < ?", before).Delete(&core.Recommendation{})
+	return result.RowsAffected, result.Error
+}
+
+// List returns the recommendations of the owner, best first
+func (r *repository) List(ctx context.Context, owner string) ([]core.Recommendation, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.List")
+	defer span.End()
+
+	var recommendations []core.Recommendation
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Order("score DESC, timeline").Find(&recommendations).Error
+	return recommendations, err
+}
+
+// IsOptedOut returns whether the owner opted out of the recommendations
+func (r *repository) IsOptedOut(ctx context.Context, owner string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.IsOptedOut")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.RecommendationOptOut{}).Where("owner = ?", owner).Count(&count).Error
+	return count > 0, err
+}
+
+// OptOut records the opt-out of the owner and deletes their recommendations
+func (r *repository) OptOut(ctx context.Context, owner string) error {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.OptOut")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&core.RecommendationOptOut{Owner: owner}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("owner = ?", owner).Delete(&core.Recommendation{}).Error
+	})
+}
+
+// OptIn removes the opt-out of the owner
+func (r *repository) OptIn(ctx context.Context, owner string) error {
+	ctx, span := tracer.Start(ctx, "Recommendation.Repository.OptIn")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Where("owner = ?", owner).Delete(&core.RecommendationOptOut{}).Error
+}
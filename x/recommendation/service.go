@@ -0,0 +1,131 @@
+// Package recommendation suggests timelines to local users from their ack graph and the subscriptions they share
+// with the others. the recommendations are computed offline by a scheduled job
+package recommendation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("recommendation")
+
+const (
+	// timelines recommended to a user
+	recommendationLimit = 20
+	// users a timeline must be supported by to be recommended
+	minSupport = 2
+)
+
+// Service is the interface for recommendation service
+type Service interface {
+	Run(ctx context.Context) error
+	List(ctx context.Context, owner string) ([]core.Recommendation, error)
+	IsOptedOut(ctx context.Context, owner string) (bool, error)
+	OptOut(ctx context.Context, owner string) error
+	OptIn(ctx context.Context, owner string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new recommendation service
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+// Run recomputes the recommendations of every local user, and drops those of the users who are gone
+func (s *service) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Recommendation.Service.Run")
+	defer span.End()
+
+	started := time.Now()
+
+	graph, err := s.repo.LoadGraph(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	users, err := s.repo.ListLocalUsers(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	computed, recommended := 0, 0
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if graph.OptOut[user] {
+			continue
+		}
+
+		recommendations := graph.Recommend(user, recommendationLimit, minSupport)
+		now := time.Now()
+		for i := range recommendations {
+			recommendations[i].CDate = now
+		}
+
+		err := s.repo.Replace(ctx, user, recommendations)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		computed++
+		recommended += len(recommendations)
+	}
+
+	dropped, err := s.repo.DeleteBefore(ctx, started)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	logging.Info(ctx, "recommendations computed",
+		slog.String("module", "recommendation"),
+		slog.Int("users", computed),
+		slog.Int("recommendations", recommended),
+		slog.Int64("dropped", dropped),
+	)
+	return nil
+}
+
+// List returns the recommendations of the owner computed by the last run
+func (s *service) List(ctx context.Context, owner string) ([]core.Recommendation, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Service.List")
+	defer span.End()
+
+	return s.repo.List(ctx, owner)
+}
+
+// IsOptedOut returns whether the owner opted out of the recommendations
+func (s *service) IsOptedOut(ctx context.Context, owner string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "Recommendation.Service.IsOptedOut")
+	defer span.End()
+
+	return s.repo.IsOptedOut(ctx, owner)
+}
+
+// OptOut stops recommending timelines to the owner, and using their acks and subscriptions for the others.
+// their recommendations are deleted at once, and they leave those of the others at the next run
+func (s *service) OptOut(ctx context.Context, owner string) error {
+	ctx, span := tracer.Start(ctx, "Recommendation.Service.OptOut")
+	defer span.End()
+
+	return s.repo.OptOut(ctx, owner)
+}
+
+// OptIn undoes the opt-out of the owner. their recommendations are computed at the next run
+func (s *service) OptIn(ctx context.Context, owner string) error {
+	ctx, span := tracer.Start(ctx, "Recommendation.Service.OptIn")
+	defer span.End()
+
+	return s.repo.OptIn(ctx, owner)
+}
@@ -0,0 +1,88 @@
+package relay
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Receive(c echo.Context) error
+	ListPeers(c echo.Context) error
+	AddPeer(c echo.Context) error
+	RemovePeer(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// Receive accepts a relay document from a peer or an upstream relay
+func (h handler) Receive(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Relay.Handler.Receive")
+	defer span.End()
+
+	var request core.Commit
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	accepted, err := h.service.Receive(ctx, request.Document, request.Signature)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": echo.Map{"accepted": accepted}})
+}
+
+// ListPeers returns the domains allowed to use this domain as a relay
+func (h handler) ListPeers(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Relay.Handler.ListPeers")
+	defer span.End()
+
+	peers, err := h.service.ListPeers(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": peers})
+}
+
+// AddPeer allows a domain to use this domain as a relay
+func (h handler) AddPeer(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Relay.Handler.AddPeer")
+	defer span.End()
+
+	peer, err := h.service.AddPeer(ctx, c.Param("domain"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": peer})
+}
+
+// RemovePeer stops relaying the events of a domain and to it
+func (h handler) RemovePeer(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Relay.Handler.RemovePeer")
+	defer span.End()
+
+	err := h.service.RemovePeer(ctx, c.Param("domain"))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
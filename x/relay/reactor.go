@@ -0,0 +1,95 @@
+package relay
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// the firehose is looked up again after this long when it could not be
+const firehoseRetryInterval = 30 * time.Second
+
+// Reactor forwards the events of the firehose of the domain to its relays
+type Reactor interface {
+	Start(ctx context.Context)
+}
+
+type reactor struct {
+	service  Service
+	timeline core.TimelineService
+	config   core.Config
+	relay    Config
+}
+
+// NewReactor creates a new reactor
+func NewReactor(service Service, timeline core.TimelineService, config core.Config, relay Config) Reactor {
+	if relay.BatchSize <= 0 {
+		relay.BatchSize = defaultBatchSize
+	}
+	if relay.FlushInterval <= 0 {
+		relay.FlushInterval = defaultFlushInterval
+	}
+	return &reactor{service: service, timeline: timeline, config: config, relay: relay}
+}
+
+// Start forwards the firehose while the domain has somewhere to send it.
+// every instance forwards the events it sees, the receivers drop the duplicates
+func (r *reactor) Start(ctx context.Context) {
+	if !r.relay.Enabled && len(r.relay.Upstreams) == 0 {
+		return
+	}
+	go r.watch(ctx)
+}
+
+func (r *reactor) watch(ctx context.Context) {
+	var firehose core.Timeline
+	for {
+		var err error
+		firehose, err = r.timeline.EnsureFirehose(ctx)
+		if err == nil {
+			break
+		}
+		slog.Error("failed to get the firehose to relay", slog.String("error", err.Error()), slog.String("module", "relay"))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(firehoseRetryInterval):
+		}
+	}
+
+	request := make(chan []string, 1)
+	events := make(chan core.Event)
+	request <- []string{firehose.ID + "@" + r.config.FQDN}
+	go r.timeline.Realtime(ctx, request, events)
+
+	ticker := time.NewTicker(r.relay.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]core.Event, 0, r.relay.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := r.service.Forward(ctx, batch)
+		if err != nil {
+			slog.Error("failed to forward events to relays", slog.String("error", err.Error()), slog.String("module", "relay"))
+		}
+		batch = make([]core.Event, 0, r.relay.BatchSize)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			flush()
+		case event := <-events:
+			batch = append(batch, event)
+			if len(batch) >= r.relay.BatchSize {
+				flush()
+			}
+		}
+	}
+}
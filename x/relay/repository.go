@@ -0,0 +1,113 @@
+package relay
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+// events seen are remembered this long, longer than a document stays fresh
+const seenTTL = 2 * documentMaxAge
+
+// Repository is the interface for relay repository
+type Repository interface {
+	ListPeers(ctx context.Context) ([]core.RelayPeer, error)
+	IsPeer(ctx context.Context, domain string) (bool, error)
+	AddPeer(ctx context.Context, domain string) (core.RelayPeer, error)
+	RemovePeer(ctx context.Context, domain string) error
+	MarkSeen(ctx context.Context, hash string) (bool, error)
+}
+
+type repository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewRepository creates a new relay repository
+func NewRepository(db *gorm.DB, rdb *redis.Client) Repository {
+	return &repository{db, rdb}
+}
+
+func (r *repository) ListPeers(ctx context.Context) ([]core.RelayPeer, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Repository.ListPeers")
+	defer span.End()
+
+	var peers []core.RelayPeer
+	err := r.db.WithContext(ctx).Order("domain ASC").Find(&peers).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return peers, err
+}
+
+func (r *repository) IsPeer(ctx context.Context, domain string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Repository.IsPeer")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.RelayPeer{}).Where("domain = ?", domain).Count(&count).Error
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AddPeer allows the domain. adding a peer already allowed returns it as is
+func (r *repository) AddPeer(ctx context.Context, domain string) (core.RelayPeer, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Repository.AddPeer")
+	defer span.End()
+
+	peer := core.RelayPeer{Domain: domain}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&peer).Error
+	if err != nil {
+		span.RecordError(err)
+		return core.RelayPeer{}, err
+	}
+
+	err = r.db.WithContext(ctx).Where("domain = ?", domain).First(&peer).Error
+	if err != nil {
+		span.RecordError(err)
+		return core.RelayPeer{}, err
+	}
+	return peer, nil
+}
+
+func (r *repository) RemovePeer(ctx context.Context, domain string) error {
+	ctx, span := tracer.Start(ctx, "Relay.Repository.RemovePeer")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("domain = ?", domain).Delete(&core.RelayPeer{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
+
+// MarkSeen records the event and reports whether it was not seen before.
+// events are reported unseen when the namespace is over its budget, duplicates are harmless to the clients
+func (r *repository) MarkSeen(ctx context.Context, hash string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Repository.MarkSeen")
+	defer span.End()
+
+	if !keyspace.Relay.Allow(r.rdb) {
+		return true, nil
+	}
+
+	ok, err := r.rdb.SetNX(ctx, keyspace.Relay.Key("seen", hash), "1", seenTTL).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		return false, err
+	}
+	return ok, nil
+}
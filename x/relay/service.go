@@ -0,0 +1,409 @@
+// Package relay lets domains exchange the events of their firehose through a relay.
+// a domain configured as a relay accepts the events of its allowlisted peers and rebroadcasts them to the others,
+// so that a small domain sees the content of the whole group without bridging to every one of its members
+package relay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/client"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/key"
+)
+
+var tracer = otel.Tracer("relay")
+
+const (
+	// relay documents signed longer ago, or this far in the future, are refused as replays
+	documentMaxAge = 5 * time.Minute
+	// a relay document sent to a domain gives up after this long
+	deliveryTimeout = 10 * time.Second
+
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+type Config struct {
+	// Enabled makes the domain a relay for its allowlisted peers
+	Enabled bool `yaml:"enabled"`
+	// Upstreams are the relays the domain sends the events of its firehose to, and accepts events from
+	Upstreams []string `yaml:"upstreams"`
+	// events are sent in documents of at most BatchSize events, at least every FlushInterval
+	BatchSize     int           `yaml:"batchSize"`
+	FlushInterval time.Duration `yaml:"flushInterval"`
+}
+
+// Service is the interface for relay service
+type Service interface {
+	Receive(ctx context.Context, document, signature string) (int, error)
+	Forward(ctx context.Context, events []core.Event) error
+	ListPeers(ctx context.Context) ([]core.RelayPeer, error)
+	AddPeer(ctx context.Context, domain string) (core.RelayPeer, error)
+	RemovePeer(ctx context.Context, domain string) error
+}
+
+type service struct {
+	repo     Repository
+	domain   core.DomainService
+	entity   core.EntityService
+	key      core.KeyService
+	timeline core.TimelineService
+	client   client.Client
+	config   core.Config
+	relay    Config
+}
+
+// NewService creates a new relay service
+func NewService(repo Repository, domain core.DomainService, entity core.EntityService, key core.KeyService, timeline core.TimelineService, client client.Client, config core.Config, relay Config) Service {
+	return &service{
+		repo:     repo,
+		domain:   domain,
+		entity:   entity,
+		key:      key,
+		timeline: timeline,
+		client:   client,
+		config:   config,
+		relay:    relay,
+	}
+}
+
+// Receive accepts the events of a relay document sent by a peer or an upstream relay, publishes them
+// to the local subscribers and, on a relay, rebroadcasts them to the peers which have not seen them.
+// it returns the number of events accepted
+func (s *service) Receive(ctx context.Context, document, signature string) (int, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Service.Receive")
+	defer span.End()
+
+	var doc core.RelayDocument
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		span.RecordError(err)
+		return 0, core.NewErrorInvalidArgument("invalid relay document")
+	}
+
+	if doc.Type != "relay" || doc.Domain == "" || doc.Domain == s.config.FQDN {
+		return 0, core.NewErrorInvalidArgument("invalid relay document")
+	}
+	if age := time.Since(doc.SignedAt); age > documentMaxAge || age < -documentMaxAge {
+		return 0, core.NewErrorInvalidArgument("relay document is not fresh")
+	}
+	if len(doc.Hops) == 0 && doc.Origin != doc.Domain {
+		return 0, core.NewErrorInvalidArgument("relay document without hops must come from its origin")
+	}
+
+	span.SetAttributes(attribute.String("domain", doc.Domain), attribute.String("origin", doc.Origin))
+
+	fromPeer, err := s.isPeer(ctx, doc.Domain)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	if !fromPeer && !slices.Contains(s.relay.Upstreams, doc.Domain) {
+		return 0, core.NewErrorPermissionDenied()
+	}
+
+	err = s.verifyDomain(ctx, doc, document, signature)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	// the events came back to a domain they went through
+	if doc.Origin == s.config.FQDN || slices.Contains(doc.Hops, s.config.FQDN) {
+		span.AddEvent("loop")
+		return 0, nil
+	}
+
+	accepted := make([]core.Event, 0, len(doc.Events))
+	for _, event := range doc.Events {
+		if !strings.HasSuffix(event.Timeline, "@"+doc.Origin) {
+			continue
+		}
+		err := s.verifyEvent(ctx, doc.Origin, event)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+
+		first, err := s.repo.MarkSeen(ctx, eventHash(event))
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		if !first {
+			continue
+		}
+
+		err = s.timeline.PublishEvent(ctx, event)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		accepted = append(accepted, event)
+	}
+
+	span.SetAttributes(attribute.Int("events", len(doc.Events)), attribute.Int("accepted", len(accepted)))
+
+	if s.relay.Enabled && len(accepted) > 0 {
+		go s.rebroadcast(context.WithoutCancel(ctx), doc, accepted)
+	}
+
+	return len(accepted), nil
+}
+
+// Forward sends the events published on this domain to its upstream relays, and to its peers when it is a relay
+func (s *service) Forward(ctx context.Context, events []core.Event) error {
+	ctx, span := tracer.Start(ctx, "Relay.Service.Forward")
+	defer span.End()
+
+	targets := slices.Clone(s.relay.Upstreams)
+	if s.relay.Enabled {
+		peers, err := s.repo.ListPeers(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		for _, peer := range peers {
+			if !slices.Contains(targets, peer.Domain) {
+				targets = append(targets, peer.Domain)
+			}
+		}
+	}
+
+	return s.send(ctx, s.config.FQDN, nil, events, targets)
+}
+
+func (s *service) ListPeers(ctx context.Context) ([]core.RelayPeer, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Service.ListPeers")
+	defer span.End()
+
+	return s.repo.ListPeers(ctx)
+}
+
+func (s *service) AddPeer(ctx context.Context, domain string) (core.RelayPeer, error) {
+	ctx, span := tracer.Start(ctx, "Relay.Service.AddPeer")
+	defer span.End()
+
+	domain = strings.ToLower(domain)
+	if domain == "" || domain == s.config.FQDN {
+		return core.RelayPeer{}, core.NewErrorInvalidArgument("invalid domain")
+	}
+
+	return s.repo.AddPeer(ctx, domain)
+}
+
+func (s *service) RemovePeer(ctx context.Context, domain string) error {
+	ctx, span := tracer.Start(ctx, "Relay.Service.RemovePeer")
+	defer span.End()
+
+	return s.repo.RemovePeer(ctx, strings.ToLower(domain))
+}
+
+// isPeer reports whether the domain is allowlisted. domains which are not relays have no peers
+func (s *service) isPeer(ctx context.Context, domain string) (bool, error) {
+	if !s.relay.Enabled {
+		return false, nil
+	}
+	return s.repo.IsPeer(ctx, domain)
+}
+
+// verifyDomain checks that the document is signed by the sending domain
+func (s *service) verifyDomain(ctx context.Context, doc core.RelayDocument, document, signature string) error {
+	ctx, span := tracer.Start(ctx, "Relay.Service.verifyDomain")
+	defer span.End()
+
+	domain, err := s.domain.GetByFQDN(ctx, doc.Domain)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	tags := core.ParseTags(domain.Tag)
+	if tags.Has("_block") {
+		return core.NewErrorPermissionDenied()
+	}
+	// an unknown signer may be the new CSID of a domain which rotated its key
+	now := time.Now()
+	if domain.CSID == "" || !domain.AcceptsCSID(doc.Signer, now) {
+		domain, err = s.domain.ForceFetch(ctx, doc.Domain)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	if !domain.AcceptsCSID(doc.Signer, now) {
+		return core.NewErrorInvalidSignature("relay document is not signed by its domain")
+	}
+
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return core.NewErrorInvalidSignature("invalid signature encoding")
+	}
+
+	err = core.VerifySignature([]byte(document), signatureBytes, doc.Signer)
+	if err != nil {
+		return core.NewErrorInvalidSignature(err.Error())
+	}
+	return nil
+}
+
+// verifyEvent checks the signature of the document the event carries. events without one are not relayed.
+// a document signed with a subkey is only accepted when the key resolves to its signer, like commits are
+func (s *service) verifyEvent(ctx context.Context, origin string, event core.Event) error {
+	ctx, span := tracer.Start(ctx, "Relay.Service.verifyEvent")
+	defer span.End()
+
+	if event.Document == "" || event.Signature == "" {
+		return fmt.Errorf("event is not signed")
+	}
+
+	var base core.DocumentBase[any]
+	err := json.Unmarshal([]byte(event.Document), &base)
+	if err != nil {
+		return err
+	}
+	if base.Signer == "" {
+		return fmt.Errorf("event document has no signer")
+	}
+
+	signer := base.Signer
+	if base.KeyID != "" {
+		entity, err := s.entity.GetWithHint(ctx, base.Signer, origin)
+		if err != nil {
+			span.RecordError(err)
+			return errors.Wrap(err, "failed to resolve signer")
+		}
+
+		var ccid string
+		if entity.Domain == s.config.FQDN {
+			ccid, err = s.key.ResolveSubkey(ctx, base.KeyID)
+		} else {
+			var keys []core.Key
+			keys, err = s.key.GetRemoteKeyResolution(ctx, entity.Domain, base.KeyID)
+			if err == nil {
+				ccid, err = key.ValidateKeyResolution(keys)
+			}
+		}
+		if err != nil {
+			span.RecordError(err)
+			return errors.Wrap(err, "failed to resolve subkey")
+		}
+		if ccid != base.Signer {
+			return fmt.Errorf("subkey %s does not belong to %s", base.KeyID, base.Signer)
+		}
+		signer = base.KeyID
+	}
+
+	signatureBytes, err := hex.DecodeString(event.Signature)
+	if err != nil {
+		return err
+	}
+	return core.VerifySignature([]byte(event.Document), signatureBytes, signer)
+}
+
+// eventHash identifies an event by its document, as the signature can be altered without invalidating it.
+// the same document posted to two timelines makes two events
+func eventHash(event core.Event) string {
+	hash := sha256.Sum256([]byte(event.Timeline + "." + event.Document))
+	return hex.EncodeToString(hash[:])
+}
+
+// rebroadcast sends the events received to the peers and upstreams which have not seen them
+func (s *service) rebroadcast(ctx context.Context, received core.RelayDocument, events []core.Event) {
+	ctx, span := tracer.Start(ctx, "Relay.Service.Rebroadcast")
+	defer span.End()
+
+	peers, err := s.repo.ListPeers(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+
+	candidates := slices.Clone(s.relay.Upstreams)
+	for _, peer := range peers {
+		candidates = append(candidates, peer.Domain)
+	}
+
+	var targets []string
+	for _, domain := range candidates {
+		if domain == received.Domain || domain == received.Origin || slices.Contains(received.Hops, domain) || slices.Contains(targets, domain) {
+			continue
+		}
+		targets = append(targets, domain)
+	}
+
+	hops := append(slices.Clone(received.Hops), s.config.FQDN)
+	err = s.send(ctx, received.Origin, hops, events, targets)
+	if err != nil {
+		span.RecordError(err)
+	}
+}
+
+// send signs a relay document of the events and posts it to the targets.
+// domains which can't be reached are only logged, the events are not queued for them
+func (s *service) send(ctx context.Context, origin string, hops []string, events []core.Event, targets []string) error {
+	ctx, span := tracer.Start(ctx, "Relay.Service.Send")
+	defer span.End()
+
+	if len(events) == 0 || len(targets) == 0 {
+		return nil
+	}
+
+	document, err := json.Marshal(core.RelayDocument{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:   s.config.CSID,
+			Type:     "relay",
+			SignedAt: time.Now(),
+		},
+		Domain: s.config.FQDN,
+		Origin: origin,
+		Hops:   hops,
+		Events: events,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	signatureBytes, err := core.SignBytes(document, s.config.PrivateKey)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	body, err := json.Marshal(core.Commit{
+		Document:  string(document),
+		Signature: hex.EncodeToString(signatureBytes),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	failed := 0
+	for _, target := range targets {
+		sendCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		err := s.client.RelayEvents(sendCtx, target, string(body), nil)
+		cancel()
+		if err != nil {
+			failed++
+			logging.Warn(ctx, "failed to relay events", slog.String("module", "relay"), slog.String("domain", target), slog.String("error", err.Error()))
+		}
+	}
+
+	span.SetAttributes(attribute.Int("events", len(events)), attribute.Int("targets", len(targets)), attribute.Int("failed", failed))
+	return nil
+}
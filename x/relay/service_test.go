@@ -0,0 +1,196 @@
+package relay
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/client/mock"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+	"github.com/totegamma/concurrent/internal/fixtures"
+)
+
+const (
+	peerFQDN = "peer.example.com"
+	peerPriv = "863183823d2c2a19101140eef0f905c872de1dae6470c9129a1547f3482cb612"
+
+	relayFQDN = "relay.example.com"
+	relayPriv = "1ca30329e8d35217b2328bacfc21c5e3d762713edab0252eead1f4c1ac0b4d81"
+)
+
+type memoryRepository struct {
+	peers []string
+	seen  map[string]bool
+}
+
+func (r *memoryRepository) ListPeers(ctx context.Context) ([]core.RelayPeer, error) {
+	peers := make([]core.RelayPeer, len(r.peers))
+	for i, domain := range r.peers {
+		peers[i] = core.RelayPeer{Domain: domain}
+	}
+	return peers, nil
+}
+
+func (r *memoryRepository) IsPeer(ctx context.Context, domain string) (bool, error) {
+	for _, peer := range r.peers {
+		if peer == domain {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRepository) AddPeer(ctx context.Context, domain string) (core.RelayPeer, error) {
+	r.peers = append(r.peers, domain)
+	return core.RelayPeer{Domain: domain}, nil
+}
+
+func (r *memoryRepository) RemovePeer(ctx context.Context, domain string) error {
+	return nil
+}
+
+func (r *memoryRepository) MarkSeen(ctx context.Context, hash string) (bool, error) {
+	if r.seen[hash] {
+		return false, nil
+	}
+	r.seen[hash] = true
+	return true, nil
+}
+
+func signedEvent(t *testing.T, timeline string) core.Event {
+	document, signature := fixtures.Sign(t, fixtures.User1, fixtures.Message(fixtures.User1, nil, timeline))
+	return core.Event{Timeline: timeline, Document: document, Signature: signature}
+}
+
+func relayDocument(t *testing.T, priv string, doc core.RelayDocument) (string, string) {
+	csid, err := core.PrivKeyToAddr(priv, "ccs")
+	assert.NoError(t, err)
+	doc.Signer = csid
+	doc.Type = "relay"
+	doc.SignedAt = time.Now()
+	document, _ := json.Marshal(doc)
+	signature, err := core.SignBytes(document, priv)
+	assert.NoError(t, err)
+	return string(document), hex.EncodeToString(signature)
+}
+
+func setup(t *testing.T, peers ...string) (*mock_core.MockTimelineService, *mock_client.MockClient, Service) {
+	ctrl := gomock.NewController(t)
+
+	csid, _ := core.PrivKeyToAddr(peerPriv, "ccs")
+	mockDomain := mock_core.NewMockDomainService(ctrl)
+	mockDomain.EXPECT().GetByFQDN(gomock.Any(), peerFQDN).Return(core.Domain{ID: peerFQDN, CSID: csid}, nil).AnyTimes()
+	// an unknown signer makes the domain fetched again, in case it rotated its key
+	mockDomain.EXPECT().ForceFetch(gomock.Any(), peerFQDN).Return(core.Domain{ID: peerFQDN, CSID: csid}, nil).AnyTimes()
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().GetWithHint(gomock.Any(), fixtures.User1.CCID, gomock.Any()).Return(core.Entity{ID: fixtures.User1.CCID, Domain: peerFQDN}, nil).AnyTimes()
+	// no subkey is enacted by the test entities
+	mockKey := mock_core.NewMockKeyService(ctrl)
+	mockKey.EXPECT().GetRemoteKeyResolution(gomock.Any(), peerFQDN, gomock.Any()).Return(nil, core.NewErrorNotFound()).AnyTimes()
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockClient := mock_client.NewMockClient(ctrl)
+
+	repo := &memoryRepository{peers: peers, seen: map[string]bool{}}
+	service := NewService(repo, mockDomain, mockEntity, mockKey, mockTimeline, mockClient, core.Config{FQDN: relayFQDN, PrivateKey: relayPriv}, Config{Enabled: true})
+	return mockTimeline, mockClient, service
+}
+
+func TestReceive(t *testing.T) {
+	mockTimeline, mockClient, service := setup(t, peerFQDN, "other.example.com")
+
+	event := signedEvent(t, "t1@"+peerFQDN)
+	forged := signedEvent(t, "t2@"+peerFQDN)
+	forged.Signature = signedEvent(t, "t3@"+peerFQDN).Signature
+	local := signedEvent(t, "t4@"+relayFQDN)
+
+	// signed with a key which is not a subkey of the signer
+	attacker := fixtures.RandomKeypair(t)
+	message := fixtures.Message(fixtures.User1, nil, "t5@"+peerFQDN)
+	message.KeyID = attacker.CKID
+	subkeyDocument, subkeySignature := fixtures.Sign(t, attacker, message)
+	subkey := core.Event{Timeline: "t5@" + peerFQDN, Document: subkeyDocument, Signature: subkeySignature}
+
+	document, signature := relayDocument(t, peerPriv, core.RelayDocument{
+		Domain: peerFQDN,
+		Origin: peerFQDN,
+		Events: []core.Event{event, forged, local, subkey},
+	})
+
+	mockTimeline.EXPECT().PublishEvent(gomock.Any(), event).Return(nil)
+	rebroadcast := make(chan core.RelayDocument)
+	mockClient.EXPECT().RelayEvents(gomock.Any(), "other.example.com", gomock.Any(), gomock.Nil()).DoAndReturn(func(ctx context.Context, domain, body string, opts any) error {
+		var commit core.Commit
+		_ = json.Unmarshal([]byte(body), &commit)
+		var doc core.RelayDocument
+		_ = json.Unmarshal([]byte(commit.Document), &doc)
+		rebroadcast <- doc
+		return nil
+	})
+
+	accepted, err := service.Receive(context.Background(), document, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, accepted)
+
+	select {
+	case doc := <-rebroadcast:
+		assert.Equal(t, relayFQDN, doc.Domain)
+		assert.Equal(t, peerFQDN, doc.Origin)
+		assert.Equal(t, []string{relayFQDN}, doc.Hops)
+		assert.Len(t, doc.Events, 1)
+	case <-time.After(time.Second):
+		t.Fatal("events were not rebroadcast")
+	}
+
+	// the same events are not published twice
+	accepted, err = service.Receive(context.Background(), document, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, accepted)
+
+	// nor when their signature is encoded differently
+	event.Signature = strings.ToUpper(event.Signature)
+	document, signature = relayDocument(t, peerPriv, core.RelayDocument{
+		Domain: peerFQDN,
+		Origin: peerFQDN,
+		Events: []core.Event{event},
+	})
+	accepted, err = service.Receive(context.Background(), document, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, accepted)
+}
+
+func TestReceiveRefused(t *testing.T) {
+	_, _, service := setup(t)
+
+	document, signature := relayDocument(t, peerPriv, core.RelayDocument{Domain: peerFQDN, Origin: peerFQDN})
+	_, err := service.Receive(context.Background(), document, signature)
+	assert.ErrorIs(t, err, core.NewErrorPermissionDenied())
+
+	_, _, service = setup(t, peerFQDN)
+
+	// signed by another key than the one of the domain
+	document, signature = relayDocument(t, fixtures.User1.PrivateKey, core.RelayDocument{Domain: peerFQDN, Origin: peerFQDN})
+	_, err = service.Receive(context.Background(), document, signature)
+	assert.ErrorIs(t, err, core.NewErrorInvalidSignature(""))
+}
+
+func TestReceiveLoop(t *testing.T) {
+	_, _, service := setup(t, peerFQDN)
+
+	// events which went through this relay already are dropped
+	document, signature := relayDocument(t, peerPriv, core.RelayDocument{
+		Domain: peerFQDN,
+		Origin: "origin.example.com",
+		Hops:   []string{relayFQDN},
+		Events: []core.Event{signedEvent(t, "t1@origin.example.com")},
+	})
+	accepted, err := service.Receive(context.Background(), document, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, accepted)
+}
@@ -0,0 +1,73 @@
+package reverify
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Request(c echo.Context) error
+	ListInvalid(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+type requestBody struct {
+	Remove bool `json:"remove"`
+}
+
+// Request starts the re-validation of the documents signed with a revoked key
+func (h handler) Request(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Reverify.Handler.Request")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	var body requestBody
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	job, err := h.service.Request(ctx, requester, c.Param("id"), body.Remove)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "key not found"})
+		}
+		if errors.Is(err, ErrorNotRevoked) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"status": "ok", "content": job})
+}
+
+// ListInvalid returns the documents found invalid for a key
+func (h handler) ListInvalid(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Reverify.Handler.ListInvalid")
+	defer span.End()
+
+	docs, err := h.service.ListInvalid(ctx, c.Param("id"))
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": docs})
+}
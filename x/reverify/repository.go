@@ -0,0 +1,154 @@
+package reverify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	// commit logs checked at once
+	batchSize = 500
+	// same limit as the key resolution
+	maxKeyDepth = 8
+)
+
+// Repository is the interface for reverify repository
+type Repository interface {
+	GetKey(ctx context.Context, keyID string) (core.Key, error)
+	ListDescendants(ctx context.Context, keyID string) ([]string, error)
+	EachSignedBy(ctx context.Context, keyIDs []string, since time.Time, fn func([]core.CommitLog) error) error
+	RemoveResource(ctx context.Context, typ, documentID string) (string, bool, error)
+	Flag(ctx context.Context, doc core.InvalidDocument) error
+	ListFlagged(ctx context.Context, keyIDs []string) ([]core.InvalidDocument, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new reverify repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// GetKey returns a key
+func (r *repository) GetKey(ctx context.Context, keyID string) (core.Key, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Repository.GetKey")
+	defer span.End()
+
+	var key core.Key
+	err := r.db.WithContext(ctx).Where("id = ?", keyID).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return core.Key{}, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return core.Key{}, err
+	}
+	return key, nil
+}
+
+// ListDescendants returns the keys enacted under the key, directly or through other subkeys
+func (r *repository) ListDescendants(ctx context.Context, keyID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Repository.ListDescendants")
+	defer span.End()
+
+	descendants := make([]string, 0)
+	parents := []string{keyID}
+	for depth := 0; depth < maxKeyDepth && len(parents) > 0; depth++ {
+		var children []string
+		err := r.db.WithContext(ctx).Model(&core.Key{}).Where("parent IN ?", parents).Pluck("id", &children).Error
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		descendants = append(descendants, children...)
+		parents = children
+	}
+	return descendants, nil
+}
+
+// EachSignedBy calls fn with the commit logs signed by the keys at or after since, in batches
+func (r *repository) EachSignedBy(ctx context.Context, keyIDs []string, since time.Time, fn func([]core.CommitLog) error) error {
+	ctx, span := tracer.Start(ctx, "Reverify.Repository.EachSignedBy")
+	defer span.End()
+
+	var last uint
+	for {
+		var logs []core.CommitLog
+		err := r.db.WithContext(ctx).
+			Where("document->>'keyID' IN ? AND signed_at >= ? AND id > ?", keyIDs, since, last).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&logs).Error
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+		if len(logs) < batchSize {
+			return nil
+		}
+		last = logs[len(logs)-1].ID
+	}
+}
+
+// RemoveResource deletes the message or the association made by a document and returns its resource id.
+// other kinds of documents are left to the owner, and reported as not removed
+func (r *repository) RemoveResource(ctx context.Context, typ, documentID string) (string, bool, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Repository.RemoveResource")
+	defer span.End()
+
+	var result *gorm.DB
+	var resourceID string
+	switch typ {
+	case "message":
+		result = r.db.WithContext(ctx).Where("id = ?", documentID).Delete(&core.Message{})
+		resourceID = "m" + documentID
+	case "association":
+		result = r.db.WithContext(ctx).Where("id = ?", documentID).Delete(&core.Association{})
+		resourceID = "a" + documentID
+	default:
+		return "", false, nil
+	}
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return "", false, result.Error
+	}
+	return resourceID, result.RowsAffected > 0, nil
+}
+
+// Flag records an invalid document. flagging it again updates the reason, and a removal is never forgotten
+func (r *repository) Flag(ctx context.Context, doc core.InvalidDocument) error {
+	ctx, span := tracer.Start(ctx, "Reverify.Repository.Flag")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "commit_log_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"reason":  gorm.Expr("excluded.reason"),
+			"removed": gorm.Expr("invalid_documents.removed OR excluded.removed"),
+		}),
+	}).Create(&doc).Error
+}
+
+// ListFlagged returns the invalid documents signed by the keys
+func (r *repository) ListFlagged(ctx context.Context, keyIDs []string) ([]core.InvalidDocument, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Repository.ListFlagged")
+	defer span.End()
+
+	var docs []core.InvalidDocument
+	err := r.db.WithContext(ctx).Where("key_id IN ?", keyIDs).Order("signed_at ASC").Find(&docs).Error
+	return docs, err
+}
@@ -0,0 +1,232 @@
+// Package reverify checks again the documents signed with a key after the key was revoked.
+// a revocation may be signed earlier than it reaches the server, so documents accepted meanwhile turn out invalid.
+package reverify
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("reverify")
+
+// JobType is the type of the job re-validating documents
+const JobType = "reverify"
+
+// ErrorNotRevoked is returned when the key to re-validate is still valid
+var ErrorNotRevoked = errors.New("key is not revoked")
+
+// Payload is the payload of a reverify job
+type Payload struct {
+	KeyID  string `json:"keyID"`
+	Remove bool   `json:"remove"`
+}
+
+// Result is the result of a reverify job
+type Result struct {
+	Checked int `json:"checked"`
+	Invalid int `json:"invalid"`
+	Removed int `json:"removed"`
+}
+
+// Service is the interface for reverify service
+type Service interface {
+	Request(ctx context.Context, requester, keyID string, remove bool) (core.Job, error)
+	Run(ctx context.Context, job *core.Job) (string, error)
+	ListInvalid(ctx context.Context, keyID string) ([]core.InvalidDocument, error)
+}
+
+type service struct {
+	repo     Repository
+	job      core.JobService
+	key      core.KeyService
+	timeline core.TimelineService
+}
+
+// NewService creates a new reverify service
+func NewService(repo Repository, job core.JobService, key core.KeyService, timeline core.TimelineService) Service {
+	return &service{repo, job, key, timeline}
+}
+
+// Request enqueues the re-validation of the documents signed with a revoked key
+func (s *service) Request(ctx context.Context, requester, keyID string, remove bool) (core.Job, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Service.Request")
+	defer span.End()
+
+	key, err := s.repo.GetKey(ctx, keyID)
+	if err != nil {
+		return core.Job{}, err
+	}
+	if key.RevokeDocument == nil {
+		return core.Job{}, ErrorNotRevoked
+	}
+
+	payload, _ := json.Marshal(Payload{KeyID: keyID, Remove: remove})
+	return s.job.Create(ctx, requester, JobType, string(payload), time.Now())
+}
+
+// Run re-validates the documents of the job. it is called by the job reactor
+func (s *service) Run(ctx context.Context, job *core.Job) (string, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Service.Run")
+	defer span.End()
+
+	var payload Payload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+
+	key, err := s.repo.GetKey(ctx, payload.KeyID)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	if key.RevokeDocument == nil {
+		return "", ErrorNotRevoked
+	}
+
+	// subkeys enacted by the revoked key are revoked along with it
+	keys, err := s.keyFamily(ctx, payload.KeyID)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	var result Result
+	err = s.repo.EachSignedBy(ctx, keys, key.ValidUntil, func(logs []core.CommitLog) error {
+		for _, log := range logs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			result.Checked++
+
+			doc, reason := s.check(ctx, log)
+			if reason == "" {
+				continue
+			}
+			result.Invalid++
+
+			if payload.Remove {
+				resourceID, removed, err := s.repo.RemoveResource(ctx, log.Type, log.DocumentID)
+				if err != nil {
+					return err
+				}
+				if removed {
+					doc.Removed = true
+					result.Removed++
+					if err := s.timeline.RemoveItemsByResourceID(ctx, resourceID); err != nil {
+						span.RecordError(err)
+					}
+				}
+			}
+
+			if err := s.repo.Flag(ctx, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+
+	logging.Info(ctx, "documents re-validated",
+		slog.String("module", "reverify"),
+		slog.String("key", payload.KeyID),
+		slog.Int("checked", result.Checked),
+		slog.Int("invalid", result.Invalid),
+		slog.Int("removed", result.Removed),
+	)
+
+	encoded, _ := json.Marshal(result)
+	return string(encoded), nil
+}
+
+// ListInvalid returns the invalid documents found for the key and its subkeys
+func (s *service) ListInvalid(ctx context.Context, keyID string) ([]core.InvalidDocument, error) {
+	ctx, span := tracer.Start(ctx, "Reverify.Service.ListInvalid")
+	defer span.End()
+
+	keys, err := s.keyFamily(ctx, keyID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return s.repo.ListFlagged(ctx, keys)
+}
+
+func (s *service) keyFamily(ctx context.Context, keyID string) ([]string, error) {
+	descendants, err := s.repo.ListDescendants(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{keyID}, descendants...), nil
+}
+
+// check validates the document again with the keys as they are now.
+// it returns the flag of the document and the reason it is invalid, or an empty reason when it is still valid
+func (s *service) check(ctx context.Context, log core.CommitLog) (core.InvalidDocument, string) {
+	var doc core.DocumentBase[any]
+	_ = json.Unmarshal([]byte(log.Document), &doc)
+
+	flag := core.InvalidDocument{
+		CommitLogID: log.ID,
+		DocumentID:  log.DocumentID,
+		Type:        log.Type,
+		Signer:      doc.Signer,
+		KeyID:       doc.KeyID,
+		SignedAt:    log.SignedAt,
+	}
+
+	reason := s.invalidReason(ctx, log, doc)
+	flag.Reason = reason
+	return flag, reason
+}
+
+func (s *service) invalidReason(ctx context.Context, log core.CommitLog, doc core.DocumentBase[any]) string {
+	if doc.KeyID == "" {
+		return "document is not signed with a subkey"
+	}
+
+	chain, err := s.key.GetKeyResolution(ctx, doc.KeyID)
+	if err != nil {
+		return fmt.Sprintf("failed to resolve key: %s", err.Error())
+	}
+	if len(chain) == 0 || chain[len(chain)-1].Root != doc.Signer {
+		return "key does not belong to the signer"
+	}
+	for _, key := range chain {
+		if reason := validAt(key, doc.SignedAt); reason != "" {
+			return reason
+		}
+	}
+
+	signature, err := hex.DecodeString(log.Signature)
+	if err != nil {
+		return "malformed signature"
+	}
+	if err := core.VerifySignature([]byte(log.Document), signature, doc.KeyID); err != nil {
+		return "signature does not match the key"
+	}
+	return ""
+}
+
+// validAt tells why the key couldn't sign at the time, or an empty string if it could
+func validAt(key core.Key, at time.Time) string {
+	if !key.ValidSince.IsZero() && at.Before(key.ValidSince) {
+		return fmt.Sprintf("signed before key %s was enacted", key.ID)
+	}
+	if key.RevokeDocument != nil && !at.Before(key.ValidUntil) {
+		return fmt.Sprintf("signed after key %s was revoked at %s", key.ID, key.ValidUntil.Format(time.RFC3339))
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package reverify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+const (
+	signer    = "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+	revokedID = "cck1ydda2qj3nr9kxdqfmgwmf8s6ahtg4y9k4cgm8e"
+)
+
+var revokedAt = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+type memoryRepository struct {
+	key     core.Key
+	logs    []core.CommitLog
+	flagged []core.InvalidDocument
+	removed []string
+}
+
+func (r *memoryRepository) GetKey(context.Context, string) (core.Key, error) { return r.key, nil }
+func (r *memoryRepository) ListDescendants(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+func (r *memoryRepository) EachSignedBy(_ context.Context, _ []string, since time.Time, fn func([]core.CommitLog) error) error {
+	var logs []core.CommitLog
+	for _, log := range r.logs {
+		if !log.SignedAt.Before(since) {
+			logs = append(logs, log)
+		}
+	}
+	return fn(logs)
+}
+func (r *memoryRepository) RemoveResource(_ context.Context, typ, documentID string) (string, bool, error) {
+	if typ != "message" {
+		return "", false, nil
+	}
+	r.removed = append(r.removed, documentID)
+	return "m" + documentID, true, nil
+}
+func (r *memoryRepository) Flag(_ context.Context, doc core.InvalidDocument) error {
+	r.flagged = append(r.flagged, doc)
+	return nil
+}
+func (r *memoryRepository) ListFlagged(context.Context, []string) ([]core.InvalidDocument, error) {
+	return r.flagged, nil
+}
+
+func commitLog(id uint, typ string, signedAt time.Time) core.CommitLog {
+	document, _ := json.Marshal(core.DocumentBase[any]{
+		Signer:   signer,
+		Type:     typ,
+		KeyID:    revokedID,
+		SignedAt: signedAt,
+	})
+	return core.CommitLog{
+		ID:         id,
+		DocumentID: "0000000000000000000000000" + string(rune('0'+id)),
+		Type:       typ,
+		Document:   string(document),
+		SignedAt:   signedAt,
+	}
+}
+
+func TestValidAt(t *testing.T) {
+	revoke := "{}"
+	key := core.Key{ID: revokedID, ValidSince: revokedAt.Add(-time.Hour), ValidUntil: revokedAt, RevokeDocument: &revoke}
+
+	assert.Empty(t, validAt(key, revokedAt.Add(-time.Minute)))
+	assert.NotEmpty(t, validAt(key, revokedAt))
+	assert.NotEmpty(t, validAt(key, revokedAt.Add(-2*time.Hour)))
+	assert.Empty(t, validAt(core.Key{ID: revokedID}, revokedAt))
+}
+
+func TestRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	revoke := "{}"
+	key := core.Key{ID: revokedID, Root: signer, Parent: signer, ValidUntil: revokedAt, RevokeDocument: &revoke}
+	repo := &memoryRepository{
+		key: key,
+		logs: []core.CommitLog{
+			commitLog(1, "message", revokedAt.Add(-time.Hour)), // before the revocation, never checked
+			commitLog(2, "message", revokedAt.Add(time.Hour)),
+			commitLog(3, "profile", revokedAt.Add(time.Hour)),
+		},
+	}
+
+	keys := mock_core.NewMockKeyService(ctrl)
+	keys.EXPECT().GetKeyResolution(gomock.Any(), revokedID).Return([]core.Key{key}, nil).Times(2)
+
+	timeline := mock_core.NewMockTimelineService(ctrl)
+	timeline.EXPECT().RemoveItemsByResourceID(gomock.Any(), "m00000000000000000000000002").Return(nil).Times(1)
+
+	service := NewService(repo, mock_core.NewMockJobService(ctrl), keys, timeline)
+
+	payload, _ := json.Marshal(Payload{KeyID: revokedID, Remove: true})
+	result, err := service.Run(context.Background(), &core.Job{Payload: string(payload)})
+	assert.NoError(t, err)
+
+	var decoded Result
+	assert.NoError(t, json.Unmarshal([]byte(result), &decoded))
+	assert.Equal(t, Result{Checked: 2, Invalid: 2, Removed: 1}, decoded)
+
+	assert.Equal(t, []string{"00000000000000000000000002"}, repo.removed)
+	assert.Len(t, repo.flagged, 2)
+	assert.True(t, repo.flagged[0].Removed)
+	assert.False(t, repo.flagged[1].Removed)
+	assert.Contains(t, repo.flagged[0].Reason, "revoked")
+}
@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	List(c echo.Context) error
+}
+
+type handler struct {
+	scheduler Scheduler
+}
+
+// NewHandler creates a new handler
+func NewHandler(scheduler Scheduler) Handler {
+	return &handler{scheduler: scheduler}
+}
+
+// List returns the health of the scheduled jobs
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Scheduler.Handler.List")
+	defer span.End()
+
+	statuses, err := h.scheduler.Status(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": statuses})
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, 6, 15, 10, 7, 30, 0, time.UTC) // saturday
+
+	testCases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/5 * * * *", time.Date(2024, 6, 15, 10, 10, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2024, 6, 16, 3, 30, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,20 * *", time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)},
+		{"@every 1m", time.Date(2024, 6, 15, 10, 8, 0, 0, time.UTC)},
+		{"@every 10s", time.Date(2024, 6, 15, 10, 7, 40, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		schedule, err := ParseSchedule(tc.spec)
+		if assert.NoError(t, err, tc.spec) {
+			assert.Equal(t, tc.expected, schedule.Next(base).UTC(), tc.spec)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10ms", "@every x"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+
+	never, err := ParseSchedule("0 0 31 2 *")
+	assert.NoError(t, err)
+	assert.True(t, never.Next(base).IsZero())
+}
+
+func TestInvokeRecoversPanic(t *testing.T) {
+	err := invoke(context.Background(), "test", func(ctx context.Context) error {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "panic: boom")
+
+	err = invoke(context.Background(), "test", func(ctx context.Context) error {
+		return nil
+	})
+	assert.NoError(t, err)
+}
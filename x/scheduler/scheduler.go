@@ -0,0 +1,304 @@
+// Package scheduler runs periodic background jobs of the api.
+// each run is recorded in redis so that the health of the jobs can be inspected,
+// and a run is executed by only one instance even when several instances share redis.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+var tracer = otel.Tracer("scheduler")
+
+var runMetrics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cc_scheduler_runs_total",
+		Help: "scheduled job runs by result",
+	},
+	[]string{"job", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(runMetrics)
+}
+
+const (
+	defaultTimeout = 10 * time.Minute
+	// a job whose next run is overdue by this much is considered stalled
+	stallGrace = time.Minute
+)
+
+// Job is a periodic task
+type Job struct {
+	Name     string
+	Spec     string   // see ParseSchedule. ignored if Schedule is set
+	Schedule Schedule // custom schedule
+	// random delay added to each activation, to spread the load of jobs sharing a schedule
+	Jitter time.Duration
+	// maximum duration of a run. defaults to 10 minutes
+	Timeout time.Duration
+	// runs on every instance, for jobs which update the state of the process itself (e.g. metrics)
+	PerInstance bool
+	Run         func(ctx context.Context) error
+}
+
+// JobStatus is the recorded state of a job
+type JobStatus struct {
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	LastRun      time.Time `json:"lastRun"`
+	LastSuccess  time.Time `json:"lastSuccess"`
+	LastDuration float64   `json:"lastDuration"` // seconds
+	LastError    string    `json:"lastError,omitempty"`
+	NextRun      time.Time `json:"nextRun"`
+	Runs         int64     `json:"runs"`
+	Failures     int64     `json:"failures"`
+	Healthy      bool      `json:"healthy"`
+}
+
+// Scheduler is the interface for the job scheduler
+type Scheduler interface {
+	Register(job Job) error
+	Start(ctx context.Context)
+	Status(ctx context.Context) ([]JobStatus, error)
+}
+
+type entry struct {
+	Job
+	spec string
+}
+
+type scheduler struct {
+	rdb      *redis.Client
+	instance string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	ctx     context.Context
+}
+
+// NewScheduler creates a new scheduler
+func NewScheduler(rdb *redis.Client) Scheduler {
+	return &scheduler{
+		rdb:      rdb,
+		instance: strconv.FormatInt(rand.Int63(), 36),
+		entries:  make(map[string]*entry),
+	}
+}
+
+func stateKey(name string) string {
+	return keyspace.Scheduler.Key(name)
+}
+
+func lockKey(name string, slot time.Time) string {
+	return keyspace.Scheduler.Key(name, "lock", strconv.FormatInt(slot.Unix(), 10))
+}
+
+// Register adds a job. jobs registered after Start are started immediately.
+func (s *scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job name and run are required")
+	}
+
+	e := &entry{Job: job, spec: job.Spec}
+	if e.Schedule == nil {
+		schedule, err := ParseSchedule(job.Spec)
+		if err != nil {
+			return errors.Wrapf(err, "job %s", job.Name)
+		}
+		e.Schedule = schedule
+	}
+	if e.spec == "" {
+		e.spec = "custom"
+	}
+	if e.Timeout <= 0 {
+		e.Timeout = defaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries[job.Name] = e
+
+	if s.ctx != nil {
+		go s.loop(s.ctx, e)
+	}
+	return nil
+}
+
+// Start runs every registered job on its schedule until ctx is done
+func (s *scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		return
+	}
+	s.ctx = ctx
+
+	for _, e := range s.entries {
+		go s.loop(ctx, e)
+	}
+}
+
+func (s *scheduler) loop(ctx context.Context, e *entry) {
+	for {
+		slot := e.Schedule.Next(time.Now())
+		if slot.IsZero() {
+			slog.Warn("job will never run again", slog.String("job", e.Name), slog.String("module", "scheduler"))
+			return
+		}
+
+		err := s.rdb.HSet(ctx, stateKey(e.Name), "next_run", slot.Unix()).Err()
+		if err != nil && ctx.Err() == nil {
+			slog.Error("failed to record next run", slog.String("job", e.Name), slog.String("error", err.Error()), slog.String("module", "scheduler"))
+		}
+
+		wait := time.Until(slot)
+		if e.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(e.Jitter)))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, e, slot)
+	}
+}
+
+// run executes the job for the slot, unless another instance already took it
+func (s *scheduler) run(ctx context.Context, e *entry, slot time.Time) {
+	ctx, span := tracer.Start(ctx, "Scheduler.Run."+e.Name)
+	defer span.End()
+
+	if !e.PerInstance {
+		acquired, err := s.rdb.SetNX(ctx, lockKey(e.Name, slot), s.instance, e.Timeout+e.Jitter).Result()
+		if err != nil {
+			// without redis the job still runs here, rather than silently not at all
+			span.RecordError(err)
+			slog.WarnContext(ctx, "failed to lock job run", slog.String("job", e.Name), slog.String("error", err.Error()), slog.String("module", "scheduler"))
+		} else if !acquired {
+			return
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, e.Timeout)
+	started := time.Now()
+	err := invoke(runCtx, e.Name, e.Run)
+	duration := time.Since(started)
+	cancel()
+
+	fields := map[string]any{
+		"last_run":      started.Unix(),
+		"last_duration": duration.Seconds(),
+	}
+	pipe := s.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, stateKey(e.Name), "runs", 1)
+	if err != nil {
+		span.RecordError(err)
+		runMetrics.WithLabelValues(e.Name, "failure").Inc()
+		slog.ErrorContext(ctx, "job failed", slog.String("job", e.Name), slog.String("error", err.Error()), slog.String("module", "scheduler"))
+		fields["last_error"] = err.Error()
+		pipe.HIncrBy(ctx, stateKey(e.Name), "failures", 1)
+	} else {
+		runMetrics.WithLabelValues(e.Name, "success").Inc()
+		fields["last_error"] = ""
+		fields["last_success"] = started.Unix()
+	}
+	pipe.HSet(ctx, stateKey(e.Name), fields)
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to record job run", slog.String("job", e.Name), slog.String("error", err.Error()), slog.String("module", "scheduler"))
+	}
+}
+
+// invoke calls fn and turns a panic into an error, so that a broken run doesn't stop the job
+func invoke(ctx context.Context, name string, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "job panicked", slog.String("job", name), slog.Any("panic", r), slog.String("stack", string(debug.Stack())), slog.String("module", "scheduler"))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func parseUnix(value string) time.Time {
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Status returns the recorded state of every registered job.
+// a job is unhealthy if its last run failed or its next run is overdue.
+func (s *scheduler) Status(ctx context.Context) ([]JobStatus, error) {
+	ctx, span := tracer.Start(ctx, "Scheduler.Status")
+	defer span.End()
+
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(entries, func(a, b *entry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	now := time.Now()
+	statuses := make([]JobStatus, 0, len(entries))
+	for _, e := range entries {
+		state, err := s.rdb.HGetAll(ctx, stateKey(e.Name)).Result()
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+
+		status := JobStatus{
+			Name:        e.Name,
+			Schedule:    e.spec,
+			LastRun:     parseUnix(state["last_run"]),
+			LastSuccess: parseUnix(state["last_success"]),
+			LastError:   state["last_error"],
+			NextRun:     parseUnix(state["next_run"]),
+		}
+		status.LastDuration, _ = strconv.ParseFloat(state["last_duration"], 64)
+		status.Runs, _ = strconv.ParseInt(state["runs"], 10, 64)
+		status.Failures, _ = strconv.ParseInt(state["failures"], 10, 64)
+
+		overdue := !status.NextRun.IsZero() && now.After(status.NextRun.Add(e.Jitter+e.Timeout+stallGrace))
+		status.Healthy = status.LastError == "" && !overdue
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	List(c echo.Context) error
+	Get(c echo.Context) error
+	Deprecate(c echo.Context) error
+	Undeprecate(c echo.Context) error
+}
+
+type handler struct {
+	registry Registry
+}
+
+// NewHandler creates a new handler
+func NewHandler(registry Registry) Handler {
+	return &handler{registry}
+}
+
+// DeprecateRequest is the body of a deprecation
+type DeprecateRequest struct {
+	Replacement string `json:"replacement"`
+}
+
+func parseID(c echo.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, core.NewErrorInvalidArgument("invalid schema id")
+	}
+	return uint(id), nil
+}
+
+// List returns the schemas of the domain with their usage
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Schema.Handler.List")
+	defer span.End()
+
+	entries, err := h.registry.List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entries})
+}
+
+// Get returns a schema with its usage. a deprecated schema redirects to its replacement,
+// unless redirect=false is given
+func (h handler) Get(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Schema.Handler.Get")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.registry.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if entry.ReplacementID != nil && c.QueryParam("redirect") != "false" {
+		return c.Redirect(http.StatusMovedPermanently, "/api/v1/schema/"+strconv.FormatUint(uint64(*entry.ReplacementID), 10))
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entry})
+}
+
+// Deprecate marks a schema as replaced by another schema url
+func (h handler) Deprecate(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Schema.Handler.Deprecate")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	var request DeprecateRequest
+	err = c.Bind(&request)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.registry.Deprecate(ctx, id, request.Replacement)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entry})
+}
+
+// Undeprecate removes the deprecation of a schema
+func (h handler) Undeprecate(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Schema.Handler.Undeprecate")
+	defer span.End()
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.registry.Undeprecate(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entry})
+}
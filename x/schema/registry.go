@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// usage is counted over the largest tables, so it is reused for a while
+const usageTTL = 5 * time.Minute
+
+// Usage is how much a schema is used by the documents stored on this domain
+type Usage struct {
+	Messages  int64      `json:"messages"`
+	Profiles  int64      `json:"profiles"`
+	Timelines int64      `json:"timelines"`
+	FirstSeen *time.Time `json:"firstSeen,omitempty"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+}
+
+// Entry is a schema of the registry with its usage
+type Entry struct {
+	core.Schema
+	Usage
+	// ReplacementID is the id of the schema at ReplacedBy
+	ReplacementID *uint `json:"replacementId,omitempty"`
+}
+
+// Registry exposes the schemas known to the domain
+type Registry interface {
+	List(ctx context.Context) ([]Entry, error)
+	Get(ctx context.Context, id uint) (Entry, error)
+	Deprecate(ctx context.Context, id uint, replacement string) (Entry, error)
+	Undeprecate(ctx context.Context, id uint) (Entry, error)
+}
+
+type registry struct {
+	repo Repository
+
+	mu      sync.Mutex
+	usage   map[uint]Usage
+	usageAt time.Time
+}
+
+// NewRegistry creates a new schema registry
+func NewRegistry(repo Repository) Registry {
+	return &registry{repo: repo}
+}
+
+func (r *registry) getUsage(ctx context.Context) (map[uint]Usage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.usage != nil && time.Since(r.usageAt) < usageTTL {
+		return r.usage, nil
+	}
+
+	usage, err := r.repo.Usage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.usage = usage
+	r.usageAt = time.Now()
+	return usage, nil
+}
+
+// List returns the schemas with their usage
+func (r *registry) List(ctx context.Context) ([]Entry, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Registry.List")
+	defer span.End()
+
+	schemas, err := r.repo.List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	usage, err := r.getUsage(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	ids := make(map[string]uint, len(schemas))
+	for _, schema := range schemas {
+		ids[schema.URL] = schema.ID
+	}
+
+	entries := make([]Entry, len(schemas))
+	for i, schema := range schemas {
+		entries[i] = Entry{Schema: schema, Usage: usage[schema.ID]}
+		if schema.ReplacedBy != nil {
+			if id, ok := ids[*schema.ReplacedBy]; ok {
+				entries[i].ReplacementID = &id
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Get returns a schema with its usage
+func (r *registry) Get(ctx context.Context, id uint) (Entry, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Registry.Get")
+	defer span.End()
+
+	schema, err := r.repo.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return Entry{}, err
+	}
+	usage, err := r.getUsage(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return Entry{}, err
+	}
+	entry := Entry{Schema: schema, Usage: usage[id]}
+	if schema.ReplacedBy != nil {
+		// registered when the schema was deprecated, so this doesn't fetch it again
+		replacement, err := r.repo.Upsert(ctx, *schema.ReplacedBy)
+		if err != nil {
+			span.RecordError(err)
+			return Entry{}, err
+		}
+		entry.ReplacementID = &replacement.ID
+	}
+	return entry, nil
+}
+
+// Deprecate marks the schema as replaced by the url. the replacement is registered as a schema as well,
+// and can't be deprecated itself so that replacements never chain
+func (r *registry) Deprecate(ctx context.Context, id uint, replacement string) (Entry, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Registry.Deprecate")
+	defer span.End()
+
+	schema, err := r.repo.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return Entry{}, err
+	}
+	if replacement == "" || replacement == schema.URL {
+		return Entry{}, core.NewErrorInvalidArgument("replacement must be another schema url")
+	}
+
+	replacing, err := r.repo.Upsert(ctx, replacement)
+	if err != nil {
+		span.RecordError(err)
+		return Entry{}, core.NewErrorInvalidArgument("replacement is not a schema: " + err.Error())
+	}
+	if replacing.ReplacedBy != nil {
+		return Entry{}, core.NewErrorInvalidArgument("replacement is deprecated itself")
+	}
+
+	err = r.repo.SetReplacement(ctx, id, &replacement)
+	if err != nil {
+		span.RecordError(err)
+		return Entry{}, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Undeprecate removes the deprecation of the schema
+func (r *registry) Undeprecate(ctx context.Context, id uint) (Entry, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Registry.Undeprecate")
+	defer span.End()
+
+	err := r.repo.SetReplacement(ctx, id, nil)
+	if err != nil {
+		span.RecordError(err)
+		return Entry{}, err
+	}
+	return r.Get(ctx, id)
+}
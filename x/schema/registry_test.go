@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type memoryRepository struct {
+	schemas    []core.Schema
+	usageCalls int
+}
+
+func (m *memoryRepository) Upsert(ctx context.Context, url string) (core.Schema, error) {
+	for _, schema := range m.schemas {
+		if schema.URL == url {
+			return schema, nil
+		}
+	}
+	schema := core.Schema{ID: uint(len(m.schemas) + 1), URL: url}
+	m.schemas = append(m.schemas, schema)
+	return schema, nil
+}
+
+func (m *memoryRepository) Get(ctx context.Context, id uint) (core.Schema, error) {
+	if id == 0 || int(id) > len(m.schemas) {
+		return core.Schema{}, core.NewErrorNotFound()
+	}
+	return m.schemas[id-1], nil
+}
+
+func (m *memoryRepository) List(ctx context.Context) ([]core.Schema, error) {
+	return m.schemas, nil
+}
+
+func (m *memoryRepository) Usage(ctx context.Context) (map[uint]Usage, error) {
+	m.usageCalls++
+	return map[uint]Usage{1: {Messages: 3, Timelines: 1}}, nil
+}
+
+func (m *memoryRepository) SetReplacement(ctx context.Context, id uint, replacement *string) error {
+	if id == 0 || int(id) > len(m.schemas) {
+		return core.NewErrorNotFound()
+	}
+	m.schemas[id-1].ReplacedBy = replacement
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepository{schemas: []core.Schema{{ID: 1, URL: "https://schema.example/note/0.0.1.json"}}}
+	registry := NewRegistry(repo)
+
+	entries, err := registry.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int64(3), entries[0].Messages)
+	assert.Nil(t, entries[0].ReplacementID)
+
+	_, err = registry.Deprecate(ctx, 1, "https://schema.example/note/0.0.1.json")
+	assert.ErrorAs(t, err, &core.ErrorInvalidArgument{})
+
+	entry, err := registry.Deprecate(ctx, 1, "https://schema.example/note/0.0.2.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://schema.example/note/0.0.2.json", *entry.ReplacedBy)
+	assert.Equal(t, uint(2), *entry.ReplacementID)
+
+	// replacements never chain
+	_, err = registry.Deprecate(ctx, 2, "https://schema.example/note/0.0.1.json")
+	assert.ErrorAs(t, err, &core.ErrorInvalidArgument{})
+
+	entry, err = registry.Undeprecate(ctx, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, entry.ReplacedBy)
+	assert.Nil(t, entry.ReplacementID)
+
+	_, err = registry.Undeprecate(ctx, 5)
+	assert.ErrorAs(t, err, &core.ErrorNotFound{})
+
+	// the usage is counted once within its ttl
+	assert.Equal(t, 1, repo.usageCalls)
+}
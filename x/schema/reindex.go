@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/totegamma/concurrent/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// referencingModels are the tables which hold schema_id
+var referencingModels = []any{
+	&core.Association{},
+	&core.Message{},
+	&core.Profile{},
+	&core.Subscription{},
+	&core.Timeline{},
+	&core.TimelineItem{},
+}
+
+// ReindexEntry is a result of migrating one schema url
+type ReindexEntry struct {
+	ID        uint             `json:"id"`
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	MergeInto *uint            `json:"mergeInto,omitempty"`
+	Rows      map[string]int64 `json:"rows,omitempty"`
+}
+
+// Reindex rewrites schema urls starting with fromPrefix to start with toPrefix.
+// If the rewritten url is already registered, references of the old id are moved to the existing id and the old row is removed.
+// Otherwise the url of the row is updated in place and ids stay the same.
+// With dryRun, the changes are computed in a transaction which is rolled back.
+func Reindex(ctx context.Context, db *gorm.DB, fromPrefix, toPrefix string, dryRun bool) ([]ReindexEntry, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Reindex")
+	defer span.End()
+
+	var entries []ReindexEntry
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var schemas []core.Schema
+		err := tx.Where("url LIKE ?", escapeLike(fromPrefix)+"%").Order("id").Find(&schemas).Error
+		if err != nil {
+			return err
+		}
+
+		for _, schema := range schemas {
+			entry := ReindexEntry{
+				ID:   schema.ID,
+				From: schema.URL,
+				To:   toPrefix + strings.TrimPrefix(schema.URL, fromPrefix),
+			}
+
+			var existing core.Schema
+			err := tx.Where("url = ?", entry.To).Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+
+			if existing.ID == 0 {
+				err = tx.Model(&core.Schema{}).Where("id = ?", schema.ID).Update("url", entry.To).Error
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry)
+				continue
+			}
+
+			entry.MergeInto = &existing.ID
+			entry.Rows = make(map[string]int64)
+			for _, model := range referencingModels {
+				result := tx.Model(model).Where("schema_id = ?", schema.ID).Update("schema_id", existing.ID)
+				if result.Error != nil {
+					return result.Error
+				}
+				stmt := &gorm.Statement{DB: tx}
+				if err := stmt.Parse(model); err != nil {
+					return err
+				}
+				entry.Rows[stmt.Schema.Table] = result.RowsAffected
+			}
+
+			err = recountAssociations(tx, existing.ID, schema.ID)
+			if err != nil {
+				return err
+			}
+
+			err = tx.Delete(&core.Schema{}, schema.ID).Error
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errDryRun) {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+var errDryRun = errors.New("dry run")
+
+// recountAssociations rebuilds the association counts of the schema which the other one was merged into.
+// the counts of both may share a target and variant, so they can't be moved like the other references
+func recountAssociations(tx *gorm.DB, into, merged uint) error {
+	err := tx.Where("schema_id IN ?", []uint{into, merged}).Delete(&core.AssociationCount{}).Error
+	if err != nil {
+		return err
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	err = stmt.Parse(&core.AssociationCount{})
+	if err != nil {
+		return err
+	}
+	counts := tx.Model(&core.Association{}).Select("target, schema_id, variant, count(*)").Where("schema_id = ?", into).Group("target, schema_id, variant")
+	return tx.Exec("INSERT INTO ? (target, schema_id, variant, count) ?", clause.Table{Name: stmt.Schema.Table}, counts).Error
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -7,6 +7,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
 	"net/http"
+	"time"
 )
 
 var tracer = otel.Tracer("schema")
@@ -14,6 +15,9 @@ var tracer = otel.Tracer("schema")
 type Repository interface {
 	Upsert(ctx context.Context, schema string) (core.Schema, error)
 	Get(ctx context.Context, id uint) (core.Schema, error)
+	List(ctx context.Context) ([]core.Schema, error)
+	Usage(ctx context.Context) (map[uint]Usage, error)
+	SetReplacement(ctx context.Context, id uint, replacement *string) error
 }
 
 type repository struct {
@@ -80,3 +84,85 @@ func (r *repository) Get(ctx context.Context, id uint) (core.Schema, error) {
 	}
 	return s, err
 }
+
+// List returns every registered schema
+func (r *repository) List(ctx context.Context) ([]core.Schema, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Repository.List")
+	defer span.End()
+
+	var schemas []core.Schema
+	err := r.db.WithContext(ctx).Order("id").Find(&schemas).Error
+	return schemas, err
+}
+
+// Usage counts the messages, profiles and timelines of each schema, with the first and the last time one was created
+func (r *repository) Usage(ctx context.Context) (map[uint]Usage, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Repository.Usage")
+	defer span.End()
+
+	usages := map[uint]Usage{}
+	for _, model := range []any{&core.Message{}, &core.Profile{}, &core.Timeline{}} {
+		var rows []struct {
+			SchemaID uint
+			Count    int64
+			First    time.Time
+			Last     time.Time
+		}
+		err := r.db.WithContext(ctx).
+			Model(model).
+			Select("schema_id, count(*) AS count, min(c_date) AS first, max(c_date) AS last").
+			Group("schema_id").
+			Scan(&rows).Error
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+
+		for _, row := range rows {
+			usage := usages[row.SchemaID]
+			switch model.(type) {
+			case *core.Message:
+				usage.Messages = row.Count
+			case *core.Profile:
+				usage.Profiles = row.Count
+			case *core.Timeline:
+				usage.Timelines = row.Count
+			}
+			if usage.FirstSeen == nil || row.First.Before(*usage.FirstSeen) {
+				first := row.First
+				usage.FirstSeen = &first
+			}
+			if usage.LastSeen == nil || row.Last.After(*usage.LastSeen) {
+				last := row.Last
+				usage.LastSeen = &last
+			}
+			usages[row.SchemaID] = usage
+		}
+	}
+	return usages, nil
+}
+
+// SetReplacement deprecates the schema in favor of the replacement url, or undeprecates it with nil
+func (r *repository) SetReplacement(ctx context.Context, id uint, replacement *string) error {
+	ctx, span := tracer.Start(ctx, "Schema.Repository.SetReplacement")
+	defer span.End()
+
+	var deprecatedAt *time.Time
+	if replacement != nil {
+		now := time.Now()
+		deprecatedAt = &now
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&core.Schema{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"replaced_by": replacement, "deprecated_at": deprecatedAt})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package schema
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/jsonschema"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+const (
+	// ValidationOff skips validation
+	ValidationOff = "off"
+	// ValidationWarn logs violations but accepts the document
+	ValidationWarn = "warn"
+	// ValidationEnforce rejects documents which violate their schema
+	ValidationEnforce = "enforce"
+
+	schemaCacheTTL     = time.Hour
+	schemaFailureTTL   = 5 * time.Minute
+	schemaFetchTimeout = 5 * time.Second
+	schemaMaxSize      = 1 << 20
+	// schema urls are chosen by the signers of the documents, so the number of cached ones is bounded
+	schemaCacheSize = 1024
+)
+
+type cachedSchema struct {
+	url       string
+	schema    *jsonschema.Schema
+	err       error
+	fetchedAt time.Time
+}
+
+type validator struct {
+	mode   string
+	client *http.Client
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewValidator creates a validator which fetches and caches JSON schemas by their https url from public hosts.
+// documents whose schema cannot be fetched or compiled are accepted without validation.
+func NewValidator(mode string) core.SchemaValidator {
+	if mode == "" {
+		mode = ValidationOff
+	}
+	return &validator{
+		mode:    mode,
+		client:  newClient(),
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// checkSchemaURL accepts only https urls, as the schema is fetched by the server
+func checkSchemaURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("schema url must be https: %s", raw)
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the special purpose ranges of IANA which are not routable on the internet,
+// or which embed an IPv4 address (NAT64, 6to4, teredo) that could point inside
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// isPublicIP reports whether the address is routable on the internet.
+// IPv4-mapped IPv6 addresses are checked as the IPv4 address they map
+func isPublicIP(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// refusePrivate is the control of the dialer, run with the resolved address of every connection
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicIP(addr) {
+		return fmt.Errorf("refused to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// newClient creates the http client fetching the schemas. only public addresses are dialed
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: schemaFetchTimeout,
+		Control: refusePrivate,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the host, so the addresses could not be checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   schemaFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return checkSchemaURL(req.URL.String())
+		},
+	}
+}
+
+func (v *validator) Validate(ctx context.Context, schemaURL string, body any) error {
+	ctx, span := tracer.Start(ctx, "Schema.Validator.Validate")
+	defer span.End()
+
+	if v.mode == ValidationOff || schemaURL == "" {
+		return nil
+	}
+
+	schema, err := v.load(ctx, schemaURL)
+	if err != nil {
+		logging.Warn(
+			ctx, "schema unavailable. skipping validation",
+			slog.String("schema", schemaURL),
+			slog.String("error", err.Error()),
+			slog.String("module", "schema"),
+		)
+		return nil
+	}
+
+	errs := schema.Validate(body)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	violations := make([]core.SchemaViolation, len(errs))
+	for i, e := range errs {
+		violations[i] = core.SchemaViolation{Path: e.Path, Keyword: e.Keyword, Message: e.Message}
+	}
+
+	if v.mode == ValidationWarn {
+		logging.Warn(
+			ctx, "document body violates schema",
+			slog.String("schema", schemaURL),
+			slog.Any("violations", violations),
+			slog.String("module", "schema"),
+		)
+		return nil
+	}
+
+	err = core.NewErrorSchemaViolation(schemaURL, violations)
+	span.RecordError(err)
+	return err
+}
+
+func (v *validator) load(ctx context.Context, schemaURL string) (*jsonschema.Schema, error) {
+	err := checkSchemaURL(schemaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := v.get(schemaURL); ok {
+		ttl := schemaCacheTTL
+		if cached.err != nil {
+			ttl = schemaFailureTTL
+		}
+		if time.Since(cached.fetchedAt) < ttl {
+			return cached.schema, cached.err
+		}
+	}
+
+	schema, err := v.fetch(ctx, schemaURL)
+	v.set(&cachedSchema{url: schemaURL, schema: schema, err: err, fetchedAt: time.Now()})
+
+	return schema, err
+}
+
+func (v *validator) get(schemaURL string) (*cachedSchema, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	elem, ok := v.entries[schemaURL]
+	if !ok {
+		return nil, false
+	}
+	v.order.MoveToFront(elem)
+	return elem.Value.(*cachedSchema), true
+}
+
+// set caches the schema, evicting the least recently used ones over schemaCacheSize
+func (v *validator) set(entry *cachedSchema) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if elem, ok := v.entries[entry.url]; ok {
+		elem.Value = entry
+		v.order.MoveToFront(elem)
+		return
+	}
+
+	v.entries[entry.url] = v.order.PushFront(entry)
+	for v.order.Len() > schemaCacheSize {
+		oldest := v.order.Back()
+		v.order.Remove(oldest)
+		delete(v.entries, oldest.Value.(*cachedSchema).url)
+	}
+}
+
+func (v *validator) fetch(ctx context.Context, schemaURL string) (*jsonschema.Schema, error) {
+	ctx, span := tracer.Start(ctx, "Schema.Validator.fetch")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", schemaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, schemaMaxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonschema.Compile(data)
+}
@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorRefusesNonPublicSchemas(t *testing.T) {
+	var hits int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`{"type": "object"}`))
+	}))
+	defer server.Close()
+
+	v := NewValidator(ValidationEnforce).(*validator)
+	v.client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	_, err := v.load(context.Background(), "http://example.com/schema.json")
+	assert.ErrorContains(t, err, "must be https")
+
+	_, err = v.load(context.Background(), server.URL+"/schema.json")
+	assert.ErrorContains(t, err, "non-public address")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+}
+
+func TestValidatorCacheIsBounded(t *testing.T) {
+	v := NewValidator(ValidationEnforce).(*validator)
+
+	for i := 0; i < schemaCacheSize+10; i++ {
+		v.set(&cachedSchema{url: fmt.Sprintf("https://example.com/%d.json", i), fetchedAt: time.Now()})
+	}
+	assert.Equal(t, schemaCacheSize, v.order.Len())
+	assert.Len(t, v.entries, schemaCacheSize)
+
+	_, ok := v.get("https://example.com/0.json")
+	assert.False(t, ok)
+	_, ok = v.get(fmt.Sprintf("https://example.com/%d.json", schemaCacheSize+9))
+	assert.True(t, ok)
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for _, addr := range []string{"93.184.215.14", "1.1.1.1", "2606:4700:4700::1111"} {
+		assert.True(t, isPublicIP(netip.MustParseAddr(addr)), addr)
+	}
+
+	for _, addr := range []string{
+		"127.0.0.1",
+		"10.1.2.3",
+		"172.16.0.1",
+		"192.168.1.1",
+		"169.254.169.254",
+		"100.64.0.1",
+		"100.127.255.254",
+		"0.0.0.0",
+		"0.1.2.3",
+		"198.18.0.1",
+		"224.0.0.1",
+		"255.255.255.255",
+		"::",
+		"::1",
+		"::ffff:127.0.0.1",
+		"::ffff:169.254.169.254",
+		"::ffff:100.64.0.1",
+		"64:ff9b::a9fe:a9fe",
+		"64:ff9b:1::a00:1",
+		"2002:a9fe:a9fe::1",
+		"2001::1",
+		"fd00::1",
+		"fe80::1",
+		"fe80::1%eth0",
+		"ff02::1",
+	} {
+		assert.False(t, isPublicIP(netip.MustParseAddr(addr)), addr)
+	}
+
+	assert.Error(t, refusePrivate("tcp", "[::ffff:10.0.0.1]:443", nil))
+	assert.Error(t, refusePrivate("tcp", "100.64.0.1:443", nil))
+	assert.NoError(t, refusePrivate("tcp", "1.1.1.1:443", nil))
+}
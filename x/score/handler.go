@@ -0,0 +1,78 @@
+package score
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Override(c echo.Context) error
+	History(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+type overrideRequest struct {
+	Score int  `json:"score"`
+	Fixed bool `json:"fixed"`
+}
+
+// Override sets the score of an entity. with fixed the score is no longer computed by the rules
+func (h handler) Override(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Score.Handler.Override")
+	defer span.End()
+
+	var request overrideRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	requester, _ := ctx.Value(core.RequesterIdCtxKey).(string)
+
+	err := h.service.Override(ctx, c.Param("id"), request.Score, request.Fixed, requester)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, core.ErrorNotFound{}) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "entity not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+// History returns the score changes of an entity
+func (h handler) History(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Score.Handler.History")
+	defer span.End()
+
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid limit"})
+		}
+	}
+
+	history, err := h.service.History(ctx, c.Param("id"), limit)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": history})
+}
@@ -0,0 +1,118 @@
+package score
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// entities scored at once
+const scoreBatchSize = 500
+
+// Repository is the interface for score repository
+type Repository interface {
+	EachScorable(ctx context.Context, fn func([]core.Entity) error) error
+	CountAckers(ctx context.Context, ccid string) (int64, error)
+	CountMessagesSince(ctx context.Context, author string, since time.Time) (int64, error)
+	GetDomainScore(ctx context.Context, fqdn string) (int, error)
+	AddHistory(ctx context.Context, history core.EntityScoreHistory) error
+	ListHistory(ctx context.Context, ccid string, limit int) ([]core.EntityScoreHistory, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new score repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// EachScorable calls fn with the entities whose score is not fixed, in batches
+func (r *repository) EachScorable(ctx context.Context, fn func([]core.Entity) error) error {
+	ctx, span := tracer.Start(ctx, "Score.Repository.EachScorable")
+	defer span.End()
+
+	// entities have no primary key for FindInBatches, so they are paged by id
+	last := ""
+	for {
+		var entities []core.Entity
+		err := r.db.WithContext(ctx).
+			Where("is_score_fixed = ? AND tombstone_document IS NULL AND id > ?", false, last).
+			Order("id ASC").
+			Limit(scoreBatchSize).
+			Find(&entities).Error
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if len(entities) == 0 {
+			return nil
+		}
+		if err := fn(entities); err != nil {
+			return err
+		}
+		if len(entities) < scoreBatchSize {
+			return nil
+		}
+		last = entities[len(entities)-1].ID
+	}
+}
+
+// CountAckers returns the number of entities validly acking the entity
+func (r *repository) CountAckers(ctx context.Context, ccid string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Score.Repository.CountAckers")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Ack{}).Where("\"to\" = ? AND valid = true", ccid).Count(&count).Error
+	return count, err
+}
+
+// CountMessagesSince returns the number of messages the author posted since the time
+func (r *repository) CountMessagesSince(ctx context.Context, author string, since time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Score.Repository.CountMessagesSince")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Message{}).Where("author = ? AND c_date >= ?", author, since).Count(&count).Error
+	return count, err
+}
+
+// GetDomainScore returns the score of a known domain. unknown domains score 0
+func (r *repository) GetDomainScore(ctx context.Context, fqdn string) (int, error) {
+	ctx, span := tracer.Start(ctx, "Score.Repository.GetDomainScore")
+	defer span.End()
+
+	var domain core.Domain
+	err := r.db.WithContext(ctx).Where("id = ?", fqdn).First(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return domain.Score, nil
+}
+
+// AddHistory records a change of an entity score
+func (r *repository) AddHistory(ctx context.Context, history core.EntityScoreHistory) error {
+	ctx, span := tracer.Start(ctx, "Score.Repository.AddHistory")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Create(&history).Error
+}
+
+// ListHistory returns the latest score changes of an entity, newest first
+func (r *repository) ListHistory(ctx context.Context, ccid string, limit int) ([]core.EntityScoreHistory, error) {
+	ctx, span := tracer.Start(ctx, "Score.Repository.ListHistory")
+	defer span.End()
+
+	var history []core.EntityScoreHistory
+	err := r.db.WithContext(ctx).Where("entity = ?", ccid).Order("id DESC").Limit(limit).Find(&history).Error
+	return history, err
+}
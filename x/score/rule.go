@@ -0,0 +1,127 @@
+package score
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Signals are the observations about an entity the rules compute the score from
+type Signals struct {
+	Entity         core.Entity
+	Local          bool
+	Ackers         int64 // entities acking the entity
+	RecentMessages int64 // messages posted within the posting rate window
+	DomainScore    int   // score of the domain of a remote entity
+	Now            time.Time
+}
+
+// Rule computes the points an entity gets for one aspect
+type Rule interface {
+	Name() string
+	Evaluate(signals Signals) float64
+}
+
+// RuleConfig configures a rule.
+//
+//	accountAge:       Weight points per day since the entity was registered
+//	ackGraph:         Weight points per acker
+//	postingRate:      Weight points per message posted over Threshold in the last hour. usually negative
+//	domainReputation: Weight points per point of the domain score. local entities get none
+//
+// Max bounds the absolute points of the rule. 0 means unbounded.
+type RuleConfig struct {
+	Type      string  `yaml:"type"`
+	Weight    float64 `yaml:"weight"`
+	Threshold float64 `yaml:"threshold"`
+	Max       float64 `yaml:"max"`
+}
+
+// PostingRateWindow is the period recent messages are counted in
+const PostingRateWindow = time.Hour
+
+type ruleFunc struct {
+	name string
+	max  float64
+	fn   func(Signals) float64
+}
+
+func (r ruleFunc) Name() string {
+	return r.name
+}
+
+func (r ruleFunc) Evaluate(signals Signals) float64 {
+	points := r.fn(signals)
+	if r.max > 0 {
+		points = math.Max(-r.max, math.Min(r.max, points))
+	}
+	return points
+}
+
+// NewRule creates a rule from its config
+func NewRule(config RuleConfig) (Rule, error) {
+	weight := config.Weight
+	var fn func(Signals) float64
+
+	switch config.Type {
+	case "accountAge":
+		fn = func(s Signals) float64 {
+			days := s.Now.Sub(s.Entity.CDate).Hours() / 24
+			if days < 0 {
+				return 0
+			}
+			return weight * days
+		}
+	case "ackGraph":
+		fn = func(s Signals) float64 {
+			return weight * float64(s.Ackers)
+		}
+	case "postingRate":
+		threshold := config.Threshold
+		fn = func(s Signals) float64 {
+			over := float64(s.RecentMessages) - threshold
+			if over <= 0 {
+				return 0
+			}
+			return weight * over
+		}
+	case "domainReputation":
+		fn = func(s Signals) float64 {
+			if s.Local {
+				return 0
+			}
+			return weight * float64(s.DomainScore)
+		}
+	default:
+		return nil, fmt.Errorf("unknown score rule: %s", config.Type)
+	}
+
+	return ruleFunc{name: config.Type, max: config.Max, fn: fn}, nil
+}
+
+// NewRules creates the rules from their configs
+func NewRules(configs []RuleConfig) ([]Rule, error) {
+	rules := make([]Rule, 0, len(configs))
+	for _, config := range configs {
+		rule, err := NewRule(config)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Evaluate sums the points of the rules and returns the score with the points of each rule
+func Evaluate(rules []Rule, signals Signals) (int, map[string]float64) {
+	total := 0.0
+	breakdown := make(map[string]float64, len(rules))
+	for _, rule := range rules {
+		points := rule.Evaluate(signals)
+		breakdown[rule.Name()] += points
+		total += points
+	}
+	return int(math.Round(total)), breakdown
+}
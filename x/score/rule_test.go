@@ -0,0 +1,50 @@
+package score
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	rules, err := NewRules([]RuleConfig{
+		{Type: "accountAge", Weight: 0.1, Max: 30},
+		{Type: "ackGraph", Weight: 1, Max: 50},
+		{Type: "postingRate", Weight: -1, Threshold: 60, Max: 100},
+		{Type: "domainReputation", Weight: 0.5},
+	})
+	assert.NoError(t, err)
+
+	signals := Signals{
+		Entity:         core.Entity{CDate: now.Add(-100 * 24 * time.Hour)},
+		Local:          true,
+		Ackers:         80,
+		RecentMessages: 70,
+		DomainScore:    40,
+		Now:            now,
+	}
+
+	score, breakdown := Evaluate(rules, signals)
+	assert.Equal(t, map[string]float64{
+		"accountAge":       10,
+		"ackGraph":         50,
+		"postingRate":      -10,
+		"domainReputation": 0,
+	}, breakdown)
+	assert.Equal(t, 50, score)
+
+	signals.Local = false
+	signals.RecentMessages = 500
+	score, breakdown = Evaluate(rules, signals)
+	assert.Equal(t, -100.0, breakdown["postingRate"])
+	assert.Equal(t, 20.0, breakdown["domainReputation"])
+	assert.Equal(t, -20, score)
+
+	_, err = NewRules([]RuleConfig{{Type: "unknown"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+// Package score computes entity scores from configurable rules
+package score
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("score")
+
+const (
+	ReasonComputed = "computed"
+	ReasonOverride = "override"
+
+	maxHistoryLimit = 100
+)
+
+// Service is the interface for score service
+type Service interface {
+	Compute(ctx context.Context, entity core.Entity) (int, map[string]float64, error)
+	Run(ctx context.Context) error
+	Override(ctx context.Context, id string, score int, fixed bool, actor string) error
+	History(ctx context.Context, id string, limit int) ([]core.EntityScoreHistory, error)
+}
+
+type service struct {
+	repo   Repository
+	entity core.EntityService
+	rules  []Rule
+	config core.Config
+}
+
+// NewService creates a new score service
+func NewService(repo Repository, entity core.EntityService, rules []Rule, config core.Config) Service {
+	return &service{repo, entity, rules, config}
+}
+
+// signals gathers what the rules need to know about the entity
+func (s *service) signals(ctx context.Context, entity core.Entity, now time.Time) (Signals, error) {
+	signals := Signals{
+		Entity: entity,
+		Local:  entity.Domain == s.config.FQDN,
+		Now:    now,
+	}
+
+	var err error
+	signals.Ackers, err = s.repo.CountAckers(ctx, entity.ID)
+	if err != nil {
+		return Signals{}, err
+	}
+	signals.RecentMessages, err = s.repo.CountMessagesSince(ctx, entity.ID, now.Add(-PostingRateWindow))
+	if err != nil {
+		return Signals{}, err
+	}
+	if !signals.Local {
+		signals.DomainScore, err = s.repo.GetDomainScore(ctx, entity.Domain)
+		if err != nil {
+			return Signals{}, err
+		}
+	}
+	return signals, nil
+}
+
+// Compute returns the score the rules give to the entity with the points of each rule
+func (s *service) Compute(ctx context.Context, entity core.Entity) (int, map[string]float64, error) {
+	ctx, span := tracer.Start(ctx, "Score.Service.Compute")
+	defer span.End()
+
+	signals, err := s.signals(ctx, entity, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return 0, nil, err
+	}
+
+	score, breakdown := Evaluate(s.rules, signals)
+	return score, breakdown, nil
+}
+
+// Run recomputes the score of every entity whose score is not fixed, and records the changed ones
+func (s *service) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Score.Service.Run")
+	defer span.End()
+
+	scored, changed := 0, 0
+	err := s.repo.EachScorable(ctx, func(entities []core.Entity) error {
+		for _, entity := range entities {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			score, breakdown, err := s.Compute(ctx, entity)
+			if err != nil {
+				// one broken entity shouldn't stop the others
+				logging.Warn(ctx, "failed to compute entity score",
+					slog.String("module", "score"),
+					slog.String("entity", entity.ID),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			scored++
+
+			if score == entity.Score {
+				continue
+			}
+
+			err = s.entity.UpdateScore(ctx, entity.ID, score)
+			if err != nil {
+				span.RecordError(err)
+				return err
+			}
+			changed++
+
+			breakdownJSON, _ := json.Marshal(breakdown)
+			err = s.repo.AddHistory(ctx, core.EntityScoreHistory{
+				Entity:    entity.ID,
+				Score:     score,
+				Previous:  entity.Score,
+				Reason:    ReasonComputed,
+				Breakdown: string(breakdownJSON),
+			})
+			if err != nil {
+				span.RecordError(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	logging.Info(ctx, "entity scores computed",
+		slog.String("module", "score"),
+		slog.Int("scored", scored),
+		slog.Int("changed", changed),
+	)
+	return nil
+}
+
+// Override sets the score of an entity by an admin. a fixed score is left alone by the rules
+func (s *service) Override(ctx context.Context, id string, score int, fixed bool, actor string) error {
+	ctx, span := tracer.Start(ctx, "Score.Service.Override")
+	defer span.End()
+
+	entity, err := s.entity.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	err = s.entity.OverrideScore(ctx, id, score, fixed)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return s.repo.AddHistory(ctx, core.EntityScoreHistory{
+		Entity:   id,
+		Score:    score,
+		Previous: entity.Score,
+		Reason:   ReasonOverride,
+		Actor:    actor,
+	})
+}
+
+// History returns the latest score changes of an entity
+func (s *service) History(ctx context.Context, id string, limit int) ([]core.EntityScoreHistory, error) {
+	ctx, span := tracer.Start(ctx, "Score.Service.History")
+	defer span.End()
+
+	if limit <= 0 || limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	return s.repo.ListHistory(ctx, id, limit)
+}
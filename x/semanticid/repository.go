@@ -13,6 +13,7 @@ type Repository interface {
 	Upsert(ctx context.Context, item core.SemanticID) (core.SemanticID, error)
 	Get(ctx context.Context, id, owner string) (core.SemanticID, error)
 	Delete(ctx context.Context, id, owner string) error
+	Repoint(ctx context.Context, from, to string) (int64, error)
 	Clean(ctx context.Context, ccid string) error
 }
 
@@ -62,6 +63,20 @@ func (r *repository) Delete(ctx context.Context, id, owner string) error {
 	return nil
 }
 
+// Repoint makes the semantic ids targeting from target to instead. the signed documents still name the old target
+func (r *repository) Repoint(ctx context.Context, from, to string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "SemanticID.Repository.Repoint")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Model(&core.SemanticID{}).Where("target = ?", from).Update("target", to)
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
 func (r *repository) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "SemanticID.Repository.Clean")
 	defer span.End()
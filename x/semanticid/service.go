@@ -51,6 +51,14 @@ func (s *service) Delete(ctx context.Context, id, owner string) error {
 	return s.repo.Delete(ctx, id, owner)
 }
 
+// Repoint makes the semantic ids targeting from target to instead, e.g. when a timeline is merged into another
+func (s *service) Repoint(ctx context.Context, from, to string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "SemanticID.Service.Repoint")
+	defer span.End()
+
+	return s.repo.Repoint(ctx, from, to)
+}
+
 func (s *service) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "SemanticID.Service.Clean")
 	defer span.End()
@@ -0,0 +1,280 @@
+package spam
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Subject is what the detectors see of a document
+type Subject struct {
+	Signer string
+	Text   string   // the string fields of the body
+	Hosts  []string // hosts of the links in Text, without duplicates
+}
+
+// Detector scores how likely a subject is spam between 0 and 1
+type Detector interface {
+	Name() string
+	Inspect(ctx context.Context, subject Subject) (float64, error)
+}
+
+const (
+	// documentsFeature counts the reviewed documents themselves
+	documentsFeature = "#documents"
+	wordPrefix       = "w:"
+	hostPrefix       = "h:"
+)
+
+// features returns what a reviewed document trains the detectors with
+func features(subject Subject) []string {
+	result := []string{documentsFeature}
+	for _, token := range tokenize(subject.Text) {
+		result = append(result, wordPrefix+token)
+	}
+	for _, host := range subject.Hosts {
+		result = append(result, hostPrefix+host)
+	}
+	return result
+}
+
+// rateDetector suspects signers posting more than limit messages in the window.
+// the score grows from 0 at the limit to 1 at twice the limit
+type rateDetector struct {
+	repo   Repository
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+func (d rateDetector) Name() string {
+	return "rate"
+}
+
+func (d rateDetector) Inspect(ctx context.Context, subject Subject) (float64, error) {
+	count, err := d.repo.CountMessagesSince(ctx, subject.Signer, d.now().Add(-d.window))
+	if err != nil {
+		return 0, err
+	}
+	// the message being inspected is not stored yet
+	over := count + 1 - int64(d.limit)
+	if over <= 0 {
+		return 0, nil
+	}
+	return math.Min(1, float64(over)/float64(d.limit)), nil
+}
+
+// minHostObservations is how many reviews a host needs before its reputation counts
+const minHostObservations = 3
+
+// linkDetector scores the links of a subject by their hosts. blocked hosts are spam,
+// trusted hosts are ignored and the others score the share of spam among the reviewed documents linking to them
+type linkDetector struct {
+	repo    Repository
+	blocked []string
+	trusted []string
+}
+
+func (d linkDetector) Name() string {
+	return "link"
+}
+
+func (d linkDetector) Inspect(ctx context.Context, subject Subject) (float64, error) {
+	var unknown []string
+	for _, host := range subject.Hosts {
+		if matchHost(host, d.trusted) {
+			continue
+		}
+		if matchHost(host, d.blocked) {
+			return 1, nil
+		}
+		unknown = append(unknown, hostPrefix+host)
+	}
+	if len(unknown) == 0 {
+		return 0, nil
+	}
+
+	reputations, err := d.repo.GetFeatures(ctx, unknown)
+	if err != nil {
+		return 0, err
+	}
+
+	score := 0.0
+	for _, reputation := range reputations {
+		observed := reputation.Spam + reputation.Ham
+		if observed < minHostObservations {
+			continue
+		}
+		score = math.Max(score, float64(reputation.Spam)/float64(observed))
+	}
+	return score, nil
+}
+
+// matchHost tells if the host is one of the domains or their subdomain
+func matchHost(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// both spam and ham need this many reviewed documents before the classifier scores anything
+	minTrainingDocuments = 10
+	// the tokens farthest from neutral decide the score
+	interestingTokens = 15
+)
+
+// bayesDetector is a naive bayes classifier of the words of a subject trained by the reviews of quarantined documents
+type bayesDetector struct {
+	repo Repository
+}
+
+func (d bayesDetector) Name() string {
+	return "bayes"
+}
+
+func (d bayesDetector) Inspect(ctx context.Context, subject Subject) (float64, error) {
+	tokens := tokenize(subject.Text)
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	keys := []string{documentsFeature}
+	for _, token := range tokens {
+		keys = append(keys, wordPrefix+token)
+	}
+	counts, err := d.repo.GetFeatures(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	documents := counts[documentsFeature]
+	if documents.Spam < minTrainingDocuments || documents.Ham < minTrainingDocuments {
+		return 0, nil
+	}
+
+	var probabilities []float64
+	for _, token := range tokens {
+		count, ok := counts[wordPrefix+token]
+		if !ok {
+			continue
+		}
+		probabilities = append(probabilities, tokenProbability(count.Spam, count.Ham, documents.Spam, documents.Ham))
+	}
+
+	return combine(probabilities), nil
+}
+
+// tokenProbability is the probability that a document containing the token is spam,
+// smoothed toward 0.5 for rarely seen tokens
+func tokenProbability(spam, ham, spamDocuments, hamDocuments int64) float64 {
+	const strength, neutral = 1.0, 0.5
+
+	spamRate := float64(spam) / float64(spamDocuments)
+	hamRate := float64(ham) / float64(hamDocuments)
+	if spamRate+hamRate == 0 {
+		return neutral
+	}
+	p := spamRate / (spamRate + hamRate)
+	n := float64(spam + ham)
+	return (strength*neutral + n*p) / (strength + n)
+}
+
+// combine joins the probabilities of the most interesting tokens into the probability of the document
+func combine(probabilities []float64) float64 {
+	if len(probabilities) == 0 {
+		return 0
+	}
+	sort.Slice(probabilities, func(i, j int) bool {
+		return math.Abs(probabilities[i]-0.5) > math.Abs(probabilities[j]-0.5)
+	})
+	if len(probabilities) > interestingTokens {
+		probabilities = probabilities[:interestingTokens]
+	}
+
+	// in log space so that many tokens do not underflow
+	eta := 0.0
+	for _, p := range probabilities {
+		p = math.Min(math.Max(p, 0.01), 0.99)
+		eta += math.Log(1-p) - math.Log(p)
+	}
+	return 1 / (1 + math.Exp(eta))
+}
+
+const (
+	minTokenLength = 2
+	maxTokenLength = 32
+)
+
+// tokenize returns the lowercased words of the text without duplicates.
+// runs of CJK characters, which are not separated by spaces, are split into character bigrams
+func tokenize(text string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	add := func(token string) {
+		length := utf8.RuneCountInString(token)
+		if length < minTokenLength || length > maxTokenLength || seen[token] {
+			return
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		runes := []rune(word)
+		start := 0
+		for start < len(runes) {
+			end := start + 1
+			cjk := isCJK(runes[start])
+			for end < len(runes) && isCJK(runes[end]) == cjk {
+				end++
+			}
+			if cjk {
+				for i := start; i+1 < end; i++ {
+					add(string(runes[i : i+2]))
+				}
+			} else {
+				add(string(runes[start:end]))
+			}
+			start = end
+		}
+	}
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+var linkRegex = regexp.MustCompile(`https?://[^\s<>"'()]+`)
+
+// linkHosts returns the lowercased hosts of the links in the text without duplicates
+func linkHosts(text string) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, link := range linkRegex.FindAllString(text, -1) {
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
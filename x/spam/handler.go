@@ -0,0 +1,85 @@
+package spam
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	List(c echo.Context) error
+	Release(c echo.Context) error
+	Reject(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// List returns the quarantined documents waiting for review, oldest first
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Spam.Handler.List")
+	defer span.End()
+
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid limit"})
+		}
+	}
+
+	docs, err := h.service.ListQuarantined(ctx, limit)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": docs})
+}
+
+// Release commits a quarantined document that is not spam
+func (h handler) Release(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Spam.Handler.Release")
+	defer span.End()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid id"})
+	}
+
+	result, err := h.service.Release(ctx, uint(id))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": result})
+}
+
+// Reject drops a quarantined document as spam
+func (h handler) Reject(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Spam.Handler.Reject")
+	defer span.End()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid id"})
+	}
+
+	err = h.service.Reject(ctx, uint(id))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
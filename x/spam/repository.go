@@ -0,0 +1,151 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for spam repository
+type Repository interface {
+	CountMessagesSince(ctx context.Context, author string, since time.Time) (int64, error)
+	GetFeatures(ctx context.Context, features []string) (map[string]core.SpamFeature, error)
+	Train(ctx context.Context, features []string, spam bool) error
+	Quarantine(ctx context.Context, doc core.QuarantinedDocument) (core.QuarantinedDocument, error)
+	ListQuarantined(ctx context.Context, limit int) ([]core.QuarantinedDocument, error)
+	GetQuarantined(ctx context.Context, id uint) (core.QuarantinedDocument, error)
+	DeleteQuarantined(ctx context.Context, id uint) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new spam repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// CountMessagesSince counts the messages the author created after since
+func (r *repository) CountMessagesSince(ctx context.Context, author string, since time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.CountMessagesSince")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Message{}).Where("author = ? AND c_date >= ?", author, since).Count(&count).Error
+	return count, err
+}
+
+// GetFeatures returns the counts of the features. features never trained are not in the result
+func (r *repository) GetFeatures(ctx context.Context, features []string) (map[string]core.SpamFeature, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.GetFeatures")
+	defer span.End()
+
+	var rows []core.SpamFeature
+	err := r.db.WithContext(ctx).Where("feature IN ?", features).Find(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result := make(map[string]core.SpamFeature, len(rows))
+	for _, row := range rows {
+		result[row.Feature] = row
+	}
+	return result, nil
+}
+
+// Train counts the features as seen once more in spam or ham
+func (r *repository) Train(ctx context.Context, features []string, spam bool) error {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.Train")
+	defer span.End()
+
+	if len(features) == 0 {
+		return nil
+	}
+
+	column := "ham"
+	if spam {
+		column = "spam"
+	}
+	// features must be unique, a row can't be updated twice in one statement
+	rows := make([]core.SpamFeature, len(features))
+	for i, feature := range features {
+		rows[i] = core.SpamFeature{Feature: feature}
+		if spam {
+			rows[i].Spam = 1
+		} else {
+			rows[i].Ham = 1
+		}
+	}
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "feature"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			column: gorm.Expr("spam_features."+column+" + ?", 1),
+		}),
+	}).Create(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *repository) Quarantine(ctx context.Context, doc core.QuarantinedDocument) (core.QuarantinedDocument, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.Quarantine")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Create(&doc).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return doc, err
+}
+
+// ListQuarantined returns the quarantined documents, oldest first
+func (r *repository) ListQuarantined(ctx context.Context, limit int) ([]core.QuarantinedDocument, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.ListQuarantined")
+	defer span.End()
+
+	var docs []core.QuarantinedDocument
+	err := r.db.WithContext(ctx).Order("id ASC").Limit(limit).Find(&docs).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return docs, err
+}
+
+func (r *repository) GetQuarantined(ctx context.Context, id uint) (core.QuarantinedDocument, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.GetQuarantined")
+	defer span.End()
+
+	var doc core.QuarantinedDocument
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&doc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return doc, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+	}
+	return doc, err
+}
+
+func (r *repository) DeleteQuarantined(ctx context.Context, id uint) error {
+	ctx, span := tracer.Start(ctx, "Spam.Repository.DeleteQuarantined")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&core.QuarantinedDocument{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return core.NewErrorNotFound()
+	}
+	return nil
+}
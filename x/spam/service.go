@@ -0,0 +1,330 @@
+// Package spam scores committed messages with pluggable detectors.
+// suspicious messages pass with the verdict available to policies, likely spam is quarantined for admins to review
+package spam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("spam")
+
+const (
+	defaultFlagThreshold       = 0.5
+	defaultQuarantineThreshold = 0.9
+	defaultRateLimit           = 30
+	defaultRateWindow          = 10 * time.Minute
+
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// Config configures the detectors and what their verdicts lead to
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// messages scoring at least FlagThreshold are labeled suspicious, at least QuarantineThreshold spam
+	FlagThreshold       float64 `yaml:"flagThreshold"`
+	QuarantineThreshold float64 `yaml:"quarantineThreshold"`
+	// posting more than RateLimit messages in RateWindow is suspicious
+	RateLimit      int           `yaml:"rateLimit"`
+	RateWindow     time.Duration `yaml:"rateWindow"`
+	BlockedDomains []string      `yaml:"blockedDomains"`
+	TrustedDomains []string      `yaml:"trustedDomains"`
+	// Weights scales the score of each detector by name. unset detectors weigh 1, 0 disables one
+	Weights map[string]float64 `yaml:"weights"`
+}
+
+// NewDetectors creates the builtin detectors: rate, link and bayes
+func NewDetectors(repo Repository, config Config) []Detector {
+	limit := config.RateLimit
+	if limit <= 0 {
+		limit = defaultRateLimit
+	}
+	window := config.RateWindow
+	if window <= 0 {
+		window = defaultRateWindow
+	}
+
+	return []Detector{
+		rateDetector{repo: repo, limit: limit, window: window, now: time.Now},
+		linkDetector{repo: repo, blocked: config.BlockedDomains, trusted: config.TrustedDomains},
+		bayesDetector{repo: repo},
+	}
+}
+
+// Service is the interface for spam service
+type Service interface {
+	core.CommitHook
+	Inspect(ctx context.Context, subject Subject) core.SpamVerdict
+	ListQuarantined(ctx context.Context, limit int) ([]core.QuarantinedDocument, error)
+	Release(ctx context.Context, id uint) (any, error)
+	Reject(ctx context.Context, id uint) error
+}
+
+type service struct {
+	repo      Repository
+	store     core.StoreService
+	detectors []Detector
+	config    Config
+}
+
+// NewService creates a new spam service. it inspects the commits of the store once registered as its hook
+func NewService(repo Repository, store core.StoreService, detectors []Detector, config Config) Service {
+	if config.FlagThreshold <= 0 {
+		config.FlagThreshold = defaultFlagThreshold
+	}
+	if config.QuarantineThreshold <= 0 {
+		config.QuarantineThreshold = defaultQuarantineThreshold
+	}
+	return &service{repo, store, detectors, config}
+}
+
+// subjectOf extracts what the detectors see of a document
+func subjectOf(doc core.DocumentBase[any]) Subject {
+	var texts []string
+	collectStrings(doc.Body, &texts)
+	text := strings.Join(texts, "\n")
+	return Subject{
+		Signer: doc.Signer,
+		Text:   text,
+		Hosts:  linkHosts(text),
+	}
+}
+
+func collectStrings(value any, texts *[]string) {
+	switch v := value.(type) {
+	case string:
+		*texts = append(*texts, v)
+	case []any:
+		for _, item := range v {
+			collectStrings(item, texts)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectStrings(v[key], texts)
+		}
+	}
+}
+
+// Inspect scores the subject with every detector. the scores are combined as independent signals:
+// the document is ham only if every detector finds it ham.
+// detection is best effort, a failing detector is left out
+func (s *service) Inspect(ctx context.Context, subject Subject) core.SpamVerdict {
+	ctx, span := tracer.Start(ctx, "Spam.Service.Inspect")
+	defer span.End()
+
+	details := make(map[string]float64, len(s.detectors))
+	ham := 1.0
+	for _, detector := range s.detectors {
+		weight, ok := s.config.Weights[detector.Name()]
+		if !ok {
+			weight = 1
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		score, err := detector.Inspect(ctx, subject)
+		if err != nil {
+			span.RecordError(err)
+			logging.Warn(ctx, "spam detector failed", slog.String("module", "spam"), slog.String("detector", detector.Name()), slog.String("error", err.Error()))
+			continue
+		}
+		details[detector.Name()] = score
+		ham *= 1 - math.Min(1, math.Max(0, score*weight))
+	}
+
+	verdict := core.SpamVerdict{
+		Score:   1 - ham,
+		Label:   core.SpamLabelHam,
+		Details: details,
+	}
+	switch {
+	case verdict.Score >= s.config.QuarantineThreshold:
+		verdict.Label = core.SpamLabelSpam
+	case verdict.Score >= s.config.FlagThreshold:
+		verdict.Label = core.SpamLabelSuspicious
+	}
+
+	span.SetAttributes(attribute.Float64("score", verdict.Score), attribute.String("label", verdict.Label))
+	return verdict
+}
+
+// BeforeCommit inspects messages committed to the domain. spam is quarantined instead of committed,
+// the verdict on the others is put on the context for the policies of the timelines to consume
+func (s *service) BeforeCommit(ctx context.Context, mode core.CommitMode, document, signature, option string, keys []core.Key) (context.Context, error) {
+	if mode != core.CommitModeExecute {
+		return ctx, nil
+	}
+	// documents released from quarantine carry the verdict of the review
+	if _, ok := ctx.Value(core.SpamVerdictCtxKey).(core.SpamVerdict); ok {
+		return ctx, nil
+	}
+
+	var doc core.DocumentBase[any]
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil || doc.Type != "message" {
+		return ctx, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Spam.Service.BeforeCommit")
+	defer span.End()
+
+	verdict := s.Inspect(ctx, subjectOf(doc))
+	if verdict.Label != core.SpamLabelSpam {
+		return context.WithValue(ctx, core.SpamVerdictCtxKey, verdict), nil
+	}
+
+	quarantined := core.QuarantinedDocument{
+		Type:      doc.Type,
+		Signer:    doc.Signer,
+		Document:  document,
+		Signature: signature,
+		Option:    option,
+		Score:     verdict.Score,
+	}
+	if len(keys) > 0 {
+		keysJSON, err := json.Marshal(keys)
+		if err != nil {
+			span.RecordError(err)
+			return ctx, err
+		}
+		quarantined.Keys = string(keysJSON)
+	}
+	verdictJSON, err := json.Marshal(verdict)
+	if err != nil {
+		span.RecordError(err)
+		return ctx, err
+	}
+	quarantined.Verdict = string(verdictJSON)
+
+	quarantined, err = s.repo.Quarantine(ctx, quarantined)
+	if err != nil {
+		span.RecordError(err)
+		return ctx, err
+	}
+
+	logging.Info(
+		ctx, "quarantined a document",
+		slog.String("type", "audit"),
+		slog.String("principal", doc.Signer),
+		slog.Uint64("quarantine", uint64(quarantined.ID)),
+		slog.Float64("score", verdict.Score),
+		slog.String("module", "spam"),
+	)
+
+	return ctx, fmt.Errorf("%w: the message is held for review", core.NewErrorPolicyDenied("spam"))
+}
+
+func (s *service) ListQuarantined(ctx context.Context, limit int) ([]core.QuarantinedDocument, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Service.ListQuarantined")
+	defer span.End()
+
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	return s.repo.ListQuarantined(ctx, min(limit, MaxListLimit))
+}
+
+// Release commits a quarantined document reviewed as ham and trains the detectors with it
+func (s *service) Release(ctx context.Context, id uint) (any, error) {
+	ctx, span := tracer.Start(ctx, "Spam.Service.Release")
+	defer span.End()
+
+	quarantined, doc, err := s.get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var keys []core.Key
+	if quarantined.Keys != "" {
+		err = json.Unmarshal([]byte(quarantined.Keys), &keys)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	reviewed := core.SpamVerdict{Score: 0, Label: core.SpamLabelHam}
+	result, err := s.store.Commit(
+		context.WithValue(ctx, core.SpamVerdictCtxKey, reviewed),
+		core.CommitModeExecute,
+		quarantined.Document,
+		quarantined.Signature,
+		quarantined.Option,
+		keys,
+		"",
+	)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return result, s.review(ctx, quarantined.ID, doc, false)
+}
+
+// Reject drops a quarantined document reviewed as spam and trains the detectors with it
+func (s *service) Reject(ctx context.Context, id uint) error {
+	ctx, span := tracer.Start(ctx, "Spam.Service.Reject")
+	defer span.End()
+
+	quarantined, doc, err := s.get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return s.review(ctx, quarantined.ID, doc, true)
+}
+
+func (s *service) get(ctx context.Context, id uint) (core.QuarantinedDocument, core.DocumentBase[any], error) {
+	quarantined, err := s.repo.GetQuarantined(ctx, id)
+	if err != nil {
+		return core.QuarantinedDocument{}, core.DocumentBase[any]{}, err
+	}
+
+	var doc core.DocumentBase[any]
+	err = json.Unmarshal([]byte(quarantined.Document), &doc)
+	if err != nil {
+		return core.QuarantinedDocument{}, core.DocumentBase[any]{}, err
+	}
+	return quarantined, doc, nil
+}
+
+// review removes the document from quarantine and trains the detectors with the decision
+func (s *service) review(ctx context.Context, id uint, doc core.DocumentBase[any], spam bool) error {
+	err := s.repo.Train(ctx, features(subjectOf(doc)), spam)
+	if err != nil {
+		return err
+	}
+
+	requester, _ := ctx.Value(core.RequesterIdCtxKey).(string)
+	logging.Info(
+		ctx, "reviewed a quarantined document",
+		slog.String("type", "audit"),
+		slog.String("principal", requester),
+		slog.Uint64("quarantine", uint64(id)),
+		slog.Bool("spam", spam),
+		slog.String("module", "spam"),
+	)
+
+	return s.repo.DeleteQuarantined(ctx, id)
+}
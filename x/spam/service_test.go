@@ -0,0 +1,155 @@
+package spam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+type memoryRepository struct {
+	messages    int64
+	features    map[string]core.SpamFeature
+	quarantined []core.QuarantinedDocument
+}
+
+func (r *memoryRepository) CountMessagesSince(ctx context.Context, author string, since time.Time) (int64, error) {
+	return r.messages, nil
+}
+
+func (r *memoryRepository) GetFeatures(ctx context.Context, features []string) (map[string]core.SpamFeature, error) {
+	result := make(map[string]core.SpamFeature)
+	for _, feature := range features {
+		if row, ok := r.features[feature]; ok {
+			result[feature] = row
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) Train(ctx context.Context, features []string, spam bool) error {
+	for _, feature := range features {
+		row := r.features[feature]
+		row.Feature = feature
+		if spam {
+			row.Spam++
+		} else {
+			row.Ham++
+		}
+		r.features[feature] = row
+	}
+	return nil
+}
+
+func (r *memoryRepository) Quarantine(ctx context.Context, doc core.QuarantinedDocument) (core.QuarantinedDocument, error) {
+	doc.ID = uint(len(r.quarantined) + 1)
+	r.quarantined = append(r.quarantined, doc)
+	return doc, nil
+}
+
+func (r *memoryRepository) ListQuarantined(ctx context.Context, limit int) ([]core.QuarantinedDocument, error) {
+	return r.quarantined, nil
+}
+
+func (r *memoryRepository) GetQuarantined(ctx context.Context, id uint) (core.QuarantinedDocument, error) {
+	for _, doc := range r.quarantined {
+		if doc.ID == id {
+			return doc, nil
+		}
+	}
+	return core.QuarantinedDocument{}, core.NewErrorNotFound()
+}
+
+func (r *memoryRepository) DeleteQuarantined(ctx context.Context, id uint) error {
+	for i, doc := range r.quarantined {
+		if doc.ID == id {
+			r.quarantined = append(r.quarantined[:i], r.quarantined[i+1:]...)
+			return nil
+		}
+	}
+	return core.NewErrorNotFound()
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"buy", "cheap", "pills", "now"}, tokenize("Buy CHEAP pills now! buy now, a"))
+	assert.Equal(t, []string{"今日", "日は", "は晴", "晴れ", "concrnt"}, tokenize("今日は晴れ concrnt"))
+}
+
+func TestLinkHosts(t *testing.T) {
+	hosts := linkHosts("see https://Spam.Example.com/a?b=c and (http://other.example.org.) https://spam.example.com/x")
+	assert.Equal(t, []string{"spam.example.com", "other.example.org"}, hosts)
+}
+
+func TestDetectors(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepository{features: map[string]core.SpamFeature{}}
+
+	rate := rateDetector{repo: repo, limit: 10, window: time.Minute, now: time.Now}
+	repo.messages = 5
+	score, err := rate.Inspect(ctx, Subject{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, score)
+	repo.messages = 14
+	score, _ = rate.Inspect(ctx, Subject{})
+	assert.Equal(t, 0.5, score)
+
+	link := linkDetector{repo: repo, blocked: []string{"spam.example.com"}, trusted: []string{"concrnt.world"}}
+	score, _ = link.Inspect(ctx, Subject{Hosts: []string{"concrnt.world", "cdn.spam.example.com"}})
+	assert.Equal(t, 1.0, score)
+	score, _ = link.Inspect(ctx, Subject{Hosts: []string{"unknown.example.net"}})
+	assert.Equal(t, 0.0, score)
+
+	// the classifier needs training data of both kinds
+	bayes := bayesDetector{repo: repo}
+	spam := Subject{Text: "buy cheap pills now", Hosts: []string{"pills.example.net"}}
+	ham := Subject{Text: "lunch with friends today"}
+	score, _ = bayes.Inspect(ctx, spam)
+	assert.Equal(t, 0.0, score)
+
+	for i := 0; i < minTrainingDocuments; i++ {
+		assert.NoError(t, repo.Train(ctx, features(spam), true))
+		assert.NoError(t, repo.Train(ctx, features(ham), false))
+	}
+
+	score, _ = bayes.Inspect(ctx, Subject{Text: "cheap pills"})
+	assert.Greater(t, score, 0.9)
+	score, _ = bayes.Inspect(ctx, Subject{Text: "friends today"})
+	assert.Less(t, score, 0.1)
+
+	// reviews build the reputation of hosts
+	score, _ = link.Inspect(ctx, Subject{Hosts: []string{"pills.example.net"}})
+	assert.Equal(t, 1.0, score)
+}
+
+func TestBeforeCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepository{features: map[string]core.SpamFeature{}}
+	s := NewService(repo, nil, []Detector{linkDetector{repo: repo, blocked: []string{"spam.example.com"}}}, Config{})
+
+	message := `{"signer":"con1alice","type":"message","body":{"body":"hello"}}`
+	next, err := s.BeforeCommit(ctx, core.CommitModeExecute, message, "sig", "", nil)
+	assert.NoError(t, err)
+	verdict, ok := next.Value(core.SpamVerdictCtxKey).(core.SpamVerdict)
+	assert.True(t, ok)
+	assert.Equal(t, core.SpamLabelHam, verdict.Label)
+
+	spam := `{"signer":"con1alice","type":"message","body":{"body":"visit https://spam.example.com/"}}`
+	_, err = s.BeforeCommit(ctx, core.CommitModeExecute, spam, "sig", "", []core.Key{{ID: "cck1"}})
+	assert.ErrorIs(t, err, core.ErrorPolicyDenied{})
+	if assert.Len(t, repo.quarantined, 1) {
+		assert.Equal(t, "con1alice", repo.quarantined[0].Signer)
+		assert.Contains(t, repo.quarantined[0].Keys, "cck1")
+	}
+
+	// replays and other documents are not inspected
+	next, err = s.BeforeCommit(ctx, core.CommitModeLocalOnlyExec, spam, "sig", "", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, next.Value(core.SpamVerdictCtxKey))
+
+	assert.NoError(t, s.Reject(ctx, 1))
+	assert.Empty(t, repo.quarantined)
+	assert.Equal(t, int64(1), repo.features[hostPrefix+"spam.example.com"].Spam)
+}
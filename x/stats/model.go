@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"time"
+)
+
+// Stats is a snapshot of the activity of the domain
+type Stats struct {
+	Resources   map[string]int64      `json:"resources"`
+	DailyRates  map[string]int64      `json:"dailyRates"` // created in the last 24 hours
+	Federation  FederationStats       `json:"federation"`
+	Caches      map[string]CacheStats `json:"caches"`
+	Realtime    RealtimeStats         `json:"realtime"`
+	Queues      map[string]int64      `json:"queues"`
+	GeneratedAt time.Time             `json:"generatedAt"`
+}
+
+// FederationStats summarizes the remote domains
+type FederationStats struct {
+	Peers               int64 `json:"peers"`
+	Online              int   `json:"online"`
+	Offline             int   `json:"offline"`
+	Probing             int   `json:"probing"`
+	RemoteFetchFailures int64 `json:"remoteFetchFailures"`
+}
+
+// CacheStats counts the lookups of a cache since the process started
+type CacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hitRatio"`
+}
+
+// RealtimeStats counts the websocket connections of this process
+type RealtimeStats struct {
+	Connections         int64 `json:"connections"`
+	RemoteSubscriptions int64 `json:"remoteSubscriptions"`
+	RemoteConnections   int64 `json:"remoteConnections"`
+}
@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// Repository is the interface for stats repository
+type Repository interface {
+	CountCreatedSince(ctx context.Context, model any, since time.Time) (int64, error)
+	CountDomains(ctx context.Context) (int64, error)
+	CountPendingJobs(ctx context.Context) (int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new stats repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// CountCreatedSince returns the number of rows of the model created since the time
+func (r *repository) CountCreatedSince(ctx context.Context, model any, since time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Stats.Repository.CountCreatedSince")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(model).Where("c_date >= ?", since).Count(&count).Error
+	return count, err
+}
+
+// CountDomains returns the number of known remote domains
+func (r *repository) CountDomains(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Stats.Repository.CountDomains")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Domain{}).Count(&count).Error
+	return count, err
+}
+
+// CountPendingJobs returns the number of jobs waiting to run
+func (r *repository) CountPendingJobs(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Stats.Repository.CountPendingJobs")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Job{}).Where("status = ?", "pending").Count(&count).Error
+	return count, err
+}
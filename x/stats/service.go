@@ -0,0 +1,181 @@
+// Package stats aggregates the counters of the other modules for the operator dashboard
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("stats")
+
+// counting every table is not cheap, so the stats are reused for a while
+const cacheTTL = 30 * time.Second
+
+// Counter is anything which counts its resources
+type Counter interface {
+	Count(ctx context.Context) (int64, error)
+}
+
+// QueueProbe returns the depth of a queue
+type QueueProbe func(ctx context.Context) (int64, error)
+
+// Service is the interface for stats service
+type Service interface {
+	Get(ctx context.Context) (Stats, error)
+	Queues(ctx context.Context) (map[string]int64, error)
+}
+
+type service struct {
+	repo     Repository
+	counters map[string]Counter
+	timeline core.TimelineService
+	domain   core.DomainService
+	queues   map[string]QueueProbe
+
+	mu     sync.Mutex
+	cached *Stats
+}
+
+// NewService creates a new stats service
+func NewService(
+	repo Repository,
+	counters map[string]Counter,
+	timeline core.TimelineService,
+	domain core.DomainService,
+	queues map[string]QueueProbe,
+) Service {
+	return &service{
+		repo:     repo,
+		counters: counters,
+		timeline: timeline,
+		domain:   domain,
+		queues:   queues,
+	}
+}
+
+// Get returns the current stats of the domain
+func (s *service) Get(ctx context.Context) (Stats, error) {
+	ctx, span := tracer.Start(ctx, "Stats.Service.Get")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.cached.GeneratedAt) < cacheTTL {
+		return *s.cached, nil
+	}
+
+	stats, err := s.collect(ctx, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return Stats{}, err
+	}
+	s.cached = &stats
+	return stats, nil
+}
+
+func (s *service) collect(ctx context.Context, now time.Time) (Stats, error) {
+	stats := Stats{
+		Resources:   map[string]int64{},
+		DailyRates:  map[string]int64{},
+		Caches:      map[string]CacheStats{},
+		GeneratedAt: now,
+	}
+
+	for name, counter := range s.counters {
+		count, err := counter.Count(ctx)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to count %s: %w", name, err)
+		}
+		stats.Resources[name] = count
+	}
+
+	since := now.Add(-24 * time.Hour)
+	for name, model := range map[string]any{
+		"message":     &core.Message{},
+		"association": &core.Association{},
+		"entity":      &core.Entity{},
+	} {
+		count, err := s.repo.CountCreatedSince(ctx, model, since)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to count recent %s: %w", name, err)
+		}
+		stats.DailyRates[name] = count
+	}
+
+	peers, err := s.repo.CountDomains(ctx)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to count domains: %w", err)
+	}
+	stats.Federation.Peers = peers
+	for _, peer := range s.domain.FederationStats(ctx) {
+		switch peer.State {
+		case "online":
+			stats.Federation.Online++
+		case "offline":
+			stats.Federation.Offline++
+		case "probing":
+			stats.Federation.Probing++
+		}
+	}
+
+	metrics := s.timeline.GetMetrics()
+	stats.Caches["chunkItr"] = newCacheStats(metrics["lookup_chunk_itr_cache_hits"], metrics["lookup_chunk_itr_cache_misses"])
+	stats.Caches["chunkBody"] = newCacheStats(metrics["load_chunk_bodies_cache_hits"], metrics["load_chunk_bodies_cache_misses"])
+	stats.Federation.RemoteFetchFailures = metrics["remote_chunk_fetch_failures"]
+	stats.Realtime = RealtimeStats{
+		Connections:         metrics["realtime_connections"],
+		RemoteSubscriptions: metrics["remoteSubs"],
+		RemoteConnections:   metrics["remoteConns"],
+	}
+
+	stats.Queues, err = s.Queues(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// Queues returns the depth of the queues of the domain.
+// it is cheap compared to Get, so the metrics read it on every collection
+func (s *service) Queues(ctx context.Context) (map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "Stats.Service.Queues")
+	defer span.End()
+
+	queues := map[string]int64{}
+
+	pending, err := s.repo.CountPendingJobs(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to count pending jobs: %w", err)
+	}
+	queues["job"] = pending
+	for name, probe := range s.queues {
+		depth, err := probe(ctx)
+		if err != nil {
+			// a queue we can't see shouldn't hide the rest of the dashboard
+			logging.Warn(ctx, "failed to probe queue", slog.String("module", "stats"), slog.String("queue", name), slog.String("error", err.Error()))
+			continue
+		}
+		queues[name] = depth
+	}
+
+	return queues, nil
+}
+
+func newCacheStats(hits, misses int64) CacheStats {
+	stats := CacheStats{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		stats.HitRatio = float64(hits) / float64(total)
+	}
+	return stats
+}
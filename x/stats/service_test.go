@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+type fakeRepository struct{}
+
+func (fakeRepository) CountCreatedSince(_ context.Context, model any, _ time.Time) (int64, error) {
+	if _, ok := model.(*core.Message); ok {
+		return 42, nil
+	}
+	return 1, nil
+}
+
+func (fakeRepository) CountDomains(context.Context) (int64, error)     { return 3, nil }
+func (fakeRepository) CountPendingJobs(context.Context) (int64, error) { return 2, nil }
+
+func TestGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	message := mock_core.NewMockMessageService(ctrl)
+	message.EXPECT().Count(gomock.Any()).Return(int64(100), nil).Times(1)
+
+	timeline := mock_core.NewMockTimelineService(ctrl)
+	timeline.EXPECT().GetMetrics().Return(map[string]int64{
+		"lookup_chunk_itr_cache_hits":   3,
+		"lookup_chunk_itr_cache_misses": 1,
+		"realtime_connections":          5,
+	}).Times(1)
+
+	domain := mock_core.NewMockDomainService(ctrl)
+	domain.EXPECT().FederationStats(gomock.Any()).Return([]core.FederationStats{
+		{Domain: "a.example.com", State: "online"},
+		{Domain: "b.example.com", State: "offline"},
+	}).Times(1)
+
+	service := NewService(
+		fakeRepository{},
+		map[string]Counter{"message": message},
+		timeline,
+		domain,
+		map[string]QueueProbe{
+			"delivery": func(context.Context) (int64, error) { return 7, nil },
+			"broken":   func(context.Context) (int64, error) { return 0, errors.New("unreachable") },
+		},
+	)
+
+	stats, err := service.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), stats.Resources["message"])
+	assert.Equal(t, int64(42), stats.DailyRates["message"])
+	assert.Equal(t, FederationStats{Peers: 3, Online: 1, Offline: 1}, stats.Federation)
+	assert.Equal(t, CacheStats{Hits: 3, Misses: 1, HitRatio: 0.75}, stats.Caches["chunkItr"])
+	assert.Equal(t, CacheStats{}, stats.Caches["chunkBody"])
+	assert.Equal(t, int64(5), stats.Realtime.Connections)
+	assert.Equal(t, map[string]int64{"job": 2, "delivery": 7}, stats.Queues)
+
+	// served from the cache without asking the sources again
+	cached, err := service.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, stats, cached)
+}
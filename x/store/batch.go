@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/cdid"
+	"github.com/totegamma/concurrent/core"
+)
+
+// MaxBatchCommits is the number of documents accepted by a single batch commit
+const MaxBatchCommits = 1000
+
+// CommitAssociations executes many association documents at once, for imports.
+// each document is validated like a single commit, then the association service stores the valid ones
+// in a single transaction without distributing them to timelines.
+// a document failing does not fail the batch: the result of each one is reported at its index
+func (s *service) CommitAssociations(ctx context.Context, commits []core.Commit, keys []core.Key, IP string) ([]core.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "Store.Service.CommitAssociations")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("documents", len(commits)))
+
+	if len(commits) == 0 {
+		return []core.BatchResult{}, nil
+	}
+	if len(commits) > MaxBatchCommits {
+		return nil, core.NewErrorInvalidArgument(fmt.Sprintf("a batch holds at most %d documents", MaxBatchCommits))
+	}
+
+	results := make([]core.BatchResult, len(commits))
+	valid := make([]core.Commit, 0, len(commits))
+	indexes := make([]int, 0, len(commits))
+	for i, commit := range commits {
+		err := s.checkBatchDocument(ctx, commit, keys)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, commit)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	created, err := s.association.CreateBatch(ctx, valid)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	for j, result := range created {
+		results[indexes[j]] = result
+		if result.Error != "" {
+			continue
+		}
+
+		commit := valid[j]
+		var base core.DocumentBase[any]
+		json.Unmarshal([]byte(commit.Document), &base)
+
+		hash := core.GetHash([]byte(commit.Document))
+		hash10 := [10]byte{}
+		copy(hash10[:], hash[:10])
+
+		_, err = s.repo.Log(ctx, core.CommitLog{
+			IP:         IP,
+			DocumentID: cdid.New(hash10, base.SignedAt).String(),
+			Type:       base.Type,
+			Document:   commit.Document,
+			Signature:  commit.Signature,
+			SignedAt:   base.SignedAt,
+			Owners:     s.localOwners(ctx, []string{base.Signer}),
+		}, s.durability)
+		if err != nil {
+			// the association is stored, only the repository of the signer misses it
+			span.RecordError(err)
+			results[indexes[j]].Error = err.Error()
+			continue
+		}
+
+		for _, listener := range s.listeners {
+			listener.AfterCommit(ctx, core.CommitModeExecute, base.Type, commit.Document, commit.Signature)
+		}
+	}
+
+	return results, nil
+}
+
+// checkBatchDocument runs the checks of Commit on a document of a batch
+func (s *service) checkBatchDocument(ctx context.Context, commit core.Commit, keys []core.Key) error {
+	err := checkLimits(commit.Document, s.limits)
+	if err != nil {
+		return err
+	}
+
+	var base core.DocumentBase[any]
+	err = json.Unmarshal([]byte(commit.Document), &base)
+	if err != nil {
+		return err
+	}
+	if base.Type != "association" {
+		return core.NewErrorInvalidArgument("only association documents are accepted in a batch")
+	}
+
+	err = s.ValidateDocument(ctx, commit.Document, commit.Signature, keys)
+	if err != nil {
+		return err
+	}
+
+	err = s.auth.AuthorizeCommit(ctx, commit.Document)
+	if err != nil {
+		return err
+	}
+
+	if base.Body != nil {
+		err = s.validator.Validate(ctx, base.Schema, base.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range s.hooks {
+		_, err = hook.BeforeCommit(ctx, core.CommitModeExecute, commit.Document, commit.Signature, commit.Option, keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
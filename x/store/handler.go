@@ -3,6 +3,8 @@ package store
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
@@ -16,10 +18,12 @@ var tracer = otel.Tracer("store")
 
 type Handler interface {
 	Commit(c echo.Context) error
+	CommitAssociations(c echo.Context) error
 	Get(c echo.Context) error
 	Post(c echo.Context) error
 	GetSyncStatus(c echo.Context) error
 	PerformSync(c echo.Context) error
+	Compact(c echo.Context) error
 }
 
 type handler struct {
@@ -49,14 +53,12 @@ func (h *handler) Commit(c echo.Context) error {
 	err := c.Bind(&request)
 	if err != nil {
 		span.RecordError(err)
+		if errors.Is(err, core.ErrorTooLarge{}) {
+			return err
+		}
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
-	// limit document size 8KB
-	if len(request.Document) > 8192 {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Document size is too large"})
-	}
-
 	keys, ok := ctx.Value(core.RequesterKeychainKey).([]core.Key)
 	if !ok {
 		keys = []core.Key{}
@@ -66,9 +68,6 @@ func (h *handler) Commit(c echo.Context) error {
 
 	result, err := h.service.Commit(ctx, core.CommitModeExecute, request.Document, request.Signature, request.Option, keys, requesterIP)
 	if err != nil {
-		if errors.Is(err, core.ErrorPermissionDenied{}) {
-			return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "error": err.Error()})
-		}
 		if errors.Is(err, core.ErrorAlreadyExists{}) {
 			return c.JSON(http.StatusOK, echo.Map{"status": "processed", "content": result})
 		}
@@ -77,12 +76,44 @@ func (h *handler) Commit(c echo.Context) error {
 		}
 
 		span.RecordError(err)
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, echo.Map{"status": "ok", "content": result})
 }
 
+// BatchCommitRequest is a batch of signed documents committed at once
+type BatchCommitRequest struct {
+	Commits []core.Commit `json:"commits"`
+}
+
+// CommitAssociations creates many associations at once, e.g. reactions imported from another platform.
+// the response lists the result of each document in the order of the request
+func (h *handler) CommitAssociations(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Store.Handler.CommitAssociations")
+	defer span.End()
+
+	var request BatchCommitRequest
+	err := c.Bind(&request)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	keys, ok := ctx.Value(core.RequesterKeychainKey).([]core.Key)
+	if !ok {
+		keys = []core.Key{}
+	}
+
+	results, err := h.service.CommitAssociations(ctx, request.Commits, keys, c.RealIP())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": results})
+}
+
 func (h *handler) Get(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Store.Handler.Get")
 	defer span.End()
@@ -92,9 +123,53 @@ func (h *handler) Get(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
 	}
 
-	path := fmt.Sprintf("/tmp/concrnt/user/%s.log", requester)
-	return c.Attachment(path, requester+".log")
+	var since, until time.Time
+	if sinceStr := c.QueryParam("since"); sinceStr != "" {
+		sinceInt, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid since"})
+		}
+		since = time.Unix(sinceInt, 0)
+	}
+	if untilStr := c.QueryParam("until"); untilStr != "" {
+		untilInt, err := strconv.ParseInt(untilStr, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid until"})
+		}
+		until = time.Unix(untilInt, 0)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", requester+".log"))
+	res.WriteHeader(http.StatusOK)
+
+	err := h.service.StreamRepository(ctx, requester, since, until, res)
+	if err != nil {
+		// headers are already sent. the client sees a truncated body
+		span.RecordError(err)
+	}
+
+	return nil
+}
+
+// Compact drops documents superseded by later deletes from the requester's repository log
+func (h *handler) Compact(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Store.Handler.Compact")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	dropped, err := h.service.CompactRepository(ctx, requester)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": echo.Map{"dropped": dropped}})
 }
 
 func (h *handler) GetSyncStatus(c echo.Context) error {
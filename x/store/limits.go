@@ -0,0 +1,44 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// SetLimits sets the limits documents are checked against before they are verified
+func (s *service) SetLimits(limits core.DocumentLimits) {
+	s.limits = limits.WithDefaults()
+}
+
+// SetDurability sets how durable commits are when they are returned
+func (s *service) SetDurability(durability core.CommitDurability) {
+	s.durability = durability.WithDefaults()
+}
+
+// checkLimits refuses the documents exceeding the limits, before anything expensive is done with them.
+// malformed documents are left to the parsing of the commit
+func checkLimits(document string, limits core.DocumentLimits) error {
+	if len(document) > limits.MaxDocumentBytes {
+		return core.NewErrorTooLarge("document", int64(limits.MaxDocumentBytes), int64(len(document)))
+	}
+
+	var doc struct {
+		PolicyParams   string   `json:"policyParams"`
+		PolicyDefaults string   `json:"policyDefaults"`
+		Timelines      []string `json:"timelines"`
+	}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil
+	}
+
+	if len(doc.Timelines) > limits.MaxTimelines {
+		return core.NewErrorTooLarge("timelines", int64(limits.MaxTimelines), int64(len(doc.Timelines)))
+	}
+	for _, params := range []string{doc.PolicyParams, doc.PolicyDefaults} {
+		if len(params) > limits.MaxPolicyParamsBytes {
+			return core.NewErrorTooLarge("policyParams", int64(limits.MaxPolicyParamsBytes), int64(len(params)))
+		}
+	}
+	return nil
+}
@@ -3,36 +3,53 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"os"
-	"path/filepath"
-	"strings"
 	"time"
 
-	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/keyspace"
 )
 
 type Repository interface {
-	Log(ctx context.Context, commit core.CommitLog) (core.CommitLog, error)
+	Log(ctx context.Context, commit core.CommitLog, durability core.CommitDurability) (core.CommitLog, error)
 	SyncCommitFile(ctx context.Context, owner string) error
 	SyncStatus(ctx context.Context, owner string) (core.SyncStatus, error)
+	StreamLog(ctx context.Context, owner string, since, until time.Time, w io.Writer) error
+	CompactLog(ctx context.Context, owner string) (int, error)
+	MarkEphemeral(ctx context.Context, documentIDs []string) error
+	PendingCompactions(ctx context.Context) ([]string, error)
+	SetCompactionPending(ctx context.Context, owner string, pending bool) error
+	GetCommitResult(ctx context.Context, signatureHash string) (commitResult, error)
+	SetCommitResult(ctx context.Context, signatureHash string, result commitResult, ttl time.Duration) error
+}
+
+// commitResult is the response of an executed commit, kept to answer re-submissions of the same document
+type commitResult struct {
+	DocumentHash string          `json:"documentHash"`
+	Result       json.RawMessage `json:"result"`
 }
 
 type repository struct {
-	db  *gorm.DB
-	rdb *redis.Client
+	db             *gorm.DB
+	rdb            *redis.Client
+	repositoryPath string
 }
 
-func NewRepository(db *gorm.DB, rdb *redis.Client) Repository {
-	return &repository{db, rdb}
+func NewRepository(db *gorm.DB, rdb *redis.Client, repositoryPath string) Repository {
+	return &repository{db, rdb, repositoryPath}
 }
 
-func (r *repository) Log(ctx context.Context, commit core.CommitLog) (core.CommitLog, error) {
+// logLockWait is how long a logged commit waits for a sync or a compaction of the repository of its owner
+const logLockWait = 5 * time.Second
+
+func (r *repository) Log(ctx context.Context, commit core.CommitLog, durability core.CommitDurability) (core.CommitLog, error) {
 	ctx, span := tracer.Start(ctx, "Store.Repository.Log")
 	defer span.End()
 
@@ -43,9 +60,34 @@ func (r *repository) Log(ctx context.Context, commit core.CommitLog) (core.Commi
 		}
 	}()
 
+	if durability.Level == core.DurabilityAsync {
+		err := tx.WithContext(ctx).Exec("SET LOCAL synchronous_commit TO OFF").Error
+		if err != nil {
+			tx.Rollback()
+			return core.CommitLog{}, err
+		}
+	}
+
 	err := tx.WithContext(ctx).Create(&commit).Error
 	if err != nil {
 		tx.Rollback()
+		// a re-submitted document is logged only once
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			var existing core.CommitLog
+			err = r.db.WithContext(ctx).Where("document_id = ?", commit.DocumentID).First(&existing).Error
+			if err == nil && existing.Signature == commit.Signature {
+				// the append of the previous submission may have failed
+				if durability.Level == core.DurabilityLogged && !existing.IsEphemeral {
+					err = r.appendLog(ctx, commit.Owners, durability.Fsync == core.FsyncAlways)
+					if err != nil {
+						span.RecordError(err)
+						return core.CommitLog{}, err
+					}
+				}
+				return existing, nil
+			}
+			return core.CommitLog{}, core.NewErrorAlreadyExists()
+		}
 		return core.CommitLog{}, err
 	}
 
@@ -62,97 +104,180 @@ func (r *repository) Log(ctx context.Context, commit core.CommitLog) (core.Commi
 	}
 
 	err = tx.Commit().Error
-	return commit, err
+	if err != nil {
+		return core.CommitLog{}, err
+	}
+
+	if durability.Level == core.DurabilityLogged && !commit.IsEphemeral {
+		err = r.appendLog(ctx, commit.Owners, durability.Fsync == core.FsyncAlways)
+		if err != nil {
+			// the commit is in the database. a retry of the client or the next sync writes the log
+			span.RecordError(err)
+			return core.CommitLog{}, err
+		}
+	}
+
+	return commit, nil
 }
 
-func (r *repository) getLatestCommitDateByOwner(ctx context.Context, owner string) (time.Time, error) {
-	ctx, span := tracer.Start(ctx, "Store.Repository.GetLatestCommitByOwner")
+// appendLog brings the repository logs of the owners up to date with the database.
+// usually this is the commit just logged, but a log behind the database is caught up first,
+// as entries are appended in the order they are signed
+func (r *repository) appendLog(ctx context.Context, owners []string, fsync bool) error {
+	ctx, span := tracer.Start(ctx, "Store.Repository.appendLog")
 	defer span.End()
 
-	userlogPath := filepath.Join("/tmp/concrnt", "/user")
-	err := os.MkdirAll(userlogPath, 0755)
-	if err != nil {
-		slog.Error("failed to create repository directory:", slog.String("error", err.Error()))
-		panic(err)
-	}
+	for _, owner := range owners {
+		lockKey := keyspace.Store.Key("lock", owner)
+		acquired := false
+		deadline := time.Now().Add(logLockWait)
+		for {
+			ok, err := r.rdb.SetNX(ctx, lockKey, "1", time.Minute).Result()
+			if err != nil {
+				return err
+			}
+			if ok {
+				acquired = true
+				break
+			}
+			if time.Now().After(deadline) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		if !acquired {
+			return fmt.Errorf("repository of %s is busy", owner)
+		}
 
-	filename := fmt.Sprintf("%s.log", owner)
-	userStore, err := os.OpenFile(filepath.Join(userlogPath, filename), os.O_CREATE|os.O_RDWR, 0644)
-	if err != nil {
-		slog.Error("failed to open user log file:", slog.String("error", err.Error()))
-		return time.Time{}, err
+		userLog, err := openUserLog(r.repositoryPath, owner, defaultSegmentSize)
+		if err == nil {
+			userLog.fsync = fsync
+			_, err = r.appendSince(ctx, userLog, owner, userLog.latest(), nil)
+		}
+		r.rdb.Del(ctx, lockKey)
+		if err != nil {
+			return err
+		}
 	}
-	defer userStore.Close()
 
-	// find last log entry
-	stats, err := userStore.Stat()
-	if err != nil {
-		slog.Error("failed to stat repository log file:", slog.String("error", err.Error()))
-		panic(err)
-	}
+	return nil
+}
 
-	var lastLine string
-	var seeker int64 = stats.Size()
+// appendSince appends the commits of the owner signed after since to the log, and returns signedAt of the last one.
+// progress is called after each page
+func (r *repository) appendSince(ctx context.Context, userLog *userLog, owner string, since time.Time, progress func(time.Time)) (time.Time, error) {
+	var pageSize = 1000
 
 	for {
-		from := seeker - 1024
-		to := seeker
+		var commits []core.CommitLog
 
-		if from < 0 {
-			from = 0
+		query := r.db.WithContext(ctx).
+			Joins("JOIN commit_owners ON commit_owners.commit_log_id = commit_logs.id").
+			Where("commit_owners.owner = ?", owner).
+			Where("commit_logs.is_ephemeral = ?", false)
+
+		if !since.IsZero() {
+			query = query.Where("commit_logs.signed_at > ?", since)
 		}
 
-		if from == 0 && to == 0 {
-			break
+		err := query.
+			Order("commit_logs.signed_at ASC").
+			Limit(pageSize).
+			Find(&commits).
+			Error
+		if err != nil {
+			return since, err
 		}
 
-		buf := make([]byte, to-from)
-		_, err := userStore.ReadAt(buf, from)
+		entries := make([]logEntry, len(commits))
+		for i, commit := range commits {
+			entries[i] = logEntry{
+				ID:        commit.DocumentID,
+				Owner:     owner,
+				Signature: commit.Signature,
+				Document:  commit.Document,
+				SignedAt:  commit.SignedAt,
+			}
+		}
+		err = userLog.append(entries)
 		if err != nil {
-			slog.Error("failed to read repository log file:", slog.String("error", err.Error()))
-			panic(err)
+			logging.Error(ctx, "failed to write to user log file:", slog.String("error", err.Error()))
+			return since, err
 		}
 
-		// remove trailing newline
-		if buf[len(buf)-1] == '\n' {
-			buf = buf[:len(buf)-1]
+		if len(commits) > 0 {
+			since = commits[len(commits)-1].SignedAt
+			if progress != nil {
+				progress(since)
+			}
 		}
 
-		lines := strings.Split(string(buf), "\n")
-		if len(lines) > 1 {
-			lastLine = lines[len(lines)-1] + lastLine
-			break
+		if len(commits) < pageSize {
+			return since, nil
 		}
+	}
+}
+
+// GetCommitResult returns the result recorded for the signature. core.ErrorNotFound is returned if there is none.
+func (r *repository) GetCommitResult(ctx context.Context, signatureHash string) (commitResult, error) {
+	ctx, span := tracer.Start(ctx, "Store.Repository.GetCommitResult")
+	defer span.End()
+
+	data, err := r.rdb.Get(ctx, keyspace.Store.Key("commit", signatureHash)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return commitResult{}, core.NewErrorNotFound()
+		}
+		span.RecordError(err)
+		return commitResult{}, err
+	}
 
-		lastLine = string(buf) + lastLine
+	var result commitResult
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (r *repository) SetCommitResult(ctx context.Context, signatureHash string, result commitResult, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "Store.Repository.SetCommitResult")
+	defer span.End()
 
-		seeker = from
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
 	}
 
-	split := strings.Split(lastLine, " ")
-	if len(split) < 4 {
-		return time.Time{}, nil
+	err = r.rdb.Set(ctx, keyspace.Store.Key("commit", signatureHash), data, ttl).Err()
+	if err != nil {
+		span.RecordError(err)
 	}
+	return err
+}
+
+func (r *repository) getLatestCommitDateByOwner(ctx context.Context, owner string) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "Store.Repository.GetLatestCommitByOwner")
+	defer span.End()
 
-	document := strings.Join(split[3:], " ")
-	object := core.DocumentBase[any]{}
-	err = json.Unmarshal([]byte(document), &object)
+	userLog, err := openUserLog(r.repositoryPath, owner, defaultSegmentSize)
 	if err != nil {
 		span.RecordError(err)
-		return time.Time{}, errors.Wrap(err, "failed to unmarshal payload")
+		return time.Time{}, err
 	}
 
-	return object.SignedAt, nil
+	return userLog.latest(), nil
 }
 
 func (r *repository) SyncStatus(ctx context.Context, owner string) (core.SyncStatus, error) {
 	ctx, span := tracer.Start(ctx, "Store.Repository.SyncStatus")
 	defer span.End()
 
-	lockKey := fmt.Sprintf("store:lock:%s", owner)
+	lockKey := keyspace.Store.Key("lock", owner)
 	value, err := r.rdb.Get(ctx, lockKey).Result()
 	if err == nil && value != "" {
-		progress, _ := r.rdb.Get(ctx, fmt.Sprintf("store:progress:%s", owner)).Result()
+		progress, _ := r.rdb.Get(ctx, keyspace.Store.Key("progress", owner)).Result()
 
 		return core.SyncStatus{
 			Owner:    owner,
@@ -194,13 +319,16 @@ func (r *repository) SyncCommitFile(ctx context.Context, owner string) error {
 	ctx, span := tracer.Start(ctx, "Store.Repository.GetLogsByOwner")
 	defer span.End()
 
-	// accuire lock
-	lockKey := fmt.Sprintf("store:lock:%s", owner)
-	_, err := r.rdb.SetNX(ctx, lockKey, "1", time.Minute).Result()
+	// accuire lock. logged commits append under the same lock
+	lockKey := keyspace.Store.Key("lock", owner)
+	ok, err := r.rdb.SetNX(ctx, lockKey, "1", time.Minute).Result()
 	if err != nil && err != redis.Nil {
 		span.RecordError(err)
 		return err
 	}
+	if !ok {
+		return fmt.Errorf("repository is busy")
+	}
 	defer r.rdb.Del(ctx, lockKey)
 
 	lastSignedAt, err := r.getLatestCommitDateByOwner(ctx, owner)
@@ -209,22 +337,11 @@ func (r *repository) SyncCommitFile(ctx context.Context, owner string) error {
 		return err
 	}
 
-	userlogPath := filepath.Join("/tmp/concrnt", "/user")
-	err = os.MkdirAll(userlogPath, 0755)
+	userLog, err := openUserLog(r.repositoryPath, owner, defaultSegmentSize)
 	if err != nil {
-		slog.Error("failed to create repository directory:", slog.String("error", err.Error()))
-		panic(err)
-	}
-
-	filename := fmt.Sprintf("%s.log", owner)
-	userStore, err := os.OpenFile(filepath.Join(userlogPath, filename), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
-	if err != nil {
-		slog.Error("failed to open user log file:", slog.String("error", err.Error()))
+		span.RecordError(err)
 		return err
 	}
-	defer userStore.Close()
-
-	var pageSize = 1000
 
 	var firstCommitDate time.Time
 	err = r.db.WithContext(ctx).
@@ -262,7 +379,7 @@ func (r *repository) SyncCommitFile(ctx context.Context, owner string) error {
 	defer cancel()
 
 	progress := float64(lastSignedAt.Sub(firstCommitDate).Seconds()) / float64(latestCommitDate.Sub(firstCommitDate).Seconds())
-	r.rdb.SetNX(ctx, fmt.Sprintf("store:progress:%s", owner), fmt.Sprintf("%.2f%%", progress*100), 10*time.Minute)
+	r.rdb.SetNX(ctx, keyspace.Store.Key("progress", owner), fmt.Sprintf("%.2f%%", progress*100), 10*time.Minute)
 
 	// log dump progress
 	go func() {
@@ -273,7 +390,7 @@ func (r *repository) SyncCommitFile(ctx context.Context, owner string) error {
 			case <-time.After(10 * time.Second):
 				progress := float64(lastSignedAt.Sub(firstCommitDate).Seconds()) / float64(latestCommitDate.Sub(firstCommitDate).Seconds())
 				fmt.Printf("dumping %s logs. (%.2f%%)\n", owner, progress*100)
-				r.rdb.SetNX(ctx, fmt.Sprintf("store:progress:%s", owner), fmt.Sprintf("%.2f%%", progress*100), 10*time.Minute)
+				r.rdb.SetNX(ctx, keyspace.Store.Key("progress", owner), fmt.Sprintf("%.2f%%", progress*100), 10*time.Minute)
 
 				// re-set lock
 				r.rdb.SetNX(ctx, lockKey, "1", time.Minute)
@@ -281,47 +398,102 @@ func (r *repository) SyncCommitFile(ctx context.Context, owner string) error {
 		}
 	}()
 
-	for {
-		var commits []core.CommitLog
+	_, err = r.appendSince(ctx, userLog, owner, lastSignedAt, func(signedAt time.Time) {
+		lastSignedAt = signedAt
+	})
+	return err
+}
 
-		query := r.db.WithContext(ctx).
-			Joins("JOIN commit_owners ON commit_owners.commit_log_id = commit_logs.id").
-			Where("commit_owners.owner = ?", owner).
-			Where("commit_logs.is_ephemeral = ?", false)
+func (r *repository) StreamLog(ctx context.Context, owner string, since, until time.Time, w io.Writer) error {
+	ctx, span := tracer.Start(ctx, "Store.Repository.StreamLog")
+	defer span.End()
 
-		if !lastSignedAt.IsZero() {
-			query = query.Where("commit_logs.signed_at > ?", lastSignedAt)
-		}
+	userLog, err := openUserLog(r.repositoryPath, owner, defaultSegmentSize)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
 
-		q := query.
-			Order("commit_logs.signed_at ASC").
-			Limit(pageSize).Find(&commits)
+	return userLog.stream(w, since, until)
+}
 
-		err = q.Error
-		if err != nil {
-			span.RecordError(err)
-			return err
-		}
+// MarkEphemeral excludes the commits of the documents from the logs synced from now on
+func (r *repository) MarkEphemeral(ctx context.Context, documentIDs []string) error {
+	ctx, span := tracer.Start(ctx, "Store.Repository.MarkEphemeral")
+	defer span.End()
 
-		var logs string
-		for _, commit := range commits {
-			// ID Owner Signature Document
-			logs += fmt.Sprintf("%s %s %s %s\n", commit.DocumentID, owner, commit.Signature, commit.Document)
-		}
-		_, err = userStore.WriteString(logs)
-		if err != nil {
-			slog.Error("failed to write to user log file:", slog.String("error", err.Error()))
-			return err
-		}
+	if len(documentIDs) == 0 {
+		return nil
+	}
 
-		if len(commits) > 0 {
-			lastSignedAt = commits[len(commits)-1].SignedAt
-		}
+	err := r.db.WithContext(ctx).
+		Model(&core.CommitLog{}).
+		Where("document_id IN ?", documentIDs).
+		Update("is_ephemeral", true).Error
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
 
-		if len(commits) < pageSize {
-			break
-		}
+// PendingCompactions returns the owners whose log still holds entries to compact away
+func (r *repository) PendingCompactions(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Store.Repository.PendingCompactions")
+	defer span.End()
+
+	owners, err := r.rdb.SMembers(ctx, keyspace.Store.Key("compact", "pending")).Result()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
 	}
+	return owners, nil
+}
 
-	return nil
+// SetCompactionPending keeps the owner until its log is compacted.
+// the entries are marked ephemeral once, so the owner would not be found again by a later run
+func (r *repository) SetCompactionPending(ctx context.Context, owner string, pending bool) error {
+	ctx, span := tracer.Start(ctx, "Store.Repository.SetCompactionPending")
+	defer span.End()
+
+	var err error
+	if pending {
+		err = r.rdb.SAdd(ctx, keyspace.Store.Key("compact", "pending"), owner).Err()
+	} else {
+		err = r.rdb.SRem(ctx, keyspace.Store.Key("compact", "pending"), owner).Err()
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (r *repository) CompactLog(ctx context.Context, owner string) (int, error) {
+	ctx, span := tracer.Start(ctx, "Store.Repository.CompactLog")
+	defer span.End()
+
+	// share the lock with sync so that segments are not rewritten while appended
+	lockKey := keyspace.Store.Key("lock", owner)
+	ok, err := r.rdb.SetNX(ctx, lockKey, "1", time.Minute).Result()
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("repository is busy")
+	}
+	defer r.rdb.Del(ctx, lockKey)
+
+	userLog, err := openUserLog(r.repositoryPath, owner, defaultSegmentSize)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	dropped, err := userLog.compact(time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	return dropped, nil
 }
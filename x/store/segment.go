@@ -0,0 +1,394 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRepositoryPath = "/tmp/concrnt"
+	defaultSegmentSize    = 4 << 20
+	segmentIndexFile      = "index.json"
+	maxLogLineSize        = 1 << 20
+)
+
+// logEntry is a line of the repository log: "ID Owner Signature Document"
+type logEntry struct {
+	ID        string
+	Owner     string
+	Signature string
+	Document  string
+	SignedAt  time.Time
+}
+
+func (e logEntry) String() string {
+	return fmt.Sprintf("%s %s %s %s", e.ID, e.Owner, e.Signature, e.Document)
+}
+
+func parseLogEntry(line string) (logEntry, error) {
+	split := strings.SplitN(line, " ", 4)
+	if len(split) < 4 {
+		return logEntry{}, fmt.Errorf("invalid log line")
+	}
+
+	var doc struct {
+		SignedAt time.Time `json:"signedAt"`
+	}
+	err := json.Unmarshal([]byte(split[3]), &doc)
+	if err != nil {
+		return logEntry{}, errors.Wrap(err, "failed to unmarshal payload")
+	}
+
+	return logEntry{
+		ID:        split[0],
+		Owner:     split[1],
+		Signature: split[2],
+		Document:  split[3],
+		SignedAt:  doc.SignedAt,
+	}, nil
+}
+
+type segmentMeta struct {
+	Name    string    `json:"name"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+	Entries int       `json:"entries"`
+	Size    int64     `json:"size"`
+}
+
+func (m *segmentMeta) add(e logEntry, size int) {
+	if m.Entries == 0 || e.SignedAt.Before(m.First) {
+		m.First = e.SignedAt
+	}
+	if e.SignedAt.After(m.Last) {
+		m.Last = e.SignedAt
+	}
+	m.Entries++
+	m.Size += int64(size)
+}
+
+type segmentIndex struct {
+	Segments []segmentMeta `json:"segments"`
+	Next     int           `json:"next"`
+}
+
+// userLog is the repository log of a user.
+// entries are appended to size-bounded segment files; index.json records the time range of each segment,
+// so the latest entry and a range of entries are found without scanning the whole log.
+type userLog struct {
+	dir     string
+	maxSize int64
+	fsync   bool // sync segments and the index to the disk before append returns
+	index   segmentIndex
+}
+
+func openUserLog(root, owner string, maxSize int64) (*userLog, error) {
+	if root == "" {
+		root = defaultRepositoryPath
+	}
+	if maxSize <= 0 {
+		maxSize = defaultSegmentSize
+	}
+
+	l := &userLog{
+		dir:     filepath.Join(root, "user", owner),
+		maxSize: maxSize,
+	}
+
+	err := os.MkdirAll(l.dir, 0755)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create repository directory")
+	}
+
+	data, err := os.ReadFile(filepath.Join(l.dir, segmentIndexFile))
+	if err == nil {
+		err = json.Unmarshal(data, &l.index)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read segment index")
+		}
+		return l, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// logs written before segmentation are a single file next to the user directories
+	legacy := filepath.Join(root, "user", owner+".log")
+	if _, err := os.Stat(legacy); err == nil {
+		err = l.importLegacy(legacy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+func (l *userLog) importLegacy(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries := []logEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		entry, err := parseLogEntry(scanner.Text())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	err = l.append(entries)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path, path+".migrated")
+}
+
+func (l *userLog) saveIndex() error {
+	data, err := json.Marshal(l.index)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(l.dir, segmentIndexFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil && l.fsync {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, filepath.Join(l.dir, segmentIndexFile))
+	if err != nil || !l.fsync {
+		return err
+	}
+
+	// the rename is durable only once the directory is synced
+	dir, err := os.Open(l.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// latest returns signedAt of the newest entry
+func (l *userLog) latest() time.Time {
+	var latest time.Time
+	for _, seg := range l.index.Segments {
+		if seg.Last.After(latest) {
+			latest = seg.Last
+		}
+	}
+	return latest
+}
+
+func (l *userLog) newSegment() *segmentMeta {
+	l.index.Segments = append(l.index.Segments, segmentMeta{Name: fmt.Sprintf("%08d.log", l.index.Next)})
+	l.index.Next++
+	return &l.index.Segments[len(l.index.Segments)-1]
+}
+
+// append writes entries to the active segment and rotates it when it gets larger than maxSize
+func (l *userLog) append(entries []logEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var seg *segmentMeta
+	if len(l.index.Segments) == 0 || l.index.Segments[len(l.index.Segments)-1].Size >= l.maxSize {
+		seg = l.newSegment()
+	} else {
+		seg = &l.index.Segments[len(l.index.Segments)-1]
+	}
+
+	for len(entries) > 0 {
+		var buf bytes.Buffer
+		written := 0
+		for _, entry := range entries {
+			line := entry.String() + "\n"
+			if seg.Size > 0 && seg.Size+int64(len(line)) > l.maxSize {
+				break
+			}
+			buf.WriteString(line)
+			seg.add(entry, len(line))
+			written++
+		}
+
+		f, err := os.OpenFile(filepath.Join(l.dir, seg.Name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(buf.Bytes())
+		if err == nil && l.fsync {
+			err = f.Sync()
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		entries = entries[written:]
+		if len(entries) > 0 {
+			seg = l.newSegment()
+		}
+	}
+
+	return l.saveIndex()
+}
+
+// stream writes entries signed within [since, until] in log order. zero times are unbounded.
+func (l *userLog) stream(w io.Writer, since, until time.Time) error {
+	for _, seg := range l.index.Segments {
+		if !since.IsZero() && seg.Last.Before(since) {
+			continue
+		}
+		if !until.IsZero() && seg.First.After(until) {
+			continue
+		}
+
+		// segments entirely inside the range are copied as is
+		whole := (since.IsZero() || !seg.First.Before(since)) && (until.IsZero() || !seg.Last.After(until))
+
+		err := l.scan(seg.Name, func(line string) error {
+			if !whole {
+				entry, err := parseLogEntry(line)
+				if err != nil {
+					return nil
+				}
+				if (!since.IsZero() && entry.SignedAt.Before(since)) || (!until.IsZero() && entry.SignedAt.After(until)) {
+					return nil
+				}
+			}
+			_, err := io.WriteString(w, line+"\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *userLog) scan(name string, fn func(line string) error) error {
+	f, err := os.Open(filepath.Join(l.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		err := fn(scanner.Text())
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites segments without documents which were deleted later in the log or which expired by now.
+// the delete documents themselves are kept so that replaying the log still removes copies elsewhere.
+func (l *userLog) compact(now time.Time) (int, error) {
+	superseded := make(map[string]bool)
+	for _, seg := range l.index.Segments {
+		err := l.scan(seg.Name, func(line string) error {
+			split := strings.SplitN(line, " ", 4)
+			if len(split) < 4 {
+				return nil
+			}
+			var doc struct {
+				Type      string     `json:"type"`
+				Target    string     `json:"target"`
+				ExpiresAt *time.Time `json:"expiresAt"`
+			}
+			if json.Unmarshal([]byte(split[3]), &doc) != nil {
+				return nil
+			}
+			if doc.ExpiresAt != nil && !doc.ExpiresAt.After(now) {
+				superseded[split[0]] = true
+			}
+			// targets are typed ids: one prefix character followed by the document id
+			if doc.Type == "delete" && len(doc.Target) > 1 {
+				superseded[doc.Target[1:]] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(superseded) == 0 {
+		return 0, nil
+	}
+
+	dropped := 0
+	segments := make([]segmentMeta, 0, len(l.index.Segments))
+	for _, seg := range l.index.Segments {
+		var buf bytes.Buffer
+		compacted := segmentMeta{Name: seg.Name}
+		err := l.scan(seg.Name, func(line string) error {
+			entry, err := parseLogEntry(line)
+			if err != nil {
+				return nil
+			}
+			if superseded[entry.ID] {
+				dropped++
+				return nil
+			}
+			buf.WriteString(line + "\n")
+			compacted.add(entry, len(line)+1)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		path := filepath.Join(l.dir, seg.Name)
+		if compacted.Entries == 0 {
+			os.Remove(path)
+			continue
+		}
+		if compacted.Entries != seg.Entries {
+			err = os.WriteFile(path+".tmp", buf.Bytes(), 0644)
+			if err != nil {
+				return 0, err
+			}
+			err = os.Rename(path+".tmp", path)
+			if err != nil {
+				return 0, err
+			}
+		}
+		segments = append(segments, compacted)
+	}
+
+	l.index.Segments = segments
+	return dropped, l.saveIndex()
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEntry(id string, signedAt time.Time, body string) logEntry {
+	return logEntry{
+		ID:        id,
+		Owner:     "con1test",
+		Signature: "sig",
+		Document:  fmt.Sprintf(`{%s"signedAt":"%s"}`, body, signedAt.Format(time.RFC3339)),
+		SignedAt:  signedAt,
+	}
+}
+
+func TestUserLogSegments(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l, err := openUserLog(root, "con1test", 200)
+	assert.NoError(t, err)
+
+	entries := []logEntry{}
+	for i := 0; i < 6; i++ {
+		entries = append(entries, testEntry(fmt.Sprintf("doc%d", i), base.Add(time.Duration(i)*time.Hour), `"type":"message",`))
+	}
+	assert.NoError(t, l.append(entries))
+	assert.Greater(t, len(l.index.Segments), 1)
+	assert.Equal(t, base.Add(5*time.Hour), l.latest())
+
+	// index survives reopening
+	l, err = openUserLog(root, "con1test", 200)
+	assert.NoError(t, err)
+	assert.Equal(t, base.Add(5*time.Hour), l.latest())
+
+	var buf bytes.Buffer
+	assert.NoError(t, l.stream(&buf, base.Add(2*time.Hour), base.Add(3*time.Hour)))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "doc2 "))
+	assert.True(t, strings.HasPrefix(lines[1], "doc3 "))
+}
+
+func TestUserLogCompact(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l, err := openUserLog(root, "con1test", 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, l.append([]logEntry{
+		testEntry("doc0", base, `"type":"message",`),
+		testEntry("doc1", base.Add(time.Hour), `"type":"message",`),
+		testEntry("doc2", base.Add(2*time.Hour), `"type":"delete","target":"mdoc0",`),
+		testEntry("doc3", base.Add(3*time.Hour), `"type":"message","expiresAt":"2024-01-01T04:00:00Z",`),
+	}))
+
+	// doc3 is still live
+	dropped, err := l.compact(base.Add(4 * time.Hour).Add(-time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+
+	dropped, err = l.compact(base.Add(4 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+
+	var buf bytes.Buffer
+	assert.NoError(t, l.stream(&buf, time.Time{}, time.Time{}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "doc1 "))
+	assert.True(t, strings.HasPrefix(lines[1], "doc2 "))
+}
+
+func TestUserLogFsync(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l, err := openUserLog(root, "con1test", 0)
+	assert.NoError(t, err)
+	l.fsync = true
+
+	assert.NoError(t, l.append([]logEntry{testEntry("doc0", base, "")}))
+	assert.NoError(t, l.append([]logEntry{testEntry("doc1", base.Add(time.Hour), "")}))
+
+	l, err = openUserLog(root, "con1test", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, base.Add(time.Hour), l.latest())
+	assert.Len(t, l.index.Segments, 1)
+}
@@ -3,16 +3,21 @@ package store
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/totegamma/concurrent/cdid"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/database"
 	"github.com/totegamma/concurrent/x/key"
 )
 
@@ -27,8 +32,14 @@ type service struct {
 	ack            core.AckService
 	subscription   core.SubscriptionService
 	semanticID     core.SemanticIDService
+	auth           core.AuthService
+	validator      core.SchemaValidator
 	config         core.Config
 	repositoryPath string
+	hooks          []core.CommitHook
+	listeners      []core.CommitListener
+	limits         core.DocumentLimits
+	durability     core.CommitDurability
 }
 
 func NewService(
@@ -42,6 +53,8 @@ func NewService(
 	ack core.AckService,
 	subscription core.SubscriptionService,
 	semanticID core.SemanticIDService,
+	auth core.AuthService,
+	validator core.SchemaValidator,
 	config core.Config,
 	repositoryPath string,
 ) core.StoreService {
@@ -56,11 +69,34 @@ func NewService(
 		ack:            ack,
 		subscription:   subscription,
 		semanticID:     semanticID,
+		auth:           auth,
+		validator:      validator,
 		config:         config,
 		repositoryPath: repositoryPath,
+		limits:         core.DocumentLimits{}.WithDefaults(),
+		durability:     core.CommitDurability{}.WithDefaults(),
 	}
 }
 
+// AddHook registers a hook called before each commit is executed, in order of registration
+func (s *service) AddHook(hook core.CommitHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// AddListener registers a listener called after each commit is executed successfully
+func (s *service) AddListener(listener core.CommitListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// commitResultTTL is how long a re-submitted document is answered with the original result
+const commitResultTTL = 24 * time.Hour
+
+// signatureHash identifies a commit. clients retrying a commit send the same signed document again.
+func signatureHash(signature string) string {
+	hash := sha256.Sum256([]byte(signature))
+	return hex.EncodeToString(hash[:])
+}
+
 type CommitOption struct {
 	IsEphemeral bool `json:"isEphemeral,omitempty"`
 }
@@ -81,6 +117,15 @@ func (s *service) Commit(
 		return nil, fmt.Errorf("unknown commit mode")
 	}
 
+	// replayed documents were accepted under the limits of their time
+	if mode != core.CommitModeLocalOnlyExec {
+		err := checkLimits(document, s.limits)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
 	var base core.DocumentBase[any]
 	err := json.Unmarshal([]byte(document), &base)
 	if err != nil {
@@ -93,6 +138,51 @@ func (s *service) Commit(
 		return nil, err
 	}
 
+	// documents signed by a subkey bound to scoped tokens, or sent with a scoped token, are limited to their scopes
+	if mode != core.CommitModeLocalOnlyExec {
+		err = s.auth.AuthorizeCommit(ctx, document)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	// a retried commit, e.g. after its response was lost, returns the original result instead of executing again
+	var commitKey, documentHash string
+	if mode == core.CommitModeExecute {
+		commitKey = signatureHash(signature)
+		hash := core.GetHash([]byte(document))
+		documentHash = hex.EncodeToString(hash)
+
+		recorded, err := s.repo.GetCommitResult(ctx, commitKey)
+		if err == nil && recorded.DocumentHash == documentHash {
+			span.SetAttributes(attribute.Bool("replayed", true))
+			return recorded.Result, nil
+		}
+	}
+
+	// replayed documents were already accepted once, so they are not validated again
+	if base.Body != nil && mode != core.CommitModeLocalOnlyExec {
+		err = s.validator.Validate(ctx, base.Schema, base.Body)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	for _, hook := range s.hooks {
+		ctx, err = hook.BeforeCommit(ctx, mode, document, signature, option, keys)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	// the async level applies to the document as well as to its log, the document is the larger write
+	if s.durability.Level == core.DurabilityAsync {
+		ctx = database.WithAsyncCommit(ctx)
+	}
+
 	var result any
 	owners := []string{}
 
@@ -105,7 +195,7 @@ func (s *service) Commit(
 
 	case "profile":
 		var p core.Profile
-		p, err = s.profile.Upsert(ctx, mode, document, signature)
+		p, err = s.profile.Upsert(ctx, mode, document, signature, option)
 		result = p
 		owners = []string{p.Author}
 
@@ -123,7 +213,7 @@ func (s *service) Commit(
 
 	case "timeline":
 		var t core.Timeline
-		t, err = s.timeline.UpsertTimeline(ctx, mode, document, signature)
+		t, err = s.timeline.UpsertTimeline(ctx, mode, document, signature, option)
 		result = t
 		owners = []string{t.Owner}
 
@@ -204,23 +294,7 @@ func (s *service) Commit(
 	}
 
 	if err == nil && base.Type != "event" && (mode == core.CommitModeExecute || mode == core.CommitModeLocalOnlyExec) {
-		var localOwners []string
-		for _, owner := range owners {
-			if owner == s.config.CSID {
-				localOwners = append(localOwners, owner)
-			}
-			if core.IsCCID(owner) {
-				ownerEntity, err := s.entity.Get(ctx, owner)
-				if err != nil {
-					span.RecordError(errors.Wrap(err, "failed to get owner entity"))
-					continue
-				}
-
-				if ownerEntity.Domain == s.config.FQDN {
-					localOwners = append(localOwners, owner)
-				}
-			}
-		}
+		localOwners := s.localOwners(ctx, owners)
 
 		isEphemeral := false
 		var commitOption CommitOption
@@ -246,16 +320,57 @@ func (s *service) Commit(
 			Owners:      localOwners,
 		}
 
-		_, err = s.repo.Log(ctx, commitLog)
+		_, err = s.repo.Log(ctx, commitLog, s.durability)
 		if err != nil {
 			span.RecordError(err)
 			return nil, err
 		}
 	}
 
+	if err == nil && commitKey != "" {
+		data, merr := json.Marshal(result)
+		if merr == nil {
+			serr := s.repo.SetCommitResult(ctx, commitKey, commitResult{DocumentHash: documentHash, Result: data}, commitResultTTL)
+			if serr != nil {
+				span.RecordError(serr)
+			}
+		}
+	}
+
+	if err == nil {
+		for _, listener := range s.listeners {
+			listener.AfterCommit(ctx, mode, base.Type, document, signature)
+		}
+	}
+
 	return result, err
 }
 
+// localOwners returns the owners whose repository is on this domain
+func (s *service) localOwners(ctx context.Context, owners []string) []string {
+	ctx, span := tracer.Start(ctx, "Store.Service.LocalOwners")
+	defer span.End()
+
+	var localOwners []string
+	for _, owner := range owners {
+		if owner == s.config.CSID {
+			localOwners = append(localOwners, owner)
+		}
+		if core.IsCCID(owner) {
+			ownerEntity, err := s.entity.Get(ctx, owner)
+			if err != nil {
+				span.RecordError(errors.Wrap(err, "failed to get owner entity"))
+				continue
+			}
+
+			if ownerEntity.Domain == s.config.FQDN {
+				localOwners = append(localOwners, owner)
+			}
+		}
+	}
+	return localOwners
+}
+
 func (s *service) Restore(ctx context.Context, archive io.Reader, from string, IP string) ([]core.BatchResult, error) {
 	ctx, span := tracer.Start(ctx, "Store.Service.Restore")
 	defer span.End()
@@ -318,7 +433,7 @@ func (s *service) ValidateDocument(ctx context.Context, document, signature stri
 	err := json.Unmarshal([]byte(document), &object)
 	if err != nil {
 		span.RecordError(err)
-		return errors.Wrap(err, "failed to unmarshal payload")
+		return core.NewErrorInvalidArgument("failed to unmarshal payload: " + err.Error())
 	}
 
 	// マスターキーの場合: そのまま検証して終了
@@ -326,12 +441,12 @@ func (s *service) ValidateDocument(ctx context.Context, document, signature stri
 		signatureBytes, err := hex.DecodeString(signature)
 		if err != nil {
 			span.RecordError(err)
-			return errors.Wrap(err, "[master] failed to decode signature")
+			return core.NewErrorInvalidSignature("[master] failed to decode signature: " + err.Error())
 		}
 		err = core.VerifySignature([]byte(document), signatureBytes, object.Signer)
 		if err != nil {
 			span.RecordError(err)
-			return errors.Wrap(err, "[master] failed to verify signature")
+			return core.NewErrorInvalidSignature("[master] failed to verify signature: " + err.Error())
 		}
 	} else { // サブキーの場合: 親キーを取得して検証
 
@@ -358,7 +473,7 @@ func (s *service) ValidateDocument(ctx context.Context, document, signature stri
 		}
 
 		if ccid != object.Signer {
-			err := fmt.Errorf("Signer is not matched with the resolved signer")
+			err := core.NewErrorInvalidSignature("signer is not matched with the resolved signer")
 			span.RecordError(err)
 			return err
 		}
@@ -366,12 +481,12 @@ func (s *service) ValidateDocument(ctx context.Context, document, signature stri
 		signatureBytes, err := hex.DecodeString(signature)
 		if err != nil {
 			span.RecordError(err)
-			return errors.Wrap(err, "[sub] failed to decode signature")
+			return core.NewErrorInvalidSignature("[sub] failed to decode signature: " + err.Error())
 		}
 		err = core.VerifySignature([]byte(document), signatureBytes, object.KeyID)
 		if err != nil {
 			span.RecordError(err)
-			return errors.Wrap(err, "[sub] failed to verify signature")
+			return core.NewErrorInvalidSignature("[sub] failed to verify signature: " + err.Error())
 		}
 	}
 
@@ -454,3 +569,85 @@ func (s *service) SyncStatus(ctx context.Context, owner string) (core.SyncStatus
 
 	return s.repo.SyncStatus(ctx, owner)
 }
+
+func (s *service) StreamRepository(ctx context.Context, owner string, since, until time.Time, w io.Writer) error {
+	ctx, span := tracer.Start(ctx, "Store.Service.StreamRepository")
+	defer span.End()
+
+	return s.repo.StreamLog(ctx, owner, since, until, w)
+}
+
+func (s *service) CompactRepository(ctx context.Context, owner string) (int, error) {
+	ctx, span := tracer.Start(ctx, "Store.Service.CompactRepository")
+	defer span.End()
+
+	return s.repo.CompactLog(ctx, owner)
+}
+
+// PruneExpired removes the messages which expired along with their timeline items,
+// then drops their commits from the logs of the local authors so that they aren't served to syncing domains anymore
+func (s *service) PruneExpired(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Store.Service.PruneExpired")
+	defer span.End()
+
+	expired, err := s.message.Expire(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	// items of remote messages expire on their own
+	_, err = s.timeline.ExpireItems(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	var owners []string
+	if len(expired) > 0 {
+		span.SetAttributes(attribute.Int("expired", len(expired)))
+
+		documentIDs := make([]string, len(expired))
+		authors := make(map[string]bool)
+		for i, message := range expired {
+			documentIDs[i] = strings.TrimPrefix(message.ID, "m")
+			authors[message.Author] = true
+		}
+
+		err = s.repo.MarkEphemeral(ctx, documentIDs)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		for author := range authors {
+			owners = append(owners, author)
+		}
+		owners = s.localOwners(ctx, owners)
+	}
+
+	// the logs which were busy on a previous run are retried until they are compacted
+	pending, err := s.repo.PendingCompactions(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	for _, owner := range pending {
+		if !slices.Contains(owners, owner) {
+			owners = append(owners, owner)
+		}
+	}
+
+	for _, owner := range owners {
+		_, err := s.repo.CompactLog(ctx, owner)
+		if err != nil {
+			span.RecordError(errors.Wrap(err, "failed to compact "+owner))
+		}
+		err = s.repo.SetCompactionPending(ctx, owner, err != nil)
+		if err != nil {
+			span.RecordError(err)
+		}
+	}
+
+	return nil
+}
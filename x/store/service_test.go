@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+	"github.com/totegamma/concurrent/internal/fixtures"
+	"github.com/totegamma/concurrent/x/schema"
+)
+
+var user1ID = fixtures.User1.CCID
+
+// memoryRepository keeps commit results and logs in memory
+type memoryRepository struct {
+	Repository
+	results map[string]commitResult
+	logs    []core.CommitLog
+}
+
+func (r *memoryRepository) Log(ctx context.Context, commit core.CommitLog, durability core.CommitDurability) (core.CommitLog, error) {
+	r.logs = append(r.logs, commit)
+	return commit, nil
+}
+
+func (r *memoryRepository) GetCommitResult(ctx context.Context, signatureHash string) (commitResult, error) {
+	result, ok := r.results[signatureHash]
+	if !ok {
+		return commitResult{}, core.NewErrorNotFound()
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) SetCommitResult(ctx context.Context, signatureHash string, result commitResult, ttl time.Duration) error {
+	r.results[signatureHash] = result
+	return nil
+}
+
+func TestCommitIsIdempotent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	document, signature := fixtures.Sign(t, fixtures.User1, fixtures.Message(fixtures.User1, nil, "home@"+user1ID))
+
+	mockAuth := mock_core.NewMockAuthService(ctrl)
+	mockAuth.EXPECT().AuthorizeCommit(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), user1ID).Return(core.Entity{ID: user1ID, Domain: "local.example.com"}, nil).AnyTimes()
+
+	// the message is created only by the first submission
+	mockMessage := mock_core.NewMockMessageService(ctrl)
+	mockMessage.EXPECT().Create(gomock.Any(), core.CommitModeExecute, document, signature).Return(core.Message{
+		ID:     "m1",
+		Author: user1ID,
+	}, []string{user1ID}, nil).Times(1)
+
+	repo := &memoryRepository{results: map[string]commitResult{}}
+	service := NewService(repo, nil, mockEntity, mockMessage, nil, nil, nil, nil, nil, nil, mockAuth, schema.NewValidator("off"), core.Config{FQDN: "local.example.com"}, "")
+
+	first, err := service.Commit(context.Background(), core.CommitModeExecute, document, signature, "", nil, "")
+	assert.NoError(t, err)
+
+	second, err := service.Commit(context.Background(), core.CommitModeExecute, document, signature, "", nil, "")
+	assert.NoError(t, err)
+
+	firstJSON, _ := json.Marshal(first)
+	secondJSON, _ := json.Marshal(second)
+	assert.JSONEq(t, string(firstJSON), string(secondJSON))
+	assert.Len(t, repo.logs, 1)
+}
+
+// compactingRepository fails the compaction of the owners whose log is busy
+type compactingRepository struct {
+	Repository
+	busy      map[string]bool
+	compacted []string
+	pending   map[string]bool
+}
+
+func (r *compactingRepository) MarkEphemeral(ctx context.Context, documentIDs []string) error {
+	return nil
+}
+
+func (r *compactingRepository) CompactLog(ctx context.Context, owner string) (int, error) {
+	if r.busy[owner] {
+		return 0, fmt.Errorf("repository is busy")
+	}
+	r.compacted = append(r.compacted, owner)
+	return 1, nil
+}
+
+func (r *compactingRepository) PendingCompactions(ctx context.Context) ([]string, error) {
+	var owners []string
+	for owner := range r.pending {
+		owners = append(owners, owner)
+	}
+	return owners, nil
+}
+
+func (r *compactingRepository) SetCompactionPending(ctx context.Context, owner string, pending bool) error {
+	if pending {
+		r.pending[owner] = true
+	} else {
+		delete(r.pending, owner)
+	}
+	return nil
+}
+
+func TestPruneExpiredRetriesBusyLogs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMessage := mock_core.NewMockMessageService(ctrl)
+	gomock.InOrder(
+		mockMessage.EXPECT().Expire(gomock.Any()).Return([]core.Message{{ID: "m1", Author: user1ID}}, nil),
+		mockMessage.EXPECT().Expire(gomock.Any()).Return(nil, nil),
+	)
+	mockTimeline := mock_core.NewMockTimelineService(ctrl)
+	mockTimeline.EXPECT().ExpireItems(gomock.Any()).Return(int64(0), nil).AnyTimes()
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), user1ID).Return(core.Entity{ID: user1ID, Domain: "local.example.com"}, nil).AnyTimes()
+
+	repo := &compactingRepository{busy: map[string]bool{user1ID: true}, pending: map[string]bool{}}
+	service := NewService(repo, nil, mockEntity, mockMessage, nil, nil, mockTimeline, nil, nil, nil, nil, schema.NewValidator("off"), core.Config{FQDN: "local.example.com"}, "")
+
+	// the log is busy, so the owner is kept for the next run
+	assert.NoError(t, service.PruneExpired(context.Background()))
+	assert.Empty(t, repo.compacted)
+	assert.True(t, repo.pending[user1ID])
+
+	// nothing expired since, but the owner is compacted anyway
+	repo.busy = map[string]bool{}
+	assert.NoError(t, service.PruneExpired(context.Background()))
+	assert.Equal(t, []string{user1ID}, repo.compacted)
+	assert.Empty(t, repo.pending)
+}
+
+func TestCheckLimits(t *testing.T) {
+	limits := core.DocumentLimits{MaxDocumentBytes: 256, MaxTimelines: 2, MaxPolicyParamsBytes: 16}.WithDefaults()
+
+	assert.NoError(t, checkLimits(`{"type":"message","timelines":["a","b"],"policyParams":"{}"}`, limits))
+	assert.NoError(t, checkLimits(`not json`, limits))
+
+	var tooLarge core.ErrorTooLarge
+	err := checkLimits(`{"type":"message","timelines":["a","b","c"]}`, limits)
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, core.NewErrorTooLarge("timelines", 2, 3), tooLarge)
+
+	err = checkLimits(`{"type":"timeline","policyParams":"{\"writer\":[\"con1...\"]}"}`, limits)
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, "policyParams", tooLarge.Limit)
+
+	err = checkLimits(`{"type":"message","body":"`+strings.Repeat("a", 300)+`"}`, limits)
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, "document", tooLarge.Limit)
+}
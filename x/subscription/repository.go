@@ -16,6 +16,7 @@ type Repository interface {
 	DeleteSubscription(ctx context.Context, id string) error
 	GetSubscriptionsByAuthor(ctx context.Context, owner string) ([]core.Subscription, error)
 	GetSubscriptionsByAuthorOwned(ctx context.Context, owner string) ([]core.Subscription, error)
+	CountSubscriptionsByAuthorOwned(ctx context.Context, owner string) (int64, error)
 
 	CreateItem(ctx context.Context, item core.SubscriptionItem) (core.SubscriptionItem, error)
 	GetItem(ctx context.Context, id string, subscription string) (core.SubscriptionItem, error)
@@ -229,6 +230,20 @@ func (r *repository) GetSubscriptionsByAuthorOwned(ctx context.Context, owner st
 	return subscriptions, err
 }
 
+// CountSubscriptionsByAuthorOwned returns the number of subscriptions the author created and owns
+func (r *repository) CountSubscriptionsByAuthorOwned(ctx context.Context, owner string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Subscription.Repository.CountSubscriptionsByAuthorOwned")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Subscription{}).Where("author = ? and domain_owned = false", owner).Count(&count).Error
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	return count, nil
+}
+
 // CreateItem creates new collection item
 func (r *repository) CreateItem(ctx context.Context, item core.SubscriptionItem) (core.SubscriptionItem, error) {
 	ctx, span := tracer.Start(ctx, "Subscription.Repository.CreateItem")
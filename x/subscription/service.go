@@ -15,6 +15,7 @@ type service struct {
 	repo   Repository
 	entity core.EntityService
 	policy core.PolicyService
+	config core.Config
 }
 
 // NewRepository creates a new collection repository
@@ -22,11 +23,13 @@ func NewService(
 	repo Repository,
 	entity core.EntityService,
 	policy core.PolicyService,
+	config core.Config,
 ) core.SubscriptionService {
 	return &service{
 		repo,
 		entity,
 		policy,
+		config,
 	}
 }
 
@@ -65,12 +68,20 @@ func (s *service) UpsertSubscription(ctx context.Context, mode core.CommitMode,
 			return core.Subscription{}, errors.New("subscription already exists")
 		}
 
+		// the limits are soft: creations are not refused while the count is unavailable
+		owned, err := s.repo.CountSubscriptionsByAuthorOwned(ctx, doc.Signer)
+		counted := err == nil
+		if err != nil {
+			span.RecordError(err)
+		}
+
 		policyResult, err := s.policy.TestWithPolicyURL(
 			ctx,
 			"",
 			core.RequestContext{
 				Requester: signer,
 				Document:  doc,
+				Owned:     owned,
 			},
 			"subscription.create",
 		)
@@ -80,7 +91,12 @@ func (s *service) UpsertSubscription(ctx context.Context, mode core.CommitMode,
 
 		result := s.policy.Summerize([]core.PolicyEvalResult{policyResult}, "subscription.create", nil)
 		if !result {
-			return core.Subscription{}, errors.New("You don't have subscription.create access")
+			return core.Subscription{}, core.NewErrorPolicyDenied("subscription.create")
+		}
+
+		limit := s.config.OwnershipLimits.For(core.ParseTags(signer.Tag)).Subscriptions
+		if counted && limit > 0 && owned >= limit {
+			return core.Subscription{}, core.NewErrorLimitExceeded("subscriptions", limit, owned)
 		}
 
 	} else {
@@ -119,7 +135,7 @@ func (s *service) UpsertSubscription(ctx context.Context, mode core.CommitMode,
 
 		result := s.policy.Summerize([]core.PolicyEvalResult{policyResult}, "subscription.update", nil)
 		if !result {
-			return core.Subscription{}, errors.New("You don't have subscription.update access")
+			return core.Subscription{}, core.NewErrorPolicyDenied("subscription.update")
 		}
 	}
 
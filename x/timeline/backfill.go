@@ -0,0 +1,167 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+const (
+	defaultBackfillWorkers = 2
+	// a timeline is backfilled once in this window, however many users subscribe to it
+	backfillDedupeTTL   = time.Hour
+	backfillPollTimeout = 5 * time.Second
+)
+
+var backfillMetrics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cc_timeline_backfill_total",
+		Help: "backfills of newly subscribed remote timelines by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(backfillMetrics)
+}
+
+// BackfillConfig configures the backfill of remote timelines subscribed by local users
+type BackfillConfig struct {
+	Chunks  int `yaml:"chunks"`  // chunks loaded into cache for a newly subscribed timeline. 0 disables the backfill
+	Workers int `yaml:"workers"` // timelines backfilled in parallel by each instance
+}
+
+// Backfiller loads the recent chunks of a remote timeline into cache in background when a local user subscribes to it,
+// so that the first read of the timeline is served from cache instead of a burst of remote fetches.
+// the timelines are queued in redis, so that any instance may backfill them
+type Backfiller interface {
+	core.CommitListener
+	Start(ctx context.Context)
+}
+
+type backfiller struct {
+	rdb      *redis.Client
+	timeline core.TimelineService
+	entity   core.EntityService
+	config   core.Config
+	backfill BackfillConfig
+}
+
+// NewBackfiller creates a new backfiller
+func NewBackfiller(rdb *redis.Client, timeline core.TimelineService, entity core.EntityService, config core.Config, backfill BackfillConfig) Backfiller {
+	if backfill.Workers <= 0 {
+		backfill.Workers = defaultBackfillWorkers
+	}
+	return &backfiller{rdb: rdb, timeline: timeline, entity: entity, config: config, backfill: backfill}
+}
+
+// AfterCommit queues the target of a subscribe document of a local user, if it is a remote timeline
+func (b *backfiller) AfterCommit(ctx context.Context, mode core.CommitMode, typ, document, signature string) {
+	if typ != "subscribe" || mode == core.CommitModeDryRun || b.backfill.Chunks <= 0 {
+		return
+	}
+
+	var doc core.SubscribeDocument[any]
+	err := json.Unmarshal([]byte(document), &doc)
+	if err != nil {
+		return
+	}
+
+	signer, err := b.entity.Get(ctx, doc.Signer)
+	if err != nil || signer.Domain != b.config.FQDN {
+		return
+	}
+
+	normalized, err := b.timeline.NormalizeTimelineID(ctx, doc.Target)
+	if err != nil {
+		return
+	}
+	split := strings.Split(normalized, "@")
+	if split[len(split)-1] == b.config.FQDN {
+		return
+	}
+
+	err = b.enqueue(ctx, normalized)
+	if err != nil {
+		logging.Warn(ctx, "failed to queue backfill", slog.String("module", "timeline"), slog.String("timeline", normalized), slog.String("error", err.Error()))
+	}
+}
+
+// enqueue queues the timeline unless it was queued within backfillDedupeTTL
+func (b *backfiller) enqueue(ctx context.Context, timeline string) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Backfiller.Enqueue")
+	defer span.End()
+
+	first, err := b.rdb.SetNX(ctx, keyspace.Backfill.Key("seen", timeline), 1, backfillDedupeTTL).Result()
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if !first {
+		return nil
+	}
+
+	err = b.rdb.LPush(ctx, keyspace.Backfill.Key("queue"), timeline).Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// Start runs the workers until ctx is done
+func (b *backfiller) Start(ctx context.Context) {
+	if b.backfill.Chunks <= 0 {
+		return
+	}
+	for i := 0; i < b.backfill.Workers; i++ {
+		go b.work(ctx)
+	}
+}
+
+func (b *backfiller) work(ctx context.Context) {
+	for {
+		result, err := b.rdb.BRPop(ctx, backfillPollTimeout, keyspace.Backfill.Key("queue")).Result()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to dequeue backfill", slog.String("module", "timeline"), slog.String("error", err.Error()))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backfillPollTimeout):
+			}
+			continue
+		}
+
+		b.run(ctx, result[1])
+	}
+}
+
+func (b *backfiller) run(ctx context.Context, timeline string) {
+	ctx, span := tracer.Start(ctx, "Timeline.Backfiller.Run")
+	defer span.End()
+
+	loaded, err := b.timeline.Backfill(ctx, timeline, b.backfill.Chunks)
+	if err != nil {
+		span.RecordError(err)
+		backfillMetrics.WithLabelValues("failed").Inc()
+		logging.Warn(ctx, "failed to backfill timeline", slog.String("module", "timeline"), slog.String("timeline", timeline), slog.Int("loaded", loaded), slog.String("error", err.Error()))
+		return
+	}
+
+	backfillMetrics.WithLabelValues("completed").Inc()
+	logging.Info(ctx, "timeline backfilled", slog.String("module", "timeline"), slog.String("timeline", timeline), slog.Int("chunks", loaded))
+}
@@ -0,0 +1,167 @@
+package timeline
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	BridgeConnected  = "connected"
+	BridgeConnecting = "connecting"
+	BridgeBackoff    = "backoff"
+)
+
+var (
+	bridgeBackoffBase = 10 * time.Second
+	bridgeBackoffMax  = 5 * time.Minute
+)
+
+// bridge is the state of the connection to a remote domain relaying its timeline events
+type bridge struct {
+	status  core.BridgeStatus
+	conn    *websocket.Conn
+	writeMu sync.Mutex // the relay and the subscription updates both write to the connection
+}
+
+var (
+	bridgeMu sync.Mutex
+	bridges  = make(map[string]*bridge)
+)
+
+func getBridge(domain string) *bridge {
+	b, ok := bridges[domain]
+	if !ok {
+		b = &bridge{status: core.BridgeStatus{Domain: domain}}
+		bridges[domain] = b
+	}
+	return b
+}
+
+// bridgeBackoff is the wait before the next attempt after the given number of failures, with 20% of jitter
+func bridgeBackoff(attempts int) time.Duration {
+	d := bridgeBackoffBase
+	for i := 1; i < attempts && d < bridgeBackoffMax; i++ {
+		d *= 2
+	}
+	if d > bridgeBackoffMax {
+		d = bridgeBackoffMax
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5 + 1))
+	return d - d/10 + jitter
+}
+
+// bridgeReady reports whether a connection to the domain may be attempted now
+func bridgeReady(domain string, now time.Time) bool {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	b, ok := bridges[domain]
+	if !ok {
+		return true
+	}
+	switch b.status.State {
+	case BridgeConnected, BridgeConnecting:
+		return false
+	case BridgeBackoff:
+		return b.status.NextRetry == nil || !now.Before(*b.status.NextRetry)
+	}
+	return true
+}
+
+func bridgeConnecting(domain string) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	getBridge(domain).status.State = BridgeConnecting
+}
+
+func bridgeConnected(domain string, conn *websocket.Conn, now time.Time) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	b := getBridge(domain)
+	b.conn = conn
+	b.status.State = BridgeConnected
+	b.status.Attempts = 0
+	b.status.LastError = ""
+	b.status.ConnectedAt = &now
+	b.status.LastHeartbeat = &now
+	b.status.NextRetry = nil
+}
+
+// bridgeFailed schedules the next attempt to connect to the domain.
+// conn is the connection which was lost, nil for a failed attempt.
+// only the first report of a lost connection counts, its reader and writer both report it
+func bridgeFailed(domain string, conn *websocket.Conn, err error, now time.Time) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	b := getBridge(domain)
+	if conn != nil && b.conn != conn {
+		return
+	}
+	b.conn = nil
+
+	b.status.State = BridgeBackoff
+	b.status.Attempts++
+	if err != nil {
+		b.status.LastError = err.Error()
+	}
+	next := now.Add(bridgeBackoff(b.status.Attempts))
+	b.status.NextRetry = &next
+}
+
+func bridgeHeartbeat(domain string, now time.Time) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	if b, ok := bridges[domain]; ok {
+		b.status.LastHeartbeat = &now
+	}
+}
+
+func bridgeTimelines(domain string, timelines int) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	getBridge(domain).status.Timelines = timelines
+}
+
+// bridgeWriteJSON writes a message to the connection of the domain
+func bridgeWriteJSON(domain string, conn *websocket.Conn, v any) error {
+	bridgeMu.Lock()
+	b := getBridge(domain)
+	bridgeMu.Unlock()
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	return conn.WriteJSON(v)
+}
+
+func removeBridge(domain string) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	delete(bridges, domain)
+}
+
+// GetBridges returns the status of the connections to the remote domains, sorted by domain
+func (k *keeper) GetBridges() []core.BridgeStatus {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+
+	statuses := make([]core.BridgeStatus, 0, len(bridges))
+	for _, b := range bridges {
+		statuses = append(statuses, b.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Domain < statuses[j].Domain
+	})
+	return statuses
+}
@@ -0,0 +1,51 @@
+package timeline
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBridgeBackoff(t *testing.T) {
+	for attempts := 1; attempts < 10; attempts++ {
+		expected := bridgeBackoffBase << (attempts - 1)
+		if expected > bridgeBackoffMax {
+			expected = bridgeBackoffMax
+		}
+		d := bridgeBackoff(attempts)
+		assert.GreaterOrEqual(t, d, expected-expected/10)
+		assert.LessOrEqual(t, d, expected+expected/10)
+	}
+}
+
+func TestBridgeFailover(t *testing.T) {
+	domain := "bridge.example.com"
+	defer removeBridge(domain)
+	now := time.Now()
+
+	assert.True(t, bridgeReady(domain, now))
+	bridgeConnecting(domain)
+	assert.False(t, bridgeReady(domain, now))
+
+	bridgeFailed(domain, nil, errors.New("dial failed"), now)
+	assert.False(t, bridgeReady(domain, now))
+	assert.True(t, bridgeReady(domain, now.Add(bridgeBackoffMax)))
+
+	conn := &websocket.Conn{}
+	bridgeConnected(domain, conn, now)
+	assert.False(t, bridgeReady(domain, now.Add(bridgeBackoffMax)))
+
+	// the reader and the writer of the connection both report its loss
+	bridgeFailed(domain, conn, errors.New("connection reset"), now)
+	bridgeFailed(domain, conn, errors.New("connection closed"), now)
+
+	statuses := (&keeper{}).GetBridges()
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, BridgeBackoff, statuses[0].State)
+		assert.Equal(t, 1, statuses[0].Attempts)
+		assert.Equal(t, "connection reset", statuses[0].LastError)
+	}
+}
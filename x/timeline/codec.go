@@ -0,0 +1,204 @@
+package timeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+// ChunkCodec encodes the items of a chunk body in the cache.
+// new items are added to a cached body with memcached prepend, so the encoding of items must be
+// the concatenation of the encoding of each item.
+type ChunkCodec interface {
+	Encode(items ...core.TimelineItem) ([]byte, error)
+	Decode(data []byte) ([]core.TimelineItem, error)
+}
+
+const (
+	// v1 bodies are JSON objects each preceded by a comma
+	chunkV1Marker = ','
+	// v2 bodies are frames of a marker, the uvarint length of the payload and the payload
+	chunkV2Marker = 0x02
+)
+
+var errMalformedChunk = errors.New("malformed chunk body")
+
+// chunkCodec is the codec the cache is written with. bodies written by older versions are still read
+var chunkCodec ChunkCodec = ChunkCodecV2{}
+
+// ChunkCodecV1 is the JSON format written before v2
+type ChunkCodecV1 struct{}
+
+// Encode encodes the items as comma prefixed JSON objects
+func (ChunkCodecV1) Encode(items ...core.TimelineItem) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(chunkV1Marker)
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a body of any version
+func (ChunkCodecV1) Decode(data []byte) ([]core.TimelineItem, error) {
+	return decodeChunk(data)
+}
+
+// ChunkCodecV2 is a binary format which needs no JSON parsing
+type ChunkCodecV2 struct{}
+
+// Encode encodes the items as length prefixed frames
+func (ChunkCodecV2) Encode(items ...core.TimelineItem) ([]byte, error) {
+	var buf bytes.Buffer
+	var payload []byte
+	for _, item := range items {
+		payload = appendItem(payload[:0], item)
+		buf.WriteByte(chunkV2Marker)
+		buf.Write(binary.AppendUvarint(nil, uint64(len(payload))))
+		buf.Write(payload)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a body of any version
+func (ChunkCodecV2) Decode(data []byte) ([]core.TimelineItem, error) {
+	return decodeChunk(data)
+}
+
+// decodeChunk decodes a body item by item, looking at the marker of each one,
+// so that a body with items prepended by instances of other versions is read as well
+func decodeChunk(data []byte) ([]core.TimelineItem, error) {
+	items := make([]core.TimelineItem, 0)
+	for len(data) > 0 {
+		switch data[0] {
+		case chunkV2Marker:
+			length, n := binary.Uvarint(data[1:])
+			if n <= 0 || uint64(len(data)-1-n) < length {
+				return nil, errMalformedChunk
+			}
+			start := 1 + n
+			item, err := readItem(data[start : start+int(length)])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			data = data[start+int(length):]
+		case chunkV1Marker:
+			// an empty v1 body is a lone comma
+			if len(data) == 1 {
+				return items, nil
+			}
+			decoder := json.NewDecoder(bytes.NewReader(data[1:]))
+			var item core.TimelineItem
+			if err := decoder.Decode(&item); err != nil {
+				return nil, fmt.Errorf("%w: %w", errMalformedChunk, err)
+			}
+			items = append(items, item)
+			data = data[1+decoder.InputOffset():]
+		default:
+			return nil, errMalformedChunk
+		}
+	}
+	return items, nil
+}
+
+// v2 payload: resourceID, timelineID, owner, author, schema as length prefixed strings, and cdate in unix nanoseconds.
+// author is written with a presence byte because it is optional.
+// expiresAt of ephemeral items follows in unix nanoseconds. it is left out otherwise, so older payloads end at cdate
+func appendItem(b []byte, item core.TimelineItem) []byte {
+	b = appendString(b, item.ResourceID)
+	b = appendString(b, item.TimelineID)
+	b = appendString(b, item.Owner)
+	if item.Author != nil {
+		b = append(b, 1)
+		b = appendString(b, *item.Author)
+	} else {
+		b = append(b, 0)
+	}
+	b = appendString(b, item.Schema)
+	b = binary.AppendVarint(b, item.CDate.UnixNano())
+	if item.ExpiresAt != nil {
+		b = binary.AppendVarint(b, item.ExpiresAt.UnixNano())
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func readItem(b []byte) (core.TimelineItem, error) {
+	r := frameReader{b: b}
+	item := core.TimelineItem{
+		ResourceID: r.string(),
+		TimelineID: r.string(),
+		Owner:      r.string(),
+	}
+	if r.byte() == 1 {
+		author := r.string()
+		item.Author = &author
+	}
+	item.Schema = r.string()
+	item.CDate = time.Unix(0, r.varint())
+	if r.err == nil && len(r.b) > 0 {
+		expiresAt := time.Unix(0, r.varint())
+		item.ExpiresAt = &expiresAt
+	}
+	if r.err != nil {
+		return core.TimelineItem{}, r.err
+	}
+	return item, nil
+}
+
+// frameReader reads the fields of a v2 payload. the first error sticks and zero values are returned after it
+type frameReader struct {
+	b   []byte
+	err error
+}
+
+func (r *frameReader) byte() byte {
+	if r.err != nil || len(r.b) < 1 {
+		r.err = errMalformedChunk
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *frameReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	length, n := binary.Uvarint(r.b)
+	if n <= 0 || uint64(len(r.b)-n) < length {
+		r.err = errMalformedChunk
+		return ""
+	}
+	s := string(r.b[n : n+int(length)])
+	r.b = r.b[n+int(length):]
+	return s
+}
+
+func (r *frameReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errMalformedChunk
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
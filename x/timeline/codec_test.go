@@ -0,0 +1,70 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestChunkCodec(t *testing.T) {
+	author := "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+	expiresAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	newer := core.TimelineItem{
+		ResourceID: "m0000000000000000000000001",
+		TimelineID: "t0000000000000000000000001@example.com",
+		Owner:      author,
+		Author:     &author,
+		Schema:     "https://schema.concrnt.world/m/markdown.json",
+		CDate:      time.Date(2024, 1, 1, 0, 0, 1, 500, time.UTC),
+		ExpiresAt:  &expiresAt,
+	}
+	older := core.TimelineItem{
+		ResourceID: "m0000000000000000000000000",
+		TimelineID: "t0000000000000000000000001@example.com",
+		Owner:      author,
+		CDate:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for name, codec := range map[string]ChunkCodec{"v1": ChunkCodecV1{}, "v2": ChunkCodecV2{}} {
+		t.Run(name, func(t *testing.T) {
+			// a body and an item prepended to it decode in order
+			body, err := codec.Encode(older)
+			assert.NoError(t, err)
+			prepended, err := codec.Encode(newer)
+			assert.NoError(t, err)
+
+			items, err := codec.Decode(append(prepended, body...))
+			assert.NoError(t, err)
+			if assert.Len(t, items, 2) {
+				assert.Equal(t, newer.ResourceID, items[0].ResourceID)
+				assert.Equal(t, author, *items[0].Author)
+				assert.Equal(t, newer.Schema, items[0].Schema)
+				assert.True(t, newer.CDate.Equal(items[0].CDate))
+				if assert.NotNil(t, items[0].ExpiresAt) {
+					assert.True(t, expiresAt.Equal(*items[0].ExpiresAt))
+				}
+				assert.Nil(t, items[1].Author)
+				assert.Nil(t, items[1].ExpiresAt)
+				assert.True(t, older.CDate.Equal(items[1].CDate))
+			}
+		})
+	}
+
+	// items prepended by v2 to a body written by v1 are read together
+	v1Body, _ := ChunkCodecV1{}.Encode(older)
+	v2Item, _ := ChunkCodecV2{}.Encode(newer)
+	items, err := ChunkCodecV2{}.Decode(append(v2Item, v1Body...))
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	// an empty v1 body
+	items, err = ChunkCodecV2{}.Decode([]byte(","))
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+
+	_, err = ChunkCodecV2{}.Decode(v2Item[:len(v2Item)-1])
+	assert.ErrorIs(t, err, errMalformedChunk)
+}
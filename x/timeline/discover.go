@@ -0,0 +1,162 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+const (
+	discoverActivityWindow   = 7 * 24 * time.Hour
+	discoverSubscriberWeight = 5
+	discoverDefaultLimit     = 20
+	discoverMaxLimit         = 100
+	discoverRemoteLimit      = 50
+	discoverRemoteCacheTTL   = 10 * time.Minute
+	discoverRemoteFanout     = 8
+
+	// pages are ranked in memory in federated mode, so the ranking is only walked this far
+	discoverMaxOffset = 1000
+)
+
+func discoverScore(t core.DiscoveredTimeline) int64 {
+	return t.Activity + t.Subscribers*discoverSubscriberWeight
+}
+
+// parseDiscoverCursor decodes the cursor, which is the offset of the next page, up to discoverMaxOffset
+func parseDiscoverCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 || offset > discoverMaxOffset {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+// Discover returns indexable timelines ranked by recent activity and subscriber count.
+// with query.Federated, the rankings of known domains are merged into the local one.
+func (s *service) Discover(ctx context.Context, query core.TimelineDiscoverQuery) ([]core.DiscoveredTimeline, string, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.Discover")
+	defer span.End()
+
+	offset, err := parseDiscoverCursor(query.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = discoverDefaultLimit
+	}
+	if limit > discoverMaxLimit {
+		limit = discoverMaxLimit
+	}
+
+	since := time.Now().Add(-discoverActivityWindow)
+
+	var timelines []core.DiscoveredTimeline
+	if !query.Federated {
+		timelines, err = s.repository.Discover(ctx, query.Schemas, since, offset, limit+1)
+		if err != nil {
+			span.RecordError(err)
+			return nil, "", err
+		}
+		for i := range timelines {
+			timelines[i].ID = timelines[i].ID + "@" + s.config.FQDN
+		}
+	} else {
+		timelines, err = s.discoverFederated(ctx, query.Schemas, since, offset+limit+1)
+		if err != nil {
+			span.RecordError(err)
+			return nil, "", err
+		}
+		if offset >= len(timelines) {
+			timelines = []core.DiscoveredTimeline{}
+		} else {
+			timelines = timelines[offset:]
+		}
+	}
+
+	next := ""
+	if len(timelines) > limit {
+		timelines = timelines[:limit]
+		if offset+limit <= discoverMaxOffset {
+			next = strconv.Itoa(offset + limit)
+		}
+	}
+
+	return timelines, next, nil
+}
+
+func (s *service) discoverFederated(ctx context.Context, schemas []string, since time.Time, size int) ([]core.DiscoveredTimeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.discoverFederated")
+	defer span.End()
+
+	timelines, err := s.repository.Discover(ctx, schemas, since, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := range timelines {
+		timelines[i].ID = timelines[i].ID + "@" + s.config.FQDN
+	}
+
+	domains, err := s.domain.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, discoverRemoteFanout)
+
+	for _, domain := range domains {
+		if domain.ID == s.config.FQDN {
+			continue
+		}
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			remote, err := s.repository.DiscoverFromRemote(ctx, host, schemas)
+			if err != nil {
+				logging.Debug(
+					ctx, "failed to discover remote timelines",
+					slog.String("domain", host),
+					slog.String("error", err.Error()),
+					slog.String("module", "timeline"),
+				)
+				return
+			}
+
+			mu.Lock()
+			timelines = append(timelines, remote...)
+			mu.Unlock()
+		}(domain.ID)
+	}
+	wg.Wait()
+
+	sort.Slice(timelines, func(i, j int) bool {
+		si, sj := discoverScore(timelines[i]), discoverScore(timelines[j])
+		if si != sj {
+			return si > sj
+		}
+		return timelines[i].ID < timelines[j].ID
+	})
+
+	if size >= 0 && len(timelines) > size {
+		timelines = timelines[:size]
+	}
+
+	return timelines, nil
+}
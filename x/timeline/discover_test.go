@@ -0,0 +1,23 @@
+package timeline
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiscoverCursor(t *testing.T) {
+	offset, err := parseDiscoverCursor("")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, offset)
+
+	offset, err = parseDiscoverCursor(strconv.Itoa(discoverMaxOffset))
+	assert.NoError(t, err)
+	assert.Equal(t, discoverMaxOffset, offset)
+
+	for _, cursor := range []string{"-1", "abc", strconv.Itoa(discoverMaxOffset + 1), "9223372036854775800"} {
+		_, err = parseDiscoverCursor(cursor)
+		assert.Error(t, err, cursor)
+	}
+}
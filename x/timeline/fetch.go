@@ -0,0 +1,77 @@
+package timeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// remote domains asked at once by a single chunk lookup
+const remoteFetchConcurrency = 8
+
+// deadline of each remote domain. a slower domain is left out of the result
+var remoteFetchTimeout = 3 * time.Second
+
+// fetchRemotes calls fetch for every domain in queries with a bounded number of workers,
+// and merges what the domains returned. domains which fail or time out are left out,
+// so that one slow peer doesn't delay or fail the whole request.
+func fetchRemotes[Q any, V any](
+	ctx context.Context,
+	queries map[string]Q,
+	fetch func(ctx context.Context, domain string, query Q) (map[string]V, error),
+	failures *atomic.Int64,
+) map[string]V {
+	result := make(map[string]V)
+	if len(queries) == 0 {
+		return result
+	}
+
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.FetchRemotes")
+	defer span.End()
+	span.SetAttributes(attribute.Int("domains", len(queries)))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, remoteFetchConcurrency)
+
+	for domain, query := range queries {
+		wg.Add(1)
+		go func(domain string, query Q) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				failures.Add(1)
+				return
+			}
+
+			fetchCtx, cancel := context.WithTimeout(ctx, remoteFetchTimeout)
+			defer cancel()
+
+			res, err := fetch(fetchCtx, domain, query)
+			if err != nil {
+				failures.Add(1)
+				span.AddEvent("remote fetch failed", trace.WithAttributes(
+					attribute.String("domain", domain),
+					attribute.String("error", err.Error()),
+				))
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for k, v := range res {
+				result[k] = v
+			}
+		}(domain, query)
+	}
+
+	wg.Wait()
+	return result
+}
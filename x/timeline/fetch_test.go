@@ -0,0 +1,50 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchRemotesPartialResult(t *testing.T) {
+	remoteFetchTimeout = 100 * time.Millisecond
+	defer func() { remoteFetchTimeout = 3 * time.Second }()
+
+	queries := map[string]string{
+		"fast.example.com":   "t1",
+		"slow.example.com":   "t2",
+		"broken.example.com": "t3",
+	}
+
+	var running, peak atomic.Int64
+	var failures atomic.Int64
+	started := time.Now()
+	result := fetchRemotes(context.Background(), queries, func(ctx context.Context, domain string, query string) (map[string]string, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+
+		switch domain {
+		case "slow.example.com":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "broken.example.com":
+			return nil, errors.New("connection refused")
+		}
+		return map[string]string{query + "@" + domain: "ok"}, nil
+	}, &failures)
+
+	assert.Equal(t, map[string]string{"t1@fast.example.com": "ok"}, result)
+	assert.Equal(t, int64(2), failures.Load())
+	assert.Less(t, time.Since(started), time.Second)
+	assert.LessOrEqual(t, peak.Load(), int64(remoteFetchConcurrency))
+}
@@ -0,0 +1,106 @@
+package timeline
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const defaultReadRecheckInterval = time.Minute
+
+// DeniedFrame tells a realtime client the timelines it listened to but may not read
+type DeniedFrame struct {
+	Type     string   `json:"type"` // always "denied"
+	Channels []string `json:"channels"`
+}
+
+// readGuard keeps a realtime connection subscribed only to the timelines its requester may read.
+// the timelines are checked against the timeline.read policy on each listen, and again periodically
+// so that a policy or timeline update applies to the connections already open.
+// every subscription of the connection goes through the guard, so that a recheck never overwrites a newer listen
+type readGuard struct {
+	mu        sync.Mutex
+	service   core.TimelineService
+	input     chan<- []string
+	notify    func(denied []string) error
+	requested []string
+	allowed   []string
+	stopped   bool
+}
+
+func newReadGuard(service core.TimelineService, input chan<- []string, notify func(denied []string) error) *readGuard {
+	return &readGuard{service: service, input: input, notify: notify}
+}
+
+// listen subscribes to the readable ones of the timelines
+func (g *readGuard) listen(ctx context.Context, timelines []string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.requested = timelines
+	return g.applyLocked(ctx, true)
+}
+
+// recheck evaluates the policies again and resubscribes if the readable timelines changed
+func (g *readGuard) recheck(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.requested == nil {
+		return nil
+	}
+	return g.applyLocked(ctx, false)
+}
+
+func (g *readGuard) applyLocked(ctx context.Context, force bool) error {
+	if g.stopped {
+		return nil
+	}
+
+	allowed, denied := g.service.FilterReadable(ctx, g.requested)
+	if !force && slices.Equal(allowed, g.allowed) {
+		return nil
+	}
+	g.allowed = allowed
+
+	select {
+	case g.input <- allowed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if len(denied) > 0 && g.notify != nil {
+		return g.notify(denied)
+	}
+	return nil
+}
+
+// run rechecks the subscription every interval until the context is done
+func (g *readGuard) run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReadRecheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.recheck(ctx)
+		}
+	}
+}
+
+// stop keeps the guard from sending to the input anymore. the context passed to the guard
+// must be canceled first, so that a send blocked on the input gives up the lock
+func (g *readGuard) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.stopped = true
+}
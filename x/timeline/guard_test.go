@@ -0,0 +1,56 @@
+package timeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+func TestReadGuard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	requested := []string{"public@example.com", "private@example.com"}
+
+	mockService := mock_core.NewMockTimelineService(ctrl)
+	gomock.InOrder(
+		mockService.EXPECT().FilterReadable(gomock.Any(), requested).
+			Return([]string{"public@example.com"}, []string{"private@example.com"}),
+		// nothing changed, so the subscription is kept as is
+		mockService.EXPECT().FilterReadable(gomock.Any(), requested).
+			Return([]string{"public@example.com"}, []string{"private@example.com"}),
+		// the policy of the public timeline was tightened
+		mockService.EXPECT().FilterReadable(gomock.Any(), requested).
+			Return([]string{}, requested),
+	)
+
+	input := make(chan []string, 1)
+	var notified [][]string
+	guard := newReadGuard(mockService, input, func(denied []string) error {
+		notified = append(notified, denied)
+		return nil
+	})
+
+	assert.NoError(t, guard.recheck(ctx))
+	assert.Len(t, input, 0)
+
+	assert.NoError(t, guard.listen(ctx, requested))
+	assert.Equal(t, []string{"public@example.com"}, <-input)
+	assert.Equal(t, [][]string{{"private@example.com"}}, notified)
+
+	assert.NoError(t, guard.recheck(ctx))
+	assert.Len(t, input, 0)
+
+	assert.NoError(t, guard.recheck(ctx))
+	assert.Equal(t, []string{}, <-input)
+	assert.Equal(t, requested, notified[1])
+
+	guard.stop()
+	assert.NoError(t, guard.listen(ctx, requested))
+	assert.Len(t, input, 0)
+}
@@ -2,17 +2,22 @@
 package timeline
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/etag"
+	"github.com/totegamma/concurrent/internal/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 )
@@ -25,23 +30,35 @@ type Handler interface {
 	Recent(c echo.Context) error
 	Range(c echo.Context) error
 	List(c echo.Context) error
+	Discover(c echo.Context) error
 	ListMine(c echo.Context) error
 	GetChunks(c echo.Context) error
 	Realtime(c echo.Context) error
+	RealtimeSSE(c echo.Context) error
 	Query(c echo.Context) error
 
 	GetChunkItr(c echo.Context) error
 	GetChunkBody(c echo.Context) error
 	Retracted(c echo.Context) error
+	Bridges(c echo.Context) error
+	Merge(c echo.Context) error
 }
 
 type handler struct {
-	service core.TimelineService
+	service     core.TimelineService
+	association core.AssociationService
+	realtime    RealtimeConfig
 }
 
 // NewHandler creates a new handler
-func NewHandler(service core.TimelineService) Handler {
-	return &handler{service: service}
+func NewHandler(service core.TimelineService, association core.AssociationService, realtime RealtimeConfig) Handler {
+	if realtime.QueueSize <= 0 {
+		realtime.QueueSize = defaultRealtimeQueueSize
+	}
+	if realtime.OverflowPolicy == "" {
+		realtime.OverflowPolicy = OverflowDropOldest
+	}
+	return &handler{service: service, association: association, realtime: realtime}
 }
 
 // Get returns a timeline by ID
@@ -57,7 +74,7 @@ func (h handler) Get(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": timeline})
+	return etag.Respond(c, etag.FromSignatures(timeline.Signature), timeline.MDate, timeline)
 }
 
 // Recent returns recent messages in some timelines
@@ -84,7 +101,7 @@ func (h handler) Recent(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": messages})
+	return c.JSON(http.StatusOK, h.itemsResponse(ctx, c, timelines, subscription, messages))
 }
 
 // Range returns messages since to until in specified timelines
@@ -122,7 +139,7 @@ func (h handler) Range(c echo.Context) error {
 			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 		}
 
-		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": messages})
+		return c.JSON(http.StatusOK, h.itemsResponse(ctx, c, timelines, subscription, messages))
 
 	} else if queryUntil != "" {
 		untilEpoch, err := strconv.ParseInt(queryUntil, 10, 64)
@@ -143,12 +160,57 @@ func (h handler) Range(c echo.Context) error {
 			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 		}
 
-		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": messages})
+		return c.JSON(http.StatusOK, h.itemsResponse(ctx, c, timelines, subscription, messages))
 	} else {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
 	}
 }
 
+// itemsResponse is the response of the items read from the timelines. the requested timelines which were
+// merged into another one are listed in redirects, so that clients can follow them
+func (h handler) itemsResponse(ctx context.Context, c echo.Context, timelines []string, subscription string, items []core.TimelineItem) echo.Map {
+	response := echo.Map{"status": "ok", "content": h.includeItems(ctx, c, items)}
+	if subscription != "" {
+		return response
+	}
+
+	redirects, err := h.service.ResolveAliases(ctx, timelines)
+	if err == nil && len(redirects) > 0 {
+		response["redirects"] = redirects
+	}
+	return response
+}
+
+// MergeRequest is the body of a merge of a timeline
+type MergeRequest struct {
+	Into string `json:"into"`
+}
+
+// Merge marks a timeline of the requester as merged into another timeline of the requester
+func (h handler) Merge(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.Merge")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	var request MergeRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return err
+	}
+
+	timeline, err := h.service.Merge(ctx, c.Param("id"), request.Into, requester)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": timeline})
+}
+
 // List returns timeline ids which filtered by specific schema
 func (h handler) List(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.List")
@@ -163,6 +225,41 @@ func (h handler) List(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": list})
 }
 
+// Discover returns indexable timelines ranked by recent activity and subscriber count
+func (h handler) Discover(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.Discover")
+	defer span.End()
+
+	query := core.TimelineDiscoverQuery{
+		Cursor:    c.QueryParam("cursor"),
+		Federated: c.QueryParam("federated") == "true",
+	}
+
+	if schema := c.QueryParam("schema"); schema != "" {
+		query.Schemas = strings.Split(schema, ",")
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid limit"})
+		}
+		query.Limit = limit
+	}
+
+	if _, err := parseDiscoverCursor(query.Cursor); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	timelines, next, err := h.service.Discover(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": timelines, "next": next})
+}
+
 // ListMine returns timeline ids which filtered by specific schema
 func (h handler) ListMine(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.ListMine")
@@ -186,6 +283,10 @@ func (h handler) GetChunks(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.GetChunks")
 	defer span.End()
 
+	// chunks of private timelines are filtered by the requester
+	_, requester := ctx.Value(core.RequesterIdCtxKey).(string)
+	etag.VaryByRequester(c, requester)
+
 	timelinesStr := c.QueryParam("timelines")
 	timelines := strings.Split(timelinesStr, ",")
 
@@ -195,8 +296,7 @@ func (h handler) GetChunks(c echo.Context) error {
 		span.RecordError(err)
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
 	}
-	time := time.Unix(timeInt, 0)
-	epoch := core.Time2Chunk(time)
+	epoch := core.Time2Chunk(time.Unix(timeInt, 0))
 	fmt.Println("epoch", epoch)
 
 	chunks, err := h.service.GetChunks(ctx, timelines, epoch)
@@ -205,13 +305,21 @@ func (h handler) GetChunks(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": chunks})
+	chunks = h.includeChunks(ctx, c, chunks)
+	if wantsNDJSON(c) {
+		return streamChunks(c, chunks)
+	}
+	return etag.Respond(c, etag.FromContent(chunks), time.Time{}, chunks)
 }
 
 func (h handler) GetChunkItr(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.GetChunkItr")
 	defer span.End()
 
+	// chunks of private timelines are filtered by the requester
+	_, requester := ctx.Value(core.RequesterIdCtxKey).(string)
+	etag.VaryByRequester(c, requester)
+
 	timelinesStr := c.QueryParam("timelines")
 	timelines := strings.Split(timelinesStr, ",")
 
@@ -222,13 +330,17 @@ func (h handler) GetChunkItr(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": table})
+	return etag.Respond(c, etag.FromContent(table), time.Time{}, table)
 }
 
 func (h handler) GetChunkBody(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "Timeline.Handler.GetChunkBody")
 	defer span.End()
 
+	// chunks of private timelines are filtered by the requester
+	_, requester := ctx.Value(core.RequesterIdCtxKey).(string)
+	etag.VaryByRequester(c, requester)
+
 	queryStr := c.QueryParam("query")
 
 	query := make(map[string]string)
@@ -246,7 +358,11 @@ func (h handler) GetChunkBody(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": chunks})
+	chunks = h.includeChunks(ctx, c, chunks)
+	if wantsNDJSON(c) {
+		return streamChunks(c, chunks)
+	}
+	return etag.Respond(c, etag.FromContent(chunks), time.Time{}, chunks)
 }
 
 func (h handler) Query(c echo.Context) error {
@@ -281,6 +397,25 @@ func (h handler) Query(c echo.Context) error {
 		}
 	}
 
+	if wantsNDJSON(c) {
+		// the items are written as they are read, so an export is not bound by the page size
+		maxLimit := maxStreamQueryLimit
+		if requester, _ := ctx.Value(core.RequesterIdCtxKey).(string); requester == "" {
+			maxLimit = maxAnonymousStreamQueryLimit
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		writer := newNDJSONWriter(c)
+		err = h.service.QueryStream(ctx, timelineID, schema, owner, author, until, limit, func(item core.TimelineItem) error {
+			return writer.write(item)
+		})
+		if err != nil {
+			span.RecordError(err)
+		}
+		return writer.close(err)
+	}
+
 	if limit > 100 {
 		limit = 100
 	}
@@ -310,6 +445,14 @@ func (h handler) Retracted(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": items})
 }
 
+// Bridges returns the status of the connections relaying the events of remote timelines
+func (h handler) Bridges(c echo.Context) error {
+	_, span := tracer.Start(c.Request().Context(), "Timeline.Handler.Bridges")
+	defer span.End()
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": h.service.GetBridges()})
+}
+
 // ---
 
 var upgrader = websocket.Upgrader{
@@ -320,15 +463,20 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// Request is a message from the websocket client.
+// protocol v2 clients start with "hello", or "resume" with the token and the last seq they received,
+// and "ack" the seq of the events they processed
 type Request struct {
 	Type     string   `json:"type"`
 	Channels []string `json:"channels"`
+	Token    string   `json:"token,omitempty"`
+	Seq      int64    `json:"seq,omitempty"`
 }
 
 func (h handler) Realtime(c echo.Context) error {
 	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
-		slog.Error(
+		logging.Error(c.Request().Context(),
 			"Failed to upgrade WebSocket",
 			slog.String("error", err.Error()),
 			slog.String("module", "socket"),
@@ -338,23 +486,70 @@ func (h handler) Realtime(c echo.Context) error {
 		ws.Close()
 	}()
 
-	ctx := c.Request().Context()
+	ctx, cancel := context.WithCancel(c.Request().Context())
 
+	// the channels are never closed, as Realtime and the guard may still be sending when the handler returns.
+	// they give up once ctx is canceled
 	input := make(chan []string)
-	defer close(input)
 	output := make(chan core.Event)
-	defer close(output)
+	var guard *readGuard
+	defer func() {
+		cancel()
+		guard.stop()
+	}()
 
 	go h.service.Realtime(ctx, input, output)
 
-	quit := make(chan struct{})
+	quit := make(chan struct{}, 2)
+	queue := newEventQueue(h.realtime.QueueSize, h.realtime.OverflowPolicy)
+
+	// the writer, and the replay of a resumed session, write to the socket
+	var writeMu sync.Mutex
+	write := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return ws.WriteJSON(v)
+	}
+
+	guard = newReadGuard(h.service, input, func(denied []string) error {
+		return write(DeniedFrame{Type: "denied", Channels: denied})
+	})
+	go guard.run(ctx, h.realtime.ReadRecheckInterval)
+
+	var sess *session
+	if h.realtime.Sessions != nil {
+		sess = newSession(h.realtime.Sessions)
+	}
+
+	// writer runs apart from the subscription so that a slow client only fills its own queue
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-queue.ready:
+				for _, event := range queue.drain() {
+					err := write(event)
+					if err != nil {
+						logging.Error(
+							ctx, "Error writing message",
+							slog.String("error", err.Error()),
+							slog.String("module", "socket"),
+						)
+						quit <- struct{}{}
+						return
+					}
+				}
+			}
+		}
+	}()
 
 	go func() {
 		for {
 			var req Request
 			err := ws.ReadJSON(&req)
 			if err != nil {
-				slog.ErrorContext(
+				logging.Error(
 					ctx, "Error reading JSON",
 					slog.String("error", err.Error()),
 					slog.String("module", "socket"),
@@ -365,15 +560,52 @@ func (h handler) Realtime(c echo.Context) error {
 
 			switch req.Type {
 			case "listen":
-				input <- req.Channels
-				slog.DebugContext(
+				if sess != nil {
+					err := sess.listen(ctx, req.Channels)
+					if err != nil {
+						logging.Warn(
+							ctx, "Failed to record session channels",
+							slog.String("error", err.Error()),
+							slog.String("module", "socket"),
+						)
+					}
+				}
+				err := guard.listen(ctx, req.Channels)
+				if err != nil {
+					logging.Warn(
+						ctx, "Failed to subscribe",
+						slog.String("error", err.Error()),
+						slog.String("module", "socket"),
+					)
+				}
+				logging.Debug(
 					ctx, fmt.Sprintf("Socket subscribe: %s", req.Channels),
 					slog.String("module", "socket"),
 				)
 			case "h": // heartbeat
 				// do nothing
+			case "hello", "resume", "ack":
+				if sess == nil {
+					logging.Info(
+						ctx, "Protocol v2 is not enabled",
+						slog.String("type", req.Type),
+						slog.String("module", "socket"),
+					)
+					continue
+				}
+				err := h.handleSession(ctx, sess, req, guard, write)
+				if err != nil {
+					logging.Error(
+						ctx, "Error handling session request",
+						slog.String("type", req.Type),
+						slog.String("error", err.Error()),
+						slog.String("module", "socket"),
+					)
+					quit <- struct{}{}
+					return
+				}
 			default:
-				slog.InfoContext(
+				logging.Info(
 					ctx, "Unknown request type",
 					slog.String("type", req.Type),
 					slog.String("module", "socket"),
@@ -386,10 +618,165 @@ func (h handler) Realtime(c echo.Context) error {
 		select {
 		case <-quit:
 			return nil
-		case items := <-output:
-			err := ws.WriteJSON(items)
+		case event := <-output:
+			if sess != nil {
+				event, err = sess.stamp(ctx, event)
+				if errors.Is(err, ErrSessionTakenOver) {
+					ws.WriteControl(
+						websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session resumed elsewhere"),
+						time.Now().Add(time.Second),
+					)
+					return nil
+				}
+				if err != nil {
+					// the event is still delivered, only a later resume misses it
+					logging.Warn(
+						ctx, "Failed to record session event",
+						slog.String("error", err.Error()),
+						slog.String("module", "socket"),
+					)
+				}
+			}
+			if !queue.push(event) {
+				logging.Warn(
+					ctx, "Realtime queue overflowed. closing connection",
+					slog.Int("size", h.realtime.QueueSize),
+					slog.String("module", "socket"),
+				)
+				ws.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"),
+					time.Now().Add(time.Second),
+				)
+				return nil
+			}
+		}
+	}
+}
+
+// handleSession serves the protocol v2 requests
+func (h handler) handleSession(ctx context.Context, sess *session, req Request, guard *readGuard, write func(any) error) error {
+	switch req.Type {
+	case "hello":
+		frame, err := sess.open(ctx)
+		if err != nil {
+			return err
+		}
+		return write(frame)
+	case "resume":
+		channels, err := sess.resume(ctx, req.Token, req.Seq, write)
+		if err != nil {
+			return err
+		}
+		if len(channels) > 0 {
+			// the policies may have changed while the client was away
+			err := guard.listen(ctx, channels)
+			if err != nil {
+				return err
+			}
+			logging.Debug(
+				ctx, fmt.Sprintf("Socket resumed: %s", channels),
+				slog.String("module", "socket"),
+			)
+		}
+	case "ack":
+		err := sess.ack(ctx, req.Seq)
+		if err != nil {
+			// an ack only frees the replay buffer, the connection goes on
+			logging.Warn(
+				ctx, "Failed to ack session events",
+				slog.String("error", err.Error()),
+				slog.String("module", "socket"),
+			)
+		}
+	}
+	return nil
+}
+
+const sseHeartbeatInterval = 30 * time.Second
+
+// RealtimeSSE streams events of given timelines with Server-Sent Events.
+// this is a fallback for clients which cannot keep a websocket connection.
+func (h handler) RealtimeSSE(c echo.Context) error {
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+
+	timelinesStr := c.QueryParam("timelines")
+	if timelinesStr == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "timelines is required"})
+	}
+	timelines := strings.Split(timelinesStr, ",")
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	input := make(chan []string, 1)
+	output := make(chan core.Event)
+
+	go h.service.Realtime(ctx, input, output)
+
+	// the guard writes the denied timelines from its own goroutine
+	var writeMu sync.Mutex
+	send := func(format string, args ...any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err := fmt.Fprintf(res, format, args...)
+		if err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	}
+
+	guard := newReadGuard(h.service, input, func(denied []string) error {
+		data, err := json.Marshal(DeniedFrame{Type: "denied", Channels: denied})
+		if err != nil {
+			return err
+		}
+		return send("event: denied\ndata: %s\n\n", data)
+	})
+	err := guard.listen(ctx, timelines)
+	if err != nil {
+		return nil
+	}
+	go guard.run(ctx, h.realtime.ReadRecheckInterval)
+
+	logging.Debug(
+		ctx, fmt.Sprintf("SSE subscribe: %s", timelines),
+		slog.String("module", "socket"),
+	)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			err := send(": heartbeat\n\n")
+			if err != nil {
+				return nil
+			}
+		case event := <-output:
+			data, err := json.Marshal(event)
+			if err != nil {
+				logging.Error(
+					ctx, "Error marshaling event",
+					slog.String("error", err.Error()),
+					slog.String("module", "socket"),
+				)
+				continue
+			}
+			err = send("data: %s\n\n", data)
 			if err != nil {
-				slog.ErrorContext(
+				logging.Error(
 					ctx, "Error writing message",
 					slog.String("error", err.Error()),
 					slog.String("module", "socket"),
@@ -399,3 +786,77 @@ func (h handler) Realtime(c echo.Context) error {
 		}
 	}
 }
+
+// wantsAssociationCounts tells whether the client asked to embed the association counts with include=associationCounts
+func wantsAssociationCounts(c echo.Context) bool {
+	for _, include := range strings.Split(c.QueryParam("include"), ",") {
+		if include == "associationCounts" {
+			return true
+		}
+	}
+	return false
+}
+
+// associationCounts returns the association counts of the messages in the items.
+// the counts are optional, so a failure is recorded and the items are served without them
+func (h handler) associationCounts(ctx context.Context, items ...[]core.TimelineItem) map[string]map[string]int64 {
+	ctx, span := tracer.Start(ctx, "Timeline.Handler.associationCounts")
+	defer span.End()
+
+	seen := make(map[string]bool)
+	targets := make([]string, 0)
+	for _, list := range items {
+		for _, item := range list {
+			if len(item.ResourceID) == 0 || item.ResourceID[0] != 'm' || seen[item.ResourceID] {
+				continue
+			}
+			seen[item.ResourceID] = true
+			targets = append(targets, item.ResourceID)
+		}
+	}
+
+	counts, err := h.association.GetCountsBySchemaForTargets(ctx, targets)
+	if err != nil {
+		span.RecordError(err)
+		return nil
+	}
+	return counts
+}
+
+// embedAssociationCounts returns a copy of the items with the counts set, leaving the cached items untouched
+func embedAssociationCounts(items []core.TimelineItem, counts map[string]map[string]int64) []core.TimelineItem {
+	embedded := make([]core.TimelineItem, len(items))
+	for i, item := range items {
+		item.AssociationCounts = counts[item.ResourceID]
+		embedded[i] = item
+	}
+	return embedded
+}
+
+// includeItems embeds what the client asked for into the items
+func (h handler) includeItems(ctx context.Context, c echo.Context, items []core.TimelineItem) []core.TimelineItem {
+	if !wantsAssociationCounts(c) {
+		return items
+	}
+	return embedAssociationCounts(items, h.associationCounts(ctx, items))
+}
+
+// includeChunks embeds what the client asked for into the items of the chunks, with one lookup for all of them
+func (h handler) includeChunks(ctx context.Context, c echo.Context, chunks map[string]core.Chunk) map[string]core.Chunk {
+	if !wantsAssociationCounts(c) {
+		return chunks
+	}
+
+	lists := make([][]core.TimelineItem, 0, len(chunks))
+	for _, chunk := range chunks {
+		lists = append(lists, chunk.Items)
+	}
+	counts := h.associationCounts(ctx, lists...)
+
+	embedded := make(map[string]core.Chunk, len(chunks))
+	for key, chunk := range chunks {
+		chunk.Items = embedAssociationCounts(chunk.Items, counts)
+		embedded[key] = chunk
+	}
+	return embedded
+}
@@ -18,8 +18,12 @@ import (
 
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
 )
 
+// SubscriptionUpdatedChannel is the event bus channel on which the keepers learn about new realtime subscriptions
+const SubscriptionUpdatedChannel = "concrnt:subscription:updated"
+
 var (
 	pingInterval      = 10 * time.Second
 	disconnectTimeout = 30 * time.Second
@@ -32,16 +36,17 @@ type Keeper interface {
 	GetRemoteSubs() []string
 	GetCurrentSubs(ctx context.Context) []string
 	GetMetrics() map[string]int64
+	GetBridges() []core.BridgeStatus
 }
 
 type keeper struct {
 	rdb    *redis.Client
-	mc     *memcache.Client
+	mc     cache.Cache
 	client client.Client
 	config core.Config
 }
 
-func NewKeeper(rdb *redis.Client, mc *memcache.Client, client client.Client, config core.Config) Keeper {
+func NewKeeper(rdb *redis.Client, mc cache.Cache, client client.Client, config core.Config) Keeper {
 	return &keeper{
 		rdb:    rdb,
 		mc:     mc,
@@ -173,6 +178,7 @@ func (k *keeper) deleteExcessiveSubs(ctx context.Context) {
 
 		delete(remoteSubs, domain)
 		delete(remoteConns, domain)
+		removeBridge(domain)
 	}
 
 	slog.Info(
@@ -184,9 +190,17 @@ func (k *keeper) deleteExcessiveSubs(ctx context.Context) {
 
 // RemoteSubRoutine subscribes to a remote server
 func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines []string) {
+	bridgeTimelines(domain, len(timelines))
+
 	if _, ok := remoteConns[domain]; !ok {
 		// new server, create new connection
 
+		// failing servers are retried with backoff
+		if !bridgeReady(domain, time.Now()) {
+			return
+		}
+		bridgeConnecting(domain)
+
 		// check server availability
 		domainInfo, err := k.client.GetDomain(ctx, domain, nil)
 		if err != nil {
@@ -195,6 +209,7 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 				slog.String("module", "agent"),
 				slog.String("group", "realtime"),
 			)
+			bridgeFailed(domain, nil, err, time.Now())
 			return
 		}
 		if domainInfo.Dimension != k.config.Dimension {
@@ -203,6 +218,7 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 				slog.String("module", "agent"),
 				slog.String("group", "realtime"),
 			)
+			bridgeFailed(domain, nil, fmt.Errorf("domain dimension mismatch"), time.Now())
 			return
 		}
 
@@ -219,19 +235,23 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 			)
 
 			delete(remoteConns, domain)
+			bridgeFailed(domain, nil, err, time.Now())
 			return
 		}
 
 		remoteConns[domain] = c
+		bridgeConnected(domain, c, time.Now())
 
 		messageChan := make(chan []byte)
 		// goroutine for reading messages from remote server
 		go func(c *websocket.Conn, messageChan chan<- []byte) {
+			var err error = fmt.Errorf("connection closed")
 			defer func() {
 				if c != nil {
 					c.Close()
 				}
 				delete(remoteConns, domain)
+				bridgeFailed(domain, c, err, time.Now())
 				slog.Info(
 					fmt.Sprintf("remote connection closed: %s", domain),
 					slog.String("module", "agent"),
@@ -248,7 +268,8 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 					)
 					break
 				}
-				_, message, err := c.ReadMessage()
+				var message []byte
+				_, message, err = c.ReadMessage()
 				if err != nil {
 					slog.Error(
 						fmt.Sprintf("fail to read message: %v", err),
@@ -264,12 +285,14 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 		// goroutine for relay messages to clients
 		go func(c *websocket.Conn, messageChan <-chan []byte) {
 			pingTicker := time.NewTicker(pingInterval)
+			var reason error = fmt.Errorf("connection closed")
 			defer func() {
 				if c != nil {
 					c.Close()
 				}
 				pingTicker.Stop()
 				delete(remoteConns, domain)
+				bridgeFailed(domain, c, reason, time.Now())
 				slog.Info(
 					fmt.Sprintf("remote connection closed: %s", domain),
 					slog.String("module", "agent"),
@@ -280,6 +303,7 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 			var lastPong time.Time = time.Now()
 			c.SetPongHandler(func(string) error {
 				lastPong = time.Now()
+				bridgeHeartbeat(domain, lastPong)
 				return nil
 			})
 
@@ -322,7 +346,7 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 					}
 
 					// update cache
-					json, err := json.Marshal(event.Item)
+					val, err := chunkCodec.Encode(*event.Item)
 					if err != nil {
 						slog.Error(
 							"fail to Marshall item",
@@ -332,7 +356,6 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 						)
 						continue
 					}
-					val := "," + string(json)
 
 					// update cache
 					// Note: see x/timeline/repository.go CreateItem
@@ -342,16 +365,28 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 					// fmt.Println("[keep] set cache", itrKey, " -> ", bodyKey)
 					err = k.mc.Replace(&memcache.Item{Key: itrKey, Value: []byte(epoch)})
 					// fmt.Println("[keep] replace err", err)
-					err = k.mc.Prepend(&memcache.Item{Key: bodyKey, Value: []byte(val)})
+					err = k.mc.Prepend(&memcache.Item{Key: bodyKey, Value: val})
 					// fmt.Println("[keep] prepend err", err)
+					invalidateRolledOver(k.mc, event.Timeline, epoch, time.Now())
 
 				case <-pingTicker.C:
-					if err := c.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
+					// the ping checks the connection, the heartbeat keeps proxies which drop control frames from closing it
+					if err := c.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(pingInterval)); err != nil {
 						slog.Error(
 							fmt.Sprintf("fail to send ping message: %v", err),
 							slog.String("module", "agent"),
 							slog.String("group", "realtime"),
 						)
+						reason = err
+						return
+					}
+					if err := bridgeWriteJSON(domain, c, channelRequest{Type: "h"}); err != nil {
+						slog.Error(
+							fmt.Sprintf("fail to send heartbeat: %v", err),
+							slog.String("module", "agent"),
+							slog.String("group", "realtime"),
+						)
+						reason = err
 						return
 					}
 					if lastPong.Before(time.Now().Add(-disconnectTimeout)) {
@@ -360,6 +395,7 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 							slog.String("module", "agent"),
 							slog.String("group", "realtime"),
 						)
+						reason = fmt.Errorf("pong timeout")
 						return
 					}
 				}
@@ -370,7 +406,8 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 		Type:     "listen",
 		Channels: timelines,
 	}
-	err := remoteConns[domain].WriteJSON(request)
+	conn := remoteConns[domain]
+	err := bridgeWriteJSON(domain, conn, request)
 	if err != nil {
 		slog.Error(
 			fmt.Sprintf("fail to send subscribe request to remote server %v", domain),
@@ -380,6 +417,7 @@ func (k *keeper) remoteSubRoutine(ctx context.Context, domain string, timelines
 		)
 
 		delete(remoteConns, domain)
+		bridgeFailed(domain, conn, err, time.Now())
 		return
 	}
 	slog.Debug(
@@ -401,7 +439,7 @@ func (k *keeper) connectionkeeperRoutine(ctx context.Context) {
 		case <-ticker.C:
 			k.createInsufficientSubs(ctx)
 			for domain := range remoteSubs {
-				if _, ok := remoteConns[domain]; !ok {
+				if _, ok := remoteConns[domain]; !ok && bridgeReady(domain, time.Now()) {
 					slog.Info(
 						fmt.Sprintf("broken connection found: %s", domain),
 						slog.String("module", "agent"),
@@ -455,7 +493,7 @@ func (k *keeper) chunkUpdaterRoutine(ctx context.Context) {
 // watchEventRoutine
 func (k *keeper) watchEventRoutine(ctx context.Context) {
 
-	pubsub := k.rdb.Subscribe(ctx, "concrnt:subscription:updated")
+	pubsub := k.rdb.Subscribe(ctx, SubscriptionUpdatedChannel)
 	defer pubsub.Close()
 
 	psch := pubsub.Channel()
@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	core "github.com/totegamma/concurrent/core"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -39,6 +40,20 @@ func (m *MockKeeper) EXPECT() *MockKeeperMockRecorder {
 	return m.recorder
 }
 
+// GetBridges mocks base method.
+func (m *MockKeeper) GetBridges() []core.BridgeStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBridges")
+	ret0, _ := ret[0].([]core.BridgeStatus)
+	return ret0
+}
+
+// GetBridges indicates an expected call of GetBridges.
+func (mr *MockKeeperMockRecorder) GetBridges() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBridges", reflect.TypeOf((*MockKeeper)(nil).GetBridges))
+}
+
 // GetCurrentSubs mocks base method.
 func (m *MockKeeper) GetCurrentSubs(ctx context.Context) []string {
 	m.ctrl.T.Helper()
@@ -56,6 +56,37 @@ func (mr *MockRepositoryMockRecorder) Count(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockRepository)(nil).Count), ctx)
 }
 
+// CountTimelineByAuthorOwned mocks base method.
+func (m *MockRepository) CountTimelineByAuthorOwned(ctx context.Context, author string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTimelineByAuthorOwned", ctx, author)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTimelineByAuthorOwned indicates an expected call of CountTimelineByAuthorOwned.
+func (mr *MockRepositoryMockRecorder) CountTimelineByAuthorOwned(ctx, author any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTimelineByAuthorOwned", reflect.TypeOf((*MockRepository)(nil).CountTimelineByAuthorOwned), ctx, author)
+}
+
+// CountWrite mocks base method.
+func (m *MockRepository) CountWrite(ctx context.Context, timelineID, author string, window time.Duration) (int64, time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountWrite", ctx, timelineID, author, window)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(time.Duration)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CountWrite indicates an expected call of CountWrite.
+func (mr *MockRepositoryMockRecorder) CountWrite(ctx, timelineID, author, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountWrite", reflect.TypeOf((*MockRepository)(nil).CountWrite), ctx, timelineID, author, window)
+}
+
 // CreateItem mocks base method.
 func (m *MockRepository) CreateItem(ctx context.Context, item core.TimelineItem) (core.TimelineItem, error) {
 	m.ctrl.T.Helper()
@@ -113,6 +144,80 @@ func (mr *MockRepositoryMockRecorder) DeleteTimeline(ctx, key any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTimeline", reflect.TypeOf((*MockRepository)(nil).DeleteTimeline), ctx, key)
 }
 
+// Discover mocks base method.
+func (m *MockRepository) Discover(ctx context.Context, schemas []string, since time.Time, offset, limit int) ([]core.DiscoveredTimeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Discover", ctx, schemas, since, offset, limit)
+	ret0, _ := ret[0].([]core.DiscoveredTimeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Discover indicates an expected call of Discover.
+func (mr *MockRepositoryMockRecorder) Discover(ctx, schemas, since, offset, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Discover", reflect.TypeOf((*MockRepository)(nil).Discover), ctx, schemas, since, offset, limit)
+}
+
+// DiscoverFromRemote mocks base method.
+func (m *MockRepository) DiscoverFromRemote(ctx context.Context, host string, schemas []string) ([]core.DiscoveredTimeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiscoverFromRemote", ctx, host, schemas)
+	ret0, _ := ret[0].([]core.DiscoveredTimeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiscoverFromRemote indicates an expected call of DiscoverFromRemote.
+func (mr *MockRepositoryMockRecorder) DiscoverFromRemote(ctx, host, schemas any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiscoverFromRemote", reflect.TypeOf((*MockRepository)(nil).DiscoverFromRemote), ctx, host, schemas)
+}
+
+// ExpireItems mocks base method.
+func (m *MockRepository) ExpireItems(ctx context.Context, now time.Time, limit int) ([]core.TimelineItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireItems", ctx, now, limit)
+	ret0, _ := ret[0].([]core.TimelineItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireItems indicates an expected call of ExpireItems.
+func (mr *MockRepositoryMockRecorder) ExpireItems(ctx, now, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireItems", reflect.TypeOf((*MockRepository)(nil).ExpireItems), ctx, now, limit)
+}
+
+// GetAliases mocks base method.
+func (m *MockRepository) GetAliases(ctx context.Context) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAliases", ctx)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAliases indicates an expected call of GetAliases.
+func (mr *MockRepositoryMockRecorder) GetAliases(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAliases", reflect.TypeOf((*MockRepository)(nil).GetAliases), ctx)
+}
+
+// GetBridges mocks base method.
+func (m *MockRepository) GetBridges() []core.BridgeStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBridges")
+	ret0, _ := ret[0].([]core.BridgeStatus)
+	return ret0
+}
+
+// GetBridges indicates an expected call of GetBridges.
+func (mr *MockRepositoryMockRecorder) GetBridges() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBridges", reflect.TypeOf((*MockRepository)(nil).GetBridges))
+}
+
 // GetImmediateItems mocks base method.
 func (m *MockRepository) GetImmediateItems(ctx context.Context, timelineID string, since time.Time, limit int) ([]core.TimelineItem, error) {
 	m.ctrl.T.Helper()
@@ -172,6 +277,21 @@ func (mr *MockRepositoryMockRecorder) GetNormalizationCache(ctx, timelineID any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNormalizationCache", reflect.TypeOf((*MockRepository)(nil).GetNormalizationCache), ctx, timelineID)
 }
 
+// GetReaders mocks base method.
+func (m *MockRepository) GetReaders(ctx context.Context) (map[string][]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReaders", ctx)
+	ret0, _ := ret[0].(map[string][]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReaders indicates an expected call of GetReaders.
+func (mr *MockRepositoryMockRecorder) GetReaders(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReaders", reflect.TypeOf((*MockRepository)(nil).GetReaders), ctx)
+}
+
 // GetRecentItems mocks base method.
 func (m *MockRepository) GetRecentItems(ctx context.Context, timelineID string, until time.Time, limit int) ([]core.TimelineItem, error) {
 	m.ctrl.T.Helper()
@@ -337,6 +457,36 @@ func (mr *MockRepositoryMockRecorder) LookupChunkItrs(ctx, timelines, epoch any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupChunkItrs", reflect.TypeOf((*MockRepository)(nil).LookupChunkItrs), ctx, timelines, epoch)
 }
 
+// Merge mocks base method.
+func (m *MockRepository) Merge(ctx context.Context, timeline, into string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, timeline, into)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockRepositoryMockRecorder) Merge(ctx, timeline, into any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockRepository)(nil).Merge), ctx, timeline, into)
+}
+
+// PeekWrite mocks base method.
+func (m *MockRepository) PeekWrite(ctx context.Context, timelineID, author string) (int64, time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PeekWrite", ctx, timelineID, author)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(time.Duration)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PeekWrite indicates an expected call of PeekWrite.
+func (mr *MockRepositoryMockRecorder) PeekWrite(ctx, timelineID, author any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PeekWrite", reflect.TypeOf((*MockRepository)(nil).PeekWrite), ctx, timelineID, author)
+}
+
 // PublishEvent mocks base method.
 func (m *MockRepository) PublishEvent(ctx context.Context, event core.Event) error {
 	m.ctrl.T.Helper()
@@ -366,6 +516,20 @@ func (mr *MockRepositoryMockRecorder) Query(ctx, timelineID, schema, owner, auth
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockRepository)(nil).Query), ctx, timelineID, schema, owner, author, until, limit)
 }
 
+// QueryEach mocks base method.
+func (m *MockRepository) QueryEach(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(core.TimelineItem) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryEach", ctx, timelineID, schema, owner, author, until, limit, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QueryEach indicates an expected call of QueryEach.
+func (mr *MockRepositoryMockRecorder) QueryEach(ctx, timelineID, schema, owner, author, until, limit, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryEach", reflect.TypeOf((*MockRepository)(nil).QueryEach), ctx, timelineID, schema, owner, author, until, limit, fn)
+}
+
 // SetNormalizationCache mocks base method.
 func (m *MockRepository) SetNormalizationCache(ctx context.Context, timelineID, value string) error {
 	m.ctrl.T.Helper()
@@ -394,6 +558,21 @@ func (mr *MockRepositoryMockRecorder) Subscribe(ctx, channels, event any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockRepository)(nil).Subscribe), ctx, channels, event)
 }
 
+// UpdateTimeline mocks base method.
+func (m *MockRepository) UpdateTimeline(ctx context.Context, timeline core.Timeline, version int64) (core.Timeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTimeline", ctx, timeline, version)
+	ret0, _ := ret[0].(core.Timeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTimeline indicates an expected call of UpdateTimeline.
+func (mr *MockRepositoryMockRecorder) UpdateTimeline(ctx, timeline, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTimeline", reflect.TypeOf((*MockRepository)(nil).UpdateTimeline), ctx, timeline, version)
+}
+
 // UpsertTimeline mocks base method.
 func (m *MockRepository) UpsertTimeline(ctx context.Context, timeline core.Timeline) (core.Timeline, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,101 @@
+package timeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// lines are flushed to the client in groups, so that a slow reader does not cost a write per row
+	ndjsonFlushLines = 64
+	// maxStreamQueryLimit caps the limit of a streamed Query. the items are not held in memory,
+	// but each stream holds a connection of the database while it is read
+	maxStreamQueryLimit = 10000
+	// anonymous streams are capped lower, since they can be opened by anyone
+	maxAnonymousStreamQueryLimit = 1000
+)
+
+// wantsNDJSON tells whether the client asked for newline delimited JSON with Accept: application/x-ndjson
+func wantsNDJSON(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), ndjsonContentType)
+}
+
+// ndjsonWriter writes a value per line of the response as soon as it is given.
+// the status is sent with the first line, so an error after it is written as the last line instead
+type ndjsonWriter struct {
+	c       echo.Context
+	encoder *json.Encoder
+	started bool
+	pending int
+}
+
+func newNDJSONWriter(c echo.Context) *ndjsonWriter {
+	return &ndjsonWriter{c: c, encoder: json.NewEncoder(c.Response())}
+}
+
+func (w *ndjsonWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.c.Response().Header().Set(echo.HeaderContentType, ndjsonContentType)
+	w.c.Response().WriteHeader(http.StatusOK)
+}
+
+func (w *ndjsonWriter) write(v any) error {
+	w.start()
+	err := w.encoder.Encode(v)
+	if err != nil {
+		return err
+	}
+	w.pending++
+	if w.pending >= ndjsonFlushLines {
+		w.c.Response().Flush()
+		w.pending = 0
+	}
+	return nil
+}
+
+// close ends the stream. an error before the first line is returned for the error handler to respond with
+func (w *ndjsonWriter) close(err error) error {
+	if err != nil {
+		if !w.started {
+			return err
+		}
+		w.encoder.Encode(echo.Map{"status": "error", "error": err.Error()})
+	}
+	w.start()
+	w.c.Response().Flush()
+	return nil
+}
+
+// chunkLine is a chunk of a streamed chunk response, with the timeline it belongs to
+type chunkLine struct {
+	Timeline string `json:"timeline"`
+	core.Chunk
+}
+
+// streamChunks writes the chunks a line each, in the order of the timelines
+func streamChunks(c echo.Context, chunks map[string]core.Chunk) error {
+	timelines := make([]string, 0, len(chunks))
+	for timeline := range chunks {
+		timelines = append(timelines, timeline)
+	}
+	sort.Strings(timelines)
+
+	writer := newNDJSONWriter(c)
+	for _, timeline := range timelines {
+		err := writer.write(chunkLine{Timeline: timeline, Chunk: chunks[timeline]})
+		if err != nil {
+			return writer.close(err)
+		}
+	}
+	return writer.close(nil)
+}
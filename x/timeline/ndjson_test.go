@@ -0,0 +1,95 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAccept, ndjsonContentType)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	assert.True(t, wantsNDJSON(c))
+
+	writer := newNDJSONWriter(c)
+	assert.NoError(t, writer.write(core.TimelineItem{ResourceID: "m1"}))
+	assert.NoError(t, writer.write(core.TimelineItem{ResourceID: "m2"}))
+	// the status is already sent, so the error ends the stream
+	assert.NoError(t, writer.close(errors.New("connection reset")))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ndjsonContentType, rec.Header().Get(echo.HeaderContentType))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"resourceID":"m1"`)
+	assert.Contains(t, lines[1], `"resourceID":"m2"`)
+	assert.JSONEq(t, `{"status":"error","error":"connection reset"}`, lines[2])
+
+	// an error before the first line is left to the error handler
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	assert.False(t, wantsNDJSON(c))
+	assert.Error(t, newNDJSONWriter(c).close(errors.New("not found")))
+	assert.Empty(t, rec.Body.String())
+
+	// an empty result is an empty stream
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	assert.NoError(t, newNDJSONWriter(c).close(nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestStreamChunks(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+	err := streamChunks(c, map[string]core.Chunk{
+		"tb@example.com": {Key: "tl:body:b", Epoch: "1", Items: []core.TimelineItem{}},
+		"ta@example.com": {Key: "tl:body:a", Epoch: "1", Items: []core.TimelineItem{}},
+	})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"timeline":"ta@example.com","key":"tl:body:a","epoch":"1","items":[]}`, lines[0])
+	assert.JSONEq(t, `{"timeline":"tb@example.com","key":"tl:body:b","epoch":"1","items":[]}`, lines[1])
+}
+
+func TestQueryStreamLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_core.NewMockTimelineService(ctrl)
+	h := NewHandler(mockService, nil, RealtimeConfig{})
+	e := echo.New()
+
+	query := func(ctx context.Context) {
+		req := httptest.NewRequest(http.MethodGet, "/timeline/t00000000000000000000000000/query?limit=100000", nil)
+		req.Header.Set(echo.HeaderAccept, ndjsonContentType)
+		c := e.NewContext(req.WithContext(ctx), httptest.NewRecorder())
+		assert.NoError(t, h.Query(c))
+	}
+
+	// anonymous streams are capped lower than those of users
+	mockService.EXPECT().QueryStream(gomock.Any(), gomock.Any(), "", "", "", gomock.Any(), maxAnonymousStreamQueryLimit, gomock.Any()).Return(nil)
+	query(context.Background())
+
+	mockService.EXPECT().QueryStream(gomock.Any(), gomock.Any(), "", "", "", gomock.Any(), maxStreamQueryLimit, gomock.Any()).Return(nil)
+	query(context.WithValue(context.Background(), core.RequesterIdCtxKey, "con1user"))
+}
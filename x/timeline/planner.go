@@ -0,0 +1,162 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+// timelines normalized at once by a single plan
+const normalizeConcurrency = 16
+
+// timelinePlan is the normalized form of the timelines of a request.
+// it is computed once and shared by the phases of the request, instead of each phase normalizing again
+type timelinePlan struct {
+	normalized []string          // in the order of the request, without the timelines which failed
+	normtable  map[string]string // normalized -> requested
+	ids        map[string]string // requested -> normalized
+	merged     map[string]bool   // requested timelines which were merged into another one
+}
+
+// planTimelines normalizes the timelines concurrently. timelines which fail to normalize, and private ones
+// the requester may not read, are left out.
+// timelines merged into another one are read from that one, once even if it is requested several times
+func (s *service) planTimelines(ctx context.Context, timelines []string) timelinePlan {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.PlanTimelines")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("timelines", len(timelines)))
+
+	aliases, err := s.repository.GetAliases(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	gate := s.readerGate(ctx)
+
+	// the reader lists of remote timelines are looked up while normalizing, so that they are fetched concurrently too
+	type result struct {
+		normalized string
+		merged     bool
+	}
+	results := make([]result, len(timelines))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, normalizeConcurrency)
+	for i, timeline := range timelines {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, timeline string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			normalized, err := s.NormalizeTimelineID(ctx, timeline)
+			if err != nil {
+				logging.Warn(
+					ctx,
+					fmt.Sprintf("failed to normalize timeline: %s", timeline),
+					slog.String("module", "timeline"),
+				)
+				return
+			}
+			normalized, merged := s.followAlias(aliases, normalized)
+			if !gate.allows(ctx, normalized) {
+				return
+			}
+			results[i] = result{normalized, merged}
+		}(i, timeline)
+	}
+	wg.Wait()
+
+	plan := timelinePlan{
+		normalized: make([]string, 0, len(timelines)),
+		normtable:  make(map[string]string, len(timelines)),
+		ids:        make(map[string]string, len(timelines)),
+		merged:     map[string]bool{},
+	}
+	for i, result := range results {
+		normalized, merged := result.normalized, result.merged
+		if normalized == "" {
+			continue
+		}
+		if merged {
+			plan.merged[timelines[i]] = true
+		}
+		plan.ids[timelines[i]] = normalized
+		if _, ok := plan.normtable[normalized]; ok {
+			// the results are keyed by the timeline itself rather than by one merged into it
+			if !merged {
+				plan.normtable[normalized] = timelines[i]
+			}
+			continue
+		}
+		plan.normalized = append(plan.normalized, normalized)
+		plan.normtable[normalized] = timelines[i]
+	}
+	return plan
+}
+
+// only returns the plan of one of the requested timelines
+func (p timelinePlan) only(timeline string) timelinePlan {
+	normalized, ok := p.ids[timeline]
+	if !ok {
+		return timelinePlan{}
+	}
+	return timelinePlan{
+		normalized: []string{normalized},
+		normtable:  map[string]string{normalized: timeline},
+		ids:        map[string]string{timeline: normalized},
+	}
+}
+
+// recoverKeys maps the keys of a result back to the timelines as requested
+func recoverKeys[V any](p timelinePlan, result map[string]V) map[string]V {
+	recovered := make(map[string]V, len(result))
+	for k, v := range result {
+		recovered[p.normtable[k]] = v
+	}
+	return recovered
+}
+
+// loadPlannedChunks loads the chunks of the planned timelines at the epoch.
+// the repository groups them by home domain: local timelines are read by a single query
+// and remote domains are asked concurrently
+func (s *service) loadPlannedChunks(ctx context.Context, plan timelinePlan, epoch string) (map[string]core.Chunk, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.LoadPlannedChunks")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("epoch", epoch))
+
+	query, err := s.repository.LookupChunkItrs(ctx, plan.normalized, epoch)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	chunks, err := s.repository.LoadChunkBodies(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return recoverKeys(plan, chunks), nil
+}
+
+// listPlannedRemovals returns the items recently retracted from the planned timelines
+func (s *service) listPlannedRemovals(ctx context.Context, plan timelinePlan) (map[string][]string, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.ListPlannedRemovals")
+	defer span.End()
+
+	retracted, err := s.repository.ListRecentlyRemovedItems(ctx, plan.normalized)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return recoverKeys(plan, retracted), nil
+}
@@ -0,0 +1,134 @@
+package timeline
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+// readerGate tells whether the requester of a request may read timelines.
+// only private timelines, those with a reader list, are restricted. every path returning items of timelines
+// goes through the gate, so that a private timeline is never served by a generic endpoint like Query.
+// the messages they point to are checked by the message service through AllowsReader as well.
+// the gate is the whole envelope of private items: they are stored and federated in plaintext, so
+// readers needing confidentiality against the servers have to encrypt the documents themselves
+type readerGate struct {
+	service   *service
+	requester string
+	readers   map[string][]string
+}
+
+// readerGate loads the reader lists of the local private timelines once for the request
+func (s *service) readerGate(ctx context.Context) readerGate {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.ReaderGate")
+	defer span.End()
+
+	readers, err := s.repository.GetReaders(ctx)
+	if err != nil {
+		// without the lists no local timeline can be told public, so they are all closed
+		span.RecordError(err)
+		readers = nil
+	}
+
+	return readerGate{service: s, requester: readerOf(ctx), readers: readers}
+}
+
+// readerOf returns the requester, or the entity another domain fetches the items for
+func readerOf(ctx context.Context) string {
+	if requester, ok := ctx.Value(core.RequesterIdCtxKey).(string); ok && requester != "" {
+		return requester
+	}
+	vouched, _ := ctx.Value(core.RequesterVouchedIdCtxKey).(string)
+	return vouched
+}
+
+// reader returns the entity of the requester, for the policies. anonymous requests get an empty entity
+func (g readerGate) reader(ctx context.Context) core.Entity {
+	if g.requester == "" {
+		return core.Entity{}
+	}
+	entity, err := g.service.entity.Get(ctx, g.requester)
+	if err != nil {
+		return core.Entity{}
+	}
+	return entity
+}
+
+// allowsChunks tells whether the chunks of the normalized timeline may be served to the reader.
+// chunks are fetched by other domains too, so besides the reader list the timelines with a policy
+// are checked against its timeline.read action, like realtime subscriptions are
+func (g readerGate) allowsChunks(ctx context.Context, reader core.Entity, normalized string) bool {
+	if !g.allows(ctx, normalized) {
+		return false
+	}
+
+	tl, err := g.service.GetTimelineAutoDomain(ctx, normalized)
+	if err != nil || tl.Policy == "" {
+		return true
+	}
+	if g.service.readPolicyAllows(ctx, reader, tl) {
+		return true
+	}
+
+	logging.Info(
+		ctx, "denied to read timeline chunks",
+		slog.String("type", "audit"),
+		slog.String("principal", g.requester),
+		slog.String("timeline", normalized),
+		slog.String("module", "timeline"),
+	)
+	return false
+}
+
+// allows tells whether the requester may read the normalized timeline
+func (g readerGate) allows(ctx context.Context, normalized string) bool {
+	split := strings.Split(normalized, "@")
+	host := split[len(split)-1]
+
+	var readers []string
+	if host == g.service.config.FQDN {
+		if g.readers == nil {
+			return false
+		}
+		list, ok := g.readers[strings.TrimPrefix(split[0], "t")]
+		if !ok {
+			return true
+		}
+		readers = list
+	} else {
+		// the home domain checks the list again when the chunks are fetched with the passport of the requester.
+		// it is checked here too since the fetched chunks are cached for everyone.
+		// a timeline whose list can't be fetched may be private, so it is closed until the list is known
+		tl, err := g.service.repository.GetTimelineFromRemote(ctx, host, split[0])
+		if err != nil {
+			logging.Warn(
+				ctx, "failed to get reader list of remote timeline",
+				slog.String("timeline", normalized),
+				slog.String("error", err.Error()),
+				slog.String("module", "timeline"),
+			)
+			return false
+		}
+		if len(tl.Readers) == 0 {
+			return true
+		}
+		readers = append([]string{tl.Owner, tl.Author}, tl.Readers...)
+	}
+
+	if g.requester != "" && slices.Contains(readers, g.requester) {
+		return true
+	}
+
+	logging.Info(
+		ctx, "denied to read private timeline",
+		slog.String("type", "audit"),
+		slog.String("principal", g.requester),
+		slog.String("timeline", normalized),
+		slog.String("module", "timeline"),
+	)
+	return false
+}
@@ -0,0 +1,115 @@
+package timeline
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/totegamma/concurrent/core"
+)
+
+const (
+	// OverflowDropOldest discards the oldest queued event to make room for the new one
+	OverflowDropOldest = "drop-oldest"
+	// OverflowDisconnect closes the connection when the queue is full
+	OverflowDisconnect = "disconnect"
+
+	defaultRealtimeQueueSize = 256
+)
+
+var droppedEventMetrics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cc_timeline_realtime_dropped_events_total",
+		Help: "number of realtime events dropped because the client could not keep up",
+	},
+	[]string{"policy"},
+)
+
+func init() {
+	prometheus.MustRegister(droppedEventMetrics)
+}
+
+// RealtimeConfig configures per connection buffering of realtime events
+type RealtimeConfig struct {
+	QueueSize      int          // max events buffered per connection
+	OverflowPolicy string       // OverflowDropOldest or OverflowDisconnect
+	Sessions       SessionStore // keeps protocol v2 sessions for resume. nil disables protocol v2
+	// ReadRecheckInterval is how often the timeline.read policy of the subscribed timelines is evaluated again
+	ReadRecheckInterval time.Duration
+}
+
+// ValidateOverflowPolicy fails on a policy which is neither of the known ones. empty means the default
+func ValidateOverflowPolicy(policy string) error {
+	switch policy {
+	case "", OverflowDropOldest, OverflowDisconnect:
+		return nil
+	}
+	return fmt.Errorf("unknown overflow policy %q. expected %q or %q", policy, OverflowDropOldest, OverflowDisconnect)
+}
+
+// eventQueue is a bounded FIFO between the subscription and a slow writer.
+// push never blocks, so the subscription keeps draining even if the client stalls.
+type eventQueue struct {
+	mu     sync.Mutex
+	items  []core.Event
+	head   int
+	size   int
+	policy string
+	ready  chan struct{}
+}
+
+func newEventQueue(capacity int, policy string) *eventQueue {
+	if capacity <= 0 {
+		capacity = defaultRealtimeQueueSize
+	}
+	return &eventQueue{
+		items:  make([]core.Event, capacity),
+		policy: policy,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push appends an event. it returns false when the queue overflowed under the disconnect policy.
+func (q *eventQueue) push(event core.Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.size == len(q.items) {
+		if q.policy == OverflowDisconnect {
+			droppedEventMetrics.WithLabelValues(OverflowDisconnect).Inc()
+			return false
+		}
+		q.items[q.head] = core.Event{}
+		q.head = (q.head + 1) % len(q.items)
+		q.size--
+		droppedEventMetrics.WithLabelValues(OverflowDropOldest).Inc()
+	}
+
+	q.items[(q.head+q.size)%len(q.items)] = event
+	q.size++
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// drain takes all queued events out in order
+func (q *eventQueue) drain() []core.Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	events := make([]core.Event, q.size)
+	for i := 0; i < q.size; i++ {
+		idx := (q.head + i) % len(q.items)
+		events[i] = q.items[idx]
+		q.items[idx] = core.Event{}
+	}
+	q.head = 0
+	q.size = 0
+
+	return events
+}
@@ -0,0 +1,41 @@
+package timeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totegamma/concurrent/core"
+)
+
+func TestEventQueueDropOldest(t *testing.T) {
+	q := newEventQueue(2, OverflowDropOldest)
+
+	assert.True(t, q.push(core.Event{Timeline: "a"}))
+	assert.True(t, q.push(core.Event{Timeline: "b"}))
+	assert.True(t, q.push(core.Event{Timeline: "c"}))
+
+	events := q.drain()
+	assert.Len(t, events, 2)
+	assert.Equal(t, "b", events[0].Timeline)
+	assert.Equal(t, "c", events[1].Timeline)
+	assert.Len(t, q.drain(), 0)
+}
+
+func TestEventQueueDisconnect(t *testing.T) {
+	q := newEventQueue(1, OverflowDisconnect)
+
+	assert.True(t, q.push(core.Event{Timeline: "a"}))
+	assert.False(t, q.push(core.Event{Timeline: "b"}))
+
+	events := q.drain()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "a", events[0].Timeline)
+	assert.True(t, q.push(core.Event{Timeline: "c"}))
+}
+
+func TestValidateOverflowPolicy(t *testing.T) {
+	assert.NoError(t, ValidateOverflowPolicy(""))
+	assert.NoError(t, ValidateOverflowPolicy(OverflowDropOldest))
+	assert.NoError(t, ValidateOverflowPolicy(OverflowDisconnect))
+	assert.Error(t, ValidateOverflowPolicy("drop-newest"))
+}
@@ -3,12 +3,16 @@ package timeline
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
@@ -16,9 +20,15 @@ import (
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/totegamma/concurrent/client"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/coalesce"
+	"github.com/totegamma/concurrent/internal/database"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/cache"
+	"github.com/totegamma/concurrent/x/keyspace"
 )
 
 // Repository is timeline repository interface
@@ -26,16 +36,24 @@ type Repository interface {
 	GetTimeline(ctx context.Context, key string) (core.Timeline, error)
 	GetTimelineFromRemote(ctx context.Context, host string, key string) (core.Timeline, error)
 	UpsertTimeline(ctx context.Context, timeline core.Timeline) (core.Timeline, error)
+	UpdateTimeline(ctx context.Context, timeline core.Timeline, version int64) (core.Timeline, error)
 	DeleteTimeline(ctx context.Context, key string) error
+	Merge(ctx context.Context, timeline, into string) error
+	GetAliases(ctx context.Context) (map[string]string, error)
+	GetReaders(ctx context.Context) (map[string][]string, error)
 
 	GetItem(ctx context.Context, timelineID string, objectID string) (core.TimelineItem, error)
 	CreateItem(ctx context.Context, item core.TimelineItem) (core.TimelineItem, error)
 	DeleteItem(ctx context.Context, timelineID string, objectID string) error
 	DeleteItemByResourceID(ctx context.Context, resourceID string) error
+	ExpireItems(ctx context.Context, now time.Time, limit int) ([]core.TimelineItem, error)
 
 	ListTimelineBySchema(ctx context.Context, schema string) ([]core.Timeline, error)
+	Discover(ctx context.Context, schemas []string, since time.Time, offset, limit int) ([]core.DiscoveredTimeline, error)
+	DiscoverFromRemote(ctx context.Context, host string, schemas []string) ([]core.DiscoveredTimeline, error)
 	ListTimelineByAuthor(ctx context.Context, author string) ([]core.Timeline, error)
 	ListTimelineByAuthorOwned(ctx context.Context, author string) ([]core.Timeline, error)
+	CountTimelineByAuthorOwned(ctx context.Context, author string) (int64, error)
 
 	GetRecentItems(ctx context.Context, timelineID string, until time.Time, limit int) ([]core.TimelineItem, error)
 	GetImmediateItems(ctx context.Context, timelineID string, since time.Time, limit int) ([]core.TimelineItem, error)
@@ -51,6 +69,7 @@ type Repository interface {
 	GetNormalizationCache(ctx context.Context, timelineID string) (string, error)
 
 	Query(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int) ([]core.TimelineItem, error)
+	QueryEach(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(core.TimelineItem) error) error
 
 	LookupChunkItrs(ctx context.Context, timelines []string, epoch string) (map[string]string, error)
 	LoadChunkBodies(ctx context.Context, query map[string]string) (map[string]core.Chunk, error)
@@ -58,13 +77,17 @@ type Repository interface {
 	ListRecentlyRemovedItems(ctx context.Context, normalized []string) (map[string][]string, error)
 	ListRecentlyRemovedItemsLocal(ctx context.Context, timelineIDs []string) (map[string][]string, error)
 
+	CountWrite(ctx context.Context, timelineID, author string, window time.Duration) (int64, time.Duration, error)
+	PeekWrite(ctx context.Context, timelineID, author string) (int64, time.Duration, error)
+
 	GetMetrics() map[string]int64
+	GetBridges() []core.BridgeStatus
 }
 
 type repository struct {
 	db     *gorm.DB
 	rdb    *redis.Client
-	mc     *memcache.Client
+	mc     cache.Cache
 	keeper Keeper
 	client client.Client
 	schema core.SchemaService
@@ -74,10 +97,16 @@ type repository struct {
 	lookupChunkItrsCacheHits   int64
 	loadChunkBodiesCacheMisses int64
 	loadChunkBodiesCacheHits   int64
+	remoteFetchFailures        atomic.Int64
+
+	// concurrent misses of the same remote resource share one fetch
+	remoteTimelines coalesce.Group[core.Timeline]
+	remoteItrs      coalesce.Group[map[string]string]
+	remoteBodies    coalesce.Group[map[string]core.Chunk]
 }
 
 // NewRepository creates a new timeline repository
-func NewRepository(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper Keeper, client client.Client, schema core.SchemaService, config core.Config) Repository {
+func NewRepository(db *gorm.DB, rdb *redis.Client, mc cache.Cache, keeper Keeper, client client.Client, schema core.SchemaService, config core.Config) Repository {
 	return &repository{
 		db,
 		rdb,
@@ -87,6 +116,10 @@ func NewRepository(db *gorm.DB, rdb *redis.Client, mc *memcache.Client, keeper K
 		schema,
 		config,
 		0, 0, 0, 0,
+		atomic.Int64{},
+		coalesce.Group[core.Timeline]{},
+		coalesce.Group[map[string]string]{},
+		coalesce.Group[map[string]core.Chunk]{},
 	}
 }
 
@@ -103,6 +136,58 @@ func (r *repository) setCurrentCount() {
 	r.mc.Set(&memcache.Item{Key: "timeline_count", Value: []byte(strconv.FormatInt(count, 10))})
 }
 
+// CountWrite counts a write of the author to the timeline in a fixed window starting at the first write.
+// it returns the writes in the window so far, and when the window ends
+func (r *repository) CountWrite(ctx context.Context, timelineID, author string, window time.Duration) (int64, time.Duration, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.CountWrite")
+	defer span.End()
+
+	key := keyspace.RateLimit.Key("timeline", timelineID, author)
+
+	pipe := r.rdb.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	ttl := pipe.PTTL(ctx, key)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, 0, err
+	}
+
+	return count.Val(), ttl.Val(), nil
+}
+
+// PeekWrite returns the writes of the author to the timeline in the current window and when it ends, without counting one
+func (r *repository) PeekWrite(ctx context.Context, timelineID, author string) (int64, time.Duration, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.PeekWrite")
+	defer span.End()
+
+	key := keyspace.RateLimit.Key("timeline", timelineID, author)
+
+	pipe := r.rdb.Pipeline()
+	count := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	_, err := pipe.Exec(ctx)
+	if errors.Is(err, redis.Nil) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		return 0, 0, err
+	}
+
+	n, err := count.Int64()
+	if err != nil {
+		span.RecordError(err)
+		return 0, 0, err
+	}
+	return n, ttl.Val(), nil
+}
+
+func (r *repository) GetBridges() []core.BridgeStatus {
+	return r.keeper.GetBridges()
+}
+
 func (r *repository) GetMetrics() map[string]int64 {
 
 	keeperMetrics := r.keeper.GetMetrics()
@@ -112,6 +197,7 @@ func (r *repository) GetMetrics() map[string]int64 {
 		"lookup_chunk_itr_cache_hits":    r.lookupChunkItrsCacheHits,
 		"load_chunk_bodies_cache_misses": r.loadChunkBodiesCacheMisses,
 		"load_chunk_bodies_cache_hits":   r.loadChunkBodiesCacheHits,
+		"remote_chunk_fetch_failures":    r.remoteFetchFailures.Load(),
 	}
 
 	for k, v := range keeperMetrics {
@@ -125,6 +211,14 @@ const (
 	normaalizationCachePrefix = "tl:norm:"
 	normaalizationCacheTTL    = 60 * 15 // 15 minutes
 
+	// every alias of the domain is cached under a single key, since reads of any local timeline look them up
+	aliasCacheKey = "tl:aliases"
+	aliasCacheTTL = 60 // 1 minute
+
+	// the readers of the private timelines are cached the same way, since every read checks them
+	readerCacheKey = "tl:readers"
+	readerCacheTTL = 60 // 1 minute
+
 	tlItrCachePrefix  = "tl:itr:"
 	tlItrCacheTTL     = 60 * 60 * 24 * 2 // 2 days
 	tlBodyCachePrefix = "tl:body:"
@@ -180,26 +274,29 @@ func (r *repository) LookupChunkItrs(ctx context.Context, normalized []string, e
 		}
 	}
 
-	for domain, timelines := range domainMap {
-		if domain == r.config.FQDN {
-			res, err := r.lookupLocalItrs(ctx, timelines, epoch)
-			if err != nil {
-				span.RecordError(err)
-				continue
-			}
-			for k, v := range res {
-				result[k] = v
-			}
-		} else {
-			res, err := r.lookupRemoteItrs(ctx, domain, timelines, epoch)
-			if err != nil {
-				span.RecordError(err)
-				continue
-			}
-			for k, v := range res {
-				result[k] = v
-			}
+	local, hasLocal := domainMap[r.config.FQDN]
+	delete(domainMap, r.config.FQDN)
+
+	// remote domains are asked in parallel while the local lookup runs
+	remote := make(chan map[string]string, 1)
+	go func() {
+		remote <- fetchRemotes(ctx, domainMap, func(ctx context.Context, domain string, timelines []string) (map[string]string, error) {
+			return r.lookupRemoteItrs(ctx, domain, timelines, epoch)
+		}, &r.remoteFetchFailures)
+	}()
+
+	if hasLocal {
+		res, err := r.lookupLocalItrs(ctx, local, epoch)
+		if err != nil {
+			span.RecordError(err)
 		}
+		for k, v := range res {
+			result[k] = v
+		}
+	}
+
+	for k, v := range <-remote {
+		result[k] = v
 	}
 
 	return result, nil
@@ -229,11 +326,7 @@ func (r *repository) LoadChunkBodies(ctx context.Context, query map[string]strin
 	for _, key := range keys {
 		timeline := keytable[key]
 		if cache[key] != nil {
-			var items []core.TimelineItem
-			cacheStr := string(cache[key].Value)
-			cacheStr = cacheStr[1:]
-			cacheStr = "[" + cacheStr + "]"
-			err = json.Unmarshal([]byte(cacheStr), &items)
+			items, err := chunkCodec.Decode(cache[key].Value)
 			if err != nil {
 				span.RecordError(err)
 				continue
@@ -262,26 +355,27 @@ func (r *repository) LoadChunkBodies(ctx context.Context, query map[string]strin
 		}
 	}
 
-	for domain, q := range domainMap {
-		if domain == r.config.FQDN {
-			for timeline, epoch := range q {
-				res, err := r.loadLocalBody(ctx, timeline, epoch)
-				if err != nil {
-					span.RecordError(err)
-					continue
-				}
-				result[timeline] = res
-			}
-		} else {
-			res, err := r.loadRemoteBodies(ctx, domain, q)
-			if err != nil {
-				span.RecordError(err)
-				continue
-			}
-			for k, v := range res {
-				result[k] = v
-			}
+	local := domainMap[r.config.FQDN]
+	delete(domainMap, r.config.FQDN)
+
+	// remote domains are asked in parallel while the local chunks are loaded
+	remote := make(chan map[string]core.Chunk, 1)
+	go func() {
+		remote <- fetchRemotes(ctx, domainMap, r.loadRemoteBodies, &r.remoteFetchFailures)
+	}()
+
+	if len(local) > 0 {
+		res, err := r.loadLocalBodies(ctx, local)
+		if err != nil {
+			span.RecordError(err)
 		}
+		for k, v := range res {
+			result[k] = v
+		}
+	}
+
+	for k, v := range <-remote {
+		result[k] = v
 	}
 
 	return result, nil
@@ -339,7 +433,7 @@ func (r *repository) lookupLocalItrs(ctx context.Context, timelines []string, ep
 			key := tlItrCachePrefix + id + ":" + epoch
 			value := core.Time2Chunk(item.MaxCDate)
 			span.AddEvent(fmt.Sprintf("cache lookupLocalItrs: %s", key))
-			r.mc.Set(&memcache.Item{Key: key, Value: []byte(value), Expiration: tlItrCacheTTL})
+			r.mc.Set(&memcache.Item{Key: key, Value: []byte(value), Expiration: itrCacheExpiration(epoch, time.Now())})
 			result[id] = value
 		}
 	}
@@ -347,7 +441,18 @@ func (r *repository) lookupLocalItrs(ctx context.Context, timelines []string, ep
 	return result, nil
 }
 
+// lookupRemoteItrs asks the domain for the iterators of its timelines, and caches them
 func (r *repository) lookupRemoteItrs(ctx context.Context, domain string, timelines []string, epoch string) (map[string]string, error) {
+	sorted := slices.Clone(timelines)
+	slices.Sort(sorted)
+	key := domain + "/" + epoch + "/" + strings.Join(sorted, ",")
+	result, _, err := r.remoteItrs.Do(ctx, key, func(ctx context.Context) (map[string]string, error) {
+		return r.fetchRemoteItrs(ctx, domain, timelines, epoch)
+	})
+	return result, err
+}
+
+func (r *repository) fetchRemoteItrs(ctx context.Context, domain string, timelines []string, epoch string) (map[string]string, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.LookupRemoteItr")
 	defer span.End()
 
@@ -375,79 +480,171 @@ func (r *repository) lookupRemoteItrs(ctx context.Context, domain string, timeli
 
 		key := tlItrCachePrefix + timeline + ":" + epoch
 		span.AddEvent(fmt.Sprintf("cache lookupRemoteItrs: %s", key))
-		r.mc.Set(&memcache.Item{Key: key, Value: []byte(itr), Expiration: tlItrCacheTTL})
+		r.mc.Set(&memcache.Item{Key: key, Value: []byte(itr), Expiration: itrCacheExpiration(epoch, time.Now())})
 	}
 
 	return result, nil
 }
 
+// timelines read by a single query of loadLocalBodies
+const localBodyBatchSize = 100
+
 func (r *repository) loadLocalBody(ctx context.Context, timeline string, epoch string) (core.Chunk, error) {
-	ctx, span := tracer.Start(ctx, "Timeline.Repository.LoadLocalBody")
+	chunks, err := r.loadLocalBodies(ctx, map[string]string{timeline: epoch})
+	if err != nil {
+		return core.Chunk{}, err
+	}
+	return chunks[timeline], nil
+}
+
+// loadLocalBodies loads the chunks of local timelines, defaultChunkSize items each, by a UNION of the timelines.
+// timelines whose items don't reach back to the previous chunk may miss some of the chunk,
+// those are read again with the whole range of the chunk. timelines without items are left out
+func (r *repository) loadLocalBodies(ctx context.Context, query map[string]string) (map[string]core.Chunk, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.LoadLocalBodies")
 	defer span.End()
 
-	chunkDate := core.Chunk2RecentTime(epoch)
-	prevChunkDate := core.Chunk2RecentTime(core.PrevChunk(epoch))
+	span.SetAttributes(attribute.Int("timelines", len(query)))
 
-	timelineID := timeline
-	if strings.Contains(timelineID, "@") {
-		timelineID = strings.Split(timelineID, "@")[0]
-	}
-	if len(timelineID) == 27 {
-		if timelineID[0] != 't' {
-			return core.Chunk{}, fmt.Errorf("timeline typed-id must start with 't'")
+	dbids := make(map[string]string) // dbid -> timeline
+	for timeline := range query {
+		dbid := timeline
+		if strings.Contains(dbid, "@") {
+			dbid = strings.Split(dbid, "@")[0]
+		}
+		if len(dbid) == 27 {
+			if dbid[0] != 't' {
+				span.RecordError(fmt.Errorf("timeline typed-id must start with 't' %s", timeline))
+				continue
+			}
+			dbid = dbid[1:]
 		}
-		timelineID = timelineID[1:]
+		dbids[dbid] = timeline
 	}
 
-	var items []core.TimelineItem
+	ids := make([]string, 0, len(dbids))
+	for dbid := range dbids {
+		ids = append(ids, dbid)
+	}
+	sort.Strings(ids)
 
-	err := r.db.WithContext(ctx).
-		Where("timeline_id = ? and c_date <= ?", timelineID, chunkDate).
-		Order("c_date desc").
-		Limit(defaultChunkSize).
-		Find(&items).Error
+	table := r.db.NamingStrategy.TableName("TimelineItem")
+	items := make(map[string][]core.TimelineItem)
+	var refetch []string
+	for start := 0; start < len(ids); start += localBodyBatchSize {
+		batch := ids[start:min(start+localBodyBatchSize, len(ids))]
 
-	// 得られた中で最も古いアイテムがチャンクをまたいでない場合、取得漏れがある可能性がある
-	// 代わりに、チャンク内のレンジの全てのアイテムを取得する
-	if items[len(items)-1].CDate.After(prevChunkDate) {
-		err = r.db.WithContext(ctx).
-			Where("timeline_id = ? and ? < c_date and c_date <= ?", timelineID, prevChunkDate, chunkDate).
-			Order("c_date desc").
-			Find(&items).Error
-	}
+		parts := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*3)
+		for i, dbid := range batch {
+			parts[i] = "(SELECT * FROM " + table + " WHERE timeline_id = ? AND c_date <= ? ORDER BY c_date DESC LIMIT ?)"
+			args = append(args, dbid, core.Chunk2RecentTime(query[dbids[dbid]]), defaultChunkSize)
+		}
 
-	if err != nil {
-		span.RecordError(err)
-		return core.Chunk{}, err
-	}
+		var res []core.TimelineItem
+		err := r.db.WithContext(ctx).Raw(strings.Join(parts, " UNION ALL "), args...).Scan(&res).Error
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		for _, item := range res {
+			items[item.TimelineID] = append(items[item.TimelineID], item)
+		}
 
-	// append domain to timelineID
-	for i, item := range items {
-		items[i].TimelineID = item.TimelineID + "@" + r.config.FQDN
+		// 得られた中で最も古いアイテムがチャンクをまたいでない場合、取得漏れがある可能性がある
+		// 代わりに、チャンク内のレンジの全てのアイテムを取得する
+		for _, dbid := range batch {
+			list := items[dbid]
+			if len(list) < defaultChunkSize {
+				continue
+			}
+			sortItems(list)
+			prevChunkDate := core.Chunk2RecentTime(core.PrevChunk(query[dbids[dbid]]))
+			if list[len(list)-1].CDate.After(prevChunkDate) {
+				refetch = append(refetch, dbid)
+			}
+		}
 	}
 
-	b, err := json.Marshal(items)
-	if err != nil {
-		span.RecordError(err)
-		return core.Chunk{}, err
+	for start := 0; start < len(refetch); start += localBodyBatchSize {
+		batch := refetch[start:min(start+localBodyBatchSize, len(refetch))]
+
+		parts := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*3)
+		for i, dbid := range batch {
+			epoch := query[dbids[dbid]]
+			parts[i] = "(SELECT * FROM " + table + " WHERE timeline_id = ? AND ? < c_date AND c_date <= ?)"
+			args = append(args, dbid, core.Chunk2RecentTime(core.PrevChunk(epoch)), core.Chunk2RecentTime(epoch))
+			delete(items, dbid)
+		}
+
+		var res []core.TimelineItem
+		err := r.db.WithContext(ctx).Raw(strings.Join(parts, " UNION ALL "), args...).Scan(&res).Error
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		for _, item := range res {
+			items[item.TimelineID] = append(items[item.TimelineID], item)
+		}
 	}
-	key := tlBodyCachePrefix + timeline + ":" + epoch
-	cacheStr := "," + string(b[1:len(b)-1])
-	span.AddEvent(fmt.Sprintf("cache loadLocalBody: %s", key))
-	err = r.mc.Set(&memcache.Item{Key: key, Value: []byte(cacheStr), Expiration: tlBodyCacheTTL})
-	if err != nil {
-		span.RecordError(err)
+
+	span.SetAttributes(attribute.Int("refetched", len(refetch)))
+
+	result := make(map[string]core.Chunk, len(items))
+	for dbid, list := range items {
+		timeline := dbids[dbid]
+		epoch := query[timeline]
+
+		sortItems(list)
+		// append domain to timelineID
+		for i, item := range list {
+			list[i].TimelineID = item.TimelineID + "@" + r.config.FQDN
+		}
+
+		key := tlBodyCachePrefix + timeline + ":" + epoch
+		body, err := chunkCodec.Encode(list...)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		span.AddEvent(fmt.Sprintf("cache loadLocalBody: %s", key))
+		err = r.mc.Set(&memcache.Item{Key: key, Value: body, Expiration: tlBodyCacheTTL})
+		if err != nil {
+			span.RecordError(err)
+		}
+
+		result[timeline] = core.Chunk{
+			Key:   key,
+			Epoch: epoch,
+			Items: list,
+		}
 	}
 
-	return core.Chunk{
-		Key:   key,
-		Epoch: epoch,
-		Items: items,
-	}, nil
+	return result, nil
+}
 
+// sortItems sorts the items newest first, as the chunks hold them
+func sortItems(items []core.TimelineItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CDate.After(items[j].CDate)
+	})
 }
 
+// loadRemoteBodies asks the domain for the chunks of its timelines, and caches them
 func (r *repository) loadRemoteBodies(ctx context.Context, remote string, query map[string]string) (map[string]core.Chunk, error) {
+	queries := make([]string, 0, len(query))
+	for timeline, epoch := range query {
+		queries = append(queries, timeline+":"+epoch)
+	}
+	slices.Sort(queries)
+	result, _, err := r.remoteBodies.Do(ctx, remote+"/"+strings.Join(queries, ","), func(ctx context.Context) (map[string]core.Chunk, error) {
+		return r.fetchRemoteBodies(ctx, remote, query)
+	})
+	return result, err
+}
+
+func (r *repository) fetchRemoteBodies(ctx context.Context, remote string, query map[string]string) (map[string]core.Chunk, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.LoadRemoteBody")
 	defer span.End()
 
@@ -472,14 +669,13 @@ func (r *repository) loadRemoteBodies(ctx context.Context, remote string, query
 		}
 
 		key := tlBodyCachePrefix + timeline + ":" + chunk.Epoch
-		b, err := json.Marshal(chunk.Items)
+		body, err := chunkCodec.Encode(chunk.Items...)
 		if err != nil {
 			span.RecordError(err)
 			continue
 		}
-		cacheStr := "," + string(b[1:len(b)-1])
 		span.AddEvent(fmt.Sprintf("cache loadRemoteBodies: %s", key))
-		err = r.mc.Set(&memcache.Item{Key: key, Value: []byte(cacheStr), Expiration: tlBodyCacheTTL})
+		err = r.mc.Set(&memcache.Item{Key: key, Value: body, Expiration: tlBodyCacheTTL})
 		if err != nil {
 			span.RecordError(err)
 			continue
@@ -577,6 +773,13 @@ func (r *repository) postprocess(ctx context.Context, timeline *core.Timeline) e
 		timeline.Policy = policyUrl
 	}
 
+	aliases, err := r.GetAliases(ctx)
+	if err == nil && len(timeline.ID) == 27 {
+		if into, ok := aliases[timeline.ID[1:]]; ok {
+			timeline.MergedInto = "t" + into
+		}
+	}
+
 	return nil
 }
 
@@ -614,7 +817,7 @@ func (r *repository) PublishEvent(ctx context.Context, event core.Event) error {
 	err := r.rdb.Publish(context.Background(), event.Timeline, jsonstr).Err()
 	if err != nil {
 		span.RecordError(err)
-		slog.ErrorContext(
+		logging.Error(
 			ctx, "fail to publish message to Redis",
 			slog.String("error", err.Error()),
 			slog.String("module", "timeline"),
@@ -624,7 +827,15 @@ func (r *repository) PublishEvent(ctx context.Context, event core.Event) error {
 	return nil
 }
 
+// getTimelineFromRemote fetches the timeline from its domain, and caches it
 func (r *repository) getTimelineFromRemote(ctx context.Context, host, key string) (core.Timeline, error) {
+	timeline, _, err := r.remoteTimelines.Do(ctx, key+"@"+host, func(ctx context.Context) (core.Timeline, error) {
+		return r.fetchTimelineFromRemote(ctx, host, key)
+	})
+	return timeline, err
+}
+
+func (r *repository) fetchTimelineFromRemote(ctx context.Context, host, key string) (core.Timeline, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.getTimelineFromRemote")
 	defer span.End()
 
@@ -642,7 +853,7 @@ func (r *repository) getTimelineFromRemote(ctx context.Context, host, key string
 		body, err := json.Marshal(timeline)
 		if err != nil {
 			span.RecordError(err)
-			slog.ErrorContext(
+			logging.Error(
 				ctx, "fail to marshal timeline",
 				slog.String("error", err.Error()),
 				slog.String("module", "timeline"),
@@ -653,7 +864,7 @@ func (r *repository) getTimelineFromRemote(ctx context.Context, host, key string
 		err = r.mc.Set(&memcache.Item{Key: cacheKey, Value: body, Expiration: 60 * 60 * 24 * 7}) // 7 days
 		if err != nil {
 			span.RecordError(err)
-			slog.ErrorContext(
+			logging.Error(
 				ctx, "fail to save cache",
 				slog.String("error", err.Error()),
 				slog.String("module", "timeline"),
@@ -662,7 +873,7 @@ func (r *repository) getTimelineFromRemote(ctx context.Context, host, key string
 		err = r.mc.Set(&memcache.Item{Key: freshKey, Value: []byte("1"), Expiration: 300}) // 5 minutes
 		if err != nil {
 			span.RecordError(err)
-			slog.ErrorContext(
+			logging.Error(
 				ctx, "fail to save cache",
 				slog.String("error", err.Error()),
 				slog.String("module", "timeline"),
@@ -758,13 +969,12 @@ func (r *repository) CreateItem(ctx context.Context, item core.TimelineItem) (co
 
 	timelineID := "t" + item.TimelineID + "@" + r.config.FQDN
 
-	json, err := json.Marshal(item)
+	val, err := chunkCodec.Encode(item)
 	if err != nil {
 		span.RecordError(err)
 		return item, err
 	}
 
-	val := "," + string(json)
 	itemChunk := core.Time2Chunk(item.CDate)
 	itrKey := tlItrCachePrefix + timelineID + ":" + itemChunk
 	cacheKey := tlBodyCachePrefix + timelineID + ":" + itemChunk
@@ -779,8 +989,9 @@ func (r *repository) CreateItem(ctx context.Context, item core.TimelineItem) (co
 	span.AddEvent(fmt.Sprintf("cache CreateItem: %s -> %s", itrKey, cacheKey))
 	err = r.mc.Replace(&memcache.Item{Key: itrKey, Value: []byte(itemChunk)})
 	span.AddEvent(fmt.Sprintf("replace err: %v", err))
-	err = r.mc.Prepend(&memcache.Item{Key: cacheKey, Value: []byte(val)})
+	err = r.mc.Prepend(&memcache.Item{Key: cacheKey, Value: val})
 	span.AddEvent(fmt.Sprintf("prepend err: %v", err))
+	invalidateRolledOver(r.mc, timelineID, itemChunk, time.Now())
 
 	item.TimelineID = "t" + item.TimelineID
 
@@ -797,8 +1008,8 @@ func (r *repository) DeleteItem(ctx context.Context, timelineID string, objectID
 		return err
 	}
 
-	r.rdb.SAdd(ctx, "timeline:"+timelineID+":deleted", objectID)
-	r.rdb.Expire(ctx, "timeline:"+timelineID+":deleted", time.Hour*24*2) // 2 days
+	r.rdb.SAdd(ctx, keyspace.TimelineRetract.Key(timelineID, "deleted"), objectID)
+	r.rdb.Expire(ctx, keyspace.TimelineRetract.Key(timelineID, "deleted"), time.Hour*24*2) // 2 days
 
 	return r.db.WithContext(ctx).Delete(&core.TimelineItem{}, "timeline_id = ? and resource_id = ?", timelineID, objectID).Error
 }
@@ -814,13 +1025,40 @@ func (r *repository) DeleteItemByResourceID(ctx context.Context, resourceID stri
 	}
 
 	for _, item := range items {
-		r.rdb.SAdd(ctx, "timeline:"+item.TimelineID+":deleted", item.ResourceID)
-		r.rdb.Expire(ctx, "timeline:"+item.TimelineID+":deleted", time.Hour*24*2) // 2 days
+		r.rdb.SAdd(ctx, keyspace.TimelineRetract.Key(item.TimelineID, "deleted"), item.ResourceID)
+		r.rdb.Expire(ctx, keyspace.TimelineRetract.Key(item.TimelineID, "deleted"), time.Hour*24*2) // 2 days
 	}
 
 	return r.db.WithContext(ctx).Delete(&core.TimelineItem{}, "resource_id = ?", resourceID).Error
 }
 
+// ExpireItems deletes up to limit items which expired by now and retracts them from the readers of their timelines
+func (r *repository) ExpireItems(ctx context.Context, now time.Time, limit int) ([]core.TimelineItem, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.ExpireItems")
+	defer span.End()
+
+	var expired []core.TimelineItem
+	keys := r.db.Model(&core.TimelineItem{}).Select("timeline_id, resource_id").Where("expires_at <= ?", now).Order("expires_at").Limit(limit)
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "timeline_id"}, {Name: "resource_id"}}}).
+		Where("(timeline_id, resource_id) IN (?)", keys).
+		Delete(&expired).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	for _, item := range expired {
+		r.rdb.SAdd(ctx, keyspace.TimelineRetract.Key(item.TimelineID, "deleted"), item.ResourceID)
+		r.rdb.Expire(ctx, keyspace.TimelineRetract.Key(item.TimelineID, "deleted"), time.Hour*24*2) // 2 days
+	}
+	for i := range expired {
+		expired[i].TimelineID = "t" + expired[i].TimelineID
+	}
+
+	return expired, nil
+}
+
 func (r *repository) ListRecentlyRemovedItems(ctx context.Context, normalized []string) (map[string][]string, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.ListRecentlyRemovedItems")
 	defer span.End()
@@ -873,7 +1111,7 @@ func (r *repository) ListRecentlyRemovedItemsLocal(ctx context.Context, timeline
 			continue
 		}
 
-		deleted, err := r.rdb.SMembers(ctx, "timeline:"+normalized+":deleted").Result()
+		deleted, err := r.rdb.SMembers(ctx, keyspace.TimelineRetract.Key(normalized, "deleted")).Result()
 		if err != nil {
 			return nil, err
 		}
@@ -1026,10 +1264,56 @@ func (r *repository) UpsertTimeline(ctx context.Context, timeline core.Timeline)
 	}
 
 	r.mc.Increment("timeline_count", 1)
+	r.mc.Delete(readerCacheKey)
 
 	return timeline, err
 }
 
+// UpdateTimeline replaces the timeline only if it is still at the version, and bumps the version
+func (r *repository) UpdateTimeline(ctx context.Context, timeline core.Timeline, version int64) (core.Timeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.UpdateTimeline")
+	defer span.End()
+
+	err := r.preprocess(ctx, &timeline)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+	timeline.Version = version + 1
+
+	result := r.db.WithContext(ctx).
+		Model(&core.Timeline{}).
+		Where("id = ? AND version = ?", timeline.ID, version).
+		Select("*").
+		Omit("c_date").
+		Updates(&timeline)
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return core.Timeline{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var current core.Timeline
+		err = r.db.WithContext(ctx).Select("version").Where("id = ?", timeline.ID).First(&current).Error
+		if err != nil {
+			span.RecordError(err)
+			return core.Timeline{}, err
+		}
+		return core.Timeline{}, core.NewErrorConflict(current.Version)
+	}
+
+	err = r.db.WithContext(ctx).Where("id = ?", timeline.ID).First(&timeline).Error
+	if err != nil {
+		return core.Timeline{}, err
+	}
+	r.mc.Delete(readerCacheKey)
+
+	err = r.postprocess(ctx, &timeline)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+
+	return timeline, nil
+}
+
 // GetListBySchema returns list of schemas by schema
 func (r *repository) ListTimelineBySchema(ctx context.Context, schema string) ([]core.Timeline, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.ListTimelineBySchema")
@@ -1053,6 +1337,94 @@ func (r *repository) ListTimelineBySchema(ctx context.Context, schema string) ([
 	return timelines, err
 }
 
+// Discover returns indexable timelines ordered by recent activity and subscriber count
+func (r *repository) Discover(ctx context.Context, schemas []string, since time.Time, offset, limit int) ([]core.DiscoveredTimeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.Discover")
+	defer span.End()
+
+	naming := r.db.NamingStrategy
+	query := r.db.WithContext(ctx).
+		Table(naming.TableName("Timeline")+" AS t").
+		Select("t.*, COALESCE(a.count, 0) AS activity, COALESCE(s.count, 0) AS subscribers").
+		Joins(
+			"LEFT JOIN (SELECT timeline_id, count(*) AS count FROM "+naming.TableName("TimelineItem")+" WHERE c_date > ? GROUP BY timeline_id) a ON a.timeline_id = t.id",
+			since,
+		).
+		Joins(
+			"LEFT JOIN (SELECT id, count(*) AS count FROM "+naming.TableName("SubscriptionItem")+" GROUP BY id) s ON s.id IN ('t' || t.id || '@' || ?, t.id || '@' || ?)",
+			r.config.FQDN, r.config.FQDN,
+		).
+		Where("t.indexable = true")
+
+	if len(schemas) > 0 {
+		schemaIDs := make([]uint, 0, len(schemas))
+		for _, schema := range schemas {
+			id, err := r.schema.UrlToID(ctx, schema)
+			if err != nil {
+				span.RecordError(err)
+				return nil, err
+			}
+			schemaIDs = append(schemaIDs, id)
+		}
+		query = query.Where("t.schema_id IN ?", schemaIDs)
+	}
+
+	var timelines []core.DiscoveredTimeline
+	err := query.
+		Order(fmt.Sprintf("COALESCE(a.count, 0) + COALESCE(s.count, 0) * %d DESC, t.id", discoverSubscriberWeight)).
+		Offset(offset).
+		Limit(limit).
+		Scan(&timelines).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	for i := range timelines {
+		err := r.postprocess(ctx, &timelines[i].Timeline)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return timelines, nil
+}
+
+// DiscoverFromRemote returns the top of the discovery ranking of a remote domain
+func (r *repository) DiscoverFromRemote(ctx context.Context, host string, schemas []string) ([]core.DiscoveredTimeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.DiscoverFromRemote")
+	defer span.End()
+
+	cacheKey := "tl:discover:" + host + ":" + strings.Join(schemas, ",")
+	if len(cacheKey) > 250 {
+		sum := sha256.Sum256([]byte(cacheKey))
+		cacheKey = "tl:discover:" + host + ":" + hex.EncodeToString(sum[:])
+	}
+
+	item, err := r.mc.Get(cacheKey)
+	if err == nil {
+		var timelines []core.DiscoveredTimeline
+		err = json.Unmarshal(item.Value, &timelines)
+		if err == nil {
+			return timelines, nil
+		}
+		span.RecordError(err)
+	}
+
+	timelines, err := r.client.DiscoverTimelines(ctx, host, schemas, discoverRemoteLimit, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	value, err := json.Marshal(timelines)
+	if err == nil {
+		r.mc.Set(&memcache.Item{Key: cacheKey, Value: value, Expiration: int32(discoverRemoteCacheTTL.Seconds())})
+	}
+
+	return timelines, nil
+}
+
 // GetListByAuthor returns list of schemas by owner
 func (r *repository) ListTimelineByAuthor(ctx context.Context, author string) ([]core.Timeline, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.ListTimelineByAuthor")
@@ -1088,6 +1460,20 @@ func (r *repository) ListTimelineByAuthorOwned(ctx context.Context, author strin
 	return timelines, err
 }
 
+// CountTimelineByAuthorOwned returns the number of timelines the author created and owns
+func (r *repository) CountTimelineByAuthorOwned(ctx context.Context, author string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.CountTimelineByAuthorOwned")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&core.Timeline{}).Where("Author = ? and domain_owned = false", author).Count(&count).Error
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	return count, nil
+}
+
 // Delete deletes a timeline
 func (r *repository) DeleteTimeline(ctx context.Context, id string) error {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.DeleteTimeline")
@@ -1109,11 +1495,112 @@ func (r *repository) DeleteTimeline(ctx context.Context, id string) error {
 		return err
 	}
 
+	// timelines merged into this one are not redirected anymore
+	err = r.db.WithContext(ctx).Delete(&core.TimelineAlias{}, "timeline = ? OR merged_into = ?", id, id).Error
+	if err != nil {
+		return err
+	}
+	r.mc.Delete(aliasCacheKey)
+	r.mc.Delete(readerCacheKey)
+
 	r.mc.Decrement("timeline_count", 1)
 
 	return nil
 }
 
+// Merge marks the timeline as merged into another one. timelines already merged into the timeline
+// are moved to the other one too, so that an alias never points to another alias
+func (r *repository) Merge(ctx context.Context, timeline, into string) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.Merge")
+	defer span.End()
+
+	timeline, err := r.normalizeLocalDBID(timeline)
+	if err != nil {
+		return err
+	}
+	into, err = r.normalizeLocalDBID(into)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&core.TimelineAlias{Timeline: timeline, MergedInto: into}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&core.TimelineAlias{}).Where("merged_into = ?", timeline).Update("merged_into", into).Error
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	r.mc.Delete(aliasCacheKey)
+	return nil
+}
+
+// GetAliases returns the timelines merged into another one, by their database ids
+func (r *repository) GetAliases(ctx context.Context) (map[string]string, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.GetAliases")
+	defer span.End()
+
+	aliases := map[string]string{}
+	item, err := r.mc.Get(aliasCacheKey)
+	if err == nil && json.Unmarshal(item.Value, &aliases) == nil {
+		return aliases, nil
+	}
+
+	var rows []core.TimelineAlias
+	err = r.db.WithContext(ctx).Find(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, row := range rows {
+		aliases[row.Timeline] = row.MergedInto
+	}
+
+	value, err := json.Marshal(aliases)
+	if err == nil {
+		r.mc.Set(&memcache.Item{Key: aliasCacheKey, Value: value, Expiration: aliasCacheTTL})
+	}
+
+	return aliases, nil
+}
+
+// GetReaders returns who may read each private timeline, by their database ids.
+// the owner and the author are included, so that a check is a lookup in the list
+func (r *repository) GetReaders(ctx context.Context) (map[string][]string, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.GetReaders")
+	defer span.End()
+
+	readers := map[string][]string{}
+	item, err := r.mc.Get(readerCacheKey)
+	if err == nil && json.Unmarshal(item.Value, &readers) == nil {
+		return readers, nil
+	}
+
+	var rows []core.Timeline
+	err = r.db.WithContext(ctx).
+		Select("id", "owner", "author", "readers").
+		Where("cardinality(readers) > 0").
+		Find(&rows).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, row := range rows {
+		readers[row.ID] = append([]string{row.Owner, row.Author}, row.Readers...)
+	}
+
+	value, err := json.Marshal(readers)
+	if err == nil {
+		r.mc.Set(&memcache.Item{Key: readerCacheKey, Value: value, Expiration: readerCacheTTL})
+	}
+
+	return readers, nil
+}
+
 // List Timeline Subscriptions
 func (r *repository) ListTimelineSubscriptions(ctx context.Context) (map[string]int64, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.ListTimelineSubscriptions")
@@ -1146,9 +1633,9 @@ func (r *repository) Subscribe(ctx context.Context, channels []string, event cha
 	defer pubsub.Close()
 
 	chanstr := strings.Join(channels, ",")
-	err := r.rdb.Publish(context.Background(), "concrnt:subscription:updated", chanstr).Err()
+	err := r.rdb.Publish(context.Background(), SubscriptionUpdatedChannel, chanstr).Err()
 	if err != nil {
-		slog.ErrorContext(
+		logging.Error(
 			ctx, "fail to publish message to Redis",
 			slog.String("error", err.Error()),
 			slog.String("module", "timeline"),
@@ -1165,13 +1652,17 @@ func (r *repository) Subscribe(ctx context.Context, channels []string, event cha
 			var item core.Event
 			err := json.Unmarshal([]byte(msg.Payload), &item)
 			if err != nil {
-				slog.Error(
+				logging.Error(ctx,
 					"failed to unmarshal message",
 					slog.String("error", err.Error()),
 				)
 				continue
 			}
-			event <- item
+			select {
+			case event <- item:
+			case <-ctx.Done():
+				return nil
+			}
 		}
 	}
 }
@@ -1180,6 +1671,70 @@ func (r *repository) Query(ctx context.Context, timelineID, schema, owner, autho
 	ctx, span := tracer.Start(ctx, "Timeline.Repository.Query")
 	defer span.End()
 
+	query, err := r.itemQuery(ctx, timelineID, schema, owner, author, until, limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var items []core.TimelineItem
+	err = query.Find(&items).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// streamQueryTimeout bounds the whole read of QueryEach, in place of the statement timeouts.
+// the rows are read as fast as the client takes them, which may take longer than a statement should
+const streamQueryTimeout = 2 * time.Minute
+
+// QueryEach calls fn with the items of Query one at a time, as the rows are read from the database.
+// the items are never held together, so the limit can be as large as an export needs
+func (r *repository) QueryEach(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(core.TimelineItem) error) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Repository.QueryEach")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, streamQueryTimeout)
+	defer cancel()
+
+	query, err := r.itemQuery(ctx, timelineID, schema, owner, author, until, limit)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	rows, err := database.WithoutTimeout(query).Rows()
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item core.TimelineItem
+		err = r.db.ScanRows(rows, &item)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		err = fn(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// itemQuery builds the query of the items of Query, newest first
+func (r *repository) itemQuery(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int) (*gorm.DB, error) {
 	query := r.db.WithContext(ctx).Model(&core.TimelineItem{})
 
 	if timelineID != "" {
@@ -1209,12 +1764,5 @@ func (r *repository) Query(ctx context.Context, timelineID, schema, owner, autho
 		query = query.Where("author = ?", author)
 	}
 
-	var items []core.TimelineItem
-	err := query.Where("c_date < ?", until).Order("c_date desc").Limit(limit).Find(&items).Error
-	if err != nil {
-		span.RecordError(err)
-		return nil, err
-	}
-
-	return items, nil
+	return query.Where("c_date < ?", until).Order("c_date desc").Limit(limit), nil
 }
@@ -890,3 +890,70 @@ func TestLoadLocalBody(t *testing.T) {
 	}
 
 }
+
+func TestLoadLocalBodies(t *testing.T) {
+	var cleanup_db func()
+	db, cleanup_db := testutil.CreateDB()
+	defer cleanup_db()
+
+	var cleanup_mc func()
+	mc, cleanup_mc := testutil.CreateMC()
+	defer cleanup_mc()
+
+	pivotEpoch := core.Time2Chunk(time.Now())
+	pivotTime := core.Chunk2RecentTime(pivotEpoch)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSchema := mock_core.NewMockSchemaService(ctrl)
+	mockSchema.EXPECT().UrlToID(gomock.Any(), gomock.Any()).Return(uint(0), nil).AnyTimes()
+	mockSchema.EXPECT().IDToUrl(gomock.Any(), gomock.Any()).Return("", nil).AnyTimes()
+
+	repo := repository{
+		db:     db,
+		mc:     mc,
+		schema: mockSchema,
+		config: core.Config{
+			FQDN: "local.example.com",
+		},
+	}
+
+	// a sparse timeline, a dense one which needs the whole chunk, and an empty one are loaded together
+	for i := 0; i < 40; i++ {
+		_, err := repo.CreateItem(ctx, core.TimelineItem{
+			ResourceID: fmt.Sprintf("m%026d", i),
+			TimelineID: "t00000000000000000000000000",
+			Owner:      "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2",
+			CDate:      pivotTime.Add(-time.Minute * time.Duration(i)),
+		})
+		assert.NoError(t, err)
+		_, err = repo.CreateItem(ctx, core.TimelineItem{
+			ResourceID: fmt.Sprintf("m%026d", i),
+			TimelineID: "t11111111111111111111111111",
+			Owner:      "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2",
+			CDate:      pivotTime.Add(-time.Minute * time.Duration(i) / 10),
+		})
+		assert.NoError(t, err)
+	}
+
+	chunks, err := repo.loadLocalBodies(ctx, map[string]string{
+		"t00000000000000000000000000@local.example.com": pivotEpoch,
+		"t11111111111111111111111111@local.example.com": pivotEpoch,
+		"t22222222222222222222222222@local.example.com": pivotEpoch,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+
+	sparse := chunks["t00000000000000000000000000@local.example.com"]
+	assert.Len(t, sparse.Items, 32)
+	assert.Equal(t, "m00000000000000000000000000", sparse.Items[0].ResourceID)
+	assert.Equal(t, "t00000000000000000000000000@local.example.com", sparse.Items[0].TimelineID)
+
+	dense := chunks["t11111111111111111111111111@local.example.com"]
+	assert.Len(t, dense.Items, 40)
+	assert.Equal(t, "m00000000000000000000000000", dense.Items[0].ResourceID)
+
+	_, err = mc.Get(tlBodyCachePrefix + "t11111111111111111111111111@local.example.com:" + pivotEpoch)
+	assert.NoError(t, err)
+}
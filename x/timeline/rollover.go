@@ -0,0 +1,36 @@
+package timeline
+
+import (
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
+)
+
+// epochRolloverWindow is how long around the start of an epoch its iterators are treated as unsettled.
+// an item written just before the rollover may be committed after a reader of the new epoch looked up
+// the iterator, which then points past the chunk of the item
+const epochRolloverWindow = 10 * time.Second
+
+// invalidateRolledOver drops the cached iterators of the epochs following the one of an item written near
+// its end, so that they are looked up again with the item. items of older epochs are left as they were
+func invalidateRolledOver(mc cache.Cache, timeline, itemEpoch string, now time.Time) {
+	end := core.Chunk2RecentTime(itemEpoch)
+	if end.Before(now.Add(-epochRolloverWindow)) || end.After(now.Add(epochRolloverWindow)) {
+		return
+	}
+
+	limit := now.Add(epochRolloverWindow)
+	for epoch := core.NextChunk(itemEpoch); !core.EpochTime(epoch).After(limit); epoch = core.NextChunk(epoch) {
+		mc.Delete(tlItrCachePrefix + timeline + ":" + epoch)
+	}
+}
+
+// itrCacheExpiration is the expiration of an iterator looked up for the epoch. the iterator of an epoch which
+// has just begun may miss items of the previous one still being committed, so it is kept only briefly
+func itrCacheExpiration(epoch string, now time.Time) int32 {
+	if now.Sub(core.EpochTime(epoch)) < epochRolloverWindow {
+		return int32(epochRolloverWindow / time.Second)
+	}
+	return tlItrCacheTTL
+}
@@ -0,0 +1,44 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
+)
+
+func TestInvalidateRolledOver(t *testing.T) {
+	mc, err := cache.NewMemory(1 << 20)
+	assert.NoError(t, err)
+
+	timeline := "t00000000000000000000000000@local.example.com"
+	epoch := core.Time2Chunk(time.Now().Add(-time.Hour))
+	next := core.NextChunk(epoch)
+	itrKey := func(epoch string) string { return tlItrCachePrefix + timeline + ":" + epoch }
+
+	// a reader of the next epoch looked up its iterator before the item was committed
+	mc.Set(&memcache.Item{Key: itrKey(next), Value: []byte(core.PrevChunk(epoch))})
+
+	// written moments before the rollover, committed moments after
+	invalidateRolledOver(mc, timeline, epoch, core.EpochTime(next).Add(2*time.Second))
+	_, err = mc.Get(itrKey(next))
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+
+	// items in the middle of their epoch leave the next one alone
+	mc.Set(&memcache.Item{Key: itrKey(next), Value: []byte(next)})
+	invalidateRolledOver(mc, timeline, epoch, core.EpochTime(epoch).Add(5*time.Minute))
+	_, err = mc.Get(itrKey(next))
+	assert.NoError(t, err)
+}
+
+func TestItrCacheExpiration(t *testing.T) {
+	epoch := core.Time2Chunk(time.Now())
+	start := core.EpochTime(epoch)
+
+	assert.Equal(t, int32(epochRolloverWindow/time.Second), itrCacheExpiration(epoch, start.Add(time.Second)))
+	assert.Equal(t, int32(tlItrCacheTTL), itrCacheExpiration(epoch, start.Add(time.Minute)))
+}
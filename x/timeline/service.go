@@ -3,6 +3,7 @@ package timeline
 import (
 	"container/heap"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/totegamma/concurrent/cdid"
 	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 )
 
 type service struct {
@@ -27,6 +29,7 @@ type service struct {
 	domain       core.DomainService
 	semanticid   core.SemanticIDService
 	subscription core.SubscriptionService
+	mute         core.MuteService
 	policy       core.PolicyService
 	config       core.Config
 
@@ -40,6 +43,7 @@ func NewService(
 	domain core.DomainService,
 	semanticid core.SemanticIDService,
 	subscription core.SubscriptionService,
+	mute core.MuteService,
 	policy core.PolicyService,
 	config core.Config,
 ) core.TimelineService {
@@ -49,6 +53,7 @@ func NewService(
 		domain,
 		semanticid,
 		subscription,
+		mute,
 		policy,
 		config,
 		0,
@@ -70,44 +75,70 @@ func min(a, b int) int {
 	return b
 }
 
-func (s *service) GetChunks(ctx context.Context, timelines []string, epoch string) (map[string]core.Chunk, error) {
-	ctx, span := tracer.Start(ctx, "Timeline.Service.GetChunks")
-	defer span.End()
-
-	normalized := make([]string, 0)
-	normtable := make(map[string]string)
-	for _, timeline := range timelines {
-		normalizedTimeline, err := s.NormalizeTimelineID(ctx, timeline)
-		if err != nil {
-			slog.WarnContext(
-				ctx,
-				fmt.Sprintf("failed to normalize timeline: %s", timeline),
-				slog.String("module", "timeline"),
-			)
-			continue
-		}
-		normalized = append(normalized, normalizedTimeline)
-		normtable[normalizedTimeline] = timeline
+// muteFilter returns the mute filter of the requester, or nil when nothing is muted
+func (s *service) muteFilter(ctx context.Context) core.MuteFilter {
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok || requester == "" {
+		return nil
 	}
 
-	query, err := s.repository.LookupChunkItrs(ctx, normalized, epoch)
+	filter, err := s.mute.Filter(ctx, requester)
 	if err != nil {
-		span.RecordError(err)
-		return nil, err
+		logging.Warn(ctx, "failed to load mute rules", slog.String("module", "timeline"), slog.String("error", err.Error()))
+		return nil
+	}
+	if filter.Empty() {
+		return nil
+	}
+	return filter
+}
+
+// filterChunks returns copies of the chunks without the muted items. cached chunks are shared, so they are never modified
+func filterChunks(ctx context.Context, filter core.MuteFilter, chunks map[string]core.Chunk) map[string]core.Chunk {
+	if filter == nil {
+		return chunks
 	}
 
-	chunks, err := s.repository.LoadChunkBodies(ctx, query)
+	items := make([]core.TimelineItem, 0)
+	for _, chunk := range chunks {
+		items = append(items, chunk.Items...)
+	}
+	muted := filter.MutedItems(ctx, items)
+	if len(muted) == 0 {
+		return chunks
+	}
+
+	filtered := make(map[string]core.Chunk, len(chunks))
+	for key, chunk := range chunks {
+		kept := make([]core.TimelineItem, 0, len(chunk.Items))
+		for _, item := range chunk.Items {
+			if !muted[item.ResourceID] {
+				kept = append(kept, item)
+			}
+		}
+		chunk.Items = kept
+		filtered[key] = chunk
+	}
+	return filtered
+}
+
+// GetChunks returns the chunks of the timelines without the items the requester muted
+func (s *service) GetChunks(ctx context.Context, timelines []string, epoch string) (map[string]core.Chunk, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.GetChunks")
+	defer span.End()
+
+	chunks, err := s.getChunks(ctx, timelines, epoch)
 	if err != nil {
 		span.RecordError(err)
 		return nil, err
 	}
 
-	recovered := make(map[string]core.Chunk)
-	for k, v := range chunks {
-		recovered[normtable[k]] = v
-	}
+	return filterChunks(ctx, s.muteFilter(ctx), chunks), nil
+}
 
-	return recovered, nil
+// getChunks returns the chunks of the timelines as stored
+func (s *service) getChunks(ctx context.Context, timelines []string, epoch string) (map[string]core.Chunk, error) {
+	return s.loadPlannedChunks(ctx, s.planTimelines(ctx, timelines), epoch)
 }
 
 // NormalizeTimelineID normalizes timelineID
@@ -118,6 +149,8 @@ func (s *service) GetChunks(ctx context.Context, timelines []string, epoch strin
 // <semanticID>@<remoteuser> -> <semanticID>@<userID>@<domainname>
 // <semanticID>@<userID>@<localdomain> -> t+<hash>@<localdomain>
 // <semanticID>@<userID>@<remotedomain> -> <semanticID>@<userID>@<remotedomain>
+// <semanticID>@<localdomain> -> t+<hash>@<localdomain> (semantic ids of the domain, e.g. the firehose)
+// <semanticID>@<remotedomain> -> <semanticID>@<remotedomain>
 func (s *service) NormalizeTimelineID(ctx context.Context, timeline string) (string, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.NormalizeTimelineID")
 	defer span.End()
@@ -159,6 +192,10 @@ func (s *service) NormalizeTimelineID(ctx context.Context, timeline string) (str
 		if cdid.IsSeemsCDID(id, 't') {
 			normalized = id + "@" + domain
 		} else {
+			// semantic ids without an owner are the ones of the domain
+			if userid == "" {
+				userid = s.config.CSID
+			}
 			target, err := s.semanticid.Lookup(ctx, id, userid)
 			if err != nil {
 				span.SetAttributes(attribute.String("timeline", timeline))
@@ -169,7 +206,7 @@ func (s *service) NormalizeTimelineID(ctx context.Context, timeline string) (str
 			normalized = target + "@" + domain
 		}
 	} else {
-		if cdid.IsSeemsCDID(id, 't') {
+		if cdid.IsSeemsCDID(id, 't') || userid == "" {
 			normalized = id + "@" + domain
 		} else {
 			normalized = id + "@" + userid + "@" + domain
@@ -188,18 +225,24 @@ func (s *service) LookupChunkItr(ctx context.Context, timeliens []string, epoch
 	ctx, span := tracer.Start(ctx, "Timeline.Service.LookupChunkItr")
 	defer span.End()
 
+	gate := s.readerGate(ctx)
+	reader := gate.reader(ctx)
+
 	normalized := make([]string, 0)
 	normtable := make(map[string]string)
 	for _, timeline := range timeliens {
 		normalizedTimeline, err := s.NormalizeTimelineID(ctx, timeline)
 		if err != nil {
-			slog.WarnContext(
+			logging.Warn(
 				ctx,
 				fmt.Sprintf("failed to normalize timeline: %s", timeline),
 				slog.String("module", "timeline"),
 			)
 			continue
 		}
+		if !gate.allowsChunks(ctx, reader, normalizedTimeline) {
+			continue
+		}
 		normalized = append(normalized, normalizedTimeline)
 		normtable[normalizedTimeline] = timeline
 	}
@@ -223,19 +266,25 @@ func (s *service) LoadChunkBody(ctx context.Context, query map[string]string) (m
 	ctx, span := tracer.Start(ctx, "Timeline.Service.LoadChunkBody")
 	defer span.End()
 
+	gate := s.readerGate(ctx)
+	reader := gate.reader(ctx)
+
 	normalized := map[string]string{}
 	normtable := map[string]string{}
 
 	for k, v := range query {
 		normalizedTimeline, err := s.NormalizeTimelineID(ctx, k)
 		if err != nil {
-			slog.WarnContext(
+			logging.Warn(
 				ctx,
 				fmt.Sprintf("failed to normalize timeline: %s", k),
 				slog.String("module", "timeline"),
 			)
 			continue
 		}
+		if !gate.allowsChunks(ctx, reader, normalizedTimeline) {
+			continue
+		}
 		normalized[normalizedTimeline] = v
 		normtable[normalizedTimeline] = k
 	}
@@ -251,7 +300,7 @@ func (s *service) LoadChunkBody(ctx context.Context, query map[string]string) (m
 		recovered[normtable[k]] = v
 	}
 
-	return recovered, nil
+	return filterChunks(ctx, s.muteFilter(ctx), recovered), nil
 }
 
 func (s *service) GetRecentItemsFromSubscription(ctx context.Context, subscription string, until time.Time, limit int) ([]core.TimelineItem, error) {
@@ -306,19 +355,45 @@ func (s *service) GetRecentItems(ctx context.Context, timelines []string, until
 
 	span.SetAttributes(attribute.StringSlice("timelines", timelines))
 
-	cancelMap, err := s.ListRecentlyRemovedItems(ctx, timelines)
-	if err != nil {
-		span.RecordError(err)
-		return nil, err
-	}
+	// the timelines are normalized once, then the retracted items and the chunks are fetched at the same time
+	plan := s.planTimelines(ctx, timelines)
 
 	epoch := core.Time2Chunk(until)
-	chunks, err := s.GetChunks(ctx, timelines, epoch)
+
+	var cancelMap map[string][]string
+	var cancelErr error
+	removals := make(chan struct{})
+	go func() {
+		defer close(removals)
+		cancelMap, cancelErr = s.listPlannedRemovals(ctx, plan)
+	}()
+
+	chunks, err := s.loadPlannedChunks(ctx, plan, epoch)
+	<-removals
+	if cancelErr != nil {
+		span.RecordError(cancelErr)
+		return nil, cancelErr
+	}
 	if err != nil {
 		span.RecordError(err)
 		return nil, err
 	}
 
+	// muted items are skipped like retracted ones, so that a chunk full of them doesn't end the walk
+	filter := s.muteFilter(ctx)
+	muted := map[string]bool{}
+	markMuted := func(chunks map[string]core.Chunk) {
+		if filter == nil {
+			return
+		}
+		for _, chunk := range chunks {
+			for id := range filter.MutedItems(ctx, chunk.Items) {
+				muted[id] = true
+			}
+		}
+	}
+	markMuted(chunks)
+
 	span.SetAttributes(attribute.Int("chunks", len(chunks)))
 
 	pq := make(PriorityQueue, 0)
@@ -350,6 +425,8 @@ func (s *service) GetRecentItems(ctx context.Context, timelines []string, until
 
 	var result []core.TimelineItem
 	var uniq = make(map[string]bool)
+	// expired items may still sit in cached chunks until the prune job retracts them
+	now := time.Now()
 
 	var itrlimit = 1000
 	for len(result) < limit && pq.Len() > 0 && itrlimit > 0 {
@@ -362,7 +439,7 @@ func (s *service) GetRecentItems(ctx context.Context, timelines []string, until
 			retracted = slices.Contains(cancelList, smallest.Item.ResourceID)
 		}
 
-		if !exists && !retracted {
+		if !exists && !retracted && !muted[smallest.Item.ResourceID] && !smallest.Item.Expired(now) {
 			result = append(result, smallest.Item)
 			uniq[smallest.Item.ResourceID] = true
 		}
@@ -382,11 +459,12 @@ func (s *service) GetRecentItems(ctx context.Context, timelines []string, until
 			if prevEpoch == smallest.Epoch {
 				prevEpoch = core.PrevChunk(prevEpoch)
 			}
-			prevChunks, err := s.GetChunks(ctx, []string{timeline}, prevEpoch)
+			prevChunks, err := s.loadPlannedChunks(ctx, plan.only(timeline), prevEpoch)
 			if err != nil {
 				span.RecordError(err)
 				continue
 			}
+			markMuted(prevChunks)
 			if prevChunk, ok := prevChunks[timeline]; ok {
 				if len(prevChunk.Items) <= 0 {
 					span.AddEvent("empty chunk")
@@ -442,7 +520,7 @@ func (s *service) PostItem(ctx context.Context, timeline string, item core.Timel
 
 	query := strings.Split(timeline, "@")
 	if len(query) != 2 {
-		return core.TimelineItem{}, fmt.Errorf("Invalid format: %v", timeline)
+		return core.TimelineItem{}, core.NewErrorInvalidArgument(fmt.Sprintf("invalid timeline: %v", timeline))
 	}
 
 	timelineID, timelineHost := query[0], query[1]
@@ -491,6 +569,10 @@ func (s *service) PostItem(ctx context.Context, timeline string, item core.Timel
 		return core.TimelineItem{}, err
 	}
 
+	if tl.MergedInto != "" {
+		return core.TimelineItem{}, core.NewErrorTimelineMerged(timeline, tl.MergedInto+"@"+s.config.FQDN)
+	}
+
 	requesterEntity, err := s.entity.Get(ctx, author)
 	if err != nil {
 		span.RecordError(err)
@@ -501,17 +583,20 @@ func (s *service) PostItem(ctx context.Context, timeline string, item core.Timel
 		json.Unmarshal([]byte(*tl.PolicyParams), &params)
 	}
 
-	result, err := s.policy.TestWithPolicyURL(
-		ctx,
-		tl.Policy,
-		core.RequestContext{
-			Self:      tl,
-			Requester: requesterEntity,
-			Params:    params,
-			Document:  doc,
-		},
-		"timeline.distribute",
-	)
+	var spam *core.SpamVerdict
+	if verdict, ok := ctx.Value(core.SpamVerdictCtxKey).(core.SpamVerdict); ok {
+		spam = &verdict
+	}
+
+	request := core.RequestContext{
+		Self:      tl,
+		Requester: requesterEntity,
+		Params:    params,
+		Document:  doc,
+		Spam:      spam,
+	}
+
+	result, err := s.policy.TestWithPolicyURL(ctx, tl.Policy, request, "timeline.distribute")
 	if err != nil {
 		span.RecordError(err)
 	}
@@ -521,17 +606,22 @@ func (s *service) PostItem(ctx context.Context, timeline string, item core.Timel
 	if !writable {
 		span.RecordError(fmt.Errorf("You don't have timeline.distribute access to %v", timelineID))
 		span.SetAttributes(attribute.Int("result", int(result)))
-		slog.InfoContext(
+		logging.Info(
 			ctx, "failed to post to timeline",
 			slog.String("type", "audit"),
 			slog.String("principal", author),
 			slog.String("timeline", timelineID),
 			slog.String("module", "timeline"),
 		)
-		return core.TimelineItem{}, fmt.Errorf("You don't have write access to %v", timelineID)
+		return core.TimelineItem{}, core.NewErrorPolicyDenied("timeline.distribute")
+	}
+
+	err = s.throttle(ctx, tl, author, request)
+	if err != nil {
+		return core.TimelineItem{}, err
 	}
 
-	slog.DebugContext(
+	logging.Debug(
 		ctx, fmt.Sprintf("post to local timeline: %v to %v", item.ResourceID, timelineID),
 		slog.String("module", "timeline"),
 	)
@@ -539,7 +629,7 @@ func (s *service) PostItem(ctx context.Context, timeline string, item core.Timel
 	// add to timeline
 	created, err := s.repository.CreateItem(ctx, item)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to create item", slog.String("error", err.Error()), slog.String("module", "timeline"))
+		logging.Error(ctx, "failed to create item", slog.String("error", err.Error()), slog.String("module", "timeline"))
 		span.RecordError(err)
 		return core.TimelineItem{}, err
 	}
@@ -559,6 +649,29 @@ func (s *service) RemoveItemsByResourceID(ctx context.Context, resourceID string
 	return err
 }
 
+// expireBatchSize bounds the items deleted by one statement when pruning
+const expireBatchSize = 1000
+
+// ExpireItems removes the items which expired from the timelines and returns how many were removed
+func (s *service) ExpireItems(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.ExpireItems")
+	defer span.End()
+
+	var total int64
+	now := time.Now()
+	for {
+		expired, err := s.repository.ExpireItems(ctx, now, expireBatchSize)
+		if err != nil {
+			span.RecordError(err)
+			return total, err
+		}
+		total += int64(len(expired))
+		if len(expired) < expireBatchSize {
+			return total, nil
+		}
+	}
+}
+
 func (s *service) PublishEvent(ctx context.Context, event core.Event) error {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.PublishEvent")
 	defer span.End()
@@ -594,7 +707,7 @@ func (s *service) Event(ctx context.Context, mode core.CommitMode, document, sig
 }
 
 // Create updates timeline information
-func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, document, signature string) (core.Timeline, error) {
+func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, document, signature, option string) (core.Timeline, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.UpsertTimline")
 	defer span.End()
 
@@ -604,6 +717,18 @@ func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, docu
 		return core.Timeline{}, err
 	}
 
+	var opts upsertOption
+	if option != "" {
+		err = json.Unmarshal([]byte(option), &opts)
+		if err != nil {
+			return core.Timeline{}, core.NewErrorInvalidArgument("invalid option")
+		}
+	}
+
+	// the version the update is applied over. 0 creates the timeline, or overwrites it when forced
+	var basis int64
+	version := int64(1)
+
 	// return existing timeline if semanticID exists
 	if doc.SemanticID != "" {
 		existingID, err := s.semanticid.Lookup(ctx, doc.SemanticID, doc.Signer)
@@ -646,12 +771,15 @@ func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, docu
 			return core.Timeline{}, fmt.Errorf("Timeline already exists: %s", doc.ID)
 		}
 
+		owned, counted := s.countOwned(ctx, doc.Signer)
+
 		policyResult, err := s.policy.TestWithPolicyURL(
 			ctx,
 			"",
 			core.RequestContext{
 				Requester: signer,
 				Document:  doc,
+				Owned:     owned,
 			},
 			"timeline.create",
 		)
@@ -661,7 +789,12 @@ func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, docu
 
 		result := s.policy.Summerize([]core.PolicyEvalResult{policyResult}, "timeline.create", nil)
 		if !result {
-			return core.Timeline{}, fmt.Errorf("You don't have timeline.create access")
+			return core.Timeline{}, core.NewErrorPolicyDenied("timeline.create")
+		}
+
+		limit := s.config.OwnershipLimits.For(core.ParseTags(signer.Tag)).Timelines
+		if counted && limit > 0 && owned >= limit {
+			return core.Timeline{}, core.NewErrorLimitExceeded("timelines", limit, owned)
 		}
 
 	} else { // Update
@@ -709,8 +842,19 @@ func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, docu
 
 		result := s.policy.Summerize([]core.PolicyEvalResult{policyResult}, "timeline.update", nil)
 		if !result {
-			return core.Timeline{}, fmt.Errorf("You don't have timeline.update access")
+			return core.Timeline{}, core.NewErrorPolicyDenied("timeline.update")
 		}
+
+		// replayed commits are applied in the order of the repository, whatever the version they were made over
+		if !opts.Force && mode != core.CommitModeLocalOnlyExec {
+			err = checkVersion(existance.Document, existance.Version, doc.SignedAt, doc.Version)
+			if err != nil {
+				span.RecordError(err)
+				return core.Timeline{}, err
+			}
+			basis = existance.Version
+		}
+		version = existance.Version + 1
 	}
 
 	var policyparams *string = nil
@@ -718,19 +862,30 @@ func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, docu
 		policyparams = &doc.PolicyParams
 	}
 
-	saved, err := s.repository.UpsertTimeline(ctx, core.Timeline{
-		ID:           doc.ID,
-		Owner:        doc.Owner,
-		Author:       doc.Signer,
-		Indexable:    doc.Indexable,
-		Schema:       doc.Schema,
-		Policy:       doc.Policy,
-		PolicyParams: policyparams,
-		Document:     document,
-		Signature:    signature,
-	})
-
+	timeline := core.Timeline{
+		ID:             doc.ID,
+		Owner:          doc.Owner,
+		Author:         doc.Signer,
+		Indexable:      doc.Indexable && len(doc.Readers) == 0, // private timelines are never discovered
+		FirehoseOptOut: doc.FirehoseOptOut,
+		Readers:        doc.Readers,
+		Schema:         doc.Schema,
+		Policy:         doc.Policy,
+		PolicyParams:   policyparams,
+		Document:       document,
+		Signature:      signature,
+		Version:        version,
+	}
+
+	var saved core.Timeline
+	if basis > 0 {
+		// another update may have been stored since the timeline was read
+		saved, err = s.repository.UpdateTimeline(ctx, timeline, basis)
+	} else {
+		saved, err = s.repository.UpsertTimeline(ctx, timeline)
+	}
 	if err != nil {
+		span.RecordError(err)
 		return core.Timeline{}, err
 	}
 
@@ -746,6 +901,231 @@ func (s *service) UpsertTimeline(ctx context.Context, mode core.CommitMode, docu
 	return saved, nil
 }
 
+// countOwned returns the number of timelines the author owns. the limits are soft:
+// counted is false when the count is unavailable, and creations are not refused then
+func (s *service) countOwned(ctx context.Context, author string) (int64, bool) {
+	owned, err := s.repository.CountTimelineByAuthorOwned(ctx, author)
+	if err != nil {
+		logging.Warn(ctx, "failed to count owned timelines", slog.String("error", err.Error()), slog.String("module", "timeline"))
+		return 0, false
+	}
+	return owned, true
+}
+
+// upsertOption is the option of a timeline commit. Force overwrites the timeline even if the update was made
+// over an older version
+type upsertOption struct {
+	Force bool `json:"force"`
+}
+
+// checkVersion refuses an update signed before the stored document, or made over another version than the stored one
+func checkVersion(stored string, current int64, signedAt time.Time, basis int64) error {
+	var base core.DocumentBase[any]
+	err := json.Unmarshal([]byte(stored), &base)
+	if err == nil && signedAt.Before(base.SignedAt) {
+		return core.NewErrorConflict(current)
+	}
+	if basis != 0 && basis != current {
+		return core.NewErrorConflict(current)
+	}
+	return nil
+}
+
+// firehoseSchema is the schema of the timelines of the domain, the one of community timelines
+const firehoseSchema = "https://schema.concrnt.world/t/community.json"
+
+// EnsureFirehose returns the firehose timeline of the domain, creating it on the first call
+func (s *service) EnsureFirehose(ctx context.Context) (core.Timeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.EnsureFirehose")
+	defer span.End()
+
+	timeline, err := s.ensureDomainTimeline(ctx, core.FirehoseTimelineSemanticID, map[string]any{
+		"name":        s.config.FQDN,
+		"shortname":   "local",
+		"description": "public messages posted on " + s.config.FQDN,
+	}, true, time.Now())
+	if err != nil {
+		span.RecordError(err)
+	}
+	return timeline, err
+}
+
+// EnsureThread returns the thread timeline of the conversation started by the root message, creating it on the first call.
+// the document is signed at the time of the root, so that concurrent first replies create the same timeline
+func (s *service) EnsureThread(ctx context.Context, rootID string) (core.Timeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.EnsureThread")
+	defer span.End()
+
+	if !cdid.IsSeemsCDID(rootID, 'm') {
+		return core.Timeline{}, core.NewErrorInvalidArgument("invalid root message id")
+	}
+	signedAt, err := cdid.TimeOf(rootID)
+	if err != nil {
+		return core.Timeline{}, core.NewErrorInvalidArgument("invalid root message id")
+	}
+
+	timeline, err := s.ensureDomainTimeline(ctx, core.ThreadTimelineSemanticIDPrefix+rootID, map[string]any{
+		"name":        "thread",
+		"shortname":   "thread",
+		"description": "replies to " + rootID,
+		"root":        rootID,
+	}, false, signedAt)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return timeline, err
+}
+
+// ensureDomainTimeline returns the timeline the domain names with the semantic id, creating it when it is missing
+func (s *service) ensureDomainTimeline(ctx context.Context, semanticID string, body map[string]any, indexable bool, signedAt time.Time) (core.Timeline, error) {
+	existingID, err := s.semanticid.Lookup(ctx, semanticID, s.config.CSID)
+	if err == nil {
+		existing, err := s.repository.GetTimeline(ctx, existingID)
+		if err == nil {
+			return existing, nil
+		}
+	}
+
+	// the timeline may still be named by the key before the latest rotation
+	if s.config.PreviousCSID != "" {
+		previousID, err := s.semanticid.Lookup(ctx, semanticID, s.config.PreviousCSID)
+		if err == nil {
+			previous, err := s.repository.GetTimeline(ctx, previousID)
+			if err == nil {
+				return s.migrateDomainTimeline(ctx, previous)
+			}
+		}
+	}
+
+	doc := core.TimelineDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Signer:     s.config.CSID,
+			Owner:      s.config.CSID,
+			Type:       "timeline",
+			Schema:     firehoseSchema,
+			Body:       body,
+			SemanticID: semanticID,
+			SignedAt:   signedAt,
+		},
+		Indexable:   indexable,
+		DomainOwned: true,
+	}
+
+	document, err := json.Marshal(doc)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+
+	signatureBytes, err := core.SignBytes(document, s.config.PrivateKey)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+	signature := hex.EncodeToString(signatureBytes)
+
+	hash := core.GetHash(document)
+	hash10 := [10]byte{}
+	copy(hash10[:], hash[:10])
+
+	saved, err := s.repository.UpsertTimeline(ctx, core.Timeline{
+		ID:        cdid.New(hash10, doc.SignedAt).String(),
+		Owner:     s.config.CSID,
+		Author:    s.config.CSID,
+		Indexable: indexable,
+		Schema:    firehoseSchema,
+		Document:  string(document),
+		Signature: signature,
+	})
+	if err != nil {
+		return core.Timeline{}, err
+	}
+
+	_, err = s.semanticid.Name(ctx, semanticID, s.config.CSID, saved.ID, string(document), signature)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+
+	return saved, nil
+}
+
+// MigrateDomainTimelines moves the timelines the domain signed with the key before the latest rotation to the
+// current key, along with their semantic ids. it returns the number of timelines moved
+func (s *service) MigrateDomainTimelines(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.MigrateDomainTimelines")
+	defer span.End()
+
+	if s.config.PreviousCSID == "" || s.config.PreviousCSID == s.config.CSID {
+		return 0, nil
+	}
+
+	timelines, err := s.repository.ListTimelineByAuthor(ctx, s.config.PreviousCSID)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	for i, timeline := range timelines {
+		_, err := s.migrateDomainTimeline(ctx, timeline)
+		if err != nil {
+			span.RecordError(err)
+			return i, err
+		}
+	}
+	return len(timelines), nil
+}
+
+// migrateDomainTimeline signs the document of the timeline again with the current key of the domain,
+// and names it under the current CSID instead of the previous one. the id of the timeline is kept
+func (s *service) migrateDomainTimeline(ctx context.Context, timeline core.Timeline) (core.Timeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.migrateDomainTimeline")
+	defer span.End()
+
+	var doc core.TimelineDocument[any]
+	err := json.Unmarshal([]byte(timeline.Document), &doc)
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+	doc.Signer = s.config.CSID
+	doc.Owner = s.config.CSID
+
+	document, err := json.Marshal(doc)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+
+	signatureBytes, err := core.SignBytes(document, s.config.PrivateKey)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+	signature := hex.EncodeToString(signatureBytes)
+
+	timeline.Owner = s.config.CSID
+	timeline.Author = s.config.CSID
+	timeline.Document = string(document)
+	timeline.Signature = signature
+
+	saved, err := s.repository.UpdateTimeline(ctx, timeline, timeline.Version)
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+
+	if doc.SemanticID != "" {
+		_, err = s.semanticid.Name(ctx, doc.SemanticID, s.config.CSID, saved.ID, string(document), signature)
+		if err != nil {
+			span.RecordError(err)
+			return core.Timeline{}, err
+		}
+		err = s.semanticid.Delete(ctx, doc.SemanticID, s.config.PreviousCSID)
+		if err != nil {
+			span.RecordError(err)
+			return core.Timeline{}, err
+		}
+	}
+
+	return saved, nil
+}
+
 // Get returns timeline information by ID
 func (s *service) GetTimeline(ctx context.Context, key string) (core.Timeline, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.GetTimeline")
@@ -779,6 +1159,104 @@ func (s *service) GetTimeline(ctx context.Context, key string) (core.Timeline, e
 	}
 }
 
+// Merge marks the timeline as merged into another local timeline: its reads are served from the other one,
+// writes to it are rejected, and the semantic ids pointing to it point to the other one from now on.
+// only the owner of the timeline can merge it
+func (s *service) Merge(ctx context.Context, timeline, into, requester string) (core.Timeline, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.Merge")
+	defer span.End()
+
+	source, err := s.getLocalTimeline(ctx, timeline)
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+	if source.Owner != requester {
+		return core.Timeline{}, core.NewErrorPermissionDenied()
+	}
+	if source.MergedInto != "" {
+		return core.Timeline{}, core.NewErrorInvalidArgument("the timeline is already merged into " + source.MergedInto)
+	}
+
+	target, err := s.getLocalTimeline(ctx, into)
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+	// readers and semantic ids of the source are redirected to the target, so its owner has to agree
+	if target.Owner != requester {
+		return core.Timeline{}, core.NewErrorPermissionDenied()
+	}
+	if target.ID == source.ID {
+		return core.Timeline{}, core.NewErrorInvalidArgument("a timeline can't be merged into itself")
+	}
+	if target.MergedInto != "" {
+		return core.Timeline{}, core.NewErrorInvalidArgument("the target is merged into " + target.MergedInto)
+	}
+
+	err = s.repository.Merge(ctx, source.ID, target.ID)
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+
+	repointed, err := s.semanticid.Repoint(ctx, source.ID, target.ID)
+	if err != nil {
+		span.RecordError(err)
+		return core.Timeline{}, err
+	}
+
+	logging.Info(
+		ctx,
+		fmt.Sprintf("timeline %s merged into %s", source.ID, target.ID),
+		slog.String("module", "timeline"),
+		slog.Int64("semanticIDs", repointed),
+	)
+
+	return s.repository.GetTimeline(ctx, source.ID)
+}
+
+// getLocalTimeline returns a timeline of this domain by any form of its id
+func (s *service) getLocalTimeline(ctx context.Context, timeline string) (core.Timeline, error) {
+	normalized, err := s.NormalizeTimelineID(ctx, timeline)
+	if err != nil {
+		return core.Timeline{}, err
+	}
+	split := strings.Split(normalized, "@")
+	if split[len(split)-1] != s.config.FQDN {
+		return core.Timeline{}, core.NewErrorInvalidArgument("not a timeline of this domain: " + timeline)
+	}
+	return s.repository.GetTimeline(ctx, split[0])
+}
+
+// ResolveAliases returns the timelines merged into another one among the requested, with the normalized id
+// of the timeline they were merged into
+func (s *service) ResolveAliases(ctx context.Context, timelines []string) (map[string]string, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.ResolveAliases")
+	defer span.End()
+
+	plan := s.planTimelines(ctx, timelines)
+
+	redirects := map[string]string{}
+	for requested := range plan.merged {
+		redirects[requested] = plan.ids[requested]
+	}
+	return redirects, nil
+}
+
+// followAlias returns the timeline a normalized local timeline was merged into, or the timeline itself
+func (s *service) followAlias(aliases map[string]string, normalized string) (string, bool) {
+	split := strings.Split(normalized, "@")
+	if len(split) != 2 || split[1] != s.config.FQDN || !cdid.IsSeemsCDID(split[0], 't') {
+		return normalized, false
+	}
+	into, ok := aliases[split[0][1:]]
+	if !ok {
+		return normalized, false
+	}
+	return "t" + into + "@" + s.config.FQDN, true
+}
+
 // TimelineListBySchema returns timelineList by schema
 func (s *service) ListTimelineBySchema(ctx context.Context, schema string) ([]core.Timeline, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.ListTimelineBySchema")
@@ -864,7 +1342,7 @@ func (s *service) Retract(ctx context.Context, mode core.CommitMode, document, s
 
 	result := s.policy.Summerize([]core.PolicyEvalResult{policyResult}, "timeline.retract", nil)
 	if !result {
-		return core.TimelineItem{}, []string{}, fmt.Errorf("You don't have timeline.retract access")
+		return core.TimelineItem{}, []string{}, core.NewErrorPolicyDenied("timeline.retract")
 	}
 
 	s.repository.DeleteItem(ctx, doc.Timeline, doc.Target)
@@ -932,6 +1410,102 @@ func (s *service) DeleteTimeline(ctx context.Context, mode core.CommitMode, docu
 	return deleteTarget, err
 }
 
+// WarmChunkCache loads chunk iterators and bodies of the most subscribed timelines into cache
+func (s *service) WarmChunkCache(ctx context.Context, epoch string, count int) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.WarmChunkCache")
+	defer span.End()
+
+	subscriptions, err := s.repository.ListTimelineSubscriptions(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	timelines := make([]string, 0, len(subscriptions))
+	for timeline := range subscriptions {
+		// skip non-timeline channels (e.g. concrnt:subscription:updated)
+		if !strings.Contains(timeline, "@") {
+			continue
+		}
+		timelines = append(timelines, timeline)
+	}
+
+	sort.Slice(timelines, func(i, j int) bool {
+		return subscriptions[timelines[i]] > subscriptions[timelines[j]]
+	})
+
+	if len(timelines) > count {
+		timelines = timelines[:count]
+	}
+
+	if len(timelines) == 0 {
+		return timelines, nil
+	}
+
+	query, err := s.repository.LookupChunkItrs(ctx, timelines, epoch)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	_, err = s.repository.LoadChunkBodies(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("warmed", len(timelines)))
+
+	return timelines, nil
+}
+
+// Backfill loads up to chunks of the most recent non-empty chunks of the timeline into cache, newest first.
+// it returns the number of chunks loaded. the walk stops at the first empty chunk
+func (s *service) Backfill(ctx context.Context, timeline string, chunks int) (int, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.Backfill")
+	defer span.End()
+
+	normalized, err := s.NormalizeTimelineID(ctx, timeline)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.String("timeline", normalized))
+
+	loaded := 0
+	epoch := core.Time2Chunk(time.Now())
+	for loaded < chunks {
+		itrs, err := s.repository.LookupChunkItrs(ctx, []string{normalized}, epoch)
+		if err != nil {
+			span.RecordError(err)
+			return loaded, err
+		}
+		itr, ok := itrs[normalized]
+		if !ok {
+			break
+		}
+		split := strings.Split(itr, ":")
+		itr = split[len(split)-1]
+
+		bodies, err := s.repository.LoadChunkBodies(ctx, map[string]string{normalized: itr})
+		if err != nil {
+			span.RecordError(err)
+			return loaded, err
+		}
+		chunk, ok := bodies[normalized]
+		if !ok || len(chunk.Items) == 0 {
+			break
+		}
+		loaded++
+
+		// the iterator points at the chunk holding the items, older ones are before it
+		epoch = core.PrevChunk(itr)
+	}
+
+	span.SetAttributes(attribute.Int("loaded", loaded))
+	return loaded, nil
+}
+
 func (s *service) ListTimelineSubscriptions(ctx context.Context) (map[string]int64, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.ListTimelineSubscriptions")
 	defer span.End()
@@ -973,6 +1547,7 @@ func (s *service) Realtime(ctx context.Context, request <-chan []string, respons
 	events := make(chan core.Event)
 
 	var mapper map[string]string
+	var filter core.MuteFilter
 
 	for {
 		select {
@@ -981,18 +1556,31 @@ func (s *service) Realtime(ctx context.Context, request <-chan []string, respons
 				cancel()
 			}
 
+			// rules are reloaded on each listen, so a new mute applies without reconnecting
+			filter = s.muteFilter(ctx)
+
+			// subscribers of a merged timeline get the events of the one it was merged into
+			aliases, err := s.repository.GetAliases(ctx)
+			if err != nil {
+				logging.Warn(ctx, "failed to load timeline aliases", slog.String("module", "timeline"), slog.String("error", err.Error()))
+			}
+
 			normalized := make([]string, 0)
 			mapper = make(map[string]string)
 			for _, timeline := range timelines {
 				normalizedTimeline, err := s.NormalizeTimelineID(ctx, timeline)
 				if err != nil {
-					slog.WarnContext(
+					logging.Warn(
 						ctx,
 						fmt.Sprintf("failed to normalize timeline: %s", timeline),
 						slog.String("module", "timeline"),
 					)
 					continue
 				}
+				normalizedTimeline, _ = s.followAlias(aliases, normalizedTimeline)
+				if _, ok := mapper[normalizedTimeline]; ok {
+					continue
+				}
 				normalized = append(normalized, normalizedTimeline)
 				mapper[normalizedTimeline] = timeline
 			}
@@ -1002,11 +1590,22 @@ func (s *service) Realtime(ctx context.Context, request <-chan []string, respons
 			go s.repository.Subscribe(subctx, normalized, events)
 		case event := <-events:
 			if mapper == nil {
-				slog.WarnContext(ctx, "mapper is nil", slog.String("module", "timeline"))
+				logging.Warn(ctx, "mapper is nil", slog.String("module", "timeline"))
+				continue
+			}
+			if filter != nil && filter.MutedEvent(ctx, event) {
 				continue
 			}
 			event.Timeline = mapper[event.Timeline]
-			response <- event
+			// the reader of response may be gone already, e.g. a disconnected SSE client
+			select {
+			case response <- event:
+			case <-ctx.Done():
+				if cancel != nil {
+					cancel()
+				}
+				return
+			}
 		case <-ctx.Done():
 			if cancel != nil {
 				cancel()
@@ -1016,6 +1615,126 @@ func (s *service) Realtime(ctx context.Context, request <-chan []string, respons
 	}
 }
 
+// AllowsReader tells whether the requester may read an item posted to the timelines by their reader lists:
+// any of them is public, or lists the requester. an item in no timeline is not restricted
+func (s *service) AllowsReader(ctx context.Context, timelines []string, requester string) bool {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.AllowsReader")
+	defer span.End()
+
+	if len(timelines) == 0 {
+		return true
+	}
+
+	aliases, err := s.repository.GetAliases(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	gate := s.readerGate(ctx)
+	gate.requester = requester
+
+	for _, timeline := range timelines {
+		normalized, err := s.NormalizeTimelineID(ctx, timeline)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		normalized, _ = s.followAlias(aliases, normalized)
+		if gate.allows(ctx, normalized) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterReadable splits the timelines into the ones the requester may read and the ones denied by their reader list
+// or the timeline.read policy.
+// timelines which cannot be normalized are left to Realtime, which skips them
+func (s *service) FilterReadable(ctx context.Context, timelines []string) ([]string, []string) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.FilterReadable")
+	defer span.End()
+
+	var requester core.Entity
+	if requesterID, ok := ctx.Value(core.RequesterIdCtxKey).(string); ok && requesterID != "" {
+		entity, err := s.entity.Get(ctx, requesterID)
+		if err != nil {
+			span.RecordError(err)
+		}
+		requester = entity
+	}
+
+	aliases, err := s.repository.GetAliases(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	gate := s.readerGate(ctx)
+
+	allowed := make([]string, 0, len(timelines))
+	denied := make([]string, 0)
+	for _, timeline := range timelines {
+		normalized, err := s.NormalizeTimelineID(ctx, timeline)
+		if err != nil {
+			allowed = append(allowed, timeline)
+			continue
+		}
+		normalized, _ = s.followAlias(aliases, normalized)
+		if !gate.allows(ctx, normalized) {
+			denied = append(denied, timeline)
+			continue
+		}
+
+		var readable bool
+		tl, err := s.GetTimelineAutoDomain(ctx, normalized)
+		if err != nil {
+			span.RecordError(err)
+			readable = s.policy.Summerize([]core.PolicyEvalResult{core.PolicyEvalResultError}, "timeline.read", nil)
+		} else {
+			readable = s.readPolicyAllows(ctx, requester, tl)
+		}
+
+		if !readable {
+			logging.Info(
+				ctx, "denied to subscribe timeline",
+				slog.String("type", "audit"),
+				slog.String("principal", requester.ID),
+				slog.String("timeline", normalized),
+				slog.String("module", "timeline"),
+			)
+			denied = append(denied, timeline)
+			continue
+		}
+		allowed = append(allowed, timeline)
+	}
+
+	return allowed, denied
+}
+
+// readPolicyAllows evaluates the timeline.read action of the policy of the timeline for the requester
+func (s *service) readPolicyAllows(ctx context.Context, requester core.Entity, tl core.Timeline) bool {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.ReadPolicyAllows")
+	defer span.End()
+
+	var params map[string]any = make(map[string]any)
+	if tl.PolicyParams != nil {
+		json.Unmarshal([]byte(*tl.PolicyParams), &params)
+	}
+
+	result, err := s.policy.TestWithPolicyURL(
+		ctx,
+		tl.Policy,
+		core.RequestContext{
+			Self:      tl,
+			Requester: requester,
+			Params:    params,
+		},
+		"timeline.read",
+	)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return s.policy.Summerize([]core.PolicyEvalResult{result}, "timeline.read", nil)
+}
+
 func (s *service) GetOwners(ctx context.Context, timelines []string) ([]string, error) {
 	ctx, span := tracer.Start(ctx, "Timeline.Service.GetOwners")
 	defer span.End()
@@ -1062,20 +1781,17 @@ func (s *service) Query(ctx context.Context, timelineID, schema, owner, author s
 	ctx, span := tracer.Start(ctx, "Timeline.Service.Query")
 	defer span.End()
 
-	normalized, err := s.NormalizeTimelineID(ctx, timelineID)
+	id, readable, err := s.queryTarget(ctx, timelineID)
 	if err != nil {
 		span.RecordError(err)
 		return nil, err
 	}
 
-	split := strings.Split(normalized, "@")
-	host := split[len(split)-1]
-	if host != s.config.FQDN {
-		return nil, fmt.Errorf("Remote timeline is not supported")
+	// a private timeline looks empty to those who are not its readers
+	if !readable {
+		return []core.TimelineItem{}, nil
 	}
 
-	id := split[0]
-
 	items, err := s.repository.Query(ctx, id, schema, owner, author, since, limit)
 	if err != nil {
 		span.RecordError(err)
@@ -1085,6 +1801,49 @@ func (s *service) Query(ctx context.Context, timelineID, schema, owner, author s
 	return items, nil
 }
 
+// QueryStream calls fn with the items of Query one at a time as they are read, for exports too large to be held together
+func (s *service) QueryStream(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(core.TimelineItem) error) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.QueryStream")
+	defer span.End()
+
+	id, readable, err := s.queryTarget(ctx, timelineID)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if !readable {
+		return nil
+	}
+
+	err = s.repository.QueryEach(ctx, id, schema, owner, author, until, limit, fn)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// queryTarget resolves the timeline of a query to the id of a local timeline, and tells whether the requester may read it
+func (s *service) queryTarget(ctx context.Context, timelineID string) (string, bool, error) {
+	normalized, err := s.NormalizeTimelineID(ctx, timelineID)
+	if err != nil {
+		return "", false, err
+	}
+
+	aliases, err := s.repository.GetAliases(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	normalized, _ = s.followAlias(aliases, normalized)
+
+	split := strings.Split(normalized, "@")
+	host := split[len(split)-1]
+	if host != s.config.FQDN {
+		return "", false, fmt.Errorf("Remote timeline is not supported")
+	}
+
+	return split[0], s.readerGate(ctx).allows(ctx, normalized), nil
+}
+
 var (
 	lookupChunkItrsTotal              *prometheus.GaugeVec
 	loadChunkBodiesTotal              *prometheus.GaugeVec
@@ -1092,6 +1851,18 @@ var (
 	outerConnection                   *prometheus.GaugeVec
 )
 
+// GetMetrics returns the cache and connection counters of the timeline service
+func (s *service) GetMetrics() map[string]int64 {
+	metrics := s.repository.GetMetrics()
+	metrics["realtime_connections"] = atomic.LoadInt64(&s.socketCounter)
+	return metrics
+}
+
+// GetBridges returns the status of the connections relaying the events of remote timelines
+func (s *service) GetBridges() []core.BridgeStatus {
+	return s.repository.GetBridges()
+}
+
 func (s *service) UpdateMetrics() {
 
 	metrics := s.repository.GetMetrics()
@@ -1153,7 +1924,7 @@ func (s *service) ListLocalRecentlyRemovedItems(ctx context.Context, timelines [
 	for _, timeline := range timelines {
 		normalizedTimeline, err := s.NormalizeTimelineID(ctx, timeline)
 		if err != nil {
-			slog.WarnContext(
+			logging.Warn(
 				ctx,
 				fmt.Sprintf("failed to normalize timeline: %s", timeline),
 				slog.String("module", "timeline"),
@@ -1170,32 +1941,5 @@ func (s *service) ListRecentlyRemovedItems(ctx context.Context, timelines []stri
 	ctx, span := tracer.Start(ctx, "Timeline.Service.GetRecentlyRemovedItems")
 	defer span.End()
 
-	normalized := make([]string, 0)
-	normtable := make(map[string]string)
-	for _, timeline := range timelines {
-		normalizedTimeline, err := s.NormalizeTimelineID(ctx, timeline)
-		if err != nil {
-			slog.WarnContext(
-				ctx,
-				fmt.Sprintf("failed to normalize timeline: %s", timeline),
-				slog.String("module", "timeline"),
-			)
-			continue
-		}
-		normalized = append(normalized, normalizedTimeline)
-		normtable[normalizedTimeline] = timeline
-	}
-
-	retracted, err := s.repository.ListRecentlyRemovedItems(ctx, normalized)
-	if err != nil {
-		span.RecordError(err)
-		return nil, err
-	}
-
-	recovered := make(map[string][]string)
-	for k, v := range retracted {
-		recovered[normtable[k]] = v
-	}
-
-	return recovered, nil
+	return s.listPlannedRemovals(ctx, s.planTimelines(ctx, timelines))
 }
@@ -2,9 +2,11 @@ package timeline
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -23,6 +25,9 @@ func TestGetRecentItemsSimple(t *testing.T) {
 	prevEpoch := "5400"
 
 	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetTimelineFromRemote(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Timeline{}, nil).AnyTimes()
 	mockRepo.EXPECT().
 		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
 		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
@@ -80,6 +85,7 @@ func TestGetRecentItemsSimple(t *testing.T) {
 	mockDomain := mock_core.NewMockDomainService(ctrl)
 	mockSemantic := mock_core.NewMockSemanticIDService(ctrl)
 	mockSubscription := mock_core.NewMockSubscriptionService(ctrl)
+	mockMute := mock_core.NewMockMuteService(ctrl)
 	mockPolicy := mock_core.NewMockPolicyService(ctrl)
 
 	service := NewService(
@@ -88,6 +94,7 @@ func TestGetRecentItemsSimple(t *testing.T) {
 		mockDomain,
 		mockSemantic,
 		mockSubscription,
+		mockMute,
 		mockPolicy,
 		core.Config{
 			FQDN: "local.example.com",
@@ -111,6 +118,9 @@ func TestGetRecentItemsLoadMore(t *testing.T) {
 	prevEpoch := "5400"
 
 	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetTimelineFromRemote(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Timeline{}, nil).AnyTimes()
 	mockRepo.EXPECT().
 		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
 		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
@@ -166,6 +176,7 @@ func TestGetRecentItemsLoadMore(t *testing.T) {
 	mockDomain := mock_core.NewMockDomainService(ctrl)
 	mockSemantic := mock_core.NewMockSemanticIDService(ctrl)
 	mockSubscription := mock_core.NewMockSubscriptionService(ctrl)
+	mockMute := mock_core.NewMockMuteService(ctrl)
 	mockPolicy := mock_core.NewMockPolicyService(ctrl)
 
 	service := NewService(
@@ -174,6 +185,7 @@ func TestGetRecentItemsLoadMore(t *testing.T) {
 		mockDomain,
 		mockSemantic,
 		mockSubscription,
+		mockMute,
 		mockPolicy,
 		core.Config{
 			FQDN: "local.example.com",
@@ -210,6 +222,9 @@ func TestGetRecentItemsWide(t *testing.T) {
 	pivotTime := core.EpochTime("6300")
 
 	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetTimelineFromRemote(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Timeline{}, nil).AnyTimes()
 	mockRepo.EXPECT().
 		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
 		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
@@ -393,6 +408,7 @@ func TestGetRecentItemsWide(t *testing.T) {
 	mockDomain := mock_core.NewMockDomainService(ctrl)
 	mockSemantic := mock_core.NewMockSemanticIDService(ctrl)
 	mockSubscription := mock_core.NewMockSubscriptionService(ctrl)
+	mockMute := mock_core.NewMockMuteService(ctrl)
 	mockPolicy := mock_core.NewMockPolicyService(ctrl)
 
 	service := NewService(
@@ -401,6 +417,7 @@ func TestGetRecentItemsWide(t *testing.T) {
 		mockDomain,
 		mockSemantic,
 		mockSubscription,
+		mockMute,
 		mockPolicy,
 		core.Config{
 			FQDN: "local.example.com",
@@ -447,3 +464,517 @@ func TestGetRecentItemsWide(t *testing.T) {
 		assert.Equal(t, expected[i], item.ResourceID)
 	}
 }
+
+func TestPlanTimelines(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetTimelineFromRemote(gomock.Any(), gomock.Any(), gomock.Any()).Return(core.Timeline{}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
+		Return("t00000000000000000000000000@local.example.com", nil)
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "test@con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2").
+		Return("t11111111111111111111111111@local.example.com", nil)
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com").
+		Return("taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com", nil)
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "broken@con1jmcread5dear85emug5gh3wvaf6st9av0kuxaj").
+		Return("", errors.New("not found"))
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), "con1jmcread5dear85emug5gh3wvaf6st9av0kuxaj").Return(core.Entity{}, errors.New("not found"))
+
+	s := &service{
+		repository: mockRepo,
+		entity:     mockEntity,
+		config:     core.Config{FQDN: "local.example.com"},
+	}
+
+	plan := s.planTimelines(context.Background(), []string{
+		"t00000000000000000000000000",
+		"test@con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2",
+		"broken@con1jmcread5dear85emug5gh3wvaf6st9av0kuxaj",
+		"taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com",
+	})
+
+	// the order of the request is kept and the broken timeline is left out
+	assert.Equal(t, []string{
+		"t00000000000000000000000000@local.example.com",
+		"t11111111111111111111111111@local.example.com",
+		"taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com",
+	}, plan.normalized)
+	assert.Equal(t, "test@con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2", plan.normtable["t11111111111111111111111111@local.example.com"])
+
+	only := plan.only("test@con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2")
+	assert.Equal(t, []string{"t11111111111111111111111111@local.example.com"}, only.normalized)
+	assert.Empty(t, plan.only("broken@con1jmcread5dear85emug5gh3wvaf6st9av0kuxaj").normalized)
+}
+
+func TestPlanTimelinesMerged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
+		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "t11111111111111111111111111").
+		Return("t11111111111111111111111111@local.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetAliases(gomock.Any()).
+		Return(map[string]string{"00000000000000000000000000": "11111111111111111111111111"}, nil).AnyTimes()
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+
+	s := &service{
+		repository: mockRepo,
+		config:     core.Config{FQDN: "local.example.com"},
+	}
+
+	// the merged timeline is read from the one it was merged into, once
+	plan := s.planTimelines(context.Background(), []string{"t00000000000000000000000000", "t11111111111111111111111111"})
+	assert.Equal(t, []string{"t11111111111111111111111111@local.example.com"}, plan.normalized)
+	assert.Equal(t, "t11111111111111111111111111@local.example.com", plan.ids["t00000000000000000000000000"])
+	assert.Equal(t, "t11111111111111111111111111", plan.normtable["t11111111111111111111111111@local.example.com"])
+
+	redirects, err := s.ResolveAliases(context.Background(), []string{"t00000000000000000000000000", "t11111111111111111111111111"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"t00000000000000000000000000": "t11111111111111111111111111@local.example.com"}, redirects)
+}
+
+func TestPlanTimelinesPrivate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
+		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com").
+		Return("taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetReaders(gomock.Any()).
+		Return(map[string][]string{"00000000000000000000000000": {"con1owner", "con1owner", "con1reader"}}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetTimelineFromRemote(gomock.Any(), "remote.example.com", "taaaaaaaaaaaaaaaaaaaaaaaaaa").
+		Return(core.Timeline{Owner: "con1remote", Author: "con1remote", Readers: []string{"con1reader"}}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "tbbbbbbbbbbbbbbbbbbbbbbbbbb@down.example.com").
+		Return("tbbbbbbbbbbbbbbbbbbbbbbbbbb@down.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetTimelineFromRemote(gomock.Any(), "down.example.com", "tbbbbbbbbbbbbbbbbbbbbbbbbbb").
+		Return(core.Timeline{}, fmt.Errorf("connection refused")).AnyTimes()
+
+	s := &service{
+		repository: mockRepo,
+		config:     core.Config{FQDN: "local.example.com"},
+	}
+
+	timelines := []string{"t00000000000000000000000000", "taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com"}
+
+	anonymous := s.planTimelines(context.Background(), timelines)
+	assert.Empty(t, anonymous.normalized)
+
+	other := s.planTimelines(context.WithValue(context.Background(), core.RequesterIdCtxKey, "con1other"), timelines)
+	assert.Empty(t, other.normalized)
+
+	reader := s.planTimelines(context.WithValue(context.Background(), core.RequesterIdCtxKey, "con1reader"), timelines)
+	assert.Equal(t, []string{
+		"t00000000000000000000000000@local.example.com",
+		"taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com",
+	}, reader.normalized)
+
+	owner := s.planTimelines(context.WithValue(context.Background(), core.RequesterIdCtxKey, "con1owner"), timelines)
+	assert.Equal(t, []string{"t00000000000000000000000000@local.example.com"}, owner.normalized)
+
+	// the reader list of a timeline on an unreachable domain is unknown, so it is not served to anyone
+	unreachable := s.planTimelines(context.WithValue(context.Background(), core.RequesterIdCtxKey, "con1reader"), []string{"tbbbbbbbbbbbbbbbbbbbbbbbbbb@down.example.com"})
+	assert.Empty(t, unreachable.normalized)
+}
+
+func TestCheckVersion(t *testing.T) {
+	signedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := `{"signer":"con1mu9xruulec4y6hd0d369sdf325l94z4770m33d","type":"timeline","signedAt":"2024-01-01T00:00:00Z"}`
+
+	assert.NoError(t, checkVersion(stored, 3, signedAt.Add(time.Minute), 0))
+	assert.NoError(t, checkVersion(stored, 3, signedAt.Add(time.Minute), 3))
+
+	// made over an older version
+	err := checkVersion(stored, 3, signedAt.Add(time.Minute), 2)
+	assert.ErrorIs(t, err, core.ErrorConflict{})
+	assert.Equal(t, int64(3), err.(core.ErrorConflict).Current)
+
+	// signed before the stored document
+	assert.ErrorIs(t, checkVersion(stored, 3, signedAt.Add(-time.Minute), 0), core.ErrorConflict{})
+}
+
+func TestUpsertTimelineOwnershipLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const signer = "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+	document := `{"signer":"` + signer + `","type":"timeline","schema":"https://example.com/timeline.json","signedAt":"2024-01-01T00:00:00Z"}`
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetTimeline(gomock.Any(), gomock.Any()).Return(core.Timeline{}, core.NewErrorNotFound()).AnyTimes()
+	mockRepo.EXPECT().CountTimelineByAuthorOwned(gomock.Any(), signer).Return(int64(8), nil).AnyTimes()
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockPolicy.EXPECT().TestWithPolicyURL(gomock.Any(), "", gomock.Any(), "timeline.create").Return(core.PolicyEvalResultDefault, nil).AnyTimes()
+	mockPolicy.EXPECT().Summerize(gomock.Any(), "timeline.create", gomock.Any()).Return(true).AnyTimes()
+
+	s := NewService(
+		mockRepo,
+		mockEntity,
+		mock_core.NewMockDomainService(ctrl),
+		mock_core.NewMockSemanticIDService(ctrl),
+		mock_core.NewMockSubscriptionService(ctrl),
+		mock_core.NewMockMuteService(ctrl),
+		mockPolicy,
+		core.Config{
+			FQDN: "local.example.com",
+			OwnershipLimits: core.OwnershipLimits{
+				core.DefaultOwnershipLimit: {Timelines: 8},
+				"creator":                  {Timelines: 0},
+			},
+		},
+	)
+
+	mockEntity.EXPECT().Get(gomock.Any(), signer).Return(core.Entity{ID: signer}, nil)
+	_, err := s.UpsertTimeline(context.Background(), core.CommitModeExecute, document, "", "")
+	assert.ErrorIs(t, err, core.ErrorLimitExceeded{})
+	assert.Equal(t, core.NewErrorLimitExceeded("timelines", 8, 8), err)
+
+	// the tag given by the admin lifts the limit
+	mockEntity.EXPECT().Get(gomock.Any(), signer).Return(core.Entity{ID: signer, Tag: "creator"}, nil)
+	mockRepo.EXPECT().UpsertTimeline(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, tl core.Timeline) (core.Timeline, error) {
+		return tl, nil
+	})
+	created, err := s.UpsertTimeline(context.Background(), core.CommitModeExecute, document, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, signer, created.Owner)
+}
+
+func TestLookupChunkItrReadPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000").
+		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetNormalizationCache(gomock.Any(), "t00000000000000000000000000@local.example.com").
+		Return("t00000000000000000000000000@local.example.com", nil).AnyTimes()
+	mockRepo.EXPECT().
+		GetTimeline(gomock.Any(), "t00000000000000000000000000").
+		Return(core.Timeline{ID: "00000000000000000000000000", Policy: "https://policy.example.com/followers.json"}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		LookupChunkItrs(gomock.Any(), []string{"t00000000000000000000000000@local.example.com"}, "100").
+		Return(map[string]string{"t00000000000000000000000000@local.example.com": "timeline:itr:all:t00000000000000000000000000@local.example.com:100"}, nil).AnyTimes()
+	mockRepo.EXPECT().
+		LookupChunkItrs(gomock.Any(), []string{}, "100").
+		Return(map[string]string{}, nil).AnyTimes()
+
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	mockEntity.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (core.Entity, error) {
+		return core.Entity{ID: id, Domain: "remote.example.com"}, nil
+	}).AnyTimes()
+
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockPolicy.EXPECT().
+		TestWithPolicyURL(gomock.Any(), "https://policy.example.com/followers.json", gomock.Any(), "timeline.read").
+		DoAndReturn(func(_ context.Context, _ string, rctx core.RequestContext, _ string) (core.PolicyEvalResult, error) {
+			if rctx.Requester.ID == "con1reader" {
+				return core.PolicyEvalResultAllow, nil
+			}
+			return core.PolicyEvalResultDeny, nil
+		}).AnyTimes()
+	mockPolicy.EXPECT().Summerize(gomock.Any(), "timeline.read", gomock.Any()).DoAndReturn(func(results []core.PolicyEvalResult, _ string, _ *map[string]bool) bool {
+		return results[0] == core.PolicyEvalResultAllow
+	}).AnyTimes()
+
+	s := &service{
+		repository: mockRepo,
+		entity:     mockEntity,
+		policy:     mockPolicy,
+		config:     core.Config{FQDN: "local.example.com"},
+	}
+
+	lookup := func(ctx context.Context) map[string]string {
+		table, err := s.LookupChunkItr(ctx, []string{"t00000000000000000000000000"}, "100")
+		assert.NoError(t, err)
+		return table
+	}
+
+	assert.Empty(t, lookup(context.Background()))
+
+	// the entity another domain vouches for in its domain passport
+	vouched := context.WithValue(context.Background(), core.RequesterVouchedIdCtxKey, "con1reader")
+	assert.Equal(t, map[string]string{"t00000000000000000000000000": "100"}, lookup(vouched))
+
+	other := context.WithValue(context.Background(), core.RequesterVouchedIdCtxKey, "con1other")
+	assert.Empty(t, lookup(other))
+}
+
+func TestBackfill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const remote = "taaaaaaaaaaaaaaaaaaaaaaaaaa@remote.example.com"
+	now := core.Time2Chunk(time.Now())
+	older := core.PrevChunk(core.PrevChunk(now))
+	oldest := core.PrevChunk(core.PrevChunk(older))
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetNormalizationCache(gomock.Any(), remote).Return(remote, nil).AnyTimes()
+	gomock.InOrder(
+		mockRepo.EXPECT().LookupChunkItrs(gomock.Any(), []string{remote}, now).Return(map[string]string{remote: now}, nil),
+		mockRepo.EXPECT().LoadChunkBodies(gomock.Any(), map[string]string{remote: now}).Return(map[string]core.Chunk{
+			remote: {Epoch: now, Items: []core.TimelineItem{{ResourceID: "m1"}}},
+		}, nil),
+		// empty chunks are skipped by the iterator
+		mockRepo.EXPECT().LookupChunkItrs(gomock.Any(), []string{remote}, core.PrevChunk(now)).Return(map[string]string{remote: older}, nil),
+		mockRepo.EXPECT().LoadChunkBodies(gomock.Any(), map[string]string{remote: older}).Return(map[string]core.Chunk{
+			remote: {Epoch: older, Items: []core.TimelineItem{{ResourceID: "m2"}}},
+		}, nil),
+		mockRepo.EXPECT().LookupChunkItrs(gomock.Any(), []string{remote}, core.PrevChunk(older)).Return(map[string]string{remote: oldest}, nil),
+		mockRepo.EXPECT().LoadChunkBodies(gomock.Any(), map[string]string{remote: oldest}).Return(map[string]core.Chunk{
+			remote: {Epoch: oldest},
+		}, nil),
+	)
+
+	s := &service{
+		repository: mockRepo,
+		config:     core.Config{FQDN: "local.example.com"},
+	}
+
+	loaded, err := s.Backfill(context.Background(), remote, 5)
+	assert.NoError(t, err)
+	// the walk stops at the first empty chunk
+	assert.Equal(t, 2, loaded)
+
+	mockRepo.EXPECT().LookupChunkItrs(gomock.Any(), []string{remote}, now).Return(map[string]string{remote: now}, nil)
+	mockRepo.EXPECT().LoadChunkBodies(gomock.Any(), map[string]string{remote: now}).Return(map[string]core.Chunk{
+		remote: {Epoch: now, Items: []core.TimelineItem{{ResourceID: "m1"}}},
+	}, nil)
+
+	loaded, err = s.Backfill(context.Background(), remote, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+}
+
+func TestWarmChunkCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const (
+		popular = "taaaaaaaaaaaaaaaaaaaaaaaaaa@local.example.com"
+		second  = "tbbbbbbbbbbbbbbbbbbbbbbbbbb@local.example.com"
+		quiet   = "tcccccccccccccccccccccccccc@local.example.com"
+	)
+	epoch := "6000"
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().ListTimelineSubscriptions(gomock.Any()).Return(map[string]int64{
+		quiet:                      1,
+		popular:                    10,
+		second:                     5,
+		SubscriptionUpdatedChannel: 20,
+	}, nil).Times(2)
+	// the most subscribed timelines are warmed, the event bus channel is not a timeline
+	mockRepo.EXPECT().LookupChunkItrs(gomock.Any(), []string{popular, second}, epoch).Return(map[string]string{popular: epoch, second: epoch}, nil)
+	mockRepo.EXPECT().LoadChunkBodies(gomock.Any(), map[string]string{popular: epoch, second: epoch}).Return(map[string]core.Chunk{}, nil)
+
+	s := &service{repository: mockRepo, config: core.Config{FQDN: "local.example.com"}}
+
+	warmed, err := s.WarmChunkCache(context.Background(), epoch, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{popular, second}, warmed)
+
+	// nothing is loaded when no timeline is warmed
+	warmed, err = s.WarmChunkCache(context.Background(), epoch, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, warmed)
+}
+
+func TestAllowsReader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil).AnyTimes()
+	mockRepo.EXPECT().GetReaders(gomock.Any()).Return(map[string][]string{
+		"00000000000000000000000001": {reader},
+	}, nil).AnyTimes()
+	mockRepo.EXPECT().GetNormalizationCache(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (string, error) {
+		return id, nil
+	}).AnyTimes()
+
+	service := NewService(
+		mockRepo,
+		mock_core.NewMockEntityService(ctrl),
+		mock_core.NewMockDomainService(ctrl),
+		mock_core.NewMockSemanticIDService(ctrl),
+		mock_core.NewMockSubscriptionService(ctrl),
+		mock_core.NewMockMuteService(ctrl),
+		mock_core.NewMockPolicyService(ctrl),
+		core.Config{
+			FQDN: "local.example.com",
+		},
+	)
+
+	ctx := context.Background()
+	private := "t00000000000000000000000001@local.example.com"
+	public := "t00000000000000000000000002@local.example.com"
+
+	assert.True(t, service.AllowsReader(ctx, nil, ""))
+	assert.True(t, service.AllowsReader(ctx, []string{private}, reader))
+	assert.False(t, service.AllowsReader(ctx, []string{private}, ""))
+	assert.False(t, service.AllowsReader(ctx, []string{private}, "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"))
+	assert.True(t, service.AllowsReader(ctx, []string{private, public}, ""))
+}
+
+func TestEnsureFirehoseAfterRotation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previousKey, err := core.GeneratePrivateKey()
+	assert.NoError(t, err)
+	previousCSID, err := core.PrivKeyToAddr(previousKey, "ccs")
+	assert.NoError(t, err)
+	currentKey, err := core.GeneratePrivateKey()
+	assert.NoError(t, err)
+	currentCSID, err := core.PrivKeyToAddr(currentKey, "ccs")
+	assert.NoError(t, err)
+
+	// the firehose created before the rotation
+	existing := core.Timeline{
+		ID:        "t00000000000000000000000001",
+		Owner:     previousCSID,
+		Author:    previousCSID,
+		Document:  `{"signer":"` + previousCSID + `","owner":"` + previousCSID + `","type":"timeline","semanticID":"` + core.FirehoseTimelineSemanticID + `","domainOwned":true}`,
+		Signature: "00",
+		Version:   1,
+	}
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetTimeline(gomock.Any(), existing.ID).Return(existing, nil)
+	mockRepo.EXPECT().UpdateTimeline(gomock.Any(), gomock.Any(), int64(1)).DoAndReturn(func(_ context.Context, timeline core.Timeline, version int64) (core.Timeline, error) {
+		timeline.Version = version + 1
+		return timeline, nil
+	})
+
+	mockSemantic := mock_core.NewMockSemanticIDService(ctrl)
+	mockSemantic.EXPECT().Lookup(gomock.Any(), core.FirehoseTimelineSemanticID, currentCSID).Return("", core.NewErrorNotFound())
+	mockSemantic.EXPECT().Lookup(gomock.Any(), core.FirehoseTimelineSemanticID, previousCSID).Return(existing.ID, nil)
+	mockSemantic.EXPECT().Name(gomock.Any(), core.FirehoseTimelineSemanticID, currentCSID, existing.ID, gomock.Any(), gomock.Any()).Return(core.SemanticID{}, nil)
+	mockSemantic.EXPECT().Delete(gomock.Any(), core.FirehoseTimelineSemanticID, previousCSID).Return(nil)
+
+	service := NewService(
+		mockRepo,
+		mock_core.NewMockEntityService(ctrl),
+		mock_core.NewMockDomainService(ctrl),
+		mockSemantic,
+		mock_core.NewMockSubscriptionService(ctrl),
+		mock_core.NewMockMuteService(ctrl),
+		mock_core.NewMockPolicyService(ctrl),
+		core.Config{
+			FQDN:         "local.example.com",
+			CSID:         currentCSID,
+			PrivateKey:   currentKey,
+			PreviousCSID: previousCSID,
+		},
+	)
+
+	firehose, err := service.EnsureFirehose(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, existing.ID, firehose.ID)
+	assert.Equal(t, currentCSID, firehose.Owner)
+	assert.Equal(t, currentCSID, firehose.Author)
+
+	signature, err := hex.DecodeString(firehose.Signature)
+	assert.NoError(t, err)
+	assert.NoError(t, core.VerifySignature([]byte(firehose.Document), signature, currentCSID))
+}
+
+func TestMergeIntoTimelineOfAnother(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requester := "con1t0tey8uxhkqkd4wcp4hd4jedt7f0vfhk29xdd2"
+	other := "con1mu9xruulec4y6hd0d369sdf325l94z4770m33d"
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetNormalizationCache(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (string, error) {
+		return id, nil
+	}).AnyTimes()
+	mockRepo.EXPECT().GetTimeline(gomock.Any(), "t00000000000000000000000001").Return(core.Timeline{ID: "t00000000000000000000000001", Owner: requester}, nil)
+	mockRepo.EXPECT().GetTimeline(gomock.Any(), "t00000000000000000000000002").Return(core.Timeline{ID: "t00000000000000000000000002", Owner: other}, nil)
+
+	service := NewService(
+		mockRepo,
+		mock_core.NewMockEntityService(ctrl),
+		mock_core.NewMockDomainService(ctrl),
+		mock_core.NewMockSemanticIDService(ctrl),
+		mock_core.NewMockSubscriptionService(ctrl),
+		mock_core.NewMockMuteService(ctrl),
+		mock_core.NewMockPolicyService(ctrl),
+		core.Config{
+			FQDN: "local.example.com",
+		},
+	)
+
+	// nothing is merged nor repointed
+	_, err := service.Merge(context.Background(), "t00000000000000000000000001@local.example.com", "t00000000000000000000000002@local.example.com", requester)
+	assert.ErrorIs(t, err, core.NewErrorPermissionDenied())
+}
+
+func TestRealtimeReturnsWithoutReader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	timeline := "t00000000000000000000000001@local.example.com"
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetAliases(gomock.Any()).Return(map[string]string{}, nil)
+	mockRepo.EXPECT().GetNormalizationCache(gomock.Any(), timeline).Return(timeline, nil)
+	subscribed := make(chan struct{})
+	mockRepo.EXPECT().Subscribe(gomock.Any(), []string{timeline}, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ []string, events chan<- core.Event) error {
+			events <- core.Event{Timeline: timeline}
+			close(subscribed)
+			return nil
+		})
+
+	s := &service{repository: mockRepo, config: core.Config{FQDN: "local.example.com"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request := make(chan []string, 1)
+	done := make(chan struct{})
+	go func() {
+		// nobody reads the response, like a client which went away
+		s.Realtime(ctx, request, make(chan core.Event))
+		close(done)
+	}()
+
+	request <- []string{timeline}
+	<-subscribed
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Realtime kept blocking on the response after the context was canceled")
+	}
+}
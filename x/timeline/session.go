@@ -0,0 +1,346 @@
+package timeline
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+const (
+	defaultResumeTTL  = 2 * time.Minute
+	defaultReplaySize = 1024
+)
+
+// ErrSessionTakenOver is returned when another connection resumed the session
+var ErrSessionTakenOver = errors.New("realtime session was resumed by another connection")
+
+// SessionStore keeps the events sent on protocol v2 connections, so that a client reconnecting
+// after a network blip resumes where it stopped instead of refetching the timelines
+type SessionStore interface {
+	// Open starts a session owned by the connection conn
+	Open(ctx context.Context, token, conn string) error
+	// Resume hands the session over to the connection conn. ok is false if the session expired
+	Resume(ctx context.Context, token, conn string) (channels []string, last int64, ok bool, err error)
+	// Append records an event sent to the client. the event must carry its sequence number
+	Append(ctx context.Context, token, conn string, event core.Event) error
+	// Replay returns the recorded events after the sequence number
+	Replay(ctx context.Context, token string, after int64) ([]core.Event, error)
+	// Ack discards the events the client received, up to the sequence number
+	Ack(ctx context.Context, token string, seq int64) error
+	// SetChannels records the timelines the session listens to
+	SetChannels(ctx context.Context, token string, channels []string) error
+}
+
+type sessionStore struct {
+	rdb  *redis.Client
+	ttl  time.Duration
+	size int64
+}
+
+// NewSessionStore creates a session store on redis. sessions expire ttl after their last event,
+// and keep at most size events not acknowledged yet
+func NewSessionStore(rdb *redis.Client, ttl time.Duration, size int64) SessionStore {
+	if ttl <= 0 {
+		ttl = defaultResumeTTL
+	}
+	if size <= 0 {
+		size = defaultReplaySize
+	}
+	return &sessionStore{rdb, ttl, size}
+}
+
+func streamKey(token string) string {
+	return keyspace.Realtime.Key("session", token)
+}
+
+func metaKey(token string) string {
+	return keyspace.Realtime.Key("session", token, "meta")
+}
+
+func (s *sessionStore) Open(ctx context.Context, token, conn string) error {
+	ctx, span := tracer.Start(ctx, "Timeline.SessionStore.Open")
+	defer span.End()
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, metaKey(token), "conn", conn, "seq", 0)
+	pipe.Expire(ctx, metaKey(token), s.ttl)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (s *sessionStore) Resume(ctx context.Context, token, conn string) ([]string, int64, bool, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.SessionStore.Resume")
+	defer span.End()
+
+	meta, err := s.rdb.HGetAll(ctx, metaKey(token)).Result()
+	if err != nil {
+		span.RecordError(err)
+		return nil, 0, false, err
+	}
+	if len(meta) == 0 {
+		return nil, 0, false, nil
+	}
+
+	var channels []string
+	if meta["channels"] != "" {
+		err = json.Unmarshal([]byte(meta["channels"]), &channels)
+		if err != nil {
+			span.RecordError(err)
+			return nil, 0, false, err
+		}
+	}
+	last, _ := strconv.ParseInt(meta["seq"], 10, 64)
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, metaKey(token), "conn", conn)
+	pipe.Expire(ctx, metaKey(token), s.ttl)
+	pipe.Expire(ctx, streamKey(token), s.ttl)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, 0, false, err
+	}
+
+	return channels, last, true, nil
+}
+
+// the connection which lost the session to a resume must not write to it anymore
+var appendScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], 'conn') ~= ARGV[1] then
+	return 0
+end
+redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[4], ARGV[2] .. '-0', 'event', ARGV[3])
+redis.call('HSET', KEYS[2], 'seq', ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+redis.call('EXPIRE', KEYS[2], ARGV[5])
+return 1
+`)
+
+func (s *sessionStore) Append(ctx context.Context, token, conn string, event core.Event) error {
+	ctx, span := tracer.Start(ctx, "Timeline.SessionStore.Append")
+	defer span.End()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	appended, err := appendScript.Run(
+		ctx, s.rdb,
+		[]string{streamKey(token), metaKey(token)},
+		conn, event.Seq, data, s.size, int64(s.ttl.Seconds()),
+	).Int()
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if appended == 0 {
+		return ErrSessionTakenOver
+	}
+	return nil
+}
+
+func (s *sessionStore) Replay(ctx context.Context, token string, after int64) ([]core.Event, error) {
+	ctx, span := tracer.Start(ctx, "Timeline.SessionStore.Replay")
+	defer span.End()
+
+	messages, err := s.rdb.XRange(ctx, streamKey(token), strconv.FormatInt(after+1, 10), "+").Result()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	events := make([]core.Event, 0, len(messages))
+	for _, message := range messages {
+		data, ok := message.Values["event"].(string)
+		if !ok {
+			continue
+		}
+		var event core.Event
+		err := json.Unmarshal([]byte(data), &event)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *sessionStore) Ack(ctx context.Context, token string, seq int64) error {
+	ctx, span := tracer.Start(ctx, "Timeline.SessionStore.Ack")
+	defer span.End()
+
+	err := s.rdb.XTrimMinID(ctx, streamKey(token), strconv.FormatInt(seq+1, 10)).Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (s *sessionStore) SetChannels(ctx context.Context, token string, channels []string) error {
+	ctx, span := tracer.Start(ctx, "Timeline.SessionStore.SetChannels")
+	defer span.End()
+
+	data, err := json.Marshal(channels)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, metaKey(token), "channels", data)
+	pipe.Expire(ctx, metaKey(token), s.ttl)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func newSessionToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// session is the protocol v2 state of a connection. it is empty until the client says hello or resumes
+type session struct {
+	mu    sync.Mutex
+	store SessionStore
+	conn  string
+	token string
+	seq   int64
+}
+
+func newSession(store SessionStore) *session {
+	return &session{store: store, conn: newSessionToken()}
+}
+
+// stamp numbers the event and records it for replay. v1 connections pass the events through as is
+func (s *session) stamp(ctx context.Context, event core.Event) (core.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" {
+		return event, nil
+	}
+	s.seq++
+	event.Seq = s.seq
+	return event, s.store.Append(ctx, s.token, s.conn, event)
+}
+
+// SessionFrame tells a protocol v2 client the session of the connection.
+// Seq is the last sequence number sent on it. a client whose resume was refused
+// gets a new session with Resumed false and should refetch the timelines
+type SessionFrame struct {
+	Type    string `json:"type"` // always "session"
+	Token   string `json:"token"`
+	Seq     int64  `json:"seq"`
+	Resumed bool   `json:"resumed"`
+}
+
+// open starts a new session on the connection
+func (s *session) open(ctx context.Context) (SessionFrame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.openLocked(ctx)
+}
+
+func (s *session) openLocked(ctx context.Context) (SessionFrame, error) {
+	token := newSessionToken()
+	err := s.store.Open(ctx, token, s.conn)
+	if err != nil {
+		return SessionFrame{}, err
+	}
+	s.token = token
+	s.seq = 0
+	return SessionFrame{Type: "session", Token: token}, nil
+}
+
+// resume takes the session over and writes the events the client missed after the sequence number.
+// events are not stamped until the replay is written, so they keep their order.
+// it returns the timelines to listen to again; if the session cannot be resumed a new one is opened
+func (s *session) resume(ctx context.Context, token string, after int64, write func(any) error) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels, last, ok, err := s.store.Resume(ctx, token, s.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []core.Event
+	if ok && after <= last {
+		events, err = s.store.Replay(ctx, token, after)
+		if err != nil {
+			return nil, err
+		}
+		// the events right after the client's are trimmed when it fell too far behind
+		if after < last && (len(events) == 0 || events[0].Seq != after+1) {
+			ok = false
+		}
+	} else {
+		ok = false
+	}
+
+	if !ok {
+		frame, err := s.openLocked(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return nil, write(frame)
+	}
+
+	s.token = token
+	s.seq = last
+	err = write(SessionFrame{Type: "session", Token: token, Seq: last, Resumed: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		err = write(event)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return channels, nil
+}
+
+// listen records the timelines of the session, so that a resume listens to them again
+func (s *session) listen(ctx context.Context, channels []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" {
+		return nil
+	}
+	return s.store.SetChannels(ctx, s.token, channels)
+}
+
+// ack discards the events the client acknowledged
+func (s *session) ack(ctx context.Context, seq int64) error {
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+
+	if token == "" {
+		return nil
+	}
+	return s.store.Ack(ctx, token, seq)
+}
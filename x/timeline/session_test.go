@@ -0,0 +1,62 @@
+package timeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/testutil"
+)
+
+func TestSessionStore(t *testing.T) {
+	rdb, cleanup := testutil.CreateRDB()
+	defer cleanup()
+
+	ctx := context.Background()
+	store := NewSessionStore(rdb, 0, 0)
+
+	assert.NoError(t, store.Open(ctx, "token", "conn1"))
+	assert.NoError(t, store.SetChannels(ctx, "token", []string{"tl1"}))
+
+	first := newSession(store)
+	first.conn = "conn1"
+	first.token = "token"
+	for _, tl := range []string{"a", "b", "c"} {
+		_, err := first.stamp(ctx, core.Event{Timeline: tl})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, first.ack(ctx, 1))
+
+	var written []any
+	write := func(v any) error {
+		written = append(written, v)
+		return nil
+	}
+
+	second := newSession(store)
+	channels, err := second.resume(ctx, "token", 1, write)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tl1"}, channels)
+	if assert.Len(t, written, 3) {
+		assert.Equal(t, SessionFrame{Type: "session", Token: "token", Seq: 3, Resumed: true}, written[0])
+		assert.Equal(t, int64(2), written[1].(core.Event).Seq)
+		assert.Equal(t, "c", written[2].(core.Event).Timeline)
+	}
+
+	// the first connection lost the session
+	_, err = first.stamp(ctx, core.Event{Timeline: "d"})
+	assert.ErrorIs(t, err, ErrSessionTakenOver)
+
+	// acked events cannot be replayed, the client gets a new session
+	written = nil
+	third := newSession(store)
+	channels, err = third.resume(ctx, "token", 0, write)
+	assert.NoError(t, err)
+	assert.Nil(t, channels)
+	if assert.Len(t, written, 1) {
+		frame := written[0].(SessionFrame)
+		assert.False(t, frame.Resumed)
+		assert.NotEqual(t, "token", frame.Token)
+	}
+}
@@ -0,0 +1,309 @@
+package timeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/shadow"
+)
+
+// shadowRepository serves every call from primary and replays reads (and optionally writes)
+// against candidate in background, so a new repository implementation can be verified
+// with production traffic without affecting responses.
+type shadowRepository struct {
+	primary   Repository
+	candidate Repository
+	runner    *shadow.Runner
+}
+
+// NewShadowRepository wraps primary with a candidate implementation running in shadow mode
+func NewShadowRepository(primary, candidate Repository, config shadow.Config) Repository {
+	if config.Target == "" {
+		config.Target = "timeline"
+	}
+	return &shadowRepository{
+		primary,
+		candidate,
+		shadow.NewRunner(config),
+	}
+}
+
+func (r *shadowRepository) GetTimeline(ctx context.Context, key string) (core.Timeline, error) {
+	result, err := r.primary.GetTimeline(ctx, key)
+	shadow.Compare(ctx, r.runner, "GetTimeline", result, err, func(ctx context.Context) (core.Timeline, error) {
+		return r.candidate.GetTimeline(ctx, key)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) GetTimelineFromRemote(ctx context.Context, host string, key string) (core.Timeline, error) {
+	return r.primary.GetTimelineFromRemote(ctx, host, key)
+}
+
+func (r *shadowRepository) UpsertTimeline(ctx context.Context, timeline core.Timeline) (core.Timeline, error) {
+	result, err := r.primary.UpsertTimeline(ctx, timeline)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "UpsertTimeline", func(ctx context.Context) error {
+			_, err := r.candidate.UpsertTimeline(ctx, timeline)
+			return err
+		})
+	}
+	return result, err
+}
+
+func (r *shadowRepository) UpdateTimeline(ctx context.Context, timeline core.Timeline, version int64) (core.Timeline, error) {
+	result, err := r.primary.UpdateTimeline(ctx, timeline, version)
+	if err == nil {
+		// the candidate follows the primary, which may be at another version than the candidate
+		shadow.Mirror(ctx, r.runner, "UpdateTimeline", func(ctx context.Context) error {
+			_, err := r.candidate.UpsertTimeline(ctx, result)
+			return err
+		})
+	}
+	return result, err
+}
+
+func (r *shadowRepository) DeleteTimeline(ctx context.Context, key string) error {
+	err := r.primary.DeleteTimeline(ctx, key)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "DeleteTimeline", func(ctx context.Context) error {
+			return r.candidate.DeleteTimeline(ctx, key)
+		})
+	}
+	return err
+}
+
+func (r *shadowRepository) Merge(ctx context.Context, timeline, into string) error {
+	err := r.primary.Merge(ctx, timeline, into)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "Merge", func(ctx context.Context) error {
+			return r.candidate.Merge(ctx, timeline, into)
+		})
+	}
+	return err
+}
+
+func (r *shadowRepository) GetAliases(ctx context.Context) (map[string]string, error) {
+	result, err := r.primary.GetAliases(ctx)
+	shadow.Compare(ctx, r.runner, "GetAliases", result, err, func(ctx context.Context) (map[string]string, error) {
+		return r.candidate.GetAliases(ctx)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) GetReaders(ctx context.Context) (map[string][]string, error) {
+	result, err := r.primary.GetReaders(ctx)
+	shadow.Compare(ctx, r.runner, "GetReaders", result, err, func(ctx context.Context) (map[string][]string, error) {
+		return r.candidate.GetReaders(ctx)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) GetItem(ctx context.Context, timelineID string, objectID string) (core.TimelineItem, error) {
+	result, err := r.primary.GetItem(ctx, timelineID, objectID)
+	shadow.Compare(ctx, r.runner, "GetItem", result, err, func(ctx context.Context) (core.TimelineItem, error) {
+		return r.candidate.GetItem(ctx, timelineID, objectID)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) CreateItem(ctx context.Context, item core.TimelineItem) (core.TimelineItem, error) {
+	result, err := r.primary.CreateItem(ctx, item)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "CreateItem", func(ctx context.Context) error {
+			_, err := r.candidate.CreateItem(ctx, item)
+			return err
+		})
+	}
+	return result, err
+}
+
+func (r *shadowRepository) DeleteItem(ctx context.Context, timelineID string, objectID string) error {
+	err := r.primary.DeleteItem(ctx, timelineID, objectID)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "DeleteItem", func(ctx context.Context) error {
+			return r.candidate.DeleteItem(ctx, timelineID, objectID)
+		})
+	}
+	return err
+}
+
+func (r *shadowRepository) DeleteItemByResourceID(ctx context.Context, resourceID string) error {
+	err := r.primary.DeleteItemByResourceID(ctx, resourceID)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "DeleteItemByResourceID", func(ctx context.Context) error {
+			return r.candidate.DeleteItemByResourceID(ctx, resourceID)
+		})
+	}
+	return err
+}
+
+func (r *shadowRepository) ExpireItems(ctx context.Context, now time.Time, limit int) ([]core.TimelineItem, error) {
+	result, err := r.primary.ExpireItems(ctx, now, limit)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "ExpireItems", func(ctx context.Context) error {
+			_, err := r.candidate.ExpireItems(ctx, now, limit)
+			return err
+		})
+	}
+	return result, err
+}
+
+func (r *shadowRepository) ListTimelineBySchema(ctx context.Context, schema string) ([]core.Timeline, error) {
+	result, err := r.primary.ListTimelineBySchema(ctx, schema)
+	shadow.Compare(ctx, r.runner, "ListTimelineBySchema", result, err, func(ctx context.Context) ([]core.Timeline, error) {
+		return r.candidate.ListTimelineBySchema(ctx, schema)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) Discover(ctx context.Context, schemas []string, since time.Time, offset, limit int) ([]core.DiscoveredTimeline, error) {
+	result, err := r.primary.Discover(ctx, schemas, since, offset, limit)
+	shadow.Compare(ctx, r.runner, "Discover", result, err, func(ctx context.Context) ([]core.DiscoveredTimeline, error) {
+		return r.candidate.Discover(ctx, schemas, since, offset, limit)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) DiscoverFromRemote(ctx context.Context, host string, schemas []string) ([]core.DiscoveredTimeline, error) {
+	return r.primary.DiscoverFromRemote(ctx, host, schemas)
+}
+
+func (r *shadowRepository) ListTimelineByAuthor(ctx context.Context, author string) ([]core.Timeline, error) {
+	result, err := r.primary.ListTimelineByAuthor(ctx, author)
+	shadow.Compare(ctx, r.runner, "ListTimelineByAuthor", result, err, func(ctx context.Context) ([]core.Timeline, error) {
+		return r.candidate.ListTimelineByAuthor(ctx, author)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) ListTimelineByAuthorOwned(ctx context.Context, author string) ([]core.Timeline, error) {
+	result, err := r.primary.ListTimelineByAuthorOwned(ctx, author)
+	shadow.Compare(ctx, r.runner, "ListTimelineByAuthorOwned", result, err, func(ctx context.Context) ([]core.Timeline, error) {
+		return r.candidate.ListTimelineByAuthorOwned(ctx, author)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) CountTimelineByAuthorOwned(ctx context.Context, author string) (int64, error) {
+	result, err := r.primary.CountTimelineByAuthorOwned(ctx, author)
+	shadow.Compare(ctx, r.runner, "CountTimelineByAuthorOwned", result, err, func(ctx context.Context) (int64, error) {
+		return r.candidate.CountTimelineByAuthorOwned(ctx, author)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) GetRecentItems(ctx context.Context, timelineID string, until time.Time, limit int) ([]core.TimelineItem, error) {
+	result, err := r.primary.GetRecentItems(ctx, timelineID, until, limit)
+	shadow.Compare(ctx, r.runner, "GetRecentItems", result, err, func(ctx context.Context) ([]core.TimelineItem, error) {
+		return r.candidate.GetRecentItems(ctx, timelineID, until, limit)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) GetImmediateItems(ctx context.Context, timelineID string, since time.Time, limit int) ([]core.TimelineItem, error) {
+	result, err := r.primary.GetImmediateItems(ctx, timelineID, since, limit)
+	shadow.Compare(ctx, r.runner, "GetImmediateItems", result, err, func(ctx context.Context) ([]core.TimelineItem, error) {
+		return r.candidate.GetImmediateItems(ctx, timelineID, since, limit)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) PublishEvent(ctx context.Context, event core.Event) error {
+	return r.primary.PublishEvent(ctx, event)
+}
+
+func (r *shadowRepository) ListTimelineSubscriptions(ctx context.Context) (map[string]int64, error) {
+	return r.primary.ListTimelineSubscriptions(ctx)
+}
+
+func (r *shadowRepository) Count(ctx context.Context) (int64, error) {
+	result, err := r.primary.Count(ctx)
+	shadow.Compare(ctx, r.runner, "Count", result, err, func(ctx context.Context) (int64, error) {
+		return r.candidate.Count(ctx)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) Subscribe(ctx context.Context, channels []string, event chan<- core.Event) error {
+	return r.primary.Subscribe(ctx, channels, event)
+}
+
+func (r *shadowRepository) SetNormalizationCache(ctx context.Context, timelineID string, value string) error {
+	err := r.primary.SetNormalizationCache(ctx, timelineID, value)
+	if err == nil {
+		shadow.Mirror(ctx, r.runner, "SetNormalizationCache", func(ctx context.Context) error {
+			return r.candidate.SetNormalizationCache(ctx, timelineID, value)
+		})
+	}
+	return err
+}
+
+func (r *shadowRepository) GetNormalizationCache(ctx context.Context, timelineID string) (string, error) {
+	result, err := r.primary.GetNormalizationCache(ctx, timelineID)
+	shadow.Compare(ctx, r.runner, "GetNormalizationCache", result, err, func(ctx context.Context) (string, error) {
+		return r.candidate.GetNormalizationCache(ctx, timelineID)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) Query(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int) ([]core.TimelineItem, error) {
+	result, err := r.primary.Query(ctx, timelineID, schema, owner, author, until, limit)
+	shadow.Compare(ctx, r.runner, "Query", result, err, func(ctx context.Context) ([]core.TimelineItem, error) {
+		return r.candidate.Query(ctx, timelineID, schema, owner, author, until, limit)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) QueryEach(ctx context.Context, timelineID, schema, owner, author string, until time.Time, limit int, fn func(core.TimelineItem) error) error {
+	return r.primary.QueryEach(ctx, timelineID, schema, owner, author, until, limit, fn)
+}
+
+func (r *shadowRepository) LookupChunkItrs(ctx context.Context, timelines []string, epoch string) (map[string]string, error) {
+	result, err := r.primary.LookupChunkItrs(ctx, timelines, epoch)
+	shadow.Compare(ctx, r.runner, "LookupChunkItrs", result, err, func(ctx context.Context) (map[string]string, error) {
+		return r.candidate.LookupChunkItrs(ctx, timelines, epoch)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) LoadChunkBodies(ctx context.Context, query map[string]string) (map[string]core.Chunk, error) {
+	result, err := r.primary.LoadChunkBodies(ctx, query)
+	shadow.Compare(ctx, r.runner, "LoadChunkBodies", result, err, func(ctx context.Context) (map[string]core.Chunk, error) {
+		return r.candidate.LoadChunkBodies(ctx, query)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) ListRecentlyRemovedItems(ctx context.Context, normalized []string) (map[string][]string, error) {
+	result, err := r.primary.ListRecentlyRemovedItems(ctx, normalized)
+	shadow.Compare(ctx, r.runner, "ListRecentlyRemovedItems", result, err, func(ctx context.Context) (map[string][]string, error) {
+		return r.candidate.ListRecentlyRemovedItems(ctx, normalized)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) ListRecentlyRemovedItemsLocal(ctx context.Context, timelineIDs []string) (map[string][]string, error) {
+	result, err := r.primary.ListRecentlyRemovedItemsLocal(ctx, timelineIDs)
+	shadow.Compare(ctx, r.runner, "ListRecentlyRemovedItemsLocal", result, err, func(ctx context.Context) (map[string][]string, error) {
+		return r.candidate.ListRecentlyRemovedItemsLocal(ctx, timelineIDs)
+	})
+	return result, err
+}
+
+func (r *shadowRepository) GetMetrics() map[string]int64 {
+	return r.primary.GetMetrics()
+}
+
+func (r *shadowRepository) CountWrite(ctx context.Context, timelineID, author string, window time.Duration) (int64, time.Duration, error) {
+	return r.primary.CountWrite(ctx, timelineID, author, window)
+}
+
+func (r *shadowRepository) PeekWrite(ctx context.Context, timelineID, author string) (int64, time.Duration, error) {
+	return r.primary.PeekWrite(ctx, timelineID, author)
+}
+
+func (r *shadowRepository) GetBridges() []core.BridgeStatus {
+	return r.primary.GetBridges()
+}
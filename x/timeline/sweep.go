@@ -0,0 +1,160 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+	"github.com/totegamma/concurrent/x/cache"
+)
+
+var sweptMetrics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cc_timeline_cache_swept_keys_total",
+		Help: "chunk iterator and body cache keys deleted by the sweep by reason",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(sweptMetrics)
+}
+
+const (
+	sweepReasonDeleted = "deleted_timeline"
+	sweepReasonExpired = "expired_epoch"
+)
+
+// Sweeper deletes the cached chunk iterators and bodies which outlived what they cache:
+// the ones of local timelines which were deleted, and the ones of epochs older than their cache ttl.
+// the latter are iterators replaced by the writes to the timeline, which don't carry the ttl and so never expire.
+// keys of old epochs cached by a read of the history are dropped as well, which costs a cache miss at worst
+type Sweeper interface {
+	Sweep(ctx context.Context) error
+}
+
+type sweeper struct {
+	db      *gorm.DB
+	mc      cache.Cache
+	scanner cache.Scanner
+	config  core.Config
+}
+
+// NewSweeper creates a new sweeper
+func NewSweeper(db *gorm.DB, mc cache.Cache, scanner cache.Scanner, config core.Config) Sweeper {
+	return &sweeper{db, mc, scanner, config}
+}
+
+// Sweep scans the cache once. backends which can't list their keys are skipped
+func (s *sweeper) Sweep(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Sweeper.Sweep")
+	defer span.End()
+
+	swept := map[string]int{}
+	now := time.Now()
+	for _, prefix := range []string{tlItrCachePrefix, tlBodyCachePrefix} {
+		err := s.scanner.Scan(ctx, prefix, func(keys []string) error {
+			return s.sweep(ctx, prefix, keys, now, swept)
+		})
+		if errors.Is(err, cache.ErrScanUnsupported) {
+			logging.Info(ctx, "cache backend can't be swept", slog.String("module", "timeline"))
+			return nil
+		}
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	logging.Info(
+		ctx, "swept timeline cache",
+		slog.String("module", "timeline"),
+		slog.Int(sweepReasonDeleted, swept[sweepReasonDeleted]),
+		slog.Int(sweepReasonExpired, swept[sweepReasonExpired]),
+	)
+	return nil
+}
+
+// sweep deletes the orphaned keys of a batch
+func (s *sweeper) sweep(ctx context.Context, prefix string, keys []string, now time.Time, swept map[string]int) error {
+	orphans := map[string]string{}
+	local := map[string][]string{}
+	for _, key := range keys {
+		timeline, epoch, ok := parseChunkCacheKey(prefix, key)
+		if !ok {
+			continue
+		}
+		if chunkCacheExpired(epoch, now) {
+			orphans[key] = sweepReasonExpired
+			continue
+		}
+		id, host, _ := strings.Cut(timeline, "@")
+		if host == s.config.FQDN && len(id) == 27 && id[0] == 't' {
+			local[id[1:]] = append(local[id[1:]], key)
+		}
+	}
+
+	if len(local) > 0 {
+		ids := make([]string, 0, len(local))
+		for id := range local {
+			ids = append(ids, id)
+		}
+		var existing []string
+		err := s.db.WithContext(ctx).Model(&core.Timeline{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range existing {
+			delete(local, id)
+		}
+		for _, keys := range local {
+			for _, key := range keys {
+				orphans[key] = sweepReasonDeleted
+			}
+		}
+	}
+
+	for key, reason := range orphans {
+		err := s.mc.Delete(key)
+		if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+			return err
+		}
+		if err == nil {
+			swept[reason]++
+			sweptMetrics.WithLabelValues(reason).Inc()
+		}
+	}
+	return nil
+}
+
+// parseChunkCacheKey splits "<prefix><timeline>:<epoch>"
+func parseChunkCacheKey(prefix, key string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(key, prefix)
+	if !ok {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	timeline, epoch := rest[:i], rest[i+1:]
+	if _, err := strconv.ParseInt(epoch, 10, 64); err != nil {
+		return "", "", false
+	}
+	return timeline, epoch, true
+}
+
+// chunkCacheExpired reports whether the chunk of the epoch is older than any key caching it should live
+func chunkCacheExpired(epoch string, now time.Time) bool {
+	ttl := time.Duration(max(tlItrCacheTTL, tlBodyCacheTTL)) * time.Second
+	return core.Chunk2RecentTime(epoch).Add(ttl).Before(now)
+}
@@ -0,0 +1,40 @@
+package timeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/cache"
+)
+
+// listScanner lists fixed keys
+type listScanner []string
+
+func (l listScanner) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	return fn(l)
+}
+
+func TestSweepExpiredEpochs(t *testing.T) {
+	mc, err := cache.NewMemory(1 << 20)
+	assert.NoError(t, err)
+
+	timeline := "t00000000000000000000000000@remote.example.com"
+	old := tlItrCachePrefix + timeline + ":" + core.Time2Chunk(time.Now().Add(-3*24*time.Hour))
+	recent := tlItrCachePrefix + timeline + ":" + core.Time2Chunk(time.Now())
+	for _, key := range []string{old, recent} {
+		mc.Set(&memcache.Item{Key: key, Value: []byte("1")})
+	}
+
+	sweeper := NewSweeper(nil, mc, listScanner{old, recent, "tl:itr:malformed"}, core.Config{FQDN: "local.example.com"})
+	assert.NoError(t, sweeper.Sweep(context.Background()))
+
+	_, err = mc.Get(old)
+	assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+	_, err = mc.Get(recent)
+	assert.NoError(t, err)
+}
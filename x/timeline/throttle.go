@@ -0,0 +1,163 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+// writeRateLimitParam is the policy param limiting how often an author may post to the timeline, e.g.
+// "writeRateLimit": {"count": 10, "window": 60} allows 10 posts per author per minute
+const writeRateLimitParam = "writeRateLimit"
+
+// unthrottledAction is allowed by the policy of a timeline to exempt requesters from its writeRateLimit
+const unthrottledAction = "timeline.distribute.unthrottled"
+
+// writeRateLimit reads the writeRateLimit param. ok is false when the timeline has no limit
+func writeRateLimit(params map[string]any) (count int64, window time.Duration, ok bool) {
+	param, ok := params[writeRateLimitParam].(map[string]any)
+	if !ok {
+		return 0, 0, false
+	}
+	c, _ := param["count"].(float64)
+	w, _ := param["window"].(float64)
+	if c < 1 || w < 1 {
+		return 0, 0, false
+	}
+	return int64(c), time.Duration(w) * time.Second, true
+}
+
+// throttled tells the writeRateLimit which applies to the author. ok is false when the author is not limited:
+// the timeline has no limit, the author owns it or the policy exempts the requester
+func (s *service) throttled(ctx context.Context, tl core.Timeline, author string, request core.RequestContext) (limit int64, window time.Duration, ok bool) {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.Throttled")
+	defer span.End()
+
+	limit, window, ok = writeRateLimit(request.Params)
+	if !ok || tl.Author == author {
+		return 0, 0, false
+	}
+
+	result, err := s.policy.TestWithPolicyURL(ctx, tl.Policy, request, unthrottledAction)
+	if err != nil {
+		span.RecordError(err)
+	}
+	if result == core.PolicyEvalResultAllow || result == core.PolicyEvalResultAlways {
+		return 0, 0, false
+	}
+
+	return limit, window, true
+}
+
+// throttle counts the post of the author to the timeline, and refuses it when it goes over the writeRateLimit.
+// the owner of the timeline is never throttled. posts pass when the counter is unavailable
+func (s *service) throttle(ctx context.Context, tl core.Timeline, author string, request core.RequestContext) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.Throttle")
+	defer span.End()
+
+	limit, window, ok := s.throttled(ctx, tl, author, request)
+	if !ok {
+		return nil
+	}
+
+	count, reset, err := s.repository.CountWrite(ctx, tl.ID, author, window)
+	if err != nil {
+		span.RecordError(err)
+		logging.Warn(ctx, "failed to count timeline write", slog.String("error", err.Error()), slog.String("module", "timeline"))
+		return nil
+	}
+
+	span.SetAttributes(attribute.Int64("count", count), attribute.Int64("limit", limit))
+	if count <= limit {
+		return nil
+	}
+
+	if reset < time.Second {
+		reset = time.Second
+	}
+	logging.Info(
+		ctx, "throttled post to timeline",
+		slog.String("type", "audit"),
+		slog.String("principal", author),
+		slog.String("timeline", tl.ID),
+		slog.String("module", "timeline"),
+	)
+	return core.NewErrorRateLimited("timeline.distribute", tl.ID, limit, window, reset)
+}
+
+// CheckThrottle refuses a post of the author to the local timelines when one of them would throttle it,
+// so that the resource is not stored before PostItem refuses it. nothing is counted here
+func (s *service) CheckThrottle(ctx context.Context, timelines []string, author, document string) error {
+	ctx, span := tracer.Start(ctx, "Timeline.Service.CheckThrottle")
+	defer span.End()
+
+	var doc core.DocumentBase[any]
+	json.Unmarshal([]byte(document), &doc)
+
+	var requester core.Entity
+	for _, timeline := range timelines {
+		normalized, err := s.NormalizeTimelineID(ctx, timeline)
+		if err != nil {
+			continue
+		}
+		split := strings.Split(normalized, "@")
+		if len(split) != 2 || split[1] != s.config.FQDN {
+			continue
+		}
+
+		tl, err := s.GetTimeline(ctx, split[0])
+		if err != nil {
+			continue
+		}
+
+		var params map[string]any = make(map[string]any)
+		if tl.PolicyParams != nil {
+			json.Unmarshal([]byte(*tl.PolicyParams), &params)
+		}
+		if _, _, ok := writeRateLimit(params); !ok || tl.Author == author {
+			continue
+		}
+
+		if requester.ID == "" {
+			requester, err = s.entity.Get(ctx, author)
+			if err != nil {
+				span.RecordError(err)
+			}
+		}
+
+		request := core.RequestContext{
+			Self:      tl,
+			Requester: requester,
+			Params:    params,
+			Document:  doc,
+		}
+
+		limit, window, ok := s.throttled(ctx, tl, author, request)
+		if !ok {
+			continue
+		}
+
+		count, reset, err := s.repository.PeekWrite(ctx, tl.ID, author)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		if count < limit {
+			continue
+		}
+
+		if reset < time.Second {
+			reset = time.Second
+		}
+		return core.NewErrorRateLimited("timeline.distribute", tl.ID, limit, window, reset)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/core/mock"
+	"github.com/totegamma/concurrent/x/timeline/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	s := &service{repository: mockRepo, policy: mockPolicy}
+
+	ctx := context.Background()
+	tl := core.Timeline{ID: "t00000000000000000000000000", Author: "con1owner", Policy: "https://policy.example.com/throttle.json"}
+	request := core.RequestContext{Params: map[string]any{
+		"writeRateLimit": map[string]any{"count": float64(2), "window": float64(60)},
+	}}
+
+	// timelines without a limit and their owner are not counted
+	assert.NoError(t, s.throttle(ctx, tl, "con1author", core.RequestContext{}))
+	assert.NoError(t, s.throttle(ctx, tl, "con1owner", request))
+
+	mockPolicy.EXPECT().TestWithPolicyURL(gomock.Any(), tl.Policy, gomock.Any(), "timeline.distribute.unthrottled").Return(core.PolicyEvalResultDefault, nil).Times(2)
+	mockRepo.EXPECT().CountWrite(gomock.Any(), tl.ID, "con1author", time.Minute).Return(int64(2), 30*time.Second, nil)
+	mockRepo.EXPECT().CountWrite(gomock.Any(), tl.ID, "con1author", time.Minute).Return(int64(3), 20*time.Second, nil)
+
+	assert.NoError(t, s.throttle(ctx, tl, "con1author", request))
+	err := s.throttle(ctx, tl, "con1author", request)
+	assert.Equal(t, core.ErrorRateLimited{
+		Action:     "timeline.distribute",
+		Target:     tl.ID,
+		Limit:      2,
+		Window:     60,
+		RetryAfter: 20,
+	}, err)
+
+	// the policy may exempt requesters
+	mockPolicy.EXPECT().TestWithPolicyURL(gomock.Any(), tl.Policy, gomock.Any(), "timeline.distribute.unthrottled").Return(core.PolicyEvalResultAllow, nil)
+	assert.NoError(t, s.throttle(ctx, tl, "con1bot", request))
+}
+
+func TestCheckThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_timeline.NewMockRepository(ctrl)
+	mockPolicy := mock_core.NewMockPolicyService(ctrl)
+	mockEntity := mock_core.NewMockEntityService(ctrl)
+	s := &service{repository: mockRepo, policy: mockPolicy, entity: mockEntity, config: core.Config{FQDN: "local.example.com"}}
+
+	ctx := context.Background()
+	params := `{"writeRateLimit": {"count": 2, "window": 60}}`
+	limited := core.Timeline{ID: "t00000000000000000000000001", Author: "con1owner", PolicyParams: &params}
+	free := core.Timeline{ID: "t00000000000000000000000002", Author: "con1owner"}
+
+	mockRepo.EXPECT().GetNormalizationCache(gomock.Any(), gomock.Any()).Return("", errors.New("not cached")).AnyTimes()
+	mockRepo.EXPECT().SetNormalizationCache(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRepo.EXPECT().GetTimeline(gomock.Any(), limited.ID).Return(limited, nil).AnyTimes()
+	mockRepo.EXPECT().GetTimeline(gomock.Any(), free.ID).Return(free, nil).AnyTimes()
+	mockEntity.EXPECT().Get(gomock.Any(), "con1author").Return(core.Entity{ID: "con1author"}, nil).AnyTimes()
+	mockPolicy.EXPECT().TestWithPolicyURL(gomock.Any(), gomock.Any(), gomock.Any(), "timeline.distribute.unthrottled").Return(core.PolicyEvalResultDefault, nil).AnyTimes()
+
+	timelines := []string{free.ID, limited.ID, "t00000000000000000000000003@remote.example.com"}
+
+	// the check only reads the counter, the post is counted by PostItem
+	mockRepo.EXPECT().PeekWrite(gomock.Any(), limited.ID, "con1author").Return(int64(1), 30*time.Second, nil)
+	assert.NoError(t, s.CheckThrottle(ctx, timelines, "con1author", "{}"))
+
+	mockRepo.EXPECT().PeekWrite(gomock.Any(), limited.ID, "con1author").Return(int64(2), 20*time.Second, nil)
+	err := s.CheckThrottle(ctx, timelines, "con1author", "{}")
+	assert.Equal(t, core.NewErrorRateLimited("timeline.distribute", limited.ID, 2, time.Minute, 20*time.Second), err)
+
+	// the owner is never throttled
+	assert.NoError(t, s.CheckThrottle(ctx, timelines, "con1owner", "{}"))
+}
@@ -0,0 +1,54 @@
+package trend
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler is the interface for handling HTTP requests
+type Handler interface {
+	Get(c echo.Context) error
+}
+
+type handler struct {
+	service Service
+}
+
+// NewHandler creates a new handler
+func NewHandler(service Service) Handler {
+	return &handler{service}
+}
+
+// Get returns the trending topics of the window (1h, 6h or 24h. default 1h)
+func (h handler) Get(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Trend.Handler.Get")
+	defer span.End()
+
+	windowStr := c.QueryParam("window")
+	if windowStr == "" {
+		windowStr = "1h"
+	}
+	window, ok := Windows[windowStr]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "window must be one of 1h, 6h or 24h"})
+	}
+
+	limit := DefaultLimit
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid limit"})
+		}
+	}
+
+	trends, err := h.service.Get(ctx, window, limit)
+	if err != nil {
+		span.RecordError(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": trends})
+}
@@ -0,0 +1,144 @@
+package trend
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/keyspace"
+)
+
+const (
+	// hourly counts are kept for the longest window and the hour in progress
+	bucketTTL = 25 * time.Hour
+	// only the top topics of each hour are kept to bound the memory
+	maxTopicsPerHour = 1000
+)
+
+// Repository is the interface for trend repository
+type Repository interface {
+	ListMessages(ctx context.Context, since, until time.Time, limit int) ([]core.Message, error)
+	GetCursor(ctx context.Context) (time.Time, error)
+	SetCursor(ctx context.Context, cursor time.Time) error
+	AddCounts(ctx context.Context, hour string, counts map[string]int64) error
+	GetCounts(ctx context.Context, hours []string) (map[string]int64, error)
+}
+
+type repository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewRepository creates a new trend repository
+func NewRepository(db *gorm.DB, rdb *redis.Client) Repository {
+	return &repository{db, rdb}
+}
+
+func bucketKey(hour string) string {
+	return keyspace.Trend.Key(hour)
+}
+
+func cursorKey() string {
+	return keyspace.Trend.Key("cursor")
+}
+
+// ListMessages returns messages created in (since, until] in order of creation
+func (r *repository) ListMessages(ctx context.Context, since, until time.Time, limit int) ([]core.Message, error) {
+	ctx, span := tracer.Start(ctx, "Trend.Repository.ListMessages")
+	defer span.End()
+
+	var messages []core.Message
+	err := r.db.WithContext(ctx).
+		Where("c_date > ? AND c_date <= ?", since, until).
+		Order("c_date ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetCursor returns the creation time of the last aggregated message. zero if nothing was aggregated yet
+func (r *repository) GetCursor(ctx context.Context) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "Trend.Repository.GetCursor")
+	defer span.End()
+
+	value, err := r.rdb.Get(ctx, cursorKey()).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// SetCursor records the creation time of the last aggregated message
+func (r *repository) SetCursor(ctx context.Context, cursor time.Time) error {
+	ctx, span := tracer.Start(ctx, "Trend.Repository.SetCursor")
+	defer span.End()
+
+	err := r.rdb.Set(ctx, cursorKey(), cursor.Format(time.RFC3339Nano), bucketTTL).Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// AddCounts adds the counts of topics to the bucket of the hour
+func (r *repository) AddCounts(ctx context.Context, hour string, counts map[string]int64) error {
+	ctx, span := tracer.Start(ctx, "Trend.Repository.AddCounts")
+	defer span.End()
+
+	if !keyspace.Trend.Allow(r.rdb) {
+		return nil
+	}
+
+	key := bucketKey(hour)
+	pipe := r.rdb.TxPipeline()
+	for topic, count := range counts {
+		pipe.ZIncrBy(ctx, key, float64(count), topic)
+	}
+	pipe.ZRemRangeByRank(ctx, key, 0, -maxTopicsPerHour-1)
+	pipe.Expire(ctx, key, bucketTTL)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// GetCounts returns the counts of topics summed over the buckets of the hours
+func (r *repository) GetCounts(ctx context.Context, hours []string) (map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "Trend.Repository.GetCounts")
+	defer span.End()
+
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, len(hours))
+	for i, hour := range hours {
+		cmds[i] = pipe.ZRangeWithScores(ctx, bucketKey(hour), 0, -1)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	for _, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			topic, ok := z.Member.(string)
+			if !ok {
+				continue
+			}
+			counts[topic] += int64(z.Score)
+		}
+	}
+	return counts, nil
+}
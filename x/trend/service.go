@@ -0,0 +1,180 @@
+// Package trend aggregates hashtags of recent messages into hourly counts
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
+)
+
+var tracer = otel.Tracer("trend")
+
+const (
+	hourFormat = "2006010215"
+	batchSize  = 1000
+	// messages are aggregated a bit late so that ones committed out of order of their creation time are not skipped
+	settleDelay = 10 * time.Second
+
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Windows are the periods trends can be computed over
+var Windows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// Trend is a topic and the number of messages mentioning it in the window
+type Trend struct {
+	Topic string `json:"topic"`
+	Count int64  `json:"count"`
+}
+
+// Service is the interface for trend service
+type Service interface {
+	Run(ctx context.Context) error
+	Get(ctx context.Context, window time.Duration, limit int) ([]Trend, error)
+}
+
+type service struct {
+	repo Repository
+	now  func() time.Time
+}
+
+// NewService creates a new trend service
+func NewService(repo Repository) Service {
+	return &service{repo, time.Now}
+}
+
+// Run aggregates the topics of the messages created since the last run
+func (s *service) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Trend.Service.Run")
+	defer span.End()
+
+	now := s.now()
+	until := now.Add(-settleDelay)
+
+	cursor, err := s.repo.GetCursor(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	// older messages are out of every window
+	if oldest := now.Add(-Windows["24h"]); cursor.Before(oldest) {
+		cursor = oldest
+	}
+
+	aggregated := 0
+	for {
+		messages, err := s.repo.ListMessages(ctx, cursor, until, batchSize)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		buckets := make(map[string]map[string]int64)
+		for _, message := range messages {
+			var doc core.MessageDocument[any]
+			err := json.Unmarshal([]byte(message.Document), &doc)
+			if err != nil {
+				logging.Warn(ctx, "failed to parse message", slog.String("module", "trend"), slog.String("id", message.ID), slog.String("error", err.Error()))
+				continue
+			}
+			// messages restricted by a policy may not be public
+			if doc.Policy != "" {
+				continue
+			}
+
+			topics := extractTopics(doc.Schema, doc.Body)
+			if len(topics) == 0 {
+				continue
+			}
+			hour := message.CDate.UTC().Format(hourFormat)
+			if _, ok := buckets[hour]; !ok {
+				buckets[hour] = make(map[string]int64)
+			}
+			for _, topic := range topics {
+				buckets[hour][topic]++
+			}
+		}
+
+		for hour, counts := range buckets {
+			err := s.repo.AddCounts(ctx, hour, counts)
+			if err != nil {
+				span.RecordError(err)
+				return err
+			}
+		}
+
+		cursor = messages[len(messages)-1].CDate
+		err = s.repo.SetCursor(ctx, cursor)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		aggregated += len(messages)
+		if len(messages) < batchSize {
+			break
+		}
+	}
+
+	span.SetAttributes(attribute.Int("aggregated", aggregated))
+	return nil
+}
+
+// Get returns the most mentioned topics in the window, which ends at the hour in progress
+func (s *service) Get(ctx context.Context, window time.Duration, limit int) ([]Trend, error) {
+	ctx, span := tracer.Start(ctx, "Trend.Service.Get")
+	defer span.End()
+
+	if window < time.Hour || window > Windows["24h"] {
+		return nil, fmt.Errorf("unsupported window: %s", window)
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	current := s.now().UTC().Truncate(time.Hour)
+	hours := make([]string, 0, int(window/time.Hour))
+	for t := current; t.After(current.Add(-window)); t = t.Add(-time.Hour) {
+		hours = append(hours, t.Format(hourFormat))
+	}
+
+	counts, err := s.repo.GetCounts(ctx, hours)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	trends := make([]Trend, 0, len(counts))
+	for topic, count := range counts {
+		trends = append(trends, Trend{Topic: topic, Count: count})
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Count != trends[j].Count {
+			return trends[i].Count > trends[j].Count
+		}
+		return trends[i].Topic < trends[j].Topic
+	})
+	if len(trends) > limit {
+		trends = trends[:limit]
+	}
+
+	return trends, nil
+}
@@ -0,0 +1,115 @@
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/totegamma/concurrent/core"
+)
+
+const markdown = "https://schema.concrnt.world/m/markdown.json"
+
+type memoryRepository struct {
+	messages []core.Message
+	cursor   time.Time
+	buckets  map[string]map[string]int64
+}
+
+func (r *memoryRepository) ListMessages(ctx context.Context, since, until time.Time, limit int) ([]core.Message, error) {
+	var messages []core.Message
+	for _, message := range r.messages {
+		if message.CDate.After(since) && !message.CDate.After(until) && len(messages) < limit {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *memoryRepository) GetCursor(ctx context.Context) (time.Time, error) {
+	return r.cursor, nil
+}
+
+func (r *memoryRepository) SetCursor(ctx context.Context, cursor time.Time) error {
+	r.cursor = cursor
+	return nil
+}
+
+func (r *memoryRepository) AddCounts(ctx context.Context, hour string, counts map[string]int64) error {
+	if r.buckets[hour] == nil {
+		r.buckets[hour] = make(map[string]int64)
+	}
+	for topic, count := range counts {
+		r.buckets[hour][topic] += count
+	}
+	return nil
+}
+
+func (r *memoryRepository) GetCounts(ctx context.Context, hours []string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, hour := range hours {
+		for topic, count := range r.buckets[hour] {
+			counts[topic] += count
+		}
+	}
+	return counts, nil
+}
+
+func newMessage(t *testing.T, cdate time.Time, body, policy string) core.Message {
+	document, err := json.Marshal(core.MessageDocument[any]{
+		DocumentBase: core.DocumentBase[any]{
+			Schema: markdown,
+			Policy: policy,
+			Body:   map[string]any{"body": body},
+		},
+	})
+	assert.NoError(t, err)
+	return core.Message{Document: string(document), CDate: cdate}
+}
+
+func TestExtractTopics(t *testing.T) {
+	topics := extractTopics(markdown, map[string]any{
+		"body": "#Go and #concrnt! #go again, #日本語 too. not https://example.com/#anchor, ##double or #1",
+	})
+	assert.Equal(t, []string{"go", "concrnt", "日本語"}, topics)
+
+	assert.Empty(t, extractTopics("https://example.com/unknown.json", map[string]any{"body": "#go"}))
+	assert.Empty(t, extractTopics(markdown, "not an object"))
+}
+
+func TestRunAndGet(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+	repo := &memoryRepository{
+		messages: []core.Message{
+			newMessage(t, now.Add(-30*time.Hour), "#stale", ""),
+			newMessage(t, now.Add(-5*time.Hour), "#go #concrnt", ""),
+			newMessage(t, now.Add(-20*time.Minute), "#go", ""),
+			newMessage(t, now.Add(-10*time.Minute), "#secret", "https://policy.example.com/private.json"),
+			newMessage(t, now.Add(-time.Second), "#tooearly", ""),
+		},
+		buckets: make(map[string]map[string]int64),
+	}
+	s := &service{repo, func() time.Time { return now }}
+	ctx := context.Background()
+
+	assert.NoError(t, s.Run(ctx))
+	assert.Equal(t, now.Add(-10*time.Minute), repo.cursor)
+
+	// a second run does not count the same messages again
+	assert.NoError(t, s.Run(ctx))
+
+	trends, err := s.Get(ctx, time.Hour, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Trend{{Topic: "go", Count: 1}}, trends)
+
+	trends, err = s.Get(ctx, 6*time.Hour, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Trend{{Topic: "go", Count: 2}, {Topic: "concrnt", Count: 1}}, trends)
+
+	trends, err = s.Get(ctx, 24*time.Hour, 1)
+	assert.NoError(t, err)
+	assert.Len(t, trends, 1)
+}
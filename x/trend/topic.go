@@ -0,0 +1,72 @@
+package trend
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTopicsPerMessage = 10
+	maxTopicLength      = 64
+)
+
+// topicFields lists the body fields scanned for hashtags for each message schema.
+// messages of other schemas are not counted.
+var topicFields = map[string][]string{
+	"https://schema.concrnt.world/m/markdown.json":  {"body"},
+	"https://schema.concrnt.world/m/plaintext.json": {"body"},
+	"https://schema.concrnt.world/m/reply.json":     {"body"},
+	"https://schema.concrnt.world/m/reroute.json":   {"body"},
+}
+
+// # must not follow a word character or another # so that anchors in urls and headings are not treated as hashtags
+var hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#/&])#([\p{L}\p{N}_]+)`)
+
+// extractTopics returns the hashtags of the message body, lowercased and without duplicates, in order of appearance
+func extractTopics(schema string, body any) []string {
+	fields, ok := topicFields[schema]
+	if !ok {
+		return nil
+	}
+
+	obj, ok := body.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var topics []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		text, ok := obj[field].(string)
+		if !ok {
+			continue
+		}
+		for _, match := range hashtagRegex.FindAllStringSubmatch(text, -1) {
+			topic := strings.ToLower(match[1])
+			if utf8.RuneCountInString(topic) > maxTopicLength || isNumeric(topic) {
+				continue
+			}
+			if seen[topic] {
+				continue
+			}
+			seen[topic] = true
+			topics = append(topics, topic)
+			if len(topics) >= maxTopicsPerMessage {
+				return topics
+			}
+		}
+	}
+
+	return topics
+}
+
+// numbers like "#1" are references rather than topics
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,9 @@ package userkv
 import (
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
@@ -14,10 +17,13 @@ import (
 
 var tracer = otel.Tracer("userkv")
 
-// Handler is the interface for handling HTTP requests
+// Handler is the interface for handling HTTP requests.
+// routes without the namespace param work on the flat keys
 type Handler interface {
 	Get(c echo.Context) error
 	Upsert(c echo.Context) error
+	Delete(c echo.Context) error
+	List(c echo.Context) error
 }
 
 type handler struct {
@@ -29,6 +35,39 @@ func NewHandler(service Service) Handler {
 	return &handler{service}
 }
 
+func (h handler) respondError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrorInvalidKey):
+		return c.JSON(http.StatusBadRequest, echo.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, ErrorVersionMismatch):
+		return c.JSON(http.StatusPreconditionFailed, echo.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, core.ErrorNotFound{}):
+		return c.JSON(http.StatusNotFound, echo.Map{"status": "error", "message": "userkv not found"})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"status": "error", "message": err.Error()})
+}
+
+// parseVersion reads the condition of a write from If-Match, or If-None-Match: * for create only
+func parseVersion(c echo.Context) (*int64, error) {
+	if c.Request().Header.Get("If-None-Match") == "*" {
+		version := int64(0)
+		return &version, nil
+	}
+	match := c.Request().Header.Get("If-Match")
+	if match == "" {
+		return nil, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(match, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return nil, errors.Wrap(ErrorInvalidKey, "invalid If-Match")
+	}
+	return &version, nil
+}
+
+func setETag(c echo.Context, kv core.UserKV) {
+	c.Response().Header().Set("ETag", `"`+strconv.FormatInt(kv.Version, 10)+`"`)
+}
+
 // Get returns a userkv by ID
 func (h handler) Get(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "UserKV.Handler.Get")
@@ -39,18 +78,15 @@ func (h handler) Get(c echo.Context) error {
 		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
 	}
 
-	key := c.Param("key")
-	value, err := h.service.Get(ctx, requester, key)
+	kv, err := h.service.Lookup(ctx, requester, c.Param("namespace"), c.Param("key"))
 	if err != nil {
-		if errors.Is(err, core.ErrorNotFound{}) {
-			return c.JSON(http.StatusNotFound, echo.Map{"status": "error", "message": "userkv not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"status": "error", "message": err.Error()})
+		return h.respondError(c, err)
 	}
-	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": value})
+	setETag(c, kv)
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": kv.Value})
 }
 
-// Upsert updates a userkv
+// Upsert updates a userkv. ttl (seconds) expires it, and If-Match makes the write conditional
 func (h handler) Upsert(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "UserKV.Handler.Upsert")
 	defer span.End()
@@ -60,7 +96,20 @@ func (h handler) Upsert(c echo.Context) error {
 		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
 	}
 
-	key := c.Param("key")
+	var opts PutOptions
+	if value := c.QueryParam("ttl"); value != "" {
+		ttl, err := strconv.Atoi(value)
+		if err != nil || ttl <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"status": "error", "message": "invalid ttl"})
+		}
+		opts.TTL = time.Duration(ttl) * time.Second
+	}
+	version, err := parseVersion(c)
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	opts.Version = version
+
 	body := c.Request().Body
 	bytes, err := io.ReadAll(body)
 	if err != nil {
@@ -68,9 +117,58 @@ func (h handler) Upsert(c echo.Context) error {
 	}
 	value := string(bytes)
 
-	err = h.service.Upsert(ctx, requester, key, value)
+	kv, err := h.service.Put(ctx, requester, c.Param("namespace"), c.Param("key"), value, opts)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"status": "error", "message": err.Error()})
+		return h.respondError(c, err)
+	}
+	setETag(c, kv)
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": kv})
+}
+
+// Delete removes a userkv. If-Match makes the delete conditional
+func (h handler) Delete(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "UserKV.Handler.Delete")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	version, err := parseVersion(c)
+	if err != nil {
+		return h.respondError(c, err)
+	}
+
+	err = h.service.Delete(ctx, requester, c.Param("namespace"), c.Param("key"), version)
+	if err != nil {
+		return h.respondError(c, err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
 }
+
+// List returns the userkvs whose key starts with the prefix
+func (h handler) List(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "UserKV.Handler.List")
+	defer span.End()
+
+	requester, ok := ctx.Value(core.RequesterIdCtxKey).(string)
+	if !ok {
+		return c.JSON(http.StatusForbidden, echo.Map{"status": "error", "message": "requester not found"})
+	}
+
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"status": "error", "message": "invalid limit"})
+		}
+	}
+
+	kvs, err := h.service.List(ctx, requester, c.Param("namespace"), c.QueryParam("prefix"), limit)
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": kvs})
+}
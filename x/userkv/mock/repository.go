@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	core "github.com/totegamma/concurrent/core"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -53,31 +54,76 @@ func (mr *MockRepositoryMockRecorder) Clean(ctx, ccid any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Clean", reflect.TypeOf((*MockRepository)(nil).Clean), ctx, ccid)
 }
 
+// Delete mocks base method.
+func (m *MockRepository) Delete(ctx context.Context, owner, namespace, key string, version *int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, owner, namespace, key, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockRepositoryMockRecorder) Delete(ctx, owner, namespace, key, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), ctx, owner, namespace, key, version)
+}
+
 // Get mocks base method.
-func (m *MockRepository) Get(ctx context.Context, owner, key string) (string, error) {
+func (m *MockRepository) Get(ctx context.Context, owner, namespace, key string) (core.UserKV, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", ctx, owner, key)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "Get", ctx, owner, namespace, key)
+	ret0, _ := ret[0].(core.UserKV)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockRepositoryMockRecorder) Get(ctx, owner, key any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Get(ctx, owner, namespace, key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), ctx, owner, key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), ctx, owner, namespace, key)
 }
 
-// Upsert mocks base method.
-func (m *MockRepository) Upsert(ctx context.Context, owner, key, value string) error {
+// List mocks base method.
+func (m *MockRepository) List(ctx context.Context, owner, namespace, prefix string, limit int) ([]core.UserKV, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Upsert", ctx, owner, key, value)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "List", ctx, owner, namespace, prefix, limit)
+	ret0, _ := ret[0].([]core.UserKV)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockRepositoryMockRecorder) List(ctx, owner, namespace, prefix, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRepository)(nil).List), ctx, owner, namespace, prefix, limit)
+}
+
+// Put mocks base method.
+func (m *MockRepository) Put(ctx context.Context, kv core.UserKV, version *int64) (core.UserKV, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Put", ctx, kv, version)
+	ret0, _ := ret[0].(core.UserKV)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Put indicates an expected call of Put.
+func (mr *MockRepositoryMockRecorder) Put(ctx, kv, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockRepository)(nil).Put), ctx, kv, version)
+}
+
+// Sweep mocks base method.
+func (m *MockRepository) Sweep(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sweep", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Upsert indicates an expected call of Upsert.
-func (mr *MockRepositoryMockRecorder) Upsert(ctx, owner, key, value any) *gomock.Call {
+// Sweep indicates an expected call of Sweep.
+func (mr *MockRepositoryMockRecorder) Sweep(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockRepository)(nil).Upsert), ctx, owner, key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sweep", reflect.TypeOf((*MockRepository)(nil).Sweep), ctx)
 }
@@ -3,14 +3,21 @@ package userkv
 
 import (
 	"context"
+	"strings"
+	"time"
+
 	"github.com/totegamma/concurrent/core"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository is the interface for userkv repository
 type Repository interface {
-	Get(ctx context.Context, owner, key string) (string, error)
-	Upsert(ctx context.Context, owner, key, value string) error
+	Get(ctx context.Context, owner, namespace, key string) (core.UserKV, error)
+	Put(ctx context.Context, kv core.UserKV, version *int64) (core.UserKV, error)
+	Delete(ctx context.Context, owner, namespace, key string, version *int64) error
+	List(ctx context.Context, owner, namespace, prefix string, limit int) ([]core.UserKV, error)
+	Sweep(ctx context.Context) (int64, error)
 	Clean(ctx context.Context, ccid string) error
 }
 
@@ -23,35 +30,178 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db}
 }
 
-// Get returns a userkv by ID
-func (r *repository) Get(ctx context.Context, owner, key string) (string, error) {
+// storageKey returns the key a namespaced entry is stored as
+func storageKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return namespace + "/" + key
+}
+
+// entryKey restores the key of an entry as seen from its namespace
+func entryKey(kv core.UserKV) core.UserKV {
+	if kv.Namespace != "" {
+		kv.Key = strings.TrimPrefix(kv.Key, kv.Namespace+"/")
+	}
+	return kv
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Get returns a live userkv entry
+func (r *repository) Get(ctx context.Context, owner, namespace, key string) (core.UserKV, error) {
 	ctx, span := tracer.Start(ctx, "UserKV.Repository.Get")
 	defer span.End()
 
 	var kv core.UserKV
-	if err := r.db.Where("owner = ? AND key = ?", owner, key).First(&kv).Error; err != nil {
+	err := r.db.WithContext(ctx).
+		Where("owner = ? AND key = ? AND namespace = ?", owner, storageKey(namespace, key), namespace).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&kv).Error
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return "", core.NewErrorNotFound()
+			return core.UserKV{}, core.NewErrorNotFound()
 		}
 		span.RecordError(err)
-		return "", err
+		return core.UserKV{}, err
+	}
+
+	return entryKey(kv), nil
+}
+
+// Put writes a userkv entry and returns it with its new version.
+// version nil writes unconditionally, 0 only creates, and otherwise the live entry must have that version.
+func (r *repository) Put(ctx context.Context, kv core.UserKV, version *int64) (core.UserKV, error) {
+	ctx, span := tracer.Start(ctx, "UserKV.Repository.Put")
+	defer span.End()
+
+	namespace, key := kv.Namespace, kv.Key
+	kv.Key = storageKey(namespace, key)
+	kv.Version = 1
+
+	now := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// an expired entry is as good as absent, so it never takes part in a version check
+		err := tx.Where("owner = ? AND key = ? AND expires_at <= ?", kv.Owner, kv.Key, now).Delete(&core.UserKV{}).Error
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case version == nil:
+			return tx.Clauses(
+				clause.OnConflict{
+					Columns: []clause.Column{{Name: "owner"}, {Name: "key"}},
+					DoUpdates: clause.Assignments(map[string]any{
+						"value":      kv.Value,
+						"version":    gorm.Expr("user_kvs.version + 1"),
+						"expires_at": kv.ExpiresAt,
+						"m_date":     now,
+					}),
+				},
+				clause.Returning{},
+			).Create(&kv).Error
+		case *version == 0:
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&kv)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrorVersionMismatch
+			}
+			return nil
+		default:
+			result := tx.Model(&kv).
+				Clauses(clause.Returning{}).
+				Where("owner = ? AND key = ? AND version = ?", kv.Owner, kv.Key, *version).
+				Updates(map[string]any{
+					"value":      kv.Value,
+					"version":    gorm.Expr("version + 1"),
+					"expires_at": kv.ExpiresAt,
+					"m_date":     now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrorVersionMismatch
+			}
+			return nil
+		}
+	})
+	if err != nil {
+		if err != ErrorVersionMismatch {
+			span.RecordError(err)
+		}
+		return core.UserKV{}, err
 	}
 
-	return kv.Value, nil
+	return entryKey(kv), nil
+}
+
+// Delete removes a userkv entry. with version, the live entry must have that version
+func (r *repository) Delete(ctx context.Context, owner, namespace, key string, version *int64) error {
+	ctx, span := tracer.Start(ctx, "UserKV.Repository.Delete")
+	defer span.End()
+
+	query := r.db.WithContext(ctx).
+		Where("owner = ? AND key = ? AND namespace = ?", owner, storageKey(namespace, key), namespace).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if version != nil {
+		query = query.Where("version = ?", *version)
+	}
+
+	result := query.Delete(&core.UserKV{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if version != nil {
+			return ErrorVersionMismatch
+		}
+		return core.NewErrorNotFound()
+	}
+	return nil
 }
 
-// Upsert updates a userkv
-func (r *repository) Upsert(ctx context.Context, owner, key, value string) error {
-	ctx, span := tracer.Start(ctx, "UserKV.Repository.Upsert")
+// List returns the live entries of a namespace whose key starts with the prefix, ordered by key
+func (r *repository) List(ctx context.Context, owner, namespace, prefix string, limit int) ([]core.UserKV, error) {
+	ctx, span := tracer.Start(ctx, "UserKV.Repository.List")
 	defer span.End()
 
-	kv := &core.UserKV{
-		Owner: owner,
-		Key:   key,
-		Value: value,
+	var kvs []core.UserKV
+	err := r.db.WithContext(ctx).
+		Where("owner = ? AND namespace = ? AND key LIKE ?", owner, namespace, escapeLike(storageKey(namespace, prefix))+"%").
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("key ASC").
+		Limit(limit).
+		Find(&kvs).Error
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
 	}
 
-	return r.db.Save(kv).Error
+	for i := range kvs {
+		kvs[i] = entryKey(kvs[i])
+	}
+	return kvs, nil
+}
+
+// Sweep deletes expired entries and returns how many were removed
+func (r *repository) Sweep(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "UserKV.Repository.Sweep")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&core.UserKV{})
+	if result.Error != nil {
+		span.RecordError(result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
 }
 
 // Clean deletes all userkvs for a given owner
@@ -59,5 +209,5 @@ func (r *repository) Clean(ctx context.Context, ccid string) error {
 	ctx, span := tracer.Start(ctx, "UserKV.Repository.Clean")
 	defer span.End()
 
-	return r.db.Where("owner = ?", ccid).Delete(&core.UserKV{}).Error
+	return r.db.WithContext(ctx).Where("owner = ?", ccid).Delete(&core.UserKV{}).Error
 }
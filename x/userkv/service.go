@@ -2,12 +2,51 @@ package userkv
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/internal/logging"
 )
 
+const (
+	maxKeyLength = 256
+	maxTTL       = 365 * 24 * time.Hour
+
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+var namespacePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+var (
+	// ErrorInvalidKey is returned for namespaces, keys and options which can't be stored
+	ErrorInvalidKey = core.NewErrorInvalidArgument("invalid key")
+	// ErrorVersionMismatch is returned when a conditional write doesn't match the stored version
+	ErrorVersionMismatch = errors.New("version mismatch")
+)
+
+// PutOptions are the options of a write
+type PutOptions struct {
+	// TTL expires the entry after the duration. zero keeps it forever
+	TTL time.Duration
+	// Version makes the write conditional. 0 only creates the entry, otherwise the stored version must match
+	Version *int64
+}
+
 // Service is the interface for userkv service
 type Service interface {
 	Get(ctx context.Context, userID string, key string) (string, error)
 	Upsert(ctx context.Context, userID string, key string, value string) error
+	Lookup(ctx context.Context, owner, namespace, key string) (core.UserKV, error)
+	Put(ctx context.Context, owner, namespace, key, value string, opts PutOptions) (core.UserKV, error)
+	Delete(ctx context.Context, owner, namespace, key string, version *int64) error
+	List(ctx context.Context, owner, namespace, prefix string, limit int) ([]core.UserKV, error)
+	Sweep(ctx context.Context) error
 	Clean(ctx context.Context, ccid string) error
 }
 
@@ -20,12 +59,30 @@ func NewService(repository Repository) Service {
 	return &service{repository: repository}
 }
 
+// validate checks the namespace and the key. the empty namespace holds the flat keys
+func validate(namespace, key string) error {
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("%w: invalid namespace", ErrorInvalidKey)
+	}
+	if key == "" || len(key) > maxKeyLength {
+		return fmt.Errorf("%w: key must be 1 to %d bytes", ErrorInvalidKey, maxKeyLength)
+	}
+	if strings.Contains(key, "/") {
+		return fmt.Errorf("%w: key must not contain '/'", ErrorInvalidKey)
+	}
+	return nil
+}
+
 // Get returns a userkv by ID
 func (s *service) Get(ctx context.Context, userID string, key string) (string, error) {
 	ctx, span := tracer.Start(ctx, "UserKV.Service.Get")
 	defer span.End()
 
-	return s.repository.Get(ctx, userID, key)
+	kv, err := s.Lookup(ctx, userID, "", key)
+	if err != nil {
+		return "", err
+	}
+	return kv.Value, nil
 }
 
 // Upsert updates a userkv
@@ -33,7 +90,92 @@ func (s *service) Upsert(ctx context.Context, userID string, key string, value s
 	ctx, span := tracer.Start(ctx, "UserKV.Service.Upsert")
 	defer span.End()
 
-	return s.repository.Upsert(ctx, userID, key, value)
+	_, err := s.Put(ctx, userID, "", key, value, PutOptions{})
+	return err
+}
+
+// Lookup returns an entry of a namespace
+func (s *service) Lookup(ctx context.Context, owner, namespace, key string) (core.UserKV, error) {
+	ctx, span := tracer.Start(ctx, "UserKV.Service.Lookup")
+	defer span.End()
+
+	if err := validate(namespace, key); err != nil {
+		return core.UserKV{}, err
+	}
+	return s.repository.Get(ctx, owner, namespace, key)
+}
+
+// Put writes an entry of a namespace and returns it with its new version
+func (s *service) Put(ctx context.Context, owner, namespace, key, value string, opts PutOptions) (core.UserKV, error) {
+	ctx, span := tracer.Start(ctx, "UserKV.Service.Put")
+	defer span.End()
+
+	if err := validate(namespace, key); err != nil {
+		return core.UserKV{}, err
+	}
+	if opts.TTL < 0 || opts.TTL > maxTTL {
+		return core.UserKV{}, fmt.Errorf("%w: ttl must be between 0 and %s", ErrorInvalidKey, maxTTL)
+	}
+	if opts.Version != nil && *opts.Version < 0 {
+		return core.UserKV{}, fmt.Errorf("%w: invalid version", ErrorInvalidKey)
+	}
+
+	kv := core.UserKV{
+		Owner:     owner,
+		Namespace: namespace,
+		Key:       key,
+		Value:     value,
+	}
+	if opts.TTL > 0 {
+		expiresAt := time.Now().Add(opts.TTL)
+		kv.ExpiresAt = &expiresAt
+	}
+
+	return s.repository.Put(ctx, kv, opts.Version)
+}
+
+// Delete removes an entry of a namespace. with version, the stored version must match
+func (s *service) Delete(ctx context.Context, owner, namespace, key string, version *int64) error {
+	ctx, span := tracer.Start(ctx, "UserKV.Service.Delete")
+	defer span.End()
+
+	if err := validate(namespace, key); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, owner, namespace, key, version)
+}
+
+// List returns the entries of a namespace whose key starts with the prefix
+func (s *service) List(ctx context.Context, owner, namespace, prefix string, limit int) ([]core.UserKV, error) {
+	ctx, span := tracer.Start(ctx, "UserKV.Service.List")
+	defer span.End()
+
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		return nil, fmt.Errorf("%w: invalid namespace", ErrorInvalidKey)
+	}
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	return s.repository.List(ctx, owner, namespace, prefix, limit)
+}
+
+// Sweep deletes expired entries
+func (s *service) Sweep(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "UserKV.Service.Sweep")
+	defer span.End()
+
+	removed, err := s.repository.Sweep(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if removed > 0 {
+		logging.Info(ctx, "expired userkv entries removed", slog.String("module", "userkv"), slog.Int64("count", removed))
+	}
+	return nil
 }
 
 // Clean deletes all userkvs for a given owner
@@ -0,0 +1,65 @@
+package userkv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/totegamma/concurrent/core"
+	"github.com/totegamma/concurrent/x/userkv/mock"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, validate("", "flat"))
+	assert.NoError(t, validate("world.concrnt.app", "settings"))
+
+	assert.True(t, errors.Is(validate("bad/namespace", "key"), ErrorInvalidKey))
+	assert.True(t, errors.Is(validate("app", ""), ErrorInvalidKey))
+	assert.True(t, errors.Is(validate("app", "a/b"), ErrorInvalidKey))
+}
+
+func TestPut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_userkv.NewMockRepository(ctrl)
+	repo.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, kv core.UserKV, version *int64) (core.UserKV, error) {
+		assert.Equal(t, "app", kv.Namespace)
+		assert.Equal(t, "draft", kv.Key)
+		assert.NotNil(t, kv.ExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *kv.ExpiresAt, time.Second)
+		assert.Equal(t, int64(3), *version)
+		kv.Version = 4
+		return kv, nil
+	})
+
+	service := NewService(repo)
+
+	version := int64(3)
+	kv, err := service.Put(context.Background(), "con1user", "app", "draft", "hello", PutOptions{TTL: time.Minute, Version: &version})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), kv.Version)
+
+	_, err = service.Put(context.Background(), "con1user", "app", "draft", "hello", PutOptions{TTL: -time.Second})
+	assert.True(t, errors.Is(err, ErrorInvalidKey))
+}
+
+func TestListLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_userkv.NewMockRepository(ctrl)
+	repo.EXPECT().List(gomock.Any(), "con1user", "app", "pref.", maxListLimit).Return(nil, nil)
+	repo.EXPECT().List(gomock.Any(), "con1user", "", "", defaultListLimit).Return(nil, nil)
+
+	service := NewService(repo)
+
+	_, err := service.List(context.Background(), "con1user", "app", "pref.", 100000)
+	assert.NoError(t, err)
+	_, err = service.List(context.Background(), "con1user", "", "", 0)
+	assert.NoError(t, err)
+}